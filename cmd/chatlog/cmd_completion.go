@@ -0,0 +1,97 @@
+package chatlog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sjzar/chatlog/internal/chatlog"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+}
+
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish|powershell",
+	Short: "Generate the shell completion script",
+	Long: `Generate the shell completion script for chatlog.
+
+The --talker flag completes user names, remarks and nicknames from the
+contacts in the work dir (--work-dir, or the last used account).
+
+  bash:        source <(chatlog completion bash)
+  zsh:         chatlog completion zsh > "${fpath[1]}/_chatlog"
+  fish:        chatlog completion fish > ~/.config/fish/completions/chatlog.fish
+  powershell:  chatlog completion powershell | Out-String | Invoke-Expression`,
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return rootCmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			return rootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			return rootCmd.GenFishCompletion(os.Stdout, true)
+		case "powershell":
+			return rootCmd.GenPowerShellCompletionWithDesc(os.Stdout)
+		}
+		return fmt.Errorf("unsupported shell: %s", args[0])
+	},
+}
+
+// completeTalker 补全 --talker 参数，候选项来自工作目录中的联系人与群聊
+// 参数以逗号分隔多个会话，只补全最后一项；备注与昵称也可作为会话名称使用
+func completeTalker(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	workDir, _ := cmd.Flags().GetString("work-dir")
+	platform, _ := cmd.Flags().GetString("platform")
+	version, _ := cmd.Flags().GetInt("version")
+
+	m, err := chatlog.New("")
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	m.SetWorkKey(workKey)
+	contacts, err := m.CommandTalkers(workDir, platform, version)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	prefix, cur := "", toComplete
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix, cur = toComplete[:i+1], toComplete[i+1:]
+	}
+	cur = strings.ToLower(cur)
+
+	seen := make(map[string]bool)
+	ret := make([]string, 0)
+	add := func(name, desc string) {
+		if name == "" || seen[name] || strings.ContainsAny(name, ",\t\n") {
+			return
+		}
+		if !strings.HasPrefix(strings.ToLower(name), cur) {
+			return
+		}
+		seen[name] = true
+		if desc != "" {
+			ret = append(ret, prefix+name+"\t"+desc)
+		} else {
+			ret = append(ret, prefix+name)
+		}
+	}
+	for _, contact := range contacts {
+		display := contact.Remark
+		if display == "" {
+			display = contact.NickName
+		}
+		add(contact.UserName, display)
+		add(contact.Alias, contact.UserName)
+		add(contact.Remark, contact.UserName)
+		add(contact.NickName, contact.UserName)
+	}
+	return ret, cobra.ShellCompDirectiveNoFileComp
+}
@@ -5,6 +5,8 @@ import (
 	"runtime"
 
 	"github.com/sjzar/chatlog/internal/chatlog"
+	"github.com/sjzar/chatlog/internal/chatlog/wechat"
+	"github.com/sjzar/chatlog/pkg/util"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -17,6 +19,9 @@ func init() {
 	decryptCmd.Flags().StringVarP(&key, "key", "k", "", "key")
 	decryptCmd.Flags().StringVarP(&decryptPlatform, "platform", "p", runtime.GOOS, "platform")
 	decryptCmd.Flags().IntVarP(&decryptVer, "version", "v", 3, "version")
+	decryptCmd.Flags().StringVar(&workKey, "work-key", "", "passphrase for encrypting the work dir, defaults to $CHATLOG_WORK_KEY")
+	decryptCmd.Flags().StringVar(&decryptOnly, "only", "", "decrypt only these database groups, separated by commas, e.g. message,contact,session")
+	decryptCmd.Flags().StringVar(&decryptSince, "since", "", "decrypt only message shards modified within an age (30d, 6m, 1y) or since a date (2024-01-01)")
 }
 
 var (
@@ -25,6 +30,9 @@ var (
 	key             string
 	decryptPlatform string
 	decryptVer      int
+	workKey         string
+	decryptOnly     string
+	decryptSince    string
 )
 
 var decryptCmd = &cobra.Command{
@@ -36,10 +44,20 @@ var decryptCmd = &cobra.Command{
 			log.Err(err).Msg("failed to create chatlog instance")
 			return
 		}
-		if err := m.CommandDecrypt(dataDir, workDir, key, decryptPlatform, decryptVer); err != nil {
+		m.SetWorkKey(workKey)
+		opts := wechat.DecryptOptions{Groups: util.Str2List(decryptOnly, ",")}
+		if decryptSince != "" {
+			t, ok := util.CutoffOf(decryptSince)
+			if !ok {
+				log.Error().Msgf("invalid --since: %s", decryptSince)
+				return
+			}
+			opts.Since = t
+		}
+		if err := m.CommandDecrypt(dataDir, workDir, key, decryptPlatform, decryptVer, opts); err != nil {
 			log.Err(err).Msg("failed to decrypt")
 			return
 		}
-		fmt.Println("decrypt success")
+		printOutput(map[string]bool{"success": true}, func() { fmt.Println("decrypt success") })
 	},
 }
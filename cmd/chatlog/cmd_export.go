@@ -0,0 +1,82 @@
+package chatlog
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/sjzar/chatlog/internal/chatlog"
+	"github.com/sjzar/chatlog/internal/chatlog/export"
+	"github.com/sjzar/chatlog/pkg/i18n"
+	"github.com/sjzar/chatlog/pkg/util"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVarP(&exportWorkDir, "work-dir", "w", "", "work dir")
+	exportCmd.Flags().StringVarP(&exportPlatform, "platform", "p", runtime.GOOS, "platform")
+	exportCmd.Flags().IntVarP(&exportVer, "version", "v", 3, "version")
+	exportCmd.Flags().StringVar(&exportTalker, "talker", "", "talkers to export, separated by commas, tag:<name> for tagged chats, defaults to all sessions")
+	exportCmd.RegisterFlagCompletionFunc("talker", completeTalker)
+	exportCmd.Flags().StringVar(&exportTime, "time", "all", "time range, e.g. 2023, 2023-01~2023-06, last-7d")
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", export.FormatHTML, "output format: html, csv or json")
+	exportCmd.Flags().StringVarP(&exportOut, "out", "o", "", "output dir, one file per talker")
+	exportCmd.Flags().StringVar(&exportHost, "host", "127.0.0.1:5030", "server address used in media links")
+	exportCmd.Flags().StringVar(&exportLang, "lang", "", "language of the text content and headers: zh or en")
+	exportCmd.Flags().StringVar(&workKey, "work-key", "", "passphrase for the encrypted work dir, defaults to $CHATLOG_WORK_KEY")
+}
+
+var (
+	exportWorkDir  string
+	exportPlatform string
+	exportVer      int
+	exportTalker   string
+	exportTime     string
+	exportFormat   string
+	exportOut      string
+	exportHost     string
+	exportLang     string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export chat history to files without starting the HTTP server",
+	Run: func(cmd *cobra.Command, args []string) {
+		start, end, ok := util.TimeRangeOf(exportTime)
+		if !ok {
+			log.Error().Msgf("invalid --time: %s", exportTime)
+			return
+		}
+		opts := export.Options{
+			Talkers: util.Str2List(exportTalker, ","),
+			Start:   start,
+			End:     end,
+			Format:  exportFormat,
+			OutDir:  exportOut,
+			Host:    exportHost,
+			Lang:    i18n.Parse(exportLang),
+		}
+
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		m.SetWorkKey(workKey)
+		report, err := m.CommandExport(exportWorkDir, exportPlatform, exportVer, opts)
+		if report != nil && Output == OutputTable {
+			for _, item := range report.Items {
+				fmt.Printf("%-40s %8d messages  %s\n", item.Talker, item.Messages, item.File)
+			}
+		}
+		if err != nil {
+			log.Err(err).Msg("failed to export")
+			return
+		}
+		printOutput(report, func() {
+			fmt.Printf("exported %d messages from %d talkers\n", report.Messages, len(report.Items))
+		})
+	},
+}
@@ -0,0 +1,57 @@
+package chatlog
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sjzar/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(importIOSCmd)
+	importIOSCmd.Flags().StringVarP(&importBackupDir, "backup", "b", "", "iTunes / Finder backup dir (the one containing Manifest.db)")
+	importIOSCmd.Flags().StringVarP(&importWorkDir, "work-dir", "w", "", "work dir")
+	importIOSCmd.Flags().StringVarP(&importAccount, "account", "a", "", "account dir name (MD5 of the WeChat ID), required when the backup has several accounts")
+	importIOSCmd.Flags().StringVar(&importPassword, "password", "", "password of the encrypted backup, defaults to $CHATLOG_BACKUP_PASSWORD")
+	importIOSCmd.Flags().StringVar(&workKey, "work-key", "", "passphrase for encrypting the work dir, defaults to $CHATLOG_WORK_KEY")
+}
+
+var (
+	importBackupDir string
+	importWorkDir   string
+	importAccount   string
+	importPassword  string
+)
+
+// envBackupPassword 加密 iOS 备份的密码，避免在命令行中明文传入
+const envBackupPassword = "CHATLOG_BACKUP_PASSWORD"
+
+var importIOSCmd = &cobra.Command{
+	Use:   "import-ios",
+	Short: "Import chat history from an iOS backup",
+	Long:  "Import messages, contacts, chat rooms, images and videos of WeChat from an iTunes / Finder backup into the work dir.\nEncrypted backups are decrypted with --password or $CHATLOG_BACKUP_PASSWORD.\nThe work dir is laid out like a decrypted macOS WeChat 3.x account; serve it with -p darwin -v 3 and use the work dir as data dir.",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		m.SetWorkKey(workKey)
+		if importPassword == "" {
+			importPassword = os.Getenv(envBackupPassword)
+		}
+		report, err := m.CommandImportIOS(importBackupDir, importWorkDir, importAccount, importPassword)
+		if err != nil {
+			log.Err(err).Msg("failed to import backup")
+			return
+		}
+		printOutput(report, func() {
+			fmt.Printf("imported account %s: %d messages in %d sessions, %d contacts, %d chat rooms, %d media files\n",
+				report.Account, report.Messages, report.Talkers, report.Contacts, report.ChatRooms, report.Media)
+			fmt.Printf("serve with: chatlog server -w %s -d %s -p darwin -v 3\n", importWorkDir, importWorkDir)
+		})
+	},
+}
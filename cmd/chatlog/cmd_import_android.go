@@ -0,0 +1,53 @@
+package chatlog
+
+import (
+	"fmt"
+
+	"github.com/sjzar/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(importAndroidCmd)
+	importAndroidCmd.Flags().StringVar(&importAndroidDB, "db", "", "path of EnMicroMsg.db")
+	importAndroidCmd.Flags().StringVar(&importAndroidUIN, "uin", "", "uin of the account (default_uin in shared_prefs/system_config_prefs.xml)")
+	importAndroidCmd.Flags().StringVar(&importAndroidIMEI, "imei", "", "IMEI used to derive the key, defaults to 1234567890ABCDEF")
+	importAndroidCmd.Flags().StringVarP(&importAndroidKey, "key", "k", "", "7-char database key, overrides --uin and --imei")
+	importAndroidCmd.Flags().StringVar(&importAndroidMedia, "media-dir", "", "account dir under MicroMsg on the phone storage (containing image2 and video)")
+	importAndroidCmd.Flags().StringVarP(&importWorkDir, "work-dir", "w", "", "work dir")
+	importAndroidCmd.Flags().StringVar(&workKey, "work-key", "", "passphrase for encrypting the work dir, defaults to $CHATLOG_WORK_KEY")
+}
+
+var (
+	importAndroidDB    string
+	importAndroidUIN   string
+	importAndroidIMEI  string
+	importAndroidKey   string
+	importAndroidMedia string
+)
+
+var importAndroidCmd = &cobra.Command{
+	Use:   "import-android",
+	Short: "Import chat history from an Android EnMicroMsg.db",
+	Long:  "Decrypt an Android WeChat EnMicroMsg.db with the key derived from uin and IMEI, and import messages, contacts, chat rooms, images and videos into the work dir.\nThe work dir is laid out like a decrypted macOS WeChat 3.x account; serve it with -p darwin -v 3 and use the work dir as data dir.",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		m.SetWorkKey(workKey)
+		report, err := m.CommandImportAndroid(importAndroidDB, importWorkDir, importAndroidKey, importAndroidUIN, importAndroidIMEI, importAndroidMedia)
+		if err != nil {
+			log.Err(err).Msg("failed to import android database")
+			return
+		}
+		printOutput(report, func() {
+			fmt.Printf("imported %d messages in %d sessions, %d contacts, %d chat rooms, %d media files\n",
+				report.Messages, report.Talkers, report.Contacts, report.ChatRooms, report.Media)
+			fmt.Printf("serve with: chatlog server -w %s -d %s -p darwin -v 3\n", importWorkDir, importWorkDir)
+		})
+	},
+}
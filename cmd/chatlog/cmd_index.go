@@ -0,0 +1,45 @@
+package chatlog
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/sjzar/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(indexCmd)
+	indexCmd.Flags().StringVarP(&indexWorkDir, "work-dir", "w", "", "work dir")
+	indexCmd.Flags().StringVarP(&indexPlatform, "platform", "p", runtime.GOOS, "platform")
+	indexCmd.Flags().IntVarP(&indexVer, "version", "v", 3, "version")
+}
+
+var (
+	indexWorkDir  string
+	indexPlatform string
+	indexVer      int
+)
+
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Build helper indexes, message stats and a full-text index in the work dir",
+	Long:  "Build talker and time indexes on message tables in the work dir to speed up queries by time range,\nplus sender indexes on WeChat v4 to speed up sender filters,\nthen summarize messages per hour and sender into chatlog_stats.db for the stats and heatmap APIs.\nFinally build a full-text index of message text into chatlog_search.db, used by keyword searches.\nIndexes are lost when the databases are decrypted again, and messages newer than the full-text index\nare matched one by one; run this command again afterwards.",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		n, talkers, err := m.CommandIndex(indexWorkDir, indexPlatform, indexVer)
+		if err != nil {
+			log.Err(err).Msg("failed to build indexes")
+			return
+		}
+		printOutput(map[string]int{"tables": n, "search_talkers": talkers}, func() {
+			fmt.Printf("indexed %d message tables, full-text indexed %d chats\n", n, talkers)
+		})
+	},
+}
@@ -12,9 +12,20 @@ import (
 func init() {
 	rootCmd.AddCommand(keyCmd)
 	keyCmd.Flags().IntVarP(&pid, "pid", "p", 0, "pid")
+	keyCmd.Flags().StringVar(&keyDump, "dump", "", "search the key in a memory dump file instead of a running process")
+	keyCmd.Flags().StringVarP(&dataDir, "data-dir", "d", "", "data dir containing the encrypted database used to validate the key")
+	keyCmd.Flags().StringVar(&keyDB, "db", "", "encrypted database file used to validate the key, overrides --data-dir")
+	keyCmd.Flags().StringVar(&keyPlatform, "platform", "", "platform of the dump, detected from the file format by default")
+	keyCmd.Flags().IntVarP(&keyVersion, "version", "v", 4, "version")
 }
 
-var pid int
+var (
+	pid         int
+	keyDump     string
+	keyDB       string
+	keyPlatform string
+	keyVersion  int
+)
 var keyCmd = &cobra.Command{
 	Use:   "key",
 	Short: "key",
@@ -24,11 +35,16 @@ var keyCmd = &cobra.Command{
 			log.Err(err).Msg("failed to create chatlog instance")
 			return
 		}
-		ret, err := m.CommandKey(pid)
+		var ret string
+		if keyDump != "" {
+			ret, err = m.CommandKeyFromDump(keyDump, dataDir, keyDB, keyPlatform, keyVersion)
+		} else {
+			ret, err = m.CommandKey(pid)
+		}
 		if err != nil {
 			log.Err(err).Msg("failed to get key")
 			return
 		}
-		fmt.Println(ret)
+		printOutput(map[string]string{"key": ret}, func() { fmt.Println(ret) })
 	},
 }
@@ -0,0 +1,49 @@
+package chatlog
+
+import (
+	"runtime"
+
+	"github.com/sjzar/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(mcpCmd)
+	mcpCmd.Flags().BoolVar(&mcpStdio, "stdio", false, "serve MCP over stdin/stdout")
+	mcpCmd.Flags().StringVarP(&mcpDataDir, "data-dir", "d", "", "data dir")
+	mcpCmd.Flags().StringVarP(&mcpWorkDir, "work-dir", "w", "", "work dir")
+	mcpCmd.Flags().StringVarP(&mcpPlatform, "platform", "p", runtime.GOOS, "platform")
+	mcpCmd.Flags().IntVarP(&mcpVer, "version", "v", 3, "version")
+	mcpCmd.Flags().StringVar(&workKey, "work-key", "", "passphrase for the encrypted work dir, defaults to $CHATLOG_WORK_KEY")
+}
+
+var (
+	mcpStdio    bool
+	mcpDataDir  string
+	mcpWorkDir  string
+	mcpPlatform string
+	mcpVer      int
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Run the MCP server over stdio for desktop clients",
+	Long:  "Run the MCP server over stdin/stdout without starting the HTTP server, so MCP clients can launch chatlog as a subprocess. Logs are written to stderr.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if !mcpStdio {
+			log.Error().Msg("only --stdio is supported, use `chatlog server` for the SSE endpoint")
+			return
+		}
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		m.SetWorkKey(workKey)
+		if err := m.CommandMCPStdio(mcpDataDir, mcpWorkDir, mcpPlatform, mcpVer); err != nil {
+			log.Err(err).Msg("mcp stdio server stopped")
+		}
+	},
+}
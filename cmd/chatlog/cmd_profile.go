@@ -0,0 +1,112 @@
+package chatlog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sjzar/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileListCmd, profileUseCmd)
+	profileUseCmd.Flags().BoolVar(&profileNone, "none", false, "stop using profiles and fall back to the last used account")
+}
+
+var profileNone bool
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "List and switch the profiles defined in the config file",
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles, the current one is marked with *",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		profiles := m.CommandProfiles()
+		printOutput(profiles, func() { printProfiles(profiles) })
+	},
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:               "use <name>",
+	Short:             "Use the profile by default in later commands",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeProfile,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 && !profileNone {
+			log.Error().Msg("profile name or --none is required")
+			return
+		}
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		if err := m.CommandUseProfile(name); err != nil {
+			log.Err(err).Msg("failed to switch profile")
+			return
+		}
+		printOutput(map[string]string{"profile": name}, func() {
+			if name == "" {
+				fmt.Println("profiles disabled")
+				return
+			}
+			fmt.Printf("using profile %s\n", name)
+		})
+	},
+}
+
+func printProfiles(profiles []*chatlog.ProfileInfo) {
+	if len(profiles) == 0 {
+		fmt.Println("no profiles, add them to the profiles section of the config file")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tNAME\tACCOUNT\tPLATFORM\tVERSION\tADDR\tWORK DIR")
+	for _, p := range profiles {
+		mark := ""
+		if p.Current {
+			mark = "*"
+		}
+		version := ""
+		if p.Version != 0 {
+			version = fmt.Sprint(p.Version)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", mark, p.Name, p.Account, p.Platform, version, p.Addr, p.WorkDir)
+	}
+	w.Flush()
+}
+
+// completeProfile 补全配置文件中的 profile 名称
+func completeProfile(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	m, err := chatlog.New("")
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var names []string
+	for _, p := range m.CommandProfiles() {
+		if strings.HasPrefix(p.Name, toComplete) {
+			names = append(names, p.Name+"\t"+p.WorkDir)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
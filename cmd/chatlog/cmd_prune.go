@@ -0,0 +1,77 @@
+package chatlog
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/sjzar/chatlog/internal/chatlog"
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/pkg/util"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+	pruneCmd.Flags().StringVarP(&pruneWorkDir, "work-dir", "w", "", "work dir")
+	pruneCmd.Flags().StringVarP(&prunePlatform, "platform", "p", runtime.GOOS, "platform")
+	pruneCmd.Flags().IntVarP(&pruneVer, "version", "v", 3, "version")
+	pruneCmd.Flags().StringVar(&pruneBefore, "before", "", "delete messages older than an age (180d, 26w, 6m, 1y) or a date (2024-01-01)")
+	pruneCmd.Flags().StringVar(&pruneTalker, "talker", "", "only prune these talkers, separated by commas")
+	pruneCmd.RegisterFlagCompletionFunc("talker", completeTalker)
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "report what would be removed without deleting anything")
+	pruneCmd.Flags().StringVar(&workKey, "work-key", "", "passphrase for the encrypted work dir, defaults to $CHATLOG_WORK_KEY")
+}
+
+var (
+	pruneWorkDir  string
+	prunePlatform string
+	pruneVer      int
+	pruneBefore   string
+	pruneTalker   string
+	pruneDryRun   bool
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete old messages or selected talkers from the work dir",
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := database.PruneOptions{
+			Talkers: util.Str2List(pruneTalker, ","),
+			DryRun:  pruneDryRun,
+		}
+		if pruneBefore != "" {
+			t, ok := util.CutoffOf(pruneBefore)
+			if !ok {
+				log.Error().Msgf("invalid --before: %s", pruneBefore)
+				return
+			}
+			opts.Before = t
+		}
+
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		m.SetWorkKey(workKey)
+		report, err := m.CommandPrune(pruneWorkDir, prunePlatform, pruneVer, opts)
+		if report != nil && Output == OutputTable {
+			for _, item := range report.Items {
+				fmt.Printf("%-40s %8d messages %6d media  %s\n", item.Talker, item.Messages, item.Media, item.Name)
+			}
+		}
+		if err != nil {
+			log.Err(err).Msg("failed to prune")
+			return
+		}
+		printOutput(report, func() {
+			if report.DryRun {
+				fmt.Printf("dry run: %d messages (%d media) in %d talkers would be removed\n", report.Messages, report.Media, len(report.Items))
+				return
+			}
+			fmt.Printf("removed %d messages (%d media) from %d talkers\n", report.Messages, report.Media, len(report.Items))
+		})
+	},
+}
@@ -0,0 +1,115 @@
+package chatlog
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/sjzar/chatlog/internal/chatlog"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/i18n"
+	"github.com/sjzar/chatlog/pkg/util"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().StringVarP(&searchWorkDir, "work-dir", "w", "", "work dir")
+	searchCmd.Flags().StringVarP(&searchPlatform, "platform", "p", runtime.GOOS, "platform")
+	searchCmd.Flags().IntVarP(&searchVer, "version", "v", 3, "version")
+	searchCmd.Flags().StringVar(&searchTalker, "talker", "", "talkers to search, separated by commas, defaults to all")
+	searchCmd.RegisterFlagCompletionFunc("talker", completeTalker)
+	searchCmd.Flags().StringVar(&searchTime, "time", "all", "time range, e.g. 2023, last-month, last-7d")
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 50, "max number of matches, the most recent are shown")
+	searchCmd.Flags().IntVarP(&searchContext, "context", "C", 2, "number of messages shown before and after each match")
+	searchCmd.Flags().BoolVar(&searchJSON, "json", false, "print results as JSON")
+	searchCmd.Flags().MarkDeprecated("json", "use --output json instead")
+	searchCmd.Flags().StringVar(&searchHost, "host", "127.0.0.1:5030", "server address used in media links")
+	searchCmd.Flags().StringVar(&searchLang, "lang", "", "language of the text content: zh or en")
+	searchCmd.Flags().StringVar(&workKey, "work-key", "", "passphrase for the encrypted work dir, defaults to $CHATLOG_WORK_KEY")
+}
+
+var (
+	searchWorkDir  string
+	searchPlatform string
+	searchVer      int
+	searchTalker   string
+	searchTime     string
+	searchLimit    int
+	searchContext  int
+	searchJSON     bool
+	searchHost     string
+	searchLang     string
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <keyword>",
+	Short: "Search chat history and print matches with context",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		start, end, ok := util.TimeRangeOf(searchTime)
+		if !ok {
+			log.Error().Msgf("invalid --time: %s", searchTime)
+			return
+		}
+		if searchLimit <= 0 {
+			log.Error().Msgf("invalid --limit: %d", searchLimit)
+			return
+		}
+		if searchContext < 0 {
+			log.Error().Msgf("invalid --context: %d", searchContext)
+			return
+		}
+
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		m.SetWorkKey(workKey)
+		results, err := m.CommandSearch(searchWorkDir, searchPlatform, searchVer, args[0], searchTalker, start, end, searchLimit, searchContext)
+		if err != nil {
+			log.Err(err).Msg("failed to search")
+			return
+		}
+
+		if searchJSON {
+			Output = OutputJSON
+		}
+		if Output != OutputTable {
+			for _, result := range results {
+				result.Message.SetMediaURLs("http://" + searchHost)
+				for _, msg := range result.Context {
+					msg.SetMediaURLs("http://" + searchHost)
+				}
+			}
+		}
+		printOutput(results, func() { printSearchResults(results) })
+	},
+}
+
+// printSearchResults 与 grep 类似，匹配消息以 "> " 标记，不同匹配之间以 "--" 分隔
+func printSearchResults(results []*chatlog.SearchResult) {
+	lang := i18n.Parse(searchLang)
+	for i, result := range results {
+		if i > 0 {
+			fmt.Println("--")
+		}
+		messages, anchor := result.Context, result.Anchor
+		if len(messages) == 0 {
+			messages, anchor = []*model.Message{result.Message}, 0
+		}
+		for j, msg := range messages {
+			if lang != "" {
+				msg.SetContent("lang", lang)
+			}
+			if j == anchor {
+				fmt.Print("> ")
+			}
+			fmt.Println(msg.PlainText(true, "2006-01-02 15:04:05", searchHost))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d matches\n", len(results))
+}
@@ -1,43 +1,199 @@
 package chatlog
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/sjzar/chatlog/internal/chatlog"
+	"github.com/sjzar/chatlog/internal/chatlog/conf"
+	"github.com/sjzar/chatlog/internal/chatlog/http"
+	"github.com/sjzar/chatlog/pkg/autostart"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 )
 
 func init() {
 	rootCmd.AddCommand(serverCmd)
-	serverCmd.Flags().StringVarP(&serverAddr, "addr", "a", "127.0.0.1:5030", "server address")
+	serverCmd.Flags().StringVarP(&serverAddr, "addr", "a", "127.0.0.1:5030", "server address, host:port or unix:<socket path>")
 	serverCmd.Flags().StringVarP(&serverDataDir, "data-dir", "d", "", "data dir")
 	serverCmd.Flags().StringVarP(&serverWorkDir, "work-dir", "w", "", "work dir")
 	serverCmd.Flags().StringVarP(&serverPlatform, "platform", "p", runtime.GOOS, "platform")
 	serverCmd.Flags().IntVarP(&serverVer, "version", "v", 3, "version")
+	serverCmd.Flags().StringVarP(&serverReportsDir, "reports-dir", "r", "", "analysis reports dir")
+	serverCmd.Flags().StringVar(&workKey, "work-key", "", "passphrase for the encrypted work dir, defaults to $CHATLOG_WORK_KEY")
+	serverCmd.Flags().BoolVar(&serverReadOnly, "read-only", false, "disable export, report generation, job submission and download-by-path endpoints")
+	serverCmd.Flags().BoolVar(&serverPprof, "debug-pprof", false, "serve net/http/pprof profiles under /debug/pprof/, requires http.auth.password")
+	serverCmd.Flags().StringArrayVar(&serverListen, "listen", nil, "additional address to listen on, host:port or unix:<socket path>, can be repeated")
+	serverCmd.Flags().StringVar(&serverBasePath, "base-path", "", "path prefix when served behind a reverse proxy, e.g. /chatlog")
+	serverCmd.Flags().StringVar(&serverTLSCert, "tls-cert", "", "TLS certificate file")
+	serverCmd.Flags().StringVar(&serverTLSKey, "tls-key", "", "TLS private key file")
+	serverCmd.Flags().BoolVar(&serverTLSSelfSigned, "tls-self-signed", false, "serve HTTPS with a generated self-signed certificate")
+	serverCmd.Flags().StringVar(&serverTLSRedirect, "tls-redirect", "", "address to redirect plain HTTP requests to HTTPS, e.g. :80")
+	serverCmd.Flags().StringVar(&serverTLSClientCA, "tls-client-ca", "", "CA bundle for verifying client certificates on API and MCP endpoints")
+	serverCmd.Flags().BoolVar(&serverDaemon, "daemon", false, "run the server in the background")
+	serverCmd.Flags().StringVar(&serverPIDFile, "pid-file", defaultPIDFile(), "pid file of the background server, written when --daemon or --pid-file is set")
+	serverCmd.Flags().StringVar(&serverLogFile, "log-file", defaultDaemonLog(), "log file of the background server or Windows service")
+
+	serverCmd.AddCommand(serverStopCmd)
+	serverStopCmd.Flags().StringVar(&serverPIDFile, "pid-file", defaultPIDFile(), "pid file of the background server")
 }
 
 var (
-	serverAddr     string
-	serverDataDir  string
-	serverWorkDir  string
-	serverPlatform string
-	serverVer      int
+	serverAddr       string
+	serverDataDir    string
+	serverWorkDir    string
+	serverPlatform   string
+	serverVer        int
+	serverReportsDir string
+	serverReadOnly   bool
+	serverBasePath   string
+	serverListen     []string
+	serverPprof      bool
+
+	serverTLSCert       string
+	serverTLSKey        string
+	serverTLSSelfSigned bool
+	serverTLSRedirect   string
+	serverTLSClientCA   string
+
+	serverDaemon  bool
+	serverPIDFile string
+	serverLogFile string
 )
 
+// serverStopWait 等待后台服务退出的最长时间，包含处理进行中请求的时间
+const serverStopWait = http.ShutdownTimeout + 5*time.Second
+
+type daemonResult struct {
+	PID     int    `json:"pid"`
+	PIDFile string `json:"pid_file"`
+	LogFile string `json:"log_file,omitempty"`
+}
+
 var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "Start HTTP server",
 	Run: func(cmd *cobra.Command, args []string) {
+		pidFile, _ := filepath.Abs(serverPIDFile)
+		if serverDaemon {
+			if pid, err := readPIDFile(pidFile); err != nil || pid != 0 {
+				if err == nil {
+					err = fmt.Errorf("server is already running, pid %d (%s)", pid, pidFile)
+				}
+				log.Err(err).Msg("failed to start server")
+				return
+			}
+			logFile, _ := filepath.Abs(serverLogFile)
+			daemonArgs := stripFlags(os.Args[1:], []string{"--daemon"}, []string{"--pid-file", "--work-key"})
+			daemonArgs = append(daemonArgs, "--pid-file", pidFile)
+			pid, err := startDaemon(daemonArgs, logFile, workKey)
+			if err != nil {
+				log.Err(err).Msg("failed to start server")
+				return
+			}
+			result := &daemonResult{PID: pid, PIDFile: pidFile, LogFile: logFile}
+			printOutput(result, func() {
+				fmt.Printf("server started in background, pid %d, log %s\n", pid, logFile)
+			})
+			return
+		}
+		// Windows 服务没有标准输出，日志写入 --log-file
+		if autostart.IsService() {
+			if out, err := openLogFile(serverLogFile); err == nil {
+				defer out.Close()
+				log.Logger = log.Output(zerolog.ConsoleWriter{Out: out, NoColor: true, TimeFormat: time.RFC3339})
+			}
+		}
+		if cmd.Flags().Changed("pid-file") {
+			if err := writePIDFile(pidFile); err != nil {
+				log.Err(err).Msg("failed to start server")
+				return
+			}
+			defer os.Remove(pidFile)
+		}
+
 		m, err := chatlog.New("")
 		if err != nil {
 			log.Err(err).Msg("failed to create chatlog instance")
 			return
 		}
-		if err := m.CommandHTTPServer(serverAddr, serverDataDir, serverWorkDir, serverPlatform, serverVer); err != nil {
+		applyServerConfig(cmd, m.ServerConfig())
+		if serverReadOnly {
+			m.SetReadOnly(true)
+		}
+		m.SetBasePath(serverBasePath)
+		m.SetListen(serverListen)
+		m.SetPprof(serverPprof)
+		m.SetWorkKey(workKey)
+		if err := m.SetTLS(serverTLSCert, serverTLSKey, serverTLSSelfSigned, serverTLSRedirect, serverTLSClientCA); err != nil {
+			log.Err(err).Msg("failed to start server")
+			return
+		}
+		run := func() error {
+			return m.CommandHTTPServer(serverAddr, serverDataDir, serverWorkDir, serverPlatform, serverVer, serverReportsDir)
+		}
+		if err := autostart.Run(chatlog.ServiceName, run, m.Shutdown); err != nil {
 			log.Err(err).Msg("failed to start server")
 			return
 		}
 	},
 }
+
+// applyServerConfig 未在命令行中指定的参数使用配置文件中 server 的值
+func applyServerConfig(cmd *cobra.Command, c conf.ServerConfig) {
+	flags := cmd.Flags()
+	if !flags.Changed("addr") && c.Addr != "" {
+		serverAddr = c.Addr
+	}
+	if !flags.Changed("data-dir") && c.DataDir != "" {
+		serverDataDir = c.DataDir
+	}
+	if !flags.Changed("work-dir") && c.WorkDir != "" {
+		serverWorkDir = c.WorkDir
+	}
+	if !flags.Changed("platform") && c.Platform != "" {
+		serverPlatform = c.Platform
+	}
+	if !flags.Changed("version") && c.Version != 0 {
+		serverVer = c.Version
+	}
+}
+
+var serverStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the background server started with --daemon",
+	Run: func(cmd *cobra.Command, args []string) {
+		pidFile, _ := filepath.Abs(serverPIDFile)
+		pid, err := readPIDFile(pidFile)
+		if err != nil {
+			log.Err(err).Msg("failed to stop server")
+			return
+		}
+		if pid == 0 {
+			log.Error().Msgf("server is not running (%s)", pidFile)
+			return
+		}
+		if err := terminate(pid); err != nil {
+			log.Err(err).Msg("failed to stop server")
+			return
+		}
+		// 等待服务处理完进行中的请求并退出
+		deadline := time.Now().Add(serverStopWait)
+		for processAlive(pid) {
+			if time.Now().After(deadline) {
+				log.Error().Msgf("server did not exit within %s, pid %d", serverStopWait, pid)
+				return
+			}
+			time.Sleep(200 * time.Millisecond)
+		}
+		os.Remove(pidFile)
+		printOutput(&daemonResult{PID: pid, PIDFile: pidFile}, func() {
+			fmt.Printf("server stopped, pid %d\n", pid)
+		})
+	},
+}
@@ -0,0 +1,111 @@
+package chatlog
+
+import (
+	"fmt"
+
+	"github.com/sjzar/chatlog/internal/chatlog"
+	"github.com/sjzar/chatlog/pkg/autostart"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(serviceCmd)
+	serviceCmd.AddCommand(serviceInstallCmd, serviceUninstallCmd, serviceStatusCmd)
+	serviceInstallCmd.Flags().StringVarP(&serviceAddr, "addr", "a", "", "server address, defaults to the configured address or 127.0.0.1:5030")
+	serviceInstallCmd.Flags().StringVarP(&serviceDataDir, "data-dir", "d", "", "data dir, defaults to the last used account")
+	serviceInstallCmd.Flags().StringVarP(&serviceWorkDir, "work-dir", "w", "", "work dir, defaults to the last used account")
+	serviceInstallCmd.Flags().StringVarP(&servicePlatform, "platform", "p", "", "platform, defaults to the last used account")
+	serviceInstallCmd.Flags().IntVarP(&serviceVer, "version", "v", 0, "version, defaults to the last used account")
+	serviceInstallCmd.Flags().StringVarP(&serviceReportsDir, "reports-dir", "r", "", "analysis reports dir")
+	serviceInstallCmd.Flags().StringVar(&serviceLogFile, "log-file", defaultDaemonLog(), "log file of the service on macOS and Windows")
+}
+
+var (
+	serviceAddr       string
+	serviceDataDir    string
+	serviceWorkDir    string
+	servicePlatform   string
+	serviceVer        int
+	serviceReportsDir string
+	serviceLogFile    string
+)
+
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Run the server as a systemd user unit, launchd agent or Windows service",
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Register the server to start on login and start it now",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		m.SetWorkKey(workKey)
+		status, err := m.CommandServiceInstall(serviceAddr, serviceDataDir, serviceWorkDir, servicePlatform, serviceVer, serviceReportsDir, serviceLogFile)
+		if err != nil {
+			log.Err(err).Msg("failed to install service")
+			return
+		}
+		printOutput(status, func() { printServiceStatus(status) })
+	},
+}
+
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop the server and remove it from the startup services",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		if err := m.CommandServiceUninstall(); err != nil {
+			log.Err(err).Msg("failed to uninstall service")
+			return
+		}
+		printOutput(map[string]bool{"uninstalled": true}, func() { fmt.Println("service uninstalled") })
+	},
+}
+
+var serviceStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the server service is installed and running",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		status, err := m.CommandServiceStatus()
+		if err != nil {
+			log.Err(err).Msg("failed to query service")
+			return
+		}
+		printOutput(status, func() { printServiceStatus(status) })
+	},
+}
+
+func printServiceStatus(s *autostart.Status) {
+	fmt.Printf("Service:   %s\n", s.Name)
+	if s.File != "" {
+		fmt.Printf("File:      %s\n", s.File)
+	}
+	if !s.Installed {
+		fmt.Println("Status:    not installed")
+		return
+	}
+	switch {
+	case s.Running && s.PID > 0:
+		fmt.Printf("Status:    running, pid %d\n", s.PID)
+	case s.Running:
+		fmt.Println("Status:    running")
+	default:
+		fmt.Println("Status:    stopped")
+	}
+}
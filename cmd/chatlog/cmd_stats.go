@@ -0,0 +1,146 @@
+package chatlog
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/sjzar/chatlog/internal/chatlog"
+	"github.com/sjzar/chatlog/internal/chatlog/analysis"
+	"github.com/sjzar/chatlog/pkg/util"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	statsCmd.Flags().StringVarP(&statsWorkDir, "work-dir", "w", "", "work dir")
+	statsCmd.Flags().StringVarP(&statsPlatform, "platform", "p", runtime.GOOS, "platform")
+	statsCmd.Flags().IntVarP(&statsVer, "version", "v", 3, "version")
+	statsCmd.Flags().StringVar(&statsTalker, "talker", "", "talkers to count, separated by commas, tag:<name> for tagged chats, defaults to all")
+	statsCmd.RegisterFlagCompletionFunc("talker", completeTalker)
+	statsCmd.Flags().StringVar(&statsTime, "time", "last-30d", "time range, e.g. 2023, last-month, last-7d, all")
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "print results as JSON")
+	statsCmd.Flags().MarkDeprecated("json", "use --output json instead")
+	statsCmd.Flags().StringVar(&workKey, "work-key", "", "passphrase for the encrypted work dir, defaults to $CHATLOG_WORK_KEY")
+}
+
+var (
+	statsWorkDir  string
+	statsPlatform string
+	statsVer      int
+	statsTalker   string
+	statsTime     string
+	statsJSON     bool
+)
+
+// statsBarWidth 活跃度曲线中最长的条形宽度
+const statsBarWidth = 40
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Print message counts, top chats and activity of the chat history",
+	Run: func(cmd *cobra.Command, args []string) {
+		start, end, ok := util.TimeRangeOf(statsTime)
+		if !ok {
+			log.Error().Msgf("invalid --time: %s", statsTime)
+			return
+		}
+
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		m.SetWorkKey(workKey)
+		result, err := m.CommandStats(statsWorkDir, statsPlatform, statsVer, statsTalker, start, end)
+		if err != nil {
+			log.Err(err).Msg("failed to count messages")
+			return
+		}
+
+		if statsJSON {
+			Output = OutputJSON
+		}
+		printOutput(result, func() { printStats(result) })
+	},
+}
+
+func printStats(r *chatlog.StatsResult) {
+	metrics := r.Metrics
+	if r.Talker != "" {
+		name := r.Talker
+		if r.Name != "" {
+			name = fmt.Sprintf("%s (%s)", r.Name, r.Talker)
+		}
+		fmt.Printf("Talker:         %s\n", name)
+	} else {
+		fmt.Printf("Sessions:       %d\n", r.Sessions)
+		fmt.Printf("Contacts:       %d\n", r.Contacts)
+		fmt.Printf("Chat rooms:     %d\n", r.ChatRooms)
+	}
+	if metrics.MessageCount == 0 {
+		fmt.Println("Messages:       0")
+		return
+	}
+	fmt.Printf("Range:          %s ~ %s\n", metrics.FirstTime.Format("2006-01-02 15:00"), metrics.LastTime.Format("2006-01-02 15:00"))
+	fmt.Printf("Messages:       %d (text %d, media %d)\n", metrics.MessageCount, metrics.TextCount, metrics.MediaCount)
+	fmt.Printf("Active members: %d\n", metrics.ActiveMembers)
+	hours := make([]string, 0, len(r.PeakHours))
+	for _, h := range r.PeakHours {
+		hours = append(hours, fmt.Sprintf("%02d:00", h))
+	}
+	fmt.Printf("Peak hours:     %s\n", strings.Join(hours, ", "))
+
+	if len(r.TopChats) > 0 {
+		fmt.Println("\nTop chats:")
+		for i, chat := range r.TopChats {
+			name := chat.Talker
+			if chat.Name != "" {
+				name = fmt.Sprintf("%s (%s)", chat.Name, chat.Talker)
+			}
+			fmt.Printf("%3d. %-50s %8d\n", i+1, name, chat.MessageCount)
+		}
+	}
+
+	if len(metrics.TopSenders) > 0 {
+		fmt.Println("\nTop senders:")
+		for i, sender := range metrics.TopSenders {
+			name := sender.Sender
+			if sender.SenderName != "" {
+				name = fmt.Sprintf("%s (%s)", sender.SenderName, sender.Sender)
+			}
+			fmt.Printf("%3d. %-50s %8d\n", i+1, name, sender.Count)
+		}
+	}
+
+	if len(metrics.TopKeywords) > 0 {
+		words := make([]string, 0, len(metrics.TopKeywords))
+		for _, k := range metrics.TopKeywords {
+			words = append(words, fmt.Sprintf("%s(%d)", k.Word, k.Count))
+		}
+		fmt.Printf("\nTop keywords:   %s\n", strings.Join(words, " "))
+	}
+
+	printActivity(metrics.Activity, metrics.Granularity)
+}
+
+// printActivity 以条形图输出活跃度曲线
+func printActivity(points []analysis.Point, granularity string) {
+	if len(points) == 0 {
+		return
+	}
+	peak := 0
+	for _, p := range points {
+		peak = max(peak, p.Count)
+	}
+	fmt.Printf("\nActivity (by %s):\n", granularity)
+	for _, p := range points {
+		width := 0
+		if peak > 0 {
+			width = (p.Count*statsBarWidth + peak - 1) / peak
+		}
+		fmt.Printf("%-16s %8d %s\n", p.Label, p.Count, strings.Repeat("#", width))
+	}
+}
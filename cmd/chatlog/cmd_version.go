@@ -2,6 +2,7 @@ package chatlog
 
 import (
 	"fmt"
+	"runtime"
 
 	"github.com/sjzar/chatlog/pkg/version"
 
@@ -18,10 +19,18 @@ var versionCmd = &cobra.Command{
 	Use:   "version [-m]",
 	Short: "Show the version of chatlog",
 	Run: func(cmd *cobra.Command, args []string) {
-		if versionM {
-			fmt.Println(version.GetMore(true))
-		} else {
-			fmt.Printf("chatlog %s\n", version.GetMore(false))
+		ret := map[string]string{
+			"version": version.Version,
+			"go":      runtime.Version(),
+			"os":      runtime.GOOS,
+			"arch":    runtime.GOARCH,
 		}
+		printOutput(ret, func() {
+			if versionM {
+				fmt.Println(version.GetMore(true))
+			} else {
+				fmt.Printf("chatlog %s\n", version.GetMore(false))
+			}
+		})
 	},
 }
@@ -0,0 +1,130 @@
+package chatlog
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// daemonStartWait 后台进程启动后等待其退出的时间，期间退出视为启动失败
+const daemonStartWait = time.Second
+
+// defaultPIDFile 与 defaultDaemonLog 为后台运行时的默认 pid 文件与日志文件
+func defaultPIDFile() string {
+	return filepath.Join(util.DefaultWorkDir(""), "chatlog.pid")
+}
+
+func defaultDaemonLog() string {
+	return filepath.Join(util.DefaultWorkDir(""), "chatlog-server.log")
+}
+
+// readPIDFile 读取 pid 文件，进程已退出时删除文件并返回 0
+func readPIDFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid pid file %s: %w", path, err)
+	}
+	if !processAlive(pid) {
+		os.Remove(path)
+		return 0, nil
+	}
+	return pid, nil
+}
+
+// writePIDFile 写入当前进程的 pid，文件中的进程仍在运行时返回错误
+func writePIDFile(path string) error {
+	pid, err := readPIDFile(path)
+	if err != nil {
+		return err
+	}
+	if pid != 0 && pid != os.Getpid() {
+		return fmt.Errorf("server is already running, pid %d (%s)", pid, path)
+	}
+	if err := util.PrepareDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
+// startDaemon 以 args 参数在后台重新启动当前程序，标准输出与错误写入 logFile
+// 工作目录口令通过环境变量传递，不出现在进程参数中
+func startDaemon(args []string, logFile string, workKey string) (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	out, err := openLogFile(logFile)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.Env = os.Environ()
+	if workKey != "" {
+		cmd.Env = append(cmd.Env, ctx.EnvWorkKey+"="+workKey)
+	}
+	cmd.SysProcAttr = daemonAttr()
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	select {
+	case err := <-exited:
+		if err == nil {
+			err = fmt.Errorf("exited")
+		}
+		return 0, fmt.Errorf("server %v, see %s", err, logFile)
+	case <-time.After(daemonStartWait):
+	}
+	return cmd.Process.Pid, nil
+}
+
+// openLogFile 以追加方式打开日志文件，目录不存在时创建
+func openLogFile(path string) (*os.File, error) {
+	if err := util.PrepareDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+}
+
+// stripFlags 删除参数中的指定选项，withValue 的选项同时删除以空格分隔的取值
+func stripFlags(args []string, names []string, withValue []string) []string {
+	ret := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name := strings.SplitN(arg, "=", 2)[0]
+		if slices.Contains(names, name) {
+			continue
+		}
+		if slices.Contains(withValue, name) {
+			if !strings.Contains(arg, "=") {
+				i++
+			}
+			continue
+		}
+		ret = append(ret, arg)
+	}
+	return ret
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package chatlog
+
+import (
+	"os"
+	"syscall"
+)
+
+// daemonAttr 使后台进程脱离当前终端会话
+func daemonAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+// terminate 发送 SIGTERM，服务处理完进行中的请求后退出
+func terminate(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Signal(syscall.SIGTERM)
+}
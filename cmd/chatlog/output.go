@@ -0,0 +1,69 @@
+package chatlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// 命令结果的输出格式，table 为便于阅读的文本，json 与 yaml 供脚本处理
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+var Output string
+
+// validOutput 检查 --output 参数，在命令执行前调用
+func validOutput() error {
+	switch Output {
+	case OutputTable, OutputJSON, OutputYAML:
+		return nil
+	}
+	return fmt.Errorf("invalid --output: %s, available: table, json, yaml", Output)
+}
+
+// printOutput 按 --output 输出命令结果，table 时调用 table 输出文本
+// yaml 由 json 编码转换而来，字段名与 json 及 HTTP 接口一致
+func printOutput(v interface{}, table func()) {
+	switch Output {
+	case OutputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			log.Err(err).Msg("failed to write output")
+		}
+	case OutputYAML:
+		data, err := json.Marshal(v)
+		if err != nil {
+			log.Err(err).Msg("failed to write output")
+			return
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			log.Err(err).Msg("failed to write output")
+			return
+		}
+		blockStyle(&node)
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(&node); err != nil {
+			log.Err(err).Msg("failed to write output")
+		}
+		enc.Close()
+	default:
+		table()
+	}
+}
+
+// blockStyle 将 json 解析得到的流式节点改为块格式，字符串的引号由编码器按需添加
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
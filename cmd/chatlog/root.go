@@ -1,7 +1,10 @@
 package chatlog
 
 import (
+	"os"
+
 	"github.com/sjzar/chatlog/internal/chatlog"
+	"github.com/sjzar/chatlog/internal/chatlog/conf"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -12,9 +15,22 @@ func init() {
 	cobra.MousetrapHelpText = ""
 
 	rootCmd.PersistentFlags().BoolVar(&Debug, "debug", false, "debug")
-	rootCmd.PersistentPreRun = initLog
+	rootCmd.PersistentFlags().StringVar(&Output, "output", OutputTable, "output format of command results: table, json or yaml")
+	rootCmd.PersistentFlags().StringVar(&Profile, "profile", "", "use the named profile from the config file, defaults to $CHATLOG_PROFILE or the one set by 'profile use'")
+	rootCmd.RegisterFlagCompletionFunc("profile", completeProfile)
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		initLog(cmd, args)
+		// 通过环境变量传给配置加载，后台运行的服务进程同样生效
+		if Profile != "" {
+			os.Setenv(conf.EnvPrefix+"_PROFILE", Profile)
+		}
+		return validOutput()
+	}
 }
 
+// Profile 本次使用的 profile
+var Profile string
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		log.Err(err).Msg("command execution failed")
@@ -28,7 +44,7 @@ var rootCmd = &cobra.Command{
 	Example: `chatlog`,
 	Args:    cobra.MinimumNArgs(0),
 	CompletionOptions: cobra.CompletionOptions{
-		HiddenDefaultCmd: true,
+		DisableDefaultCmd: true,
 	},
 	PreRun: initTuiLog,
 	Run:    Root,
@@ -0,0 +1,117 @@
+package analysis
+
+import (
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/redact"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// CorpusRecord 匿名语料中的一条消息
+type CorpusRecord struct {
+	Conversation string    `json:"conversation"`
+	Group        bool      `json:"group"`
+	Speaker      string    `json:"speaker"`
+	Self         bool      `json:"self"`
+	Time         time.Time `json:"time"`
+	Type         string    `json:"type"`
+	Text         string    `json:"text"`
+}
+
+// CorpusOptions 匿名语料选项
+type CorpusOptions struct {
+	// Jitter 每个会话的时间整体随机偏移的最大值，为 0 时不偏移
+	Jitter time.Duration
+
+	// Media 保留多媒体消息的占位符，如 <image>，为 false 时丢弃多媒体消息
+	Media bool
+}
+
+// CorpusBuilder 生成可用于研究或模型训练的匿名语料：
+// 会话与发言人使用固定的化名，正文脱敏并去除链接，多媒体替换为占位符，时间按会话整体随机偏移并精确到分钟
+type CorpusBuilder struct {
+	r    *redact.Redactor
+	opts CorpusOptions
+}
+
+// NewCorpusBuilder r 应已设置联系人与群成员名称，以便替换正文中提到的名字
+func NewCorpusBuilder(r *redact.Redactor, opts CorpusOptions) *CorpusBuilder {
+	return &CorpusBuilder{r: r, opts: opts}
+}
+
+// Records 转换一个会话的消息，会话内使用相同的时间偏移，保留消息顺序与间隔
+func (b *CorpusBuilder) Records(talker string, messages []*model.Message) []*CorpusRecord {
+	var offset time.Duration
+	if b.opts.Jitter > 0 {
+		offset = time.Duration(rand.Int64N(int64(2*b.opts.Jitter))) - b.opts.Jitter
+	}
+
+	conversation := b.r.ID(talker)
+	records := make([]*CorpusRecord, 0, len(messages))
+	for _, m := range messages {
+		_type, text := corpusText(m)
+		if _type == "" || (text == "" && !b.opts.Media) {
+			continue
+		}
+		if text == "" {
+			text = "<" + _type + ">"
+		} else {
+			text = b.r.Text(stripURLs(text))
+		}
+		records = append(records, &CorpusRecord{
+			Conversation: conversation,
+			Group:        m.IsChatRoom,
+			Speaker:      b.r.ID(m.Sender),
+			Self:         m.IsSelf,
+			Time:         m.Time.Add(offset).Truncate(time.Minute),
+			Type:         _type,
+			Text:         text,
+		})
+	}
+	return records
+}
+
+// corpusText 消息类型与可用作语料的文本，多媒体消息文本为空，系统消息等不纳入语料的类型返回空类型
+func corpusText(m *model.Message) (string, string) {
+	switch m.Type {
+	case 1:
+		return "text", m.Content
+	case 3:
+		return "image", ""
+	case 34:
+		return "voice", ""
+	case 42:
+		return "card", ""
+	case 43:
+		return "video", ""
+	case 47:
+		return "emoji", ""
+	case 48:
+		return "location", ""
+	case 49:
+		switch m.SubType {
+		case 5:
+			title, _ := m.Contents["title"].(string)
+			return "link", title
+		case 6:
+			return "file", ""
+		case 8:
+			return "emoji", ""
+		case 57:
+			// 只保留回复内容，不包含被引用的消息
+			return "quote", m.Content
+		}
+	}
+	return "", ""
+}
+
+// stripURLs 链接中常带有用户标识，替换为占位符
+func stripURLs(text string) string {
+	for _, u := range util.ExtractURLs(text) {
+		text = strings.ReplaceAll(text, u, "<url>")
+	}
+	return text
+}
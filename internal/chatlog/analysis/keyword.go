@@ -0,0 +1,129 @@
+package analysis
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// KeywordStat 关键词及出现次数
+type KeywordStat struct {
+	Word  string `json:"word"`
+	Count int    `json:"count"`
+	Watch bool   `json:"watch,omitempty"` // 是否为关注词
+}
+
+// Keywords 统计文本中的高频词
+// 英文、数字按单词切分，中文按相邻两字切分（bigram），适合在没有分词词典的情况下粗略提取话题词
+// n 为返回数量上限（<=0 表示不限制），关注词只要出现就会追加到结果中，不受数量与次数限制
+func Keywords(contents []string, rules *KeywordRules, n int) []KeywordStat {
+	if rules == nil {
+		rules = DefaultKeywordRules
+	}
+
+	counts := make(map[string]int)
+	for _, content := range contents {
+		// 同一条消息中重复出现的词只计一次，避免刷屏影响结果
+		seen := make(map[string]bool)
+		for _, token := range tokenize(content, rules.MinLength) {
+			if seen[token] || rules.isStopword(token) {
+				continue
+			}
+			seen[token] = true
+			counts[token]++
+		}
+	}
+
+	stats := make([]KeywordStat, 0, len(counts))
+	for word, count := range counts {
+		if count < rules.MinCount {
+			continue
+		}
+		stats = append(stats, KeywordStat{Word: word, Count: count})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		return stats[i].Word < stats[j].Word
+	})
+	if n > 0 && len(stats) > n {
+		stats = stats[:n]
+	}
+
+	return appendWatched(stats, contents, rules.Watch)
+}
+
+// appendWatched 统计关注词出现的消息数，标记或追加到结果中
+func appendWatched(stats []KeywordStat, contents []string, watch []string) []KeywordStat {
+	for _, w := range watch {
+		word := strings.ToLower(strings.TrimSpace(w))
+		if word == "" {
+			continue
+		}
+		count := 0
+		for _, content := range contents {
+			if strings.Contains(strings.ToLower(content), word) {
+				count++
+			}
+		}
+		if count == 0 {
+			continue
+		}
+
+		found := false
+		for i := range stats {
+			if stats[i].Word == word {
+				stats[i].Watch = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			stats = append(stats, KeywordStat{Word: word, Count: count, Watch: true})
+		}
+	}
+	return stats
+}
+
+// tokenize 将文本切分为候选关键词，英文、数字单词长度需不小于 minLength
+func tokenize(content string, minLength int) []string {
+	if minLength < 2 {
+		minLength = 2
+	}
+	tokens := make([]string, 0)
+	word := strings.Builder{}
+	han := make([]rune, 0)
+
+	flushWord := func() {
+		if utf8.RuneCountInString(word.String()) >= minLength {
+			tokens = append(tokens, strings.ToLower(word.String()))
+		}
+		word.Reset()
+	}
+	flushHan := func() {
+		for i := 0; i+1 < len(han); i++ {
+			tokens = append(tokens, string(han[i:i+2]))
+		}
+		han = han[:0]
+	}
+
+	for _, r := range content {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word.WriteRune(r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+
+	return tokens
+}
@@ -0,0 +1,376 @@
+package analysis
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/chatlog/conf"
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+// 活跃度曲线粒度
+const (
+	GranularityHour  = "hour"
+	GranularityDay   = "day"
+	GranularityMonth = "month"
+)
+
+// Metrics 一组消息的统计指标
+type Metrics struct {
+	MessageCount   int           `json:"message_count"`
+	TextCount      int           `json:"text_count"`
+	MediaCount     int           `json:"media_count"`
+	ActiveMembers  int           `json:"active_members"`
+	FirstTime      time.Time     `json:"first_time"`
+	LastTime       time.Time     `json:"last_time"`
+	TopSenders     []SenderStat  `json:"top_senders"`
+	TopKeywords    []KeywordStat `json:"top_keywords"`
+	HourlyActivity [24]int       `json:"hourly_activity"`
+	Granularity    string        `json:"granularity"`
+	Activity       []Point       `json:"activity"`
+}
+
+// SenderStat 发送人消息数量
+type SenderStat struct {
+	Sender     string `json:"sender"`
+	SenderName string `json:"sender_name,omitempty"`
+	Count      int    `json:"count"`
+}
+
+// Point 活跃度曲线上的一个点
+type Point struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// Options 统计选项
+type Options struct {
+	TopN     int           // 发送人、关键词的返回数量
+	Keywords *KeywordRules // 关键词提取规则，为空时使用默认规则
+}
+
+var DefaultOptions = Options{
+	TopN:     10,
+	Keywords: DefaultKeywordRules,
+}
+
+// OptionsOf 根据关键词配置生成统计选项，停用词文件读取失败时仅使用内置停用词
+func OptionsOf(c conf.KeywordConfig) Options {
+	stopwords := append([]string{}, c.Stopwords...)
+	if c.StopwordsFile != "" {
+		words, err := LoadStopwords(c.StopwordsFile)
+		if err != nil {
+			log.Err(err).Msgf("load stopwords file %s failed", c.StopwordsFile)
+		}
+		stopwords = append(stopwords, words...)
+	}
+	return Options{
+		TopN:     DefaultOptions.TopN,
+		Keywords: NewKeywordRules(stopwords, c.MinLength, c.MinCount, c.Watch),
+	}
+}
+
+// IsMedia 判断消息是否为多媒体消息（图片、语音、视频、表情、文件）
+func IsMedia(msg *model.Message) bool {
+	return msg.IsMedia()
+}
+
+// GranularityOf 根据时间跨度选择活跃度曲线的粒度
+func GranularityOf(start, end time.Time) string {
+	span := end.Sub(start)
+	switch {
+	case span <= 48*time.Hour:
+		return GranularityHour
+	case span <= 92*24*time.Hour:
+		return GranularityDay
+	default:
+		return GranularityMonth
+	}
+}
+
+// Compute 计算消息的统计指标，start/end 用于生成完整的活跃度曲线（无消息的时间段计为 0）
+func Compute(messages []*model.Message, start, end time.Time, opts Options) *Metrics {
+	if opts.TopN <= 0 {
+		opts.TopN = DefaultOptions.TopN
+	}
+	if opts.Keywords == nil {
+		opts.Keywords = DefaultKeywordRules
+	}
+
+	m := &Metrics{
+		MessageCount: len(messages),
+		TopSenders:   []SenderStat{},
+		TopKeywords:  []KeywordStat{},
+		Activity:     []Point{},
+	}
+
+	senders := make(map[string]*SenderStat)
+	texts := make([]string, 0)
+	times := make([]time.Time, 0, len(messages))
+	for _, msg := range messages {
+		times = append(times, msg.Time)
+		if m.FirstTime.IsZero() || msg.Time.Before(m.FirstTime) {
+			m.FirstTime = msg.Time
+		}
+		if msg.Time.After(m.LastTime) {
+			m.LastTime = msg.Time
+		}
+		m.HourlyActivity[msg.Time.Hour()]++
+
+		switch {
+		case msg.Type == 1:
+			m.TextCount++
+			texts = append(texts, msg.Content)
+		case IsMedia(msg):
+			m.MediaCount++
+		}
+
+		if msg.Type == 10000 || msg.Sender == "" {
+			continue
+		}
+		stat, ok := senders[msg.Sender]
+		if !ok {
+			stat = &SenderStat{Sender: msg.Sender, SenderName: msg.SenderName}
+			senders[msg.Sender] = stat
+		}
+		stat.Count++
+	}
+
+	m.ActiveMembers = len(senders)
+	for _, stat := range senders {
+		m.TopSenders = append(m.TopSenders, *stat)
+	}
+	sort.Slice(m.TopSenders, func(i, j int) bool {
+		if m.TopSenders[i].Count != m.TopSenders[j].Count {
+			return m.TopSenders[i].Count > m.TopSenders[j].Count
+		}
+		return m.TopSenders[i].Sender < m.TopSenders[j].Sender
+	})
+	if len(m.TopSenders) > opts.TopN {
+		m.TopSenders = m.TopSenders[:opts.TopN]
+	}
+
+	m.TopKeywords = Keywords(texts, opts.Keywords, opts.TopN)
+
+	// 时间范围为 all 时，以实际消息时间作为曲线范围
+	if start.Year() <= 1970 || end.Year() >= 9999 {
+		start, end = m.FirstTime, m.LastTime
+	}
+	m.Granularity = GranularityOf(start, end)
+	m.Activity = activityCurve(times, start, end, m.Granularity)
+
+	return m
+}
+
+// Heatmap 按星期与小时统计消息数量，第一维为星期（0 为周日），第二维为小时
+func Heatmap(messages []*model.Message) [7][24]int {
+	var ret [7][24]int
+	for _, msg := range messages {
+		ret[msg.Time.Weekday()][msg.Time.Hour()]++
+	}
+	return ret
+}
+
+// ComputeStats 根据按小时汇总的统计计算指标，texts 为范围内的文本消息，用于提取关键词与发送人名称
+// 时间精确到小时，FirstTime、LastTime 为首末消息所在的整点
+func ComputeStats(stats []*model.HourStat, texts []*model.Message, start, end time.Time, opts Options) *Metrics {
+	if opts.TopN <= 0 {
+		opts.TopN = DefaultOptions.TopN
+	}
+	if opts.Keywords == nil {
+		opts.Keywords = DefaultKeywordRules
+	}
+
+	m := &Metrics{
+		TopSenders:  []SenderStat{},
+		TopKeywords: []KeywordStat{},
+		Activity:    []Point{},
+	}
+
+	senders := make(map[string]*SenderStat)
+	for _, stat := range stats {
+		m.MessageCount += stat.Messages
+		m.TextCount += stat.Texts
+		m.MediaCount += stat.Media
+		if m.FirstTime.IsZero() || stat.Hour.Before(m.FirstTime) {
+			m.FirstTime = stat.Hour
+		}
+		if stat.Hour.After(m.LastTime) {
+			m.LastTime = stat.Hour
+		}
+		m.HourlyActivity[stat.Hour.Hour()] += stat.Messages
+
+		if stat.Sender == "" {
+			continue
+		}
+		sender, ok := senders[stat.Sender]
+		if !ok {
+			sender = &SenderStat{Sender: stat.Sender}
+			senders[stat.Sender] = sender
+		}
+		sender.Count += stat.Messages
+	}
+
+	contents := make([]string, 0, len(texts))
+	for _, msg := range texts {
+		contents = append(contents, msg.Content)
+		if sender, ok := senders[msg.Sender]; ok && sender.SenderName == "" {
+			sender.SenderName = msg.SenderName
+		}
+	}
+
+	m.ActiveMembers = len(senders)
+	for _, sender := range senders {
+		m.TopSenders = append(m.TopSenders, *sender)
+	}
+	sort.Slice(m.TopSenders, func(i, j int) bool {
+		if m.TopSenders[i].Count != m.TopSenders[j].Count {
+			return m.TopSenders[i].Count > m.TopSenders[j].Count
+		}
+		return m.TopSenders[i].Sender < m.TopSenders[j].Sender
+	})
+	if len(m.TopSenders) > opts.TopN {
+		m.TopSenders = m.TopSenders[:opts.TopN]
+	}
+
+	m.TopKeywords = Keywords(contents, opts.Keywords, opts.TopN)
+
+	if start.Year() <= 1970 || end.Year() >= 9999 {
+		start, end = m.FirstTime, m.LastTime
+	}
+	m.Granularity = GranularityOf(start, end)
+	format, _, _ := bucketOf(m.Granularity)
+	counts := make(map[string]int)
+	for _, stat := range stats {
+		counts[stat.Hour.Format(format)] += stat.Messages
+	}
+	m.Activity = curveOf(counts, start, end, m.Granularity)
+
+	return m
+}
+
+// HeatmapOf 根据按小时汇总的统计按星期与小时统计消息数量，与 Heatmap 的结构相同
+func HeatmapOf(stats []*model.HourStat) [7][24]int {
+	var ret [7][24]int
+	for _, stat := range stats {
+		ret[stat.Hour.Weekday()][stat.Hour.Hour()] += stat.Messages
+	}
+	return ret
+}
+
+// DayCount 单日消息数量
+type DayCount struct {
+	Date  string `json:"date"` // 本地日期，如 2023-01-02
+	Count int    `json:"count"`
+}
+
+// CalendarOf 根据按小时汇总的统计按本地日期统计消息数量，只返回有消息的日期，按日期升序排列
+func CalendarOf(stats []*model.HourStat) []DayCount {
+	counts := make(map[string]int)
+	for _, stat := range stats {
+		if stat.Messages > 0 {
+			counts[stat.Hour.Format("2006-01-02")] += stat.Messages
+		}
+	}
+	ret := make([]DayCount, 0, len(counts))
+	for date, count := range counts {
+		ret = append(ret, DayCount{Date: date, Count: count})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Date < ret[j].Date })
+	return ret
+}
+
+// TopChatsOf 根据按小时汇总的统计按会话汇总消息数量，返回消息最多的 n 个会话，名称与关键词由调用方补充
+func TopChatsOf(stats []*model.HourStat, n int) []ChatStat {
+	chats := make(map[string]*ChatStat)
+	senders := make(map[string]map[string]bool)
+	days := make(map[string]map[string]bool)
+	for _, stat := range stats {
+		chat, ok := chats[stat.Talker]
+		if !ok {
+			chat = &ChatStat{Talker: stat.Talker, IsChatRoom: strings.HasSuffix(stat.Talker, "@chatroom"), TopKeywords: []KeywordStat{}}
+			chats[stat.Talker] = chat
+			senders[stat.Talker] = make(map[string]bool)
+			days[stat.Talker] = make(map[string]bool)
+		}
+		chat.MessageCount += stat.Messages
+		chat.TextCount += stat.Texts
+		chat.MediaCount += stat.Media
+		if stat.Sender != "" {
+			senders[stat.Talker][stat.Sender] = true
+		}
+		days[stat.Talker][stat.Hour.Format("2006-01-02")] = true
+	}
+
+	ret := make([]ChatStat, 0, len(chats))
+	for talker, chat := range chats {
+		chat.ActiveMembers = len(senders[talker])
+		chat.ActiveDays = len(days[talker])
+		ret = append(ret, *chat)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].MessageCount != ret[j].MessageCount {
+			return ret[i].MessageCount > ret[j].MessageCount
+		}
+		return ret[i].Talker < ret[j].Talker
+	})
+	if n > 0 && len(ret) > n {
+		ret = ret[:n]
+	}
+	return ret
+}
+
+// activityCurve 按粒度统计时间范围内每个时间段的消息数量
+func activityCurve(times []time.Time, start, end time.Time, granularity string) []Point {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return []Point{}
+	}
+
+	format, _, _ := bucketOf(granularity)
+	counts := make(map[string]int)
+	for _, t := range times {
+		counts[t.Format(format)]++
+	}
+	return curveOf(counts, start, end, granularity)
+}
+
+// curveOf 按粒度生成时间范围内每个时间段的点，counts 为各时间段标签对应的消息数量
+func curveOf(counts map[string]int, start, end time.Time, granularity string) []Point {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return []Point{}
+	}
+
+	format, next, truncate := bucketOf(granularity)
+	points := make([]Point, 0)
+	for t := truncate(start); !t.After(end); t = next(t) {
+		label := t.Format(format)
+		points = append(points, Point{Label: label, Count: counts[label]})
+	}
+	return points
+}
+
+func bucketOf(granularity string) (string, func(time.Time) time.Time, func(time.Time) time.Time) {
+	switch granularity {
+	case GranularityHour:
+		return "2006-01-02 15:00",
+			func(t time.Time) time.Time { return t.Add(time.Hour) },
+			func(t time.Time) time.Time {
+				return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+			}
+	case GranularityMonth:
+		return "2006-01",
+			func(t time.Time) time.Time { return t.AddDate(0, 1, 0) },
+			func(t time.Time) time.Time {
+				return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+			}
+	default:
+		return "2006-01-02",
+			func(t time.Time) time.Time { return t.AddDate(0, 0, 1) },
+			func(t time.Time) time.Time {
+				return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+			}
+	}
+}
@@ -0,0 +1,99 @@
+package analysis
+
+import (
+	"sort"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+// 消息量趋势
+const (
+	TrendUp   = "up"
+	TrendDown = "down"
+	TrendFlat = "flat"
+)
+
+// Profile 联系人的聊天画像
+type Profile struct {
+	Metrics     *Metrics `json:"metrics"`
+	MediaRatio  float64  `json:"media_ratio"`  // 多媒体消息占比
+	SelfRatio   float64  `json:"self_ratio"`   // 自己发送的消息占比
+	PeakHours   []int    `json:"peak_hours"`   // 最活跃的时段（小时）
+	Trend       string   `json:"trend"`        // 消息量趋势：up/down/flat
+	TrendChange float64  `json:"trend_change"` // 后半段相对前半段的消息量变化比例
+}
+
+// BuildProfile 基于统计指标生成聊天画像
+func BuildProfile(messages []*model.Message, start, end time.Time, opts Options) *Profile {
+	m := Compute(messages, start, end, opts)
+	p := &Profile{
+		Metrics:   m,
+		PeakHours: PeakHours(m.HourlyActivity, 3),
+		Trend:     TrendFlat,
+	}
+
+	if m.MessageCount == 0 {
+		return p
+	}
+
+	self := 0
+	for _, msg := range messages {
+		if msg.IsSelf {
+			self++
+		}
+	}
+	p.MediaRatio = float64(m.MediaCount) / float64(m.MessageCount)
+	p.SelfRatio = float64(self) / float64(m.MessageCount)
+	p.Trend, p.TrendChange = trendOf(m.Activity)
+
+	return p
+}
+
+// PeakHours 返回消息数最多的 n 个小时（按消息数降序），不包含无消息的时段
+func PeakHours(hourly [24]int, n int) []int {
+	hours := make([]int, 0, 24)
+	for h, count := range hourly {
+		if count > 0 {
+			hours = append(hours, h)
+		}
+	}
+	sort.SliceStable(hours, func(i, j int) bool {
+		return hourly[hours[i]] > hourly[hours[j]]
+	})
+	if len(hours) > n {
+		hours = hours[:n]
+	}
+	return hours
+}
+
+// trendOf 比较活跃度曲线前后两半的消息量，变化超过 20% 视为上升或下降
+func trendOf(points []Point) (string, float64) {
+	if len(points) < 2 {
+		return TrendFlat, 0
+	}
+	half := len(points) / 2
+	before, after := 0, 0
+	for i, p := range points {
+		if i < half {
+			before += p.Count
+		} else if i >= len(points)-half {
+			after += p.Count
+		}
+	}
+	if before == 0 {
+		if after == 0 {
+			return TrendFlat, 0
+		}
+		return TrendUp, 1
+	}
+	change := float64(after-before) / float64(before)
+	switch {
+	case change > 0.2:
+		return TrendUp, change
+	case change < -0.2:
+		return TrendDown, change
+	default:
+		return TrendFlat, change
+	}
+}
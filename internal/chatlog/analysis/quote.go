@@ -0,0 +1,49 @@
+package analysis
+
+import "strings"
+
+// maxQuotes 金句数量上限
+const maxQuotes = 10
+
+// Quote 金句
+type Quote struct {
+	Content string `json:"content"`
+	Index   int    `json:"index"` // 在输入文本中的序号，从 1 开始
+	Length  int    `json:"length"`
+}
+
+// GoldenQuotes 提取金句：优先选择带有感叹、疑问或特定标记的消息，不足时补充较长的消息
+func GoldenQuotes(messages []string) []Quote {
+	quotes := make([]Quote, 0)
+	for i, msg := range messages {
+		if len(msg) <= 15 || len(msg) >= 200 {
+			continue
+		}
+		if strings.Contains(msg, "！") || strings.Contains(msg, "？") ||
+			strings.Contains(msg, "💡") || strings.Contains(msg, "🌟") ||
+			strings.Contains(msg, "金句") || strings.Contains(msg, "经典") {
+			quotes = append(quotes, Quote{Content: msg, Index: i + 1, Length: len(msg)})
+		}
+	}
+
+	if len(quotes) < maxQuotes {
+		seen := make(map[string]bool, len(quotes))
+		for _, q := range quotes {
+			seen[q.Content] = true
+		}
+		for i, msg := range messages {
+			if len(quotes) >= maxQuotes {
+				break
+			}
+			if len(msg) > 30 && !seen[msg] {
+				seen[msg] = true
+				quotes = append(quotes, Quote{Content: msg, Index: i + 1, Length: len(msg)})
+			}
+		}
+	}
+
+	if len(quotes) > maxQuotes {
+		quotes = quotes[:maxQuotes]
+	}
+	return quotes
+}
@@ -0,0 +1,163 @@
+package analysis
+
+import (
+	"sort"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+// Report 全量聊天分析报告
+type Report struct {
+	Time            string        `json:"time"`
+	StartDate       string        `json:"start_date"`
+	EndDate         string        `json:"end_date"`
+	TotalMessages   int           `json:"total_messages"`
+	TotalChats      int           `json:"total_chats"`
+	ActiveChats     int           `json:"active_chats"`
+	TextCount       int           `json:"text_count"`
+	MediaCount      int           `json:"media_count"`
+	SelfCount       int           `json:"self_count"`
+	TopChats        []ChatStat    `json:"top_chats"`
+	TopKeywords     []KeywordStat `json:"top_keywords"`
+	HourlyActivity  [24]int       `json:"hourly_activity"`
+	WeekdayActivity [7]int        `json:"weekday_activity"` // 0 为周日
+	Heatmap         [7][24]int    `json:"heatmap"`          // 星期 x 小时
+	Granularity     string        `json:"granularity"`
+	Activity        []Point       `json:"activity"`
+	GeneratedAt     string        `json:"generated_at"`
+}
+
+// ChatStat 单个会话的统计及内容概要
+type ChatStat struct {
+	Talker        string        `json:"talker"`
+	Name          string        `json:"name"`
+	IsChatRoom    bool          `json:"is_chat_room"`
+	MessageCount  int           `json:"message_count"`
+	TextCount     int           `json:"text_count"`
+	MediaCount    int           `json:"media_count"`
+	ActiveMembers int           `json:"active_members"`
+	ActiveDays    int           `json:"active_days"`
+	TopKeywords   []KeywordStat `json:"top_keywords"`
+}
+
+// ReportBuilder 按会话逐个累加消息生成报告，避免同时持有所有会话的消息
+type ReportBuilder struct {
+	start time.Time
+	end   time.Time
+	opts  Options
+
+	report   *Report
+	chats    []ChatStat
+	times    []time.Time
+	texts    []string
+	maxTexts int
+}
+
+func NewReportBuilder(_time string, start, end time.Time, opts Options) *ReportBuilder {
+	if opts.TopN <= 0 {
+		opts.TopN = DefaultOptions.TopN
+	}
+	if opts.Keywords == nil {
+		opts.Keywords = DefaultKeywordRules
+	}
+	return &ReportBuilder{
+		start: start,
+		end:   end,
+		opts:  opts,
+		report: &Report{
+			Time: _time,
+		},
+		chats:    make([]ChatStat, 0),
+		times:    make([]time.Time, 0),
+		texts:    make([]string, 0),
+		maxTexts: 100000,
+	}
+}
+
+// Add 累加一个会话的消息
+func (b *ReportBuilder) Add(talker, name string, messages []*model.Message) {
+	r := b.report
+	r.TotalChats++
+	if len(messages) == 0 {
+		return
+	}
+	r.ActiveChats++
+
+	m := Compute(messages, b.start, b.end, b.opts)
+	days := make(map[string]bool)
+	isChatRoom := false
+	for _, msg := range messages {
+		b.times = append(b.times, msg.Time)
+		days[msg.Time.Format("2006-01-02")] = true
+		isChatRoom = isChatRoom || msg.IsChatRoom
+		if msg.IsSelf {
+			r.SelfCount++
+		}
+		wd, h := int(msg.Time.Weekday()), msg.Time.Hour()
+		r.WeekdayActivity[wd]++
+		r.Heatmap[wd][h]++
+		if msg.Type == 1 && len(b.texts) < b.maxTexts {
+			b.texts = append(b.texts, msg.Content)
+		}
+	}
+	for h, count := range m.HourlyActivity {
+		r.HourlyActivity[h] += count
+	}
+	r.TotalMessages += m.MessageCount
+	r.TextCount += m.TextCount
+	r.MediaCount += m.MediaCount
+
+	if name == "" {
+		name = messages[0].TalkerName
+	}
+	b.chats = append(b.chats, ChatStat{
+		Talker:        talker,
+		Name:          name,
+		IsChatRoom:    isChatRoom,
+		MessageCount:  m.MessageCount,
+		TextCount:     m.TextCount,
+		MediaCount:    m.MediaCount,
+		ActiveMembers: m.ActiveMembers,
+		ActiveDays:    len(days),
+		TopKeywords:   m.TopKeywords,
+	})
+}
+
+// Build 生成报告
+func (b *ReportBuilder) Build() *Report {
+	r := b.report
+
+	sort.Slice(b.chats, func(i, j int) bool {
+		if b.chats[i].MessageCount != b.chats[j].MessageCount {
+			return b.chats[i].MessageCount > b.chats[j].MessageCount
+		}
+		return b.chats[i].Talker < b.chats[j].Talker
+	})
+	r.TopChats = b.chats
+	if len(r.TopChats) > b.opts.TopN {
+		r.TopChats = r.TopChats[:b.opts.TopN]
+	}
+	r.TopKeywords = Keywords(b.texts, b.opts.Keywords, b.opts.TopN)
+
+	// 时间范围为 all 时，以实际消息时间作为曲线范围
+	start, end := b.start, b.end
+	if start.Year() <= 1970 || end.Year() >= 9999 {
+		start, end = time.Time{}, time.Time{}
+		for _, t := range b.times {
+			if start.IsZero() || t.Before(start) {
+				start = t
+			}
+			if t.After(end) {
+				end = t
+			}
+		}
+	}
+	r.StartDate = start.Format("2006-01-02")
+	r.EndDate = end.Format("2006-01-02")
+	r.Granularity = GranularityOf(start, end)
+	r.Activity = activityCurve(b.times, start, end, r.Granularity)
+	r.GeneratedAt = time.Now().Format("2006-01-02 15:04:05")
+
+	return r
+}
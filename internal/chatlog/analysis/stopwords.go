@@ -0,0 +1,94 @@
+package analysis
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultStopwords 内置停用词
+// 单字停用词用于过滤包含该字的中文双字词（如“的”会过滤“我的”“的话”），多字停用词按整词过滤
+var defaultStopwords = []string{
+	// 单字
+	"的", "了", "是", "我", "你", "他", "她", "它", "们", "这", "那", "就", "都", "也", "在",
+	"吗", "呢", "吧", "啊", "哦", "嗯", "哈", "呀", "啦", "么", "个", "着", "得", "嘛", "哇",
+	// 多字
+	"呵呵", "嘿嘿", "嘻嘻", "什么", "怎么", "没有", "可以", "就是", "还是", "因为", "所以",
+	"但是", "然后", "现在", "知道", "觉得", "一下", "不是", "自己", "大家", "一起", "不过",
+	"真的", "还有", "如果", "已经", "应该", "不要", "这样", "那样",
+	// 英文
+	"the", "and", "or", "of", "to", "is", "are", "in", "on", "for", "it", "that", "this",
+	"you", "we", "he", "she", "they", "be", "was", "were", "have", "has", "ok", "okay",
+	"lol", "http", "https", "www", "com", "cn",
+}
+
+// KeywordRules 关键词提取规则
+type KeywordRules struct {
+	Stopwords map[string]bool
+	MinLength int      // 英文、数字单词的最小长度（中文固定按双字切分）
+	MinCount  int      // 最少出现次数
+	Watch     []string // 关注词，只要出现就会出现在结果中
+}
+
+// DefaultKeywordRules 默认规则：内置停用词，单词至少 2 个字符，至少出现 3 次
+var DefaultKeywordRules = NewKeywordRules(nil, 0, 0, nil)
+
+// NewKeywordRules 创建关键词规则，stopwords 会与内置停用词合并，minLength、minCount 不大于 0 时使用默认值
+func NewKeywordRules(stopwords []string, minLength, minCount int, watch []string) *KeywordRules {
+	if minLength <= 0 {
+		minLength = 2
+	}
+	if minCount <= 0 {
+		minCount = 3
+	}
+	r := &KeywordRules{
+		Stopwords: make(map[string]bool),
+		MinLength: minLength,
+		MinCount:  minCount,
+		Watch:     watch,
+	}
+	for _, list := range [][]string{defaultStopwords, stopwords} {
+		for _, w := range list {
+			if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+				r.Stopwords[w] = true
+			}
+		}
+	}
+	return r
+}
+
+// isStopword 判断候选词是否应被过滤：整词命中，或中文双字词中包含单字停用词
+func (r *KeywordRules) isStopword(token string) bool {
+	if r.Stopwords[token] {
+		return true
+	}
+	if utf8.RuneCountInString(token) == 2 {
+		for _, c := range token {
+			if r.Stopwords[string(c)] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LoadStopwords 读取停用词文件，每行一个词，忽略空行与 # 开头的注释
+func LoadStopwords(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	words := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	return words, scanner.Err()
+}
@@ -1,18 +1,23 @@
 package chatlog
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"time"
 
 	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/internal/ui/browser"
 	"github.com/sjzar/chatlog/internal/ui/footer"
 	"github.com/sjzar/chatlog/internal/ui/form"
 	"github.com/sjzar/chatlog/internal/ui/help"
 	"github.com/sjzar/chatlog/internal/ui/infobar"
 	"github.com/sjzar/chatlog/internal/ui/menu"
 	"github.com/sjzar/chatlog/internal/wechat"
+	"github.com/sjzar/chatlog/pkg/util"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
@@ -37,6 +42,7 @@ type App struct {
 
 	// tab
 	menu      *menu.Menu
+	browser   *browser.Browser
 	help      *help.Help
 	activeTab int
 	tabCount  int
@@ -55,6 +61,9 @@ func NewApp(ctx *ctx.Context, m *Manager) *App {
 		help:        help.New(),
 	}
 
+	app.browser = browser.New(app.Application, &browserSource{m: m})
+	app.browser.OnJump = app.browserJump
+
 	app.initMenu()
 
 	app.updateMenuItemsState()
@@ -74,8 +83,9 @@ func (a *App) Run() error {
 
 	a.tabPages.
 		AddPage("0", a.menu, true, true).
-		AddPage("1", a.help, true, false)
-	a.tabCount = 2
+		AddPage("1", a.browser, true, false).
+		AddPage("2", a.help, true, false)
+	a.tabCount = 3
 
 	a.SetInputCapture(a.inputCapture)
 
@@ -130,6 +140,9 @@ func (a *App) switchTab(step int) {
 	}
 	a.activeTab = index
 	a.tabPages.SwitchToPage(fmt.Sprint(a.activeTab))
+	if a.activeTab == 1 {
+		a.browser.Load()
+	}
 }
 
 func (a *App) refresh() {
@@ -179,7 +192,8 @@ func (a *App) inputCapture(event *tcell.EventKey) *tcell.EventKey {
 		return nil
 	}
 
-	if a.tabPages.HasFocus() {
+	// 在聊天记录搜索框中输入时，左右方向键用于移动光标
+	if a.tabPages.HasFocus() && !a.browser.Typing() {
 		switch event.Key() {
 		case tcell.KeyLeft:
 			a.switchTab(-1)
@@ -488,6 +502,11 @@ func (a *App) settingSelected(i *menu.Item) {
 			description: "配置微信数据文件所在目录",
 			action:      a.settingDataDir,
 		},
+		{
+			name:        "解锁 HTTP 服务",
+			description: "空闲自动锁定后输入口令恢复访问",
+			action:      a.settingUnlock,
+		},
 	}
 
 	subMenu := menu.NewSubMenu("设置")
@@ -565,6 +584,37 @@ func (a *App) settingWorkDir() {
 	a.SetFocus(formView)
 }
 
+// settingUnlock 输入口令解锁 HTTP 服务
+func (a *App) settingUnlock() {
+	if !a.m.ServiceLocked() {
+		a.showInfo("HTTP 服务未锁定")
+		return
+	}
+
+	formView := form.NewForm("解锁 HTTP 服务")
+
+	passphrase := ""
+	formView.AddPasswordField("口令", "", 0, func(text string) {
+		passphrase = text
+	})
+
+	formView.AddButton("解锁", func() {
+		a.mainPages.RemovePage("submenu2")
+		if err := a.m.UnlockService(passphrase); err != nil {
+			a.showError(fmt.Errorf("解锁失败: %v", err))
+			return
+		}
+		a.showInfo("HTTP 服务已解锁")
+	})
+
+	formView.AddButton("取消", func() {
+		a.mainPages.RemovePage("submenu2")
+	})
+
+	a.mainPages.AddPage("submenu2", formView, true, true)
+	a.SetFocus(formView)
+}
+
 // settingDataKey 设置数据密钥
 func (a *App) settingDataKey() {
 	// 使用我们的自定义表单组件
@@ -786,6 +836,106 @@ func (a *App) selectAccountSelected(i *menu.Item) {
 	a.SetFocus(subMenu)
 }
 
+// browserJump 输入日期，聊天记录浏览器跳转到该日期之后的第一条消息
+func (a *App) browserJump() {
+	formView := form.NewForm("跳转到日期")
+
+	date := ""
+	formView.AddInputField("日期", "", 0, nil, func(text string) {
+		date = text
+	})
+
+	formView.AddButton("跳转", func() {
+		a.mainPages.RemovePage("submenu2")
+		start, _, ok := util.TimeRangeOf(date)
+		if !ok {
+			a.showError(fmt.Errorf("无法解析日期: %s", date))
+			return
+		}
+		a.browser.JumpTo(start)
+	})
+
+	formView.AddButton("取消", func() {
+		a.mainPages.RemovePage("submenu2")
+	})
+
+	a.mainPages.AddPage("submenu2", formView, true, true)
+	a.SetFocus(formView)
+}
+
+// browserSource 聊天记录浏览器的数据来源，未启动 HTTP 服务时打开工作目录中的数据库
+type browserSource struct {
+	m *Manager
+}
+
+func (s *browserSource) open() error {
+	if s.m.db.GetDB() == nil {
+		return s.m.db.Start()
+	}
+	return nil
+}
+
+func (s *browserSource) Sessions() ([]*model.Session, error) {
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	resp, err := s.m.db.GetSessions(context.Background(), "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Items, nil
+}
+
+func (s *browserSource) Messages(talker string, start, end time.Time, desc bool, limit int) ([]*model.Message, error) {
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	msgs, err := s.m.db.GetMessages(context.Background(), start, end, talker, "", "", "", desc, limit, 0)
+	if err != nil {
+		return nil, err
+	}
+	if desc {
+		for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+			msgs[i], msgs[j] = msgs[j], msgs[i]
+		}
+	}
+	return msgs, nil
+}
+
+func (s *browserSource) Context(talker string, seq int64, before, after int) ([]*model.Message, error) {
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	msgs, _, err := s.m.db.GetMessageContext(context.Background(), talker, seq, before, after)
+	return msgs, err
+}
+
+// Search 按输入的文字搜索，关键词中的正则表达式符号按普通字符匹配，已建立全文索引时从索引中查询
+func (s *browserSource) Search(keyword string, limit int) ([]*model.Message, error) {
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s.m.searchMessages(context.Background(), regexp.QuoteMeta(keyword), "", time.Time{}, time.Now(), limit)
+}
+
+func (s *browserSource) MediaPath(msg *model.Message) string {
+	_type, keys := msg.MediaKeys()
+	if len(keys) == 0 {
+		return ""
+	}
+	ctx := context.Background()
+	dataDir, err := s.m.db.DataDir(ctx)
+	if err != nil {
+		return ""
+	}
+	for _, key := range keys {
+		if media, err := s.m.db.GetMedia(ctx, _type, key); err == nil && media.Path != "" {
+			return filepath.Join(dataDir, media.Path)
+		}
+	}
+	return ""
+}
+
 // showModal 显示一个模态对话框
 func (a *App) showModal(text string, buttons []string, doneFunc func(buttonIndex int, buttonLabel string)) {
 	modal := tview.NewModal().
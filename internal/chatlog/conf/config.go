@@ -1,11 +1,265 @@
 package conf
 
-import "github.com/sjzar/chatlog/pkg/config"
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sjzar/chatlog/pkg/config"
+)
 
 type Config struct {
 	ConfigDir   string          `mapstructure:"-"`
 	LastAccount string          `mapstructure:"last_account" json:"last_account"`
 	History     []ProcessConfig `mapstructure:"history" json:"history"`
+	Accounts    []ProcessConfig `mapstructure:"accounts" json:"accounts"` // 通过 account 参数查询的其他账号，填写 account、platform、version、data_dir、work_dir，同名时优先于 history
+	ReportsDir  string          `mapstructure:"reports_dir" json:"reports_dir"`
+	Server      ServerConfig    `mapstructure:"server" json:"server"`
+	Profile     string          `mapstructure:"profile" json:"profile"` // 当前使用的 profile，可通过 --profile 参数或 CHATLOG_PROFILE 环境变量临时指定
+	Profiles    []Profile       `mapstructure:"profiles" json:"profiles"`
+	LLM         LLMConfig       `mapstructure:"llm" json:"llm"`
+	Cache       CacheConfig     `mapstructure:"cache" json:"cache"`
+	Schedules   []Schedule      `mapstructure:"schedules" json:"schedules"`
+	SMTP        SMTPConfig      `mapstructure:"smtp" json:"smtp"`
+	Webhooks    []Webhook       `mapstructure:"webhooks" json:"webhooks"`
+	Keywords    KeywordConfig   `mapstructure:"keywords" json:"keywords"`
+	HTTP        HTTPConfig      `mapstructure:"http" json:"http"`
+	MCP         MCPConfig       `mapstructure:"mcp" json:"mcp"`
+	Exclude     []string        `mapstructure:"exclude" json:"exclude"` // 不通过 API、MCP 与导出提供的会话，可填写 ID、备注或昵称
+	Trace       TraceConfig     `mapstructure:"trace" json:"trace"`
+
+	// 单次数据库查询的超时时间（秒），流式输出与导出时只计算等待数据库返回的时间，超时或客户端断开时中断查询，0 为不限制
+	QueryTimeout int `mapstructure:"query_timeout" json:"query_timeout" default:"120"`
+
+	// 慢查询阈值（毫秒），超过时记录查询及其参数，0 为不记录
+	SlowQuery int `mapstructure:"slow_query" json:"slow_query" default:"1000"`
+}
+
+// ServerConfig server 命令的默认参数，命令行中指定的参数优先
+type ServerConfig struct {
+	// 账号名称，为空时按工作目录在 history 中查找，工作目录挂载到其他路径（如容器中）时用于标识账号
+	Account  string `mapstructure:"account" json:"account"`
+	Addr     string `mapstructure:"addr" json:"addr"`
+	DataDir  string `mapstructure:"data_dir" json:"data_dir"`
+	WorkDir  string `mapstructure:"work_dir" json:"work_dir"`
+	Platform string `mapstructure:"platform" json:"platform"`
+	Version  int    `mapstructure:"version" json:"version"`
+}
+
+// Profile 一组命名的账号、目录与服务地址，用于在多份聊天记录之间切换
+// 使用 profile 时其中非空的配置项覆盖 server 与上次选择的账号
+type Profile struct {
+	Name         string `mapstructure:"name" json:"name"`
+	ServerConfig `mapstructure:",squash"`
+}
+
+// ActiveProfile 返回当前使用的 profile，未使用时返回 nil
+func (c *Config) ActiveProfile() (*Profile, error) {
+	if c.Profile == "" {
+		return nil, nil
+	}
+	for i := range c.Profiles {
+		if c.Profiles[i].Name == c.Profile {
+			return &c.Profiles[i], nil
+		}
+	}
+	return nil, fmt.Errorf("profile %s not found", c.Profile)
+}
+
+// ActiveServer 返回 server 命令的默认参数，当前 profile 中的非空配置项优先
+func (c *Config) ActiveServer() ServerConfig {
+	server := c.Server
+	p, _ := c.ActiveProfile()
+	if p == nil {
+		return server
+	}
+	if p.Account != "" {
+		server.Account = p.Account
+	}
+	if p.Addr != "" {
+		server.Addr = p.Addr
+	}
+	if p.DataDir != "" {
+		server.DataDir = p.DataDir
+	}
+	if p.WorkDir != "" {
+		server.WorkDir = p.WorkDir
+	}
+	if p.Platform != "" {
+		server.Platform = p.Platform
+	}
+	if p.Version != 0 {
+		server.Version = p.Version
+	}
+	return server
+}
+
+// HTTPConfig HTTP 服务配置
+type HTTPConfig struct {
+	Envelope           bool  `mapstructure:"envelope" json:"envelope"`                                            // /api/v1 的 JSON 响应统一包装为 {data, pagination, error, request_id}
+	InlineMediaMaxSize int64 `mapstructure:"inline_media_max_size" json:"inline_media_max_size" default:"262144"` // inline_media=1 与 MCP 返回图片的最大字节数
+	ReadOnly           bool  `mapstructure:"read_only" json:"read_only"`                                          // 只读模式，禁用导出、报告生成、任务提交与按路径下载，只保留查询接口
+
+	// 默认脱敏输出，遮盖手机号、身份证号、银行卡号与微信 ID，名称替换为化名，请求可通过 redact=0/1 覆盖
+	Redact     bool   `mapstructure:"redact" json:"redact"`
+	RedactSalt string `mapstructure:"redact_salt" json:"redact_salt"` // 生成化名的密钥，留空时每次启动随机生成
+	ImageMask  string `mapstructure:"image_mask" json:"image_mask"`   // 脱敏时图片的处理方式：blur 模糊、replace 替换为灰色占位图，留空时保持原图
+
+	// 除数据目录与报告目录外允许通过 HTTP 访问的目录，用于数据目录中指向其他位置的符号链接
+	FileRoots []string `mapstructure:"file_roots" json:"file_roots"`
+
+	// 允许访问的客户端地址（CIDR 或 IP），默认仅本机与局域网，设为 ["0.0.0.0/0", "::/0"] 时不限制
+	Allow []string `mapstructure:"allow" json:"allow" default:"[\"127.0.0.0/8\", \"::1/128\", \"10.0.0.0/8\", \"172.16.0.0/12\", \"192.168.0.0/16\", \"fc00::/7\"]"`
+
+	// 除同源页面外允许建立 WebSocket 连接的页面来源，如 https://example.com，不带 Origin 的客户端不受限制
+	WSOrigins []string `mapstructure:"ws_origins" json:"ws_origins"`
+
+	// 在 /debug/pprof/ 下提供性能分析接口，经过登录校验，仅用于排查问题
+	Pprof bool `mapstructure:"pprof" json:"pprof"`
+
+	// 页面与静态文件目录，其中的同名文件覆盖内嵌文件，不存在的文件仍使用内嵌版本，修改后无需重新编译
+	StaticDir string `mapstructure:"static_dir" json:"static_dir"`
+
+	// 除主地址外同时监听的地址，TCP 地址或 unix:<socket 路径>，unix socket 仅当前用户可访问且始终使用 HTTP
+	Listen []string `mapstructure:"listen" json:"listen"`
+
+	// 通过反向代理以子路径提供服务时的路径前缀，如 /chatlog，页面、接口与生成的多媒体链接、跳转地址均带有该前缀
+	BasePath string `mapstructure:"base_path" json:"base_path"`
+
+	// 外部访问服务的地址，如 https://chatlog.example.com/chatlog，用于 webhook 通知中的下载链接，留空时根据监听地址生成
+	PublicURL string `mapstructure:"public_url" json:"public_url"`
+
+	// 绑定局域网地址时通过 mDNS 发布的名称，局域网设备可通过 <mdns>.local 访问，设为 "-" 时不发布
+	MDNS string `mapstructure:"mdns" json:"mdns" default:"chatlog"`
+
+	Auth      AuthConfig      `mapstructure:"auth" json:"auth"`
+	TLS       TLSConfig       `mapstructure:"tls" json:"tls"`
+	RateLimit RateLimitConfig `mapstructure:"rate_limit" json:"rate_limit"`
+	Audit     AuditConfig     `mapstructure:"audit" json:"audit"`
+	Lock      LockConfig      `mapstructure:"lock" json:"lock"`
+}
+
+// MCPConfig MCP 服务配置
+type MCPConfig struct {
+	// chatlog 工具单次返回内容的大致 token 上限，超出时分页并返回 cursor，0 为不限制
+	MaxTokens int `mapstructure:"max_tokens" json:"max_tokens" default:"8000"`
+}
+
+// LockConfig 空闲自动锁定，超过 idle 分钟没有请求后关闭数据库并清空缓存，输入口令后才能继续访问数据
+type LockConfig struct {
+	Idle       int    `mapstructure:"idle" json:"idle"`             // 空闲分钟数，0 为不锁定
+	Passphrase string `mapstructure:"passphrase" json:"passphrase"` // 解锁口令，明文或 bcrypt 哈希，留空时使用 http.auth.password
+}
+
+// AuditConfig 访问审计日志，记录每个请求的接口、参数、客户端与返回字节数，按大小轮转
+type AuditConfig struct {
+	Enabled  bool   `mapstructure:"enabled" json:"enabled"`
+	Dir      string `mapstructure:"dir" json:"dir"`                         // 日志目录，默认为配置目录下的 audit
+	MaxSize  int    `mapstructure:"max_size" json:"max_size" default:"10"`  // 单个日志文件的最大大小（MB）
+	MaxFiles int    `mapstructure:"max_files" json:"max_files" default:"5"` // 保留的历史日志文件数
+}
+
+// RateLimitConfig 令牌桶限流配置，rate 为每秒补充的请求数，小于等于 0 时不限流
+// analysis_rate 单独限制分析、批量查询与 GraphQL 等耗时接口，同时仍受 rate 限制
+type RateLimitConfig struct {
+	Rate          float64 `mapstructure:"rate" json:"rate"`
+	Burst         int     `mapstructure:"burst" json:"burst" default:"20"`
+	AnalysisRate  float64 `mapstructure:"analysis_rate" json:"analysis_rate"`
+	AnalysisBurst int     `mapstructure:"analysis_burst" json:"analysis_burst" default:"5"`
+	KeyBy         string  `mapstructure:"key_by" json:"key_by" default:"ip"` // ip 按客户端 IP 计数；api_key 按登录用户名或客户端证书名称计数，未认证时退回 IP
+}
+
+// TLSConfig HTTPS 配置，指定证书或开启 self_signed 后 HTTP 服务改为 HTTPS
+type TLSConfig struct {
+	CertFile     string `mapstructure:"cert_file" json:"cert_file"`
+	KeyFile      string `mapstructure:"key_file" json:"key_file"`
+	SelfSigned   bool   `mapstructure:"self_signed" json:"self_signed"`     // 未指定证书时在配置目录生成自签名证书，首次运行时生成并复用
+	RedirectAddr string `mapstructure:"redirect_addr" json:"redirect_addr"` // 在该地址监听 HTTP 请求并重定向到 HTTPS，如 :80
+	ClientCA     string `mapstructure:"client_ca" json:"client_ca"`         // 客户端证书 CA（PEM），设置后除页面与静态文件外的请求都要求经过验证的客户端证书
+}
+
+// Prefix 规范化的路径前缀，以 / 开头且不以 / 结尾，未配置时为空
+func (c HTTPConfig) Prefix() string {
+	p := strings.Trim(strings.TrimSpace(c.BasePath), "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// Enabled 是否启用 HTTPS
+func (c TLSConfig) Enabled() bool {
+	return (c.CertFile != "" && c.KeyFile != "") || c.SelfSigned
+}
+
+// AuthConfig 登录配置，password 为空时不启用登录
+// password 可为明文或 bcrypt 哈希（以 $2a$、$2b$ 开头），secret 用于签名会话 Cookie，为空时每次启动随机生成
+type AuthConfig struct {
+	Username   string `mapstructure:"username" json:"username" default:"admin"`
+	Password   string `mapstructure:"password" json:"password"`
+	Secret     string `mapstructure:"secret" json:"secret"`
+	SessionTTL int    `mapstructure:"session_ttl" json:"session_ttl" default:"604800"` // 会话有效期（秒）
+	MaxFails   int    `mapstructure:"max_fails" json:"max_fails" default:"5"`          // 同一 IP 连续登录失败次数上限，超过后锁定
+	LockTime   int    `mapstructure:"lock_time" json:"lock_time" default:"900"`        // 锁定时长（秒）
+}
+
+// KeywordConfig 话题关键词提取规则
+type KeywordConfig struct {
+	StopwordsFile string   `mapstructure:"stopwords_file" json:"stopwords_file"` // 停用词文件，每行一个词
+	Stopwords     []string `mapstructure:"stopwords" json:"stopwords"`
+	MinLength     int      `mapstructure:"min_length" json:"min_length" default:"2"` // 英文、数字单词最小长度
+	MinCount      int      `mapstructure:"min_count" json:"min_count" default:"3"`   // 最少出现次数
+	Watch         []string `mapstructure:"watch" json:"watch"`                       // 关注词，出现即报告
+}
+
+// Webhook 任务结束时的通知地址，配置 secret 后请求带有 HMAC-SHA256 签名
+// events 为空时通知所有事件，可选 job.succeeded、job.failed、job.canceled
+type Webhook struct {
+	URL    string   `mapstructure:"url" json:"url"`
+	Secret string   `mapstructure:"secret" json:"secret"`
+	Events []string `mapstructure:"events" json:"events"`
+}
+
+// SMTPConfig 邮件发送配置，用于投递摘要邮件
+type SMTPConfig struct {
+	Host     string `mapstructure:"host" json:"host"`
+	Port     int    `mapstructure:"port" json:"port" default:"587"`
+	Username string `mapstructure:"username" json:"username"`
+	Password string `mapstructure:"password" json:"password"`
+	From     string `mapstructure:"from" json:"from"`
+}
+
+// CacheConfig 分析接口的结果缓存配置
+type CacheConfig struct {
+	TTL int    `mapstructure:"ttl" json:"ttl" default:"300"` // 缓存有效期（秒），小于 0 时关闭缓存
+	Dir string `mapstructure:"dir" json:"dir"`               // 磁盘缓存目录，为空时仅缓存在内存中
+}
+
+// TraceConfig OpenTelemetry 链路追踪，以 OTLP/HTTP 导出 HTTP 请求、数据库查询、多媒体解码与 MCP 工具调用的耗时
+type TraceConfig struct {
+	Endpoint    string            `mapstructure:"endpoint" json:"endpoint"`                           // OTLP/HTTP 地址，如 http://localhost:4318，为空时使用 OTEL_EXPORTER_OTLP_ENDPOINT，均为空时不导出
+	Headers     map[string]string `mapstructure:"headers" json:"headers"`                             // 导出时附加的请求头
+	ServiceName string            `mapstructure:"service_name" json:"service_name" default:"chatlog"` // 上报的服务名称
+}
+
+// LLMConfig OpenAI 兼容接口的大模型配置，用于生成分析摘要
+type LLMConfig struct {
+	BaseURL string `mapstructure:"base_url" json:"base_url"`
+	APIKey  string `mapstructure:"api_key" json:"api_key"`
+	Model   string `mapstructure:"model" json:"model"`
+}
+
+// Schedule 定时任务配置，在 HTTP 服务运行期间按 cron 表达式提交后台任务
+// 例如每天 08:00 生成前一天指定群聊的报告：{"name": "daily", "cron": "0 8 * * *", "type": "report", "time": "yesterday", "talkers": ["xxx@chatroom"]}
+// type 为 digest 时将摘要与金句以邮件发送给 recipients
+type Schedule struct {
+	Name       string   `mapstructure:"name" json:"name"`
+	Cron       string   `mapstructure:"cron" json:"cron"`
+	Type       string   `mapstructure:"type" json:"type" default:"report"`
+	Time       string   `mapstructure:"time" json:"time" default:"yesterday"`
+	Talkers    []string `mapstructure:"talkers" json:"talkers"`
+	Recipients []string `mapstructure:"recipients" json:"recipients"`
+	Webhook    string   `mapstructure:"webhook" json:"webhook"` // 任务结束后 POST 通知的地址
 }
 
 type ProcessConfig struct {
@@ -21,6 +275,7 @@ type ProcessConfig struct {
 	HTTPAddr    string `mapstructure:"http_addr" json:"http_addr"`
 	LastTime    int64  `mapstructure:"last_time" json:"last_time"`
 	Files       []File `mapstructure:"files" json:"files"`
+	WxID        string `mapstructure:"wxid" json:"wxid,omitempty"` // 账号本人的微信 ID，合并多个账号时识别自己发送的消息，为空时使用账号名
 }
 
 type File struct {
@@ -29,6 +284,55 @@ type File struct {
 	Size         int64  `mapstructure:"size" json:"size"`
 }
 
+// resolvePaths 将配置中的相对路径解析为相对于配置目录的路径
+// 数据目录、工作目录等与配置目录放在一起时，整体移动或挂载到其他位置后无需修改配置
+func (c *Config) resolvePaths() {
+	resolve := func(path *string) {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(c.ConfigDir, *path)
+		}
+	}
+	resolve(&c.Server.DataDir)
+	resolve(&c.Server.WorkDir)
+	resolve(&c.ReportsDir)
+	resolve(&c.Cache.Dir)
+	resolve(&c.Keywords.StopwordsFile)
+	resolve(&c.HTTP.Audit.Dir)
+	resolve(&c.HTTP.StaticDir)
+	resolve(&c.HTTP.TLS.CertFile)
+	resolve(&c.HTTP.TLS.KeyFile)
+	resolve(&c.HTTP.TLS.ClientCA)
+	for i := range c.HTTP.FileRoots {
+		resolve(&c.HTTP.FileRoots[i])
+	}
+	for i := range c.Accounts {
+		resolve(&c.Accounts[i].DataDir)
+		resolve(&c.Accounts[i].WorkDir)
+	}
+	for i := range c.Profiles {
+		resolve(&c.Profiles[i].DataDir)
+		resolve(&c.Profiles[i].WorkDir)
+	}
+}
+
+// SetProfile 保存当前使用的 profile，name 为空时不再使用 profile
+func (c *Config) SetProfile(name string) error {
+	if name != "" {
+		found := false
+		for _, p := range c.Profiles {
+			if p.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("profile %s not found", name)
+		}
+	}
+	c.Profile = name
+	return config.SetConfig("profile", name)
+}
+
 func (c *Config) ParseHistory() map[string]ProcessConfig {
 	m := make(map[string]ProcessConfig)
 	for _, v := range c.History {
@@ -4,14 +4,19 @@ import (
 	"log"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/sjzar/chatlog/pkg/config"
+	"github.com/sjzar/chatlog/pkg/filemonitor"
 )
 
 const (
 	ConfigName   = "chatlog"
 	ConfigType   = "json"
 	EnvConfigDir = "CHATLOG_DIR"
+
+	// EnvPrefix 覆盖配置项的环境变量前缀，如 CHATLOG_HTTP_AUTH_PASSWORD 对应 http.auth.password
+	EnvPrefix = "CHATLOG"
 )
 
 // Service 配置服务
@@ -19,6 +24,11 @@ type Service struct {
 	configPath string
 	config     *Config
 	mu         sync.RWMutex
+
+	// 配置文件监听
+	watchMu sync.Mutex
+	fm      *filemonitor.FileMonitor
+	timer   *time.Timer
 }
 
 // NewService 创建配置服务
@@ -47,12 +57,17 @@ func (s *Service) Load() error {
 	if err := config.Init(ConfigName, ConfigType, configPath); err != nil {
 		log.Fatal(err)
 	}
+	config.SetEnvPrefix(EnvPrefix)
 
 	conf := &Config{}
 	if err := config.Load(conf); err != nil {
 		log.Fatal(err)
 	}
 	conf.ConfigDir = config.ConfigPath
+	conf.resolvePaths()
+	if _, err := conf.ActiveProfile(); err != nil {
+		return err
+	}
 	s.config = conf
 	return nil
 }
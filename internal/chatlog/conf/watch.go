@@ -0,0 +1,83 @@
+package conf
+
+import (
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/pkg/config"
+	"github.com/sjzar/chatlog/pkg/filemonitor"
+)
+
+// reloadDelay 配置文件变化后等待的时间，编辑器保存时常连续产生多个事件，合并为一次加载
+const reloadDelay = 500 * time.Millisecond
+
+// Watch 监听配置文件，文件变化时重新加载并回调 onChange
+// 文件格式错误时保留原配置并记录日志
+func (s *Service) Watch(onChange func(*Config)) error {
+	pattern := "^" + regexp.QuoteMeta(filepath.Base(config.ConfigFile())) + "$"
+	group, err := filemonitor.NewFileGroup("config", config.ConfigPath, pattern, nil)
+	if err != nil {
+		return err
+	}
+	group.AddCallback(func(event fsnotify.Event) error {
+		if !event.Op.Has(fsnotify.Write) && !event.Op.Has(fsnotify.Create) {
+			return nil
+		}
+		s.watchMu.Lock()
+		defer s.watchMu.Unlock()
+		if s.timer != nil {
+			s.timer.Stop()
+		}
+		s.timer = time.AfterFunc(reloadDelay, func() {
+			if conf, ok := s.reload(); ok {
+				onChange(conf)
+			}
+		})
+		return nil
+	})
+
+	fm := filemonitor.NewFileMonitor()
+	if err := fm.AddGroup(group); err != nil {
+		return err
+	}
+	if err := fm.Start(); err != nil {
+		return err
+	}
+	s.watchMu.Lock()
+	s.fm = fm
+	s.watchMu.Unlock()
+	return nil
+}
+
+// StopWatch 停止监听配置文件
+func (s *Service) StopWatch() {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if s.fm != nil {
+		s.fm.Stop()
+		s.fm = nil
+	}
+}
+
+func (s *Service) reload() (*Config, bool) {
+	conf := &Config{}
+	if err := config.Reload(conf); err != nil {
+		log.Err(err).Msgf("failed to reload config %s", config.ConfigFile())
+		return nil, false
+	}
+	conf.ConfigDir = config.ConfigPath
+	conf.resolvePaths()
+
+	s.mu.Lock()
+	s.config = conf
+	s.mu.Unlock()
+	return s.GetConfig(), true
+}
@@ -1,7 +1,9 @@
 package ctx
 
 import (
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sjzar/chatlog/internal/chatlog/conf"
@@ -9,12 +11,18 @@ import (
 	"github.com/sjzar/chatlog/pkg/util"
 )
 
+// EnvWorkKey 工作目录加密口令的环境变量
+const EnvWorkKey = "CHATLOG_WORK_KEY"
+
 // Context is a context for a chatlog.
 // It is used to store information about the chatlog.
 type Context struct {
 	conf *conf.Service
 	mu   sync.RWMutex
 
+	// 配置文件所在目录
+	ConfigDir string
+
 	History map[string]conf.ProcessConfig
 
 	// 微信账号相关状态
@@ -30,9 +38,28 @@ type Context struct {
 	WorkDir   string
 	WorkUsage string
 
-	// HTTP服务相关状态
+	// 工作目录加密口令，只从环境变量或命令行参数读取，不写入配置文件
+	WorkKey string
+
+	// HTTP服务相关状态，其中可重新加载的部分通过 Settings 读取
 	HTTPEnabled bool
 	HTTPAddr    string
+	HTTP        conf.HTTPConfig
+
+	// 分析报告与导出文件目录，为空时使用进程工作目录
+	ReportsDir string
+
+	// 分析接口缓存配置
+	Cache conf.CacheConfig
+
+	// 定时任务
+	Schedules []conf.Schedule
+
+	// 运行中可重新加载的配置
+	settings atomic.Pointer[Settings]
+
+	// 链路追踪
+	Trace conf.TraceConfig
 
 	// 自动解密
 	AutoDecrypt bool
@@ -48,23 +75,117 @@ type Context struct {
 	WeChatInstances []*wechat.Account
 }
 
+// Settings 服务运行中可以直接替换的配置，Reload 时整体替换，已发布的快照不再修改
+// 请求处理中通过 Context.Settings 读取，避免与配置重新加载并发读写
+type Settings struct {
+	LLM      conf.LLMConfig     // 大模型配置
+	SMTP     conf.SMTPConfig    // 邮件发送配置
+	Webhooks []conf.Webhook     // 任务通知
+	Keywords conf.KeywordConfig // 话题关键词规则
+	MCP      conf.MCPConfig     // MCP 服务配置
+	Exclude  []string           // 不对外提供的会话
+
+	QueryTimeout int // 单次数据库查询的超时时间（秒）
+	SlowQuery    int // 慢查询阈值（毫秒）
+	CacheTTL     int // 分析接口缓存有效期（秒）
+
+	Envelope           bool
+	InlineMediaMaxSize int64
+	Redact             bool
+	ImageMask          string
+	Allow              []string
+	WSOrigins          []string
+	Auth               conf.AuthConfig
+}
+
+func settingsOf(conf *conf.Config) *Settings {
+	return &Settings{
+		LLM:                conf.LLM,
+		SMTP:               conf.SMTP,
+		Webhooks:           conf.Webhooks,
+		Keywords:           conf.Keywords,
+		MCP:                conf.MCP,
+		Exclude:            conf.Exclude,
+		QueryTimeout:       conf.QueryTimeout,
+		SlowQuery:          conf.SlowQuery,
+		CacheTTL:           conf.Cache.TTL,
+		Envelope:           conf.HTTP.Envelope,
+		InlineMediaMaxSize: conf.HTTP.InlineMediaMaxSize,
+		Redact:             conf.HTTP.Redact,
+		ImageMask:          conf.HTTP.ImageMask,
+		Allow:              conf.HTTP.Allow,
+		WSOrigins:          conf.HTTP.WSOrigins,
+		Auth:               conf.HTTP.Auth,
+	}
+}
+
 func New(conf *conf.Service) *Context {
 	ctx := &Context{
 		conf: conf,
 	}
 
 	ctx.loadConfig()
+	ctx.WorkKey = os.Getenv(EnvWorkKey)
 
 	return ctx
 }
 
 func (c *Context) loadConfig() {
 	conf := c.conf.GetConfig()
+	c.ConfigDir = conf.ConfigDir
 	c.History = conf.ParseHistory()
+	for _, account := range conf.Accounts {
+		if account.Account != "" {
+			c.History[account.Account] = account
+		}
+	}
+	c.ReportsDir = conf.ReportsDir
+	c.Cache = conf.Cache
+	c.Schedules = conf.Schedules
+	c.settings.Store(settingsOf(conf))
+	c.Trace = conf.Trace
+	c.HTTP = conf.HTTP
 	c.SwitchHistory(conf.LastAccount)
+	if p, _ := conf.ActiveProfile(); p != nil {
+		c.applyProfile(p)
+	}
 	c.Refresh()
 }
 
+// applyProfile 使用 profile 中的账号、目录与服务地址，指定了账号时先切换到该账号的历史记录
+func (c *Context) applyProfile(p *conf.Profile) {
+	if p.Account != "" {
+		c.SwitchHistory(p.Account)
+		c.Account = p.Account
+	}
+	if p.DataDir != "" {
+		c.DataDir = p.DataDir
+	}
+	if p.WorkDir != "" {
+		c.WorkDir = p.WorkDir
+	}
+	if p.Platform != "" {
+		c.Platform = p.Platform
+	}
+	if p.Version != 0 {
+		c.Version = p.Version
+	}
+	if p.Addr != "" {
+		c.HTTPAddr = p.Addr
+	}
+}
+
+// Reload 应用重新加载的配置，只替换服务运行中可以直接替换的部分
+// 服务地址、数据与工作目录、TLS、限流、审计、空闲锁定、定时任务与缓存目录需重启服务后生效
+func (c *Context) Reload(conf *conf.Config) {
+	c.settings.Store(settingsOf(conf))
+}
+
+// Settings 当前可重新加载配置的快照，调用方不应修改
+func (c *Context) Settings() *Settings {
+	return c.settings.Load()
+}
+
 func (c *Context) SwitchHistory(account string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/wechatdb"
+	"github.com/sjzar/chatlog/pkg/filecrypt"
+)
+
+type accountKey struct{}
+
+// WithAccount 指定 ctx 中的查询使用的账号，为空时使用当前账号
+func WithAccount(ctx context.Context, account string) context.Context {
+	if account == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, accountKey{}, account)
+}
+
+// AccountOf 返回 ctx 中指定的账号，未指定时为空
+func AccountOf(ctx context.Context) string {
+	account, _ := ctx.Value(accountKey{}).(string)
+	return account
+}
+
+// Account 可查询的账号
+type Account struct {
+	Name     string `json:"name"`
+	Platform string `json:"platform"`
+	Version  int    `json:"version"`
+	Current  bool   `json:"current"` // 当前账号，未指定 account 参数时查询该账号
+	Opened   bool   `json:"opened"`  // 数据库已打开
+}
+
+// Accounts 列出当前账号与配置中解密过且工作目录存在的其他账号
+func (s *Service) Accounts() []*Account {
+	s.dbMu.RLock()
+	defer s.dbMu.RUnlock()
+
+	ret := make([]*Account, 0)
+	if s.ctx.Account != "" || s.db != nil {
+		ret = append(ret, &Account{
+			Name:     s.ctx.Account,
+			Platform: s.ctx.Platform,
+			Version:  s.ctx.Version,
+			Current:  true,
+			Opened:   s.db != nil,
+		})
+	}
+	others := make([]*Account, 0)
+	for name, history := range s.ctx.History {
+		if s.isCurrent(name) || !dirExists(history.WorkDir) {
+			continue
+		}
+		_, opened := s.accounts[name]
+		others = append(others, &Account{
+			Name:     name,
+			Platform: history.Platform,
+			Version:  history.Version,
+			Opened:   opened,
+		})
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].Name < others[j].Name })
+	return append(ret, others...)
+}
+
+// getDB 返回 ctx 指定账号的数据库，其他账号在首次查询时打开，合并多个账号时使用第一个账号
+// 数据库未打开时返回错误，避免锁定或停止后仍有请求访问已关闭的连接
+func (s *Service) getDB(ctx context.Context) (*wechatdb.DB, error) {
+	account := s.primaryAccount(ctx)
+	s.dbMu.RLock()
+	db, opened := s.db, s.accounts[account]
+	s.dbMu.RUnlock()
+	if db == nil {
+		return nil, errors.ErrDBClosed
+	}
+	if s.isCurrent(account) {
+		return db, nil
+	}
+	if opened != nil {
+		return opened, nil
+	}
+
+	history, ok := s.ctx.History[account]
+	if !ok || !dirExists(history.WorkDir) {
+		return nil, errors.WeChatAccountNotFound(account)
+	}
+
+	s.dbMu.Lock()
+	defer s.dbMu.Unlock()
+	if s.db == nil {
+		return nil, errors.ErrDBClosed
+	}
+	if db, ok := s.accounts[account]; ok {
+		return db, nil
+	}
+	key, err := filecrypt.DirKey(history.WorkDir, s.ctx.WorkKey)
+	if err != nil {
+		return nil, err
+	}
+	db, err = wechatdb.New(history.WorkDir, history.Platform, history.Version, key)
+	if err != nil {
+		return nil, err
+	}
+	db.SetExclude(s.ctx.Settings().Exclude)
+	s.accounts[account] = db
+	return db, nil
+}
+
+// DataDir 返回 ctx 指定账号的数据目录，用于读取图片、视频等文件
+func (s *Service) DataDir(ctx context.Context) (string, error) {
+	account := s.primaryAccount(ctx)
+	if s.isCurrent(account) {
+		return s.ctx.DataDir, nil
+	}
+	history, ok := s.ctx.History[account]
+	if !ok || !dirExists(history.WorkDir) {
+		return "", errors.WeChatAccountNotFound(account)
+	}
+	return history.DataDir, nil
+}
+
+// primaryAccount ctx 指定的账号，合并多个账号时为第一个账号
+func (s *Service) primaryAccount(ctx context.Context) string {
+	if accounts := s.mergedAccounts(ctx); accounts != nil {
+		if len(accounts) == 0 {
+			return ""
+		}
+		return accounts[0]
+	}
+	return AccountOf(ctx)
+}
+
+// isCurrent 账号为空、为当前账号或与当前账号使用同一工作目录时查询当前账号的数据库
+func (s *Service) isCurrent(account string) bool {
+	if account == "" || account == s.ctx.Account {
+		return true
+	}
+	history, ok := s.ctx.History[account]
+	return ok && history.WorkDir != "" && filepath.Clean(history.WorkDir) == filepath.Clean(s.ctx.WorkDir)
+}
+
+func dirExists(dir string) bool {
+	if dir == "" {
+		return false
+	}
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
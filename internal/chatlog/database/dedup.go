@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+// DefaultDedupWindow 去重时认为是同一条消息的最大时间差
+const DefaultDedupWindow = 5 * time.Second
+
+type dedupKey struct{}
+
+// WithDedup 合并多个账号查询时去除不同账号中重复的消息，window 为时间窗口，不大于 0 时使用默认值
+func WithDedup(ctx context.Context, window time.Duration) context.Context {
+	if window <= 0 {
+		window = DefaultDedupWindow
+	}
+	return context.WithValue(ctx, dedupKey{}, window)
+}
+
+// dedupOf 返回 ctx 中的去重时间窗口，未开启去重时为 0
+func dedupOf(ctx context.Context) time.Duration {
+	window, _ := ctx.Value(dedupKey{}).(time.Duration)
+	return window
+}
+
+// dedupMessages 去除按时间排序的消息中来自不同账号的重复消息，保留先出现的一条
+// 会话、发送人、消息类型与内容相同且时间差不超过 window 时视为重复，自己发送的消息不比较发送人，
+// 以便未配置 wxid 的同一账号的手机备份与电脑端数据也能识别；同一账号内的重复内容（如连续发送的相同文字）不去除
+func dedupMessages(messages []*model.Message, window time.Duration) []*model.Message {
+	type seen struct {
+		time    time.Time
+		account string
+	}
+	kept := make(map[string][]seen)
+	ret := make([]*model.Message, 0, len(messages))
+	for _, m := range messages {
+		key := dedupHash(m)
+		duplicate := false
+		for _, prev := range kept[key] {
+			diff := m.Time.Sub(prev.time)
+			if diff < 0 {
+				diff = -diff
+			}
+			if prev.account != m.Account && diff <= window {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+		kept[key] = append(kept[key], seen{time: m.Time, account: m.Account})
+		ret = append(ret, m)
+	}
+	return ret
+}
+
+// dedupHash 会话、发送人、类型与内容的摘要，多媒体消息的本地路径因设备而异，使用标题、链接与文件 MD5 代替
+// 没有标题、链接与 MD5 的消息（如系统消息、拍一拍）仍比较文字内容，避免同一时间段内的不同消息被误判为重复
+func dedupHash(m *model.Message) string {
+	sender := m.Sender
+	if m.IsSelf {
+		sender = ""
+	}
+	content := m.Content
+	if m.Type != 1 {
+		title, url, md5 := m.Contents["title"], m.Contents["url"], m.Contents["md5"]
+		if title != nil || url != nil || md5 != nil {
+			content = fmt.Sprint(title, "|", url, "|", md5)
+		}
+	}
+	sum := md5.Sum([]byte(fmt.Sprintf("%s\x00%s\x00%d\x00%d\x00%s", m.Talker, sender, m.Type, m.SubType, content)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+func TestDedupMessages(t *testing.T) {
+	base := time.Date(2024, 3, 15, 10, 0, 0, 0, time.Local)
+	msg := func(account string, offset time.Duration, sender string, _type int64, content string, contents map[string]interface{}) *model.Message {
+		return &model.Message{
+			Time:     base.Add(offset),
+			Talker:   "xxx@chatroom",
+			Sender:   sender,
+			Type:     _type,
+			Content:  content,
+			Contents: contents,
+			Account:  account,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		messages []*model.Message
+		want     int
+	}{
+		{
+			name: "same text from different accounts",
+			messages: []*model.Message{
+				msg("a", 0, "wxid_1", 1, "hello", nil),
+				msg("b", 2*time.Second, "wxid_1", 1, "hello", nil),
+			},
+			want: 1,
+		},
+		{
+			name: "same text from the same account",
+			messages: []*model.Message{
+				msg("a", 0, "wxid_1", 1, "hello", nil),
+				msg("a", time.Second, "wxid_1", 1, "hello", nil),
+			},
+			want: 2,
+		},
+		{
+			name: "outside the window",
+			messages: []*model.Message{
+				msg("a", 0, "wxid_1", 1, "hello", nil),
+				msg("b", 10*time.Second, "wxid_1", 1, "hello", nil),
+			},
+			want: 2,
+		},
+		{
+			name: "different senders",
+			messages: []*model.Message{
+				msg("a", 0, "wxid_1", 1, "hello", nil),
+				msg("b", 0, "wxid_2", 1, "hello", nil),
+			},
+			want: 2,
+		},
+		{
+			name: "shared links with the same url",
+			messages: []*model.Message{
+				msg("a", 0, "wxid_1", 49, "", map[string]interface{}{"title": "t", "url": "https://example.com", "path": "a/1.dat"}),
+				msg("b", time.Second, "wxid_1", 49, "", map[string]interface{}{"title": "t", "url": "https://example.com", "path": "b/2.dat"}),
+			},
+			want: 1,
+		},
+		{
+			name: "images with different md5",
+			messages: []*model.Message{
+				msg("a", 0, "wxid_1", 3, "", map[string]interface{}{"md5": "1"}),
+				msg("b", time.Second, "wxid_1", 3, "", map[string]interface{}{"md5": "2"}),
+			},
+			want: 2,
+		},
+		{
+			name: "system messages without title, url or md5",
+			messages: []*model.Message{
+				msg("a", 0, "", 10000, "A 邀请 B 加入了群聊", nil),
+				msg("b", time.Second, "", 10000, "C 修改群名为 D", nil),
+			},
+			want: 2,
+		},
+		{
+			name: "same system message",
+			messages: []*model.Message{
+				msg("a", 0, "", 10000, "A 邀请 B 加入了群聊", nil),
+				msg("b", time.Second, "", 10000, "A 邀请 B 加入了群聊", nil),
+			},
+			want: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := dedupMessages(tt.messages, DefaultDedupWindow); len(got) != tt.want {
+			t.Errorf("%s: dedupMessages() = %d messages, want %d", tt.name, len(got), tt.want)
+		}
+	}
+
+	// 自己发送的消息不比较发送人
+	self := []*model.Message{
+		{Time: base, Talker: "wxid_1", Sender: "wxid_me", IsSelf: true, Type: 1, Content: "hi", Account: "a"},
+		{Time: base, Talker: "wxid_1", Sender: "", IsSelf: true, Type: 1, Content: "hi", Account: "b"},
+	}
+	if got := dedupMessages(self, DefaultDedupWindow); len(got) != 1 {
+		t.Errorf("dedupMessages(self) = %d messages, want 1", len(got))
+	}
+}
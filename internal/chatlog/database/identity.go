@@ -0,0 +1,185 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/internal/wechatdb"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// identityMap 当前账号合并的联系人身份，账号到身份 ID 的映射，读取失败时只记录日志，按原有账号处理
+func (s *Service) identityMap(ctx context.Context) map[string]string {
+	ids, err := s.sidecar.IdentityMap(ctx, s.AccountName(ctx))
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to load identities")
+		return nil
+	}
+	return ids
+}
+
+// identityMembers 身份包含的全部账号，ID 在前，其余按账号排序
+func identityMembers(ids map[string]string, id string) []string {
+	if primary, ok := ids[id]; ok {
+		id = primary
+	}
+	ret := []string{id}
+	for alias, primary := range ids {
+		if primary == id {
+			ret = append(ret, alias)
+		}
+	}
+	sort.Strings(ret[1:])
+	return ret
+}
+
+// expandIdentities 将以逗号分隔的账号中属于合并身份的账号展开为该身份的全部账号
+func expandIdentities(ids map[string]string, list string) string {
+	if len(ids) == 0 || list == "" {
+		return list
+	}
+	seen := make(map[string]bool)
+	ret := make([]string, 0)
+	for _, item := range util.Str2List(list, ",") {
+		members := []string{item}
+		if isIdentity(ids, item) {
+			members = identityMembers(ids, item)
+		}
+		for _, m := range members {
+			if !seen[m] {
+				seen[m] = true
+				ret = append(ret, m)
+			}
+		}
+	}
+	return strings.Join(ret, ",")
+}
+
+// isIdentity 账号是否属于合并的身份
+func isIdentity(ids map[string]string, id string) bool {
+	if _, ok := ids[id]; ok {
+		return true
+	}
+	for _, primary := range ids {
+		if primary == id {
+			return true
+		}
+	}
+	return false
+}
+
+// identityResolver 将消息与统计中合并身份的账号替换为身份 ID，名称使用身份 ID 对应联系人的名称
+type identityResolver struct {
+	db    *wechatdb.DB
+	ids   map[string]string
+	names map[string]string
+}
+
+func newIdentityResolver(db *wechatdb.DB, ids map[string]string) *identityResolver {
+	return &identityResolver{db: db, ids: ids, names: make(map[string]string)}
+}
+
+// name 身份 ID 对应联系人的显示名称，结果按 ID 缓存
+func (r *identityResolver) name(ctx context.Context, id string) string {
+	if name, ok := r.names[id]; ok {
+		return name
+	}
+	name := ""
+	if resp, err := r.db.GetContacts(ctx, id, 0, 0); err == nil {
+		for _, contact := range resp.Items {
+			if contact.UserName == id {
+				name = contact.DisplayName()
+				break
+			}
+		}
+	}
+	r.names[id] = name
+	return name
+}
+
+// messages 替换消息的会话与发送人，群聊的会话不变
+func (r *identityResolver) messages(ctx context.Context, messages ...*model.Message) {
+	if len(r.ids) == 0 {
+		return
+	}
+	for _, m := range messages {
+		if id, ok := r.ids[m.Talker]; ok && !m.IsChatRoom {
+			m.Talker = id
+			if name := r.name(ctx, id); name != "" {
+				m.TalkerName = name
+			}
+		}
+		if id, ok := r.ids[m.Sender]; ok {
+			m.Sender = id
+			if name := r.name(ctx, id); name != "" {
+				m.SenderName = name
+			}
+		}
+	}
+}
+
+// stats 替换统计中的会话与发送人，合并替换后重复的条目
+func (r *identityResolver) stats(stats []*model.HourStat) []*model.HourStat {
+	if len(r.ids) == 0 {
+		return stats
+	}
+	type key struct {
+		talker, sender string
+		hour           time.Time
+	}
+	index := make(map[key]*model.HourStat, len(stats))
+	ret := make([]*model.HourStat, 0, len(stats))
+	for _, stat := range stats {
+		st := *stat
+		if id, ok := r.ids[st.Talker]; ok {
+			st.Talker = id
+		}
+		if id, ok := r.ids[st.Sender]; ok {
+			st.Sender = id
+		}
+		k := key{st.Talker, st.Sender, st.Hour}
+		if prev, ok := index[k]; ok {
+			prev.Messages += st.Messages
+			prev.Texts += st.Texts
+			prev.Media += st.Media
+			continue
+		}
+		index[k] = &st
+		ret = append(ret, &st)
+	}
+	return ret
+}
+
+// sessions 合并同一身份的会话：保留最先出现的会话，会话 ID 与名称替换为身份 ID 及其名称
+func (r *identityResolver) sessions(ctx context.Context, resp *wechatdb.GetSessionsResp) {
+	if resp == nil || len(r.ids) == 0 {
+		return
+	}
+	seen := make(map[string]bool)
+	items := make([]*model.Session, 0, len(resp.Items))
+	for _, session := range resp.Items {
+		id := session.UserName
+		if primary, ok := r.ids[id]; ok {
+			id = primary
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if id != session.UserName {
+			c := *session
+			c.UserName = id
+			if name := r.name(ctx, id); name != "" {
+				c.NickName = name
+			}
+			session = &c
+		}
+		items = append(items, session)
+	}
+	resp.Items = items
+}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// MergeAll account 为 all 时合并全部可查询的账号
+const MergeAll = "all"
+
+// mergedAccounts ctx 指定了多个账号（以逗号分隔，或为 all）时返回要合并的账号，否则返回 nil
+// 合并模式只作用于聊天记录的查询与统计，其他查询使用第一个账号
+func (s *Service) mergedAccounts(ctx context.Context) []string {
+	account := AccountOf(ctx)
+	if account == MergeAll {
+		accounts := s.Accounts()
+		ret := make([]string, 0, len(accounts))
+		for _, a := range accounts {
+			ret = append(ret, a.Name)
+		}
+		return ret
+	}
+	if !strings.Contains(account, ",") {
+		return nil
+	}
+	return util.Str2List(account, ",")
+}
+
+// accountCtx 返回只查询单个账号的 ctx，账号为空时查询当前账号
+// 不使用 WithAccount，以便空账号覆盖 ctx 中的合并账号
+func accountCtx(ctx context.Context, account string) context.Context {
+	return context.WithValue(ctx, accountKey{}, account)
+}
+
+// selfID 账号本人的微信 ID，未在配置中指定 wxid 时使用账号名
+func (s *Service) selfID(account string) string {
+	if account == "" {
+		account = s.ctx.Account
+	}
+	if history, ok := s.ctx.History[account]; ok && history.WxID != "" {
+		return history.WxID
+	}
+	return account
+}
+
+// mergeMessages 分别查询各账号的聊天记录，按时间交错合并为一条时间线
+// 每条消息记录来源账号；各账号自己发送的消息以本人的微信 ID 作为发送人，
+// 发送人为任一合并账号本人时同样视为自己发送，如两个账号互发的消息
+func (s *Service) mergeMessages(ctx context.Context, accounts []string, desc bool, query func(ctx context.Context) ([]*model.Message, error)) ([]*model.Message, error) {
+	if len(accounts) == 0 {
+		return nil, errors.WeChatAccountNotFound(AccountOf(ctx))
+	}
+	selves := make(map[string]bool)
+	merged := make([]*model.Message, 0)
+	for _, account := range accounts {
+		messages, err := query(accountCtx(ctx, account))
+		if err != nil {
+			return nil, err
+		}
+		id := s.selfID(account)
+		selves[id] = true
+		name := account
+		if name == "" {
+			name = s.ctx.Account
+		}
+		for _, m := range messages {
+			m.Account = name
+			if m.IsSelf {
+				if m.Sender == "" {
+					m.Sender = id
+				}
+				selves[m.Sender] = true
+			}
+		}
+		merged = append(merged, messages...)
+	}
+	for _, m := range merged {
+		if selves[m.Sender] {
+			m.IsSelf = true
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		if desc {
+			return merged[i].Time.After(merged[j].Time)
+		}
+		return merged[i].Time.Before(merged[j].Time)
+	})
+	if window := dedupOf(ctx); window > 0 {
+		merged = dedupMessages(merged, window)
+	}
+	return merged, nil
+}
+
+// getMergedMessages 合并模式下的 GetMessages，各账号分别取前 offset+limit 条后合并再分页
+// 开启去重时每页去除的消息数不同，分页结果可能少于 limit 条
+func (s *Service) getMergedMessages(ctx context.Context, accounts []string, start, end time.Time, talker string, sender string, keyword string, msgType string, desc bool, limit, offset int) ([]*model.Message, error) {
+	n := 0
+	if limit > 0 {
+		n = offset + limit
+	}
+	messages, err := s.mergeMessages(ctx, accounts, desc, func(ctx context.Context) ([]*model.Message, error) {
+		return s.GetMessages(ctx, start, end, talker, sender, keyword, msgType, desc, n, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if offset >= len(messages) {
+		return []*model.Message{}, nil
+	}
+	messages = messages[offset:]
+	if limit > 0 && limit < len(messages) {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// countMergedMessages 合并模式下的 CountMessages，各账号的消息数之和，开启去重时读取全部消息后计数
+func (s *Service) countMergedMessages(ctx context.Context, accounts []string, start, end time.Time, talker string) (int, error) {
+	if dedupOf(ctx) > 0 {
+		messages, err := s.getMergedMessages(ctx, accounts, start, end, talker, "", "", "", false, 0, 0)
+		if err != nil {
+			return 0, err
+		}
+		return len(messages), nil
+	}
+	total := 0
+	for _, account := range accounts {
+		n, err := s.CountMessages(accountCtx(ctx, account), start, end, talker)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// getMergedMedia 合并模式下按账号顺序查找多媒体文件，记录找到文件的账号
+func (s *Service) getMergedMedia(ctx context.Context, accounts []string, _type string, key string) (*model.Media, error) {
+	var _err error
+	for _, account := range accounts {
+		media, err := s.GetMedia(accountCtx(ctx, account), _type, key)
+		if err != nil {
+			_err = err
+			continue
+		}
+		media.Account = account
+		if media.Account == "" {
+			media.Account = s.ctx.Account
+		}
+		return media, nil
+	}
+	if _err == nil {
+		_err = errors.WeChatAccountNotFound(AccountOf(ctx))
+	}
+	return nil, _err
+}
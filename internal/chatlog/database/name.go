@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/internal/wechatdb"
+)
+
+// displayNames 当前账号的自定义显示名称，读取失败时只记录日志，按原有名称显示
+func (s *Service) displayNames(ctx context.Context) map[string]string {
+	names, err := s.sidecar.DisplayNames(ctx, s.AccountName(ctx))
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to load display names")
+		return nil
+	}
+	return names
+}
+
+// resolveName 查询条件为自定义显示名称时替换为对应的会话 ID
+func resolveName(names map[string]string, key string) string {
+	for id, name := range names {
+		if name == key {
+			return id
+		}
+	}
+	return key
+}
+
+// renameMessages 以自定义显示名称替换消息中的会话名称与发送人名称
+func renameMessages(names map[string]string, messages ...*model.Message) {
+	if len(names) == 0 {
+		return
+	}
+	for _, m := range messages {
+		if name, ok := names[m.Talker]; ok {
+			m.TalkerName = name
+		}
+		if name, ok := names[m.Sender]; ok && m.Sender != "" {
+			m.SenderName = name
+		}
+	}
+}
+
+// renameContacts 以自定义显示名称作为联系人备注，DisplayName 与各种输出随之使用该名称
+// 仓库返回的是缓存中的联系人，替换前先复制
+func renameContacts(names map[string]string, resp *wechatdb.GetContactsResp) {
+	if resp == nil || len(names) == 0 {
+		return
+	}
+	items := make([]*model.Contact, len(resp.Items))
+	for i, contact := range resp.Items {
+		items[i] = contact
+		if name, ok := names[contact.UserName]; ok {
+			c := *contact
+			c.Remark = name
+			items[i] = &c
+		}
+	}
+	resp.Items = items
+}
+
+// renameChatRooms 以自定义显示名称作为群聊备注，群成员的显示名称同样替换，替换前先复制
+func renameChatRooms(names map[string]string, resp *wechatdb.GetChatRoomsResp) {
+	if resp == nil || len(names) == 0 {
+		return
+	}
+	items := make([]*model.ChatRoom, len(resp.Items))
+	for i, room := range resp.Items {
+		r := *room
+		if name, ok := names[r.Name]; ok {
+			r.Remark = name
+		}
+		r.Users = make([]model.ChatRoomUser, len(room.Users))
+		for j, user := range room.Users {
+			if name, ok := names[user.UserName]; ok {
+				user.DisplayName = name
+			}
+			r.Users[j] = user
+		}
+		r.User2DisplayName = make(map[string]string, len(room.User2DisplayName))
+		for id, displayName := range room.User2DisplayName {
+			if name, ok := names[id]; ok {
+				displayName = name
+			}
+			r.User2DisplayName[id] = displayName
+		}
+		items[i] = &r
+	}
+	resp.Items = items
+}
+
+// renameSessions 以自定义显示名称替换会话列表中的名称
+func renameSessions(names map[string]string, resp *wechatdb.GetSessionsResp) {
+	if resp == nil || len(names) == 0 {
+		return
+	}
+	items := make([]*model.Session, len(resp.Items))
+	for i, session := range resp.Items {
+		items[i] = session
+		if name, ok := names[session.UserName]; ok {
+			c := *session
+			c.NickName = name
+			items[i] = &c
+		}
+	}
+	resp.Items = items
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/wechatdb"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// PruneOptions 清理条件，Before 与 Talkers 至少指定一个
+type PruneOptions struct {
+	// Before 删除此时间之前的消息，零值表示不限时间
+	Before time.Time
+
+	// Talkers 只清理这些会话，须为完整的 wxid 或群聊 ID，为空时清理最近会话列表中的所有会话
+	Talkers []string
+
+	// DryRun 只统计将被删除的数据，不做修改
+	DryRun bool
+}
+
+// PruneItem 单个会话的清理结果
+type PruneItem struct {
+	Talker   string `json:"talker"`
+	Name     string `json:"name,omitempty"`
+	Messages int    `json:"messages"`
+	Media    int    `json:"media"` // 其中图片、语音、视频、文件消息的数量
+}
+
+// PruneReport 清理结果，DryRun 时为将被删除的数据
+type PruneReport struct {
+	Before   *time.Time   `json:"before,omitempty"`
+	DryRun   bool         `json:"dryRun"`
+	Messages int          `json:"messages"`
+	Media    int          `json:"media"`
+	Items    []*PruneItem `json:"items"`
+}
+
+// Prune 从工作目录中删除过期或指定会话的消息，语音数据随消息一起删除
+// 数据目录中的图片、视频、文件不做修改；重新解密后数据会恢复，需配合 DryRun 确认范围后使用
+func (s *Service) Prune(ctx context.Context, opts PruneOptions) (*PruneReport, error) {
+	if opts.Before.IsZero() && len(opts.Talkers) == 0 {
+		return nil, errors.InvalidArg("before")
+	}
+	db, err := s.getDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 删除过程不随请求取消而中断，避免只删除了部分会话
+	bg := context.Background()
+
+	report := &PruneReport{DryRun: opts.DryRun, Items: make([]*PruneItem, 0)}
+	start, end, _ := util.TimeRangeOf("all")
+	if !opts.Before.IsZero() {
+		report.Before = &opts.Before
+		// 消息时间精确到秒，查询范围包含结束时间
+		end = opts.Before.Add(-time.Second)
+	}
+
+	items := make([]*PruneItem, 0)
+	if len(opts.Talkers) > 0 {
+		for _, talker := range opts.Talkers {
+			name, err := exactTalkerName(bg, db, talker)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, &PruneItem{Talker: talker, Name: name})
+		}
+	} else {
+		resp, err := db.GetSessions(bg, "", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range resp.Items {
+			items = append(items, &PruneItem{Talker: session.UserName, Name: session.NickName})
+		}
+	}
+
+	for _, item := range items {
+		count, err := db.CountMessages(bg, start, end, item.Talker)
+		if err != nil {
+			log.Debug().Err(err).Msgf("count messages of %s failed", item.Talker)
+			continue
+		}
+		if count == 0 {
+			continue
+		}
+		item.Messages = count
+		if media, err := db.GetMessages(bg, start, end, item.Talker, "", "", "image,voice,video,file", false, 0, 0); err == nil {
+			item.Media = len(media)
+		}
+
+		if !opts.DryRun {
+			n, err := db.DeleteMessages(start, end, item.Talker)
+			if err != nil {
+				return report, err
+			}
+			item.Messages = n
+		}
+		report.Messages += item.Messages
+		report.Media += item.Media
+		report.Items = append(report.Items, item)
+	}
+
+	if !opts.DryRun {
+		log.Info().Msgf("pruned %d messages from %d sessions", report.Messages, len(report.Items))
+	}
+	return report, nil
+}
+
+// exactTalkerName 按完整的 wxid 或群聊 ID 查找会话名称
+// 会话与联系人查询包含模糊匹配，只接受 ID 完全一致的结果，避免清理到其他会话
+func exactTalkerName(ctx context.Context, db *wechatdb.DB, talker string) (string, error) {
+	if contacts, err := db.GetContacts(ctx, talker, 0, 0); err == nil {
+		for _, contact := range contacts.Items {
+			if contact.UserName == talker {
+				return contact.DisplayName(), nil
+			}
+		}
+	}
+	if chatRooms, err := db.GetChatRooms(ctx, talker, 0, 0); err == nil {
+		for _, room := range chatRooms.Items {
+			if room.Name == talker {
+				return room.DisplayName(), nil
+			}
+		}
+	}
+	if sessions, err := db.GetSessions(ctx, talker, 0, 0); err == nil {
+		for _, session := range sessions.Items {
+			if session.UserName == talker {
+				return session.NickName, nil
+			}
+		}
+	}
+	return "", errors.TalkerNotFound(talker)
+}
@@ -1,68 +1,514 @@
 package database
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+
 	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+	"github.com/sjzar/chatlog/internal/chatlog/sidecar"
 	"github.com/sjzar/chatlog/internal/model"
 	"github.com/sjzar/chatlog/internal/wechatdb"
+	"github.com/sjzar/chatlog/pkg/filecrypt"
+	"github.com/sjzar/chatlog/pkg/trace"
 )
 
 type Service struct {
 	ctx *ctx.Context
-	db  *wechatdb.DB
+
+	// 未解密或已锁定时 db 为 nil
+	dbMu sync.RWMutex
+	db   *wechatdb.DB
+
+	// 通过 account 参数查询的其他账号，首次查询时打开，Stop 时关闭
+	accounts map[string]*wechatdb.DB
+
+	// 消息、会话数据库更新的订阅者
+	mutex       sync.Mutex
+	subscribers map[chan struct{}]struct{}
+
+	// 收藏等附加数据，保存在配置目录中
+	sidecar *sidecar.Store
 }
 
 func NewService(ctx *ctx.Context) *Service {
-	return &Service{
-		ctx: ctx,
+	s := &Service{
+		ctx:         ctx,
+		accounts:    make(map[string]*wechatdb.DB),
+		subscribers: make(map[chan struct{}]struct{}),
 	}
+	s.sidecar = sidecar.New(s.sidecarPath)
+	return s
 }
 
 func (s *Service) Start() error {
-	db, err := wechatdb.New(s.ctx.WorkDir, s.ctx.Platform, s.ctx.Version)
+	key, err := filecrypt.DirKey(s.ctx.WorkDir, s.ctx.WorkKey)
 	if err != nil {
 		return err
 	}
+	db, err := wechatdb.New(s.ctx.WorkDir, s.ctx.Platform, s.ctx.Version, key)
+	if err != nil {
+		return err
+	}
+	db.SetExclude(s.ctx.Settings().Exclude)
+	s.dbMu.Lock()
 	s.db = db
+	s.dbMu.Unlock()
+	for _, name := range []string{"message", "session"} {
+		if err := db.SetCallback(name, s.updateCallback); err != nil {
+			log.Debug().Err(err).Msgf("watch %s db failed", name)
+		}
+	}
 	return nil
 }
 
+func (s *Service) updateCallback(event fsnotify.Event) error {
+	if !event.Op.Has(fsnotify.Create) {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for ch := range s.subscribers {
+		// 通知合并，订阅者尚未处理时不重复发送
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Subscribe 订阅消息、会话数据库更新，返回通知通道与取消订阅函数
+func (s *Service) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	s.mutex.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mutex.Unlock()
+	return ch, func() {
+		s.mutex.Lock()
+		delete(s.subscribers, ch)
+		s.mutex.Unlock()
+	}
+}
+
 func (s *Service) Stop() error {
+	s.dbMu.Lock()
+	defer s.dbMu.Unlock()
 	if s.db != nil {
 		s.db.Close()
 	}
 	s.db = nil
+	for name, db := range s.accounts {
+		db.Close()
+		delete(s.accounts, name)
+	}
+	s.sidecar.Close()
 	return nil
 }
 
+// Reload 应用重新加载的排除列表，已打开的各账号数据库同时更新
+func (s *Service) Reload() {
+	s.dbMu.Lock()
+	defer s.dbMu.Unlock()
+	if s.db != nil {
+		s.db.SetExclude(s.ctx.Settings().Exclude)
+	}
+	for _, db := range s.accounts {
+		db.SetExclude(s.ctx.Settings().Exclude)
+	}
+}
+
 func (s *Service) GetDB() *wechatdb.DB {
+	s.dbMu.RLock()
+	defer s.dbMu.RUnlock()
 	return s.db
 }
 
-func (s *Service) GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
-	return s.db.GetMessages(start, end, talker, sender, keyword, limit, offset)
+// queryCtx 为单次查询附加超时，超时或请求取消时中断查询
+func (s *Service) queryCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := s.ctx.Settings().QueryTimeout
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+}
+
+// idleTimer 逐条读取时的查询超时，只计算等待数据库返回下一条消息的时间
+// 导出、流式输出等回调的耗时不计入，避免大量消息的导出因总耗时超过 query_timeout 被中断
+type idleTimer struct {
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+// iterCtx 为逐条读取附加空闲超时，超时时以 context.DeadlineExceeded 取消查询
+func (s *Service) iterCtx(ctx context.Context) (context.Context, *idleTimer, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	t := &idleTimer{timeout: time.Duration(s.ctx.Settings().QueryTimeout) * time.Second}
+	if t.timeout > 0 {
+		t.timer = time.AfterFunc(t.timeout, func() { cancel(context.DeadlineExceeded) })
+	}
+	return ctx, t, func() {
+		t.pause()
+		cancel(context.Canceled)
+	}
+}
+
+// pause 回调执行期间暂停计时
+func (t *idleTimer) pause() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+// resume 回调返回后重新开始计时
+func (t *idleTimer) resume() {
+	if t.timer != nil {
+		t.timer.Reset(t.timeout)
+	}
+}
+
+func (s *Service) GetMessages(ctx context.Context, start, end time.Time, talker string, sender string, keyword string, msgType string, desc bool, limit, offset int) ([]*model.Message, error) {
+	if accounts := s.mergedAccounts(ctx); accounts != nil {
+		return s.getMergedMessages(ctx, accounts, start, end, talker, sender, keyword, msgType, desc, limit, offset)
+	}
+	db, err := s.getDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names, ids := s.displayNames(ctx), s.identityMap(ctx)
+	if talker, err = s.expandTalkers(ctx, talker, names, ids); err != nil {
+		return nil, err
+	}
+	sender = expandIdentities(ids, resolveName(names, sender))
+	ctx, cancel := s.queryCtx(ctx)
+	defer cancel()
+	ctx, done := s.observe(ctx, "GetMessages", start, end, talker, sender, keyword, msgType, desc, limit, offset)
+	defer done()
+	messages, err := db.GetMessages(ctx, start, end, talker, sender, keyword, msgType, desc, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	newIdentityResolver(db, ids).messages(ctx, messages...)
+	renameMessages(names, messages...)
+	return messages, nil
+}
+
+// IterMessages 逐条读取消息，用于结果较多时流式输出或统计
+// 合并多个账号时需要先读取全部消息再按时间排序
+func (s *Service) IterMessages(ctx context.Context, start, end time.Time, talker string, sender string, keyword string, msgType string, desc bool, fn func(*model.Message) error) error {
+	if accounts := s.mergedAccounts(ctx); accounts != nil {
+		messages, err := s.getMergedMessages(ctx, accounts, start, end, talker, sender, keyword, msgType, desc, 0, 0)
+		if err != nil {
+			return err
+		}
+		for _, m := range messages {
+			if err := fn(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	db, err := s.getDB(ctx)
+	if err != nil {
+		return err
+	}
+	names, ids := s.displayNames(ctx), s.identityMap(ctx)
+	if talker, err = s.expandTalkers(ctx, talker, names, ids); err != nil {
+		return err
+	}
+	sender = expandIdentities(ids, resolveName(names, sender))
+	ctx, timer, cancel := s.iterCtx(ctx)
+	defer cancel()
+	ctx, done := s.observe(ctx, "IterMessages", start, end, talker, sender, keyword, msgType, desc)
+	defer done()
+	resolver := newIdentityResolver(db, ids)
+	err = db.IterMessages(ctx, start, end, talker, sender, keyword, msgType, desc, func(m *model.Message) error {
+		resolver.messages(ctx, m)
+		renameMessages(names, m)
+		timer.pause()
+		defer timer.resume()
+		return fn(m)
+	})
+	if err != nil && context.Cause(ctx) == context.DeadlineExceeded {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+func (s *Service) GetMessage(ctx context.Context, talker string, seq int64) (*model.Message, error) {
+	db, err := s.getDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := s.queryCtx(ctx)
+	defer cancel()
+	ctx, done := s.observe(ctx, "GetMessage", talker, seq)
+	defer done()
+	names, ids := s.displayNames(ctx), s.identityMap(ctx)
+	talker = resolveName(names, talker)
+	message, err := db.GetMessage(ctx, talker, seq)
+	if err != nil && isIdentity(ids, talker) {
+		// 消息可能属于同一身份的其他账号
+		for _, id := range identityMembers(ids, talker) {
+			if id == talker {
+				continue
+			}
+			if m, e := db.GetMessage(ctx, id, seq); e == nil {
+				message, err = m, nil
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	newIdentityResolver(db, ids).messages(ctx, message)
+	renameMessages(names, message)
+	return message, nil
+}
+
+func (s *Service) GetMessageContext(ctx context.Context, talker string, seq int64, before, after int) ([]*model.Message, int, error) {
+	db, err := s.getDB(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	ctx, cancel := s.queryCtx(ctx)
+	defer cancel()
+	ctx, done := s.observe(ctx, "GetMessageContext", talker, seq, before, after)
+	defer done()
+	names, ids := s.displayNames(ctx), s.identityMap(ctx)
+	talker = resolveName(names, talker)
+	messages, index, err := db.GetMessageContext(ctx, talker, seq, before, after)
+	if err != nil && isIdentity(ids, talker) {
+		for _, id := range identityMembers(ids, talker) {
+			if id == talker {
+				continue
+			}
+			if m, i, e := db.GetMessageContext(ctx, id, seq, before, after); e == nil {
+				messages, index, err = m, i, nil
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	newIdentityResolver(db, ids).messages(ctx, messages...)
+	renameMessages(names, messages...)
+	return messages, index, nil
 }
 
-func (s *Service) GetContacts(key string, limit, offset int) (*wechatdb.GetContactsResp, error) {
-	return s.db.GetContacts(key, limit, offset)
+func (s *Service) GetContacts(ctx context.Context, key string, limit, offset int) (*wechatdb.GetContactsResp, error) {
+	db, err := s.getDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := s.queryCtx(ctx)
+	defer cancel()
+	ctx, done := s.observe(ctx, "GetContacts", key, limit, offset)
+	defer done()
+	names := s.displayNames(ctx)
+	resp, err := db.GetContacts(ctx, resolveName(names, key), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	renameContacts(names, resp)
+	return resp, nil
 }
 
-func (s *Service) GetChatRooms(key string, limit, offset int) (*wechatdb.GetChatRoomsResp, error) {
-	return s.db.GetChatRooms(key, limit, offset)
+func (s *Service) GetChatRooms(ctx context.Context, key string, limit, offset int) (*wechatdb.GetChatRoomsResp, error) {
+	db, err := s.getDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := s.queryCtx(ctx)
+	defer cancel()
+	ctx, done := s.observe(ctx, "GetChatRooms", key, limit, offset)
+	defer done()
+	names := s.displayNames(ctx)
+	resp, err := db.GetChatRooms(ctx, resolveName(names, key), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	renameChatRooms(names, resp)
+	return resp, nil
 }
 
 // GetSession retrieves session information
-func (s *Service) GetSessions(key string, limit, offset int) (*wechatdb.GetSessionsResp, error) {
-	return s.db.GetSessions(key, limit, offset)
+func (s *Service) GetSessions(ctx context.Context, key string, limit, offset int) (*wechatdb.GetSessionsResp, error) {
+	db, err := s.getDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := s.queryCtx(ctx)
+	defer cancel()
+	ctx, done := s.observe(ctx, "GetSessions", key, limit, offset)
+	defer done()
+	names := s.displayNames(ctx)
+	resp, err := db.GetSessions(ctx, resolveName(names, key), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	newIdentityResolver(db, s.identityMap(ctx)).sessions(ctx, resp)
+	renameSessions(names, resp)
+	return resp, nil
 }
 
-func (s *Service) GetMedia(_type string, key string) (*model.Media, error) {
-	return s.db.GetMedia(_type, key)
+func (s *Service) CountMessages(ctx context.Context, start, end time.Time, talker string) (int, error) {
+	if accounts := s.mergedAccounts(ctx); accounts != nil {
+		return s.countMergedMessages(ctx, accounts, start, end, talker)
+	}
+	db, err := s.getDB(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if talker, err = s.ExpandTalkers(ctx, talker); err != nil {
+		return 0, err
+	}
+	ctx, cancel := s.queryCtx(ctx)
+	defer cancel()
+	ctx, done := s.observe(ctx, "CountMessages", start, end, talker)
+	defer done()
+	return db.CountMessages(ctx, start, end, talker)
+}
+
+// PurgeTalker 从工作目录中删除会话数据
+func (s *Service) PurgeTalker(ctx context.Context, talker string) (int, error) {
+	db, err := s.getDB(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return db.PurgeTalker(talker)
+}
+
+// BuildIndexes 在工作目录的消息数据库中建立辅助索引
+func (s *Service) BuildIndexes() (int, error) {
+	db, err := s.getDB(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	return db.BuildIndexes()
+}
+
+// GetStats 按会话、发送人、整点统计消息数量，已汇总的部分读取统计数据库
+func (s *Service) GetStats(ctx context.Context, start, end time.Time, talker string) ([]*model.HourStat, error) {
+	db, err := s.getDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if talker, err = s.ExpandTalkers(ctx, talker); err != nil {
+		return nil, err
+	}
+	ctx, cancel := s.queryCtx(ctx)
+	defer cancel()
+	ctx, done := s.observe(ctx, "GetStats", start, end, talker)
+	defer done()
+	stats, err := db.GetStats(ctx, start, end, talker)
+	if err != nil {
+		return nil, err
+	}
+	return newIdentityResolver(db, s.identityMap(ctx)).stats(stats), nil
+}
+
+// BuildStats 重新汇总工作目录中的消息统计，返回汇总的会话数量
+func (s *Service) BuildStats() (int, error) {
+	db, err := s.getDB(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	return db.BuildStats(context.Background())
+}
+
+// BuildSearchIndex 重新建立工作目录中的消息全文索引，返回建立索引的会话数量
+func (s *Service) BuildSearchIndex() (int, error) {
+	db, err := s.getDB(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	return db.BuildSearchIndex(context.Background())
+}
+
+func (s *Service) CountContacts(ctx context.Context) (int, error) {
+	db, err := s.getDB(ctx)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := s.queryCtx(ctx)
+	defer cancel()
+	ctx, done := s.observe(ctx, "CountContacts")
+	defer done()
+	return db.CountContacts(ctx)
+}
+
+func (s *Service) CountChatRooms(ctx context.Context) (int, error) {
+	db, err := s.getDB(ctx)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := s.queryCtx(ctx)
+	defer cancel()
+	ctx, done := s.observe(ctx, "CountChatRooms")
+	defer done()
+	return db.CountChatRooms(ctx)
+}
+
+func (s *Service) CountSessions(ctx context.Context) (int, error) {
+	db, err := s.getDB(ctx)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := s.queryCtx(ctx)
+	defer cancel()
+	ctx, done := s.observe(ctx, "CountSessions")
+	defer done()
+	return db.CountSessions(ctx)
+}
+
+func (s *Service) GetMedia(ctx context.Context, _type string, key string) (*model.Media, error) {
+	if accounts := s.mergedAccounts(ctx); accounts != nil {
+		return s.getMergedMedia(ctx, accounts, _type, key)
+	}
+	db, err := s.getDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := s.queryCtx(ctx)
+	defer cancel()
+	ctx, done := s.observe(ctx, "GetMedia", _type, key)
+	defer done()
+	return db.GetMedia(ctx, _type, key)
+}
+
+// observe 开始一次查询，返回的函数在查询结束时调用
+// 启用链路追踪时记录查询的 Span，耗时超过阈值时记录查询名称与参数，流式读取的耗时包含调用方处理每条消息的时间
+func (s *Service) observe(ctx context.Context, name string, args ...interface{}) (context.Context, func()) {
+	start := time.Now()
+	ctx, span := trace.Start(ctx, "db."+name, trace.String("db.operation.name", name), trace.String("db.query.args", fmt.Sprintf("%v", args)))
+	return ctx, func() {
+		span.SetError(ctx.Err())
+		span.End()
+		s.logSlow(start, name, args...)
+	}
+}
+
+// logSlow 查询耗时超过阈值时记录查询名称与参数
+func (s *Service) logSlow(start time.Time, name string, args ...interface{}) {
+	threshold := s.ctx.Settings().SlowQuery
+	if threshold <= 0 {
+		return
+	}
+	elapsed := time.Since(start)
+	if elapsed < time.Duration(threshold)*time.Millisecond {
+		return
+	}
+	log.Warn().Dur("elapsed", elapsed).Str("query", name).Interface("args", args).Msg("慢查询")
 }
 
 // Close closes the database connection
 func (s *Service) Close() {
-	// Add cleanup code if needed
-	s.db.Close()
+	s.Stop()
 }
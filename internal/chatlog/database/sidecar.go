@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/sjzar/chatlog/internal/chatlog/sidecar"
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// Sidecar 收藏等用户添加的附加数据，按 AccountName 区分账号
+func (s *Service) Sidecar() *sidecar.Store {
+	return s.sidecar
+}
+
+// AccountName ctx 指定的账号名称，未指定时为当前账号，合并多个账号时为第一个账号
+func (s *Service) AccountName(ctx context.Context) string {
+	if account := s.primaryAccount(ctx); !s.isCurrent(account) {
+		return account
+	}
+	return s.ctx.Account
+}
+
+func (s *Service) sidecarPath() string {
+	if s.ctx.ConfigDir == "" {
+		return ""
+	}
+	return filepath.Join(s.ctx.ConfigDir, sidecar.File)
+}
+
+// TagPrefix 以 tag: 开头的聊天对象表示带有该标签的所有会话，如 tag:家人，可与其他会话以逗号分隔
+const TagPrefix = "tag:"
+
+// ExpandTalkers 将以逗号分隔的聊天对象中的标签替换为带有该标签的会话，自定义显示名称替换为对应的会话 ID，
+// 合并身份中的账号展开为该身份的全部账号；标签不存在或没有会话时返回 404 错误
+func (s *Service) ExpandTalkers(ctx context.Context, talker string) (string, error) {
+	if talker == "" {
+		return talker, nil
+	}
+	return s.expandTalkers(ctx, talker, s.displayNames(ctx), s.identityMap(ctx))
+}
+
+func (s *Service) expandTalkers(ctx context.Context, talker string, names, ids map[string]string) (string, error) {
+	if talker == "" {
+		return talker, nil
+	}
+	items := util.Str2List(talker, ",")
+	ret := make([]string, 0, len(items))
+	for _, item := range items {
+		tag, ok := strings.CutPrefix(item, TagPrefix)
+		if !ok {
+			ret = append(ret, s.identityOf(ctx, ids, resolveName(names, item)))
+			continue
+		}
+		talkers, err := s.sidecar.TagTalkers(ctx, s.AccountName(ctx), tag)
+		if err != nil {
+			return "", err
+		}
+		if len(talkers) == 0 {
+			return "", errors.TagNotFound(tag)
+		}
+		ret = append(ret, talkers...)
+	}
+	return expandIdentities(ids, strings.Join(ret, ",")), nil
+}
+
+// identityOf 以名称指定合并身份中的联系人时替换为其微信 ID，以便展开为该身份的全部账号
+func (s *Service) identityOf(ctx context.Context, ids map[string]string, key string) string {
+	if len(ids) == 0 || isIdentity(ids, key) {
+		return key
+	}
+	db, err := s.getDB(ctx)
+	if err != nil {
+		return key
+	}
+	if resp, err := db.GetContacts(ctx, key, 0, 0); err == nil && len(resp.Items) == 1 && isIdentity(ids, resp.Items[0].UserName) {
+		return resp.Items[0].UserName
+	}
+	return key
+}
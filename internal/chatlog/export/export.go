@@ -0,0 +1,330 @@
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/i18n"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// 支持的导出格式
+const (
+	FormatHTML = "html"
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// Options 导出条件
+type Options struct {
+	// Talkers 导出的会话，可使用 tag:名称 表示带有该标签的会话，为空时导出最近会话列表中的全部会话
+	Talkers []string
+	Start   time.Time
+	End     time.Time
+
+	// Format 导出格式：html、csv 或 json
+	Format string
+
+	// OutDir 输出目录，每个会话写入一个文件
+	OutDir string
+
+	// Host 聊天记录中多媒体链接使用的服务地址，如 127.0.0.1:5030
+	Host string
+
+	// Lang 纯文本内容与表头使用的语言，为空时使用中文
+	Lang string
+}
+
+// Item 单个会话的导出结果
+type Item struct {
+	Talker   string `json:"talker"`
+	Name     string `json:"name"`
+	Messages int    `json:"messages"`
+	File     string `json:"file"`
+}
+
+// Report 导出结果
+type Report struct {
+	Items    []*Item `json:"items"`
+	Messages int     `json:"messages"`
+}
+
+// Run 将会话的聊天记录逐条读出写入输出目录，不在内存中保留全部消息
+// 没有消息的会话不生成文件
+func Run(ctx context.Context, db *database.Service, opts Options) (*Report, error) {
+	format := strings.ToLower(opts.Format)
+	switch format {
+	case FormatHTML, FormatCSV, FormatJSON:
+	default:
+		return nil, errors.InvalidArg("format")
+	}
+	if opts.OutDir == "" {
+		return nil, errors.InvalidArg("out")
+	}
+	if err := util.PrepareDir(opts.OutDir); err != nil {
+		return nil, err
+	}
+
+	talkers := opts.Talkers
+	if len(talkers) > 0 {
+		expanded, err := db.ExpandTalkers(ctx, strings.Join(talkers, ","))
+		if err != nil {
+			return nil, err
+		}
+		talkers = util.Str2List(expanded, ",")
+	}
+	if len(talkers) == 0 {
+		sessions, err := db.GetSessions(ctx, "", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range sessions.Items {
+			talkers = append(talkers, session.UserName)
+		}
+	}
+
+	report := &Report{Items: make([]*Item, 0, len(talkers))}
+	for _, talker := range talkers {
+		item, err := exportTalker(ctx, db, talker, format, opts)
+		if err != nil {
+			return report, err
+		}
+		if item.Messages == 0 {
+			continue
+		}
+		report.Items = append(report.Items, item)
+		report.Messages += item.Messages
+	}
+	return report, nil
+}
+
+// exportTalker 导出单个会话，先写入临时文件，完成后替换，没有消息时删除
+func exportTalker(ctx context.Context, db *database.Service, talker string, format string, opts Options) (item *Item, err error) {
+	item = &Item{Talker: talker, File: filepath.Join(opts.OutDir, fileName(talker)+"."+format)}
+	temp := item.File + ".tmp"
+	f, err := os.Create(temp)
+	if err != nil {
+		return nil, errors.OpenFileFailed(temp, err)
+	}
+	defer func() {
+		f.Close()
+		if err != nil || item.Messages == 0 {
+			os.Remove(temp)
+			return
+		}
+		if err = os.Rename(temp, item.File); err != nil {
+			err = errors.WriteOutputFailed(err)
+		}
+	}()
+
+	w := newWriter(f, format, opts)
+	err = db.IterMessages(ctx, opts.Start, opts.End, talker, "", "", "", false, func(m *model.Message) error {
+		if item.Messages == 0 {
+			item.Name = talkerName(ctx, db, m)
+			if err := w.begin(item); err != nil {
+				return err
+			}
+		}
+		item.Messages++
+		if opts.Lang != "" {
+			m.SetContent("lang", opts.Lang)
+		}
+		return w.write(m)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if item.Messages > 0 {
+		if err := w.end(); err != nil {
+			return nil, errors.WriteOutputFailed(err)
+		}
+	}
+	return item, nil
+}
+
+// talkerName 会话名称，私聊消息中没有会话名称时从联系人中查找
+func talkerName(ctx context.Context, db *database.Service, m *model.Message) string {
+	if m.TalkerName != "" {
+		return m.TalkerName
+	}
+	if m.IsChatRoom {
+		if rooms, err := db.GetChatRooms(ctx, m.Talker, 1, 0); err == nil && len(rooms.Items) > 0 {
+			return rooms.Items[0].DisplayName()
+		}
+		return ""
+	}
+	if contacts, err := db.GetContacts(ctx, m.Talker, 0, 0); err == nil {
+		for _, contact := range contacts.Items {
+			if contact.UserName == m.Talker {
+				return contact.DisplayName()
+			}
+		}
+	}
+	return ""
+}
+
+// fileName 会话 ID 作为文件名，替换路径分隔符等不能用于文件名的字符
+func fileName(talker string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, talker)
+}
+
+// writer 按格式逐条写出消息
+type writer interface {
+	begin(item *Item) error
+	write(m *model.Message) error
+	end() error
+}
+
+func newWriter(w io.Writer, format string, opts Options) writer {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w), opts: opts}
+	case FormatJSON:
+		return &jsonWriter{w: w, opts: opts}
+	default:
+		return &htmlWriter{w: w, opts: opts}
+	}
+}
+
+// csvWriter 每条消息一行，内容为纯文本格式
+type csvWriter struct {
+	w    *csv.Writer
+	opts Options
+}
+
+func (c *csvWriter) begin(item *Item) error {
+	lang := c.opts.Lang
+	if lang == "" {
+		lang = i18n.EN
+	}
+	return c.w.Write(strings.Split(i18n.T(lang, "csv.messages"), ","))
+}
+
+func (c *csvWriter) write(m *model.Message) error {
+	m.SetContent("host", c.opts.Host)
+	return c.w.Write([]string{
+		m.Time.Format("2006-01-02 15:04:05"),
+		m.Talker,
+		m.Sender,
+		m.SenderName,
+		strconv.FormatBool(m.IsSelf),
+		strconv.FormatInt(m.Type, 10),
+		strconv.FormatInt(m.SubType, 10),
+		m.PlainTextContent(),
+	})
+}
+
+func (c *csvWriter) end() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonWriter 输出与 /api/v1/chatlog?format=json 相同结构的消息数组
+type jsonWriter struct {
+	w     io.Writer
+	opts  Options
+	count int
+}
+
+func (j *jsonWriter) begin(item *Item) error {
+	_, err := io.WriteString(j.w, "[")
+	return err
+}
+
+func (j *jsonWriter) write(m *model.Message) error {
+	if j.opts.Host != "" {
+		m.SetMediaURLs("http://" + j.opts.Host)
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if j.count > 0 {
+		if _, err := io.WriteString(j.w, ",\n"); err != nil {
+			return err
+		}
+	}
+	j.count++
+	_, err = j.w.Write(b)
+	return err
+}
+
+func (j *jsonWriter) end() error {
+	_, err := io.WriteString(j.w, "]\n")
+	return err
+}
+
+// htmlWriter 可直接在浏览器中打开的聊天记录页面
+type htmlWriter struct {
+	w    io.Writer
+	opts Options
+}
+
+func (h *htmlWriter) begin(item *Item) error {
+	title := item.Talker
+	if item.Name != "" {
+		title = item.Name
+	}
+	return htmlTemplate.ExecuteTemplate(h.w, "header", map[string]interface{}{
+		"Lang":  h.opts.Lang,
+		"Title": i18n.Tf(h.opts.Lang, "export.title", title),
+	})
+}
+
+func (h *htmlWriter) write(m *model.Message) error {
+	m.SetContent("host", h.opts.Host)
+	sender := m.SenderName
+	if sender == "" {
+		sender = m.Sender
+	}
+	if m.IsSelf {
+		sender = i18n.T(h.opts.Lang, "msg.me")
+	}
+	return htmlTemplate.ExecuteTemplate(h.w, "message", map[string]interface{}{
+		"Time":    m.Time.Format("2006-01-02 15:04:05"),
+		"Sender":  sender,
+		"IsSelf":  m.IsSelf,
+		"Content": m.PlainTextContent(),
+	})
+}
+
+func (h *htmlWriter) end() error {
+	return htmlTemplate.ExecuteTemplate(h.w, "footer", nil)
+}
+
+var htmlTemplate = template.Must(template.New("export").Parse(`{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", "PingFang SC", "Microsoft YaHei", sans-serif; margin: 24px auto; max-width: 900px; }
+.msg { padding: 6px 0; border-bottom: 1px solid #eee; }
+.meta { color: #888; font-size: 12px; }
+.self .meta { color: #2e7d32; }
+.content { white-space: pre-wrap; word-break: break-word; font-size: 14px; }
+</style>
+</head>
+<body>
+<h2>{{.Title}}</h2>
+{{end}}{{define "message"}}<div class="msg{{if .IsSelf}} self{{end}}"><div class="meta">{{.Sender}} {{.Time}}</div><div class="content">{{.Content}}</div></div>
+{{end}}{{define "footer"}}</body>
+</html>
+{{end}}`))
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+// accountPathPrefix 以 /account/<账号>/ 开头的请求等同于带有 account 参数的请求，用于多媒体链接等无法附加参数的地址
+const accountPathPrefix = "/account/"
+
+// accountHandler 去掉 /account/<账号> 前缀后交给路由处理，并在请求上下文中记录账号
+// 在路由之前改写路径，登录、限流与审计等中间件只处理一次，看到的是改写后的路径
+func accountHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, accountPathPrefix)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		account, path, _ := strings.Cut(rest, "/")
+		if account == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		u := *r.URL
+		u.Path = "/" + path
+		u.RawPath = ""
+		r = r.WithContext(database.WithAccount(r.Context(), account))
+		r.URL = &u
+		next.ServeHTTP(w, r)
+	})
+}
+
+// accountMiddleware 按 account 参数指定查询的账号，未指定时查询当前账号
+// 账号记录在请求上下文中，批量查询的子请求、GraphQL 与通过该连接建立的 MCP 会话同样生效
+// 合并多个账号时 dedup=1 去除不同账号中的重复消息，dedup_window 为时间窗口（秒）
+func (s *Service) accountMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if account := c.Query("account"); account != "" {
+			c.Request = c.Request.WithContext(database.WithAccount(c.Request.Context(), account))
+		}
+		if dedup, _ := strconv.ParseBool(c.Query("dedup")); dedup {
+			window, _ := strconv.Atoi(c.Query("dedup_window"))
+			c.Request = c.Request.WithContext(database.WithDedup(c.Request.Context(), time.Duration(window)*time.Second))
+		}
+		c.Next()
+	}
+}
+
+// accountPath 请求指定了账号时返回 /account/<账号> 前缀，用于生成指向该账号多媒体内容的地址
+func accountPath(ctx context.Context) string {
+	if account := database.AccountOf(ctx); account != "" {
+		return accountPathPrefix + url.PathEscape(account)
+	}
+	return ""
+}
+
+// hostOf 聊天记录文本中多媒体链接使用的地址，带有 http.base_path 前缀，请求指定了账号时带有 /account/<账号> 前缀
+func hostOf(c *gin.Context) string {
+	return c.Request.Host + basePath(c.Request.Context()) + accountPath(c.Request.Context())
+}
+
+// mediaCtx 合并多个账号查询时，多媒体文件从找到文件的账号读取
+func mediaCtx(ctx context.Context, media *model.Media) context.Context {
+	return database.WithAccount(ctx, media.Account)
+}
+
+// GetAccounts 列出可查询的账号：当前账号与配置中解密过且工作目录仍存在的账号
+func (s *Service) GetAccounts(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"items": s.db.Accounts()})
+}
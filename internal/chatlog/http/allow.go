@@ -0,0 +1,74 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// parseAllowlist 解析允许访问的地址列表，单个 IP 视为仅包含该地址的网段
+func parseAllowlist(list []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, item := range list {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				log.Warn().Msgf("invalid allow address: %s", item)
+				continue
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(item)
+		if err != nil {
+			log.Warn().Err(err).Msgf("invalid allow address: %s", item)
+			continue
+		}
+		nets = append(nets, ipnet)
+	}
+	return nets
+}
+
+// allowed 客户端地址是否在允许的网段内
+func allowed(nets []*net.IPNet, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipnet := range nets {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) setAllowlist(list []string) {
+	nets := parseAllowlist(list)
+	s.allow.Store(&nets)
+}
+
+// allowMiddleware 拒绝不在 http.allow 中的客户端，避免误绑定到公网地址时泄露聊天记录
+// 允许的网段随配置重新加载更新
+func (s *Service) allowMiddleware() gin.HandlerFunc {
+	s.setAllowlist(s.ctx.Settings().Allow)
+	return func(c *gin.Context) {
+		if !fromUnixSocket(c.Request) && !allowed(*s.allow.Load(), c.ClientIP()) {
+			log.Debug().Msgf("rejected request from %s", c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,329 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/chatlog/analysis"
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/llm"
+	"github.com/sjzar/chatlog/pkg/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// analysisScope 分析范围：对话方 + 时间范围
+type analysisScope struct {
+	Talker string    `json:"talker"`
+	Time   string    `json:"time"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+}
+
+func newAnalysisScope(talker, _time string) (*analysisScope, error) {
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+	start, end, ok := util.TimeRangeOf(_time)
+	if !ok {
+		return nil, errors.InvalidArg("time")
+	}
+	return &analysisScope{
+		Talker: talker,
+		Time:   _time,
+		Start:  start,
+		End:    end,
+	}, nil
+}
+
+// metrics 计算范围内的统计指标，数量来自消息统计，关键词与发送人名称读取文本消息
+func (s *Service) metrics(ctx context.Context, scope *analysisScope) (*analysis.Metrics, error) {
+	stats, err := s.db.GetStats(ctx, scope.Start, scope.End, scope.Talker)
+	if err != nil {
+		return nil, err
+	}
+	texts, err := s.db.GetMessages(ctx, scope.Start, scope.End, scope.Talker, "", "", "text", false, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return analysis.ComputeStats(stats, texts, scope.Start, scope.End, s.options()), nil
+}
+
+// CompareAnalysis 对比两个范围（两个群聊，或同一群聊的两个时间段）的统计指标
+// talker/time 为两个范围的公共参数，talker_a/time_a、talker_b/time_b 分别覆盖对应范围
+// 例如：?talker=工作群&time_a=this-year&time_b=last-year
+func (s *Service) CompareAnalysis(c *gin.Context) {
+	q := struct {
+		Talker  string `form:"talker"`
+		Time    string `form:"time"`
+		TalkerA string `form:"talker_a"`
+		TimeA   string `form:"time_a"`
+		TalkerB string `form:"talker_b"`
+		TimeB   string `form:"time_b"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	or := func(v, def string) string {
+		if v != "" {
+			return v
+		}
+		return def
+	}
+
+	scopeA, err := newAnalysisScope(or(q.TalkerA, q.Talker), or(q.TimeA, q.Time))
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	scopeB, err := newAnalysisScope(or(q.TalkerB, q.Talker), or(q.TimeB, q.Time))
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	metricsA, err := s.metrics(c.Request.Context(), scopeA)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	metricsB, err := s.metrics(c.Request.Context(), scopeB)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	// 两个范围共同的高频词
+	keywordsA := make(map[string]bool)
+	for _, k := range metricsA.TopKeywords {
+		keywordsA[k.Word] = true
+	}
+	commonKeywords := make([]string, 0)
+	for _, k := range metricsB.TopKeywords {
+		if keywordsA[k.Word] {
+			commonKeywords = append(commonKeywords, k.Word)
+		}
+	}
+
+	ratio := func(a, b int) float64 {
+		if a == 0 {
+			return 0
+		}
+		return float64(b-a) / float64(a)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"a": gin.H{"scope": scopeA, "metrics": metricsA},
+		"b": gin.H{"scope": scopeB, "metrics": metricsB},
+		"diff": gin.H{
+			"message_count":        metricsB.MessageCount - metricsA.MessageCount,
+			"message_count_change": ratio(metricsA.MessageCount, metricsB.MessageCount),
+			"active_members":       metricsB.ActiveMembers - metricsA.ActiveMembers,
+			"media_count":          metricsB.MediaCount - metricsA.MediaCount,
+		},
+		"common_keywords": commonKeywords,
+		"generated_at":    time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// GetProfileAnalysis 联系人聊天画像：消息量趋势、活跃时段、常聊话题、多媒体占比
+// summary=true 时调用配置的大模型生成一段文字总结
+func (s *Service) GetProfileAnalysis(c *gin.Context) {
+	q := struct {
+		Talker  string `form:"talker"`
+		Time    string `form:"time"`
+		Summary bool   `form:"summary"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if q.Time == "" {
+		q.Time = "all"
+	}
+
+	scope, err := newAnalysisScope(q.Talker, q.Time)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	resp, err := s.profile(c.Request.Context(), scope, q.Summary)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// profile 计算联系人聊天画像，summary 为 true 时调用大模型生成文字总结
+func (s *Service) profile(ctx context.Context, scope *analysisScope, summary bool) (gin.H, error) {
+	messages, err := s.db.GetMessages(ctx, scope.Start, scope.End, scope.Talker, "", "", "", false, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	profile := analysis.BuildProfile(messages, scope.Start, scope.End, s.options())
+
+	name := scope.Talker
+	if len(messages) > 0 && messages[0].TalkerName != "" {
+		name = messages[0].TalkerName
+	}
+
+	resp := gin.H{
+		"scope":        scope,
+		"name":         name,
+		"profile":      profile,
+		"generated_at": time.Now().Format("2006-01-02 15:04:05"),
+	}
+
+	if summary {
+		conf := s.ctx.Settings().LLM
+		client := llm.NewClient(conf.BaseURL, conf.APIKey, conf.Model)
+		text, err := client.Chat(ctx, profilePrompt(name, profile, messages))
+		if err != nil {
+			// 总结失败不影响统计结果返回
+			resp["summary_error"] = err.Error()
+		} else {
+			resp["summary"] = text
+		}
+	}
+
+	return resp, nil
+}
+
+// profileSampleSize 生成总结时附带的最近文本消息数量
+const profileSampleSize = 50
+
+// profilePrompt 根据聊天画像和最近的文本消息构造大模型提示词
+func profilePrompt(name string, p *analysis.Profile, messages []*model.Message) []llm.Message {
+	m := p.Metrics
+
+	keywords := make([]string, 0, len(m.TopKeywords))
+	for _, k := range m.TopKeywords {
+		keywords = append(keywords, k.Word)
+	}
+
+	samples := make([]string, 0, profileSampleSize)
+	for i := len(messages) - 1; i >= 0 && len(samples) < profileSampleSize; i-- {
+		if messages[i].Type != 1 {
+			continue
+		}
+		sender := messages[i].SenderName
+		if messages[i].IsSelf {
+			sender = "我"
+		} else if sender == "" {
+			sender = messages[i].Sender
+		}
+		samples = append(samples, fmt.Sprintf("%s: %s", sender, messages[i].Content))
+	}
+	// 恢复时间正序
+	for i, j := 0, len(samples)-1; i < j; i, j = i+1, j-1 {
+		samples[i], samples[j] = samples[j], samples[i]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "聊天对象：%s\n", name)
+	fmt.Fprintf(&b, "时间范围：%s 至 %s\n", m.FirstTime.Format("2006-01-02"), m.LastTime.Format("2006-01-02"))
+	fmt.Fprintf(&b, "消息总数：%d，文本消息：%d，多媒体占比：%.1f%%，我发送的占比：%.1f%%\n",
+		m.MessageCount, m.TextCount, p.MediaRatio*100, p.SelfRatio*100)
+	fmt.Fprintf(&b, "消息量趋势：%s（变化 %.1f%%）\n", p.Trend, p.TrendChange*100)
+	fmt.Fprintf(&b, "最活跃时段（小时）：%v\n", p.PeakHours)
+	fmt.Fprintf(&b, "高频话题词：%s\n", strings.Join(keywords, "、"))
+	if len(samples) > 0 {
+		b.WriteString("\n最近的聊天记录：\n")
+		b.WriteString(strings.Join(samples, "\n"))
+	}
+
+	return []llm.Message{
+		{Role: "system", Content: "你是一个聊天记录分析助手。请根据提供的统计数据和聊天片段，用一段简洁的中文（不超过200字）总结与该联系人的交流模式，包括交流频率变化、活跃时间、常聊话题和沟通风格。不要编造数据中没有的信息。"},
+		{Role: "user", Content: b.String()},
+	}
+}
+
+// GenerateAnalysisReport 生成指定时间范围的完整分析报告（统计、活跃会话、活跃热力图、会话话题概要），可通过 talker 限定会话
+// 报告以 wechat_report_<时间戳>.json 写入报告目录，由后台任务执行，返回任务信息
+func (s *Service) GenerateAnalysisReport(c *gin.Context) {
+	_time := c.DefaultQuery("time", "all")
+	if _, _, ok := util.TimeRangeOf(_time); !ok {
+		errors.Err(c, errors.InvalidArg("time"))
+		return
+	}
+
+	j, err := s.jobs.Submit("report", map[string]string{"time": _time, "talker": c.Query("talker"), "account": c.Query("account")})
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, j)
+}
+
+// generateReport 遍历会话生成报告并写入报告目录，talker 为空时包含所有会话，多个会话以英文逗号分隔
+func (s *Service) generateReport(ctx context.Context, _time string, talker string, progress func(int)) (gin.H, error) {
+	if _time == "" {
+		_time = "all"
+	}
+	start, end, ok := util.TimeRangeOf(_time)
+	if !ok {
+		return nil, errors.InvalidArg("time")
+	}
+
+	sessions := make([]*model.Session, 0)
+	if talker != "" {
+		for _, t := range util.Str2List(talker, ",") {
+			sessions = append(sessions, &model.Session{UserName: t})
+		}
+	} else {
+		resp, err := s.db.GetSessions(ctx, "", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		sessions = resp.Items
+	}
+
+	builder := analysis.NewReportBuilder(_time, start, end, s.options())
+	for i, session := range sessions {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		messages, err := s.db.GetMessages(ctx, start, end, session.UserName, "", "", "", false, 0, 0)
+		if err != nil {
+			// 单个会话查询失败（如会话无消息表）不影响整体报告
+			messages = nil
+		}
+		builder.Add(session.UserName, session.NickName, messages)
+		progress((i + 1) * 95 / len(sessions))
+	}
+	report := builder.Build()
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.reportsDir(), 0755); err != nil {
+		return nil, errors.WriteOutputFailed(err)
+	}
+	name := fmt.Sprintf("wechat_report_%s.json", time.Now().Format("20060102_150405"))
+	if err := os.WriteFile(filepath.Join(s.reportsDir(), name), data, 0644); err != nil {
+		return nil, errors.WriteOutputFailed(err)
+	}
+
+	return gin.H{
+		"file":           name,
+		"url":            s.downloadURL("file=" + name),
+		"total_messages": report.TotalMessages,
+		"active_chats":   report.ActiveChats,
+	}, nil
+}
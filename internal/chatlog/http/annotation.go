@@ -0,0 +1,140 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+// annotationItem 备注及对应的消息，消息已不在聊天记录中时 message 为空
+type annotationItem struct {
+	*model.Annotation
+	Message *messageDetail `json:"message,omitempty"`
+}
+
+// annotationBody 备注的请求体
+type annotationBody struct {
+	Note string   `json:"note"`
+	Tags []string `json:"tags"`
+}
+
+// withAnnotations 请求是否要求附带消息备注
+func withAnnotations(c *gin.Context) bool {
+	ok, _ := strconv.ParseBool(c.Query("include_annotations"))
+	return ok
+}
+
+// annotate include_annotations=1 时为消息附加备注与标签，读取失败时只记录日志，不影响聊天记录的返回
+func (s *Service) annotate(c *gin.Context, messages []*model.Message) {
+	if !withAnnotations(c) {
+		return
+	}
+	ctx := c.Request.Context()
+	if err := s.db.Sidecar().Annotate(ctx, s.db.AccountName(ctx), messages); err != nil {
+		log.Err(err).Msg("failed to load annotations")
+	}
+}
+
+// ListAnnotations 按修改时间倒序列出消息备注，附带消息内容，messages=0 时只返回备注
+func (s *Service) ListAnnotations(c *gin.Context) {
+	q := struct {
+		Talker   string `form:"talker"`
+		Tag      string `form:"tag"`
+		Messages bool   `form:"messages,default=true"`
+		Limit    int    `form:"limit"`
+		Offset   int    `form:"offset"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if q.Limit < 0 {
+		q.Limit = 0
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+
+	ctx := c.Request.Context()
+	annotations, total, err := s.db.Sidecar().ListAnnotations(ctx, s.db.AccountName(ctx), q.Talker, q.Tag, q.Limit, q.Offset)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	items := make([]*annotationItem, 0, len(annotations))
+	for _, a := range annotations {
+		item := &annotationItem{Annotation: a}
+		if q.Messages {
+			item.Message = s.findMessage(c, a.Talker, a.Seq)
+		}
+		items = append(items, item)
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "total": total})
+}
+
+// GetAnnotation 查询消息的备注及对应的消息
+func (s *Service) GetAnnotation(c *gin.Context) {
+	talker, seq, ok := messageParams(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	a, err := s.db.Sidecar().GetAnnotation(ctx, s.db.AccountName(ctx), talker, seq)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &annotationItem{Annotation: a, Message: s.findMessage(c, talker, seq)})
+}
+
+// PutAnnotation 设置消息的备注与标签，覆盖已有的内容，消息须存在于聊天记录中；备注与标签都为空时删除
+// 请求体：{"note": "备注", "tags": ["待办", "工作"]}
+func (s *Service) PutAnnotation(c *gin.Context) {
+	talker, seq, ok := messageParams(c)
+	if !ok {
+		return
+	}
+	var req annotationBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors.Err(c, errors.InvalidArg("body"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	message, err := s.db.GetMessage(ctx, talker, seq)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	a := &model.Annotation{Talker: message.Talker, Seq: message.Seq, Note: req.Note, Tags: req.Tags}
+	if err := s.db.Sidecar().SetAnnotation(ctx, s.db.AccountName(ctx), a); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if a.Note == "" && len(a.Tags) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+// DeleteAnnotation 删除消息的备注与标签
+func (s *Service) DeleteAnnotation(c *gin.Context) {
+	talker, seq, ok := messageParams(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	if err := s.db.Sidecar().DeleteAnnotation(ctx, s.db.AccountName(ctx), talker, seq); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
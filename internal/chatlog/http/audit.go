@@ -0,0 +1,329 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+const (
+	auditFile = "audit.log"
+
+	// maxAuditBody MCP 请求体最多记录的字节数
+	maxAuditBody = 4096
+)
+
+// auditEntry 一条访问审计记录
+type auditEntry struct {
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"requestId,omitempty"`
+	Client     string    `json:"client"`
+	User       string    `json:"user,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Query      string    `json:"query,omitempty"`
+	Body       string    `json:"body,omitempty"`
+	Status     int       `json:"status"`
+	Bytes      int       `json:"bytes"`
+	DurationMs int64     `json:"durationMs"`
+}
+
+// auditLog 以 JSON Lines 格式写入审计日志，超过大小后轮转为 audit.log.1、audit.log.2 ...
+type auditLog struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func newAuditLog(dir string, maxSizeMB, maxFiles int) (*auditLog, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	l := &auditLog{
+		dir:      dir,
+		maxSize:  int64(maxSizeMB) << 20,
+		maxFiles: maxFiles,
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *auditLog) open() error {
+	f, err := os.OpenFile(filepath.Join(l.dir, auditFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file, l.size = f, info.Size()
+	return nil
+}
+
+func (l *auditLog) write(e *auditEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(data)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+	return err
+}
+
+// rotate 依次重命名历史文件，删除超出保留数量的最旧文件
+func (l *auditLog) rotate() error {
+	l.file.Close()
+	os.Remove(l.path(l.maxFiles))
+	for i := l.maxFiles; i > 0; i-- {
+		os.Rename(l.path(i-1), l.path(i))
+	}
+	return l.open()
+}
+
+// path 第 i 个日志文件，0 为当前文件
+func (l *auditLog) path(i int) string {
+	if i == 0 {
+		return filepath.Join(l.dir, auditFile)
+	}
+	return filepath.Join(l.dir, fmt.Sprintf("%s.%d", auditFile, i))
+}
+
+// query 从新到旧返回满足条件的记录
+// 持有锁时只打开各日志文件，读取在锁外进行，不阻塞请求的审计写入；已打开的文件不受之后的轮转影响
+func (l *auditLog) query(match func(*auditEntry) bool, limit, offset int) ([]*auditEntry, error) {
+	files, err := l.openFiles()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	ret := make([]*auditEntry, 0)
+	for _, f := range files {
+		entries, err := readAuditFile(f)
+		if err != nil {
+			return nil, err
+		}
+		for j := len(entries) - 1; j >= 0; j-- {
+			if !match(entries[j]) {
+				continue
+			}
+			if offset > 0 {
+				offset--
+				continue
+			}
+			ret = append(ret, entries[j])
+			if limit > 0 && len(ret) >= limit {
+				return ret, nil
+			}
+		}
+	}
+	return ret, nil
+}
+
+// openFiles 从新到旧打开当前与历史日志文件
+func (l *auditLog) openFiles() ([]*os.File, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	files := make([]*os.File, 0, l.maxFiles+1)
+	for i := 0; i <= l.maxFiles; i++ {
+		f, err := os.Open(l.path(i))
+		if err != nil {
+			if os.IsNotExist(err) {
+				break
+			}
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func readAuditFile(f *os.File) ([]*auditEntry, error) {
+	entries := make([]*auditEntry, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		entries = append(entries, &e)
+	}
+	return entries, scanner.Err()
+}
+
+// initAudit 按配置打开审计日志，未启用或打开失败时不记录
+func (s *Service) initAudit() {
+	conf := s.ctx.HTTP.Audit
+	if !conf.Enabled {
+		return
+	}
+	dir := conf.Dir
+	if dir == "" {
+		dir = filepath.Join(s.ctx.ConfigDir, "audit")
+	}
+	audit, err := newAuditLog(dir, conf.MaxSize, conf.MaxFiles)
+	if err != nil {
+		log.Err(err).Msg("failed to open audit log")
+		return
+	}
+	s.audit = audit
+}
+
+// audited 页面与静态资源不记录
+func audited(path string) bool {
+	return !isPage(path)
+}
+
+// isPage 页面与静态资源
+func isPage(path string) bool {
+	switch path {
+	case "/", "/favicon.ico", "/swagger", "/login":
+		return true
+	}
+	return strings.HasPrefix(path, "/static/")
+}
+
+// auditMiddleware 记录每个接口请求，MCP 请求额外记录请求体以便查看调用的工具与参数
+func (s *Service) auditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if s.audit == nil || !audited(path) {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		var body string
+		if c.Request.Method == http.MethodPost && (path == "/messages" || path == "/message") {
+			data, _ := io.ReadAll(io.LimitReader(c.Request.Body, maxAuditBody))
+			c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), c.Request.Body))
+			body = string(data)
+		}
+
+		c.Next()
+
+		entry := &auditEntry{
+			Time:       start,
+			RequestID:  c.GetString("RequestID"),
+			Client:     c.ClientIP(),
+			User:       c.GetString("User"),
+			Method:     c.Request.Method,
+			Path:       path,
+			Query:      c.Request.URL.RawQuery,
+			Body:       body,
+			Status:     c.Writer.Status(),
+			Bytes:      max(c.Writer.Size(), 0),
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if err := s.audit.write(entry); err != nil {
+			log.Debug().Err(err).Msg("failed to write audit log")
+		}
+	}
+}
+
+// adminMiddleware 管理接口只允许已登录的用户、携带客户端证书的请求或本机访问，未启用登录时局域网客户端不能查看
+func (s *Service) adminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("User") != "" || hasClientCert(c.Request) || fromUnixSocket(c.Request) || isLoopback(c.ClientIP()) {
+			c.Next()
+			return
+		}
+		errors.Err(c, errors.New(nil, http.StatusForbidden, "admin endpoints require login or local access"))
+		c.Abort()
+	}
+}
+
+func isLoopback(ip string) bool {
+	addr := net.ParseIP(ip)
+	return addr != nil && addr.IsLoopback()
+}
+
+// GetAudit 查询审计日志，按时间倒序返回
+func (s *Service) GetAudit(c *gin.Context) {
+	q := struct {
+		Time   string `form:"time"`
+		Path   string `form:"path"`
+		Client string `form:"client"`
+		User   string `form:"user"`
+		Limit  int    `form:"limit"`
+		Offset int    `form:"offset"`
+	}{}
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if s.audit == nil {
+		errors.Err(c, errors.New(nil, http.StatusNotFound, "audit log is disabled"))
+		return
+	}
+
+	var start, end time.Time
+	if q.Time != "" {
+		var ok bool
+		if start, end, ok = util.TimeRangeOf(q.Time); !ok {
+			errors.Err(c, errors.InvalidArg("time"))
+			return
+		}
+	}
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+
+	items, err := s.audit.query(func(e *auditEntry) bool {
+		if !start.IsZero() && (e.Time.Before(start) || e.Time.After(end)) {
+			return false
+		}
+		return strings.HasPrefix(e.Path, q.Path) &&
+			(q.Client == "" || e.Client == q.Client) &&
+			(q.User == "" || e.User == q.User)
+	}, q.Limit, q.Offset)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
@@ -0,0 +1,300 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/sjzar/chatlog/internal/chatlog/conf"
+)
+
+// sessionCookie 登录会话 Cookie 名称
+const sessionCookie = "chatlog_session"
+
+// authenticator 校验登录凭据与会话 Cookie，并限制同一 IP 的连续失败次数
+type authenticator struct {
+	conf   conf.AuthConfig
+	secret []byte
+
+	mu    sync.Mutex
+	fails map[string]*loginFails
+}
+
+type loginFails struct {
+	count       int
+	lastFail    time.Time
+	lockedUntil time.Time
+}
+
+// expired 距最后一次失败超过锁定时长且已解除锁定时，失败记录不再计入
+func (f *loginFails) expired(lockTime time.Duration, now time.Time) bool {
+	return now.After(f.lockedUntil) && now.Sub(f.lastFail) > lockTime
+}
+
+func newAuthenticator(c conf.AuthConfig) *authenticator {
+	a := &authenticator{
+		conf:  c,
+		fails: make(map[string]*loginFails),
+	}
+	if c.Secret != "" {
+		a.secret = []byte(c.Secret)
+	} else {
+		a.secret = make([]byte, 32)
+		if _, err := rand.Read(a.secret); err != nil {
+			log.Err(err).Msg("generate session secret failed")
+		}
+	}
+	return a
+}
+
+// update 应用重新加载的登录配置，secret 未变化时已登录的会话继续有效，清空 secret 时沿用当前的随机密钥
+func (a *authenticator) update(c conf.AuthConfig) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c.Secret != "" && c.Secret != a.conf.Secret {
+		a.secret = []byte(c.Secret)
+	}
+	a.conf = c
+}
+
+// config 当前的登录配置与签名密钥，配置重新加载时整体替换
+func (a *authenticator) config() (conf.AuthConfig, []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.conf, a.secret
+}
+
+// enabled 配置了密码时启用登录
+func (a *authenticator) enabled() bool {
+	c, _ := a.config()
+	return c.Password != ""
+}
+
+// username 登录用户名
+func (a *authenticator) username() string {
+	c, _ := a.config()
+	return c.Username
+}
+
+// check 校验用户名与密码
+func (a *authenticator) check(username, password string) bool {
+	c, _ := a.config()
+	userOK := subtle.ConstantTimeCompare([]byte(username), []byte(c.Username)) == 1
+	passOK := checkPassword(c.Password, password)
+	return userOK && passOK
+}
+
+// checkPassword 校验密码，expected 为明文或 bcrypt 哈希
+func checkPassword(expected, password string) bool {
+	if strings.HasPrefix(expected, "$2") {
+		return bcrypt.CompareHashAndPassword([]byte(expected), []byte(password)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(password), []byte(expected)) == 1
+}
+
+// locked 返回 IP 剩余的锁定时长，未锁定时为 0
+func (a *authenticator) locked(ip string) time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, ok := a.fails[ip]
+	if !ok {
+		return 0
+	}
+	return max(time.Until(f.lockedUntil).Round(time.Second), 0)
+}
+
+// fail 记录一次失败，达到上限后锁定该 IP
+// 同时清理已过期的失败记录，避免来自大量 IP 的尝试使记录无限增长
+func (a *authenticator) fail(ip string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	lockTime := time.Duration(a.conf.LockTime) * time.Second
+	for k, f := range a.fails {
+		if f.expired(lockTime, now) {
+			delete(a.fails, k)
+		}
+	}
+	f, ok := a.fails[ip]
+	if !ok {
+		f = &loginFails{}
+		a.fails[ip] = f
+	}
+	f.count++
+	f.lastFail = now
+	if f.count >= a.conf.MaxFails {
+		f.count = 0
+		f.lockedUntil = now.Add(lockTime)
+		log.Warn().Msgf("too many failed logins from %s, locked for %ds", ip, a.conf.LockTime)
+	}
+}
+
+func (a *authenticator) succeed(ip string) {
+	a.mu.Lock()
+	delete(a.fails, ip)
+	a.mu.Unlock()
+}
+
+// token 生成会话令牌，格式为 base64(用户名|过期时间).签名
+func (a *authenticator) token(username string, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(username + "|" + strconv.FormatInt(expires.Unix(), 10)))
+	return payload + "." + a.sign(payload)
+}
+
+// verify 校验会话令牌的签名与有效期
+func (a *authenticator) verify(token string) bool {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(a.sign(payload))) {
+		return false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return false
+	}
+	username, exp, ok := strings.Cut(string(data), "|")
+	if !ok || username != a.username() {
+		return false
+	}
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	return err == nil && time.Now().Unix() < expires
+}
+
+func (a *authenticator) sign(payload string) string {
+	_, secret := a.config()
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// authMiddleware 启用登录后，除登录页外的请求需要有效的会话 Cookie 或 HTTP Basic 认证
+// 浏览器访问页面时跳转到登录页，其余请求返回 401
+func (s *Service) authMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.auth.enabled() {
+			c.Next()
+			return
+		}
+		switch c.Request.URL.Path {
+		case "/login", "/favicon.ico":
+			c.Next()
+			return
+		}
+
+		if cookie, err := c.Cookie(sessionCookie); err == nil && s.auth.verify(cookie) {
+			c.Set("User", s.auth.username())
+			c.Next()
+			return
+		}
+
+		// 已通过客户端证书认证的请求无需再登录
+		if hasClientCert(c.Request) {
+			c.Set("User", c.Request.TLS.VerifiedChains[0][0].Subject.CommonName)
+			c.Next()
+			return
+		}
+
+		// 脚本与 MCP 客户端可使用 HTTP Basic 认证
+		if username, password, ok := c.Request.BasicAuth(); ok {
+			ip := c.ClientIP()
+			if d := s.auth.locked(ip); d > 0 {
+				c.Header("Retry-After", strconv.Itoa(int(d.Seconds())))
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many failed logins"})
+				return
+			}
+			if s.auth.check(username, password) {
+				s.auth.succeed(ip)
+				c.Set("User", username)
+				c.Next()
+				return
+			}
+			s.auth.fail(ip)
+		}
+
+		if c.Request.Method == http.MethodGet && strings.Contains(c.GetHeader("Accept"), "text/html") {
+			base := basePath(c.Request.Context())
+			c.Redirect(http.StatusFound, base+"/login?next="+url.QueryEscape(base+c.Request.URL.RequestURI()))
+			c.Abort()
+			return
+		}
+		c.Header("WWW-Authenticate", `Basic realm="chatlog"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	}
+}
+
+// Login 校验用户名与密码并写入会话 Cookie，支持表单与 JSON 请求
+func (s *Service) Login(c *gin.Context) {
+	var req struct {
+		Username string `form:"username" json:"username"`
+		Password string `form:"password" json:"password"`
+		Next     string `form:"next" json:"next"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	if !s.auth.enabled() {
+		c.JSON(http.StatusOK, gin.H{"next": safeNext(req.Next, basePath(c.Request.Context()))})
+		return
+	}
+
+	ip := c.ClientIP()
+	if d := s.auth.locked(ip); d > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(d.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed logins, retry after " + d.String()})
+		return
+	}
+	if !s.auth.check(req.Username, req.Password) {
+		s.auth.fail(ip)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
+		return
+	}
+	s.auth.succeed(ip)
+
+	authConf, _ := s.auth.config()
+	ttl := time.Duration(authConf.SessionTTL) * time.Second
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    s.auth.token(req.Username, time.Now().Add(ttl)),
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	c.JSON(http.StatusOK, gin.H{"next": safeNext(req.Next, basePath(c.Request.Context()))})
+}
+
+// Logout 清除会话 Cookie 并返回登录页，只接受 POST，避免被其他页面的链接或图片触发
+func (s *Service) Logout(c *gin.Context) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	c.Redirect(http.StatusFound, basePath(c.Request.Context())+"/login")
+}
+
+// safeNext 登录后的跳转地址，只允许站内路径，不合法时返回首页
+func safeNext(next string, base string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return base + "/"
+	}
+	return next
+}
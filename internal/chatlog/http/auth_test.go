@@ -0,0 +1,124 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/chatlog/conf"
+)
+
+func newTestAuth() *authenticator {
+	return newAuthenticator(conf.AuthConfig{
+		Username:   "admin",
+		Password:   "secret",
+		Secret:     "test-secret",
+		SessionTTL: 3600,
+		MaxFails:   3,
+		LockTime:   60,
+	})
+}
+
+func login(s *Service, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.RemoteAddr = "192.0.2.1:1234"
+	s.Login(c)
+	return w
+}
+
+func TestLogin(t *testing.T) {
+	s := &Service{auth: newTestAuth()}
+
+	w := login(s, `{"username": "admin", "password": "wrong"}`)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Login(wrong password) = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	w = login(s, `{"username": "admin", "password": "secret", "next": "//evil.example"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Login() = %d, want %d", w.Code, http.StatusOK)
+	}
+	if !strings.Contains(w.Body.String(), `"next":"/"`) {
+		t.Errorf("Login() body = %s, want next /", w.Body.String())
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookie || !cookies[0].HttpOnly {
+		t.Fatalf("Login() cookies = %v, want one HttpOnly session cookie", cookies)
+	}
+	if !s.auth.verify(cookies[0].Value) {
+		t.Errorf("verify(session cookie) = false, want true")
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	s := &Service{auth: newTestAuth()}
+
+	for i := 0; i < 3; i++ {
+		if w := login(s, `{"username": "admin", "password": "wrong"}`); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Login(attempt %d) = %d, want %d", i+1, w.Code, http.StatusUnauthorized)
+		}
+	}
+
+	// 锁定期间正确的密码也被拒绝
+	w := login(s, `{"username": "admin", "password": "secret"}`)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Login(locked) = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Errorf("Login(locked) missing Retry-After")
+	}
+}
+
+func TestLoginFailsExpire(t *testing.T) {
+	a := newTestAuth()
+	a.fail("192.0.2.1")
+	a.fail("192.0.2.2")
+
+	// 超过锁定时长的失败记录在下次失败时清理
+	a.mu.Lock()
+	a.fails["192.0.2.1"].lastFail = time.Now().Add(-2 * time.Minute)
+	a.mu.Unlock()
+	a.fail("192.0.2.3")
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.fails["192.0.2.1"]; ok {
+		t.Errorf("expired fails of 192.0.2.1 not removed")
+	}
+	if f, ok := a.fails["192.0.2.2"]; !ok || f.count != 1 {
+		t.Errorf("fails of 192.0.2.2 = %+v, want count 1", f)
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	a := newTestAuth()
+
+	if token := a.token("admin", time.Now().Add(time.Hour)); !a.verify(token) {
+		t.Errorf("verify(valid token) = false, want true")
+	}
+	if token := a.token("admin", time.Now().Add(-time.Second)); a.verify(token) {
+		t.Errorf("verify(expired token) = true, want false")
+	}
+	if token := a.token("other", time.Now().Add(time.Hour)); a.verify(token) {
+		t.Errorf("verify(token of other user) = true, want false")
+	}
+
+	token := a.token("admin", time.Now().Add(time.Hour))
+	if a.verify(token + "x") {
+		t.Errorf("verify(tampered token) = true, want false")
+	}
+
+	// 修改 secret 后已签发的令牌失效
+	a.update(conf.AuthConfig{Username: "admin", Password: "secret", Secret: "rotated", MaxFails: 3, LockTime: 60})
+	if a.verify(token) {
+		t.Errorf("verify(token after secret change) = true, want false")
+	}
+}
@@ -0,0 +1,47 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type basePathKey struct{}
+
+// basePathHandler 去掉 http.base_path 前缀后交给下一个处理器，并在请求上下文中记录前缀，生成的地址据此加上前缀
+// 访问前缀本身时跳转到以 / 结尾的地址，使页面中的相对地址落在前缀之下
+// 不带前缀的请求照常处理，兼容转发前已去掉前缀的反向代理配置
+func basePathHandler(base string, next http.Handler) http.Handler {
+	if base == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == base {
+			target := base + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), basePathKey{}, base))
+		if rest, ok := strings.CutPrefix(r.URL.Path, base+"/"); ok {
+			u := *r.URL
+			u.Path = "/" + rest
+			u.RawPath = ""
+			r.URL = &u
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// basePath 请求上下文中的路径前缀，未配置时为空
+func basePath(ctx context.Context) string {
+	base, _ := ctx.Value(basePathKey{}).(string)
+	return base
+}
+
+// downloadURL 报告下载地址，带上配置的路径前缀
+func (s *Service) downloadURL(query string) string {
+	return s.ctx.HTTP.Prefix() + "/api/v1/analysis/download?" + query
+}
@@ -0,0 +1,112 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// maxBatchSize 单次批量请求最多包含的子请求数
+const maxBatchSize = 50
+
+// batchRequest 批量请求中的子请求，只支持 /api/v1 下的 GET 接口
+type batchRequest struct {
+	ID    string            `json:"id"`
+	Path  string            `json:"path"`
+	Query map[string]string `json:"query"`
+}
+
+// batchResponse 子请求的执行结果，JSON 响应原样嵌入 body，其余内容以字符串返回
+type batchResponse struct {
+	ID     string      `json:"id"`
+	Status int         `json:"status"`
+	Body   interface{} `json:"body"`
+}
+
+// Batch 在一次请求中依次执行多个查询，减少仪表盘与 MCP 编排时的往返次数
+func (s *Service) Batch(c *gin.Context) {
+	var req struct {
+		Requests []batchRequest `json:"requests"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors.Err(c, errors.InvalidArg("requests"))
+		return
+	}
+	if len(req.Requests) == 0 || len(req.Requests) > maxBatchSize {
+		errors.Err(c, errors.InvalidArg("requests"))
+		return
+	}
+
+	responses := make([]batchResponse, 0, len(req.Requests))
+	for _, sub := range req.Requests {
+		responses = append(responses, s.execBatch(c, sub))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": responses})
+}
+
+func (s *Service) execBatch(c *gin.Context, sub batchRequest) batchResponse {
+	resp := batchResponse{ID: sub.ID}
+
+	_path, rawQuery, _ := strings.Cut(sub.Path, "?")
+	_path = path.Clean("/" + _path)
+	if base := basePath(c.Request.Context()); base != "" {
+		if rest, ok := strings.CutPrefix(_path, base+"/"); ok {
+			_path = "/" + rest
+		}
+	}
+	if !strings.HasPrefix(_path, "/api/v1/") {
+		_path = path.Join("/api/v1", _path)
+	}
+	if _path == "/api/v1/batch" {
+		resp.Status = http.StatusBadRequest
+		resp.Body = "nested batch is not allowed"
+		return resp
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Body = err.Error()
+		return resp
+	}
+	for k, v := range sub.Query {
+		query.Set(k, v)
+	}
+	// 子请求结果统一为 JSON，便于嵌入响应
+	if query.Get("format") == "" {
+		query.Set("format", "json")
+	}
+
+	r, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, _path+"?"+query.Encode(), nil)
+	if err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Body = err.Error()
+		return resp
+	}
+	r.Host = c.Request.Host
+	r.TLS = c.Request.TLS
+	r.RemoteAddr = c.Request.RemoteAddr
+	r.Header = c.Request.Header.Clone()
+	r.Header.Del("Content-Type")
+	r.Header.Del("Content-Length")
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, r)
+
+	resp.Status = w.Code
+	body := w.Body.Bytes()
+	if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") && json.Valid(body) {
+		resp.Body = json.RawMessage(body)
+	} else {
+		resp.Body = string(body)
+	}
+	return resp
+}
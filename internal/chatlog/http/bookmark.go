@@ -0,0 +1,152 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/chatlog/sidecar"
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// bookmarkItem 收藏及对应的消息，消息已不在聊天记录中时 message 为空
+type bookmarkItem struct {
+	*sidecar.Bookmark
+	Message *messageDetail `json:"message,omitempty"`
+}
+
+// ListBookmarks 按收藏时间倒序列出收藏的消息，附带消息内容，messages=0 时只返回收藏
+func (s *Service) ListBookmarks(c *gin.Context) {
+	q := struct {
+		Talker   string `form:"talker"`
+		Messages bool   `form:"messages,default=true"`
+		Limit    int    `form:"limit"`
+		Offset   int    `form:"offset"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if q.Limit < 0 {
+		q.Limit = 0
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+
+	ctx := c.Request.Context()
+	bookmarks, total, err := s.db.Sidecar().ListBookmarks(ctx, s.db.AccountName(ctx), q.Talker, q.Limit, q.Offset)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	items := make([]*bookmarkItem, 0, len(bookmarks))
+	for _, b := range bookmarks {
+		item := &bookmarkItem{Bookmark: b}
+		if q.Messages {
+			item.Message = s.findMessage(c, b.Talker, b.Seq)
+		}
+		items = append(items, item)
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "total": total})
+}
+
+// GetBookmark 查询一条收藏及对应的消息
+func (s *Service) GetBookmark(c *gin.Context) {
+	talker, seq, ok := messageParams(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	b, err := s.db.Sidecar().GetBookmark(ctx, s.db.AccountName(ctx), talker, seq)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &bookmarkItem{Bookmark: b, Message: s.findMessage(c, talker, seq)})
+}
+
+// CreateBookmark 收藏消息，消息须存在于聊天记录中，已收藏时更新备注
+// 请求体：{"talker": "wxid_xxx", "seq": 1700000000001, "note": "备注"}
+func (s *Service) CreateBookmark(c *gin.Context) {
+	var req struct {
+		Talker string `json:"talker"`
+		Seq    int64  `json:"seq"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors.Err(c, errors.InvalidArg("body"))
+		return
+	}
+	if req.Talker == "" {
+		errors.Err(c, errors.ErrTalkerEmpty)
+		return
+	}
+	if req.Seq <= 0 {
+		errors.Err(c, errors.InvalidArg("seq"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	message, err := s.db.GetMessage(ctx, req.Talker, req.Seq)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	b := &sidecar.Bookmark{Talker: message.Talker, Seq: message.Seq, Note: req.Note}
+	if err := s.db.Sidecar().AddBookmark(ctx, s.db.AccountName(ctx), b); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	b, err = s.db.Sidecar().GetBookmark(ctx, s.db.AccountName(ctx), b.Talker, b.Seq)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, b)
+}
+
+// UpdateBookmark 修改收藏的备注
+// 请求体：{"note": "备注"}
+func (s *Service) UpdateBookmark(c *gin.Context) {
+	talker, seq, ok := messageParams(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Note string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors.Err(c, errors.InvalidArg("body"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	store, account := s.db.Sidecar(), s.db.AccountName(ctx)
+	if err := store.UpdateBookmark(ctx, account, talker, seq, req.Note); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	b, err := store.GetBookmark(ctx, account, talker, seq)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+// DeleteBookmark 取消收藏
+func (s *Service) DeleteBookmark(c *gin.Context) {
+	talker, seq, ok := messageParams(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	if err := s.db.Sidecar().DeleteBookmark(ctx, s.db.AccountName(ctx), talker, seq); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,289 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxCacheEntries 内存与磁盘缓存的条目上限，超过后清理过期条目，仍超出时丢弃最早的条目
+	maxCacheEntries = 1000
+	// diskPruneInterval 每写入若干条磁盘缓存后清理一次缓存目录
+	diskPruneInterval = 100
+)
+
+// cacheEntry 缓存的响应内容
+type cacheEntry struct {
+	ContentType string    `json:"content_type"`
+	Body        []byte    `json:"body"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// responseCache 分析接口的响应缓存，以请求路径 + 参数为键
+// 内存缓存始终开启，配置 dir 后同时写入磁盘，服务重启后仍可命中
+type responseCache struct {
+	ttl     time.Duration
+	dir     string
+	entries map[string]*cacheEntry
+	writes  int
+	mu      sync.RWMutex
+}
+
+func newResponseCache(ttl time.Duration, dir string) *responseCache {
+	rc := &responseCache{
+		ttl:     ttl,
+		dir:     dir,
+		entries: make(map[string]*cacheEntry),
+	}
+	rc.pruneDisk()
+	return rc
+}
+
+// Middleware 缓存成功响应，请求参数 refresh=1 时跳过缓存并重新生成
+// scope 返回请求参数之外影响响应内容的状态，如查询的账号与是否脱敏，计入缓存键
+func (rc *responseCache) Middleware(scope func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ttl := rc.getTTL()
+		if ttl <= 0 || c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+
+		key := cacheKey(c.Request) + "#" + scope(c)
+		if c.Query("refresh") != "1" {
+			if e, ok := rc.get(key); ok {
+				c.Header("X-Cache", "HIT")
+				c.Data(http.StatusOK, e.ContentType, e.Body)
+				c.Abort()
+				return
+			}
+		}
+
+		c.Header("X-Cache", "MISS")
+		w := &cacheWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		if w.Status() == http.StatusOK {
+			rc.set(key, &cacheEntry{
+				ContentType: w.Header().Get("Content-Type"),
+				Body:        w.buf.Bytes(),
+				ExpiresAt:   time.Now().Add(ttl),
+			})
+		}
+	}
+}
+
+func (rc *responseCache) getTTL() time.Duration {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return rc.ttl
+}
+
+// setTTL 修改缓存有效期，已缓存的条目保持原有的过期时间
+func (rc *responseCache) setTTL(ttl time.Duration) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.ttl = ttl
+}
+
+func (rc *responseCache) get(key string) (*cacheEntry, bool) {
+	rc.mu.RLock()
+	e, ok := rc.entries[key]
+	rc.mu.RUnlock()
+
+	if !ok && rc.dir != "" {
+		data, err := os.ReadFile(rc.path(key))
+		if err == nil {
+			e = &cacheEntry{}
+			ok = json.Unmarshal(data, e) == nil
+		}
+	}
+	if !ok || time.Now().After(e.ExpiresAt) {
+		return nil, false
+	}
+	return e, true
+}
+
+func (rc *responseCache) set(key string, e *cacheEntry) {
+	rc.mu.Lock()
+	if len(rc.entries) >= maxCacheEntries {
+		rc.evict()
+	}
+	rc.entries[key] = e
+	rc.writes++
+	prune := rc.writes%diskPruneInterval == 0
+	rc.mu.Unlock()
+
+	if rc.dir == "" {
+		return
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	// 缓存内容包含聊天记录，仅当前用户可读
+	if err := os.MkdirAll(rc.dir, 0700); err != nil {
+		log.Debug().Err(err).Msg("create cache dir failed")
+		return
+	}
+	if err := os.WriteFile(rc.path(key), data, 0600); err != nil {
+		log.Debug().Err(err).Msg("write cache file failed")
+	}
+	if prune {
+		rc.pruneDisk()
+	}
+}
+
+// evict 清理内存中过期的条目，仍超出上限时丢弃最早过期的条目，调用方需持有写锁
+func (rc *responseCache) evict() {
+	now := time.Now()
+	keys := make([]string, 0, len(rc.entries))
+	for k, v := range rc.entries {
+		if now.After(v.ExpiresAt) {
+			delete(rc.entries, k)
+			continue
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) < maxCacheEntries {
+		return
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return rc.entries[keys[i]].ExpiresAt.Before(rc.entries[keys[j]].ExpiresAt)
+	})
+	for _, k := range keys[:len(keys)-maxCacheEntries+1] {
+		delete(rc.entries, k)
+	}
+}
+
+// pruneDisk 删除缓存目录中超过有效期的文件，文件数量仍超出上限时删除最早写入的文件
+func (rc *responseCache) pruneDisk() {
+	if rc.dir == "" {
+		return
+	}
+	ttl := rc.getTTL()
+	files, _ := filepath.Glob(filepath.Join(rc.dir, "*.json"))
+	type cacheFile struct {
+		path    string
+		modTime time.Time
+	}
+	remain := make([]cacheFile, 0, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if ttl <= 0 || time.Since(info.ModTime()) > ttl {
+			os.Remove(file)
+			continue
+		}
+		remain = append(remain, cacheFile{path: file, modTime: info.ModTime()})
+	}
+	if len(remain) <= maxCacheEntries {
+		return
+	}
+	sort.Slice(remain, func(i, j int) bool {
+		return remain[i].modTime.Before(remain[j].modTime)
+	})
+	for _, f := range remain[:len(remain)-maxCacheEntries] {
+		os.Remove(f.path)
+	}
+}
+
+// clear 清空内存与磁盘中的缓存
+func (rc *responseCache) clear() {
+	rc.mu.Lock()
+	rc.entries = make(map[string]*cacheEntry)
+	rc.mu.Unlock()
+
+	if rc.dir == "" {
+		return
+	}
+	files, _ := filepath.Glob(filepath.Join(rc.dir, "*.json"))
+	for _, file := range files {
+		os.Remove(file)
+	}
+}
+
+func (rc *responseCache) path(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(rc.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// cacheKey 由请求路径与排序后的参数（不含 refresh、envelope、fields）组成，
+// 通过 Accept-Language 指定的语言也计入其中
+func cacheKey(r *http.Request) string {
+	query := r.URL.Query()
+	query.Del("refresh")
+	query.Del("envelope")
+	query.Del("fields")
+	if lang := langOf(r); lang != "" {
+		query.Set("lang", lang)
+	}
+	return r.URL.Path + "?" + query.Encode()
+}
+
+// cacheScope 影响响应内容的账号与脱敏状态，未指定 account 时为当前账号
+func (s *Service) cacheScope(c *gin.Context) string {
+	account := c.Query("account")
+	if account == "" {
+		account = s.ctx.Account
+	}
+	return "account=" + account + "&redact=" + strconv.FormatBool(s.redactorOf(c) != nil)
+}
+
+// startCacheInvalidation 消息或会话数据库更新时清空缓存，避免返回更新前的统计结果
+func (s *Service) startCacheInvalidation() {
+	updates, cancel := s.db.Subscribe()
+	stop := make(chan struct{})
+	s.stopCache = func() {
+		cancel()
+		close(stop)
+	}
+	go func() {
+		for {
+			select {
+			case <-updates:
+				s.cache.clear()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *Service) stopCacheInvalidation() {
+	if s.stopCache != nil {
+		s.stopCache()
+		s.stopCache = nil
+	}
+}
+
+// cacheWriter 在写出响应的同时保留一份副本用于缓存
+type cacheWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *cacheWriter) Write(data []byte) (int, error) {
+	w.buf.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *cacheWriter) WriteString(s string) (int, error) {
+	w.buf.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
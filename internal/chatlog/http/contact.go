@@ -0,0 +1,275 @@
+package http
+
+import (
+	"context"
+
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// contactSubject 导出或清除的对象，联系人或群聊
+type contactSubject struct {
+	Talker   string          `json:"talker"`
+	Contact  *model.Contact  `json:"contact,omitempty"`
+	ChatRoom *model.ChatRoom `json:"chatRoom,omitempty"`
+}
+
+// findSubject 按 ID、微信号、备注或昵称查找联系人或群聊，匹配到多个时要求使用 ID
+// exact 为 true 时只接受完整的 wxid 或群聊 ID，用于清除等不可恢复的操作
+func (s *Service) findSubject(ctx context.Context, key string, exact bool) (*contactSubject, error) {
+	if key == "" {
+		return nil, errors.InvalidArg("key")
+	}
+	subject := &contactSubject{}
+	if contacts, err := s.db.GetContacts(ctx, key, 0, 0); err == nil && len(contacts.Items) > 0 {
+		for _, contact := range contacts.Items {
+			if contact.UserName == key {
+				subject.Contact = contact
+				break
+			}
+		}
+		if subject.Contact == nil && !exact {
+			if len(contacts.Items) > 1 {
+				return nil, errors.Newf(nil, http.StatusConflict, "multiple contacts match %s, use the wxid", key)
+			}
+			subject.Contact = contacts.Items[0]
+		}
+		if subject.Contact != nil {
+			subject.Talker = subject.Contact.UserName
+		}
+	}
+
+	room := key
+	if subject.Talker != "" {
+		room = subject.Talker
+	}
+	if subject.Talker == "" || strings.HasSuffix(subject.Talker, "@chatroom") {
+		if chatRooms, err := s.db.GetChatRooms(ctx, room, 0, 0); err == nil {
+			for _, chatRoom := range chatRooms.Items {
+				if chatRoom.Name == room || (!exact && len(chatRooms.Items) == 1) {
+					subject.ChatRoom = chatRoom
+					subject.Talker = chatRoom.Name
+					break
+				}
+			}
+		}
+	}
+
+	if subject.Talker == "" {
+		return nil, errors.ContactNotFound(key)
+	}
+	return subject, nil
+}
+
+// ExportContactData 将与一个联系人相关的全部数据打包为 ZIP：资料、聊天记录与图片、视频、语音、文件
+// groups=1 时同时导出该联系人在共同群聊中发送的消息，用于个人数据导出请求
+func (s *Service) ExportContactData(c *gin.Context) {
+	subject, err := s.findSubject(c.Request.Context(), c.Param("key"), false)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	start, end, _ := util.TimeRangeOf("all")
+
+	messages, err := s.db.GetMessages(c.Request.Context(), start, end, subject.Talker, "", "", "", false, 0, 0)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	// 联系人在共同群聊中发送的消息
+	groups := make(map[string][]*model.Message)
+	if subject.ChatRoom == nil && c.Query("groups") == "1" {
+		chatRooms, err := s.db.GetChatRooms(c.Request.Context(), "", 0, 0)
+		if err != nil {
+			errors.Err(c, err)
+			return
+		}
+		for _, room := range chatRooms.Items {
+			if !hasMember(room, subject.Talker) {
+				continue
+			}
+			list, err := s.db.GetMessages(c.Request.Context(), start, end, room.Name, subject.Talker, "", "", false, 0, 0)
+			if err != nil || len(list) == 0 {
+				continue
+			}
+			groups[room.Name] = list
+		}
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", subject.Talker+".zip"))
+	zw := zip.NewWriter(c.Writer)
+	if err := s.writeContactBundle(c.Request.Context(), zw, subject, messages, groups, hostOf(c)); err != nil {
+		log.Err(err).Msgf("export contact %s failed", subject.Talker)
+	}
+	if err := zw.Close(); err != nil {
+		log.Err(err).Msgf("export contact %s failed", subject.Talker)
+	}
+}
+
+// writeContactBundle 写出导出包，媒体文件按消息序号命名，找不到的文件跳过
+func (s *Service) writeContactBundle(ctx context.Context, zw *zip.Writer, subject *contactSubject, messages []*model.Message, groups map[string][]*model.Message, host string) error {
+	if err := writeZipJSON(zw, "contact.json", subject); err != nil {
+		return err
+	}
+	if err := writeZipJSON(zw, "messages.json", messages); err != nil {
+		return err
+	}
+	w, err := zw.Create("messages.txt")
+	if err != nil {
+		return err
+	}
+	for _, m := range messages {
+		if _, err := w.Write([]byte(m.PlainText(false, "2006-01-02 15:04:05", host))); err != nil {
+			return err
+		}
+	}
+	for room, list := range groups {
+		if err := writeZipJSON(zw, "groups/"+room+".json", list); err != nil {
+			return err
+		}
+	}
+
+	media := 0
+	written := make(map[string]bool)
+	for _, list := range append([][]*model.Message{messages}, mapValues(groups)...) {
+		for _, m := range list {
+			name, data := s.mediaContent(ctx, m)
+			if data == nil || written[name] {
+				continue
+			}
+			written[name] = true
+			w, err := zw.Create("media/" + name)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+			media++
+		}
+	}
+
+	return writeZipJSON(zw, "manifest.json", gin.H{
+		"talker":   subject.Talker,
+		"messages": len(messages),
+		"groups":   len(groups),
+		"media":    media,
+		"exported": time.Now().Format(time.RFC3339),
+	})
+}
+
+// mediaContent 多媒体消息对应的文件名与内容，图片解码为原始格式，语音转换为 MP3
+func (s *Service) mediaContent(ctx context.Context, m *model.Message) (string, []byte) {
+	prefix := fmt.Sprintf("%s_%d", m.Talker, m.Seq)
+	if m.Type == 34 {
+		_, keys := m.MediaKeys()
+		for _, key := range keys {
+			media, err := s.db.GetMedia(ctx, "voice", key)
+			if err != nil || len(media.Data) == 0 {
+				continue
+			}
+			if out, err := decodeVoice(ctx, media.Data); err == nil {
+				return prefix + ".mp3", out
+			}
+			return prefix + ".silk", media.Data
+		}
+		return "", nil
+	}
+
+	media := s.resolveMedia(ctx, m)
+	if media == nil {
+		return "", nil
+	}
+	path, err := s.dataPath(mediaCtx(ctx, media), media.Path)
+	if err != nil {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil
+	}
+	name := filepath.Base(path)
+	if strings.ToLower(filepath.Ext(path)) == ".dat" {
+		if out, ext, err := decodeImage(ctx, data); err == nil {
+			data, name = out, strings.TrimSuffix(name, filepath.Ext(name))+"."+ext
+		}
+	}
+	return prefix + "_" + name, data
+}
+
+// PurgeContactData 从解密后的工作目录中删除与联系人或群聊的聊天记录、联系人与最近会话记录，
+// 并删除该会话的收藏、消息备注、会话标签与显示名称
+// 只接受完整的 wxid 或群聊 ID；数据目录中的图片等原始文件不做修改，重新解密后数据会恢复；加密的工作目录不支持清除
+func (s *Service) PurgeContactData(c *gin.Context) {
+	subject, err := s.findSubject(c.Request.Context(), c.Param("key"), true)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	count, err := s.db.PurgeTalker(c.Request.Context(), subject.Talker)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	s.cache.clear()
+	s.resetRedactNames()
+	log.Info().Msgf("purged %s: %d messages", subject.Talker, count)
+
+	ret := gin.H{
+		"talker":   subject.Talker,
+		"messages": count,
+	}
+	// 附加数据保存在配置目录，清除失败时聊天记录已删除，在响应中说明原因
+	purged, err := s.db.Sidecar().PurgeTalker(c.Request.Context(), s.db.AccountName(c.Request.Context()), subject.Talker)
+	switch e, ok := err.(*errors.Error); {
+	case err == nil:
+		ret["sidecar"] = purged
+	case ok && e.Code == http.StatusServiceUnavailable:
+	default:
+		log.Warn().Err(err).Msgf("purge sidecar data of %s failed", subject.Talker)
+		ret["sidecarError"] = err.Error()
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+func hasMember(room *model.ChatRoom, userName string) bool {
+	for _, user := range room.Users {
+		if user.UserName == userName {
+			return true
+		}
+	}
+	return false
+}
+
+func mapValues(m map[string][]*model.Message) [][]*model.Message {
+	ret := make([][]*model.Message, 0, len(m))
+	for _, v := range m {
+		ret = append(ret, v)
+	}
+	return ret
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
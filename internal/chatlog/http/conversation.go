@@ -0,0 +1,202 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// maxConversationSize 会话视图单页最多返回的消息数
+const maxConversationSize = 1000
+
+// participant 会话中的联系人或群聊
+type participant struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Avatar     string `json:"avatar,omitempty"`
+	IsChatRoom bool   `json:"isChatRoom,omitempty"`
+}
+
+// bubble 按聊天气泡渲染所需的单条消息
+type bubble struct {
+	Seq        int64                  `json:"seq"`
+	Time       time.Time              `json:"time"`
+	Kind       string                 `json:"kind"`
+	Type       int64                  `json:"type"`
+	SubType    int64                  `json:"subType"`
+	Sender     string                 `json:"sender"`
+	SenderName string                 `json:"senderName"`
+	Avatar     string                 `json:"avatar,omitempty"`
+	IsSelf     bool                   `json:"isSelf"`
+	Text       string                 `json:"text"`
+	MediaURL   string                 `json:"mediaUrl,omitempty"`
+	ThumbURL   string                 `json:"thumbUrl,omitempty"`
+	Contents   map[string]interface{} `json:"contents,omitempty"`
+	Annotation *model.Annotation      `json:"annotation,omitempty"` // include_annotations=1 时返回
+}
+
+// conversation 会话视图
+type conversation struct {
+	Talker *participant `json:"talker"`
+	Items  []*bubble    `json:"items"`
+	Limit  int          `json:"limit"`
+	Offset int          `json:"offset"`
+	More   bool         `json:"more"` // 是否还有下一页
+}
+
+// GetConversation 会话视图，返回已关联发送人名称、头像、解析后文本与多媒体地址的消息，
+// 页面无需再分别请求聊天记录、联系人与多媒体接口
+func (s *Service) GetConversation(c *gin.Context) {
+	q := struct {
+		Talker string `form:"talker"`
+		Time   string `form:"time"`
+		Order  string `form:"order"`
+		Limit  int    `form:"limit,default=100"`
+		Offset int    `form:"offset"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if q.Talker == "" || strings.Contains(q.Talker, ",") {
+		errors.Err(c, errors.ErrTalkerEmpty)
+		return
+	}
+	start, end, ok := util.TimeRangeOf(q.Time)
+	if !ok {
+		errors.Err(c, errors.InvalidArg("time"))
+		return
+	}
+	if q.Limit <= 0 || q.Limit > maxConversationSize {
+		errors.Err(c, errors.InvalidArg("limit"))
+		return
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+	var desc bool
+	switch strings.ToLower(q.Order) {
+	case "", "asc":
+	case "desc":
+		desc = true
+	default:
+		errors.Err(c, errors.InvalidArg("order"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	messages, err := s.db.GetMessages(ctx, start, end, q.Talker, "", "", "", desc, q.Limit, q.Offset)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	s.annotate(c, messages)
+	talker := s.participant(ctx, q.Talker)
+	avatars := make(map[string]string)
+	setLang(messages, langOf(c.Request))
+	prefix, host := mediaPrefix(c), hostOf(c)
+	items := make([]*bubble, 0, len(messages))
+	for _, m := range messages {
+		if talker.Name == q.Talker && m.TalkerName != "" {
+			talker.Name = m.TalkerName
+		}
+		avatar, ok := avatars[m.Sender]
+		if !ok && m.Sender != "" {
+			avatar = s.participant(ctx, m.Sender).Avatar
+			avatars[m.Sender] = avatar
+		}
+
+		m.SetContent("host", host)
+		m.SetMediaURLs(prefix)
+		items = append(items, &bubble{
+			Seq:        m.Seq,
+			Time:       m.Time,
+			Kind:       bubbleKind(m),
+			Type:       m.Type,
+			SubType:    m.SubType,
+			Sender:     m.Sender,
+			SenderName: m.SenderName,
+			Avatar:     avatar,
+			IsSelf:     m.IsSelf,
+			Text:       m.PlainTextContent(),
+			MediaURL:   m.MediaURL,
+			ThumbURL:   m.ThumbURL,
+			Contents:   m.Contents,
+			Annotation: m.Annotation,
+		})
+	}
+
+	c.JSON(http.StatusOK, &conversation{
+		Talker: talker,
+		Items:  items,
+		Limit:  q.Limit,
+		Offset: q.Offset,
+		More:   len(messages) == q.Limit,
+	})
+}
+
+// participant 按 ID 查找联系人或群聊的名称与头像，找不到时名称为 ID
+func (s *Service) participant(ctx context.Context, id string) *participant {
+	p := &participant{ID: id, Name: id, IsChatRoom: strings.HasSuffix(id, "@chatroom")}
+	if contacts, err := s.db.GetContacts(ctx, id, 0, 0); err == nil {
+		for _, contact := range contacts.Items {
+			if contact.UserName != id {
+				continue
+			}
+			if name := contact.DisplayName(); name != "" {
+				p.Name = name
+			}
+			p.Avatar = contact.Avatar
+			return p
+		}
+	}
+	if p.IsChatRoom {
+		if chatRooms, err := s.db.GetChatRooms(ctx, id, 0, 0); err == nil && len(chatRooms.Items) == 1 {
+			if name := chatRooms.Items[0].DisplayName(); name != "" {
+				p.Name = name
+			}
+		}
+	}
+	return p
+}
+
+// bubbleKind 气泡样式，取 model.MessageTypeNames 中最具体的名称，未知类型为 other
+func bubbleKind(m *model.Message) string {
+	switch m.Type {
+	case 1:
+		return "text"
+	case 3:
+		return "image"
+	case 34:
+		return "voice"
+	case 42:
+		return "card"
+	case 43:
+		return "video"
+	case 47:
+		return "emoji"
+	case 48:
+		return "location"
+	case 50:
+		return "voip"
+	case 10000, 10002:
+		return "system"
+	case 49:
+		for _, name := range []string{"link", "file", "emoji", "forward", "miniapp", "channels", "quote", "pat", "transfer"} {
+			if m.MatchTypes(model.MessageTypeNames[name]) {
+				return name
+			}
+		}
+		return "appmsg"
+	}
+	return "other"
+}
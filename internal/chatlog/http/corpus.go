@@ -0,0 +1,163 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/chatlog/analysis"
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/pkg/redact"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// defaultCorpusJitter 默认的时间偏移范围，单位为天
+const defaultCorpusJitter = 30
+
+// corpusExport 匿名语料导出参数
+type corpusExport struct {
+	start    time.Time
+	end      time.Time
+	sessions []string
+	salt     string
+	opts     analysis.CorpusOptions
+}
+
+// newCorpusExport 解析参数：time 默认为 all，talker 为空时导出所有会话，jitter 为时间偏移天数，media=0 时丢弃多媒体消息
+func (s *Service) newCorpusExport(ctx context.Context, params map[string]string) (*corpusExport, error) {
+	_time := params["time"]
+	if _time == "" {
+		_time = "all"
+	}
+	start, end, ok := util.TimeRangeOf(_time)
+	if !ok {
+		return nil, errors.InvalidArg("time")
+	}
+
+	jitter := defaultCorpusJitter
+	if v := params["jitter"]; v != "" {
+		var err error
+		if jitter, err = strconv.Atoi(v); err != nil || jitter < 0 {
+			return nil, errors.InvalidArg("jitter")
+		}
+	}
+
+	sessions := util.Str2List(params["talker"], ",")
+	if len(sessions) == 0 {
+		resp, err := s.db.GetSessions(ctx, "", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range resp.Items {
+			sessions = append(sessions, session.UserName)
+		}
+	}
+
+	return &corpusExport{
+		start:    start,
+		end:      end,
+		sessions: sessions,
+		salt:     params["salt"],
+		opts: analysis.CorpusOptions{
+			Jitter: time.Duration(jitter) * 24 * time.Hour,
+			Media:  params["media"] != "0",
+		},
+	}, nil
+}
+
+// writeCorpus 逐个会话写出 JSON Lines，返回写出的消息数
+// 未指定 salt 时每次导出随机生成，不同导出之间的化名无法关联
+func (s *Service) writeCorpus(ctx context.Context, w io.Writer, e *corpusExport, progress func(int)) (int, error) {
+	r := redact.New(e.salt)
+	r.SetNames(s.redactNames(ctx, r))
+	builder := analysis.NewCorpusBuilder(r, e.opts)
+
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	total := 0
+	for i, talker := range e.sessions {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		messages, err := s.db.GetMessages(ctx, e.start, e.end, talker, "", "", "", false, 0, 0)
+		if err != nil {
+			// 单个会话查询失败（如会话无消息表）不影响整体导出
+			continue
+		}
+		for _, record := range builder.Records(talker, messages) {
+			if err := encoder.Encode(record); err != nil {
+				return total, err
+			}
+			total++
+		}
+		if err := bw.Flush(); err != nil {
+			return total, err
+		}
+		progress((i + 1) * 95 / len(e.sessions))
+	}
+	return total, bw.Flush()
+}
+
+// ExportCorpus 以 JSON Lines 格式导出匿名语料，用于 NLP 研究或模型微调
+// 会话与发言人替换为固定化名，正文脱敏并去除链接，多媒体替换为占位符，时间按会话整体随机偏移
+func (s *Service) ExportCorpus(c *gin.Context) {
+	params := map[string]string{
+		"time":   c.Query("time"),
+		"talker": c.Query("talker"),
+		"salt":   c.Query("salt"),
+		"jitter": c.Query("jitter"),
+		"media":  c.Query("media"),
+	}
+	e, err := s.newCorpusExport(c.Request.Context(), params)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename=corpus.jsonl")
+	if _, err := s.writeCorpus(c.Request.Context(), c.Writer, e, func(int) {}); err != nil {
+		log.Err(err).Msg("export corpus failed")
+	}
+}
+
+// generateCorpus 导出匿名语料到报告目录，用于数据量较大的后台任务
+func (s *Service) generateCorpus(ctx context.Context, params map[string]string, progress func(int)) (gin.H, error) {
+	e, err := s.newCorpusExport(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.reportsDir(), 0755); err != nil {
+		return nil, errors.WriteOutputFailed(err)
+	}
+	name := fmt.Sprintf("corpus_%s.jsonl", time.Now().Format("20060102_150405"))
+	path := filepath.Join(s.reportsDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, errors.WriteOutputFailed(err)
+	}
+	total, err := s.writeCorpus(ctx, f, e, progress)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	return gin.H{
+		"file":     name,
+		"url":      s.downloadURL("file=" + name),
+		"records":  total,
+		"sessions": len(e.sessions),
+	}, nil
+}
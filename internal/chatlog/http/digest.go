@@ -0,0 +1,185 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/chatlog/analysis"
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/pkg/i18n"
+	"github.com/sjzar/chatlog/pkg/mail"
+	"github.com/sjzar/chatlog/pkg/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// digestItem 单个会话的摘要
+type digestItem struct {
+	Talker        string
+	Name          string
+	MessageCount  int
+	ActiveMembers int
+	ActivityLevel string
+	Keywords      []analysis.KeywordStat
+	Quotes        []analysis.Quote
+}
+
+// digest 摘要邮件内容
+type digest struct {
+	Lang      string
+	Title     string
+	StartDate string
+	EndDate   string
+	Items     []digestItem
+}
+
+var digestHTMLTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{"t": i18n.T, "tf": i18n.Tf}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body style="font-family: -apple-system, 'Segoe UI', 'PingFang SC', 'Microsoft YaHei', sans-serif; margin: 24px; color: #333;">
+<h2>{{.Title}}</h2>
+<p style="color: #888;">{{.StartDate}} ~ {{.EndDate}}</p>
+{{range .Items}}<div style="border: 1px solid #eee; border-radius: 6px; padding: 12px 16px; margin-bottom: 16px;">
+<h3 style="margin: 0 0 8px;">{{.Name}}</h3>
+<p style="margin: 0 0 8px;">{{tf $.Lang "digest.stats" .MessageCount .ActiveMembers .ActivityLevel}}</p>
+{{if .Keywords}}<p style="margin: 0 0 8px;">{{t $.Lang "digest.keywords"}}{{range $i, $k := .Keywords}}{{if $i}}{{t $.Lang "digest.sep"}}{{end}}{{$k.Word}}{{end}}</p>{{end}}
+{{if .Quotes}}<p style="margin: 0 0 4px;">{{t $.Lang "digest.quotes"}}</p>
+<ul style="margin: 0; padding-left: 20px;">{{range .Quotes}}<li>{{.Content}}</li>{{end}}</ul>{{end}}
+</div>
+{{else}}<p>{{t $.Lang "digest.empty"}}</p>
+{{end}}
+</body>
+</html>
+`))
+
+// buildDigest 生成指定会话的摘要，多个会话以英文逗号分隔，lang 为空时使用中文
+func (s *Service) buildDigest(ctx context.Context, _time string, talker string, lang string) (*digest, error) {
+	if _time == "" {
+		_time = "yesterday"
+	}
+	start, end, ok := util.TimeRangeOf(_time)
+	if !ok {
+		return nil, errors.InvalidArg("time")
+	}
+	talkers := util.Str2List(talker, ",")
+	if len(talkers) == 0 {
+		return nil, errors.ErrTalkerEmpty
+	}
+
+	d := &digest{
+		Lang:      lang,
+		Title:     i18n.T(lang, "digest.title"),
+		StartDate: start.Format("2006-01-02"),
+		EndDate:   end.Format("2006-01-02"),
+		Items:     make([]digestItem, 0, len(talkers)),
+	}
+	if d.StartDate != d.EndDate {
+		d.Title += " " + d.StartDate + " ~ " + d.EndDate
+	} else {
+		d.Title += " " + d.StartDate
+	}
+
+	for _, t := range talkers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		messages, err := s.db.GetMessages(ctx, start, end, t, "", "", "", false, 0, 0)
+		if err != nil || len(messages) == 0 {
+			continue
+		}
+		m := analysis.Compute(messages, start, end, s.options())
+
+		texts := make([]string, 0)
+		for _, msg := range messages {
+			if msg.Type == 1 && msg.Content != "" && len(msg.Content) > 10 {
+				texts = append(texts, msg.Content)
+			}
+		}
+		quotes := analysis.GoldenQuotes(texts)
+		if len(quotes) > 5 {
+			quotes = quotes[:5]
+		}
+
+		name := messages[0].TalkerName
+		if name == "" {
+			name = t
+		}
+		days := int(end.Sub(start).Hours()/24) + 1
+		d.Items = append(d.Items, digestItem{
+			Talker:        t,
+			Name:          name,
+			MessageCount:  m.MessageCount,
+			ActiveMembers: m.ActiveMembers,
+			ActivityLevel: getActivityLevel(lang, m.TextCount/days),
+			Keywords:      m.TopKeywords,
+			Quotes:        quotes,
+		})
+	}
+
+	return d, nil
+}
+
+func (d *digest) HTML() (string, error) {
+	var buf bytes.Buffer
+	if err := digestHTMLTemplate.Execute(&buf, d); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// sendDigest 生成摘要并通过邮件发送
+func (s *Service) sendDigest(ctx context.Context, _time, talker, recipients string) (gin.H, error) {
+	to := util.Str2List(recipients, ",")
+	if len(to) == 0 {
+		return nil, errors.InvalidArg("recipients")
+	}
+
+	d, err := s.buildDigest(ctx, _time, talker, "")
+	if err != nil {
+		return nil, err
+	}
+	body, err := d.HTML()
+	if err != nil {
+		return nil, err
+	}
+
+	smtp := s.ctx.Settings().SMTP
+	smtpConf := mail.Config{
+		Host:     smtp.Host,
+		Port:     smtp.Port,
+		Username: smtp.Username,
+		Password: smtp.Password,
+		From:     smtp.From,
+	}
+	if err := mail.Send(smtpConf, &mail.Message{To: to, Subject: d.Title, HTML: body}); err != nil {
+		return nil, errors.New(err, http.StatusBadGateway, "send digest mail failed")
+	}
+
+	return gin.H{
+		"subject":    d.Title,
+		"recipients": to,
+		"chats":      len(d.Items),
+		"sent_at":    time.Now().Format("2006-01-02 15:04:05"),
+	}, nil
+}
+
+// GetDigest 预览摘要邮件内容（HTML）
+func (s *Service) GetDigest(c *gin.Context) {
+	d, err := s.buildDigest(c.Request.Context(), c.Query("time"), c.Query("talker"), langOf(c.Request))
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	body, err := d.HTML()
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(body))
+}
@@ -0,0 +1,185 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// envelope 统一的 JSON 响应结构
+type envelope struct {
+	Data       json.RawMessage `json:"data,omitempty"`
+	Pagination *pagination     `json:"pagination,omitempty"`
+	Error      *envelopeError  `json:"error,omitempty"`
+	RequestID  string          `json:"request_id"`
+}
+
+// pagination 列表接口的分页信息
+type pagination struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+	Count  int `json:"count"`
+}
+
+// envelopeError 错误信息，code 为稳定的错误类型，便于客户端判断
+type envelopeError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+// envelopeMiddleware 将 JSON 响应包装为统一结构，非 JSON 响应（文件、CSV、文本）保持不变
+// 默认行为由配置 http.envelope 决定，请求可通过 envelope=1 或 envelope=0 覆盖，兼容旧版客户端
+func (s *Service) envelopeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		enabled := s.ctx.Settings().Envelope
+		if v := c.Query("envelope"); v != "" {
+			enabled, _ = strconv.ParseBool(v)
+		}
+		if !enabled {
+			c.Next()
+			return
+		}
+
+		w := &jsonWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if !w.buffered {
+			return
+		}
+
+		env := &envelope{RequestID: c.GetString("RequestID")}
+		body := bytes.TrimSpace(w.buf.Bytes())
+		if status := w.Status(); status >= http.StatusBadRequest {
+			env.Error = &envelopeError{
+				Code:      errorCode(status),
+				Message:   errorMessage(body, status),
+				RequestID: env.RequestID,
+			}
+		} else {
+			if len(body) == 0 {
+				body = []byte("null")
+			}
+			env.Data = body
+			env.Pagination = paginationOf(c, body)
+		}
+
+		data, err := json.Marshal(env)
+		if err != nil {
+			w.ResponseWriter.Write(w.buf.Bytes())
+			return
+		}
+		w.ResponseWriter.Write(data)
+	}
+}
+
+// jsonWriter 缓存 JSON 响应体以便改写，其余内容直接写出
+type jsonWriter struct {
+	gin.ResponseWriter
+	buf      bytes.Buffer
+	decided  bool
+	buffered bool
+}
+
+func (w *jsonWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	w.buffered = strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+}
+
+func (w *jsonWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.buffered {
+		return w.buf.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *jsonWriter) WriteString(s string) (int, error) {
+	w.decide()
+	if w.buffered {
+		return w.buf.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// paginationOf 列表结果（数组或带 items 的对象）附带分页信息
+func paginationOf(c *gin.Context, body []byte) *pagination {
+	var count int
+	switch body[0] {
+	case '[':
+		var items []json.RawMessage
+		if json.Unmarshal(body, &items) != nil {
+			return nil
+		}
+		count = len(items)
+	case '{':
+		var obj struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		if json.Unmarshal(body, &obj) != nil || obj.Items == nil {
+			return nil
+		}
+		count = len(obj.Items)
+	default:
+		return nil
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	return &pagination{Limit: limit, Offset: offset, Count: count}
+}
+
+// errorMessage 兼容 errors.Err 输出的字符串与 {"error": ...}、{"message": ...} 两种对象
+func errorMessage(body []byte, status int) string {
+	var msg string
+	if json.Unmarshal(body, &msg) == nil && msg != "" {
+		return msg
+	}
+	var obj struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &obj) == nil {
+		if obj.Error != "" {
+			return obj.Error
+		}
+		if obj.Message != "" {
+			return obj.Message
+		}
+	}
+	return http.StatusText(status)
+}
+
+// errorCode HTTP 状态码对应的错误类型
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_argument"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	default:
+		if status >= http.StatusInternalServerError {
+			return "internal"
+		}
+		return "error"
+	}
+}
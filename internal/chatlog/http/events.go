@@ -0,0 +1,99 @@
+package http
+
+import (
+	"context"
+
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// sessionEvent 会话更新事件，只包含展示角标所需的信息
+type sessionEvent struct {
+	UserName string    `json:"userName"`
+	NickName string    `json:"nickName"`
+	Content  string    `json:"content"`
+	Time     time.Time `json:"time"`
+	New      bool      `json:"new"` // 是否为新出现的会话
+}
+
+// GetEvents 以 SSE 推送会话更新事件，数据库增量解密后比较会话的最后消息时间，
+// 对有新消息的会话发送 session 事件，页面据此显示新消息提示而无需轮询
+func (s *Service) GetEvents(c *gin.Context) {
+	updates, cancel := s.db.Subscribe()
+	defer cancel()
+
+	last, err := s.sessionTimes(c.Request.Context())
+	if err != nil {
+		log.Debug().Err(err).Msg("load sessions failed")
+		last = make(map[string]time.Time)
+	}
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.WriteString("event: ready\ndata: {}\n\n")
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(livePingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-s.closing:
+			return
+		case <-ticker.C:
+			c.Writer.WriteString(fmt.Sprintf(": ping - %s\n\n", time.Now().Format(time.RFC3339)))
+			c.Writer.Flush()
+		case <-updates:
+			// 数据库文件替换后稍作等待，确保新文件已可读取
+			time.Sleep(time.Second)
+			resp, err := s.db.GetSessions(c.Request.Context(), "", 0, 0)
+			if err != nil {
+				log.Debug().Err(err).Msg("load sessions failed")
+				continue
+			}
+			for _, session := range resp.Items {
+				t, ok := last[session.UserName]
+				if ok && !session.NTime.After(t) {
+					continue
+				}
+				last[session.UserName] = session.NTime
+				writeEvent(c, "session", &sessionEvent{
+					UserName: session.UserName,
+					NickName: session.NickName,
+					Content:  session.Content,
+					Time:     session.NTime,
+					New:      !ok,
+				})
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// sessionTimes 各会话最后一条消息的时间
+func (s *Service) sessionTimes(ctx context.Context) (map[string]time.Time, error) {
+	resp, err := s.db.GetSessions(ctx, "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]time.Time, len(resp.Items))
+	for _, session := range resp.Items {
+		ret[session.UserName] = session.NTime
+	}
+	return ret, nil
+}
+
+func writeEvent(c *gin.Context, event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	c.Writer.WriteString(fmt.Sprintf("event: %s\ndata: %s\n\n", event, data))
+}
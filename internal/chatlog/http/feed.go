@@ -0,0 +1,198 @@
+package http
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// maxFeedDays 摘要模式最多包含的天数
+const maxFeedDays = 31
+
+// atomFeed Atom 订阅源，见 RFC 4287
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Link    []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Link    *atomLink   `xml:"link,omitempty"`
+	Content atomContent `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// GetFeed 将会话的最近消息或每日摘要输出为 Atom 订阅源，路径为 /feed/:talker.atom
+// mode=messages（默认）每条消息一个条目，limit 控制条数；mode=daily 每天一个摘要条目，days 控制天数
+func (s *Service) GetFeed(c *gin.Context) {
+	talker, ok := strings.CutSuffix(c.Param("file"), ".atom")
+	if !ok || talker == "" {
+		errors.Err(c, errors.ErrTalkerEmpty)
+		return
+	}
+
+	q := struct {
+		Mode  string `form:"mode"`
+		Limit int    `form:"limit,default=50"`
+		Days  int    `form:"days,default=7"`
+	}{}
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	prefix := mediaPrefix(c)
+	feed := &atomFeed{
+		ID:     "urn:chatlog:" + talker,
+		Title:  talker,
+		Author: atomAuthor{Name: "chatlog"},
+		Link: []atomLink{
+			{Rel: "self", Href: prefix + c.Request.URL.RequestURI()},
+			{Rel: "alternate", Href: prefix + "/api/v1/chatlog?talker=" + url.QueryEscape(talker) + "&time=last-7d"},
+		},
+	}
+
+	var err error
+	switch strings.ToLower(q.Mode) {
+	case "", "messages":
+		if q.Limit <= 0 || q.Limit > maxContextSize {
+			errors.Err(c, errors.InvalidArg("limit"))
+			return
+		}
+		err = s.feedMessages(c, feed, talker, q.Limit)
+	case "daily":
+		if q.Days <= 0 || q.Days > maxFeedDays {
+			errors.Err(c, errors.InvalidArg("days"))
+			return
+		}
+		err = s.feedDaily(c, feed, talker, q.Days)
+	default:
+		errors.Err(c, errors.InvalidArg("mode"))
+		return
+	}
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	if feed.Updated == "" {
+		feed.Updated = time.Now().Format(time.RFC3339)
+	}
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/atom+xml; charset=utf-8", append([]byte(xml.Header), data...))
+}
+
+// feedMessages 最近的消息，新消息在前
+func (s *Service) feedMessages(c *gin.Context, feed *atomFeed, talker string, limit int) error {
+	start, end, _ := util.TimeRangeOf("all")
+	messages, err := s.db.GetMessages(c.Request.Context(), start, end, talker, "", "", "", true, limit, 0)
+	if err != nil {
+		return err
+	}
+
+	prefix := mediaPrefix(c)
+	setLang(messages, langOf(c.Request))
+	for i, m := range messages {
+		if i == 0 {
+			feed.Updated = m.Time.Format(time.RFC3339)
+			if m.TalkerName != "" {
+				feed.Title = m.TalkerName
+			}
+		}
+		m.SetContent("host", hostOf(c))
+		text := m.PlainTextContent()
+
+		sender := m.SenderName
+		if sender == "" {
+			sender = m.Sender
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      fmt.Sprintf("urn:chatlog:%s:%d", m.Talker, m.Seq),
+			Title:   feedTitle(sender, text),
+			Updated: m.Time.Format(time.RFC3339),
+			Author:  &atomAuthor{Name: sender},
+			Link:    &atomLink{Href: fmt.Sprintf("%s/api/v1/message/%s/%d", prefix, url.PathEscape(m.Talker), m.Seq)},
+			Content: atomContent{Type: "text", Body: text},
+		})
+	}
+	return nil
+}
+
+// feedDaily 最近几天的每日摘要，没有消息的日期不生成条目
+func (s *Service) feedDaily(c *gin.Context, feed *atomFeed, talker string, days int) error {
+	today := time.Now()
+	for i := 0; i < days; i++ {
+		date := today.AddDate(0, 0, -i).Format("2006-01-02")
+		d, err := s.buildDigest(c.Request.Context(), date, talker, langOf(c.Request))
+		if err != nil {
+			return err
+		}
+		if len(d.Items) == 0 {
+			continue
+		}
+		html, err := d.HTML()
+		if err != nil {
+			return err
+		}
+
+		_, end, _ := util.TimeRangeOf(date)
+		updated := end.Format(time.RFC3339)
+		if i == 0 {
+			updated = today.Format(time.RFC3339)
+		}
+		if feed.Updated == "" {
+			feed.Updated = updated
+			feed.Title = d.Items[0].Name
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      fmt.Sprintf("urn:chatlog:%s:daily:%s", talker, date),
+			Title:   d.Title,
+			Updated: updated,
+			Link:    &atomLink{Href: fmt.Sprintf("%s/api/v1/analysis/digest?talker=%s&time=%s", mediaPrefix(c), url.QueryEscape(talker), date)},
+			Content: atomContent{Type: "html", Body: html},
+		})
+	}
+	return nil
+}
+
+// feedTitle 条目标题，取消息内容的第一行并截断
+func feedTitle(sender, text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	if r := []rune(line); len(r) > 60 {
+		line = string(r[:60]) + "…"
+	}
+	return sender + ": " + line
+}
@@ -0,0 +1,155 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// fieldSet 需要保留的字段，支持以 . 分隔的嵌套字段，如 contents.md5
+type fieldSet map[string]fieldSet
+
+func parseFields(str string) fieldSet {
+	fields := fieldSet{}
+	for _, field := range util.Str2List(str, ",") {
+		node := fields
+		for _, name := range strings.Split(field, ".") {
+			if name == "" {
+				break
+			}
+			child, ok := node[name]
+			if !ok {
+				child = fieldSet{}
+				node[name] = child
+			}
+			node = child
+		}
+	}
+	return fields
+}
+
+// fieldsMiddleware 根据 fields 参数裁剪列表接口返回的记录，只保留指定字段
+// 作用于 JSON 数组的每个元素或对象中 items 数组的每个元素，其余响应保持不变
+func (s *Service) fieldsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fields := parseFields(c.Query("fields"))
+		if len(fields) == 0 {
+			c.Next()
+			return
+		}
+
+		w := &jsonWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if !w.buffered {
+			return
+		}
+
+		body := w.buf.Bytes()
+		if w.Status() != http.StatusOK {
+			w.ResponseWriter.Write(body)
+			return
+		}
+
+		var v interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&v); err != nil {
+			w.ResponseWriter.Write(body)
+			return
+		}
+
+		switch list := v.(type) {
+		case []interface{}:
+			for i := range list {
+				list[i] = fields.apply(list[i])
+			}
+		case map[string]interface{}:
+			if items, ok := list["items"].([]interface{}); ok {
+				for i := range items {
+					items[i] = fields.apply(items[i])
+				}
+			}
+		}
+
+		data, err := json.Marshal(v)
+		if err != nil {
+			w.ResponseWriter.Write(body)
+			return
+		}
+		w.ResponseWriter.Write(data)
+	}
+}
+
+// apply 裁剪单条记录，叶子节点保留完整的值
+func (f fieldSet) apply(v interface{}) interface{} {
+	if len(f) == 0 {
+		return v
+	}
+	switch obj := v.(type) {
+	case map[string]interface{}:
+		ret := make(map[string]interface{}, len(f))
+		for name, sub := range f {
+			if value, ok := obj[name]; ok {
+				ret[name] = sub.apply(value)
+			}
+		}
+		return ret
+	case []interface{}:
+		for i := range obj {
+			obj[i] = f.apply(obj[i])
+		}
+		return obj
+	default:
+		return v
+	}
+}
+
+// writeJSONL 以 NDJSON 格式逐行输出列表，每行一个 JSON 对象，同样支持 fields 参数裁剪字段
+func writeJSONL[T any](c *gin.Context, items []T) {
+	fields := parseFields(c.Query("fields"))
+
+	writeJSONLHeader(c)
+	for _, item := range items {
+		writeJSONLine(c, fields, item)
+	}
+	c.Writer.Flush()
+}
+
+// writeJSONLHeader 写出 NDJSON 响应头
+func writeJSONLHeader(c *gin.Context) {
+	c.Writer.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.WriteHeader(http.StatusOK)
+}
+
+// writeJSONLine 输出一行 JSON，序列化失败时跳过
+func writeJSONLine(c *gin.Context, fields fieldSet, item interface{}) {
+	v := item
+	if len(fields) > 0 {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return
+		}
+		var obj interface{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&obj); err != nil {
+			return
+		}
+		v = fields.apply(obj)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	c.Writer.Write(data)
+	c.Writer.WriteString("\n")
+}
@@ -0,0 +1,262 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/graphql"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// 查询的最大字段嵌套层数与关联字段解析次数，避免嵌套的关联查询成倍放大全量扫描
+const (
+	graphqlMaxDepth = 4
+	graphqlMaxNodes = 1000
+)
+
+// graphqlRequest GraphQL 请求，GET 请求中 variables 为 JSON 字符串
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// GraphQL 执行 GraphQL 查询，支持消息、联系人、群聊、会话与多媒体之间的关联查询
+func (s *Service) GraphQL(c *gin.Context) {
+	var req graphqlRequest
+	switch c.Request.Method {
+	case http.MethodGet:
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if v := c.Query("variables"); v != "" {
+			if err := decodeJSON([]byte(v), &req.Variables); err != nil {
+				errors.Err(c, errors.InvalidArg("variables"))
+				return
+			}
+		}
+	default:
+		body, err := c.GetRawData()
+		if err != nil || decodeJSON(body, &req) != nil {
+			errors.Err(c, errors.InvalidArg("body"))
+			return
+		}
+	}
+	if req.Query == "" {
+		errors.Err(c, errors.InvalidArg("query"))
+		return
+	}
+
+	result := s.graphqlSchema(c).Execute(c.Request.Context(), req.Query, req.Variables, req.OperationName)
+	if result.Data == nil {
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// decodeJSON 解析 JSON 时保留数字原样，避免整数参数被转为浮点数
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// graphqlSchema 构建 GraphQL 查询结构，多媒体地址依赖当前请求的 Host
+func (s *Service) graphqlSchema(c *gin.Context) *graphql.Schema {
+	prefix := mediaPrefix(c)
+	host := hostOf(c)
+	lang := langOf(c.Request)
+
+	return &graphql.Schema{
+		Query:    "Query",
+		MaxDepth: graphqlMaxDepth,
+		MaxNodes: graphqlMaxNodes,
+		Types: map[string]graphql.Object{
+			"Query": {
+				"messages": {Type: "Message", Resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					start, end, ok := util.TimeRangeOf(graphql.String(args, "time"))
+					if !ok {
+						return nil, errors.InvalidArg("time")
+					}
+					var desc bool
+					switch strings.ToLower(graphql.String(args, "order")) {
+					case "", "asc":
+					case "desc":
+						desc = true
+					default:
+						return nil, errors.InvalidArg("order")
+					}
+					limit := graphql.Int(args, "limit", 100)
+					offset := graphql.Int(args, "offset", 0)
+					if limit < 0 || offset < 0 {
+						return nil, errors.InvalidArg("limit")
+					}
+					messages, err := s.db.GetMessages(ctx, start, end,
+						graphql.String(args, "talker"),
+						graphql.String(args, "sender"),
+						graphql.String(args, "keyword"),
+						graphql.String(args, "type"),
+						desc, limit, offset)
+					if err != nil {
+						return nil, err
+					}
+					for _, m := range messages {
+						m.SetMediaURLs(prefix)
+					}
+					return messages, nil
+				}},
+				"message": {Type: "Message", Resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					talker := graphql.String(args, "talker")
+					seq := graphql.Int(args, "seq", 0)
+					if talker == "" || seq <= 0 {
+						return nil, errors.InvalidArg("seq")
+					}
+					m, err := s.db.GetMessage(ctx, talker, int64(seq))
+					if err != nil {
+						return nil, err
+					}
+					m.SetMediaURLs(prefix)
+					return m, nil
+				}},
+				"contacts": {Type: "Contact", Resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					resp, err := s.db.GetContacts(ctx, graphql.String(args, "keyword"), graphql.Int(args, "limit", 0), graphql.Int(args, "offset", 0))
+					if err != nil {
+						return nil, err
+					}
+					return resp.Items, nil
+				}},
+				"contact": {Type: "Contact", Resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					return s.graphqlContact(ctx, graphql.String(args, "id"))
+				}},
+				"chatrooms": {Type: "ChatRoom", Resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					resp, err := s.db.GetChatRooms(ctx, graphql.String(args, "keyword"), graphql.Int(args, "limit", 0), graphql.Int(args, "offset", 0))
+					if err != nil {
+						return nil, err
+					}
+					return resp.Items, nil
+				}},
+				"chatroom": {Type: "ChatRoom", Resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					return s.graphqlChatRoom(ctx, graphql.String(args, "id"))
+				}},
+				"sessions": {Type: "Session", Resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					resp, err := s.db.GetSessions(ctx, graphql.String(args, "keyword"), graphql.Int(args, "limit", 0), graphql.Int(args, "offset", 0))
+					if err != nil {
+						return nil, err
+					}
+					return resp.Items, nil
+				}},
+			},
+			"Message": {
+				"text": {Resolve: func(ctx context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+					m := source.(*model.Message)
+					m.SetContent("host", host)
+					setLang([]*model.Message{m}, lang)
+					return m.PlainTextContent(), nil
+				}},
+				"senderContact": {Type: "Contact", Resolve: func(ctx context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+					return s.graphqlContact(ctx, source.(*model.Message).Sender)
+				}},
+				"chatroom": {Type: "ChatRoom", Resolve: func(ctx context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+					m := source.(*model.Message)
+					if !m.IsChatRoom {
+						return nil, nil
+					}
+					return s.graphqlChatRoom(ctx, m.Talker)
+				}},
+				"media": {Resolve: func(ctx context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+					return s.resolveMedia(ctx, source.(*model.Message)), nil
+				}},
+			},
+			"Contact": {
+				"chatrooms": {Type: "ChatRoom", Resolve: func(ctx context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+					return s.graphqlMemberOf(ctx, source.(*model.Contact).UserName)
+				}},
+			},
+			"ChatRoom": {
+				"owner": {Type: "Contact", Resolve: func(ctx context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+					return s.graphqlContact(ctx, source.(*model.ChatRoom).Owner)
+				}},
+				"members": {Type: "Contact", Resolve: func(ctx context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+					room := source.(*model.ChatRoom)
+					members := make([]*model.Contact, 0, len(room.Users))
+					for _, u := range room.Users {
+						contact, err := s.graphqlContact(ctx, u.UserName)
+						if err != nil {
+							return nil, err
+						}
+						if contact == nil {
+							// 非好友的群成员没有联系人记录，使用群昵称代替
+							contact = &model.Contact{UserName: u.UserName, NickName: u.DisplayName}
+						}
+						members = append(members, contact)
+					}
+					return members, nil
+				}},
+			},
+			"Session": {
+				"contact": {Type: "Contact", Resolve: func(ctx context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+					return s.graphqlContact(ctx, source.(*model.Session).UserName)
+				}},
+			},
+		},
+	}
+}
+
+// graphqlContact 按微信 ID 精确查找联系人，不存在时返回 nil
+func (s *Service) graphqlContact(ctx context.Context, id string) (*model.Contact, error) {
+	if id == "" {
+		return nil, nil
+	}
+	resp, err := s.db.GetContacts(ctx, id, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, contact := range resp.Items {
+		if contact.UserName == id {
+			return contact, nil
+		}
+	}
+	return nil, nil
+}
+
+// graphqlChatRoom 按群 ID 精确查找群聊，不存在时返回 nil
+func (s *Service) graphqlChatRoom(ctx context.Context, id string) (*model.ChatRoom, error) {
+	if id == "" {
+		return nil, nil
+	}
+	resp, err := s.db.GetChatRooms(ctx, id, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, room := range resp.Items {
+		if room.Name == id {
+			return room, nil
+		}
+	}
+	return nil, nil
+}
+
+// graphqlMemberOf 查找联系人所在的群聊
+func (s *Service) graphqlMemberOf(ctx context.Context, userName string) ([]*model.ChatRoom, error) {
+	resp, err := s.db.GetChatRooms(ctx, "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*model.ChatRoom, 0)
+	for _, room := range resp.Items {
+		for _, u := range room.Users {
+			if u.UserName == userName {
+				ret = append(ret, room)
+				break
+			}
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,36 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/i18n"
+)
+
+// langOf 请求使用的语言，lang 参数优先于 Accept-Language 请求头，均未指定时返回空字符串
+func langOf(r *http.Request) string {
+	if lang := i18n.Parse(r.URL.Query().Get("lang")); lang != "" {
+		return lang
+	}
+	return i18n.Parse(r.Header.Get("Accept-Language"))
+}
+
+// csvHeader CSV 表头，只按 lang 参数切换语言，不受浏览器 Accept-Language 影响
+// 未指定 lang 时保持原有的英文列名，兼容已有的解析脚本
+func csvHeader(r *http.Request, key string) string {
+	lang := i18n.Parse(r.URL.Query().Get("lang"))
+	if lang == "" {
+		lang = i18n.EN
+	}
+	return i18n.T(lang, key)
+}
+
+// setLang 指定消息纯文本输出使用的语言
+func setLang(messages []*model.Message, lang string) {
+	if lang == "" {
+		return
+	}
+	for _, m := range messages {
+		m.SetContent("lang", lang)
+	}
+}
@@ -0,0 +1,148 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/chatlog/sidecar"
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// identityDetail 合并的身份及各账号的名称与头像
+type identityDetail struct {
+	ID      *participant   `json:"id"`
+	Aliases []*participant `json:"aliases"`
+}
+
+// identityBody 合并身份的请求体，账号可以是 ID、微信号、备注或昵称
+type identityBody struct {
+	Aliases []string `json:"aliases"`
+}
+
+// ListIdentities 按 ID 列出合并的联系人身份
+func (s *Service) ListIdentities(c *gin.Context) {
+	ctx := c.Request.Context()
+	identities, err := s.db.Sidecar().Identities(ctx, s.db.AccountName(ctx))
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": identities, "total": len(identities)})
+}
+
+// GetIdentity 查询身份合并的账号，附带名称与头像
+func (s *Service) GetIdentity(c *gin.Context) {
+	ctx := c.Request.Context()
+	subject, err := s.findSubject(ctx, c.Param("id"), false)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	identity, err := s.identity(c, subject.Talker)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	ret := &identityDetail{ID: s.participant(ctx, identity.ID), Aliases: make([]*participant, 0, len(identity.Aliases))}
+	for _, alias := range identity.Aliases {
+		ret.Aliases = append(ret.Aliases, s.participant(ctx, alias))
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// identity 查找账号所属的身份，账号可以是身份 ID 或其中任一账号
+func (s *Service) identity(c *gin.Context, id string) (*sidecar.Identity, error) {
+	ctx := c.Request.Context()
+	identities, err := s.db.Sidecar().Identities(ctx, s.db.AccountName(ctx))
+	if err != nil {
+		return nil, err
+	}
+	for _, identity := range identities {
+		if identity.ID == id {
+			return identity, nil
+		}
+		for _, alias := range identity.Aliases {
+			if alias == id {
+				return identity, nil
+			}
+		}
+	}
+	return nil, errors.IdentityNotFound(id)
+}
+
+// MergeIdentity 将账号合并到身份，查询、统计与导出时视为同一个人
+// 请求体：{"aliases": ["wxid_old"]}
+func (s *Service) MergeIdentity(c *gin.Context) {
+	var req identityBody
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Aliases) == 0 {
+		errors.Err(c, errors.InvalidArg("aliases"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	subject, err := s.findSubject(ctx, c.Param("id"), false)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if strings.HasSuffix(subject.Talker, "@chatroom") {
+		errors.Err(c, errors.InvalidArg("id"))
+		return
+	}
+	aliases := make([]string, 0, len(req.Aliases))
+	for _, key := range req.Aliases {
+		alias, err := s.findSubject(ctx, strings.TrimSpace(key), false)
+		if err != nil {
+			errors.Err(c, err)
+			return
+		}
+		if strings.HasSuffix(alias.Talker, "@chatroom") {
+			errors.Err(c, errors.InvalidArg("aliases"))
+			return
+		}
+		aliases = append(aliases, alias.Talker)
+	}
+
+	id, err := s.db.Sidecar().MergeIdentity(ctx, s.db.AccountName(ctx), subject.Talker, aliases)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	identity, err := s.identity(c, id)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, identity)
+}
+
+// SplitIdentity 拆分合并的身份，id 可以是身份中的任一账号，路径中带有账号时只拆分该账号
+func (s *Service) SplitIdentity(c *gin.Context) {
+	ctx := c.Request.Context()
+	subject, err := s.findSubject(ctx, c.Param("id"), false)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	identity, err := s.identity(c, subject.Talker)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	var aliases []string
+	if key := c.Param("alias"); key != "" {
+		alias, err := s.findSubject(ctx, key, false)
+		if err != nil {
+			errors.Err(c, err)
+			return
+		}
+		aliases = append(aliases, alias.Talker)
+	}
+	if err := s.db.Sidecar().RemoveIdentity(ctx, s.db.AccountName(ctx), identity.ID, aliases...); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
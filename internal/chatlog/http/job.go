@@ -0,0 +1,135 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+
+	"github.com/sjzar/chatlog/internal/chatlog/analysis"
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/chatlog/job"
+	"github.com/sjzar/chatlog/internal/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jobsDir 任务结果持久化目录，位于工作目录下
+// 工作目录加密时不持久化，避免任务结果中的聊天内容以明文写入磁盘
+func (s *Service) jobsDir() string {
+	if s.ctx.WorkDir == "" || s.ctx.WorkKey != "" {
+		return ""
+	}
+	return filepath.Join(s.ctx.WorkDir, "jobs")
+}
+
+// registerJobs 注册可异步执行的分析任务，任务参数中的 account 指定查询的账号
+func (s *Service) registerJobs() {
+	register := func(_type string, fn job.Func) {
+		s.jobs.Register(_type, func(ctx context.Context, params map[string]string, progress func(int)) (interface{}, error) {
+			return fn(database.WithAccount(ctx, params["account"]), params, progress)
+		})
+	}
+
+	register("metrics", func(ctx context.Context, params map[string]string, progress func(int)) (interface{}, error) {
+		scope, err := newAnalysisScope(params["talker"], params["time"])
+		if err != nil {
+			return nil, err
+		}
+		messages, err := s.db.GetMessages(ctx, scope.Start, scope.End, scope.Talker, "", "", "", false, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		progress(50)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return gin.H{
+			"scope":   scope,
+			"metrics": analysis.Compute(messages, scope.Start, scope.End, s.options()),
+		}, nil
+	})
+
+	register("profile", func(ctx context.Context, params map[string]string, progress func(int)) (interface{}, error) {
+		_time := params["time"]
+		if _time == "" {
+			_time = "all"
+		}
+		scope, err := newAnalysisScope(params["talker"], _time)
+		if err != nil {
+			return nil, err
+		}
+		return s.profile(ctx, scope, params["summary"] == "true")
+	})
+
+	register("report", func(ctx context.Context, params map[string]string, progress func(int)) (interface{}, error) {
+		return s.generateReport(ctx, params["time"], params["talker"], progress)
+	})
+
+	register("corpus", func(ctx context.Context, params map[string]string, progress func(int)) (interface{}, error) {
+		return s.generateCorpus(ctx, params, progress)
+	})
+
+	register("digest", func(ctx context.Context, params map[string]string, progress func(int)) (interface{}, error) {
+		return s.sendDigest(ctx, params["time"], params["talker"], params["recipients"])
+	})
+}
+
+// CreateJob 提交异步任务
+// 请求体：{"type": "metrics", "params": {"talker": "xxx", "time": "2024"}}
+func (s *Service) CreateJob(c *gin.Context) {
+	var req struct {
+		Type   string            `json:"type"`
+		Params map[string]string `json:"params"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors.Err(c, errors.InvalidArg("body"))
+		return
+	}
+	if req.Type == "" {
+		errors.Err(c, errors.InvalidArg("type"))
+		return
+	}
+
+	// 任务在后台执行，account 参数随任务参数保存
+	if account := c.Query("account"); account != "" {
+		if req.Params == nil {
+			req.Params = make(map[string]string)
+		}
+		req.Params["account"] = account
+	}
+	j, err := s.jobs.Submit(req.Type, req.Params)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, j)
+}
+
+// ListJobs 列出任务及可用的任务类型
+func (s *Service) ListJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"types": s.jobs.Types(),
+		"items": s.jobs.List(),
+	})
+}
+
+// GetJob 查询任务状态、进度与结果
+func (s *Service) GetJob(c *gin.Context) {
+	j, err := s.jobs.Get(c.Param("id"))
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, j)
+}
+
+// CancelJob 取消未结束的任务，返回任务的实际状态
+func (s *Service) CancelJob(c *gin.Context) {
+	j, err := s.jobs.Cancel(c.Param("id"))
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, j)
+}
@@ -0,0 +1,143 @@
+package http
+
+import (
+	"encoding/base64"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/sjzar/chatlog/pkg/mdns"
+	"github.com/sjzar/chatlog/pkg/qrcode"
+	"github.com/sjzar/chatlog/pkg/version"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// ServerInfo 服务的访问地址，绑定局域网地址时手机等设备可扫描二维码访问
+type ServerInfo struct {
+	Version string `json:"version"`
+
+	// URL 首选的访问地址，绑定局域网地址时为第一个局域网地址，否则为本机地址
+	URL  string   `json:"url"`
+	URLs []string `json:"urls"`
+	LAN  bool     `json:"lan"`
+
+	// MDNS 通过 mDNS 发布的地址，未绑定局域网地址或未启用时为空
+	MDNS string `json:"mdns,omitempty"`
+
+	// QRCode URL 对应的二维码，PNG 格式的 data URL
+	QRCode string `json:"qrcode,omitempty"`
+}
+
+// ServerInfo 根据监听地址计算访问地址
+func (s *Service) ServerInfo() *ServerInfo {
+	scheme := "http"
+	if s.ctx.HTTP.TLS.Enabled() {
+		scheme = "https"
+	}
+	host, port, err := net.SplitHostPort(s.tcpAddr())
+	if err != nil {
+		host, port = "127.0.0.1", "5030"
+	}
+
+	info := &ServerInfo{Version: version.Version}
+	base := s.ctx.HTTP.Prefix() + "/"
+	ips := lanIPs(host)
+	for _, ip := range ips {
+		info.URLs = append(info.URLs, scheme+"://"+net.JoinHostPort(ip.String(), port)+base)
+	}
+	info.LAN = len(info.URLs) > 0
+	if !info.LAN {
+		if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+			host = "127.0.0.1"
+		}
+		info.URLs = append(info.URLs, scheme+"://"+net.JoinHostPort(host, port)+base)
+	}
+	info.URL = info.URLs[0]
+	if name := s.ctx.HTTP.MDNS; info.LAN && name != "" && name != "-" {
+		info.MDNS = scheme + "://" + net.JoinHostPort(name+".local", port) + base
+	}
+	return info
+}
+
+// lanIPs 返回监听地址对应的局域网 IPv4 地址，监听所有地址时列出各网卡的局域网地址
+func lanIPs(host string) []net.IP {
+	ip := net.ParseIP(host)
+	if host != "" && ip == nil {
+		return nil
+	}
+	if ip != nil && !ip.IsUnspecified() {
+		if ip.To4() != nil && ip.IsPrivate() {
+			return []net.IP{ip}
+		}
+		return nil
+	}
+
+	var ret []net.IP
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if ip4 := ipNet.IP.To4(); ip4 != nil && ip4.IsPrivate() {
+				ret = append(ret, ip4)
+			}
+		}
+	}
+	return ret
+}
+
+// startMDNS 绑定局域网地址时通过 mDNS 发布服务，http.mdns 设为 "-" 时不发布
+func (s *Service) startMDNS() {
+	name := s.ctx.HTTP.MDNS
+	if name == "" || name == "-" {
+		return
+	}
+	host, port, err := net.SplitHostPort(s.tcpAddr())
+	if err != nil {
+		return
+	}
+	ips := lanIPs(host)
+	if len(ips) == 0 {
+		return
+	}
+	p, _ := strconv.Atoi(port)
+	server, err := mdns.Start(name, p, ips, "path="+s.ctx.HTTP.Prefix()+"/")
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to start mDNS")
+		return
+	}
+	s.mdns = server
+	log.Info().Msgf("Advertising %s.local via mDNS", name)
+}
+
+func (s *Service) stopMDNS() {
+	if s.mdns != nil {
+		s.mdns.Close()
+		s.mdns = nil
+	}
+}
+
+// GetServerInfo 返回服务的访问地址与首选地址的二维码
+func (s *Service) GetServerInfo(c *gin.Context) {
+	info := s.ServerInfo()
+	if code, err := qrcode.Encode([]byte(info.URL)); err == nil {
+		if data, err := code.PNG(8, 4); err == nil {
+			info.QRCode = "data:image/png;base64," + base64.StdEncoding.EncodeToString(data)
+		}
+	}
+	c.JSON(http.StatusOK, info)
+}
@@ -0,0 +1,110 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// latencyBuckets 接口耗时直方图的桶上限（秒），覆盖从缓存命中到全量分析的耗时
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// latencyKey 按请求方法与路由模板统计，避免路径参数导致统计项无限增长
+type latencyKey struct {
+	method string
+	route  string
+}
+
+// latencyHistogram 单个接口的耗时分布，counts[i] 为耗时不超过 latencyBuckets[i] 的请求数（不累加）
+type latencyHistogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// latencyMetrics 各接口的耗时直方图
+type latencyMetrics struct {
+	mu         sync.Mutex
+	histograms map[latencyKey]*latencyHistogram
+}
+
+func newLatencyMetrics() *latencyMetrics {
+	return &latencyMetrics{histograms: make(map[latencyKey]*latencyHistogram)}
+}
+
+func (m *latencyMetrics) observe(method, route string, d time.Duration) {
+	seconds := d.Seconds()
+	i := sort.SearchFloat64s(latencyBuckets, seconds)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := latencyKey{method: method, route: route}
+	h, ok := m.histograms[key]
+	if !ok {
+		h = &latencyHistogram{counts: make([]uint64, len(latencyBuckets))}
+		m.histograms[key] = h
+	}
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// writeTo 按 Prometheus 文本格式输出直方图
+func (m *latencyMetrics) writeTo(b *strings.Builder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]latencyKey, 0, len(m.histograms))
+	for key := range m.histograms {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].method < keys[j].method
+	})
+
+	b.WriteString("# HELP chatlog_http_request_duration_seconds HTTP request latency by route.\n")
+	b.WriteString("# TYPE chatlog_http_request_duration_seconds histogram\n")
+	for _, key := range keys {
+		h := m.histograms[key]
+		labels := fmt.Sprintf("method=%q,route=%q", key.method, key.route)
+		var cumulative uint64
+		for i, le := range latencyBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(b, "chatlog_http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(b, "chatlog_http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(b, "chatlog_http_request_duration_seconds_sum{%s} %g\n", labels, h.sum)
+		fmt.Fprintf(b, "chatlog_http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+}
+
+// latencyMiddleware 记录每个请求的耗时，未匹配路由的请求合并统计
+func (s *Service) latencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		s.latency.observe(c.Request.Method, route, time.Since(start))
+	}
+}
+
+// GetMetrics 以 Prometheus 文本格式返回各接口的耗时直方图
+func (s *Service) GetMetrics(c *gin.Context) {
+	var b strings.Builder
+	s.latency.writeTo(&b)
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"encoding/csv"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/pkg/i18n"
+	"github.com/sjzar/chatlog/pkg/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SharedLink 聊天中分享过的链接
+type SharedLink struct {
+	URL        string    `json:"url"`
+	Title      string    `json:"title,omitempty"`
+	Talker     string    `json:"talker"`
+	TalkerName string    `json:"talkerName,omitempty"`
+	Sender     string    `json:"sender"`
+	SenderName string    `json:"senderName,omitempty"`
+	Time       time.Time `json:"time"` // 首次分享时间
+	Count      int       `json:"count"`
+}
+
+var linksHTMLTemplate = template.Must(template.New("links").Funcs(template.FuncMap{"t": i18n.T, "tf": i18n.Tf}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", "PingFang SC", "Microsoft YaHei", sans-serif; margin: 24px; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ddd; padding: 6px 8px; text-align: left; vertical-align: top; font-size: 14px; }
+th { background: #f5f5f5; }
+td.url { word-break: break-all; }
+</style>
+</head>
+<body>
+<h2>{{.Title}}</h2>
+<p>{{tf .Lang "links.count" (len .Links)}}</p>
+<table>
+<tr><th>{{t .Lang "links.time"}}</th><th>{{t .Lang "links.sender"}}</th><th>{{t .Lang "links.url"}}</th><th>{{t .Lang "links.times"}}</th></tr>
+{{range .Links}}<tr>
+<td>{{.Time.Format "2006-01-02 15:04:05"}}</td>
+<td>{{if .SenderName}}{{.SenderName}}({{.Sender}}){{else}}{{.Sender}}{{end}}</td>
+<td class="url">{{if .Title}}{{.Title}}<br>{{end}}<a href="{{.URL}}" target="_blank" rel="noreferrer">{{.URL}}</a></td>
+<td>{{.Count}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// GetLinks 提取聊天中分享过的所有链接（文本消息与分享类消息），去重后按首次分享时间排序
+func (s *Service) GetLinks(c *gin.Context) {
+	q := struct {
+		Time   string `form:"time"`
+		Talker string `form:"talker"`
+		Sender string `form:"sender"`
+		Format string `form:"format"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	if q.Talker == "" {
+		errors.Err(c, errors.ErrTalkerEmpty)
+		return
+	}
+	lang := langOf(c.Request)
+	if q.Time == "" {
+		q.Time = "all"
+	}
+	start, end, ok := util.TimeRangeOf(q.Time)
+	if !ok {
+		errors.Err(c, errors.InvalidArg("time"))
+		return
+	}
+
+	messages, err := s.db.GetMessages(c.Request.Context(), start, end, q.Talker, q.Sender, "", "", false, 0, 0)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	links := make([]*SharedLink, 0)
+	index := make(map[string]*SharedLink)
+	for _, msg := range messages {
+		for _, url := range msg.SharedURLs() {
+			if link, ok := index[url]; ok {
+				link.Count++
+				continue
+			}
+			link := &SharedLink{
+				URL:        url,
+				Talker:     msg.Talker,
+				TalkerName: msg.TalkerName,
+				Sender:     msg.Sender,
+				SenderName: msg.SenderName,
+				Time:       msg.Time,
+				Count:      1,
+			}
+			if msg.Type == 49 {
+				if title, ok := msg.Contents["title"].(string); ok {
+					link.Title = title
+				}
+			}
+			if msg.IsSelf {
+				link.SenderName = i18n.T(lang, "msg.me")
+			}
+			index[url] = link
+			links = append(links, link)
+		}
+	}
+
+	switch strings.ToLower(q.Format) {
+	case "csv":
+		c.Writer.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		c.Writer.Header().Set("Content-Disposition", "attachment; filename=links_export.csv")
+		c.Writer.WriteHeader(http.StatusOK)
+
+		w := csv.NewWriter(c.Writer)
+		w.Write(strings.Split(csvHeader(c.Request, "csv.links"), ","))
+		for _, link := range links {
+			w.Write([]string{
+				link.Time.Format("2006-01-02 15:04:05"),
+				link.Talker,
+				link.Sender,
+				link.SenderName,
+				link.Title,
+				link.URL,
+				strconv.Itoa(link.Count),
+			})
+		}
+		w.Flush()
+	case "html":
+		title := i18n.Tf(lang, "links.title", q.Talker)
+		if len(messages) > 0 && messages[0].TalkerName != "" {
+			title = i18n.Tf(lang, "links.title", messages[0].TalkerName)
+		}
+		c.Writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		c.Writer.WriteHeader(http.StatusOK)
+		if err := linksHTMLTemplate.Execute(c.Writer, gin.H{"Lang": lang, "Title": title, "Links": links}); err != nil {
+			c.Error(err)
+		}
+	case "jsonl", "ndjson":
+		writeJSONL(c, links)
+	default:
+		c.JSON(http.StatusOK, links)
+	}
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// UnixPrefix 监听地址以 unix: 开头时表示 unix domain socket，如 unix:/run/chatlog.sock
+const UnixPrefix = "unix:"
+
+type unixConnKey struct{}
+
+// listenAddrs 所有监听地址，主地址在前，去掉重复与空地址
+func (s *Service) listenAddrs() []string {
+	seen := make(map[string]bool)
+	addrs := make([]string, 0, 1+len(s.ctx.HTTP.Listen))
+	for _, addr := range append([]string{s.ctx.HTTPAddr}, s.ctx.HTTP.Listen...) {
+		addr = strings.TrimSpace(addr)
+		if addr == "" || seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// tcpAddr 第一个 TCP 监听地址，用于生成访问地址、证书与局域网发布，只监听 unix socket 时为空
+func (s *Service) tcpAddr() string {
+	for _, addr := range s.listenAddrs() {
+		if !strings.HasPrefix(addr, UnixPrefix) {
+			return addr
+		}
+	}
+	return ""
+}
+
+// listen 监听 TCP 地址或 unix socket
+// socket 文件已存在且无人监听时删除后重新创建，权限为 0600，仅当前用户可访问
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, UnixPrefix)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("invalid unix socket address %q", addr)
+	}
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use", path)
+		}
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// unixConnContext 标记来自 unix socket 的连接
+func unixConnContext(ctx context.Context, c net.Conn) context.Context {
+	if _, ok := c.LocalAddr().(*net.UnixAddr); ok {
+		return context.WithValue(ctx, unixConnKey{}, true)
+	}
+	return ctx
+}
+
+// fromUnixSocket 请求是否来自 unix socket
+func fromUnixSocket(r *http.Request) bool {
+	ok, _ := r.Context().Value(unixConnKey{}).(bool)
+	return ok
+}
+
+// unixHandler 来自 unix socket 的请求没有客户端地址，视为本机地址，限流与审计日志据此处理
+// socket 文件仅当前用户可访问，不受 http.allow 与客户端证书限制
+func unixHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fromUnixSocket(r) {
+			r.RemoteAddr = "127.0.0.1:0"
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/websocket"
+)
+
+// livePingInterval WebSocket 心跳间隔，避免代理断开空闲连接
+const livePingInterval = 30 * time.Second
+
+// liveCursor 记录已推送消息的位置，同一秒内的消息按会话与序号去重
+type liveCursor struct {
+	since time.Time
+	seen  map[string]struct{}
+}
+
+func newLiveCursor(since time.Time) *liveCursor {
+	return &liveCursor{since: since.Truncate(time.Second), seen: make(map[string]struct{})}
+}
+
+// next 过滤已推送的消息并前移游标
+func (l *liveCursor) next(messages []*model.Message) []*model.Message {
+	ret := make([]*model.Message, 0, len(messages))
+	for _, m := range messages {
+		key := fmt.Sprintf("%s:%d", m.Talker, m.Seq)
+		if _, ok := l.seen[key]; ok || m.Time.Before(l.since) {
+			continue
+		}
+		ret = append(ret, m)
+
+		if t := m.Time.Truncate(time.Second); t.After(l.since) {
+			l.since = t
+			l.seen = make(map[string]struct{})
+		}
+		l.seen[key] = struct{}{}
+	}
+	return ret
+}
+
+// GetChatlogLive 通过 WebSocket 推送会话中新出现的消息
+// 解密数据库刷新后查询上次推送之后的消息，每条消息作为一个 JSON 文本帧发送
+func (s *Service) GetChatlogLive(c *gin.Context) {
+	talker := c.Query("talker")
+	if talker == "" {
+		errors.Err(c, errors.ErrTalkerEmpty)
+		return
+	}
+
+	conn, err := websocket.Upgrade(c.Writer, c.Request, s.ctx.Settings().WSOrigins...)
+	if err != nil {
+		log.Debug().Err(err).Msg("websocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+
+	updates, cancel := s.db.Subscribe()
+	defer cancel()
+
+	// 客户端断开或发送 close 帧时结束推送
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	prefix := mediaPrefix(c)
+	redactor := s.redactorOf(c)
+	cursor := newLiveCursor(time.Now())
+	ticker := time.NewTicker(livePingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-s.closing:
+			return
+		case <-ticker.C:
+			if err := conn.WriteMessage(websocket.OpPing, nil); err != nil {
+				return
+			}
+		case <-updates:
+			// 数据库文件替换后稍作等待，确保新文件已可读取
+			time.Sleep(time.Second)
+			messages, err := s.db.GetMessages(c.Request.Context(), cursor.since, time.Now().Add(time.Minute), talker, "", "", "", false, 0, 0)
+			if err != nil {
+				log.Debug().Err(err).Msg("live query failed")
+				continue
+			}
+			for _, m := range cursor.next(messages) {
+				m.SetMediaURLs(prefix)
+				data, err := json.Marshal(m)
+				if err != nil {
+					continue
+				}
+				// WebSocket 连接不经过脱敏中间件，逐条处理
+				if redactor != nil {
+					data = redactor.JSON(data)
+				}
+				if err := conn.WriteText(data); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
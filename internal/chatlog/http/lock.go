@@ -0,0 +1,237 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+var errInvalidPassphrase = errors.New(nil, http.StatusUnauthorized, "invalid passphrase")
+
+// idleLock 空闲自动锁定状态，进行中的请求（推送连接除外）不计入空闲时间
+type idleLock struct {
+	idle       time.Duration
+	passphrase string
+
+	// op 串行执行锁定时的清理与解锁时的重新打开
+	op sync.Mutex
+
+	mu     sync.Mutex
+	last   time.Time
+	active int
+	locked bool
+	stop   chan struct{}
+}
+
+// newIdleLock 未配置空闲时间或解锁口令时返回 nil，表示不自动锁定
+func newIdleLock(idle int, passphrase string) *idleLock {
+	if idle <= 0 {
+		return nil
+	}
+	if passphrase == "" {
+		log.Warn().Msg("auto lock disabled: set http.lock.passphrase or http.auth.password")
+		return nil
+	}
+	return &idleLock{
+		idle:       time.Duration(idle) * time.Minute,
+		passphrase: passphrase,
+		last:       time.Now(),
+	}
+}
+
+func (l *idleLock) isLocked() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.locked
+}
+
+// begin 请求开始，推送连接只记录时间，不阻止锁定
+func (l *idleLock) begin(stream bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.last = time.Now()
+	if !stream {
+		l.active++
+	}
+}
+
+func (l *idleLock) end() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.last = time.Now()
+	l.active--
+}
+
+// expired 空闲超时且没有进行中的请求时标记为锁定，返回是否需要执行锁定
+func (l *idleLock) expired(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.locked || l.active > 0 || now.Sub(l.last) < l.idle {
+		return false
+	}
+	l.locked = true
+	return true
+}
+
+// startIdleLock 定期检查空闲时间
+func (s *Service) startIdleLock() {
+	if s.lock == nil {
+		return
+	}
+	s.lock.stop = make(chan struct{})
+	interval := min(s.lock.idle/4, time.Minute)
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				s.lock.op.Lock()
+				if s.lock.expired(now) {
+					s.wipe()
+					log.Info().Msgf("locked after %s idle", s.lock.idle)
+				}
+				s.lock.op.Unlock()
+			}
+		}
+	}(s.lock.stop)
+}
+
+func (s *Service) stopIdleLock() {
+	if s.lock != nil && s.lock.stop != nil {
+		close(s.lock.stop)
+		s.lock.stop = nil
+	}
+}
+
+// Locked 服务是否处于锁定状态
+func (s *Service) Locked() bool {
+	return s.lock != nil && s.lock.isLocked()
+}
+
+// Lock 立即锁定，未启用自动锁定时返回 false
+func (s *Service) Lock() bool {
+	if s.lock == nil {
+		return false
+	}
+	s.lock.op.Lock()
+	defer s.lock.op.Unlock()
+	s.lock.mu.Lock()
+	already := s.lock.locked
+	s.lock.locked = true
+	s.lock.mu.Unlock()
+	if !already {
+		s.wipe()
+		log.Info().Msg("locked")
+	}
+	return true
+}
+
+// Unlock 校验口令后重新打开数据库
+func (s *Service) Unlock(passphrase string) error {
+	if s.lock == nil {
+		return nil
+	}
+	s.lock.op.Lock()
+	defer s.lock.op.Unlock()
+	if !s.lock.isLocked() {
+		return nil
+	}
+	if !checkPassword(s.lock.passphrase, passphrase) {
+		return errInvalidPassphrase
+	}
+	if err := s.db.Start(); err != nil {
+		return err
+	}
+	s.lock.mu.Lock()
+	s.lock.locked = false
+	s.lock.last = time.Now()
+	s.lock.mu.Unlock()
+	log.Info().Msg("unlocked")
+	return nil
+}
+
+// wipe 关闭数据库连接，清空分析结果缓存与脱敏名称对照表
+func (s *Service) wipe() {
+	if err := s.db.Stop(); err != nil {
+		log.Err(err).Msg("close db failed")
+	}
+	s.cache.clear()
+	s.resetRedactNames()
+}
+
+// lockMiddleware 锁定后除页面与解锁接口外的请求返回 423，未锁定时记录请求用于计算空闲时间
+func (s *Service) lockMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if s.lock == nil || isPage(path) || path == "/api/v1/lock" || path == "/api/v1/unlock" {
+			c.Next()
+			return
+		}
+		if s.lock.isLocked() {
+			errors.Err(c, errors.Locked())
+			c.Abort()
+			return
+		}
+
+		stream := path == "/sse" || path == "/api/v1/events" || path == "/ws/chatlog"
+		s.lock.begin(stream)
+		if !stream {
+			defer s.lock.end()
+		}
+		c.Next()
+	}
+}
+
+// GetLock 查询锁定状态
+func (s *Service) GetLock(c *gin.Context) {
+	if s.lock == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false, "locked": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "locked": s.lock.isLocked(), "idle": int(s.lock.idle.Minutes())})
+}
+
+// PostLock 立即锁定，离开电脑前可手动调用
+func (s *Service) PostLock(c *gin.Context) {
+	if !s.Lock() {
+		errors.Err(c, errors.New(nil, http.StatusNotFound, "auto lock is disabled"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"locked": true})
+}
+
+// PostUnlock 输入口令解锁，连续失败次数与登录共用限制
+func (s *Service) PostUnlock(c *gin.Context) {
+	var req struct {
+		Passphrase string `form:"passphrase" json:"passphrase"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		errors.Err(c, errors.InvalidArg("passphrase"))
+		return
+	}
+
+	ip := c.ClientIP()
+	if d := s.auth.locked(ip); d > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(d.Seconds())))
+		errors.Err(c, errors.RateLimited())
+		return
+	}
+	if err := s.Unlock(req.Passphrase); err != nil {
+		if err == errInvalidPassphrase {
+			s.auth.fail(ip)
+		}
+		errors.Err(c, err)
+		return
+	}
+	s.auth.succeed(ip)
+	c.JSON(http.StatusOK, gin.H{"locked": false})
+}
@@ -0,0 +1,341 @@
+package http
+
+import (
+	"context"
+
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/chatlog/analysis"
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/redact"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// messageDetail 单条消息详情，附带解析后的文本与媒体文件信息
+type messageDetail struct {
+	*model.Message
+	Text  string       `json:"text"`
+	Media *model.Media `json:"media,omitempty"`
+}
+
+// GetMessage 按会话与消息序号获取单条消息
+func (s *Service) GetMessage(c *gin.Context) {
+	talker := c.Param("talker")
+	seq, err := strconv.ParseInt(c.Param("seq"), 10, 64)
+	if talker == "" || err != nil || seq <= 0 {
+		errors.Err(c, errors.InvalidArg("seq"))
+		return
+	}
+
+	message, err := s.db.GetMessage(c.Request.Context(), talker, seq)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	message.SetContent("host", hostOf(c))
+	setLang([]*model.Message{message}, langOf(c.Request))
+	message.SetMediaURLs(mediaPrefix(c))
+	s.annotate(c, []*model.Message{message})
+	c.JSON(http.StatusOK, &messageDetail{
+		Message: message,
+		Text:    message.PlainTextContent(),
+		Media:   s.resolveMedia(c.Request.Context(), message),
+	})
+}
+
+// messageParams 路径中的会话与消息序号，不合法时返回错误响应
+func messageParams(c *gin.Context) (string, int64, bool) {
+	talker := c.Param("talker")
+	seq, err := strconv.ParseInt(c.Param("seq"), 10, 64)
+	if talker == "" || err != nil || seq <= 0 {
+		errors.Err(c, errors.InvalidArg("seq"))
+		return "", 0, false
+	}
+	return talker, seq, true
+}
+
+// findMessage 收藏或备注对应的消息，重新解密后消息已不存在时返回 nil
+func (s *Service) findMessage(c *gin.Context, talker string, seq int64) *messageDetail {
+	message, err := s.db.GetMessage(c.Request.Context(), talker, seq)
+	if err != nil {
+		return nil
+	}
+	message.SetContent("host", hostOf(c))
+	setLang([]*model.Message{message}, langOf(c.Request))
+	message.SetMediaURLs(mediaPrefix(c))
+	return &messageDetail{Message: message, Text: message.PlainTextContent()}
+}
+
+// maxContextSize 上下文单侧最多返回的消息数
+const maxContextSize = 500
+
+// GetMessageContext 获取指定消息前后的消息，用于从搜索结果跳转到原始对话
+func (s *Service) GetMessageContext(c *gin.Context) {
+	q := struct {
+		Talker string `form:"talker"`
+		Seq    int64  `form:"seq"`
+		Before int    `form:"before,default=20"`
+		After  int    `form:"after,default=20"`
+		Format string `form:"format"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if q.Talker == "" {
+		errors.Err(c, errors.ErrTalkerEmpty)
+		return
+	}
+	if q.Seq <= 0 {
+		errors.Err(c, errors.InvalidArg("seq"))
+		return
+	}
+	if q.Before < 0 || q.Before > maxContextSize {
+		errors.Err(c, errors.InvalidArg("before"))
+		return
+	}
+	if q.After < 0 || q.After > maxContextSize {
+		errors.Err(c, errors.InvalidArg("after"))
+		return
+	}
+
+	messages, index, err := s.db.GetMessageContext(c.Request.Context(), q.Talker, q.Seq, q.Before, q.After)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	switch strings.ToLower(q.Format) {
+	case "json":
+		setMediaURLs(c, messages)
+		s.annotate(c, messages)
+		c.JSON(http.StatusOK, gin.H{
+			"items":  messages,
+			"anchor": index,
+		})
+	case "jsonl", "ndjson":
+		// 逐行输出时以 X-Anchor 响应头标记目标消息的位置
+		setMediaURLs(c, messages)
+		s.annotate(c, messages)
+		c.Header("X-Anchor", strconv.Itoa(index))
+		writeJSONL(c, messages)
+	default:
+		c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		setLang(messages, langOf(c.Request))
+		for i, m := range messages {
+			if i == index {
+				c.Writer.WriteString("> ")
+			}
+			c.Writer.WriteString(m.PlainText(false, "2006-01-02 15:04:05", hostOf(c)))
+			c.Writer.WriteString("\n")
+		}
+	}
+}
+
+// messagePosition 某个日期之后第一条消息的位置
+type messagePosition struct {
+	Talker string    `json:"talker"`
+	Seq    int64     `json:"seq"`
+	Time   time.Time `json:"time"`
+	Offset int       `json:"offset"` // 按时间升序排列时的位置，可作为 /chatlog、/conversation 的 offset 参数（time=all）
+}
+
+// GetMessageJump 查找指定日期当天或之后的第一条消息，用于跳转到某个日期
+func (s *Service) GetMessageJump(c *gin.Context) {
+	q := struct {
+		Talker string `form:"talker"`
+		Date   string `form:"date"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if q.Talker == "" || strings.Contains(q.Talker, ",") {
+		errors.Err(c, errors.ErrTalkerEmpty)
+		return
+	}
+	date, ok := util.TimeOf(q.Date)
+	if !ok {
+		errors.Err(c, errors.InvalidArg("date"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	start, end, _ := util.TimeRangeOf("all")
+	messages, err := s.db.GetMessages(ctx, date, end, q.Talker, "", "", "", false, 1, 0)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if len(messages) == 0 {
+		errors.Err(c, errors.TimeRangeNotFound(date, end))
+		return
+	}
+	offset := 0
+	if date.After(start) {
+		// 消息时间精确到秒，统计该日期之前一秒及更早的消息
+		if offset, err = s.db.CountMessages(ctx, start, date.Add(-time.Second), q.Talker); err != nil {
+			errors.Err(c, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, &messagePosition{
+		Talker: messages[0].Talker,
+		Seq:    messages[0].Seq,
+		Time:   messages[0].Time,
+		Offset: offset,
+	})
+}
+
+// calendar 一年中每天的消息数量
+type calendar struct {
+	Talker string              `json:"talker,omitempty"`
+	Year   int                 `json:"year"`
+	Total  int                 `json:"total"`
+	Max    int                 `json:"max"` // 单日最多的消息数，用于计算热力图的颜色深浅
+	Days   []analysis.DayCount `json:"days"`
+}
+
+// GetMessageCalendar 按天统计一年中的消息数量，用于日期选择器中标记有聊天记录的日期
+func (s *Service) GetMessageCalendar(c *gin.Context) {
+	q := struct {
+		Talker string `form:"talker"`
+		Year   int    `form:"year"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if q.Year == 0 {
+		q.Year = time.Now().Year()
+	}
+	if q.Year < 1970 || q.Year > 9999 {
+		errors.Err(c, errors.InvalidArg("year"))
+		return
+	}
+
+	start := time.Date(q.Year, 1, 1, 0, 0, 0, 0, time.Local)
+	end := start.AddDate(1, 0, 0).Add(-time.Nanosecond)
+	stats, err := s.db.GetStats(c.Request.Context(), start, end, q.Talker)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	ret := &calendar{Talker: q.Talker, Year: q.Year, Days: analysis.CalendarOf(stats)}
+	for _, day := range ret.Days {
+		ret.Total += day.Count
+		ret.Max = max(ret.Max, day.Count)
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// mediaPrefix 多媒体地址前缀，使用客户端访问的地址，指定账号时带有 /account/<账号> 前缀
+func mediaPrefix(c *gin.Context) string {
+	if c.Request.TLS != nil {
+		return "https://" + hostOf(c)
+	}
+	return "http://" + hostOf(c)
+}
+
+// setMediaURLs 为 JSON 输出的消息补充多媒体地址
+func setMediaURLs(c *gin.Context, messages []*model.Message) {
+	prefix := mediaPrefix(c)
+	for _, m := range messages {
+		m.SetMediaURLs(prefix)
+	}
+}
+
+// inlineImages 将小于 maxSize 的图片以 data URI 形式内嵌到消息中，mask 不为空时内嵌遮盖后的图片
+func (s *Service) inlineImages(ctx context.Context, messages []*model.Message, maxSize int64, mask string) {
+	for _, m := range messages {
+		if m.Type != 3 {
+			continue
+		}
+		media := s.resolveMedia(ctx, m)
+		if media == nil {
+			continue
+		}
+		path, err := s.dataPath(mediaCtx(ctx, media), media.Path)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.Size() > maxSize {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		mime := "image/jpeg"
+		if strings.ToLower(filepath.Ext(path)) == ".dat" {
+			out, ext, err := decodeImage(ctx, data)
+			if err != nil {
+				continue
+			}
+			data, mime = out, imageMIME(ext)
+		} else if t := http.DetectContentType(data); strings.HasPrefix(t, "image/") {
+			mime = t
+		}
+		if mask != "" {
+			if data, mime, err = redact.Image(data, mask); err != nil {
+				continue
+			}
+		}
+		if int64(len(data)) > maxSize {
+			continue
+		}
+		m.MediaData = "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data)
+	}
+}
+
+// imageMIME dat2img 解码结果对应的 Content-Type
+func imageMIME(ext string) string {
+	switch ext {
+	case "png":
+		return "image/png"
+	case "gif":
+		return "image/gif"
+	case "bmp":
+		return "image/bmp"
+	default:
+		return "image/jpeg"
+	}
+}
+
+// resolveMedia 查找多媒体消息对应的文件，规则与 GetMedia 一致
+func (s *Service) resolveMedia(ctx context.Context, m *model.Message) *model.Media {
+	_type, keys := m.MediaKeys()
+	for _, k := range keys {
+		if len(k) != 32 {
+			if _, err := s.dataPath(ctx, k); err != nil {
+				continue
+			}
+			return &model.Media{Type: _type, Path: k, Name: filepath.Base(k)}
+		}
+		media, err := s.db.GetMedia(ctx, _type, k)
+		if err != nil {
+			continue
+		}
+		// 语音数据直接内嵌在数据库中，详情中不返回
+		media.Data = nil
+		return media
+	}
+	return nil
+}
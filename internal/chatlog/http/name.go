@@ -0,0 +1,67 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/chatlog/sidecar"
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// displayNameBody 显示名称的请求体
+type displayNameBody struct {
+	Name string `json:"name"`
+}
+
+// ListDisplayNames 按名称列出自定义显示名称
+func (s *Service) ListDisplayNames(c *gin.Context) {
+	ctx := c.Request.Context()
+	names, err := s.db.Sidecar().ListDisplayNames(ctx, s.db.AccountName(ctx))
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": names, "total": len(names)})
+}
+
+// PutDisplayName 设置联系人或群聊的显示名称，优先于备注与昵称用于聊天记录、导出与会话列表；名称为空时删除
+// 请求体：{"name": "老王"}
+func (s *Service) PutDisplayName(c *gin.Context) {
+	var req displayNameBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors.Err(c, errors.InvalidArg("body"))
+		return
+	}
+	ctx := c.Request.Context()
+	subject, err := s.findSubject(ctx, c.Param("talker"), false)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	n := &sidecar.DisplayName{Talker: subject.Talker, Name: req.Name}
+	if err := s.db.Sidecar().SetDisplayName(ctx, s.db.AccountName(ctx), n); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if n.Name == "" {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, n)
+}
+
+// DeleteDisplayName 删除显示名称，恢复使用备注与昵称
+func (s *Service) DeleteDisplayName(c *gin.Context) {
+	ctx := c.Request.Context()
+	subject, err := s.findSubject(ctx, c.Param("talker"), false)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if err := s.db.Sidecar().DeleteDisplayName(ctx, s.db.AccountName(ctx), subject.Talker); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,409 @@
+package http
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/chatlog/analysis"
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/chatlog/job"
+	"github.com/sjzar/chatlog/internal/chatlog/sidecar"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/internal/wechatdb"
+	"github.com/sjzar/chatlog/pkg/graphql"
+)
+
+// apiParam 接口参数描述
+type apiParam struct {
+	Name     string
+	In       string // query | path
+	Type     string // string | integer | boolean
+	Desc     string
+	Required bool
+	Enum     []string
+}
+
+// apiOperation 接口描述，用于生成 OpenAPI 文档
+type apiOperation struct {
+	Method  string
+	Path    string
+	Tag     string
+	Summary string
+	Params  []apiParam
+	Body    interface{} // 请求体示例类型
+	Result  interface{} // 响应类型，nil 表示通用 JSON 对象
+	Content string      // 非 JSON 响应的 Content-Type
+	Status  int         // 成功状态码，默认 200
+}
+
+var (
+	pTime     = apiParam{Name: "time", In: "query", Type: "string", Desc: "时间范围，如 2024-01-01、2024-01-01~2024-01-31、last-7d、yesterday、all"}
+	pTalker   = apiParam{Name: "talker", In: "query", Type: "string", Desc: "聊天对象，微信 ID、群 ID 或名称，多个以逗号分隔，tag:名称 表示带有该标签的会话"}
+	pSender   = apiParam{Name: "sender", In: "query", Type: "string", Desc: "发送人"}
+	pKeyword  = apiParam{Name: "keyword", In: "query", Type: "string", Desc: "关键词"}
+	pType     = apiParam{Name: "type", In: "query", Type: "string", Desc: "消息类型，多个以逗号分隔：text、image、voice、video、card、emoji、location、appmsg、link、file、forward、miniapp、channels、quote、pat、transfer、voip、system，或数字形式如 3、49:6"}
+	pLimit    = apiParam{Name: "limit", In: "query", Type: "integer", Desc: "返回条数"}
+	pOffset   = apiParam{Name: "offset", In: "query", Type: "integer", Desc: "偏移量"}
+	pFormat   = apiParam{Name: "format", In: "query", Type: "string", Desc: "输出格式，默认为 text", Enum: []string{"json", "jsonl", "csv", "text"}}
+	pFields   = apiParam{Name: "fields", In: "query", Type: "string", Desc: "只返回指定字段，多个以逗号分隔，支持 contents.md5 形式的嵌套字段"}
+	pRefresh  = apiParam{Name: "refresh", In: "query", Type: "boolean", Desc: "跳过缓存重新计算"}
+	pDate     = apiParam{Name: "date", In: "query", Type: "string", Desc: "日期，格式 2006-01-02，默认为今天"}
+	pMediaKey = apiParam{Name: "key", In: "path", Type: "string", Desc: "多媒体 key 或相对路径", Required: true}
+	pJobID    = apiParam{Name: "id", In: "path", Type: "string", Desc: "任务 ID", Required: true}
+
+	pBefore      = apiParam{Name: "before", In: "query", Type: "string", Desc: "删除此前的消息，保留期限如 180d、26w、6m、1y，或截止日期如 2024-01-01"}
+	pPruneTalker = apiParam{Name: "talker", In: "query", Type: "string", Desc: "只清理这些会话，须为完整的 wxid 或群聊 ID，多个以逗号分隔；与 before 至少指定一个"}
+	pContactKey  = apiParam{Name: "key", In: "path", Type: "string", Desc: "微信 ID、群 ID、微信号、备注或昵称", Required: true}
+	pContactID   = apiParam{Name: "key", In: "path", Type: "string", Desc: "完整的微信 ID 或群 ID", Required: true}
+	pTalkerPath  = apiParam{Name: "talker", In: "path", Type: "string", Desc: "聊天对象，微信 ID、群 ID 或名称"}
+	pIdentity    = apiParam{Name: "id", In: "path", Type: "string", Desc: "身份 ID，即合并后使用的微信 ID"}
+	pTagName     = apiParam{Name: "tag", In: "path", Type: "string", Desc: "标签名称，不能包含逗号"}
+	pAnnotations = apiParam{Name: "include_annotations", In: "query", Type: "boolean", Desc: "JSON 输出时在 annotation 字段附带消息的备注与标签"}
+	pMessageKey  = []apiParam{{Name: "talker", In: "path", Type: "string", Desc: "聊天对象", Required: true}, {Name: "seq", In: "path", Type: "integer", Desc: "消息序号", Required: true}}
+	pImages      = apiParam{Name: "images", In: "query", Type: "string", Desc: "图片遮盖方式：blur 模糊、replace 替换为占位图；脱敏时默认使用 http.image_mask"}
+
+	// pAccount 除 meta 外的所有接口都可以指定账号
+	pAccount = apiParam{Name: "account", In: "query", Type: "string", Desc: "查询的账号，见 /api/v1/accounts，默认为当前账号；以逗号分隔多个账号或为 all 时将聊天记录合并为一条时间线"}
+)
+
+// apiOperations 所有对外接口
+var apiOperations = []apiOperation{
+	{Method: "GET", Path: "/image/{key}", Tag: "media", Summary: "获取图片", Params: []apiParam{pMediaKey, pImages}, Content: "image/*"},
+	{Method: "GET", Path: "/video/{key}", Tag: "media", Summary: "获取视频", Params: []apiParam{pMediaKey}, Content: "video/*"},
+	{Method: "GET", Path: "/file/{key}", Tag: "media", Summary: "获取文件", Params: []apiParam{pMediaKey}, Content: "application/octet-stream"},
+	{Method: "GET", Path: "/voice/{key}", Tag: "media", Summary: "获取语音，转码为 MP3", Params: []apiParam{pMediaKey}, Content: "audio/mp3"},
+	{Method: "GET", Path: "/data/{path}", Tag: "media", Summary: "按数据目录相对路径获取文件", Params: []apiParam{{Name: "path", In: "path", Type: "string", Desc: "数据目录下的相对路径", Required: true}, pImages}, Content: "application/octet-stream"},
+
+	{Method: "GET", Path: "/sse", Tag: "mcp", Summary: "MCP SSE 连接", Content: "text/event-stream"},
+	{Method: "POST", Path: "/messages", Tag: "mcp", Summary: "MCP 消息", Params: []apiParam{{Name: "sessionId", In: "query", Type: "string", Desc: "SSE 会话 ID", Required: true}}},
+
+	{Method: "GET", Path: "/api/v1/openapi.json", Tag: "meta", Summary: "OpenAPI 文档"},
+	{Method: "GET", Path: "/api/v1/server/info", Tag: "meta", Summary: "服务的访问地址，绑定局域网地址时包含局域网地址、mDNS 地址与扫码访问的二维码", Result: ServerInfo{}},
+	{Method: "GET", Path: "/api/v1/accounts", Tag: "meta", Summary: "列出可查询的账号：当前账号与解密过且工作目录仍存在的账号", Result: struct {
+		Items []*database.Account `json:"items"`
+	}{}},
+	{Method: "POST", Path: "/login", Tag: "meta", Summary: "登录并写入会话 Cookie（配置 http.auth.password 后启用）", Params: []apiParam{
+		{Name: "username", In: "query", Type: "string", Desc: "用户名，也可通过表单或 JSON 请求体提交"},
+		{Name: "password", In: "query", Type: "string", Desc: "密码"},
+		{Name: "next", In: "query", Type: "string", Desc: "登录后跳转的站内路径"},
+	}},
+	{Method: "POST", Path: "/logout", Tag: "meta", Summary: "退出登录"},
+	{Method: "GET", Path: "/api/v1/lock", Tag: "meta", Summary: "查询空闲锁定状态（配置 http.lock.idle 后启用）"},
+	{Method: "POST", Path: "/api/v1/lock", Tag: "meta", Summary: "立即锁定，关闭数据库并清空缓存"},
+	{Method: "POST", Path: "/api/v1/unlock", Tag: "meta", Summary: "输入口令解锁", Body: struct {
+		Passphrase string `json:"passphrase"`
+	}{}},
+	{Method: "GET", Path: "/api/v1/admin/audit", Tag: "meta", Summary: "查询访问审计日志（配置 http.audit.enabled 后启用）", Params: []apiParam{pTime,
+		{Name: "path", In: "query", Type: "string", Desc: "接口路径前缀，如 /api/v1/chatlog、/messages"},
+		{Name: "client", In: "query", Type: "string", Desc: "客户端 IP"},
+		{Name: "user", In: "query", Type: "string", Desc: "登录用户名或客户端证书名称"},
+		pLimit, pOffset}},
+
+	{Method: "GET", Path: "/api/v1/chatlog", Tag: "data", Summary: "查询聊天记录", Params: []apiParam{pTime, pTalker, pSender, pKeyword, pType, {Name: "order", In: "query", Type: "string", Desc: "排序方式，默认为 asc", Enum: []string{"asc", "desc"}}, pLimit, pOffset, pFormat, pFields,
+		{Name: "inline_media", In: "query", Type: "boolean", Desc: "format=json 时将较小的图片以 base64 data URI 内嵌到 mediaData 字段"},
+		{Name: "dedup", In: "query", Type: "boolean", Desc: "合并多个账号时去除不同账号中的重复消息"}, {Name: "dedup_window", In: "query", Type: "integer", Desc: "去重的时间窗口（秒），默认 5"}, pAnnotations}, Result: []*model.Message{}},
+	{Method: "GET", Path: "/api/v1/chatlog/context", Tag: "data", Summary: "获取指定消息前后的消息", Params: []apiParam{{Name: "talker", In: "query", Type: "string", Desc: "聊天对象", Required: true}, {Name: "seq", In: "query", Type: "integer", Desc: "消息序号", Required: true},
+		{Name: "before", In: "query", Type: "integer", Desc: "之前的消息数，默认 20"}, {Name: "after", In: "query", Type: "integer", Desc: "之后的消息数，默认 20"}, {Name: "format", In: "query", Type: "string", Desc: "输出格式，默认为 text", Enum: []string{"json", "jsonl", "text"}}, pFields, pAnnotations}},
+	{Method: "GET", Path: "/api/v1/chatlog/jump", Tag: "data", Summary: "查找指定日期当天或之后的第一条消息，返回消息序号与位置", Params: []apiParam{{Name: "talker", In: "query", Type: "string", Desc: "聊天对象，微信 ID 或群 ID", Required: true},
+		{Name: "date", In: "query", Type: "string", Desc: "日期，如 2021-03、2021-03-01", Required: true}}, Result: messagePosition{}},
+	{Method: "GET", Path: "/api/v1/chatlog/calendar", Tag: "data", Summary: "按天统计一年中的消息数量，只返回有消息的日期", Params: []apiParam{{Name: "talker", In: "query", Type: "string", Desc: "聊天对象，为空时统计所有会话"},
+		{Name: "year", In: "query", Type: "integer", Desc: "年份，默认为今年"}}, Result: calendar{}},
+	{Method: "GET", Path: "/api/v1/message/{talker}/{seq}", Tag: "data", Summary: "按消息序号获取单条消息", Params: []apiParam{{Name: "talker", In: "path", Type: "string", Desc: "聊天对象"}, {Name: "seq", In: "path", Type: "integer", Desc: "消息序号"}, pAnnotations}, Result: messageDetail{}},
+	{Method: "GET", Path: "/api/v1/conversation", Tag: "data", Summary: "会话视图，消息附带发送人名称、头像、解析后文本与多媒体地址", Params: []apiParam{{Name: "talker", In: "query", Type: "string", Desc: "聊天对象，微信 ID 或群 ID", Required: true}, pTime,
+		{Name: "order", In: "query", Type: "string", Desc: "排序方式，默认为 asc", Enum: []string{"asc", "desc"}}, {Name: "limit", In: "query", Type: "integer", Desc: "返回数量，默认 100，最大 1000"}, pOffset, pAnnotations}, Result: conversation{}},
+	{Method: "GET", Path: "/api/v1/media/wall", Tag: "data", Summary: "照片墙，按月分组返回会话中的图片与缩略图地址", Params: []apiParam{{Name: "talker", In: "query", Type: "string", Desc: "聊天对象，微信 ID 或群 ID", Required: true}, {Name: "time", In: "query", Type: "string", Desc: "时间范围，默认为 all"},
+		{Name: "order", In: "query", Type: "string", Desc: "排序方式，默认为 desc（最新的图片在前）", Enum: []string{"asc", "desc"}}, {Name: "limit", In: "query", Type: "integer", Desc: "每页图片数，默认 100，最大 500"}, pOffset}, Result: wall{}},
+	{Method: "GET", Path: "/api/v1/contact", Tag: "data", Summary: "查询联系人", Params: []apiParam{pKeyword, pLimit, pOffset, pFormat, pFields}, Result: wechatdb.GetContactsResp{}},
+	{Method: "GET", Path: "/api/v1/contact/{key}/export", Tag: "data", Summary: "导出与联系人或群聊相关的全部数据为 ZIP（资料、聊天记录、图片、视频、语音、文件）", Params: []apiParam{pContactKey,
+		{Name: "groups", In: "query", Type: "boolean", Desc: "同时导出该联系人在共同群聊中发送的消息"}}, Content: "application/zip"},
+	{Method: "DELETE", Path: "/api/v1/contact/{key}", Tag: "data", Summary: "从解密后的工作目录中删除与联系人或群聊的聊天记录、联系人与最近会话记录", Params: []apiParam{pContactID}, Result: struct {
+		Talker   string `json:"talker"`
+		Messages int    `json:"messages"`
+	}{}},
+	{Method: "GET", Path: "/api/v1/prune", Tag: "data", Summary: "预览清理结果：列出将从工作目录中删除的消息数量", Params: []apiParam{pBefore, pPruneTalker}, Result: database.PruneReport{}},
+	{Method: "POST", Path: "/api/v1/prune", Tag: "data", Summary: "从解密后的工作目录中删除过期或指定会话的消息及语音", Params: []apiParam{pBefore, pPruneTalker}, Result: database.PruneReport{}},
+	{Method: "GET", Path: "/api/v1/chatroom", Tag: "data", Summary: "查询群聊", Params: []apiParam{pKeyword, pLimit, pOffset, pFormat, pFields}, Result: wechatdb.GetChatRoomsResp{}},
+	{Method: "GET", Path: "/api/v1/session", Tag: "data", Summary: "查询最近会话", Params: []apiParam{pKeyword, pLimit, pOffset, pFormat, pFields}, Result: wechatdb.GetSessionsResp{}},
+	{Method: "GET", Path: "/api/v1/events", Tag: "data", Summary: "会话更新事件（SSE）", Content: "text/event-stream"},
+	{Method: "GET", Path: "/feed/{talker}.atom", Tag: "data", Summary: "会话 Atom 订阅源", Params: []apiParam{
+		{Name: "talker", In: "path", Type: "string", Desc: "聊天对象 ID", Required: true},
+		{Name: "mode", In: "query", Type: "string", Desc: "条目类型", Enum: []string{"messages", "daily"}},
+		{Name: "limit", In: "query", Type: "integer", Desc: "消息条数，默认 50"},
+		{Name: "days", In: "query", Type: "integer", Desc: "摘要天数，默认 7"},
+	}, Content: "application/atom+xml"},
+	{Method: "GET", Path: "/api/v1/links", Tag: "data", Summary: "提取聊天中分享的链接", Params: []apiParam{pTime, pTalker, pSender, {Name: "format", In: "query", Type: "string", Desc: "输出格式，默认为 json", Enum: []string{"json", "jsonl", "csv", "html"}}, pFields}, Result: []*SharedLink{}},
+
+	{Method: "GET", Path: "/api/v1/analysis/report", Tag: "analysis", Summary: "读取最新生成的分析报告", Result: analysis.Report{}},
+	{Method: "POST", Path: "/api/v1/analysis/report", Tag: "analysis", Summary: "提交报告生成任务", Params: []apiParam{pTime, pTalker}, Result: job.Job{}, Status: http.StatusAccepted},
+	{Method: "GET", Path: "/api/v1/analysis/stats", Tag: "analysis", Summary: "数据总量统计", Params: []apiParam{pRefresh}},
+	{Method: "GET", Path: "/api/v1/analysis/export", Tag: "analysis", Summary: "导出基础数据为 CSV", Params: []apiParam{{Name: "type", In: "query", Type: "string", Desc: "导出类型", Required: true, Enum: []string{"sessions", "contacts", "chatrooms"}}}, Content: "text/csv"},
+	{Method: "GET", Path: "/api/v1/analysis/files", Tag: "analysis", Summary: "列出报告目录中的文件"},
+	{Method: "GET", Path: "/api/v1/analysis/download", Tag: "analysis", Summary: "下载报告文件，目录以 ZIP 打包", Params: []apiParam{{Name: "file", In: "query", Type: "string", Desc: "文件名"}, {Name: "folder", In: "query", Type: "string", Desc: "目录名"}}, Content: "application/octet-stream"},
+	{Method: "GET", Path: "/api/v1/analysis/search", Tag: "analysis", Summary: "跨会话搜索消息", Params: []apiParam{{Name: "keyword", In: "query", Type: "string", Desc: "关键词", Required: true}, {Name: "days", In: "query", Type: "integer", Desc: "最近天数，默认 7"}}},
+	{Method: "GET", Path: "/api/v1/analysis/chatroom", Tag: "analysis", Summary: "群聊历史统计", Params: []apiParam{{Name: "talker", In: "query", Type: "string", Desc: "群 ID", Required: true}, {Name: "days", In: "query", Type: "integer", Desc: "最近天数，默认 30"}, pRefresh}},
+	{Method: "GET", Path: "/api/v1/analysis/daily-summary", Tag: "analysis", Summary: "群聊每日或区间总结", Params: []apiParam{pDate, pTime, pTalker, pRefresh}},
+	{Method: "GET", Path: "/api/v1/analysis/golden-quotes", Tag: "analysis", Summary: "金句摘录", Params: []apiParam{pDate, pTalker, pRefresh}},
+	{Method: "GET", Path: "/api/v1/analysis/compare", Tag: "analysis", Summary: "对比两个会话或时间段", Params: []apiParam{pTalker, pTime,
+		{Name: "talker_a", In: "query", Type: "string", Desc: "A 组聊天对象"}, {Name: "time_a", In: "query", Type: "string", Desc: "A 组时间范围"},
+		{Name: "talker_b", In: "query", Type: "string", Desc: "B 组聊天对象"}, {Name: "time_b", In: "query", Type: "string", Desc: "B 组时间范围"}, pRefresh}},
+	{Method: "GET", Path: "/api/v1/analysis/profile", Tag: "analysis", Summary: "联系人画像", Params: []apiParam{{Name: "talker", In: "query", Type: "string", Desc: "联系人", Required: true}, pTime, {Name: "summary", In: "query", Type: "boolean", Desc: "调用 LLM 生成文字总结"}, pRefresh}},
+	{Method: "GET", Path: "/api/v1/analysis/digest", Tag: "analysis", Summary: "预览邮件摘要", Params: []apiParam{pTime, pTalker, pRefresh}, Content: "text/html"},
+	{Method: "GET", Path: "/api/v1/analysis/pii", Tag: "analysis", Summary: "扫描疑似敏感信息（手机号、身份证号、银行卡号、地址、密码）", Params: []apiParam{pTime, pTalker, pSender, {Name: "kind", In: "query", Type: "string", Desc: "只报告指定类型，多个以逗号分隔：phone、id_card、bank_card、address、password"}, pLimit, pOffset, pFields}, Result: []*PIIItem{}},
+	{Method: "GET", Path: "/api/v1/analysis/corpus", Tag: "analysis", Summary: "导出匿名语料（JSON Lines），会话与发言人为固定化名，正文脱敏，多媒体替换为占位符，时间随机偏移", Params: []apiParam{pTime, pTalker,
+		{Name: "salt", In: "query", Type: "string", Desc: "化名使用的 salt，相同 salt 的多次导出化名一致，默认每次随机"},
+		{Name: "jitter", In: "query", Type: "integer", Desc: "每个会话时间整体随机偏移的最大天数，默认 30，0 为不偏移"},
+		{Name: "media", In: "query", Type: "boolean", Desc: "为 0 时丢弃多媒体消息，默认保留为 <image> 等占位符"}}, Result: []analysis.CorpusRecord{}, Content: "application/x-ndjson"},
+
+	{Method: "GET", Path: "/api/v1/bookmarks", Tag: "bookmarks", Summary: "按收藏时间倒序列出收藏的消息", Params: []apiParam{{Name: "talker", In: "query", Type: "string", Desc: "只列出该会话中收藏的消息"},
+		{Name: "messages", In: "query", Type: "boolean", Desc: "附带消息内容，默认为 true"}, pLimit, pOffset}, Result: struct {
+		Items []*bookmarkItem `json:"items"`
+		Total int             `json:"total"`
+	}{}},
+	{Method: "POST", Path: "/api/v1/bookmarks", Tag: "bookmarks", Summary: "收藏消息，已收藏时更新备注", Body: struct {
+		Talker string `json:"talker"`
+		Seq    int64  `json:"seq"`
+		Note   string `json:"note"`
+	}{}, Result: sidecar.Bookmark{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/api/v1/bookmarks/{talker}/{seq}", Tag: "bookmarks", Summary: "查询收藏", Params: pMessageKey, Result: bookmarkItem{}},
+	{Method: "PUT", Path: "/api/v1/bookmarks/{talker}/{seq}", Tag: "bookmarks", Summary: "修改收藏的备注", Params: pMessageKey, Body: struct {
+		Note string `json:"note"`
+	}{}, Result: sidecar.Bookmark{}},
+	{Method: "DELETE", Path: "/api/v1/bookmarks/{talker}/{seq}", Tag: "bookmarks", Summary: "取消收藏", Params: pMessageKey, Status: http.StatusNoContent},
+
+	{Method: "GET", Path: "/api/v1/annotations", Tag: "annotations", Summary: "按修改时间倒序列出消息备注", Params: []apiParam{{Name: "talker", In: "query", Type: "string", Desc: "只列出该会话中的备注"},
+		{Name: "tag", In: "query", Type: "string", Desc: "只列出带有该标签的备注"}, {Name: "messages", In: "query", Type: "boolean", Desc: "附带消息内容，默认为 true"}, pLimit, pOffset}, Result: struct {
+		Items []*annotationItem `json:"items"`
+		Total int               `json:"total"`
+	}{}},
+	{Method: "GET", Path: "/api/v1/annotations/{talker}/{seq}", Tag: "annotations", Summary: "查询消息的备注与标签", Params: pMessageKey, Result: annotationItem{}},
+	{Method: "PUT", Path: "/api/v1/annotations/{talker}/{seq}", Tag: "annotations", Summary: "设置消息的备注与标签，覆盖已有内容，都为空时删除", Params: pMessageKey, Body: annotationBody{}, Result: model.Annotation{}},
+	{Method: "DELETE", Path: "/api/v1/annotations/{talker}/{seq}", Tag: "annotations", Summary: "删除消息的备注与标签", Params: pMessageKey, Status: http.StatusNoContent},
+
+	{Method: "GET", Path: "/api/v1/tags", Tag: "tags", Summary: "按名称列出会话标签", Params: []apiParam{{Name: "talker", In: "query", Type: "string", Desc: "只列出该会话的标签"}}, Result: struct {
+		Items []*sidecar.Tag `json:"items"`
+		Total int            `json:"total"`
+	}{}},
+	{Method: "GET", Path: "/api/v1/tags/{tag}", Tag: "tags", Summary: "查询带有标签的会话", Params: []apiParam{pTagName}, Result: tagDetail{}},
+	{Method: "PUT", Path: "/api/v1/tags/{tag}", Tag: "tags", Summary: "设置带有标签的会话，替换原有的会话", Params: []apiParam{pTagName}, Body: tagBody{}, Result: sidecar.Tag{}},
+	{Method: "POST", Path: "/api/v1/tags/{tag}", Tag: "tags", Summary: "为会话添加标签", Params: []apiParam{pTagName}, Body: tagBody{}, Result: sidecar.Tag{}},
+	{Method: "DELETE", Path: "/api/v1/tags/{tag}", Tag: "tags", Summary: "删除标签", Params: []apiParam{pTagName}, Status: http.StatusNoContent},
+	{Method: "DELETE", Path: "/api/v1/tags/{tag}/{talker}", Tag: "tags", Summary: "移除会话的标签", Params: []apiParam{pTagName, pTalkerPath}, Status: http.StatusNoContent},
+
+	{Method: "GET", Path: "/api/v1/names", Tag: "names", Summary: "列出自定义显示名称", Result: struct {
+		Items []*sidecar.DisplayName `json:"items"`
+		Total int                    `json:"total"`
+	}{}},
+	{Method: "PUT", Path: "/api/v1/names/{talker}", Tag: "names", Summary: "设置联系人或群聊的显示名称，优先于备注与昵称，为空时删除", Params: []apiParam{pTalkerPath}, Body: displayNameBody{}, Result: sidecar.DisplayName{}},
+	{Method: "DELETE", Path: "/api/v1/names/{talker}", Tag: "names", Summary: "删除显示名称", Params: []apiParam{pTalkerPath}, Status: http.StatusNoContent},
+
+	{Method: "GET", Path: "/api/v1/identities", Tag: "identities", Summary: "列出合并的联系人身份", Result: struct {
+		Items []*sidecar.Identity `json:"items"`
+		Total int                 `json:"total"`
+	}{}},
+	{Method: "GET", Path: "/api/v1/identities/{id}", Tag: "identities", Summary: "查询身份合并的账号", Params: []apiParam{pIdentity}, Result: identityDetail{}},
+	{Method: "POST", Path: "/api/v1/identities/{id}", Tag: "identities", Summary: "将账号合并到身份，查询、统计与导出时视为同一个人", Params: []apiParam{pIdentity}, Body: identityBody{}, Result: sidecar.Identity{}},
+	{Method: "DELETE", Path: "/api/v1/identities/{id}", Tag: "identities", Summary: "拆分整个身份", Params: []apiParam{pIdentity}, Status: http.StatusNoContent},
+	{Method: "DELETE", Path: "/api/v1/identities/{id}/{alias}", Tag: "identities", Summary: "从身份中拆分一个账号", Params: []apiParam{pIdentity, {Name: "alias", In: "path", Type: "string", Desc: "合并的账号"}}, Status: http.StatusNoContent},
+
+	{Method: "POST", Path: "/api/v1/batch", Tag: "data", Summary: "批量执行多个查询", Body: struct {
+		Requests []batchRequest `json:"requests"`
+	}{}, Result: struct {
+		Items []batchResponse `json:"items"`
+	}{}},
+
+	{Method: "POST", Path: "/graphql", Tag: "data", Summary: "GraphQL 查询", Body: graphqlRequest{}, Result: graphql.Result{}},
+
+	{Method: "POST", Path: "/api/v1/jobs", Tag: "jobs", Summary: "提交后台任务", Body: struct {
+		Type   string            `json:"type"`
+		Params map[string]string `json:"params"`
+	}{}, Result: job.Job{}, Status: http.StatusAccepted},
+	{Method: "GET", Path: "/api/v1/jobs", Tag: "jobs", Summary: "列出任务"},
+	{Method: "GET", Path: "/api/v1/jobs/{id}", Tag: "jobs", Summary: "查询任务状态", Params: []apiParam{pJobID}, Result: job.Job{}},
+	{Method: "DELETE", Path: "/api/v1/jobs/{id}", Tag: "jobs", Summary: "取消任务", Params: []apiParam{pJobID}, Result: job.Job{}},
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  gin.H
+)
+
+// GetOpenAPI 返回 OpenAPI 3 文档
+func (s *Service) GetOpenAPI(c *gin.Context) {
+	openAPIOnce.Do(func() {
+		openAPIDoc = buildOpenAPI(apiOperations)
+	})
+	c.JSON(http.StatusOK, openAPIDoc)
+}
+
+// buildOpenAPI 根据接口描述生成 OpenAPI 文档，响应结构由 Go 类型反射得到
+func buildOpenAPI(ops []apiOperation) gin.H {
+	g := &schemaGen{schemas: gin.H{}}
+	g.schemas["Error"] = g.schemaOf(reflect.TypeOf(struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}{}))
+
+	paths := gin.H{}
+	for _, op := range ops {
+		item, ok := paths[op.Path].(gin.H)
+		if !ok {
+			item = gin.H{}
+			paths[op.Path] = item
+		}
+
+		opParams := op.Params
+		if op.Tag != "meta" {
+			opParams = append(opParams[:len(opParams):len(opParams)], pAccount)
+		}
+		params := make([]gin.H, 0, len(opParams))
+		for _, p := range opParams {
+			schema := gin.H{"type": p.Type}
+			if len(p.Enum) > 0 {
+				schema["enum"] = p.Enum
+			}
+			params = append(params, gin.H{
+				"name":        p.Name,
+				"in":          p.In,
+				"description": p.Desc,
+				"required":    p.Required || p.In == "path",
+				"schema":      schema,
+			})
+		}
+
+		var resp gin.H
+		switch {
+		case op.Content != "":
+			resp = gin.H{"description": "OK", "content": gin.H{op.Content: gin.H{"schema": gin.H{"type": "string", "format": "binary"}}}}
+		case op.Result != nil:
+			resp = gin.H{"description": "OK", "content": gin.H{"application/json": gin.H{"schema": g.schemaOf(reflect.TypeOf(op.Result))}}}
+		default:
+			resp = gin.H{"description": "OK", "content": gin.H{"application/json": gin.H{"schema": gin.H{"type": "object"}}}}
+		}
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		operation := gin.H{
+			"tags":       []string{op.Tag},
+			"summary":    op.Summary,
+			"parameters": params,
+			"responses": gin.H{
+				strconv.Itoa(status): resp,
+				"default": gin.H{
+					"description": "Error",
+					"content":     gin.H{"application/json": gin.H{"schema": gin.H{"$ref": "#/components/schemas/Error"}}},
+				},
+			},
+		}
+		if op.Body != nil {
+			operation["requestBody"] = gin.H{
+				"required": true,
+				"content":  gin.H{"application/json": gin.H{"schema": g.schemaOf(reflect.TypeOf(op.Body))}},
+			}
+		}
+		item[strings.ToLower(op.Method)] = operation
+	}
+
+	return gin.H{
+		"openapi": "3.0.3",
+		"info": gin.H{
+			"title":       "Chatlog API",
+			"description": "聊天记录查询与分析接口。开启 http.envelope 配置或请求时加上 envelope=1，/api/v1 的 JSON 响应将包装为 {data, pagination, error, request_id}",
+			"version":     "v1",
+		},
+		"paths":      paths,
+		"components": gin.H{"schemas": g.schemas},
+	}
+}
+
+// schemaGen 将 Go 类型转换为 JSON Schema，具名结构体放入 components 复用
+type schemaGen struct {
+	schemas gin.H
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGen) schemaOf(t reflect.Type) gin.H {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return gin.H{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		name := t.Name()
+		if _, ok := g.schemas[name]; !ok {
+			// 先占位，避免递归类型无限展开
+			g.schemas[name] = gin.H{}
+			g.schemas[name] = g.structSchema(t)
+		}
+		return gin.H{"$ref": "#/components/schemas/" + name}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return gin.H{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return gin.H{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return gin.H{"type": "number"}
+	case reflect.String:
+		return gin.H{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return gin.H{"type": "string", "format": "byte"}
+		}
+		return gin.H{"type": "array", "items": g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return gin.H{"type": "object", "additionalProperties": g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		return g.structSchema(t)
+	default:
+		return gin.H{}
+	}
+}
+
+func (g *schemaGen) structSchema(t reflect.Type) gin.H {
+	props := gin.H{}
+	g.collectFields(t, props)
+	return gin.H{"type": "object", "properties": props}
+}
+
+// collectFields 按 encoding/json 的规则收集字段，匿名结构体字段展开
+func (g *schemaGen) collectFields(t reflect.Type, props gin.H) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.collectFields(ft, props)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schemaOf(f.Type)
+	}
+}
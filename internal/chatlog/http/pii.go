@@ -0,0 +1,129 @@
+package http
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/redact"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// PIIItem 包含疑似敏感信息的消息，可通过 /api/v1/message/:talker/:seq 查看原文
+type PIIItem struct {
+	Talker     string           `json:"talker"`
+	TalkerName string           `json:"talkerName,omitempty"`
+	Seq        int64            `json:"seq"`
+	Time       time.Time        `json:"time"`
+	Sender     string           `json:"sender"`
+	SenderName string           `json:"senderName,omitempty"`
+	Findings   []redact.Finding `json:"findings"`
+}
+
+// GetPIIReport 扫描范围内的文本消息与分享消息的标题、描述，报告疑似的手机号、身份证号、银行卡号、地址与密码
+// 结果中的敏感内容已遮盖，用于导出数据前逐条清理
+func (s *Service) GetPIIReport(c *gin.Context) {
+	q := struct {
+		Time   string `form:"time"`
+		Talker string `form:"talker"`
+		Sender string `form:"sender"`
+		Kind   string `form:"kind"`
+		Limit  int    `form:"limit"`
+		Offset int    `form:"offset"`
+	}{}
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if q.Time == "" {
+		q.Time = "all"
+	}
+	scope, err := newAnalysisScope(q.Talker, q.Time)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	kinds := make(map[string]bool)
+	for _, kind := range util.Str2List(q.Kind, ",") {
+		kinds[kind] = true
+	}
+
+	// 逐条扫描，只保留包含敏感信息的消息，范围较大时不在内存中保留全部消息
+	scanned := 0
+	counts := make(map[string]int)
+	items := make([]*PIIItem, 0)
+	err = s.db.IterMessages(c.Request.Context(), scope.Start, scope.End, scope.Talker, q.Sender, "", "", false, func(msg *model.Message) error {
+		scanned++
+		findings := make([]redact.Finding, 0)
+		for _, text := range piiTexts(msg) {
+			for _, f := range redact.Find(text) {
+				if len(kinds) == 0 || kinds[f.Kind] {
+					findings = append(findings, f)
+				}
+			}
+		}
+		if len(findings) == 0 {
+			return nil
+		}
+		for _, f := range findings {
+			counts[f.Kind]++
+		}
+		items = append(items, &PIIItem{
+			Talker:     msg.Talker,
+			TalkerName: msg.TalkerName,
+			Seq:        msg.Seq,
+			Time:       msg.Time,
+			Sender:     msg.Sender,
+			SenderName: msg.SenderName,
+			Findings:   findings,
+		})
+		return nil
+	})
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	// 多个会话时按会话分组读取，结果按时间排序
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Time.Before(items[j].Time)
+	})
+
+	total := len(items)
+	if q.Offset > 0 {
+		items = items[min(q.Offset, len(items)):]
+	}
+	if q.Limit > 0 && q.Limit < len(items) {
+		items = items[:q.Limit]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scope":   scope,
+		"scanned": scanned,
+		"total":   total,
+		"counts":  counts,
+		"items":   items,
+	})
+}
+
+// piiTexts 消息中需要扫描的文本
+func piiTexts(msg *model.Message) []string {
+	switch msg.Type {
+	case 1:
+		return []string{msg.Content}
+	case 49:
+		texts := make([]string, 0, 2)
+		for _, key := range []string{"title", "desc"} {
+			if text, ok := msg.Contents[key].(string); ok {
+				texts = append(texts, text)
+			}
+		}
+		return texts
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// initPprof 在 /debug/pprof/ 下提供 net/http/pprof 的性能分析接口，仅在启用 http.pprof 且设置了登录密码时可访问
+// 与其他接口一样经过访问地址限制、登录与客户端证书校验，用于在运行中排查大批量导出时的内存增长
+// 不提供 cmdline，命令行参数中可能包含 --work-key 等口令
+func (s *Service) initPprof(router *gin.Engine) {
+	router.Any("/debug/pprof/*name", func(c *gin.Context) {
+		name := strings.TrimPrefix(c.Param("name"), "/")
+		if !s.ctx.HTTP.Pprof || !s.auth.enabled() || name == "cmdline" {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		switch name {
+		case "profile":
+			pprof.Profile(c.Writer, c.Request)
+		case "symbol":
+			pprof.Symbol(c.Writer, c.Request)
+		case "trace":
+			pprof.Trace(c.Writer, c.Request)
+		default:
+			pprof.Index(c.Writer, c.Request)
+		}
+	})
+}
+
+// logPprof 启用性能分析接口时提示访问地址，未启用登录时提示接口不可用
+func (s *Service) logPprof() {
+	if !s.ctx.HTTP.Pprof {
+		return
+	}
+	if !s.auth.enabled() {
+		log.Warn().Msg("pprof endpoints are disabled because login is not enabled, set http.auth.password to use them")
+		return
+	}
+	log.Info().Msg("pprof endpoints are enabled at /debug/pprof/")
+}
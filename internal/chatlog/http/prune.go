@@ -0,0 +1,59 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// pruneOptions 解析清理条件：before 为保留期限（如 180d、1y）或截止日期，talker 为需要清理的会话
+func pruneOptions(c *gin.Context) (database.PruneOptions, error) {
+	var opts database.PruneOptions
+	if before := c.Query("before"); before != "" {
+		t, ok := util.CutoffOf(before)
+		if !ok {
+			return opts, errors.InvalidArg("before")
+		}
+		opts.Before = t
+	}
+	opts.Talkers = util.Str2List(c.Query("talker"), ",")
+	return opts, nil
+}
+
+// GetPrune 预览清理结果，不做修改
+func (s *Service) GetPrune(c *gin.Context) {
+	opts, err := pruneOptions(c)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	opts.DryRun = true
+	report, err := s.db.Prune(c.Request.Context(), opts)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// PostPrune 从工作目录中删除过期或指定会话的消息，完成后清空分析结果缓存
+func (s *Service) PostPrune(c *gin.Context) {
+	opts, err := pruneOptions(c)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	report, err := s.db.Prune(c.Request.Context(), opts)
+	if report != nil && report.Messages > 0 {
+		s.cache.clear()
+	}
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
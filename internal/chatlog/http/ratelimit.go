@@ -0,0 +1,107 @@
+package http
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// rateLimitSweep 清理空闲令牌桶的间隔
+const rateLimitSweep = time.Minute
+
+// rateLimiter 按客户端划分的令牌桶，每秒补充 rate 个令牌，最多累积 burst 个
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter rate 小于等于 0 时返回 nil，表示不限流
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow 尝试消耗一个令牌，失败时返回需要等待的时长
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > rateLimitSweep {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep 删除已经补满的令牌桶，避免长期运行时客户端数量无限增长
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// rateLimitKey 限流计数的客户端标识
+// api_key 时按登录校验后的用户名或客户端证书名称计数，未经认证的请求退回 IP，避免伪造请求头绕过限流
+func (s *Service) rateLimitKey(c *gin.Context) string {
+	if s.ctx.HTTP.RateLimit.KeyBy == "api_key" {
+		if user := c.GetString("User"); user != "" {
+			return "user:" + user
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// rateLimitMiddleware 超出限制时返回 429 与 Retry-After，limiter 为 nil 时不限流
+func (s *Service) rateLimitMiddleware(l *rateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l == nil {
+			c.Next()
+			return
+		}
+		if ok, wait := l.allow(s.rateLimitKey(c), time.Now()); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			errors.Err(c, errors.RateLimited())
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
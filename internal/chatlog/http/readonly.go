@@ -0,0 +1,19 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// readOnlyMiddleware 只读模式下拒绝写入磁盘、提交任务与按路径下载文件的请求
+func (s *Service) readOnlyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.ctx.HTTP.ReadOnly {
+			errors.Err(c, errors.ReadOnly())
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
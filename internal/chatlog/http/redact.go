@@ -0,0 +1,228 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/pkg/redact"
+)
+
+// redactNamesTTL 化名对照表的刷新间隔，新增联系人与群名片修改在此之后生效
+const redactNamesTTL = 5 * time.Minute
+
+// redactorOf 请求是否需要脱敏，redact 参数优先于配置中的默认值，不需要时返回 nil
+func (s *Service) redactorOf(c *gin.Context) *redact.Redactor {
+	enabled := s.ctx.Settings().Redact
+	switch strings.ToLower(c.Query("redact")) {
+	case "1", "true", "yes", "on":
+		enabled = true
+	case "0", "false", "no", "off":
+		enabled = false
+	}
+	if !enabled {
+		return nil
+	}
+
+	ctx := c.Request.Context()
+	account := database.AccountOf(ctx)
+	s.redactMu.Lock()
+	defer s.redactMu.Unlock()
+	if s.redactors == nil {
+		s.redactors = make(map[string]*accountRedactor)
+	}
+	ar, ok := s.redactors[account]
+	if !ok {
+		ar = &accountRedactor{r: redact.New(s.redactSaltOf())}
+		s.redactors[account] = ar
+	}
+	if time.Since(ar.at) > redactNamesTTL {
+		ar.r.SetNames(s.redactNames(ctx, ar.r))
+		ar.at = time.Now()
+	}
+	return ar.r
+}
+
+// accountRedactor 账号的脱敏处理器与化名对照表的刷新时间
+type accountRedactor struct {
+	r  *redact.Redactor
+	at time.Time
+}
+
+// redactSaltOf 配置的 redact_salt，未配置时随机生成一次，各账号使用相同的 salt
+func (s *Service) redactSaltOf() string {
+	if s.ctx.HTTP.RedactSalt != "" {
+		return s.ctx.HTTP.RedactSalt
+	}
+	if s.redactSalt == "" {
+		b := make([]byte, 16)
+		rand.Read(b)
+		s.redactSalt = hex.EncodeToString(b)
+	}
+	return s.redactSalt
+}
+
+// resetRedactNames 清空化名对照表，下次请求时重新读取联系人与群聊
+func (s *Service) resetRedactNames() {
+	s.redactMu.Lock()
+	defer s.redactMu.Unlock()
+	for _, ar := range s.redactors {
+		ar.r.SetNames(nil)
+		ar.at = time.Time{}
+	}
+}
+
+// imageExts 数据目录中需要遮盖的图片扩展名，.dat 图片解密后处理
+var imageExts = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".bmp": true, ".webp": true}
+
+// imageMask 图片遮盖方式，images=blur/replace 参数优先，其次在脱敏时使用 http.image_mask，不遮盖时返回空字符串
+func (s *Service) imageMask(c *gin.Context) string {
+	switch mode := c.Query("images"); mode {
+	case redact.ImageBlur, redact.ImageReplace:
+		return mode
+	}
+	if mask := s.ctx.Settings().ImageMask; mask != "" && s.redactorOf(c) != nil {
+		return mask
+	}
+	return ""
+}
+
+// serveMaskedImage 返回模糊或替换后的图片，尺寸与原图相同
+func serveMaskedImage(c *gin.Context, data []byte, mode string) {
+	out, contentType, err := redact.Image(data, mode)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	serveData(c, contentType, out)
+}
+
+// redactNames ctx 所属账号的联系人、群聊与群成员的 ID 和名称对应的化名，不随请求取消而中断
+func (s *Service) redactNames(ctx context.Context, r *redact.Redactor) map[string]string {
+	ctx = database.WithAccount(context.Background(), database.AccountOf(ctx))
+	names := make(map[string]string)
+	if contacts, err := s.db.GetContacts(ctx, "", 0, 0); err == nil {
+		for _, contact := range contacts.Items {
+			names[contact.UserName] = r.ID(contact.UserName)
+			names[contact.Alias] = r.ID(contact.Alias)
+			names[contact.NickName] = r.Name("User", contact.NickName)
+			names[contact.Remark] = r.Name("User", contact.Remark)
+		}
+	}
+	if chatRooms, err := s.db.GetChatRooms(ctx, "", 0, 0); err == nil {
+		for _, room := range chatRooms.Items {
+			names[room.Name] = r.ID(room.Name)
+			names[room.NickName] = r.Name("Group", room.NickName)
+			names[room.Remark] = r.Name("Group", room.Remark)
+			for _, user := range room.Users {
+				names[user.UserName] = r.ID(user.UserName)
+				names[user.DisplayName] = r.Name("User", user.DisplayName)
+			}
+		}
+	}
+	delete(names, "")
+	return names
+}
+
+// redactMiddleware 脱敏所有文本响应：JSON 逐个处理字符串，SSE 逐个事件处理，其余文本整体处理
+// 图片、语音、压缩包等二进制内容原样输出
+func (s *Service) redactMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isPage(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		r := s.redactorOf(c)
+		if r == nil {
+			c.Next()
+			return
+		}
+
+		w := &redactWriter{ResponseWriter: c.Writer, r: r}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if w.mode != redactBuffer {
+			return
+		}
+		body := w.buf.Bytes()
+		switch contentType := w.Header().Get("Content-Type"); {
+		case strings.HasPrefix(contentType, "application/json"):
+			body = r.JSON(body)
+		case strings.HasPrefix(contentType, "application/x-ndjson"):
+			lines := bytes.Split(body, []byte("\n"))
+			for i, line := range lines {
+				if len(bytes.TrimSpace(line)) > 0 {
+					lines[i] = r.JSON(line)
+				}
+			}
+			body = bytes.Join(lines, []byte("\n"))
+		default:
+			body = []byte(r.Text(string(body)))
+		}
+		w.ResponseWriter.Write(body)
+	}
+}
+
+const (
+	redactUndecided = iota
+	redactPassthrough
+	redactStream
+	redactBuffer
+)
+
+// redactWriter 按响应类型决定脱敏方式，首次写入时根据 Content-Type 判断
+type redactWriter struct {
+	gin.ResponseWriter
+	r    *redact.Redactor
+	buf  bytes.Buffer
+	mode int
+}
+
+func (w *redactWriter) decide() {
+	if w.mode != redactUndecided {
+		return
+	}
+	contentType := w.Header().Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "text/event-stream"):
+		w.mode = redactStream
+	case strings.HasPrefix(contentType, "text/"),
+		strings.HasPrefix(contentType, "application/json"),
+		strings.HasPrefix(contentType, "application/x-ndjson"),
+		strings.Contains(contentType, "xml"):
+		w.mode = redactBuffer
+	default:
+		w.mode = redactPassthrough
+		return
+	}
+	// 脱敏后长度会变化
+	w.Header().Del("Content-Length")
+}
+
+func (w *redactWriter) Write(data []byte) (int, error) {
+	w.decide()
+	switch w.mode {
+	case redactBuffer:
+		return w.buf.Write(data)
+	case redactStream:
+		if _, err := w.ResponseWriter.WriteString(w.r.Text(string(data))); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *redactWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
@@ -1,10 +1,11 @@
 package http
 
 import (
+	"bytes"
+	"context"
 	"embed"
 	"encoding/json"
 	"fmt"
-	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -12,12 +13,14 @@ import (
 	"strings"
 	"time"
 
+	"github.com/sjzar/chatlog/internal/chatlog/analysis"
 	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/i18n"
 	"github.com/sjzar/chatlog/pkg/util"
-	"github.com/sjzar/chatlog/pkg/util/dat2img"
-	"github.com/sjzar/chatlog/pkg/util/silk"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
 )
 
 // EFS holds embedded file system data for static assets.
@@ -29,17 +32,39 @@ func (s *Service) initRouter() {
 
 	router := s.GetRouter()
 
-	staticDir, _ := fs.Sub(EFS, "static")
+	// 链路追踪、耗时统计、审计、访问地址限制、客户端证书、登录、限流、空闲锁定、账号选择与脱敏，需在注册路由前启用
+	// 限流在登录之后，以便按认证后的用户计数；登录失败由登录锁定限制
+	limits := s.ctx.HTTP.RateLimit
+	router.Use(s.traceMiddleware(), s.latencyMiddleware(), s.auditMiddleware(), s.allowMiddleware(), s.clientCertMiddleware(), s.authMiddleware(), s.rateLimitMiddleware(newRateLimiter(limits.Rate, limits.Burst)), s.lockMiddleware(), s.accountMiddleware(), s.redactMiddleware())
+
+	// 耗时接口单独限流
+	heavy := s.rateLimitMiddleware(newRateLimiter(limits.AnalysisRate, limits.AnalysisBurst))
+
+	// 只读模式下禁用的接口
+	writable := s.readOnlyMiddleware()
+
+	staticDir := s.staticFS()
 	router.StaticFS("/static", http.FS(staticDir))
 	router.StaticFileFS("/favicon.ico", "./favicon.ico", http.FS(staticDir))
 	router.StaticFileFS("/", "./index.htm", http.FS(staticDir))
+	router.StaticFileFS("/swagger", "./swagger.htm", http.FS(staticDir))
+	router.StaticFileFS("/login", "./login.htm", http.FS(staticDir))
+	router.POST("/login", s.Login)
+	router.POST("/logout", s.Logout)
 
 	// Media
 	router.GET("/image/*key", s.GetImage)
 	router.GET("/video/*key", s.GetVideo)
 	router.GET("/file/*key", s.GetFile)
 	router.GET("/voice/*key", s.GetVoice)
-	router.GET("/data/*path", s.GetMediaData)
+	router.GET("/data/*path", s.readOnlyMiddleware(), s.GetMediaData)
+
+	// 下载工具与播放器通过 HEAD 请求获取文件大小
+	router.HEAD("/image/*key", s.GetImage)
+	router.HEAD("/video/*key", s.GetVideo)
+	router.HEAD("/file/*key", s.GetFile)
+	router.HEAD("/voice/*key", s.GetVoice)
+	router.HEAD("/data/*path", s.readOnlyMiddleware(), s.GetMediaData)
 
 	// MCP Server
 	{
@@ -50,22 +75,106 @@ func (s *Service) initRouter() {
 		router.POST("/message", s.mcp.HandleMessages)
 	}
 
+	// 分析接口结果缓存
+	cached := s.cache.Middleware(s.cacheScope)
+
+	// API 文档，不做统一包装
+	router.GET("/api/v1/openapi.json", s.GetOpenAPI)
+
+	// 各接口耗时直方图，Prometheus 文本格式
+	router.GET("/metrics", s.GetMetrics)
+
+	// 性能分析
+	s.initPprof(router)
+
+	// GraphQL，按查询返回所需字段，不做统一包装
+	router.GET("/graphql", heavy, s.GraphQL)
+	router.POST("/graphql", heavy, s.GraphQL)
+
+	// 实时推送
+	router.GET("/ws/chatlog", s.GetChatlogLive)
+
+	// 订阅源
+	router.GET("/feed/:file", s.GetFeed)
+
 	// API V1 Router
-	api := router.Group("/api/v1")
+	api := router.Group("/api/v1", s.envelopeMiddleware(), s.fieldsMiddleware())
 	{
+		api.GET("/accounts", s.GetAccounts)
 		api.GET("/chatlog", s.GetChatlog)
+		api.GET("/chatlog/context", s.GetMessageContext)
+		api.GET("/chatlog/jump", s.GetMessageJump)
+		api.GET("/chatlog/calendar", heavy, cached, s.GetMessageCalendar)
+		api.GET("/message/:talker/:seq", s.GetMessage)
+		api.GET("/conversation", s.GetConversation)
+		api.GET("/media/wall", s.GetMediaWall)
 		api.GET("/contact", s.GetContacts)
+		api.GET("/contact/:key/export", writable, heavy, s.ExportContactData)
+		api.DELETE("/contact/:key", writable, s.PurgeContactData)
+		api.GET("/prune", writable, heavy, s.GetPrune)
+		api.POST("/prune", writable, heavy, s.PostPrune)
 		api.GET("/chatroom", s.GetChatRooms)
 		api.GET("/session", s.GetSessions)
+		api.GET("/events", s.GetEvents)
+		api.GET("/links", s.GetLinks)
 		api.GET("/analysis/report", s.GetAnalysisReport)
-		api.GET("/analysis/stats", s.GetAnalysisStats)
-		api.GET("/analysis/export", s.ExportAnalysisData)
-		api.GET("/analysis/files", s.GetAnalysisFiles)
-		api.GET("/analysis/download", s.DownloadAnalysisFile)
-		api.GET("/analysis/search", s.SearchMessages)
-		api.GET("/analysis/chatroom", s.GetChatroomHistory)
-		api.GET("/analysis/daily-summary", s.GetDailySummary)
-		api.GET("/analysis/golden-quotes", s.GetGoldenQuotes)
+		api.POST("/analysis/report", writable, heavy, s.GenerateAnalysisReport)
+		api.GET("/analysis/stats", heavy, cached, s.GetAnalysisStats)
+		api.GET("/analysis/export", writable, heavy, s.ExportAnalysisData)
+		api.GET("/analysis/files", writable, s.GetAnalysisFiles)
+		api.GET("/analysis/download", writable, s.DownloadAnalysisFile)
+		api.GET("/analysis/search", heavy, s.SearchMessages)
+		api.GET("/analysis/chatroom", heavy, cached, s.GetChatroomHistory)
+		api.GET("/analysis/daily-summary", heavy, cached, s.GetDailySummary)
+		api.GET("/analysis/golden-quotes", heavy, cached, s.GetGoldenQuotes)
+		api.GET("/analysis/compare", heavy, cached, s.CompareAnalysis)
+		api.GET("/analysis/profile", heavy, cached, s.GetProfileAnalysis)
+		api.GET("/analysis/digest", heavy, cached, s.GetDigest)
+		api.GET("/analysis/pii", heavy, s.GetPIIReport)
+		api.GET("/analysis/corpus", writable, heavy, s.ExportCorpus)
+
+		api.GET("/bookmarks", s.ListBookmarks)
+		api.POST("/bookmarks", writable, s.CreateBookmark)
+		api.GET("/bookmarks/:talker/:seq", s.GetBookmark)
+		api.PUT("/bookmarks/:talker/:seq", writable, s.UpdateBookmark)
+		api.DELETE("/bookmarks/:talker/:seq", writable, s.DeleteBookmark)
+
+		api.GET("/annotations", s.ListAnnotations)
+		api.GET("/annotations/:talker/:seq", s.GetAnnotation)
+		api.PUT("/annotations/:talker/:seq", writable, s.PutAnnotation)
+		api.DELETE("/annotations/:talker/:seq", writable, s.DeleteAnnotation)
+
+		api.GET("/tags", s.ListTags)
+		api.GET("/tags/:tag", s.GetTag)
+		api.PUT("/tags/:tag", writable, s.PutTag)
+		api.POST("/tags/:tag", writable, s.AddTag)
+		api.DELETE("/tags/:tag", writable, s.DeleteTag)
+		api.DELETE("/tags/:tag/:talker", writable, s.DeleteTag)
+
+		api.GET("/names", s.ListDisplayNames)
+		api.PUT("/names/:talker", writable, s.PutDisplayName)
+		api.DELETE("/names/:talker", writable, s.DeleteDisplayName)
+
+		api.GET("/identities", s.ListIdentities)
+		api.GET("/identities/:id", s.GetIdentity)
+		api.POST("/identities/:id", writable, s.MergeIdentity)
+		api.DELETE("/identities/:id", writable, s.SplitIdentity)
+		api.DELETE("/identities/:id/:alias", writable, s.SplitIdentity)
+
+		api.POST("/batch", heavy, s.Batch)
+
+		api.POST("/jobs", writable, heavy, s.CreateJob)
+		api.GET("/jobs", s.ListJobs)
+		api.GET("/jobs/:id", s.GetJob)
+		api.DELETE("/jobs/:id", writable, s.CancelJob)
+
+		api.GET("/admin/audit", s.adminMiddleware(), s.GetAudit)
+
+		api.GET("/server/info", s.GetServerInfo)
+
+		api.GET("/lock", s.GetLock)
+		api.POST("/lock", s.PostLock)
+		api.POST("/unlock", s.PostUnlock)
 	}
 
 	router.NoRoute(s.NoRoute)
@@ -80,7 +189,7 @@ func (s *Service) NoRoute(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
 	default:
 		c.Header("Cache-Control", "no-cache, no-store, max-age=0, must-revalidate, value")
-		c.Redirect(http.StatusFound, "/")
+		c.Redirect(http.StatusFound, basePath(c.Request.Context())+"/")
 	}
 }
 
@@ -91,6 +200,9 @@ func (s *Service) GetChatlog(c *gin.Context) {
 		Talker  string `form:"talker"`
 		Sender  string `form:"sender"`
 		Keyword string `form:"keyword"`
+		Type    string `form:"type"`
+		Order   string `form:"order"`
+		Inline  bool   `form:"inline_media"`
 		Limit   int    `form:"limit"`
 		Offset  int    `form:"offset"`
 		Format  string `form:"format"`
@@ -114,16 +226,57 @@ func (s *Service) GetChatlog(c *gin.Context) {
 		q.Offset = 0
 	}
 
-	messages, err := s.db.GetMessages(start, end, q.Talker, q.Sender, q.Keyword, q.Limit, q.Offset)
+	var desc bool
+	switch strings.ToLower(q.Order) {
+	case "", "asc":
+	case "desc":
+		desc = true
+	default:
+		errors.Err(c, errors.InvalidArg("order"))
+		return
+	}
+
+	format := strings.ToLower(q.Format)
+	if streamable(q.Talker, format) {
+		s.streamChatlog(c, chatlogStream{
+			start:       start,
+			end:         end,
+			talker:      q.Talker,
+			sender:      q.Sender,
+			keyword:     q.Keyword,
+			msgType:     q.Type,
+			desc:        desc,
+			limit:       q.Limit,
+			offset:      q.Offset,
+			inline:      q.Inline,
+			jsonl:       format == "jsonl" || format == "ndjson",
+			annotations: withAnnotations(c),
+		})
+		return
+	}
+
+	messages, err := s.db.GetMessages(c.Request.Context(), start, end, q.Talker, q.Sender, q.Keyword, q.Type, desc, q.Limit, q.Offset)
 	if err != nil {
 		errors.Err(c, err)
 		return
 	}
 
-	switch strings.ToLower(q.Format) {
+	switch format {
 	case "csv":
+	case "jsonl", "ndjson":
+		setMediaURLs(c, messages)
+		s.annotate(c, messages)
+		if q.Inline {
+			s.inlineImages(c.Request.Context(), messages, s.ctx.Settings().InlineMediaMaxSize, s.imageMask(c))
+		}
+		writeJSONL(c, messages)
 	case "json":
 		// json
+		setMediaURLs(c, messages)
+		s.annotate(c, messages)
+		if q.Inline {
+			s.inlineImages(c.Request.Context(), messages, s.ctx.Settings().InlineMediaMaxSize, s.imageMask(c))
+		}
 		c.JSON(http.StatusOK, messages)
 	default:
 		// plain text
@@ -132,8 +285,9 @@ func (s *Service) GetChatlog(c *gin.Context) {
 		c.Writer.Header().Set("Connection", "keep-alive")
 		c.Writer.Flush()
 
+		setLang(messages, langOf(c.Request))
 		for _, m := range messages {
-			c.Writer.WriteString(m.PlainText(strings.Contains(q.Talker, ","), util.PerfectTimeFormat(start, end), c.Request.Host))
+			c.Writer.WriteString(m.PlainText(strings.Contains(q.Talker, ","), util.PerfectTimeFormat(start, end), hostOf(c)))
 			c.Writer.WriteString("\n")
 			c.Writer.Flush()
 		}
@@ -154,7 +308,7 @@ func (s *Service) GetContacts(c *gin.Context) {
 		return
 	}
 
-	list, err := s.db.GetContacts(q.Keyword, q.Limit, q.Offset)
+	list, err := s.db.GetContacts(c.Request.Context(), q.Keyword, q.Limit, q.Offset)
 	if err != nil {
 		errors.Err(c, err)
 		return
@@ -165,6 +319,8 @@ func (s *Service) GetContacts(c *gin.Context) {
 	case "json":
 		// json
 		c.JSON(http.StatusOK, list)
+	case "jsonl", "ndjson":
+		writeJSONL(c, list.Items)
 	default:
 		// csv
 		if format == "csv" {
@@ -177,7 +333,7 @@ func (s *Service) GetContacts(c *gin.Context) {
 		c.Writer.Header().Set("Connection", "keep-alive")
 		c.Writer.Flush()
 
-		c.Writer.WriteString("UserName,Alias,Remark,NickName\n")
+		c.Writer.WriteString(csvHeader(c.Request, "csv.contacts") + "\n")
 		for _, contact := range list.Items {
 			c.Writer.WriteString(fmt.Sprintf("%s,%s,%s,%s\n", contact.UserName, contact.Alias, contact.Remark, contact.NickName))
 		}
@@ -199,7 +355,7 @@ func (s *Service) GetChatRooms(c *gin.Context) {
 		return
 	}
 
-	list, err := s.db.GetChatRooms(q.Keyword, q.Limit, q.Offset)
+	list, err := s.db.GetChatRooms(c.Request.Context(), q.Keyword, q.Limit, q.Offset)
 	if err != nil {
 		errors.Err(c, err)
 		return
@@ -209,6 +365,8 @@ func (s *Service) GetChatRooms(c *gin.Context) {
 	case "json":
 		// json
 		c.JSON(http.StatusOK, list)
+	case "jsonl", "ndjson":
+		writeJSONL(c, list.Items)
 	default:
 		// csv
 		if format == "csv" {
@@ -221,7 +379,7 @@ func (s *Service) GetChatRooms(c *gin.Context) {
 		c.Writer.Header().Set("Connection", "keep-alive")
 		c.Writer.Flush()
 
-		c.Writer.WriteString("Name,Remark,NickName,Owner,UserCount\n")
+		c.Writer.WriteString(csvHeader(c.Request, "csv.chatrooms") + "\n")
 		for _, chatRoom := range list.Items {
 			c.Writer.WriteString(fmt.Sprintf("%s,%s,%s,%s,%d\n", chatRoom.Name, chatRoom.Remark, chatRoom.NickName, chatRoom.Owner, len(chatRoom.Users)))
 		}
@@ -243,7 +401,7 @@ func (s *Service) GetSessions(c *gin.Context) {
 		return
 	}
 
-	sessions, err := s.db.GetSessions(q.Keyword, q.Limit, q.Offset)
+	sessions, err := s.db.GetSessions(c.Request.Context(), q.Keyword, q.Limit, q.Offset)
 	if err != nil {
 		errors.Err(c, err)
 		return
@@ -256,7 +414,7 @@ func (s *Service) GetSessions(c *gin.Context) {
 		c.Writer.Header().Set("Connection", "keep-alive")
 		c.Writer.Flush()
 
-		c.Writer.WriteString("UserName,NOrder,NickName,Content,NTime\n")
+		c.Writer.WriteString(csvHeader(c.Request, "csv.sessions") + "\n")
 		for _, session := range sessions.Items {
 			c.Writer.WriteString(fmt.Sprintf("%s,%d,%s,%s,%s\n", session.UserName, session.NOrder, session.NickName, strings.ReplaceAll(session.Content, "\n", "\\n"), session.NTime))
 		}
@@ -264,6 +422,8 @@ func (s *Service) GetSessions(c *gin.Context) {
 	case "json":
 		// json
 		c.JSON(http.StatusOK, sessions)
+	case "jsonl", "ndjson":
+		writeJSONL(c, sessions.Items)
 	default:
 		c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		c.Writer.Header().Set("Cache-Control", "no-cache")
@@ -308,14 +468,17 @@ func (s *Service) GetMedia(c *gin.Context, _type string) {
 	var _err error
 	for _, k := range keys {
 		if len(k) != 32 {
-			absolutePath := filepath.Join(s.ctx.DataDir, k)
-			if _, err := os.Stat(absolutePath); os.IsNotExist(err) {
+			// 只读模式下不允许按路径访问
+			if s.ctx.HTTP.ReadOnly {
 				continue
 			}
-			c.Redirect(http.StatusFound, "/data/"+k)
+			if _, err := s.dataPath(c.Request.Context(), k); err != nil {
+				continue
+			}
+			redirectData(c, k)
 			return
 		}
-		media, err := s.db.GetMedia(_type, k)
+		media, err := s.db.GetMedia(c.Request.Context(), _type, k)
 		if err != nil {
 			_err = err
 			continue
@@ -324,12 +487,18 @@ func (s *Service) GetMedia(c *gin.Context, _type string) {
 			c.JSON(http.StatusOK, media)
 			return
 		}
+		c.Request = c.Request.WithContext(mediaCtx(c.Request.Context(), media))
 		switch media.Type {
 		case "voice":
 			s.HandleVoice(c, media.Data)
 			return
 		default:
-			c.Redirect(http.StatusFound, "/data/"+media.Path)
+			// 只读模式下 /data 不可用，直接返回文件
+			if s.ctx.HTTP.ReadOnly {
+				s.serveDataFile(c, media.Path)
+				return
+			}
+			redirectData(c, media.Path)
 			return
 		}
 	}
@@ -340,12 +509,29 @@ func (s *Service) GetMedia(c *gin.Context, _type string) {
 	}
 }
 
-func (s *Service) GetMediaData(c *gin.Context) {
-	relativePath := filepath.Clean(c.Param("path"))
+// redirectData 跳转到 /data 下的文件，保留 images、redact 等查询参数
+func redirectData(c *gin.Context, path string) {
+	location := basePath(c.Request.Context()) + accountPath(c.Request.Context()) + "/data/" + path
+	if query := c.Request.URL.RawQuery; query != "" {
+		location += "?" + query
+	}
+	c.Redirect(http.StatusFound, location)
+}
 
-	absolutePath := filepath.Join(s.ctx.DataDir, relativePath)
+func (s *Service) GetMediaData(c *gin.Context) {
+	s.serveDataFile(c, c.Param("path"))
+}
 
-	if _, err := os.Stat(absolutePath); os.IsNotExist(err) {
+// serveDataFile 返回数据目录下的文件，加密图片实时解密
+func (s *Service) serveDataFile(c *gin.Context, path string) {
+	absolutePath, err := s.dataPath(c.Request.Context(), path)
+	if err == nil {
+		var info os.FileInfo
+		if info, err = os.Stat(absolutePath); err == nil && info.IsDir() {
+			err = os.ErrNotExist
+		}
+	}
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "File not found",
 		})
@@ -356,6 +542,13 @@ func (s *Service) GetMediaData(c *gin.Context) {
 	switch {
 	case ext == ".dat":
 		s.HandleDatFile(c, absolutePath)
+	case imageExts[ext] && s.imageMask(c) != "":
+		b, err := os.ReadFile(absolutePath)
+		if err != nil {
+			errors.Err(c, err)
+			return
+		}
+		serveMaskedImage(c, b, s.imageMask(c))
 	default:
 		// 直接返回文件
 		c.File(absolutePath)
@@ -370,7 +563,12 @@ func (s *Service) HandleDatFile(c *gin.Context, path string) {
 		errors.Err(c, err)
 		return
 	}
-	out, ext, err := dat2img.Dat2Image(b)
+	out, ext, err := decodeImage(c.Request.Context(), b)
+	if mode := s.imageMask(c); mode != "" {
+		// 无法解码时 out 为空，返回占位图
+		serveMaskedImage(c, out, mode)
+		return
+	}
 	if err != nil {
 		c.File(path)
 		return
@@ -378,32 +576,66 @@ func (s *Service) HandleDatFile(c *gin.Context, path string) {
 
 	switch ext {
 	case "jpg":
-		c.Data(http.StatusOK, "image/jpeg", out)
+		serveData(c, "image/jpeg", out)
 	case "png":
-		c.Data(http.StatusOK, "image/png", out)
+		serveData(c, "image/png", out)
 	case "gif":
-		c.Data(http.StatusOK, "image/gif", out)
+		serveData(c, "image/gif", out)
 	case "bmp":
-		c.Data(http.StatusOK, "image/bmp", out)
+		serveData(c, "image/bmp", out)
 	default:
-		c.Data(http.StatusOK, "image/jpg", out)
+		serveData(c, "image/jpg", out)
 		// c.File(path)
 	}
 }
 
 func (s *Service) HandleVoice(c *gin.Context, data []byte) {
-	out, err := silk.Silk2MP3(data)
+	out, err := decodeVoice(c.Request.Context(), data)
 	if err != nil {
-		c.Data(http.StatusOK, "audio/silk", data)
+		serveData(c, "audio/silk", data)
 		return
 	}
-	c.Data(http.StatusOK, "audio/mp3", out)
+	serveData(c, "audio/mp3", out)
+}
+
+// serveData 返回解码后的多媒体内容，设置准确的 Content-Length，并支持 HEAD 与 Range 请求
+func serveData(c *gin.Context, contentType string, data []byte) {
+	c.Header("Content-Type", contentType)
+	http.ServeContent(c.Writer, c.Request, "", time.Time{}, bytes.NewReader(data))
+}
+
+// reportsDir 分析报告与导出文件所在目录
+func (s *Service) reportsDir() string {
+	if s.ctx.ReportsDir == "" {
+		return "."
+	}
+	return s.ctx.ReportsDir
+}
+
+// sandbox 可通过 HTTP 访问的目录：数据目录、报告目录与 http.file_roots
+func (s *Service) sandbox(dataDir string) *util.Sandbox {
+	roots := append([]string{dataDir, s.reportsDir()}, s.ctx.HTTP.FileRoots...)
+	return util.NewSandbox(roots...)
+}
+
+// dataPath 将相对路径解析为 ctx 所属账号数据目录下的真实路径，不允许访问允许目录之外的文件
+func (s *Service) dataPath(ctx context.Context, name string) (string, error) {
+	dataDir, err := s.db.DataDir(ctx)
+	if err != nil {
+		return "", err
+	}
+	return s.sandbox(dataDir).Resolve(dataDir, name)
+}
+
+// reportPath 将文件名解析为报告目录下的真实路径，不允许访问允许目录之外的文件
+func (s *Service) reportPath(name string) (string, error) {
+	return s.sandbox(s.ctx.DataDir).Resolve(s.reportsDir(), name)
 }
 
 // GetAnalysisReport 获取分析报告
 func (s *Service) GetAnalysisReport(c *gin.Context) {
 	// 查找最新的分析报告文件
-	pattern := "wechat_report_*.json"
+	pattern := filepath.Join(s.reportsDir(), "wechat_report_*.json")
 	matches, err := filepath.Glob(pattern)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find report files"})
@@ -437,29 +669,29 @@ func (s *Service) GetAnalysisStats(c *gin.Context) {
 	stats := make(map[string]interface{})
 
 	// 统计会话数量
-	sessions, err := s.db.GetSessions("", 0, 0)
-	if err == nil {
-		stats["total_sessions"] = len(sessions.Items)
+	if count, err := s.db.CountSessions(c.Request.Context()); err == nil {
+		stats["total_sessions"] = count
 	}
 
 	// 统计联系人数量
-	contacts, err := s.db.GetContacts("", 0, 0)
-	if err == nil {
-		stats["total_contacts"] = len(contacts.Items)
+	if count, err := s.db.CountContacts(c.Request.Context()); err == nil {
+		stats["total_contacts"] = count
 	}
 
 	// 统计群聊数量
-	chatrooms, err := s.db.GetChatRooms("", 0, 0)
-	if err == nil {
-		stats["total_chatrooms"] = len(chatrooms.Items)
+	if count, err := s.db.CountChatRooms(c.Request.Context()); err == nil {
+		stats["total_chatrooms"] = count
 	}
 
 	// 统计最近7天的消息数量
 	end := time.Now()
 	start := end.AddDate(0, 0, -7)
-	messages, err := s.db.GetMessages(start, end, "", "", "", 0, 0)
-	if err == nil {
-		stats["recent_messages"] = len(messages)
+	if hours, err := s.db.GetStats(c.Request.Context(), start, end, ""); err == nil {
+		count := 0
+		for _, stat := range hours {
+			count += stat.Messages
+		}
+		stats["recent_messages"] = count
 	}
 
 	// 添加时间戳
@@ -474,7 +706,7 @@ func (s *Service) ExportAnalysisData(c *gin.Context) {
 	
 	switch exportType {
 	case "sessions":
-		sessions, err := s.db.GetSessions("", 0, 0)
+		sessions, err := s.db.GetSessions(c.Request.Context(), "", 0, 0)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sessions"})
 			return
@@ -483,7 +715,7 @@ func (s *Service) ExportAnalysisData(c *gin.Context) {
 		c.Header("Content-Type", "text/csv; charset=utf-8")
 		c.Header("Content-Disposition", "attachment; filename=sessions_export.csv")
 		
-		c.Writer.WriteString("UserName,NOrder,NickName,Content,NTime\n")
+		c.Writer.WriteString(csvHeader(c.Request, "csv.sessions") + "\n")
 		for _, session := range sessions.Items {
 			c.Writer.WriteString(fmt.Sprintf("%s,%d,%s,%s,%s\n", 
 				session.UserName, session.NOrder, session.NickName, 
@@ -491,7 +723,7 @@ func (s *Service) ExportAnalysisData(c *gin.Context) {
 		}
 		
 	case "contacts":
-		contacts, err := s.db.GetContacts("", 0, 0)
+		contacts, err := s.db.GetContacts(c.Request.Context(), "", 0, 0)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get contacts"})
 			return
@@ -500,14 +732,14 @@ func (s *Service) ExportAnalysisData(c *gin.Context) {
 		c.Header("Content-Type", "text/csv; charset=utf-8")
 		c.Header("Content-Disposition", "attachment; filename=contacts_export.csv")
 		
-		c.Writer.WriteString("UserName,Alias,Remark,NickName\n")
+		c.Writer.WriteString(csvHeader(c.Request, "csv.contacts") + "\n")
 		for _, contact := range contacts.Items {
 			c.Writer.WriteString(fmt.Sprintf("%s,%s,%s,%s\n", 
 				contact.UserName, contact.Alias, contact.Remark, contact.NickName))
 		}
 		
 	case "chatrooms":
-		chatrooms, err := s.db.GetChatRooms("", 0, 0)
+		chatrooms, err := s.db.GetChatRooms(c.Request.Context(), "", 0, 0)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chatrooms"})
 			return
@@ -516,7 +748,7 @@ func (s *Service) ExportAnalysisData(c *gin.Context) {
 		c.Header("Content-Type", "text/csv; charset=utf-8")
 		c.Header("Content-Disposition", "attachment; filename=chatrooms_export.csv")
 		
-		c.Writer.WriteString("Name,Remark,NickName,Owner,UserCount\n")
+		c.Writer.WriteString(csvHeader(c.Request, "csv.chatrooms") + "\n")
 		for _, chatroom := range chatrooms.Items {
 			c.Writer.WriteString(fmt.Sprintf("%s,%s,%s,%s,%d\n", 
 				chatroom.Name, chatroom.Remark, chatroom.NickName, chatroom.Owner, len(chatroom.Users)))
@@ -532,33 +764,35 @@ func (s *Service) GetAnalysisFiles(c *gin.Context) {
 	files := []map[string]string{}
 	
 	// 查找分析报告文件
-	pattern := "wechat_report_*.json"
+	pattern := filepath.Join(s.reportsDir(), "wechat_report_*.json")
 	matches, err := filepath.Glob(pattern)
 	if err == nil {
 		for _, match := range matches {
 			info, err := os.Stat(match)
 			if err == nil {
+				name := filepath.Base(match)
 				files = append(files, map[string]string{
-					"name": match,
+					"name": name,
 					"size": fmt.Sprintf("%.2f KB", float64(info.Size())/1024),
 					"type": "JSON Report",
-					"url":  "/api/v1/analysis/download?file=" + match,
+					"url":  s.downloadURL("file=" + name),
 				})
 			}
 		}
 	}
 	
 	// 查找导出目录
-	exportDirs, err := filepath.Glob("wechat_export_*")
+	exportDirs, err := filepath.Glob(filepath.Join(s.reportsDir(), "wechat_export_*"))
 	if err == nil {
 		for _, dir := range exportDirs {
 			info, err := os.Stat(dir)
 			if err == nil && info.IsDir() {
+				name := filepath.Base(dir)
 				files = append(files, map[string]string{
-					"name": dir,
+					"name": name,
 					"size": "Directory",
 					"type": "Export Folder",
-					"url":  "/api/v1/analysis/download?folder=" + dir,
+					"url":  s.downloadURL("folder=" + name),
 				})
 			}
 		}
@@ -574,7 +808,14 @@ func (s *Service) DownloadAnalysisFile(c *gin.Context) {
 	
 	if file != "" {
 		// 下载单个文件
-		if _, err := os.Stat(file); os.IsNotExist(err) {
+		file, err := s.reportPath(file)
+		if err == nil {
+			var info os.FileInfo
+			if info, err = os.Stat(file); err == nil && info.IsDir() {
+				err = os.ErrNotExist
+			}
+		}
+		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 			return
 		}
@@ -584,16 +825,26 @@ func (s *Service) DownloadAnalysisFile(c *gin.Context) {
 	
 	if folder != "" {
 		// 下载整个文件夹（压缩）
-		if _, err := os.Stat(folder); os.IsNotExist(err) {
+		folder, err := s.reportPath(folder)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+			return
+		}
+		info, err := os.Stat(folder)
+		if err != nil || !info.IsDir() {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
 			return
 		}
 		
-		// 这里可以添加压缩功能，暂时直接返回文件夹信息
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Folder download not implemented yet",
-			"folder":  folder,
-		})
+		// 边打包边输出，不在内存或磁盘中生成完整的压缩包
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(folder)+".zip"))
+		c.Status(http.StatusOK)
+		if err := util.ZipDir(c.Writer, folder); err != nil {
+			// 响应头已发送，只能记录错误并中断
+			log.Err(err).Str("folder", folder).Msg("zip folder failed")
+			c.Abort()
+		}
 		return
 	}
 	
@@ -619,7 +870,7 @@ func (s *Service) SearchMessages(c *gin.Context) {
 	start := end.AddDate(0, 0, -daysInt)
 	
 	// 搜索消息
-	messages, err := s.db.GetMessages(start, end, "", "", keyword, 1000, 0)
+	messages, err := s.db.GetMessages(c.Request.Context(), start, end, "", "", keyword, "", false, 1000, 0)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search messages"})
 		return
@@ -630,7 +881,7 @@ func (s *Service) SearchMessages(c *gin.Context) {
 	for _, msg := range messages {
 		groupKey := msg.Talker
 		if groupKey == "" {
-			groupKey = "未知群聊"
+			groupKey = i18n.T(langOf(c.Request), "topic.unknown_group")
 		}
 		
 		msgData := map[string]interface{}{
@@ -673,16 +924,10 @@ func (s *Service) GetChatroomHistory(c *gin.Context) {
 	}
 	start := end.AddDate(0, 0, -daysInt)
 	
-	// 获取群聊消息
-	messages, err := s.db.GetMessages(start, end, talker, "", "", 5000, 0)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chatroom history"})
-		return
-	}
-	
-	// 按日期分组
+	// 逐条读取群聊消息并按日期分组，最多 5000 条
 	dailyMessages := make(map[string][]interface{})
-	for _, msg := range messages {
+	total := 0
+	err := s.db.IterMessages(c.Request.Context(), start, end, talker, "", "", "", false, func(msg *model.Message) error {
 		date := msg.Time.Format("2006-01-02")
 		
 		msgData := map[string]interface{}{
@@ -694,11 +939,19 @@ func (s *Service) GetChatroomHistory(c *gin.Context) {
 		}
 		
 		dailyMessages[date] = append(dailyMessages[date], msgData)
+		if total++; total >= 5000 {
+			return errors.ErrIterStop
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chatroom history"})
+		return
 	}
 	
 	// 统计信息
 	stats := map[string]interface{}{
-		"total_messages": len(messages),
+		"total_messages": total,
 		"total_days":     len(dailyMessages),
 		"start_date":     start.Format("2006-01-02"),
 		"end_date":       end.Format("2006-01-02"),
@@ -714,59 +967,110 @@ func (s *Service) GetChatroomHistory(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
-// GetDailySummary 获取每日群聊内容主题汇总
+// GetDailySummary 获取群聊内容主题汇总
+// 默认按 date 汇总单日内容；传入 time 时使用与 /chatlog 相同的时间范围语法（如 2024-01-01~2024-01-07、2024-01、last-7d），按群聊汇总整个范围
 func (s *Service) GetDailySummary(c *gin.Context) {
 	date := c.DefaultQuery("date", time.Now().Format("2006-01-02"))
+	_time := c.Query("time")
 	talker := c.Query("talker") // 可选，指定群聊
 	
-	// 解析日期
-	targetDate, err := time.Parse("2006-01-02", date)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
-		return
-	}
-	
-	start := targetDate
-	end := targetDate.AddDate(0, 0, 1)
-	
-	// 获取当日消息
-	messages, err := s.db.GetMessages(start, end, talker, "", "", 10000, 0)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get daily messages"})
-		return
+	var start, end time.Time
+	limit := 10000
+	if _time != "" {
+		var ok bool
+		start, end, ok = util.TimeRangeOf(_time)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time format"})
+			return
+		}
+		limit = 0
+	} else {
+		// 解析日期
+		targetDate, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
+			return
+		}
+		start = targetDate
+		end = targetDate.AddDate(0, 0, 1)
 	}
 	
-	// 按群聊分组
+	// 逐条读取范围内消息，按群聊分组，同时统计每天的消息数
 	groupedMessages := make(map[string][]string)
-	for _, msg := range messages {
+	groupedDaily := make(map[string]map[string]int)
+	total := 0
+	var first, last time.Time
+	err := s.db.IterMessages(c.Request.Context(), start, end, talker, "", "", "", false, func(msg *model.Message) error {
+		if first.IsZero() || msg.Time.Before(first) {
+			first = msg.Time
+		}
+		if msg.Time.After(last) {
+			last = msg.Time
+		}
 		if msg.Type == 1 && msg.Content != "" { // 只处理文本消息
 			groupKey := msg.Talker
 			if groupKey == "" {
-				groupKey = "未知群聊"
+				groupKey = i18n.T(langOf(c.Request), "topic.unknown_group")
 			}
 			groupedMessages[groupKey] = append(groupedMessages[groupKey], msg.Content)
+			if groupedDaily[groupKey] == nil {
+				groupedDaily[groupKey] = make(map[string]int)
+			}
+			groupedDaily[groupKey][msg.Time.Format("2006-01-02")]++
+		}
+		if total++; limit > 0 && total >= limit {
+			return errors.ErrIterStop
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get daily messages"})
+		return
+	}
+	
+	// 范围跨越的天数，用于按日均消息数评估活跃度
+	days := 1
+	if _time != "" {
+		// 时间范围为 all 时以实际消息时间计算，否则以查询范围计算
+		if (start.Year() > 1970 && end.Year() < 9999) || total == 0 {
+			first, last = start, end
+		}
+		if d := int(last.Sub(first).Hours()/24) + 1; d > 1 {
+			days = d
 		}
 	}
 	
 	// 生成主题汇总
 	dailySummaries := make(map[string]interface{})
 	for groupName, contents := range groupedMessages {
-		summary := generateTopicSummary(contents)
-		dailySummaries[groupName] = map[string]interface{}{
+		summary := generateTopicSummary(langOf(c.Request), contents, s.options().Keywords)
+		groupSummary := map[string]interface{}{
 			"message_count": len(contents),
 			"topics":        summary.topics,
 			"keywords":      summary.keywords,
-			"activity_level": getActivityLevel(len(contents)),
+			"activity_level": getActivityLevel(langOf(c.Request), len(contents)/days),
 		}
+		if _time != "" {
+			groupSummary["active_days"] = len(groupedDaily[groupName])
+			groupSummary["daily"] = groupedDaily[groupName]
+		}
+		dailySummaries[groupName] = groupSummary
 	}
 	
 	result := map[string]interface{}{
 		"date":           date,
 		"total_groups":   len(groupedMessages),
-		"total_messages": len(messages),
+		"total_messages": total,
 		"summaries":      dailySummaries,
 		"generated_at":   time.Now().Format("2006-01-02 15:04:05"),
 	}
+	if _time != "" {
+		delete(result, "date")
+		result["time"] = _time
+		result["start_date"] = start.Format("2006-01-02")
+		result["end_date"] = end.Format("2006-01-02")
+		result["days"] = days
+	}
 	
 	c.JSON(http.StatusOK, result)
 }
@@ -786,23 +1090,25 @@ func (s *Service) GetGoldenQuotes(c *gin.Context) {
 	start := targetDate
 	end := targetDate.AddDate(0, 0, 1)
 	
-	// 获取当日消息
-	messages, err := s.db.GetMessages(start, end, talker, "", "", 10000, 0)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get daily messages"})
-		return
-	}
-	
-	// 提取文本消息
+	// 逐条读取当日消息，只保留文本内容，最多 10000 条
 	var textMessages []string
-	for _, msg := range messages {
+	total := 0
+	err = s.db.IterMessages(c.Request.Context(), start, end, talker, "", "", "", false, func(msg *model.Message) error {
 		if msg.Type == 1 && msg.Content != "" && len(msg.Content) > 10 {
 			textMessages = append(textMessages, msg.Content)
 		}
+		if total++; total >= 10000 {
+			return errors.ErrIterStop
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get daily messages"})
+		return
 	}
 	
 	// 生成金句
-	goldenQuotes := extractGoldenQuotes(textMessages)
+	goldenQuotes := analysis.GoldenQuotes(textMessages)
 	
 	result := map[string]interface{}{
 		"date":         date,
@@ -822,42 +1128,23 @@ type topicSummary struct {
 }
 
 // generateTopicSummary 生成主题汇总
-func generateTopicSummary(contents []string) topicSummary {
-	// 简单的关键词提取逻辑
+func generateTopicSummary(lang string, contents []string, rules *analysis.KeywordRules) topicSummary {
 	keywords := []string{}
 	topics := []string{}
 	
-	// 统计高频词汇
-	wordCount := make(map[string]int)
-	for _, content := range contents {
-		words := strings.Fields(content)
-		for _, word := range words {
-			if len(word) > 1 {
-				wordCount[word]++
-			}
-		}
-	}
-	
-	// 提取高频关键词
-	for word, count := range wordCount {
-		if count >= 3 && len(word) > 1 {
-			keywords = append(keywords, word)
-		}
-	}
-	
-	// 限制关键词数量
-	if len(keywords) > 10 {
-		keywords = keywords[:10]
+	// 按关键词规则提取高频词（过滤停用词，包含关注词）
+	for _, k := range analysis.Keywords(contents, rules, 10) {
+		keywords = append(keywords, k.Word)
 	}
 	
 	// 生成主题
 	if len(contents) > 0 {
-		topics = append(topics, "日常交流")
+		topics = append(topics, i18n.T(lang, "topic.daily"))
 		if len(keywords) > 5 {
-			topics = append(topics, "热门话题讨论")
+			topics = append(topics, i18n.T(lang, "topic.hot"))
 		}
 		if len(contents) > 100 {
-			topics = append(topics, "活跃群聊")
+			topics = append(topics, i18n.T(lang, "topic.active"))
 		}
 	}
 	
@@ -868,71 +1155,17 @@ func generateTopicSummary(contents []string) topicSummary {
 }
 
 // getActivityLevel 获取活跃度等级
-func getActivityLevel(messageCount int) string {
+func getActivityLevel(lang string, messageCount int) string {
 	switch {
 	case messageCount >= 100:
-		return "🔥 非常活跃"
+		return i18n.T(lang, "activity.very_active")
 	case messageCount >= 50:
-		return "⚡ 活跃"
+		return i18n.T(lang, "activity.active")
 	case messageCount >= 20:
-		return "📈 一般"
+		return i18n.T(lang, "activity.normal")
 	case messageCount >= 10:
-		return "📊 较少"
+		return i18n.T(lang, "activity.low")
 	default:
-		return "😴 安静"
-	}
-}
-
-// extractGoldenQuotes 提取金句
-func extractGoldenQuotes(messages []string) []map[string]interface{} {
-	var quotes []map[string]interface{}
-	
-	// 简单的金句提取逻辑
-	for i, msg := range messages {
-		// 筛选可能成为金句的消息
-		if len(msg) > 15 && len(msg) < 200 {
-			// 检查是否包含特殊符号或表情
-			if strings.Contains(msg, "！") || strings.Contains(msg, "？") || 
-			   strings.Contains(msg, "💡") || strings.Contains(msg, "🌟") ||
-			   strings.Contains(msg, "金句") || strings.Contains(msg, "经典") {
-				
-				quotes = append(quotes, map[string]interface{}{
-					"content": msg,
-					"index":   i + 1,
-					"length":  len(msg),
-				})
-			}
-		}
-	}
-	
-	// 如果金句不够，选择一些较长的消息
-	if len(quotes) < 10 {
-		for i, msg := range messages {
-			if len(msg) > 30 && len(quotes) < 10 {
-				// 避免重复
-				isDuplicate := false
-				for _, quote := range quotes {
-					if quote["content"] == msg {
-						isDuplicate = true
-						break
-					}
-				}
-				
-				if !isDuplicate {
-					quotes = append(quotes, map[string]interface{}{
-						"content": msg,
-						"index":   i + 1,
-						"length":  len(msg),
-					})
-				}
-			}
-		}
-	}
-	
-	// 限制数量
-	if len(quotes) > 10 {
-		quotes = quotes[:10]
+		return i18n.T(lang, "activity.quiet")
 	}
-	
-	return quotes
 }
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/chatlog/conf"
+	"github.com/sjzar/chatlog/internal/chatlog/scheduler"
+)
+
+// startScheduler 根据配置启动定时任务，每个定时任务到点后提交对应类型的后台任务
+func (s *Service) startScheduler() {
+	s.scheduler = scheduler.New()
+	for i, task := range s.ctx.Schedules {
+		task := task
+		if task.Name == "" {
+			task.Name = fmt.Sprintf("schedule-%d", i+1)
+		}
+		err := s.scheduler.Add(task.Name, task.Cron, func() {
+			s.runSchedule(task)
+		})
+		if err != nil {
+			log.Err(err).Msgf("invalid schedule %s", task.Name)
+		}
+	}
+	s.scheduler.Start()
+}
+
+func (s *Service) stopScheduler() {
+	if s.scheduler != nil {
+		s.scheduler.Stop()
+	}
+}
+
+// runSchedule 提交定时任务对应的后台任务，任务结束后通知 webhook
+func (s *Service) runSchedule(task conf.Schedule) {
+	params := map[string]string{
+		"time":       task.Time,
+		"talker":     strings.Join(task.Talkers, ","),
+		"recipients": strings.Join(task.Recipients, ","),
+	}
+	j, err := s.jobs.Submit(task.Type, params)
+	if err != nil {
+		log.Err(err).Msgf("schedule %s submit job failed", task.Name)
+		return
+	}
+
+	if task.Webhook == "" {
+		return
+	}
+	<-s.jobs.Done(j.ID)
+	j, err = s.jobs.Get(j.ID)
+	if err != nil {
+		return
+	}
+	payload := s.jobPayload("schedule.finished", j)
+	payload["schedule"] = task.Name
+	if err := postWebhook(task.Webhook, "", "schedule.finished", payload); err != nil {
+		log.Err(err).Msgf("schedule %s webhook failed", task.Name)
+	}
+}
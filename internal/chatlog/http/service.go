@@ -2,13 +2,21 @@ package http
 
 import (
 	"context"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/sjzar/chatlog/internal/chatlog/analysis"
 	"github.com/sjzar/chatlog/internal/chatlog/ctx"
 	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/chatlog/job"
 	"github.com/sjzar/chatlog/internal/chatlog/mcp"
+	"github.com/sjzar/chatlog/internal/chatlog/scheduler"
 	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/pkg/mdns"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
@@ -16,6 +24,9 @@ import (
 
 const (
 	DefalutHTTPAddr = "127.0.0.1:5030"
+
+	// ShutdownTimeout 收到退出信号后等待处理中请求完成的最长时间
+	ShutdownTimeout = 30 * time.Second
 )
 
 type Service struct {
@@ -23,8 +34,32 @@ type Service struct {
 	db  *database.Service
 	mcp *mcp.Service
 
-	router *gin.Engine
-	server *http.Server
+	opts      atomic.Pointer[analysis.Options]
+	auth      *authenticator
+	audit     *auditLog
+	latency   *latencyMetrics
+	lock      *idleLock
+	jobs      *job.Manager
+	cache     *responseCache
+	stopCache func()
+	scheduler *scheduler.Scheduler
+	mdns      *mdns.Server
+
+	// 允许访问的客户端网段，由 http.allow 解析
+	allow atomic.Pointer[[]*net.IPNet]
+
+	// 各账号的化名对照表，未配置 redact_salt 时共用启动时随机生成的 salt
+	redactMu   sync.Mutex
+	redactors  map[string]*accountRedactor
+	redactSalt string
+
+	router    *gin.Engine
+	server    *http.Server
+	redirect  *http.Server
+	listeners []net.Listener
+
+	// 服务关闭时关闭，事件推送等长连接据此结束，不阻塞关闭
+	closing chan struct{}
 }
 
 func NewService(ctx *ctx.Context, db *database.Service, mcp *mcp.Service) *Service {
@@ -49,61 +84,200 @@ func NewService(ctx *ctx.Context, db *database.Service, mcp *mcp.Service) *Servi
 		mcp:    mcp,
 		router: router,
 	}
+	s.latency = newLatencyMetrics()
+
+	s.setOptions()
+	s.auth = newAuthenticator(ctx.Settings().Auth)
+	passphrase := ctx.HTTP.Lock.Passphrase
+	if passphrase == "" {
+		passphrase = ctx.HTTP.Auth.Password
+	}
+	s.lock = newIdleLock(ctx.HTTP.Lock.Idle, passphrase)
+	s.initAudit()
+	s.jobs = job.NewManager(s.jobsDir)
+	s.jobs.OnFinish(s.notifyJob)
+	s.registerJobs()
+	s.cache = newResponseCache(time.Duration(ctx.Cache.TTL)*time.Second, ctx.Cache.Dir)
 
 	s.initRouter()
 	return s
 }
 
-func (s *Service) Start() error {
+// Reload 应用重新加载的配置，ctx 已由调用方更新
+func (s *Service) Reload() {
+	settings := s.ctx.Settings()
+	s.setOptions()
+	s.auth.update(settings.Auth)
+	s.setAllowlist(settings.Allow)
+	s.cache.setTTL(time.Duration(settings.CacheTTL) * time.Second)
+}
 
-	if s.ctx.HTTPAddr == "" {
-		s.ctx.HTTPAddr = DefalutHTTPAddr
-	}
+// setOptions 按当前关键词规则生成分析参数
+func (s *Service) setOptions() {
+	opts := analysis.OptionsOf(s.ctx.Settings().Keywords)
+	s.opts.Store(&opts)
+}
 
-	s.server = &http.Server{
-		Addr:    s.ctx.HTTPAddr,
-		Handler: s.router,
+// options 当前的分析参数
+func (s *Service) options() analysis.Options {
+	return *s.opts.Load()
+}
+
+func (s *Service) Start() error {
+
+	if err := s.initServer(); err != nil {
+		return err
 	}
 
 	go func() {
 		// Handle error from Run
-		if err := s.server.ListenAndServe(); err != nil {
+		if err := s.serve(); err != nil {
 			log.Err(err).Msg("Failed to start HTTP server")
 		}
 	}()
 
-	log.Info().Msg("Starting HTTP server on " + s.ctx.HTTPAddr)
+	log.Info().Msg("Starting HTTP server on " + strings.Join(s.listenAddrs(), ", "))
+
+	s.startScheduler()
+	s.startIdleLock()
+	s.startMDNS()
+	s.startCacheInvalidation()
 
 	return nil
 }
 
 func (s *Service) ListenAndServe() error {
 
+	if err := s.initServer(); err != nil {
+		return err
+	}
+
+	log.Info().Msg("Starting HTTP server on " + strings.Join(s.listenAddrs(), ", "))
+
+	s.startScheduler()
+	s.startIdleLock()
+	s.startMDNS()
+	s.startCacheInvalidation()
+	defer s.stopScheduler()
+	defer s.stopIdleLock()
+	defer s.stopMDNS()
+	defer s.stopCacheInvalidation()
+
+	return s.serve()
+}
+
+// initServer 创建 HTTP 服务并监听所有地址，启用 HTTPS 时加载证书，并按配置启动 HTTP 到 HTTPS 的重定向服务
+func (s *Service) initServer() error {
+
 	if s.ctx.HTTPAddr == "" {
 		s.ctx.HTTPAddr = DefalutHTTPAddr
 	}
 
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	s.listeners = s.listeners[:0]
+	for _, addr := range s.listenAddrs() {
+		l, err := listen(addr)
+		if err != nil {
+			for _, l := range s.listeners {
+				l.Close()
+			}
+			s.listeners = nil
+			return err
+		}
+		s.listeners = append(s.listeners, l)
+	}
+
 	s.server = &http.Server{
-		Addr:    s.ctx.HTTPAddr,
-		Handler: s.router,
+		Addr:        s.ctx.HTTPAddr,
+		Handler:     unixHandler(basePathHandler(s.ctx.HTTP.Prefix(), accountHandler(s.router))),
+		TLSConfig:   tlsConfig,
+		ConnContext: unixConnContext,
+	}
+	s.logPprof()
+
+	closing := make(chan struct{})
+	s.closing = closing
+	s.server.RegisterOnShutdown(func() { close(closing) })
+
+	if tlsConfig != nil && s.ctx.HTTP.TLS.RedirectAddr != "" {
+		s.redirect = &http.Server{
+			Addr:    s.ctx.HTTP.TLS.RedirectAddr,
+			Handler: redirectHandler(s.tcpAddr()),
+		}
+		go func() {
+			if err := s.redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Err(err).Msg("Failed to start HTTPS redirect server")
+			}
+		}()
+		log.Info().Msg("Redirecting HTTP requests on " + s.ctx.HTTP.TLS.RedirectAddr + " to HTTPS")
 	}
 
-	log.Info().Msg("Starting HTTP server on " + s.ctx.HTTPAddr)
-	return s.server.ListenAndServe()
+	return nil
+}
+
+// serve 阻塞处理所有监听地址上的请求，服务被关闭时返回 nil
+// unix socket 仅限本机访问，始终使用 HTTP
+func (s *Service) serve() error {
+	// Serve 会为 HTTP/2 补全 TLSConfig，需在启动前确定是否启用 HTTPS
+	useTLS := s.server.TLSConfig != nil
+	errCh := make(chan error, len(s.listeners))
+	for _, l := range s.listeners {
+		go func(l net.Listener) {
+			var err error
+			if _, ok := l.Addr().(*net.UnixAddr); ok || !useTLS {
+				err = s.server.Serve(l)
+			} else {
+				err = s.server.ServeTLS(l, "", "")
+			}
+			if err == http.ErrServerClosed {
+				err = nil
+			}
+			errCh <- err
+		}(l)
+	}
+
+	var err error
+	for range s.listeners {
+		if e := <-errCh; e != nil && err == nil {
+			err = e
+			s.server.Close()
+		}
+	}
+	return err
 }
 
 func (s *Service) Stop() error {
+	return s.Shutdown(2 * time.Second)
+}
+
+// Shutdown 停止接收新请求，等待处理中的请求完成后关闭服务，超过 timeout 时强制断开剩余连接
+func (s *Service) Shutdown(timeout time.Duration) error {
+
+	s.stopScheduler()
+	s.stopIdleLock()
+	s.stopMDNS()
+	s.stopCacheInvalidation()
 
 	if s.server == nil {
 		return nil
 	}
 
 	// 使用超时上下文优雅关闭
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	if s.redirect != nil {
+		s.redirect.Shutdown(ctx)
+		s.redirect = nil
+	}
+
 	if err := s.server.Shutdown(ctx); err != nil {
 		log.Debug().Err(err).Msg("Failed to shutdown HTTP server")
+		s.server.Close()
 		return nil
 	}
 
@@ -0,0 +1,37 @@
+package http
+
+import (
+	"io/fs"
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+// overlayFS 优先读取目录中的文件，不存在时使用内嵌文件
+type overlayFS struct {
+	dir  fs.FS
+	base fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if f, err := o.dir.Open(name); err == nil {
+		return f, nil
+	}
+	return o.base.Open(name)
+}
+
+// staticFS 页面与静态文件，配置 http.static_dir 时目录中的同名文件覆盖内嵌文件
+// 每次请求重新读取目录，修改后刷新页面即可生效
+func (s *Service) staticFS() fs.FS {
+	embedded, _ := fs.Sub(EFS, "static")
+	dir := s.ctx.HTTP.StaticDir
+	if dir == "" {
+		return embedded
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		log.Warn().Msgf("static dir %s is not a directory, using embedded files", dir)
+		return embedded
+	}
+	log.Info().Msgf("Serving static files from %s", dir)
+	return overlayFS{dir: os.DirFS(dir), base: embedded}
+}
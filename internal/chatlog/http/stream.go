@@ -0,0 +1,106 @@
+package http
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// chatlogStream 单个会话聊天记录的流式查询条件
+type chatlogStream struct {
+	start, end  time.Time
+	talker      string
+	sender      string
+	keyword     string
+	msgType     string
+	desc        bool
+	limit       int
+	offset      int
+	inline      bool
+	annotations bool
+	jsonl       bool
+}
+
+// streamable 判断能否逐条输出：多个会话的结果需要整体按时间排序，只有单个会话的纯文本与 NDJSON 输出可以流式处理
+func streamable(talker, format string) bool {
+	if talker == "" || strings.Contains(talker, ",") || strings.HasPrefix(talker, database.TagPrefix) {
+		return false
+	}
+	switch format {
+	case "csv", "json":
+		return false
+	}
+	return true
+}
+
+// streamChatlog 逐条读取并输出聊天记录，不在内存中保留全部消息
+// 输出第一条消息前出错时按普通错误响应，之后出错只能中断输出
+func (s *Service) streamChatlog(c *gin.Context, q chatlogStream) {
+	lang := langOf(c.Request)
+	prefix := mediaPrefix(c)
+	fields := parseFields(c.Query("fields"))
+	timeFormat := util.PerfectTimeFormat(q.start, q.end)
+	mask := s.imageMask(c)
+
+	written := false
+	begin := func() {
+		if written {
+			return
+		}
+		written = true
+		if q.jsonl {
+			writeJSONLHeader(c)
+			return
+		}
+		c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		c.Writer.Header().Set("Cache-Control", "no-cache")
+		c.Writer.Header().Set("Connection", "keep-alive")
+		c.Writer.Flush()
+	}
+
+	count := 0
+	err := s.db.IterMessages(c.Request.Context(), q.start, q.end, q.talker, q.sender, q.keyword, q.msgType, q.desc, func(m *model.Message) error {
+		count++
+		if count <= q.offset {
+			return nil
+		}
+		begin()
+		messages := []*model.Message{m}
+		if q.jsonl {
+			m.SetMediaURLs(prefix)
+			if q.annotations {
+				s.annotate(c, messages)
+			}
+			if q.inline {
+				s.inlineImages(c.Request.Context(), messages, s.ctx.Settings().InlineMediaMaxSize, mask)
+			}
+			writeJSONLine(c, fields, m)
+		} else {
+			setLang(messages, lang)
+			c.Writer.WriteString(m.PlainText(false, timeFormat, hostOf(c)))
+			c.Writer.WriteString("\n")
+			c.Writer.Flush()
+		}
+		if q.limit > 0 && count >= q.offset+q.limit {
+			return errors.ErrIterStop
+		}
+		return nil
+	})
+	if err != nil {
+		if !written {
+			errors.Err(c, err)
+			return
+		}
+		log.Err(err).Msgf("流式输出 %s 的聊天记录中断", q.talker)
+		return
+	}
+	begin()
+	c.Writer.Flush()
+}
@@ -0,0 +1,154 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/chatlog/sidecar"
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// tagDetail 标签及带有该标签的会话
+type tagDetail struct {
+	Name    string         `json:"name"`
+	Talkers []*participant `json:"talkers"`
+}
+
+// tagBody 标签的请求体，会话可以是 ID、微信号、备注或昵称
+type tagBody struct {
+	Talkers []string `json:"talkers"`
+}
+
+// tagParam 路径中的标签名称，不能为空或包含逗号
+func tagParam(c *gin.Context) (string, bool) {
+	tag := strings.TrimSpace(c.Param("tag"))
+	if tag == "" || strings.Contains(tag, ",") {
+		errors.Err(c, errors.InvalidArg("tag"))
+		return "", false
+	}
+	return tag, true
+}
+
+// ListTags 按名称列出所有标签，talker 不为空时只列出该会话的标签
+func (s *Service) ListTags(c *gin.Context) {
+	q := struct {
+		Talker string `form:"talker"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if q.Talker != "" {
+		subject, err := s.findSubject(ctx, q.Talker, false)
+		if err != nil {
+			errors.Err(c, err)
+			return
+		}
+		q.Talker = subject.Talker
+	}
+	tags, err := s.db.Sidecar().Tags(ctx, s.db.AccountName(ctx), q.Talker)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": tags, "total": len(tags)})
+}
+
+// GetTag 查询带有标签的会话，附带名称与头像
+func (s *Service) GetTag(c *gin.Context) {
+	tag, ok := tagParam(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	talkers, err := s.db.Sidecar().TagTalkers(ctx, s.db.AccountName(ctx), tag)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if len(talkers) == 0 {
+		errors.Err(c, errors.TagNotFound(tag))
+		return
+	}
+	ret := &tagDetail{Name: tag, Talkers: make([]*participant, 0, len(talkers))}
+	for _, talker := range talkers {
+		ret.Talkers = append(ret.Talkers, s.participant(ctx, talker))
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// PutTag 设置带有标签的会话，替换原有的会话
+// 请求体：{"talkers": ["wxid_xxx", "xxx@chatroom"]}
+func (s *Service) PutTag(c *gin.Context) {
+	s.saveTag(c, true)
+}
+
+// AddTag 为会话添加标签，保留原有的会话
+// 请求体：{"talkers": ["wxid_xxx", "xxx@chatroom"]}
+func (s *Service) AddTag(c *gin.Context) {
+	s.saveTag(c, false)
+}
+
+// saveTag 按 ID、微信号、备注或昵称查找会话后保存标签，返回保存后的标签
+func (s *Service) saveTag(c *gin.Context, replace bool) {
+	tag, ok := tagParam(c)
+	if !ok {
+		return
+	}
+	var req tagBody
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Talkers) == 0 {
+		errors.Err(c, errors.InvalidArg("talkers"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	talkers := make([]string, 0, len(req.Talkers))
+	for _, key := range req.Talkers {
+		subject, err := s.findSubject(ctx, strings.TrimSpace(key), false)
+		if err != nil {
+			errors.Err(c, err)
+			return
+		}
+		talkers = append(talkers, subject.Talker)
+	}
+
+	store, account := s.db.Sidecar(), s.db.AccountName(ctx)
+	if err := store.AddTag(ctx, account, tag, talkers, replace); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	talkers, err := store.TagTalkers(ctx, account, tag)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &sidecar.Tag{Name: tag, Talkers: talkers})
+}
+
+// DeleteTag 删除标签，路径中带有会话时只移除该会话的标签
+func (s *Service) DeleteTag(c *gin.Context) {
+	tag, ok := tagParam(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	var talkers []string
+	if key := c.Param("talker"); key != "" {
+		subject, err := s.findSubject(ctx, key, false)
+		if err != nil {
+			errors.Err(c, err)
+			return
+		}
+		talkers = append(talkers, subject.Talker)
+	}
+	if err := s.db.Sidecar().RemoveTag(ctx, s.db.AccountName(ctx), tag, talkers...); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,181 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// selfSignedValidity 自签名证书有效期，过期后下次启动时重新生成
+	selfSignedValidity = 365 * 24 * time.Hour
+)
+
+// tlsConfig 根据配置加载证书，未启用 HTTPS 时返回 nil
+func (s *Service) tlsConfig() (*tls.Config, error) {
+	conf := s.ctx.HTTP.TLS
+	if (conf.CertFile == "") != (conf.KeyFile == "") {
+		return nil, fmt.Errorf("tls cert_file and key_file must be set together")
+	}
+	if !conf.Enabled() {
+		if conf.ClientCA != "" {
+			return nil, fmt.Errorf("client certificate auth requires https, set a certificate or enable self-signed")
+		}
+		return nil, nil
+	}
+
+	certFile, keyFile := conf.CertFile, conf.KeyFile
+	if certFile == "" || keyFile == "" {
+		dir := filepath.Join(s.ctx.ConfigDir, "tls")
+		certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		if err := ensureSelfSigned(certFile, keyFile, s.tcpAddr()); err != nil {
+			return nil, err
+		}
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	// 页面访问可不带证书，由 clientCertMiddleware 限制其他请求
+	if conf.ClientCA != "" {
+		data, err := os.ReadFile(conf.ClientCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", conf.ClientCA)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config, nil
+}
+
+// hasClientCert 请求是否携带经过验证的客户端证书
+func hasClientCert(r *http.Request) bool {
+	return r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+}
+
+// clientCertRequired 除页面与静态文件外的请求都需要客户端证书，包括接口、多媒体、订阅源、监控与 MCP
+func clientCertRequired(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return true
+	}
+	switch r.URL.Path {
+	case "/", "/favicon.ico", "/swagger", "/login":
+		return false
+	}
+	return !strings.HasPrefix(r.URL.Path, "/static/")
+}
+
+// clientCertMiddleware 配置客户端证书 CA 后，拒绝未携带有效证书的非页面请求
+func (s *Service) clientCertMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.ctx.HTTP.TLS.ClientCA == "" || !clientCertRequired(c.Request) || hasClientCert(c.Request) || fromUnixSocket(c.Request) {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "client certificate required"})
+	}
+}
+
+// ensureSelfSigned 证书不存在或已过期时生成自签名证书，包含 localhost 与监听地址
+func ensureSelfSigned(certFile, keyFile, addr string) error {
+	if data, err := os.ReadFile(certFile); err == nil {
+		if block, _ := pem.Decode(data); block != nil {
+			if cert, err := x509.ParseCertificate(block.Bytes); err == nil && time.Now().Before(cert.NotAfter) {
+				if _, err := os.Stat(keyFile); err == nil {
+					return nil
+				}
+			}
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Chatlog"}, CommonName: "chatlog"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil && host != "" {
+		if ip := net.ParseIP(host); ip != nil {
+			if !ip.IsUnspecified() && !ip.IsLoopback() {
+				template.IPAddresses = append(template.IPAddresses, ip)
+			}
+		} else if host != "localhost" {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certFile), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+	log.Info().Msgf("generated self-signed certificate %s", certFile)
+	return nil
+}
+
+// redirectHandler 将 HTTP 请求重定向到 HTTPS 服务的同一路径
+func redirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sjzar/chatlog/pkg/trace"
+	"github.com/sjzar/chatlog/pkg/util/dat2img"
+	"github.com/sjzar/chatlog/pkg/util/silk"
+
+	"github.com/gin-gonic/gin"
+)
+
+// traceMiddleware 为每个请求创建链路追踪的 Span，请求头带有 traceparent 时延续调用方的链路
+// 响应头 X-Trace-Id 为链路 ID，便于在追踪后端中查找慢请求
+func (s *Service) traceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !trace.Enabled() {
+			c.Next()
+			return
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx, span := trace.StartServer(c.Request.Context(), c.Request.Method+" "+route, c.GetHeader("traceparent"),
+			trace.String("http.request.method", c.Request.Method),
+			trace.String("http.route", route),
+			trace.String("url.path", c.Request.URL.Path),
+			trace.String("client.address", c.ClientIP()),
+		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+		c.Header("X-Trace-Id", span.TraceID())
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(trace.Int("http.response.status_code", status))
+		if status >= 500 {
+			span.SetError(fmt.Errorf("status %d", status))
+		}
+		if len(c.Errors) > 0 {
+			span.SetError(c.Errors.Last())
+		}
+	}
+}
+
+// decodeImage 解码微信图片文件（.dat），并记录解码耗时
+func decodeImage(ctx context.Context, data []byte) ([]byte, string, error) {
+	_, span := trace.Start(ctx, "media.decode_image", trace.Int("media.size", len(data)))
+	defer span.End()
+	out, ext, err := dat2img.Dat2Image(data)
+	span.SetError(err)
+	return out, ext, err
+}
+
+// decodeVoice 将 SILK 语音转换为 MP3，并记录转换耗时
+func decodeVoice(ctx context.Context, data []byte) ([]byte, error) {
+	_, span := trace.Start(ctx, "media.decode_voice", trace.Int("media.size", len(data)))
+	defer span.End()
+	out, err := silk.Silk2MP3(data)
+	span.SetError(err)
+	return out, err
+}
@@ -0,0 +1,119 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// maxWallSize 照片墙单页最多返回的图片数
+const maxWallSize = 500
+
+// wallImage 照片墙中的一张图片
+type wallImage struct {
+	Seq      int64     `json:"seq"`
+	Time     time.Time `json:"time"`
+	Sender   string    `json:"sender"`
+	IsSelf   bool      `json:"isSelf"`
+	Key      string    `json:"key"`
+	MediaURL string    `json:"mediaUrl"`
+	ThumbURL string    `json:"thumbUrl"` // 没有缩略图时与 mediaUrl 相同
+}
+
+// wallMonth 同一个月的图片
+type wallMonth struct {
+	Month  string       `json:"month"` // 本地时间的月份，如 2023-01
+	Images []*wallImage `json:"images"`
+}
+
+// wall 照片墙
+type wall struct {
+	Talker string       `json:"talker"`
+	Months []*wallMonth `json:"months"`
+	Limit  int          `json:"limit"`
+	Offset int          `json:"offset"`
+	More   bool         `json:"more"` // 是否还有下一页，下一页的 offset 为 offset + limit
+}
+
+// GetMediaWall 照片墙，按月分组返回会话中的图片与缩略图地址，默认从最新的图片开始
+// 分页按图片数量计算，同一个月的图片可能分布在相邻的两页中，页面按 month 合并即可
+func (s *Service) GetMediaWall(c *gin.Context) {
+	q := struct {
+		Talker string `form:"talker"`
+		Time   string `form:"time,default=all"`
+		Order  string `form:"order"`
+		Limit  int    `form:"limit,default=100"`
+		Offset int    `form:"offset"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if q.Talker == "" || strings.Contains(q.Talker, ",") {
+		errors.Err(c, errors.ErrTalkerEmpty)
+		return
+	}
+	start, end, ok := util.TimeRangeOf(q.Time)
+	if !ok {
+		errors.Err(c, errors.InvalidArg("time"))
+		return
+	}
+	if q.Limit <= 0 || q.Limit > maxWallSize {
+		errors.Err(c, errors.InvalidArg("limit"))
+		return
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+	desc := true
+	switch strings.ToLower(q.Order) {
+	case "", "desc":
+	case "asc":
+		desc = false
+	default:
+		errors.Err(c, errors.InvalidArg("order"))
+		return
+	}
+
+	messages, err := s.db.GetMessages(c.Request.Context(), start, end, q.Talker, "", "", "image", desc, q.Limit, q.Offset)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	ret := &wall{Talker: q.Talker, Months: make([]*wallMonth, 0), Limit: q.Limit, Offset: q.Offset, More: len(messages) == q.Limit}
+	prefix := mediaPrefix(c)
+	for _, m := range messages {
+		_, keys := m.MediaKeys()
+		if len(keys) == 0 {
+			continue
+		}
+		m.SetMediaURLs(prefix)
+		image := &wallImage{
+			Seq:      m.Seq,
+			Time:     m.Time,
+			Sender:   m.Sender,
+			IsSelf:   m.IsSelf,
+			Key:      keys[0],
+			MediaURL: m.MediaURL,
+			ThumbURL: m.ThumbURL,
+		}
+		if image.ThumbURL == "" {
+			image.ThumbURL = image.MediaURL
+		}
+
+		month := m.Time.Local().Format("2006-01")
+		if n := len(ret.Months); n == 0 || ret.Months[n-1].Month != month {
+			ret.Months = append(ret.Months, &wallMonth{Month: month})
+		}
+		last := ret.Months[len(ret.Months)-1]
+		last.Images = append(last.Images, image)
+	}
+	c.JSON(http.StatusOK, ret)
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/chatlog/job"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// 请求头
+const (
+	WebhookEventHeader     = "X-Chatlog-Event"
+	WebhookTimestampHeader = "X-Chatlog-Timestamp"
+	WebhookSignatureHeader = "X-Chatlog-Signature"
+)
+
+// notifyJob 任务结束时通知所有匹配的 webhook
+func (s *Service) notifyJob(j *job.Job) {
+	event := "job." + j.Status
+	payload := s.jobPayload(event, j)
+	for _, hook := range s.ctx.Settings().Webhooks {
+		if hook.URL == "" || !matchEvent(hook.Events, event) {
+			continue
+		}
+		if err := postWebhook(hook.URL, hook.Secret, event, payload); err != nil {
+			log.Err(err).Str("url", hook.URL).Msgf("webhook %s failed", event)
+		}
+	}
+}
+
+// jobPayload 构造任务通知内容，任务结果中包含文件地址时附带完整的下载链接
+func (s *Service) jobPayload(event string, j *job.Job) map[string]interface{} {
+	payload := map[string]interface{}{
+		"event": event,
+		"job":   j,
+	}
+	var result map[string]interface{}
+	switch r := j.Result.(type) {
+	case gin.H:
+		result = r
+	case map[string]interface{}:
+		result = r
+	}
+	if url, ok := result["url"].(string); ok && url != "" {
+		payload["download_url"] = s.absoluteURL(url)
+	}
+	return payload
+}
+
+// absoluteURL 将服务内的相对路径（已带有路径前缀）转换为完整地址
+// 配置了 public_url 时以其替换路径前缀，否则使用监听地址，监听所有地址时使用局域网地址
+func (s *Service) absoluteURL(path string) string {
+	if public := strings.TrimSuffix(s.ctx.HTTP.PublicURL, "/"); public != "" {
+		return public + strings.TrimPrefix(path, s.ctx.HTTP.Prefix())
+	}
+
+	addr := s.tcpAddr()
+	if addr == "" {
+		addr = DefalutHTTPAddr
+	}
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+			host = "127.0.0.1"
+			if ips := lanIPs(""); len(ips) > 0 {
+				host = ips[0].String()
+			}
+		}
+		addr = net.JoinHostPort(host, port)
+	}
+	if s.ctx.HTTP.TLS.Enabled() {
+		return "https://" + addr + path
+	}
+	return "http://" + addr + path
+}
+
+func matchEvent(events []string, event string) bool {
+	if len(events) == 0 {
+		return true
+	}
+	for _, e := range events {
+		if e == event || e == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// postWebhook 以 JSON 格式 POST 通知
+// 配置了 secret 时，X-Chatlog-Signature 为 sha256=hex(HMAC-SHA256(secret, timestamp + "." + body))
+func postWebhook(url, secret, event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, event)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	if secret != "" {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+signWebhook(secret, timestamp, data))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,78 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 任务状态
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCanceled  = "canceled"
+)
+
+// Func 任务执行函数，通过 progress 上报进度百分比（0-100），ctx 取消时应尽快返回
+type Func func(ctx context.Context, params map[string]string, progress func(percent int)) (interface{}, error)
+
+// Job 异步任务
+type Job struct {
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	Params     map[string]string `json:"params"`
+	Status     string            `json:"status"`
+	Progress   int               `json:"progress"`
+	Error      string            `json:"error,omitempty"`
+	Result     interface{}       `json:"result,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	StartedAt  *time.Time        `json:"startedAt,omitempty"`
+	FinishedAt *time.Time        `json:"finishedAt,omitempty"`
+
+	mu     sync.RWMutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Finished 任务是否已结束
+func (j *Job) Finished() bool {
+	switch j.Status {
+	case StatusSucceeded, StatusFailed, StatusCanceled:
+		return true
+	}
+	return false
+}
+
+// snapshot 返回任务当前状态的副本，避免读写竞争
+func (j *Job) snapshot() *Job {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return &Job{
+		ID:         j.ID,
+		Type:       j.Type,
+		Params:     j.Params,
+		Status:     j.Status,
+		Progress:   j.Progress,
+		Error:      j.Error,
+		Result:     j.Result,
+		CreatedAt:  j.CreatedAt,
+		StartedAt:  j.StartedAt,
+		FinishedAt: j.FinishedAt,
+	}
+}
+
+func (j *Job) setProgress(percent int) {
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if percent > j.Progress {
+		j.Progress = percent
+	}
+}
@@ -0,0 +1,346 @@
+package job
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+const (
+	// DefaultConcurrency 同时运行的任务数量
+	DefaultConcurrency = 2
+	// FinishedTTL 已结束的任务在内存中保留的时间，之后只能从持久化目录查询
+	FinishedTTL = time.Hour
+	// MaxFinished 内存中最多保留的已结束任务数量，超出时丢弃最早结束的任务
+	MaxFinished = 100
+	// cancelWait 取消任务时等待任务结束的时间
+	cancelWait = 5 * time.Second
+)
+
+// Manager 异步任务管理
+// 任务在后台 goroutine 中执行，结束后以 <id>.json 的形式持久化到 dir 目录，重启后仍可查询结果
+type Manager struct {
+	dir   func() string
+	funcs map[string]Func
+	jobs  map[string]*Job
+	hooks []func(*Job)
+	sem   chan struct{}
+	mu    sync.RWMutex
+}
+
+// NewManager 创建任务管理器，dir 返回任务结果的持久化目录，为空时不持久化
+func NewManager(dir func() string) *Manager {
+	return &Manager{
+		dir:   dir,
+		funcs: make(map[string]Func),
+		jobs:  make(map[string]*Job),
+		sem:   make(chan struct{}, DefaultConcurrency),
+	}
+}
+
+// Register 注册任务类型
+func (m *Manager) Register(_type string, fn Func) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.funcs[_type] = fn
+}
+
+// OnFinish 注册任务结束（成功、失败或取消）时的回调
+func (m *Manager) OnFinish(fn func(*Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, fn)
+}
+
+// Types 返回已注册的任务类型
+func (m *Manager) Types() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	types := make([]string, 0, len(m.funcs))
+	for t := range m.funcs {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Submit 提交任务，立即返回，任务在后台执行
+func (m *Manager) Submit(_type string, params map[string]string) (*Job, error) {
+	m.mu.Lock()
+	fn, ok := m.funcs[_type]
+	if !ok {
+		m.mu.Unlock()
+		return nil, errors.JobTypeUnsupported(_type)
+	}
+	if params == nil {
+		params = make(map[string]string)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		ID:        newID(),
+		Type:      _type,
+		Params:    params,
+		Status:    StatusPending,
+		CreatedAt: time.Now(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	m.jobs[job.ID] = job
+	m.mu.Unlock()
+
+	go m.run(ctx, job, fn)
+
+	return job.snapshot(), nil
+}
+
+func (m *Manager) run(ctx context.Context, job *Job, fn Func) {
+	defer job.cancel()
+
+	// 等待空闲的执行槽位
+	select {
+	case m.sem <- struct{}{}:
+		defer func() { <-m.sem }()
+	case <-ctx.Done():
+		m.finish(job, nil, ctx.Err())
+		return
+	}
+
+	now := time.Now()
+	job.mu.Lock()
+	job.Status = StatusRunning
+	job.StartedAt = &now
+	job.mu.Unlock()
+
+	result, err := func() (result interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = errors.Newf(nil, http.StatusInternalServerError, "job panic: %v", r)
+			}
+		}()
+		return fn(ctx, job.Params, job.setProgress)
+	}()
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	m.finish(job, result, err)
+}
+
+func (m *Manager) finish(job *Job, result interface{}, err error) {
+	now := time.Now()
+	job.mu.Lock()
+	job.FinishedAt = &now
+	switch {
+	case err == context.Canceled:
+		job.Status = StatusCanceled
+	case err != nil:
+		job.Status = StatusFailed
+		job.Error = err.Error()
+	default:
+		job.Status = StatusSucceeded
+		job.Progress = 100
+		job.Result = result
+	}
+	job.mu.Unlock()
+
+	snapshot := job.snapshot()
+	if err := m.persist(snapshot); err != nil {
+		log.Err(err).Str("job", job.ID).Msg("persist job failed")
+	}
+	close(job.done)
+	m.prune()
+
+	m.mu.RLock()
+	hooks := m.hooks
+	m.mu.RUnlock()
+	for _, fn := range hooks {
+		go fn(snapshot)
+	}
+}
+
+// prune 从内存中移除超过 FinishedTTL 或超出 MaxFinished 的已结束任务
+func (m *Manager) prune() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	finished := make([]*Job, 0)
+	for id, job := range m.jobs {
+		snapshot := job.snapshot()
+		if !snapshot.Finished() {
+			continue
+		}
+		if time.Since(*snapshot.FinishedAt) > FinishedTTL {
+			delete(m.jobs, id)
+			continue
+		}
+		finished = append(finished, snapshot)
+	}
+	if len(finished) <= MaxFinished {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].FinishedAt.Before(*finished[j].FinishedAt)
+	})
+	for _, job := range finished[:len(finished)-MaxFinished] {
+		delete(m.jobs, job.ID)
+	}
+}
+
+// Done 返回任务结束时关闭的 channel，任务不在内存中时返回已关闭的 channel
+func (m *Manager) Done(id string) <-chan struct{} {
+	m.mu.RLock()
+	job, ok := m.jobs[id]
+	m.mu.RUnlock()
+	if !ok {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return job.done
+}
+
+// Get 查询任务，内存中不存在时从持久化目录读取
+func (m *Manager) Get(id string) (*Job, error) {
+	m.mu.RLock()
+	job, ok := m.jobs[id]
+	m.mu.RUnlock()
+	if ok {
+		return job.snapshot(), nil
+	}
+
+	dir := m.persistDir()
+	if dir == "" || !validID(id) {
+		return nil, errors.JobNotFound(id)
+	}
+	job, err := load(filepath.Join(dir, id+".json"))
+	if err != nil {
+		return nil, errors.JobNotFound(id)
+	}
+	return job, nil
+}
+
+// List 列出所有任务（包括已持久化的历史任务），按创建时间倒序
+func (m *Manager) List() []*Job {
+	m.mu.RLock()
+	jobs := make(map[string]*Job, len(m.jobs))
+	for id, job := range m.jobs {
+		jobs[id] = job.snapshot()
+	}
+	m.mu.RUnlock()
+
+	if dir := m.persistDir(); dir != "" {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		for _, file := range files {
+			id := strings.TrimSuffix(filepath.Base(file), ".json")
+			if _, ok := jobs[id]; ok {
+				continue
+			}
+			if job, err := load(file); err == nil {
+				// 列表中不返回结果内容，通过详情接口获取
+				job.Result = nil
+				jobs[id] = job
+			}
+		}
+	}
+
+	ret := make([]*Job, 0, len(jobs))
+	for _, job := range jobs {
+		ret = append(ret, job)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].CreatedAt.After(ret[j].CreatedAt)
+	})
+	return ret
+}
+
+// Cancel 取消未结束的任务，等待任务结束后返回最终状态
+// 任务在 cancelWait 内未响应取消时返回当前状态，任务在执行完成前结束时返回实际的结束状态
+func (m *Manager) Cancel(id string) (*Job, error) {
+	m.mu.RLock()
+	job, ok := m.jobs[id]
+	m.mu.RUnlock()
+	if !ok {
+		if _, err := m.Get(id); err != nil {
+			return nil, err
+		}
+		return nil, errors.JobFinished(id)
+	}
+
+	snapshot := job.snapshot()
+	if snapshot.Finished() {
+		return nil, errors.JobFinished(id)
+	}
+	job.cancel()
+	select {
+	case <-job.done:
+	case <-time.After(cancelWait):
+	}
+	return job.snapshot(), nil
+}
+
+func (m *Manager) persistDir() string {
+	if m.dir == nil {
+		return ""
+	}
+	return m.dir()
+}
+
+func (m *Manager) persist(job *Job) error {
+	dir := m.persistDir()
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.JobPersistFailed(err)
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return errors.JobPersistFailed(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, job.ID+".json"), data, 0600); err != nil {
+		return errors.JobPersistFailed(err)
+	}
+	return nil
+}
+
+func load(path string) (*Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{}
+	if err := json.Unmarshal(data, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func newID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return time.Now().Format("20060102150405") + "-" + hex.EncodeToString(b)
+}
+
+// validID 校验任务 ID，避免路径穿越
+func validID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
@@ -3,19 +3,40 @@ package chatlog
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/sjzar/chatlog/internal/chatlog/analysis"
 	"github.com/sjzar/chatlog/internal/chatlog/conf"
 	"github.com/sjzar/chatlog/internal/chatlog/ctx"
 	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/chatlog/export"
 	"github.com/sjzar/chatlog/internal/chatlog/http"
 	"github.com/sjzar/chatlog/internal/chatlog/mcp"
 	"github.com/sjzar/chatlog/internal/chatlog/wechat"
+	"github.com/sjzar/chatlog/internal/model"
 	iwechat "github.com/sjzar/chatlog/internal/wechat"
+	"github.com/sjzar/chatlog/internal/wechat/android"
+	"github.com/sjzar/chatlog/internal/wechat/decrypt"
+	"github.com/sjzar/chatlog/internal/wechat/importer"
+	"github.com/sjzar/chatlog/internal/wechat/ios"
+	"github.com/sjzar/chatlog/internal/wechat/key"
+	"github.com/sjzar/chatlog/pkg/autostart"
+	"github.com/sjzar/chatlog/pkg/filecrypt"
+	"github.com/sjzar/chatlog/pkg/qrcode"
+	"github.com/sjzar/chatlog/pkg/trace"
 	"github.com/sjzar/chatlog/pkg/util"
 	"github.com/sjzar/chatlog/pkg/util/dat2img"
+	"github.com/sjzar/chatlog/pkg/version"
+	"golang.org/x/term"
 )
 
 // Manager 管理聊天日志应用
@@ -31,6 +52,10 @@ type Manager struct {
 
 	// Terminal UI
 	app *App
+
+	// 关闭后 CommandHTTPServer 优雅退出，用于 Windows 服务的停止请求
+	quit     chan struct{}
+	quitOnce sync.Once
 }
 
 func New(configPath string) (*Manager, error) {
@@ -59,6 +84,7 @@ func New(configPath string) (*Manager, error) {
 		mcp:    mcp,
 		http:   http,
 		wechat: wechat,
+		quit:   make(chan struct{}),
 	}, nil
 }
 
@@ -187,6 +213,71 @@ func (m *Manager) SetHTTPAddr(text string) error {
 	return nil
 }
 
+// ServiceLocked HTTP 服务是否因空闲而锁定
+func (m *Manager) ServiceLocked() bool {
+	return m.http.Locked()
+}
+
+// UnlockService 输入口令解锁 HTTP 服务
+func (m *Manager) UnlockService(passphrase string) error {
+	return m.http.Unlock(passphrase)
+}
+
+// SetWorkKey 使用命令行参数覆盖环境变量中的工作目录加密口令
+func (m *Manager) SetWorkKey(key string) {
+	if key != "" {
+		m.ctx.WorkKey = key
+	}
+}
+
+// SetReadOnly 设置 HTTP 服务的只读模式
+func (m *Manager) SetReadOnly(readOnly bool) {
+	m.ctx.HTTP.ReadOnly = readOnly
+}
+
+// SetPprof 启用性能分析接口，参数为 false 时保持配置文件的值
+func (m *Manager) SetPprof(enabled bool) {
+	if enabled {
+		m.ctx.HTTP.Pprof = true
+	}
+}
+
+// SetListen 使用命令行参数覆盖配置文件中额外的监听地址，参数为空时保持配置文件的值
+func (m *Manager) SetListen(addrs []string) {
+	if len(addrs) > 0 {
+		m.ctx.HTTP.Listen = addrs
+	}
+}
+
+// SetBasePath 使用命令行参数覆盖配置文件中的路径前缀，参数为空时保持配置文件的值
+func (m *Manager) SetBasePath(base string) {
+	if base != "" {
+		m.ctx.HTTP.BasePath = base
+	}
+}
+
+// SetTLS 使用命令行参数覆盖配置文件中的 HTTPS 设置，参数为空时保持配置文件的值
+// 证书与私钥需同时指定，只指定其中一个时返回错误，避免误以为启用了 HTTPS
+func (m *Manager) SetTLS(certFile, keyFile string, selfSigned bool, redirectAddr, clientCA string) error {
+	if (certFile == "") != (keyFile == "") {
+		return fmt.Errorf("--tls-cert 与 --tls-key 需同时指定")
+	}
+	if certFile != "" {
+		m.ctx.HTTP.TLS.CertFile = certFile
+		m.ctx.HTTP.TLS.KeyFile = keyFile
+	}
+	if selfSigned {
+		m.ctx.HTTP.TLS.SelfSigned = true
+	}
+	if redirectAddr != "" {
+		m.ctx.HTTP.TLS.RedirectAddr = redirectAddr
+	}
+	if clientCA != "" {
+		m.ctx.HTTP.TLS.ClientCA = clientCA
+	}
+	return nil
+}
+
 func (m *Manager) GetDataKey() error {
 	if m.ctx.Current == nil {
 		return fmt.Errorf("未选择任何账号")
@@ -215,11 +306,29 @@ func (m *Manager) DecryptDBFiles() error {
 	if err := m.wechat.DecryptDBFiles(); err != nil {
 		return err
 	}
+	m.buildStats()
 	m.ctx.Refresh()
 	m.ctx.UpdateConfig()
 	return nil
 }
 
+// buildStats 解密后重新汇总消息统计，失败时统计接口改为直接统计消息，不影响解密结果
+func (m *Manager) buildStats() {
+	if m.db.GetDB() == nil {
+		if err := m.db.Start(); err != nil {
+			log.Debug().Err(err).Msg("open db for stats failed")
+			return
+		}
+		defer m.db.Stop()
+	}
+	n, err := m.db.BuildStats()
+	if err != nil {
+		log.Debug().Err(err).Msg("build stats failed")
+		return
+	}
+	log.Info().Msgf("built message stats of %d sessions", n)
+}
+
 func (m *Manager) StartAutoDecrypt() error {
 	if m.ctx.DataKey == "" || m.ctx.DataDir == "" {
 		return fmt.Errorf("请先获取密钥")
@@ -251,7 +360,7 @@ func (m *Manager) RefreshSession() error {
 			return err
 		}
 	}
-	resp, err := m.db.GetSessions("", 1, 0)
+	resp, err := m.db.GetSessions(context.Background(), "", 1, 0)
 	if err != nil {
 		return err
 	}
@@ -285,7 +394,37 @@ func (m *Manager) CommandKey(pid int) (string, error) {
 	return "", fmt.Errorf("wechat process not found")
 }
 
-func (m *Manager) CommandDecrypt(dataDir string, workDir string, key string, platform string, version int) error {
+// CommandKeyFromDump 在进程内存转储文件中搜索密钥，不需要运行中的微信进程
+// dbFile 为用于验证密钥的加密数据库文件，为空时使用 dataDir 中的数据库；platform 为空时根据转储格式判断
+func (m *Manager) CommandKeyFromDump(dumpFile string, dataDir string, dbFile string, platform string, version int) (string, error) {
+	if dumpFile == "" {
+		return "", fmt.Errorf("dump file is required")
+	}
+	if dataDir == "" && dbFile == "" {
+		return "", fmt.Errorf("data dir or db file is required to validate the key")
+	}
+	if platform == "" {
+		var err error
+		if platform, err = key.DumpFilePlatform(dumpFile); err != nil {
+			return "", err
+		}
+	}
+
+	var validator *decrypt.Validator
+	var err error
+	if dbFile != "" {
+		validator, err = decrypt.NewValidatorWithFile(platform, version, dbFile)
+	} else {
+		validator, err = decrypt.NewValidator(platform, version, dataDir)
+	}
+	if err != nil {
+		return "", err
+	}
+	return key.SearchDumpFile(context.Background(), dumpFile, platform, version, validator)
+}
+
+// CommandDecrypt 解密数据目录中的数据库，opts 指定只解密部分分组或近期的消息分库
+func (m *Manager) CommandDecrypt(dataDir string, workDir string, key string, platform string, version int, opts wechat.DecryptOptions) error {
 	if dataDir == "" {
 		return fmt.Errorf("dataDir is required")
 	}
@@ -300,14 +439,490 @@ func (m *Manager) CommandDecrypt(dataDir string, workDir string, key string, pla
 	m.ctx.DataKey = key
 	m.ctx.Platform = platform
 	m.ctx.Version = version
-	if err := m.wechat.DecryptDBFiles(); err != nil {
+	if err := m.wechat.DecryptSelectedDBFiles(opts); err != nil {
 		return err
 	}
+	m.buildStats()
 
 	return nil
 }
 
-func (m *Manager) CommandHTTPServer(addr string, dataDir string, workDir string, platform string, version int) error {
+// CommandImportIOS 将 iOS 备份中的聊天数据导入工作目录，导入后以 darwin 平台、版本 3 读取
+// 加密的备份以 password 解密
+func (m *Manager) CommandImportIOS(backupDir string, workDir string, account string, password string) (*importer.Report, error) {
+	if backupDir == "" {
+		return nil, fmt.Errorf("backupDir is required")
+	}
+	if workDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+	b, err := ios.Open(backupDir, password)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+	key, err := filecrypt.DirKey(workDir, m.ctx.WorkKey)
+	if err != nil {
+		return nil, err
+	}
+	report, err := ios.Import(context.Background(), b, account, workDir, key)
+	if err != nil {
+		return nil, err
+	}
+
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = importer.Platform
+	m.ctx.Version = importer.Version
+	m.buildStats()
+	return report, nil
+}
+
+// CommandImportAndroid 解密 Android 的 EnMicroMsg.db 并导入工作目录，key 为空时由 uin 与 IMEI 计算数据库口令
+func (m *Manager) CommandImportAndroid(dbFile string, workDir string, key string, uin string, imei string, mediaDir string) (*importer.Report, error) {
+	if dbFile == "" {
+		return nil, fmt.Errorf("dbFile is required")
+	}
+	if workDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+	if key == "" {
+		if uin == "" {
+			return nil, fmt.Errorf("uin or key is required")
+		}
+		if imei == "" {
+			imei = android.DefaultIMEI
+		}
+		key = android.Key(imei, uin)
+	}
+	workKey, err := filecrypt.DirKey(workDir, m.ctx.WorkKey)
+	if err != nil {
+		return nil, err
+	}
+	report, err := android.Import(context.Background(), dbFile, key, mediaDir, workDir, workKey)
+	if err != nil {
+		return nil, err
+	}
+
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = importer.Platform
+	m.ctx.Version = importer.Version
+	m.buildStats()
+	return report, nil
+}
+
+// CommandPrune 清理工作目录中过期或指定会话的消息
+func (m *Manager) CommandPrune(workDir string, platform string, version int, opts database.PruneOptions) (*database.PruneReport, error) {
+	if workDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = platform
+	m.ctx.Version = version
+	if err := m.db.Start(); err != nil {
+		return nil, err
+	}
+	defer m.db.Stop()
+	return m.db.Prune(context.Background(), opts)
+}
+
+// CommandExport 不启动 HTTP 服务，直接读取工作目录将聊天记录导出为文件
+func (m *Manager) CommandExport(workDir string, platform string, version int, opts export.Options) (*export.Report, error) {
+	if workDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = platform
+	m.ctx.Version = version
+	if err := m.db.Start(); err != nil {
+		return nil, err
+	}
+	defer m.db.Stop()
+	return export.Run(context.Background(), m.db, opts)
+}
+
+// SearchResult 命令行搜索的单条匹配，Context 为匹配消息前后的消息，Anchor 为匹配消息在 Context 中的位置
+type SearchResult struct {
+	Message *model.Message   `json:"message"`
+	Context []*model.Message `json:"context,omitempty"`
+	Anchor  int              `json:"anchor"`
+}
+
+// CommandSearch 在工作目录中按关键词搜索最近的 limit 条消息，按时间顺序返回，around 大于 0 时附带前后各 around 条消息
+func (m *Manager) CommandSearch(workDir string, platform string, version int, keyword string, talker string, start, end time.Time, limit int, around int) ([]*SearchResult, error) {
+	if workDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = platform
+	m.ctx.Version = version
+	if err := m.db.Start(); err != nil {
+		return nil, err
+	}
+	defer m.db.Stop()
+
+	ctx := context.Background()
+	messages, err := m.searchMessages(ctx, keyword, talker, start, end, limit)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*SearchResult, 0, len(messages))
+	for i := len(messages) - 1; i >= 0; i-- {
+		result := &SearchResult{Message: messages[i]}
+		if around > 0 {
+			result.Context, result.Anchor, err = m.db.GetMessageContext(ctx, messages[i].Talker, messages[i].Seq, around, around)
+			if err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// searchMessages 按关键词搜索最近的 limit 条消息，按时间倒序返回，未指定会话时搜索最近会话列表中的全部会话
+// 关键词为普通文字且已执行 chatlog index 时全部会话只查询一次全文索引
+func (m *Manager) searchMessages(ctx context.Context, keyword string, talker string, start, end time.Time, limit int) ([]*model.Message, error) {
+	if talker == "" {
+		sessions, err := m.db.GetSessions(ctx, "", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		talkers := make([]string, 0, len(sessions.Items))
+		for _, session := range sessions.Items {
+			talkers = append(talkers, session.UserName)
+		}
+		talker = strings.Join(talkers, ",")
+	}
+	return m.db.GetMessages(ctx, start, end, talker, "", keyword, "", true, limit, 0)
+}
+
+// StatsResult 命令行统计结果，未指定会话时包含会话、联系人、群聊数量与消息最多的会话
+type StatsResult struct {
+	Talker    string              `json:"talker,omitempty"`
+	Name      string              `json:"name,omitempty"`
+	Start     time.Time           `json:"start"`
+	End       time.Time           `json:"end"`
+	Sessions  int                 `json:"sessions,omitempty"`
+	Contacts  int                 `json:"contacts,omitempty"`
+	ChatRooms int                 `json:"chat_rooms,omitempty"`
+	Metrics   *analysis.Metrics   `json:"metrics"`
+	PeakHours []int               `json:"peak_hours"`
+	TopChats  []analysis.ChatStat `json:"top_chats,omitempty"`
+}
+
+// CommandStats 统计工作目录中的消息数量与活跃度，使用与分析接口相同的消息统计
+// 指定会话时读取文本消息提取高频词与发送人名称，未指定时只统计数量
+func (m *Manager) CommandStats(workDir string, platform string, version int, talker string, start, end time.Time) (*StatsResult, error) {
+	if workDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = platform
+	m.ctx.Version = version
+	if err := m.db.Start(); err != nil {
+		return nil, err
+	}
+	defer m.db.Stop()
+
+	ctx := context.Background()
+	opts := analysis.OptionsOf(m.ctx.Settings().Keywords)
+	stats, err := m.db.GetStats(ctx, start, end, talker)
+	if err != nil {
+		return nil, err
+	}
+
+	// 会话名称来自最近会话列表
+	names := make(map[string]string)
+	if sessions, err := m.db.GetSessions(ctx, "", 0, 0); err == nil {
+		for _, session := range sessions.Items {
+			names[session.UserName] = session.NickName
+		}
+	}
+
+	ret := &StatsResult{Talker: talker, Name: names[talker], Start: start, End: end}
+	var texts []*model.Message
+	if talker != "" {
+		if texts, err = m.db.GetMessages(ctx, start, end, talker, "", "", "text", false, 0, 0); err != nil {
+			return nil, err
+		}
+		if ret.Name == "" && len(texts) > 0 {
+			ret.Name = texts[0].TalkerName
+		}
+	} else {
+		ret.Sessions, _ = m.db.CountSessions(ctx)
+		ret.Contacts, _ = m.db.CountContacts(ctx)
+		ret.ChatRooms, _ = m.db.CountChatRooms(ctx)
+		ret.TopChats = analysis.TopChatsOf(stats, opts.TopN)
+		for i := range ret.TopChats {
+			ret.TopChats[i].Name = names[ret.TopChats[i].Talker]
+		}
+	}
+	ret.Metrics = analysis.ComputeStats(stats, texts, start, end, opts)
+	ret.PeakHours = analysis.PeakHours(ret.Metrics.HourlyActivity, 3)
+	return ret, nil
+}
+
+// CommandIndex 在工作目录的消息数据库中建立辅助索引、汇总消息统计并建立全文索引，
+// 返回建立索引的表数量与建立全文索引的会话数量
+func (m *Manager) CommandIndex(workDir string, platform string, version int) (int, int, error) {
+	if workDir == "" {
+		return 0, 0, fmt.Errorf("workDir is required")
+	}
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = platform
+	m.ctx.Version = version
+	if err := m.db.Start(); err != nil {
+		return 0, 0, err
+	}
+	defer m.db.Stop()
+	n, err := m.db.BuildIndexes()
+	if err != nil {
+		return 0, 0, err
+	}
+	// 索引建立后汇总统计，按时间范围读取消息更快
+	if _, err := m.db.BuildStats(); err != nil {
+		return n, 0, err
+	}
+	talkers, err := m.db.BuildSearchIndex()
+	if err != nil {
+		return n, 0, err
+	}
+	return n, talkers, nil
+}
+
+// CommandTalkers 读取工作目录中的联系人与群聊，供命令行补全会话参数
+// 未指定工作目录时使用上次选择的账号
+func (m *Manager) CommandTalkers(workDir string, platform string, version int) ([]*model.Contact, error) {
+	if workDir != "" {
+		m.ctx.WorkDir = workDir
+		m.ctx.Platform = platform
+		m.ctx.Version = version
+	}
+	if m.ctx.WorkDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+	if err := m.db.Start(); err != nil {
+		return nil, err
+	}
+	defer m.db.Stop()
+
+	ctx := context.Background()
+	contacts, err := m.db.GetContacts(ctx, "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	ret := contacts.Items
+	seen := make(map[string]bool, len(ret))
+	for _, contact := range ret {
+		seen[contact.UserName] = true
+	}
+	// 群聊可能不在联系人列表中
+	if chatRooms, err := m.db.GetChatRooms(ctx, "", 0, 0); err == nil {
+		for _, room := range chatRooms.Items {
+			if seen[room.Name] {
+				continue
+			}
+			seen[room.Name] = true
+			ret = append(ret, &model.Contact{UserName: room.Name, Remark: room.Remark, NickName: room.NickName})
+		}
+	}
+	return ret, nil
+}
+
+// accountOf 返回工作目录对应的历史账号，命令行指定目录时以此作为当前账号，其余账号通过 account 参数查询
+// 配置了 server.account 或 profile 的 account 时直接使用，工作目录挂载到其他路径后仍能识别账号
+func (m *Manager) accountOf(workDir string) string {
+	if account := m.conf.GetConfig().ActiveServer().Account; account != "" {
+		return account
+	}
+	for name, history := range m.ctx.History {
+		if history.WorkDir != "" && filepath.Clean(history.WorkDir) == filepath.Clean(workDir) {
+			return name
+		}
+	}
+	return ""
+}
+
+// Shutdown 通知 CommandHTTPServer 优雅退出，可重复调用
+func (m *Manager) Shutdown() {
+	m.quitOnce.Do(func() { close(m.quit) })
+}
+
+// ServiceName 注册为系统服务时使用的名称
+const ServiceName = "chatlog"
+
+// CommandServiceInstall 将 HTTP 服务注册为系统服务并立即启动，登录或开机后自动运行
+// 未指定的参数使用配置文件中上次选择的账号，服务使用与当前相同的配置目录
+// logFile 为 macOS 与 Windows 下服务日志的写入位置，Linux 下日志写入 journald
+func (m *Manager) CommandServiceInstall(addr string, dataDir string, workDir string, platform string, version int, reportsDir string, logFile string) (*autostart.Status, error) {
+	if addr == "" {
+		addr = m.ctx.HTTPAddr
+	}
+	if addr == "" {
+		addr = "127.0.0.1:5030"
+	}
+	if workDir == "" {
+		workDir = m.ctx.WorkDir
+		if dataDir == "" {
+			dataDir = m.ctx.DataDir
+		}
+		if platform == "" {
+			platform = m.ctx.Platform
+		}
+		if version == 0 {
+			version = m.ctx.Version
+		}
+	}
+	if workDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+	if platform == "" {
+		platform = runtime.GOOS
+	}
+	if version == 0 {
+		version = 3
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	workDir, _ = filepath.Abs(workDir)
+	args := []string{"server", "-a", addr, "-w", workDir, "-p", platform, "-v", strconv.Itoa(version)}
+	if dataDir != "" {
+		dataDir, _ = filepath.Abs(dataDir)
+		args = append(args, "-d", dataDir)
+	}
+	if reportsDir != "" {
+		reportsDir, _ = filepath.Abs(reportsDir)
+		args = append(args, "-r", reportsDir)
+	}
+	if logFile != "" {
+		logFile, _ = filepath.Abs(logFile)
+		if runtime.GOOS == "windows" {
+			args = append(args, "--log-file", logFile)
+		}
+	}
+	// 口令不写入服务定义，加密的工作目录需要在服务环境中另行设置 CHATLOG_WORK_KEY
+	if m.ctx.WorkKey != "" {
+		log.Warn().Msg("the work key is not saved in the service, set " + ctx.EnvWorkKey + " in the service environment")
+	}
+
+	if err := autostart.Install(autostart.Config{
+		Name:        ServiceName,
+		DisplayName: "Chatlog",
+		Description: "Chatlog HTTP and MCP server",
+		Exec:        exe,
+		Args:        args,
+		Env:         []string{conf.EnvConfigDir + "=" + m.ctx.ConfigDir},
+		LogFile:     logFile,
+	}); err != nil {
+		return nil, err
+	}
+	return autostart.Query(ServiceName)
+}
+
+// CommandServiceUninstall 停止并删除系统服务
+func (m *Manager) CommandServiceUninstall() error {
+	return autostart.Uninstall(ServiceName)
+}
+
+// CommandServiceStatus 查询系统服务的安装与运行状态
+func (m *Manager) CommandServiceStatus() (*autostart.Status, error) {
+	return autostart.Query(ServiceName)
+}
+
+// CommandMCPStdio 在标准输入输出上提供 MCP 服务，不启动 HTTP 服务，标准输入关闭时返回
+func (m *Manager) CommandMCPStdio(dataDir string, workDir string, platform string, version int) error {
+	if workDir == "" {
+		return fmt.Errorf("workDir is required")
+	}
+	m.ctx.Account = m.accountOf(workDir)
+	m.ctx.DataDir = dataDir
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = platform
+	m.ctx.Version = version
+
+	if m.ctx.Version == 4 && m.ctx.DataDir != "" {
+		go dat2img.ScanAndSetXorKey(m.ctx.DataDir)
+	}
+
+	defer m.startTrace()()
+
+	if err := m.db.Start(); err != nil {
+		return err
+	}
+	defer m.db.Stop()
+	if err := m.mcp.Start(); err != nil {
+		return err
+	}
+	defer m.mcp.Stop()
+
+	return m.mcp.ServeStdio(os.Stdin, os.Stdout)
+}
+
+// startTrace 配置了导出地址时开始导出链路追踪，返回的函数导出剩余数据后停止
+func (m *Manager) startTrace() func() {
+	t, err := trace.Init(trace.Config{
+		Endpoint:    m.ctx.Trace.Endpoint,
+		Headers:     m.ctx.Trace.Headers,
+		ServiceName: m.ctx.Trace.ServiceName,
+		Version:     version.Version,
+	})
+	if err != nil {
+		log.Err(err).Msg("Failed to start tracing")
+		return func() {}
+	}
+	if t == nil {
+		return func() {}
+	}
+	log.Info().Msgf("Exporting traces to %s", t.Endpoint())
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		t.Shutdown(ctx)
+	}
+}
+
+// reloadConfig 将重新加载的配置应用到运行中的服务
+func (m *Manager) reloadConfig(c *conf.Config) {
+	m.ctx.Reload(c)
+	m.db.Reload()
+	m.mcp.Reload()
+	m.http.Reload()
+	log.Info().Msg("Config reloaded")
+}
+
+// ProfileInfo profile 及其是否为当前使用的 profile
+type ProfileInfo struct {
+	conf.Profile
+	Current bool `json:"current"`
+}
+
+// CommandProfiles 列出配置文件中的 profile
+func (m *Manager) CommandProfiles() []*ProfileInfo {
+	c := m.conf.GetConfig()
+	ret := make([]*ProfileInfo, 0, len(c.Profiles))
+	for _, p := range c.Profiles {
+		ret = append(ret, &ProfileInfo{Profile: p, Current: p.Name == c.Profile})
+	}
+	return ret
+}
+
+// CommandUseProfile 切换默认使用的 profile，name 为空时不再使用 profile
+func (m *Manager) CommandUseProfile(name string) error {
+	return m.conf.GetConfig().SetProfile(name)
+}
+
+// ServerConfig 返回配置文件中 server 命令的默认参数，使用 profile 时合并其中的配置
+func (m *Manager) ServerConfig() conf.ServerConfig {
+	return m.conf.GetConfig().ActiveServer()
+}
+
+func (m *Manager) CommandHTTPServer(addr string, dataDir string, workDir string, platform string, version int, reportsDir string) error {
 
 	if addr == "" {
 		addr = "127.0.0.1:5030"
@@ -326,24 +941,77 @@ func (m *Manager) CommandHTTPServer(addr string, dataDir string, workDir string,
 	}
 
 	m.ctx.HTTPAddr = addr
+	m.ctx.Account = m.accountOf(workDir)
 	m.ctx.DataDir = dataDir
 	m.ctx.WorkDir = workDir
 	m.ctx.Platform = platform
 	m.ctx.Version = version
+	if reportsDir != "" {
+		m.ctx.ReportsDir = reportsDir
+	}
 
 	// 如果是 4.0 版本，更新下 xorkey
 	if m.ctx.Version == 4 && m.ctx.DataDir != "" {
 		go dat2img.ScanAndSetXorKey(m.ctx.DataDir)
 	}
 
+	defer m.startTrace()()
+
 	// 按依赖顺序启动服务
 	if err := m.db.Start(); err != nil {
 		return err
 	}
 
 	if err := m.mcp.Start(); err != nil {
+		m.db.Stop()
 		return err
 	}
 
-	return m.http.ListenAndServe()
+	// 绑定局域网地址时输出访问地址，在终端中运行时同时输出二维码，便于手机扫码访问
+	if info := m.http.ServerInfo(); info.LAN {
+		log.Info().Msgf("LAN access: %s", strings.Join(info.URLs, ", "))
+		if term.IsTerminal(int(os.Stdout.Fd())) {
+			if code, err := qrcode.Encode([]byte(info.URL)); err == nil {
+				fmt.Print(code.String(2))
+				fmt.Println(info.URL)
+			}
+			if info.MDNS != "" {
+				fmt.Println(info.MDNS)
+			}
+		}
+	}
+
+	// 配置文件变化时重新加载可在运行中生效的部分
+	if err := m.conf.Watch(m.reloadConfig); err != nil {
+		log.Debug().Err(err).Msg("Failed to watch config file")
+	}
+
+	// 收到 SIGINT、SIGTERM 或调用 Shutdown 时停止接收新请求，等待处理中的请求完成后再关闭数据库
+	sig, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- m.http.ListenAndServe()
+	}()
+
+	go func() {
+		select {
+		case <-m.quit:
+			stop()
+		case <-sig.Done():
+		}
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-sig.Done():
+		log.Info().Msg("Shutting down, waiting for in-flight requests")
+		m.http.Shutdown(http.ShutdownTimeout)
+		err = <-errCh
+	}
+	m.conf.StopWatch()
+	m.mcp.Stop()
+	m.db.Stop()
+	return err
 }
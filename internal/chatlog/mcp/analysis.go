@@ -0,0 +1,237 @@
+package mcp
+
+import (
+	"context"
+
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/chatlog/analysis"
+	"github.com/sjzar/chatlog/internal/mcp"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// maxSummaryGroups 未指定会话时，每日汇总最多列出的会话数
+const maxSummaryGroups = 20
+
+// weekdays 热力图按周一到周日排列
+var weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+
+var weekdayNames = map[time.Weekday]string{
+	time.Monday: "周一", time.Tuesday: "周二", time.Wednesday: "周三", time.Thursday: "周四",
+	time.Friday: "周五", time.Saturday: "周六", time.Sunday: "周日",
+}
+
+// stringArg 读取字符串参数，缺失或类型不符时返回空字符串
+func stringArg(args mcp.M, key string) string {
+	v, _ := args[key].(string)
+	return strings.TrimSpace(v)
+}
+
+// analysisRange 解析 time 参数，为空时使用 defaultTime
+func analysisRange(args mcp.M, defaultTime string) (time.Time, time.Time, error) {
+	_time := stringArg(args, "time")
+	if _time == "" {
+		_time = defaultTime
+	}
+	start, end, ok := util.TimeRangeOf(_time)
+	if !ok {
+		return start, end, fmt.Errorf("无法解析时间范围: %s", _time)
+	}
+	return start, end, nil
+}
+
+// analysisStats 未指定会话时返回整体数量，指定会话时返回该会话的统计指标
+func (s *Service) analysisStats(ctx context.Context, buf *bytes.Buffer, args mcp.M) error {
+	start, end, err := analysisRange(args, "last-7d")
+	if err != nil {
+		return err
+	}
+	talker := stringArg(args, "talker")
+	if talker == "" {
+		sessions, _ := s.db.CountSessions(ctx)
+		contacts, _ := s.db.CountContacts(ctx)
+		chatRooms, _ := s.db.CountChatRooms(ctx)
+		stats, err := s.db.GetStats(ctx, start, end, "")
+		if err != nil {
+			return fmt.Errorf("无法统计消息数量: %v", err)
+		}
+		messages := analysis.ComputeStats(stats, nil, start, end, s.options()).MessageCount
+		buf.WriteString(fmt.Sprintf("范围: %s ~ %s\n会话: %d\n联系人: %d\n群聊: %d\n消息: %d\n",
+			start.Format("2006-01-02"), end.Format("2006-01-02"), sessions, contacts, chatRooms, messages))
+		return nil
+	}
+
+	stats, err := s.db.GetStats(ctx, start, end, talker)
+	if err != nil {
+		return fmt.Errorf("无法统计消息数量: %v", err)
+	}
+	if len(stats) == 0 {
+		buf.WriteString("范围内没有消息")
+		return nil
+	}
+	// 数量来自统计，关键词与发送人名称只需读取文本消息
+	texts, err := s.db.GetMessages(ctx, start, end, talker, "", "", "text", false, 0, 0)
+	if err != nil {
+		return fmt.Errorf("无法获取聊天记录: %v", err)
+	}
+	m := analysis.ComputeStats(stats, texts, start, end, s.options())
+	name := talker
+	if len(texts) > 0 {
+		name = talkerName(texts)
+	}
+	buf.WriteString(fmt.Sprintf("会话: %s\n范围: %s ~ %s\n消息: %d（文本 %d，多媒体 %d）\n发言人数: %d\n",
+		name, m.FirstTime.Format("2006-01-02 15:00"), m.LastTime.Format("2006-01-02 15:00"),
+		m.MessageCount, m.TextCount, m.MediaCount, m.ActiveMembers))
+	senders := make([]string, 0, len(m.TopSenders))
+	for _, stat := range m.TopSenders {
+		name := stat.SenderName
+		if name == "" {
+			name = stat.Sender
+		}
+		senders = append(senders, fmt.Sprintf("%s %d", name, stat.Count))
+	}
+	buf.WriteString("发言最多: " + strings.Join(senders, "，") + "\n")
+	buf.WriteString("高频词: " + keywordList(m.TopKeywords) + "\n")
+	return nil
+}
+
+// activityHeatmap 按星期与小时输出消息数量，每行一个星期，列为 0-23 时
+func (s *Service) activityHeatmap(ctx context.Context, buf *bytes.Buffer, args mcp.M) error {
+	start, end, err := analysisRange(args, "last-30d")
+	if err != nil {
+		return err
+	}
+	stats, err := s.db.GetStats(ctx, start, end, stringArg(args, "talker"))
+	if err != nil {
+		return fmt.Errorf("无法统计消息数量: %v", err)
+	}
+	heatmap := analysis.HeatmapOf(stats)
+
+	buf.WriteString("星期")
+	for h := 0; h < 24; h++ {
+		buf.WriteString(fmt.Sprintf(",%d", h))
+	}
+	buf.WriteString("\n")
+	peak, peakDay, peakHour := 0, time.Monday, 0
+	for _, day := range weekdays {
+		buf.WriteString(weekdayNames[day])
+		for h, count := range heatmap[day] {
+			buf.WriteString(fmt.Sprintf(",%d", count))
+			if count > peak {
+				peak, peakDay, peakHour = count, day, h
+			}
+		}
+		buf.WriteString("\n")
+	}
+	if peak > 0 {
+		buf.WriteString(fmt.Sprintf("最活跃: %s %d 时，%d 条\n", weekdayNames[peakDay], peakHour, peak))
+	}
+	return nil
+}
+
+// dailySummary 按会话汇总范围内的文本消息数量、发言人数与高频词
+func (s *Service) dailySummary(ctx context.Context, buf *bytes.Buffer, args mcp.M) error {
+	start, end, err := analysisRange(args, "today")
+	if err != nil {
+		return err
+	}
+	messages, err := s.db.GetMessages(ctx, start, end, stringArg(args, "talker"), "", "", "", false, 0, 0)
+	if err != nil {
+		return fmt.Errorf("无法获取聊天记录: %v", err)
+	}
+
+	groups := make(map[string][]*model.Message)
+	for _, msg := range messages {
+		if msg.Type == 1 && msg.Content != "" {
+			groups[msg.Talker] = append(groups[msg.Talker], msg)
+		}
+	}
+	if len(groups) == 0 {
+		buf.WriteString("范围内没有文本消息")
+		return nil
+	}
+	talkers := make([]string, 0, len(groups))
+	for talker := range groups {
+		talkers = append(talkers, talker)
+	}
+	sort.Slice(talkers, func(i, j int) bool {
+		if len(groups[talkers[i]]) != len(groups[talkers[j]]) {
+			return len(groups[talkers[i]]) > len(groups[talkers[j]])
+		}
+		return talkers[i] < talkers[j]
+	})
+
+	buf.WriteString(fmt.Sprintf("范围: %s ~ %s，%d 个会话\n", start.Format("2006-01-02"), end.Format("2006-01-02"), len(talkers)))
+	if len(talkers) > maxSummaryGroups {
+		buf.WriteString(fmt.Sprintf("只列出消息最多的 %d 个会话\n", maxSummaryGroups))
+		talkers = talkers[:maxSummaryGroups]
+	}
+	for _, talker := range talkers {
+		list := groups[talker]
+		m := analysis.Compute(list, start, end, s.options())
+		buf.WriteString(fmt.Sprintf("\n%s(%s): %d 条，%d 人发言\n高频词: %s\n",
+			talkerName(list), talker, m.TextCount, m.ActiveMembers, keywordList(m.TopKeywords)))
+	}
+	return nil
+}
+
+// goldenQuotes 提取范围内的金句，附带发送人与时间
+func (s *Service) goldenQuotes(ctx context.Context, buf *bytes.Buffer, args mcp.M) error {
+	start, end, err := analysisRange(args, "today")
+	if err != nil {
+		return err
+	}
+	talker := stringArg(args, "talker")
+	messages, err := s.db.GetMessages(ctx, start, end, talker, "", "", "", false, 0, 0)
+	if err != nil {
+		return fmt.Errorf("无法获取聊天记录: %v", err)
+	}
+
+	texts := make([]string, 0)
+	sources := make([]*model.Message, 0)
+	for _, msg := range messages {
+		if msg.Type == 1 && len(msg.Content) > 10 {
+			texts = append(texts, msg.Content)
+			sources = append(sources, msg)
+		}
+	}
+	quotes := analysis.GoldenQuotes(texts)
+	if len(quotes) == 0 {
+		buf.WriteString("范围内没有找到金句")
+		return nil
+	}
+	for _, q := range quotes {
+		msg := sources[q.Index-1]
+		sender := msg.SenderName
+		if sender == "" {
+			sender = msg.Sender
+		}
+		if talker == "" || strings.Contains(talker, ",") {
+			sender = talkerName([]*model.Message{msg}) + " / " + sender
+		}
+		buf.WriteString(fmt.Sprintf("[%s] %s: %s\n", msg.Time.Format("2006-01-02 15:04"), sender, q.Content))
+	}
+	return nil
+}
+
+// talkerName 会话名称，没有名称时使用 ID
+func talkerName(messages []*model.Message) string {
+	if messages[0].TalkerName != "" {
+		return messages[0].TalkerName
+	}
+	return messages[0].Talker
+}
+
+// keywordList 高频词列表，如 "项目(12)，上线(8)"
+func keywordList(keywords []analysis.KeywordStat) string {
+	words := make([]string, 0, len(keywords))
+	for _, k := range keywords {
+		words = append(words, fmt.Sprintf("%s(%d)", k.Word, k.Count))
+	}
+	return strings.Join(words, "，")
+}
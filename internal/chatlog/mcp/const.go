@@ -105,6 +105,7 @@ var (
 - 如果上述任一问题答案为"否"，则必须纠正流程
 
 返回格式："昵称(ID) 时间\n消息内容\n昵称(ID) 时间\n消息内容"
+结果较多时会分页返回，末尾注明"第 1/N 页"与下一页的 cursor；需要后续内容时保持其他参数不变并传入 cursor，通常应优先缩小时间范围或使用 keyword
 当查询多个Talker时，返回格式为："昵称(ID)\n[TalkerName(Talker)] 时间\n消息内容"
 
 重要提示：
@@ -165,6 +166,10 @@ var (
   3. 错误示例：对所有找到的关键词消息一次性查询大范围上下文
   4. 正确示例：对每个时间点T分别执行查询"T前后15-30分钟"（不带keyword）`,
 				},
+				"cursor": mcp.M{
+					"type":        "string",
+					"description": "分页游标，取自上一次结果末尾的提示，首次查询时不传",
+				},
 			},
 			Required: []string{"time", "talker"},
 		},
@@ -185,12 +190,227 @@ var (
 		},
 	}
 
+	ToolImage = mcp.Tool{
+		Name: "get_image",
+		Description: `获取聊天记录中的图片内容，以便查看图片中的信息。
+聊天记录中的图片形如"![图片](http://host/image/key)"，将链接中 /image/ 之后的部分（或整个链接）作为 key 传入即可，加密的 .dat 图片会自动解密。
+当用户询问图片内容，或需要结合图片理解上下文时使用此工具。`,
+		InputSchema: mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"key": mcp.M{
+					"type":        "string",
+					"description": "图片的 key 或链接，多个 key 以\",\"分隔时返回第一个可用的图片",
+				},
+			},
+			Required: []string{"key"},
+		},
+	}
+
+	ToolVoice = mcp.Tool{
+		Name: "get_voice",
+		Description: `获取聊天记录中的语音内容，返回 MP3 格式的音频。
+聊天记录中的语音形如"[语音](http://host/voice/key)"，将链接中 /voice/ 之后的部分（或整个链接）作为 key 传入即可。
+当用户询问语音消息说了什么时使用此工具。`,
+		InputSchema: mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"key": mcp.M{
+					"type":        "string",
+					"description": "语音的 key 或链接",
+				},
+			},
+			Required: []string{"key"},
+		},
+	}
+
+	ToolAnalysisStats = mcp.Tool{
+		Name:        "analysis_stats",
+		Description: "统计聊天数据。不指定 talker 时返回会话、联系人、群聊总数与范围内的消息总数；指定 talker 时返回该会话的消息数、发言人数、发言最多的成员与高频词。当用户询问“群里谁最活跃”“最近聊了多少”等统计问题时使用此工具。",
+		InputSchema: mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"talker": mcp.M{
+					"type":        "string",
+					"description": "对话方（联系人或群聊），可使用ID、昵称或备注名；为空时统计全部数据",
+				},
+				"time": mcp.M{
+					"type":        "string",
+					"description": "时间范围，语法与 chatlog 工具的 time 参数相同，另支持 today、yesterday、this-week、last-7d、last-3m 等，默认为 last-7d",
+				},
+			},
+		},
+	}
+
+	ToolActivityHeatmap = mcp.Tool{
+		Name:        "activity_heatmap",
+		Description: "按星期与小时统计消息数量，返回 7 行 24 列的 CSV 热力图（每行一个星期，列为 0-23 时）以及最活跃的时段。当用户询问“一般什么时候聊天”“哪个时段最活跃”时使用此工具。",
+		InputSchema: mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"talker": mcp.M{
+					"type":        "string",
+					"description": "对话方（联系人或群聊），可使用ID、昵称或备注名，多个用\",\"分隔；为空时统计所有会话",
+				},
+				"time": mcp.M{
+					"type":        "string",
+					"description": "时间范围，语法与 chatlog 工具的 time 参数相同，另支持 today、yesterday、this-week、last-7d、last-3m 等，默认为 last-30d",
+				},
+			},
+		},
+	}
+
+	ToolDailySummary = mcp.Tool{
+		Name:        "daily_summary",
+		Description: "按会话汇总范围内的文本消息数量、发言人数与高频词，未指定 talker 时列出消息最多的 20 个会话。当用户询问“今天各群都在聊什么”时使用此工具，需要具体内容时再用 chatlog 工具查询。",
+		InputSchema: mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"talker": mcp.M{
+					"type":        "string",
+					"description": "对话方（联系人或群聊），可使用ID、昵称或备注名，多个用\",\"分隔；为空时汇总所有会话",
+				},
+				"time": mcp.M{
+					"type":        "string",
+					"description": "时间范围，语法与 chatlog 工具的 time 参数相同，另支持 today、yesterday、this-week、last-7d、last-3m 等，默认为 today",
+				},
+			},
+		},
+	}
+
+	ToolGoldenQuotes = mcp.Tool{
+		Name:        "golden_quotes",
+		Description: "提取范围内的金句（有代表性或较有内容的发言），最多 10 条，附带发送人与时间。当用户想看某天群里的精彩发言时使用此工具。",
+		InputSchema: mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"talker": mcp.M{
+					"type":        "string",
+					"description": "对话方（联系人或群聊），可使用ID、昵称或备注名，多个用\",\"分隔；为空时从所有会话中提取",
+				},
+				"time": mcp.M{
+					"type":        "string",
+					"description": "时间范围，语法与 chatlog 工具的 time 参数相同，另支持 today、yesterday、this-week、last-7d、last-3m 等，默认为 today",
+				},
+			},
+		},
+	}
+
+	ToolSearch = mcp.Tool{
+		Name: "search_messages",
+		Description: `按关键词搜索聊天记录，可同时按对话方、发送者、消息类型与时间过滤，返回精简的命中列表。
+每条命中包含时间、会话、发送者、关键词附近的内容片段，以及形如 ref="talker#seq" 的消息引用；需要完整上下文时将 ref 传给 message_context 工具。
+与 chatlog 工具不同，本工具不返回完整聊天记录，适合先定位话题出现在哪些会话和时间点。`,
+		InputSchema: mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"keyword": mcp.M{
+					"type":        "string",
+					"description": "搜索关键词，支持正则表达式",
+				},
+				"time": mcp.M{
+					"type":        "string",
+					"description": "时间范围，语法与 chatlog 工具的 time 参数相同，另支持 today、last-7d、last-3m 等，默认为 last-30d",
+				},
+				"talker": mcp.M{
+					"type":        "string",
+					"description": "对话方（联系人或群聊），可使用ID、昵称或备注名，多个用\",\"分隔；为空时搜索所有会话",
+				},
+				"sender": mcp.M{
+					"type":        "string",
+					"description": "发送者，多个用\",\"分隔",
+				},
+				"type": mcp.M{
+					"type":        "string",
+					"description": "消息类型，多个用\",\"分隔，如 text、image、voice、video、file、link、quote，也支持数字形式如 49:6",
+				},
+				"limit": mcp.M{
+					"type":        "integer",
+					"description": "最多返回的命中数，默认 50，最大 200",
+				},
+				"cursor": mcp.M{
+					"type":        "string",
+					"description": "上一页结果末尾给出的 cursor，用于继续查看",
+				},
+			},
+			Required: []string{"keyword"},
+		},
+	}
+
+	ToolMessageContext = mcp.Tool{
+		Name:        "message_context",
+		Description: "根据 search_messages 返回的消息引用获取该消息前后的聊天记录，目标消息以\"> \"标记。当需要了解某条命中消息的上下文时使用此工具。",
+		InputSchema: mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"ref": mcp.M{
+					"type":        "string",
+					"description": "消息引用，形如\"talker#seq\"",
+				},
+				"before": mcp.M{
+					"type":        "integer",
+					"description": "向前获取的消息数，默认 15",
+				},
+				"after": mcp.M{
+					"type":        "integer",
+					"description": "向后获取的消息数，默认 15",
+				},
+			},
+			Required: []string{"ref"},
+		},
+	}
+
+	ToolSummarize = mcp.Tool{
+		Name: "summarize_chatlog",
+		Description: `总结一段时间内的聊天记录，例如"总结工作群这个月聊了什么"。
+服务端会分段读取聊天记录，通过 MCP sampling 请求客户端的大模型逐段总结后合并，只返回最终摘要，适合消息量很大、无法直接用 chatlog 工具读取的范围。
+需要客户端支持 sampling；不支持时请改用 chatlog 工具分页查询。`,
+		InputSchema: mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"time": mcp.M{
+					"type":        "string",
+					"description": "时间范围，语法与 chatlog 工具的 time 参数相同，另支持 this-month、last-7d 等",
+				},
+				"talker": mcp.M{
+					"type":        "string",
+					"description": "对话方（联系人或群聊），可使用ID、昵称或备注名",
+				},
+				"sender": mcp.M{
+					"type":        "string",
+					"description": "只总结这些发送者的消息，多个用\",\"分隔",
+				},
+				"keyword": mcp.M{
+					"type":        "string",
+					"description": "只总结包含关键词的消息，支持正则表达式",
+				},
+				"focus": mcp.M{
+					"type":        "string",
+					"description": "总结时重点关注的内容，如\"项目进度与待办\"",
+				},
+			},
+			Required: []string{"time", "talker"},
+		},
+	}
+
 	ResourceRecentChat = mcp.Resource{
 		Name:        "最近会话",
 		URI:         "session://recent",
 		Description: "获取最近的聊天会话列表",
 	}
 
+	ResourceContacts = mcp.Resource{
+		Name:        "联系人列表",
+		URI:         "contact://all",
+		Description: "获取所有联系人",
+	}
+
+	ResourceChatRooms = mcp.Resource{
+		Name:        "群聊列表",
+		URI:         "chatroom://all",
+		Description: "获取所有群聊",
+	}
+
 	ResourceTemplateContact = mcp.ResourceTemplate{
 		Name:        "联系人信息",
 		URITemplate: "contact://{username}",
@@ -200,7 +420,7 @@ var (
 	ResourceTemplateChatRoom = mcp.ResourceTemplate{
 		Name:        "群聊信息",
 		URITemplate: "chatroom://{roomid}",
-		Description: "获取指定群聊的详细信息",
+		Description: "获取指定群聊的详细信息与成员列表",
 	}
 
 	ResourceTemplateChatlog = mcp.ResourceTemplate{
@@ -0,0 +1,149 @@
+package mcp
+
+import (
+	"context"
+
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/mcp"
+	"github.com/sjzar/chatlog/pkg/redact"
+	"github.com/sjzar/chatlog/pkg/trace"
+	"github.com/sjzar/chatlog/pkg/util"
+	"github.com/sjzar/chatlog/pkg/util/dat2img"
+	"github.com/sjzar/chatlog/pkg/util/silk"
+)
+
+// mediaKeys 解析 key 参数，支持直接传入聊天记录中的链接，如 http://host/image/key1,key2
+func mediaKeys(v interface{}) []string {
+	key, _ := v.(string)
+	for _, prefix := range []string{"/image/", "/voice/"} {
+		if i := strings.Index(key, prefix); i >= 0 {
+			key = key[i+len(prefix):]
+		}
+	}
+	return util.Str2List(strings.TrimRight(key, ")"), ",")
+}
+
+// dataPath 将相对路径解析为 ctx 所属账号数据目录下的真实路径，规则与 HTTP 服务一致
+func (s *Service) dataPath(ctx context.Context, name string) (string, error) {
+	dataDir, err := s.db.DataDir(ctx)
+	if err != nil {
+		return "", err
+	}
+	roots := append([]string{dataDir}, s.ctx.HTTP.FileRoots...)
+	return util.NewSandbox(roots...).Resolve(dataDir, name)
+}
+
+// imageContent 返回第一个可用的图片，.dat 图片解密后返回
+// 配置了默认脱敏与 http.image_mask 时返回遮盖后的图片
+// 图片以 base64 内嵌在响应中，超过 http.inline_media_max_size 的图片不返回，避免占满模型上下文
+func (s *Service) imageContent(ctx context.Context, keys []string) (mcp.Content, error) {
+	if len(keys) == 0 {
+		return mcp.Content{}, mcp.ErrInvalidParams
+	}
+	maxSize := s.ctx.Settings().InlineMediaMaxSize
+	tooLarge := false
+	for _, k := range keys {
+		name, account := k, ""
+		if len(k) == 32 {
+			media, err := s.db.GetMedia(ctx, "image", k)
+			if err != nil {
+				continue
+			}
+			name, account = media.Path, media.Account
+		} else if s.ctx.HTTP.ReadOnly {
+			// 只读模式下不允许按路径访问
+			continue
+		}
+		// 合并多个账号查询时从找到文件的账号读取
+		path, err := s.dataPath(database.WithAccount(ctx, account), name)
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(path); err != nil || maxSize > 0 && info.Size() > maxSize {
+			tooLarge = tooLarge || err == nil
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if strings.ToLower(filepath.Ext(path)) == ".dat" {
+			if data, _, err = decodeImage(ctx, data); err != nil {
+				continue
+			}
+		}
+		mimeType := http.DetectContentType(data)
+		if !strings.HasPrefix(mimeType, "image/") {
+			continue
+		}
+		if settings := s.ctx.Settings(); settings.Redact && settings.ImageMask != "" {
+			if data, mimeType, err = redact.Image(data, settings.ImageMask); err != nil {
+				return mcp.Content{}, err
+			}
+		}
+		if maxSize > 0 && int64(len(data)) > maxSize {
+			tooLarge = true
+			continue
+		}
+		return mcp.Content{
+			Type:     "image",
+			Data:     base64.StdEncoding.EncodeToString(data),
+			MimeType: mimeType,
+		}, nil
+	}
+	if tooLarge {
+		return mcp.Content{}, fmt.Errorf("图片超过 %d 字节，请通过聊天记录中的链接查看: %s", maxSize, strings.Join(keys, ","))
+	}
+	return mcp.Content{}, fmt.Errorf("未找到图片: %s", strings.Join(keys, ","))
+}
+
+// voiceContent 返回语音内容，转码为 MP3，转码失败时返回原始的 SILK 数据
+func (s *Service) voiceContent(ctx context.Context, keys []string) (mcp.Content, error) {
+	if len(keys) == 0 {
+		return mcp.Content{}, mcp.ErrInvalidParams
+	}
+	for _, k := range keys {
+		media, err := s.db.GetMedia(ctx, "voice", k)
+		if err != nil || len(media.Data) == 0 {
+			continue
+		}
+		data, mimeType := media.Data, "audio/silk"
+		if out, err := decodeVoice(ctx, media.Data); err == nil {
+			data, mimeType = out, "audio/mpeg"
+		}
+		return mcp.Content{
+			Type: "resource",
+			Resource: &mcp.ReadingResourceContent{
+				URI:      "voice://" + k,
+				MimeType: mimeType,
+				Blob:     base64.StdEncoding.EncodeToString(data),
+			},
+		}, nil
+	}
+	return mcp.Content{}, fmt.Errorf("未找到语音: %s", strings.Join(keys, ","))
+}
+
+// decodeImage 解码微信图片文件（.dat），并记录解码耗时
+func decodeImage(ctx context.Context, data []byte) ([]byte, string, error) {
+	_, span := trace.Start(ctx, "media.decode_image", trace.Int("media.size", len(data)))
+	defer span.End()
+	out, ext, err := dat2img.Dat2Image(data)
+	span.SetError(err)
+	return out, ext, err
+}
+
+// decodeVoice 将 SILK 语音转换为 MP3，并记录转换耗时
+func decodeVoice(ctx context.Context, data []byte) ([]byte, error) {
+	_, span := trace.Start(ctx, "media.decode_voice", trace.Int("media.size", len(data)))
+	defer span.End()
+	out, err := silk.Silk2MP3(data)
+	span.SetError(err)
+	return out, err
+}
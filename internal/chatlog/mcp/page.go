@@ -0,0 +1,74 @@
+package mcp
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// page 按 token 预算切分后的一页结果
+type page struct {
+	start, end int // 本页包含 lines[start:end]
+	index      int // 从 1 开始的页码
+	total      int
+}
+
+// paginate 按 token 预算将结果分页，cursor 为上一页返回的下一页起始位置，为空时返回第一页
+// 单行超出预算时独占一页，budget <= 0 时不分页
+func paginate(lines []string, budget int, cursor string) (*page, error) {
+	starts := []int{0}
+	if budget > 0 {
+		used := 0
+		for i, line := range lines {
+			n := util.EstimateTokens(line)
+			if used > 0 && used+n > budget {
+				starts = append(starts, i)
+				used = 0
+			}
+			used += n
+		}
+	}
+
+	index := 0
+	if cursor != "" {
+		offset, err := strconv.Atoi(cursor)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 cursor: %s", cursor)
+		}
+		index = -1
+		for i, start := range starts {
+			if start == offset {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("cursor %s 已失效，请去掉 cursor 重新查询", cursor)
+		}
+	}
+
+	p := &page{start: starts[index], end: len(lines), index: index + 1, total: len(starts)}
+	if index+1 < len(starts) {
+		p.end = starts[index+1]
+	}
+	return p, nil
+}
+
+// writePage 写出一页结果，有多页时在末尾说明页码与继续查询的 cursor
+func writePage(buf *bytes.Buffer, lines []string, p *page) {
+	for _, line := range lines[p.start:p.end] {
+		buf.WriteString(line)
+	}
+	if p.total <= 1 {
+		return
+	}
+	if p.index < p.total {
+		buf.WriteString(fmt.Sprintf("\n[第 %d/%d 页，共 %d 条消息，本页为第 %d-%d 条。结果较多，请先根据本页内容判断是否需要继续；继续查看时保持其他参数不变，并设置 cursor=\"%d\"]\n",
+			p.index, p.total, len(lines), p.start+1, p.end, p.end))
+		return
+	}
+	buf.WriteString(fmt.Sprintf("\n[第 %d/%d 页，共 %d 条消息，本页为第 %d-%d 条，已是最后一页]\n",
+		p.index, p.total, len(lines), p.start+1, p.end))
+}
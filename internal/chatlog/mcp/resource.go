@@ -0,0 +1,146 @@
+package mcp
+
+import (
+	"context"
+
+	"bytes"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/mcp"
+)
+
+// resourcesPageSize 资源列表每页的会话数
+const resourcesPageSize = 100
+
+// resourcesList 列出固定资源与最近会话对应的联系人、群聊资源，按 cursor 分页
+func (s *Service) resourcesList(session *mcp.Session, req *mcp.Request) error {
+	offset := 0
+	if req.Params != nil {
+		listReq, err := parseParams[mcp.ResourcesListRequest](req.Params)
+		if err != nil {
+			return fmt.Errorf("解析资源列表参数失败: %v", err)
+		}
+		if listReq.Cursor != "" {
+			if offset, err = strconv.Atoi(listReq.Cursor); err != nil || offset < 0 {
+				return mcp.ErrInvalidParams
+			}
+		}
+	}
+
+	resp := mcp.ResourcesListResponse{Resources: []mcp.Resource{}}
+	if offset == 0 {
+		resp.Resources = append(resp.Resources, ResourceRecentChat, ResourceContacts, ResourceChatRooms)
+	}
+	data, err := s.db.GetSessions(session.Context(), "", resourcesPageSize, offset)
+	if err != nil {
+		return fmt.Errorf("无法获取会话列表: %v", err)
+	}
+	for _, item := range data.Items {
+		resource := mcp.Resource{
+			URI:         "contact://" + item.UserName,
+			Name:        item.NickName,
+			Description: "联系人信息",
+			MimeType:    "text/plain",
+		}
+		if strings.HasSuffix(item.UserName, "@chatroom") {
+			resource.URI = "chatroom://" + item.UserName
+			resource.Description = "群聊信息与成员列表"
+		}
+		if resource.Name == "" {
+			resource.Name = item.UserName
+		}
+		resp.Resources = append(resp.Resources, resource)
+	}
+	if len(data.Items) == resourcesPageSize {
+		resp.NextCursor = strconv.Itoa(offset + resourcesPageSize)
+	}
+	return session.WriteResponse(req, resp)
+}
+
+// resourcesSubscribe 订阅或取消订阅资源更新，只有会话列表与聊天记录会收到更新通知
+func (s *Service) resourcesSubscribe(session *mcp.Session, req *mcp.Request, subscribe bool) error {
+	subReq, err := parseParams[mcp.ResourcesSubscribeRequest](req.Params)
+	if err != nil {
+		return fmt.Errorf("解析订阅参数失败: %v", err)
+	}
+	if _, err := url.Parse(subReq.URI); err != nil || subReq.URI == "" {
+		return fmt.Errorf("无法解析URI: %s", subReq.URI)
+	}
+	if subscribe {
+		session.Subscribe(subReq.URI)
+	} else {
+		session.Unsubscribe(subReq.URI)
+	}
+	return s.sendCustomParams(session, req, struct{}{})
+}
+
+// notifier 消息、会话数据库更新时，通知订阅了会话列表或聊天记录的客户端重新读取
+func (s *Service) notifier(updates <-chan struct{}, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-updates:
+			for _, session := range s.mcp.Sessions() {
+				for _, uri := range session.Subscriptions() {
+					if !strings.HasPrefix(uri, "session://") && !strings.HasPrefix(uri, "chatlog://") {
+						continue
+					}
+					if err := session.WriteNotification(mcp.NofiticationResourcesUpdated, mcp.ResourceUpdatedParams{URI: uri}); err != nil {
+						log.Debug().Err(err).Msgf("notify %s failed", uri)
+					}
+				}
+			}
+		}
+	}
+}
+
+// resourceID URI 中的联系人或群聊 ID，群 ID 中的 @ 会被解析为用户信息，需要还原
+func resourceID(u *url.URL) string {
+	if u.User != nil {
+		return u.User.String() + "@" + u.Host
+	}
+	return u.Host
+}
+
+// writeContact 写出单个联系人的详细信息
+func (s *Service) writeContact(ctx context.Context, buf *bytes.Buffer, userName string) bool {
+	list, err := s.db.GetContacts(ctx, userName, 0, 0)
+	if err != nil {
+		return false
+	}
+	for _, contact := range list.Items {
+		if contact.UserName != userName {
+			continue
+		}
+		buf.WriteString(fmt.Sprintf("UserName: %s\nAlias: %s\nRemark: %s\nNickName: %s\nIsFriend: %t\n",
+			contact.UserName, contact.Alias, contact.Remark, contact.NickName, contact.IsFriend))
+		return true
+	}
+	return false
+}
+
+// writeChatRoom 写出单个群聊的信息与成员列表
+func (s *Service) writeChatRoom(ctx context.Context, buf *bytes.Buffer, name string) bool {
+	list, err := s.db.GetChatRooms(ctx, name, 0, 0)
+	if err != nil {
+		return false
+	}
+	for _, chatRoom := range list.Items {
+		if chatRoom.Name != name {
+			continue
+		}
+		buf.WriteString(fmt.Sprintf("Name: %s\nRemark: %s\nNickName: %s\nOwner: %s\nUserCount: %d\n\nUserName,DisplayName\n",
+			chatRoom.Name, chatRoom.Remark, chatRoom.NickName, chatRoom.Owner, len(chatRoom.Users)))
+		for _, user := range chatRoom.Users {
+			buf.WriteString(fmt.Sprintf("%s,%s\n", user.UserName, user.DisplayName))
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/mcp"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+const (
+	// samplingTimeout 等待客户端大模型响应的时间，客户端可能需要用户确认
+	samplingTimeout = 5 * time.Minute
+
+	// summaryMaxTokens 每次生成摘要的 token 上限
+	summaryMaxTokens = 1000
+
+	// summaryChunkTokens 未配置 mcp.max_tokens 时每段聊天记录的 token 数
+	summaryChunkTokens = 8000
+
+	// maxSummaryChunks 分段数量上限，超出时要求缩小范围
+	maxSummaryChunks = 20
+)
+
+const summarySystemPrompt = "你是一个聊天记录分析助手。请用简洁的中文总结提供的聊天记录，列出主要话题、结论与待办事项，并注明关键发言人。不要编造聊天记录中没有的信息。"
+
+const mergeSystemPrompt = "你是一个聊天记录分析助手。以下是同一段聊天记录按时间顺序分段生成的摘要，请合并为一份完整、简洁的中文摘要，去除重复内容，保留主要话题、结论与待办事项。"
+
+// summarize 通过 MCP sampling 请客户端的大模型总结聊天记录，在单独的 goroutine 中执行，不阻塞其他请求
+func (s *Service) summarize(session *mcp.Session, req *mcp.Request, args mcp.M) {
+	text, err := s.summarizeChatlog(session, args)
+	if err != nil {
+		session.WriteError(req, err)
+		return
+	}
+	session.WriteResponse(req, mcp.ToolsCallResponse{
+		Content: []mcp.Content{{Type: "text", Text: text}},
+	})
+}
+
+// summarizeChatlog 聊天记录超出单次上下文时分段总结，再合并为一份摘要
+func (s *Service) summarizeChatlog(session *mcp.Session, args mcp.M) (string, error) {
+	if !session.SupportsSampling() {
+		return "", errors.New("客户端不支持 MCP sampling，请使用 chatlog 工具分页查询后自行总结")
+	}
+	if stringArg(args, "time") == "" {
+		return "", fmt.Errorf("缺少时间范围")
+	}
+	start, end, err := analysisRange(args, "")
+	if err != nil {
+		return "", err
+	}
+	talker := stringArg(args, "talker")
+	if talker == "" {
+		return "", fmt.Errorf("缺少对话方")
+	}
+	messages, err := s.db.GetMessages(session.Context(), start, end, talker, stringArg(args, "sender"), stringArg(args, "keyword"), "", false, 0, 0)
+	if err != nil {
+		return "", fmt.Errorf("无法获取聊天记录: %v", err)
+	}
+	if len(messages) == 0 {
+		return "未找到符合查询条件的聊天记录", nil
+	}
+
+	lines := make([]string, 0, len(messages))
+	for _, m := range messages {
+		lines = append(lines, m.PlainText(strings.Contains(talker, ","), util.PerfectTimeFormat(start, end), "")+"\n")
+	}
+	budget := s.ctx.Settings().MCP.MaxTokens
+	if budget <= 0 {
+		budget = summaryChunkTokens
+	}
+	chunks := make([]string, 0)
+	cursor := ""
+	for {
+		p, err := paginate(lines, budget, cursor)
+		if err != nil {
+			return "", err
+		}
+		if p.total > maxSummaryChunks {
+			return "", fmt.Errorf("聊天记录过多（约 %d 段），请缩小时间范围或指定 sender、keyword", p.total)
+		}
+		chunks = append(chunks, strings.Join(lines[p.start:p.end], ""))
+		if p.index == p.total {
+			break
+		}
+		cursor = strconv.Itoa(p.end)
+	}
+
+	focus := stringArg(args, "focus")
+	summaries := make([]string, 0, len(chunks))
+	for i, chunk := range chunks {
+		prompt := "聊天记录"
+		if len(chunks) > 1 {
+			prompt = fmt.Sprintf("聊天记录（第 %d/%d 段）", i+1, len(chunks))
+		}
+		if focus != "" {
+			prompt += "，请重点关注：" + focus
+		}
+		summary, err := s.sample(session, summarySystemPrompt, prompt+"\n\n"+chunk)
+		if err != nil {
+			return "", err
+		}
+		summaries = append(summaries, summary)
+	}
+
+	summary := summaries[0]
+	if len(summaries) > 1 {
+		prompt := "分段摘要"
+		if focus != "" {
+			prompt += "，请重点关注：" + focus
+		}
+		if summary, err = s.sample(session, mergeSystemPrompt, prompt+"\n\n"+strings.Join(summaries, "\n\n---\n\n")); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("以下摘要由客户端大模型根据 %d 条消息生成（共 %d 段）：\n\n%s", len(messages), len(chunks), summary), nil
+}
+
+// sample 发送一次 sampling 请求，返回生成的文本
+func (s *Service) sample(session *mcp.Session, system, text string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), samplingTimeout)
+	defer cancel()
+
+	var result mcp.CreateMessageResult
+	err := session.Call(ctx, mcp.MethodSamplingCreateMessage, mcp.CreateMessageRequest{
+		Messages: []mcp.SamplingMessage{
+			{Role: "user", Content: mcp.Content{Type: "text", Text: text}},
+		},
+		SystemPrompt:   system,
+		IncludeContext: "none",
+		MaxTokens:      summaryMaxTokens,
+	}, &result)
+	if err != nil {
+		return "", fmt.Errorf("客户端大模型调用失败: %v", err)
+	}
+	if result.Content.Type != "text" {
+		return "", fmt.Errorf("客户端大模型返回了不支持的内容类型: %s", result.Content.Type)
+	}
+	return result.Content.Text, nil
+}
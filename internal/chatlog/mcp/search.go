@@ -0,0 +1,147 @@
+package mcp
+
+import (
+	"context"
+
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sjzar/chatlog/internal/mcp"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 200
+
+	// snippetRadius 内容片段在命中位置两侧保留的字数
+	snippetRadius = 40
+
+	defaultContextSize = 15
+	maxContextSize     = 100
+)
+
+// searchMessages 按关键词及过滤条件搜索消息，按时间倒序返回命中片段与消息引用
+func (s *Service) searchMessages(ctx context.Context, buf *bytes.Buffer, args mcp.M) error {
+	keyword := stringArg(args, "keyword")
+	if keyword == "" {
+		return mcp.ErrInvalidParams
+	}
+	re, err := regexp.Compile(keyword)
+	if err != nil {
+		return fmt.Errorf("无效的关键词: %v", err)
+	}
+	msgType := stringArg(args, "type")
+	if _, err := model.ParseMessageTypes(msgType); err != nil {
+		return fmt.Errorf("无效的消息类型: %v", err)
+	}
+	start, end, err := analysisRange(args, "last-30d")
+	if err != nil {
+		return err
+	}
+	limit := util.MustAnyToInt(args["limit"])
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	talker := stringArg(args, "talker")
+	messages, err := s.db.GetMessages(ctx, start, end, talker, stringArg(args, "sender"), keyword, msgType, true, limit, 0)
+	if err != nil {
+		return fmt.Errorf("无法搜索聊天记录: %v", err)
+	}
+	if len(messages) == 0 {
+		buf.WriteString("未找到符合条件的消息")
+		return nil
+	}
+
+	lines := make([]string, 0, len(messages))
+	for _, m := range messages {
+		lines = append(lines, searchHit(m, re))
+	}
+	p, err := paginate(lines, s.ctx.Settings().MCP.MaxTokens, stringArg(args, "cursor"))
+	if err != nil {
+		return err
+	}
+	if p.index == 1 {
+		buf.WriteString(fmt.Sprintf("共 %d 条命中（按时间倒序）", len(messages)))
+		if len(messages) == limit {
+			buf.WriteString("，已达到 limit 上限，可缩小时间范围或增加过滤条件")
+		}
+		buf.WriteString("\n\n")
+	}
+	writePage(buf, lines, p)
+	return nil
+}
+
+// searchHit 输出一条命中：时间、会话、发送者、消息引用与内容片段
+func searchHit(m *model.Message, re *regexp.Regexp) string {
+	talker := m.Talker
+	if m.TalkerName != "" {
+		talker = m.TalkerName + "(" + m.Talker + ")"
+	}
+	sender := m.Sender
+	if m.SenderName != "" {
+		sender = m.SenderName + "(" + m.Sender + ")"
+	}
+	return fmt.Sprintf("%s [%s] %s ref=\"%s#%d\"\n%s\n\n",
+		m.Time.Format("2006-01-02 15:04:05"), talker, sender, m.Talker, m.Seq, snippet(m.PlainTextContent(), re))
+}
+
+// snippet 截取命中位置附近的内容并合并为单行，未命中（如关键词匹配在其他字段上）时截取开头
+func snippet(content string, re *regexp.Regexp) string {
+	content = strings.Join(strings.Fields(content), " ")
+	runes := []rune(content)
+	from, to := 0, 0
+	if loc := re.FindStringIndex(content); loc != nil {
+		from = len([]rune(content[:loc[0]]))
+		to = from + len([]rune(content[loc[0]:loc[1]]))
+	}
+	start, end := from-snippetRadius, to+snippetRadius
+	prefix, suffix := "…", "…"
+	if start <= 0 {
+		start, prefix = 0, ""
+	}
+	if end >= len(runes) {
+		end, suffix = len(runes), ""
+	}
+	return prefix + string(runes[start:end]) + suffix
+}
+
+// messageContext 根据消息引用获取目标消息前后的聊天记录
+func (s *Service) messageContext(ctx context.Context, buf *bytes.Buffer, args mcp.M) error {
+	ref := stringArg(args, "ref")
+	i := strings.LastIndex(ref, "#")
+	if i <= 0 {
+		return fmt.Errorf("无效的消息引用: %s", ref)
+	}
+	seq, err := strconv.ParseInt(ref[i+1:], 10, 64)
+	if err != nil || seq <= 0 {
+		return fmt.Errorf("无效的消息引用: %s", ref)
+	}
+	before, after := defaultContextSize, defaultContextSize
+	if _, ok := args["before"]; ok {
+		before = min(max(util.MustAnyToInt(args["before"]), 0), maxContextSize)
+	}
+	if _, ok := args["after"]; ok {
+		after = min(max(util.MustAnyToInt(args["after"]), 0), maxContextSize)
+	}
+
+	messages, index, err := s.db.GetMessageContext(ctx, ref[:i], seq, before, after)
+	if err != nil {
+		return fmt.Errorf("无法获取消息上下文: %v", err)
+	}
+	for i, m := range messages {
+		if i == index {
+			buf.WriteString("> ")
+		}
+		buf.WriteString(m.PlainText(false, "2006-01-02 15:04:05", ""))
+	}
+	return nil
+}
@@ -2,33 +2,44 @@ package mcp
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/sjzar/chatlog/internal/chatlog/analysis"
 	"github.com/sjzar/chatlog/internal/chatlog/ctx"
 	"github.com/sjzar/chatlog/internal/chatlog/database"
 	"github.com/sjzar/chatlog/internal/mcp"
+	"github.com/sjzar/chatlog/pkg/trace"
 	"github.com/sjzar/chatlog/pkg/util"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Service struct {
-	ctx *ctx.Context
-	db  *database.Service
+	ctx  *ctx.Context
+	db   *database.Service
+	opts atomic.Pointer[analysis.Options]
 
 	mcp *mcp.MCP
+
+	// cancel 停止资源更新通知
+	cancel func()
 }
 
 func NewService(ctx *ctx.Context, db *database.Service) *Service {
-	return &Service{
+	s := &Service{
 		ctx: ctx,
 		db:  db,
 	}
+	s.setOptions()
+	return s
 }
 
 // GetMCP 获取底层MCP实例
@@ -36,15 +47,44 @@ func (s *Service) GetMCP() *mcp.MCP {
 	return s.mcp
 }
 
+// Reload 应用重新加载的配置，ctx 已由调用方更新
+func (s *Service) Reload() {
+	s.setOptions()
+}
+
+// setOptions 按当前关键词规则生成分析参数
+func (s *Service) setOptions() {
+	opts := analysis.OptionsOf(s.ctx.Settings().Keywords)
+	s.opts.Store(&opts)
+}
+
+// options 当前的分析参数
+func (s *Service) options() analysis.Options {
+	return *s.opts.Load()
+}
+
 // Start 启动MCP服务
 func (s *Service) Start() error {
 	s.mcp = mcp.NewMCP()
+	s.mcp.BasePath = s.ctx.HTTP.Prefix()
 	go s.worker()
+
+	updates, cancel := s.db.Subscribe()
+	stop := make(chan struct{})
+	s.cancel = func() {
+		cancel()
+		close(stop)
+	}
+	go s.notifier(updates, stop)
 	return nil
 }
 
 // Stop 停止MCP服务
 func (s *Service) Stop() error {
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
 	if s.mcp != nil {
 		s.mcp.Close()
 	}
@@ -72,6 +112,11 @@ func (s *Service) HandleMessages(c *gin.Context) {
 	s.mcp.HandleMessages(c)
 }
 
+// ServeStdio 在标准输入输出上提供 MCP 服务，输入结束时返回
+func (s *Service) ServeStdio(r io.Reader, w io.Writer) error {
+	return s.mcp.ServeStdio(context.Background(), r, w)
+}
+
 // processMCP 处理MCP请求
 func (s *Service) processMCP(session *mcp.Session, req *mcp.Request) {
 	var err error
@@ -84,16 +129,23 @@ func (s *Service) processMCP(session *mcp.Session, req *mcp.Request) {
 			ToolChatRoom,
 			ToolRecentChat,
 			ToolChatLog,
+			ToolSearch,
+			ToolMessageContext,
 			ToolCurrentTime,
+			ToolImage,
+			ToolVoice,
+			ToolAnalysisStats,
+			ToolActivityHeatmap,
+			ToolDailySummary,
+			ToolGoldenQuotes,
+			ToolSummarize,
 		}})
 	case mcp.MethodToolsCall:
 		err = s.toolsCall(session, req)
 	case mcp.MethodPromptsList:
 		err = s.sendCustomParams(session, req, mcp.M{"prompts": []mcp.Prompt{}})
 	case mcp.MethodResourcesList:
-		err = s.sendCustomParams(session, req, mcp.M{"resources": []mcp.Resource{
-			ResourceRecentChat,
-		}})
+		err = s.resourcesList(session, req)
 	case mcp.MethodResourcesTemplateList:
 		err = s.sendCustomParams(session, req, mcp.M{"resourceTemplates": []mcp.ResourceTemplate{
 			ResourceTemplateContact,
@@ -102,6 +154,10 @@ func (s *Service) processMCP(session *mcp.Session, req *mcp.Request) {
 		}})
 	case mcp.MethodResourcesRead:
 		err = s.resourcesRead(session, req)
+	case mcp.MethodResourcesSubscribe:
+		err = s.resourcesSubscribe(session, req, true)
+	case mcp.MethodResourcesUnsubscribe:
+		err = s.resourcesSubscribe(session, req, false)
 	case mcp.MethodPing:
 		err = s.sendCustomParams(session, req, struct{}{})
 	}
@@ -118,17 +174,25 @@ func (s *Service) initialize(session *mcp.Session, req *mcp.Request) error {
 		return fmt.Errorf("解析初始化参数失败: %v", err)
 	}
 	session.SaveClientInfo(initReq.ClientInfo)
+	session.SaveClientCapabilities(initReq.Capabilities)
 
 	return session.WriteResponse(req, InitializeResponse)
 }
 
 // toolsCall 处理工具调用
-func (s *Service) toolsCall(session *mcp.Session, req *mcp.Request) error {
+func (s *Service) toolsCall(session *mcp.Session, req *mcp.Request) (err error) {
 	callReq, err := parseParams[mcp.ToolsCallRequest](req.Params)
 	if err != nil {
 		return fmt.Errorf("解析工具调用参数失败: %v", err)
 	}
 
+	// 每次工具调用单独记录链路，不挂在长时间保持的 SSE 连接下
+	ctx, span := trace.StartServer(trace.Detach(session.Context()), "mcp.tools/call "+callReq.Name, "", trace.String("mcp.tool.name", callReq.Name))
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
 	buf := &bytes.Buffer{}
 	switch callReq.Name {
 	case "query_contact":
@@ -138,7 +202,7 @@ func (s *Service) toolsCall(session *mcp.Session, req *mcp.Request) error {
 		}
 		limit := util.MustAnyToInt(callReq.Arguments["limit"])
 		offset := util.MustAnyToInt(callReq.Arguments["offset"])
-		list, err := s.db.GetContacts(keyword, limit, offset)
+		list, err := s.db.GetContacts(ctx, keyword, limit, offset)
 		if err != nil {
 			return fmt.Errorf("无法获取联系人列表: %v", err)
 		}
@@ -153,7 +217,7 @@ func (s *Service) toolsCall(session *mcp.Session, req *mcp.Request) error {
 		}
 		limit := util.MustAnyToInt(callReq.Arguments["limit"])
 		offset := util.MustAnyToInt(callReq.Arguments["offset"])
-		list, err := s.db.GetChatRooms(keyword, limit, offset)
+		list, err := s.db.GetChatRooms(ctx, keyword, limit, offset)
 		if err != nil {
 			return fmt.Errorf("无法获取群聊列表: %v", err)
 		}
@@ -168,7 +232,7 @@ func (s *Service) toolsCall(session *mcp.Session, req *mcp.Request) error {
 		}
 		limit := util.MustAnyToInt(callReq.Arguments["limit"])
 		offset := util.MustAnyToInt(callReq.Arguments["offset"])
-		data, err := s.db.GetSessions(keyword, limit, offset)
+		data, err := s.db.GetSessions(ctx, keyword, limit, offset)
 		if err != nil {
 			return fmt.Errorf("无法获取会话列表: %v", err)
 		}
@@ -202,22 +266,57 @@ func (s *Service) toolsCall(session *mcp.Session, req *mcp.Request) error {
 		}
 		limit := util.MustAnyToInt(callReq.Arguments["limit"])
 		offset := util.MustAnyToInt(callReq.Arguments["offset"])
-		messages, err := s.db.GetMessages(start, end, talker, sender, keyword, limit, offset)
+		messages, err := s.db.GetMessages(ctx, start, end, talker, sender, keyword, "", false, limit, offset)
 		if err != nil {
 			return fmt.Errorf("无法获取聊天记录: %v", err)
 		}
 		if len(messages) == 0 {
 			buf.WriteString("未找到符合查询条件的聊天记录")
 		}
+		lines := make([]string, 0, len(messages))
 		for _, m := range messages {
-			buf.WriteString(m.PlainText(strings.Contains(talker, ","), util.PerfectTimeFormat(start, end), ""))
-			buf.WriteString("\n")
+			lines = append(lines, m.PlainText(strings.Contains(talker, ","), util.PerfectTimeFormat(start, end), "")+"\n")
+		}
+		p, err := paginate(lines, s.ctx.Settings().MCP.MaxTokens, stringArg(callReq.Arguments, "cursor"))
+		if err != nil {
+			return err
 		}
+		writePage(buf, lines, p)
 	case "current_time":
 		buf.WriteString(time.Now().Local().Format(time.RFC3339))
+	case "analysis_stats":
+		err = s.analysisStats(ctx, buf, callReq.Arguments)
+	case "activity_heatmap":
+		err = s.activityHeatmap(ctx, buf, callReq.Arguments)
+	case "daily_summary":
+		err = s.dailySummary(ctx, buf, callReq.Arguments)
+	case "golden_quotes":
+		err = s.goldenQuotes(ctx, buf, callReq.Arguments)
+	case "search_messages":
+		err = s.searchMessages(ctx, buf, callReq.Arguments)
+	case "message_context":
+		err = s.messageContext(ctx, buf, callReq.Arguments)
+	case "summarize_chatlog":
+		// 需要等待客户端大模型的响应，响应由 summarize 写出
+		go s.summarize(session, req, callReq.Arguments)
+		return nil
+	case "get_image", "get_voice":
+		var content mcp.Content
+		if callReq.Name == "get_image" {
+			content, err = s.imageContent(ctx, mediaKeys(callReq.Arguments["key"]))
+		} else {
+			content, err = s.voiceContent(ctx, mediaKeys(callReq.Arguments["key"]))
+		}
+		if err != nil {
+			return err
+		}
+		return session.WriteResponse(req, mcp.ToolsCallResponse{Content: []mcp.Content{content}})
 	default:
 		return fmt.Errorf("未支持的工具: %s", callReq.Name)
 	}
+	if err != nil {
+		return err
+	}
 
 	resp := mcp.ToolsCallResponse{
 		Content: []mcp.Content{
@@ -240,10 +339,19 @@ func (s *Service) resourcesRead(session *mcp.Session, req *mcp.Request) error {
 		return fmt.Errorf("无法解析URI: %v", err)
 	}
 
+	// contact://all 与 chatroom://all 为完整列表，ID 完全匹配时返回详细信息
+	id := resourceID(u)
+	if id == "all" {
+		id = ""
+	}
+
 	buf := &bytes.Buffer{}
 	switch u.Scheme {
 	case "contact":
-		list, err := s.db.GetContacts(u.Host, 0, 0)
+		if id != "" && s.writeContact(session.Context(), buf, id) {
+			break
+		}
+		list, err := s.db.GetContacts(session.Context(), id, 0, 0)
 		if err != nil {
 			return fmt.Errorf("无法获取联系人列表: %v", err)
 		}
@@ -252,7 +360,10 @@ func (s *Service) resourcesRead(session *mcp.Session, req *mcp.Request) error {
 			buf.WriteString(fmt.Sprintf("%s,%s,%s,%s\n", contact.UserName, contact.Alias, contact.Remark, contact.NickName))
 		}
 	case "chatroom":
-		list, err := s.db.GetChatRooms(u.Host, 0, 0)
+		if id != "" && s.writeChatRoom(session.Context(), buf, id) {
+			break
+		}
+		list, err := s.db.GetChatRooms(session.Context(), id, 0, 0)
 		if err != nil {
 			return fmt.Errorf("无法获取群聊列表: %v", err)
 		}
@@ -261,7 +372,7 @@ func (s *Service) resourcesRead(session *mcp.Session, req *mcp.Request) error {
 			buf.WriteString(fmt.Sprintf("%s,%s,%s,%s,%d\n", chatRoom.Name, chatRoom.Remark, chatRoom.NickName, chatRoom.Owner, len(chatRoom.Users)))
 		}
 	case "session":
-		data, err := s.db.GetSessions("", 0, 0)
+		data, err := s.db.GetSessions(session.Context(), "", 0, 0)
 		if err != nil {
 			return fmt.Errorf("无法获取会话列表: %v", err)
 		}
@@ -276,7 +387,7 @@ func (s *Service) resourcesRead(session *mcp.Session, req *mcp.Request) error {
 		}
 		limit := util.MustAnyToInt(u.Query().Get("limit"))
 		offset := util.MustAnyToInt(u.Query().Get("offset"))
-		messages, err := s.db.GetMessages(start, end, u.Host, "", "", limit, offset)
+		messages, err := s.db.GetMessages(session.Context(), start, end, id, "", "", "", false, limit, offset)
 		if err != nil {
 			return fmt.Errorf("无法获取聊天记录: %v", err)
 		}
@@ -284,7 +395,7 @@ func (s *Service) resourcesRead(session *mcp.Session, req *mcp.Request) error {
 			buf.WriteString("未找到符合查询条件的聊天记录")
 		}
 		for _, m := range messages {
-			buf.WriteString(m.PlainText(strings.Contains(u.Host, ","), util.PerfectTimeFormat(start, end), ""))
+			buf.WriteString(m.PlainText(strings.Contains(id, ","), util.PerfectTimeFormat(start, end), ""))
 			buf.WriteString("\n")
 		}
 	default:
@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	base := time.Date(2024, 3, 15, 10, 30, 0, 0, time.Local) // 周五
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"0 8 * * *", time.Date(2024, 3, 16, 8, 0, 0, 0, time.Local)},
+		{"45 10 * * *", time.Date(2024, 3, 15, 10, 45, 0, 0, time.Local)},
+		{"*/15 * * * *", time.Date(2024, 3, 15, 10, 45, 0, 0, time.Local)},
+		{"@hourly", time.Date(2024, 3, 15, 11, 0, 0, 0, time.Local)},
+		{"@daily", time.Date(2024, 3, 16, 0, 0, 0, 0, time.Local)},
+		{"0 9 * * 1", time.Date(2024, 3, 18, 9, 0, 0, 0, time.Local)},
+		{"0 9 * * 7", time.Date(2024, 3, 17, 9, 0, 0, 0, time.Local)},
+		{"0 9 1 * *", time.Date(2024, 4, 1, 9, 0, 0, 0, time.Local)},
+		{"0 9 1-5 * 1-5", time.Date(2024, 3, 18, 9, 0, 0, 0, time.Local)},
+		{"30 10 15 3 *", time.Date(2025, 3, 15, 10, 30, 0, 0, time.Local)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.Local)},
+	}
+
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(base); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q).Next() = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) expected error", spec)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type entry struct {
+	name     string
+	schedule *Schedule
+	run      func()
+	next     time.Time
+}
+
+// Scheduler 按 cron 表达式定时执行任务
+type Scheduler struct {
+	entries []*entry
+	stop    chan struct{}
+	running bool
+	mu      sync.Mutex
+}
+
+func New() *Scheduler {
+	return &Scheduler{
+		entries: make([]*entry, 0),
+	}
+}
+
+// Add 添加定时任务，需在 Start 之前调用
+func (s *Scheduler) Add(name, spec string, run func()) error {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, &entry{
+		name:     name,
+		schedule: schedule,
+		run:      run,
+	})
+	return nil
+}
+
+// Len 返回任务数量
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Start 在后台开始调度
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running || len(s.entries) == 0 {
+		return
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+	go s.loop(s.stop)
+}
+
+// Stop 停止调度，已开始执行的任务不受影响
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		return
+	}
+	s.running = false
+	close(s.stop)
+}
+
+func (s *Scheduler) loop(stop chan struct{}) {
+	now := time.Now()
+	for _, e := range s.entries {
+		e.next = e.schedule.Next(now)
+		log.Info().Msgf("scheduled task %s, next run at %s", e.name, e.next.Format("2006-01-02 15:04:05"))
+	}
+
+	for {
+		var earliest time.Time
+		for _, e := range s.entries {
+			if e.next.IsZero() {
+				continue
+			}
+			if earliest.IsZero() || e.next.Before(earliest) {
+				earliest = e.next
+			}
+		}
+		if earliest.IsZero() {
+			return
+		}
+
+		timer := time.NewTimer(time.Until(earliest))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case now = <-timer.C:
+		}
+
+		for _, e := range s.entries {
+			if e.next.IsZero() || e.next.After(now) {
+				continue
+			}
+			log.Info().Msgf("running scheduled task %s", e.name)
+			go e.run()
+			e.next = e.schedule.Next(now)
+		}
+	}
+}
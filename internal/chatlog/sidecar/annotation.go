@@ -0,0 +1,210 @@
+package sidecar
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+// SetAnnotation 保存消息的备注与标签，覆盖已有的内容，备注与标签都为空时删除
+func (s *Store) SetAnnotation(ctx context.Context, account string, a *model.Annotation) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	a.Note = strings.TrimSpace(a.Note)
+	a.Tags = normalizeTags(a.Tags)
+	if a.Note == "" && len(a.Tags) == 0 {
+		query := "DELETE FROM annotations WHERE account = ? AND talker = ? AND seq = ?"
+		if _, err := db.ExecContext(ctx, query, account, a.Talker, a.Seq); err != nil {
+			return errors.QueryFailed(query, err)
+		}
+		return nil
+	}
+
+	tags, _ := json.Marshal(a.Tags)
+	a.UpdatedAt = time.Now().Truncate(time.Second)
+	query := `INSERT INTO annotations (account, talker, seq, note, tags, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (account, talker, seq) DO UPDATE SET note = excluded.note, tags = excluded.tags, updated_at = excluded.updated_at`
+	if _, err := db.ExecContext(ctx, query, account, a.Talker, a.Seq, a.Note, string(tags), a.UpdatedAt.Unix()); err != nil {
+		return errors.QueryFailed(query, err)
+	}
+	return nil
+}
+
+// GetAnnotation 查询消息的备注与标签，没有时返回 404 错误
+func (s *Store) GetAnnotation(ctx context.Context, account, talker string, seq int64) (*model.Annotation, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT talker, seq, note, tags, updated_at FROM annotations WHERE account = ? AND talker = ? AND seq = ?"
+	a, err := scanAnnotation(db.QueryRowContext(ctx, query, account, talker, seq))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.AnnotationNotFound(talker, seq)
+		}
+		return nil, errors.QueryFailed(query, err)
+	}
+	return a, nil
+}
+
+// DeleteAnnotation 删除消息的备注与标签，没有时返回 404 错误
+func (s *Store) DeleteAnnotation(ctx context.Context, account, talker string, seq int64) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	query := "DELETE FROM annotations WHERE account = ? AND talker = ? AND seq = ?"
+	res, err := db.ExecContext(ctx, query, account, talker, seq)
+	if err != nil {
+		return errors.QueryFailed(query, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.AnnotationNotFound(talker, seq)
+	}
+	return nil
+}
+
+// ListAnnotations 按修改时间倒序列出备注，talker、tag 不为空时只列出该会话或带有该标签的备注，同时返回总数
+func (s *Store) ListAnnotations(ctx context.Context, account, talker, tag string, limit, offset int) ([]*model.Annotation, int, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, 0, err
+	}
+	where := " WHERE account = ?"
+	args := []interface{}{account}
+	if talker != "" {
+		where += " AND talker = ?"
+		args = append(args, talker)
+	}
+	if tag != "" {
+		where += " AND EXISTS (SELECT 1 FROM json_each(annotations.tags) WHERE value = ?)"
+		args = append(args, tag)
+	}
+
+	var total int
+	query := "SELECT COUNT(*) FROM annotations" + where
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return nil, 0, errors.QueryFailed(query, err)
+	}
+
+	query = "SELECT talker, seq, note, tags, updated_at FROM annotations" + where + " ORDER BY updated_at DESC, talker, seq DESC"
+	if limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, offset)
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+
+	ret := make([]*model.Annotation, 0)
+	for rows.Next() {
+		a, err := scanAnnotation(rows)
+		if err != nil {
+			return nil, 0, errors.ScanRowFailed(err)
+		}
+		ret = append(ret, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, errors.QueryFailed(query, err)
+	}
+	return ret, total, nil
+}
+
+// Annotate 为消息附加备注与标签，没有备注的消息不变
+// 按消息序号范围一次读取，合并多个账号的消息按各自的账号读取
+func (s *Store) Annotate(ctx context.Context, account string, messages []*model.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+
+	type key struct {
+		account string
+		talker  string
+		seq     int64
+	}
+	byAccount := make(map[string][2]int64)
+	index := make(map[key]*model.Message, len(messages))
+	for _, m := range messages {
+		a := account
+		if m.Account != "" {
+			a = m.Account
+		}
+		index[key{a, m.Talker, m.Seq}] = m
+		r, ok := byAccount[a]
+		if !ok {
+			r = [2]int64{m.Seq, m.Seq}
+		}
+		r[0], r[1] = min(r[0], m.Seq), max(r[1], m.Seq)
+		byAccount[a] = r
+	}
+
+	query := "SELECT talker, seq, note, tags, updated_at FROM annotations WHERE account = ? AND seq >= ? AND seq <= ?"
+	for a, r := range byAccount {
+		rows, err := db.QueryContext(ctx, query, a, r[0], r[1])
+		if err != nil {
+			return errors.QueryFailed(query, err)
+		}
+		for rows.Next() {
+			annotation, err := scanAnnotation(rows)
+			if err != nil {
+				rows.Close()
+				return errors.ScanRowFailed(err)
+			}
+			if m, ok := index[key{a, annotation.Talker, annotation.Seq}]; ok {
+				m.Annotation = annotation
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return errors.QueryFailed(query, err)
+		}
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAnnotation(row scanner) (*model.Annotation, error) {
+	var tags string
+	var updated int64
+	a := &model.Annotation{}
+	if err := row.Scan(&a.Talker, &a.Seq, &a.Note, &tags, &updated); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(tags), &a.Tags); err != nil || a.Tags == nil {
+		a.Tags = []string{}
+	}
+	a.UpdatedAt = time.Unix(updated, 0)
+	return a, nil
+}
+
+// normalizeTags 去掉标签两端的空白、空标签与重复的标签，保持原有顺序
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	ret := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		ret = append(ret, tag)
+	}
+	return ret
+}
@@ -0,0 +1,133 @@
+package sidecar
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// Bookmark 收藏的消息
+type Bookmark struct {
+	Talker    string    `json:"talker"`
+	Seq       int64     `json:"seq"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AddBookmark 收藏消息，已收藏时更新备注，保留原收藏时间
+func (s *Store) AddBookmark(ctx context.Context, account string, b *Bookmark) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	if b.CreatedAt.IsZero() {
+		b.CreatedAt = time.Now()
+	}
+	query := `INSERT INTO bookmarks (account, talker, seq, note, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (account, talker, seq) DO UPDATE SET note = excluded.note`
+	if _, err := db.ExecContext(ctx, query, account, b.Talker, b.Seq, b.Note, b.CreatedAt.Unix()); err != nil {
+		return errors.QueryFailed(query, err)
+	}
+	return nil
+}
+
+// GetBookmark 查询收藏的消息，未收藏时返回 404 错误
+func (s *Store) GetBookmark(ctx context.Context, account, talker string, seq int64) (*Bookmark, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	var created int64
+	b := &Bookmark{Talker: talker, Seq: seq}
+	query := "SELECT note, created_at FROM bookmarks WHERE account = ? AND talker = ? AND seq = ?"
+	if err := db.QueryRowContext(ctx, query, account, talker, seq).Scan(&b.Note, &created); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.BookmarkNotFound(talker, seq)
+		}
+		return nil, errors.QueryFailed(query, err)
+	}
+	b.CreatedAt = time.Unix(created, 0)
+	return b, nil
+}
+
+// UpdateBookmark 修改收藏的备注，未收藏时返回 404 错误
+func (s *Store) UpdateBookmark(ctx context.Context, account, talker string, seq int64, note string) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	query := "UPDATE bookmarks SET note = ? WHERE account = ? AND talker = ? AND seq = ?"
+	res, err := db.ExecContext(ctx, query, note, account, talker, seq)
+	if err != nil {
+		return errors.QueryFailed(query, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.BookmarkNotFound(talker, seq)
+	}
+	return nil
+}
+
+// DeleteBookmark 取消收藏，未收藏时返回 404 错误
+func (s *Store) DeleteBookmark(ctx context.Context, account, talker string, seq int64) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	query := "DELETE FROM bookmarks WHERE account = ? AND talker = ? AND seq = ?"
+	res, err := db.ExecContext(ctx, query, account, talker, seq)
+	if err != nil {
+		return errors.QueryFailed(query, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.BookmarkNotFound(talker, seq)
+	}
+	return nil
+}
+
+// ListBookmarks 按收藏时间倒序列出收藏的消息，talker 不为空时只列出该会话，同时返回总数
+func (s *Store) ListBookmarks(ctx context.Context, account, talker string, limit, offset int) ([]*Bookmark, int, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, 0, err
+	}
+	where := " WHERE account = ?"
+	args := []interface{}{account}
+	if talker != "" {
+		where += " AND talker = ?"
+		args = append(args, talker)
+	}
+
+	var total int
+	query := "SELECT COUNT(*) FROM bookmarks" + where
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return nil, 0, errors.QueryFailed(query, err)
+	}
+
+	query = "SELECT talker, seq, note, created_at FROM bookmarks" + where + " ORDER BY created_at DESC, talker, seq DESC"
+	if limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, offset)
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+
+	ret := make([]*Bookmark, 0)
+	for rows.Next() {
+		var created int64
+		b := &Bookmark{}
+		if err := rows.Scan(&b.Talker, &b.Seq, &b.Note, &created); err != nil {
+			return nil, 0, errors.ScanRowFailed(err)
+		}
+		b.CreatedAt = time.Unix(created, 0)
+		ret = append(ret, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, errors.QueryFailed(query, err)
+	}
+	return ret, total, nil
+}
@@ -0,0 +1,131 @@
+package sidecar
+
+import (
+	"context"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// Identity 同一个人的多个微信账号，如更换账号前后的旧号与新号
+// 查询、统计与导出时 Aliases 中的账号视为 ID
+type Identity struct {
+	ID      string   `json:"id"`
+	Aliases []string `json:"aliases"`
+}
+
+// Identities 按 ID 列出所有合并的身份
+func (s *Store) Identities(ctx context.Context, account string) ([]*Identity, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT id, alias FROM identities WHERE account = ? ORDER BY id, created_at, alias"
+	rows, err := db.QueryContext(ctx, query, account)
+	if err != nil {
+		return nil, errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+
+	ret := make([]*Identity, 0)
+	for rows.Next() {
+		var id, alias string
+		if err := rows.Scan(&id, &alias); err != nil {
+			return nil, errors.ScanRowFailed(err)
+		}
+		if n := len(ret); n == 0 || ret[n-1].ID != id {
+			ret = append(ret, &Identity{ID: id})
+		}
+		last := ret[len(ret)-1]
+		last.Aliases = append(last.Aliases, alias)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.QueryFailed(query, err)
+	}
+	return ret, nil
+}
+
+// IdentityMap 账号到所属身份 ID 的映射，不包含 ID 本身
+func (s *Store) IdentityMap(ctx context.Context, account string) (map[string]string, error) {
+	identities, err := s.Identities(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]string)
+	for _, identity := range identities {
+		for _, alias := range identity.Aliases {
+			ret[alias] = identity.ID
+		}
+	}
+	return ret, nil
+}
+
+// MergeIdentity 将 aliases 合并到身份 id，返回实际使用的 ID
+// id 本身已合并到其他身份时合并到该身份，aliases 原有的账号一并合并，保持映射只有一层
+func (s *Store) MergeIdentity(ctx context.Context, account, id string, aliases []string) (string, error) {
+	db, err := s.open()
+	if err != nil {
+		return "", err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", errors.QueryFailed("BEGIN", err)
+	}
+	defer tx.Rollback()
+
+	query := "SELECT id FROM identities WHERE account = ? AND alias = ?"
+	var primary string
+	if err := tx.QueryRowContext(ctx, query, account, id).Scan(&primary); err == nil {
+		id = primary
+	}
+
+	now := time.Now().Unix()
+	for _, alias := range aliases {
+		if alias == "" || alias == id {
+			continue
+		}
+		query := "UPDATE identities SET id = ? WHERE account = ? AND id = ?"
+		if _, err := tx.ExecContext(ctx, query, id, account, alias); err != nil {
+			return "", errors.QueryFailed(query, err)
+		}
+		query = `INSERT INTO identities (account, alias, id, created_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT (account, alias) DO UPDATE SET id = excluded.id`
+		if _, err := tx.ExecContext(ctx, query, account, alias, id, now); err != nil {
+			return "", errors.QueryFailed(query, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return "", errors.QueryFailed("COMMIT", err)
+	}
+	return id, nil
+}
+
+// RemoveIdentity 将账号从身份中拆分出来，aliases 为空时拆分整个身份，没有可拆分的账号时返回 404 错误
+func (s *Store) RemoveIdentity(ctx context.Context, account, id string, aliases ...string) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	var removed int64
+	if len(aliases) == 0 {
+		query := "DELETE FROM identities WHERE account = ? AND id = ?"
+		res, err := db.ExecContext(ctx, query, account, id)
+		if err != nil {
+			return errors.QueryFailed(query, err)
+		}
+		removed, _ = res.RowsAffected()
+	}
+	query := "DELETE FROM identities WHERE account = ? AND id = ? AND alias = ?"
+	for _, alias := range aliases {
+		res, err := db.ExecContext(ctx, query, account, id, alias)
+		if err != nil {
+			return errors.QueryFailed(query, err)
+		}
+		n, _ := res.RowsAffected()
+		removed += n
+	}
+	if removed == 0 {
+		return errors.IdentityNotFound(id)
+	}
+	return nil
+}
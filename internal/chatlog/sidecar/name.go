@@ -0,0 +1,99 @@
+package sidecar
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// DisplayName 联系人或群聊的自定义显示名称，优先于备注与昵称
+type DisplayName struct {
+	Talker    string    `json:"talker"`
+	Name      string    `json:"name"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// SetDisplayName 设置会话的显示名称，覆盖已有的名称，名称为空时删除
+func (s *Store) SetDisplayName(ctx context.Context, account string, n *DisplayName) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	n.Name = strings.TrimSpace(n.Name)
+	if n.Name == "" {
+		query := "DELETE FROM names WHERE account = ? AND talker = ?"
+		if _, err := db.ExecContext(ctx, query, account, n.Talker); err != nil {
+			return errors.QueryFailed(query, err)
+		}
+		return nil
+	}
+
+	n.UpdatedAt = time.Now().Truncate(time.Second)
+	query := `INSERT INTO names (account, talker, name, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (account, talker) DO UPDATE SET name = excluded.name, updated_at = excluded.updated_at`
+	if _, err := db.ExecContext(ctx, query, account, n.Talker, n.Name, n.UpdatedAt.Unix()); err != nil {
+		return errors.QueryFailed(query, err)
+	}
+	return nil
+}
+
+// DeleteDisplayName 删除会话的显示名称，没有时返回 404 错误
+func (s *Store) DeleteDisplayName(ctx context.Context, account, talker string) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	query := "DELETE FROM names WHERE account = ? AND talker = ?"
+	res, err := db.ExecContext(ctx, query, account, talker)
+	if err != nil {
+		return errors.QueryFailed(query, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.DisplayNameNotFound(talker)
+	}
+	return nil
+}
+
+// ListDisplayNames 按名称列出账号的全部显示名称
+func (s *Store) ListDisplayNames(ctx context.Context, account string) ([]*DisplayName, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT talker, name, updated_at FROM names WHERE account = ? ORDER BY name, talker"
+	rows, err := db.QueryContext(ctx, query, account)
+	if err != nil {
+		return nil, errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+
+	ret := make([]*DisplayName, 0)
+	for rows.Next() {
+		var updated int64
+		n := &DisplayName{}
+		if err := rows.Scan(&n.Talker, &n.Name, &updated); err != nil {
+			return nil, errors.ScanRowFailed(err)
+		}
+		n.UpdatedAt = time.Unix(updated, 0)
+		ret = append(ret, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.QueryFailed(query, err)
+	}
+	return ret, nil
+}
+
+// DisplayNames 账号的全部显示名称，以会话 ID 为键
+func (s *Store) DisplayNames(ctx context.Context, account string) (map[string]string, error) {
+	names, err := s.ListDisplayNames(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]string, len(names))
+	for _, n := range names {
+		ret[n.Talker] = n.Name
+	}
+	return ret, nil
+}
@@ -0,0 +1,163 @@
+// Package sidecar 保存用户在聊天记录之上添加的数据，如收藏的消息、消息备注与标签、会话标签、自定义显示名称、合并的联系人身份
+// 数据保存在配置目录中独立的 SQLite 数据库，与工作目录分开，重新解密或清空工作目录后仍然保留
+// 消息以账号、会话与消息序号标识，消息序号由消息时间生成，重新解密后不变
+package sidecar
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// File 配置目录中的数据库文件名
+const File = "sidecar.db"
+
+// schema 按顺序执行，只追加新的语句，已有的数据库打开时补充新增的表
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS bookmarks (
+		account TEXT NOT NULL,
+		talker TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL,
+		PRIMARY KEY (account, talker, seq)
+	) WITHOUT ROWID`,
+	`CREATE INDEX IF NOT EXISTS bookmarks_created ON bookmarks (account, created_at)`,
+	`CREATE TABLE IF NOT EXISTS annotations (
+		account TEXT NOT NULL,
+		talker TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		tags TEXT NOT NULL DEFAULT '[]',
+		updated_at INTEGER NOT NULL,
+		PRIMARY KEY (account, talker, seq)
+	) WITHOUT ROWID`,
+	`CREATE INDEX IF NOT EXISTS annotations_updated ON annotations (account, updated_at)`,
+	`CREATE TABLE IF NOT EXISTS tags (
+		account TEXT NOT NULL,
+		tag TEXT NOT NULL,
+		talker TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		PRIMARY KEY (account, tag, talker)
+	) WITHOUT ROWID`,
+	`CREATE INDEX IF NOT EXISTS tags_talker ON tags (account, talker)`,
+	`CREATE TABLE IF NOT EXISTS names (
+		account TEXT NOT NULL,
+		talker TEXT NOT NULL,
+		name TEXT NOT NULL,
+		updated_at INTEGER NOT NULL,
+		PRIMARY KEY (account, talker)
+	) WITHOUT ROWID`,
+	`CREATE TABLE IF NOT EXISTS identities (
+		account TEXT NOT NULL,
+		alias TEXT NOT NULL,
+		id TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		PRIMARY KEY (account, alias)
+	) WITHOUT ROWID`,
+	`CREATE INDEX IF NOT EXISTS identities_id ON identities (account, id)`,
+}
+
+// Store 附加数据存储，首次使用时打开数据库
+type Store struct {
+	path func() string
+
+	mu sync.Mutex
+	db *sql.DB
+}
+
+// New 创建存储，path 返回数据库文件路径，为空时不可用
+func New(path func() string) *Store {
+	return &Store{path: path}
+}
+
+// open 打开数据库，不存在时创建
+func (s *Store) open() (*sql.DB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db != nil {
+		return s.db, nil
+	}
+
+	path := s.path()
+	if path == "" {
+		return nil, errors.SidecarUnavailable(fmt.Errorf("no config dir"))
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, errors.SidecarUnavailable(err)
+	}
+	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?_journal_mode=WAL&_busy_timeout=5000", path))
+	if err != nil {
+		return nil, errors.DBConnectFailed(path, err)
+	}
+	for _, query := range schema {
+		if _, err := db.Exec(query); err != nil {
+			db.Close()
+			return nil, errors.QueryFailed(query, err)
+		}
+	}
+	s.db = db
+	return db, nil
+}
+
+// Close 关闭数据库，之后再次使用时重新打开
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
+// Purged 清除会话时删除的附加数据条数
+type Purged struct {
+	Bookmarks    int64 `json:"bookmarks"`
+	Annotations  int64 `json:"annotations"`
+	Tags         int64 `json:"tags"`
+	DisplayNames int64 `json:"display_names"`
+}
+
+// PurgeTalker 删除账号中与会话相关的收藏、消息备注、会话标签与显示名称，合并的身份不做修改
+func (s *Store) PurgeTalker(ctx context.Context, account, talker string) (*Purged, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errors.QueryFailed("BEGIN", err)
+	}
+	defer tx.Rollback()
+
+	ret := &Purged{}
+	for _, t := range []struct {
+		table string
+		n     *int64
+	}{
+		{"bookmarks", &ret.Bookmarks},
+		{"annotations", &ret.Annotations},
+		{"tags", &ret.Tags},
+		{"names", &ret.DisplayNames},
+	} {
+		query := "DELETE FROM " + t.table + " WHERE account = ? AND talker = ?"
+		res, err := tx.ExecContext(ctx, query, account, talker)
+		if err != nil {
+			return nil, errors.QueryFailed(query, err)
+		}
+		*t.n, _ = res.RowsAffected()
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, errors.QueryFailed("COMMIT", err)
+	}
+	return ret, nil
+}
@@ -0,0 +1,269 @@
+package sidecar
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+// newTestStore 临时目录中的存储，测试结束时关闭
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), File)
+	s := New(func() string { return path })
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestBookmarks(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	if err := s.AddBookmark(ctx, "alice", &Bookmark{Talker: "wxid_a", Seq: 1, Note: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddBookmark(ctx, "alice", &Bookmark{Talker: "wxid_b", Seq: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddBookmark(ctx, "bob", &Bookmark{Talker: "wxid_a", Seq: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重复收藏只更新备注
+	if err := s.AddBookmark(ctx, "alice", &Bookmark{Talker: "wxid_a", Seq: 1, Note: "updated"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.GetBookmark(ctx, "alice", "wxid_a", 1)
+	if err != nil || b.Note != "updated" {
+		t.Fatalf("GetBookmark = %+v, %v", b, err)
+	}
+
+	items, total, err := s.ListBookmarks(ctx, "alice", "", 0, 0)
+	if err != nil || total != 2 || len(items) != 2 {
+		t.Fatalf("ListBookmarks = %d items, total %d, %v", len(items), total, err)
+	}
+	items, total, _ = s.ListBookmarks(ctx, "alice", "wxid_b", 0, 0)
+	if total != 1 || items[0].Seq != 2 {
+		t.Fatalf("ListBookmarks(wxid_b) = %+v, total %d", items, total)
+	}
+
+	// 重新打开后仍然保留
+	s.Close()
+	if err := s.DeleteBookmark(ctx, "alice", "wxid_a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetBookmark(ctx, "alice", "wxid_a", 1); err == nil {
+		t.Fatal("bookmark not deleted")
+	}
+	if err := s.DeleteBookmark(ctx, "alice", "wxid_a", 1); err == nil {
+		t.Fatal("expected not found")
+	}
+	if err := s.UpdateBookmark(ctx, "bob", "wxid_a", 1, "note"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	if err := s.SetAnnotation(ctx, "alice", &model.Annotation{Talker: "wxid_a", Seq: 1, Note: " note ", Tags: []string{"todo", " todo", "", "work"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetAnnotation(ctx, "alice", &model.Annotation{Talker: "wxid_b", Seq: 5, Tags: []string{"work"}}); err != nil {
+		t.Fatal(err)
+	}
+	a, err := s.GetAnnotation(ctx, "alice", "wxid_a", 1)
+	if err != nil || a.Note != "note" || len(a.Tags) != 2 {
+		t.Fatalf("GetAnnotation = %+v, %v", a, err)
+	}
+
+	items, total, err := s.ListAnnotations(ctx, "alice", "", "work", 0, 0)
+	if err != nil || total != 2 || len(items) != 2 {
+		t.Fatalf("ListAnnotations(work) = %d items, total %d, %v", len(items), total, err)
+	}
+	if _, total, _ = s.ListAnnotations(ctx, "alice", "", "todo", 0, 0); total != 1 {
+		t.Fatalf("ListAnnotations(todo) total = %d", total)
+	}
+
+	messages := []*model.Message{{Talker: "wxid_a", Seq: 1}, {Talker: "wxid_b", Seq: 1}, {Talker: "wxid_b", Seq: 5}}
+	if err := s.Annotate(ctx, "alice", messages); err != nil {
+		t.Fatal(err)
+	}
+	if messages[0].Annotation == nil || messages[1].Annotation != nil || messages[2].Annotation == nil {
+		t.Fatalf("Annotate = %+v, %+v, %+v", messages[0].Annotation, messages[1].Annotation, messages[2].Annotation)
+	}
+
+	// 备注与标签都为空时删除
+	if err := s.SetAnnotation(ctx, "alice", &model.Annotation{Talker: "wxid_b", Seq: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetAnnotation(ctx, "alice", "wxid_b", 5); err == nil {
+		t.Fatal("annotation not deleted")
+	}
+}
+
+func TestTags(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	if err := s.AddTag(ctx, "alice", "family", []string{"wxid_a", "wxid_b"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddTag(ctx, "alice", "work", []string{"wxid_b", "room@chatroom"}, false); err != nil {
+		t.Fatal(err)
+	}
+	// 重复添加不会产生重复的会话
+	if err := s.AddTag(ctx, "alice", "family", []string{"wxid_a"}, false); err != nil {
+		t.Fatal(err)
+	}
+	talkers, err := s.TagTalkers(ctx, "alice", "family")
+	if err != nil || len(talkers) != 2 {
+		t.Fatalf("TagTalkers(family) = %v, %v", talkers, err)
+	}
+	if talkers, _ = s.TagTalkers(ctx, "bob", "family"); len(talkers) != 0 {
+		t.Fatalf("TagTalkers(bob) = %v", talkers)
+	}
+
+	tags, err := s.Tags(ctx, "alice", "")
+	if err != nil || len(tags) != 2 || tags[0].Name != "family" {
+		t.Fatalf("Tags = %+v, %v", tags, err)
+	}
+	if tags, _ = s.Tags(ctx, "alice", "wxid_a"); len(tags) != 1 || tags[0].Name != "family" {
+		t.Fatalf("Tags(wxid_a) = %+v", tags)
+	}
+
+	// 替换原有的会话
+	if err := s.AddTag(ctx, "alice", "work", []string{"wxid_c"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if talkers, _ = s.TagTalkers(ctx, "alice", "work"); len(talkers) != 1 || talkers[0] != "wxid_c" {
+		t.Fatalf("TagTalkers(work) = %v", talkers)
+	}
+
+	if err := s.RemoveTag(ctx, "alice", "family", "wxid_a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveTag(ctx, "alice", "family", "wxid_a"); err == nil {
+		t.Fatal("expected not found")
+	}
+	if err := s.RemoveTag(ctx, "alice", "work"); err != nil {
+		t.Fatal(err)
+	}
+	if tags, _ = s.Tags(ctx, "alice", ""); len(tags) != 1 || len(tags[0].Talkers) != 1 {
+		t.Fatalf("Tags after remove = %+v", tags)
+	}
+}
+
+func TestDisplayNames(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	if err := s.SetDisplayName(ctx, "alice", &DisplayName{Talker: "wxid_a", Name: " Mom "}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetDisplayName(ctx, "alice", &DisplayName{Talker: "wxid_a", Name: "Mum"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetDisplayName(ctx, "bob", &DisplayName{Talker: "wxid_b", Name: "Boss"}); err != nil {
+		t.Fatal(err)
+	}
+	names, err := s.DisplayNames(ctx, "alice")
+	if err != nil || len(names) != 1 || names["wxid_a"] != "Mum" {
+		t.Fatalf("DisplayNames = %v, %v", names, err)
+	}
+
+	// 名称为空时删除
+	if err := s.SetDisplayName(ctx, "alice", &DisplayName{Talker: "wxid_a"}); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ = s.DisplayNames(ctx, "alice"); len(names) != 0 {
+		t.Fatalf("DisplayNames after clear = %v", names)
+	}
+	if err := s.DeleteDisplayName(ctx, "alice", "wxid_a"); err == nil {
+		t.Fatal("expected not found")
+	}
+	if err := s.DeleteDisplayName(ctx, "bob", "wxid_b"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIdentities(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	if _, err := s.MergeIdentity(ctx, "alice", "wxid_b", []string{"wxid_a"}); err != nil {
+		t.Fatal(err)
+	}
+	// 合并到已合并的账号时使用其身份 ID，原有身份一并合并
+	id, err := s.MergeIdentity(ctx, "alice", "wxid_a", []string{"wxid_c", "wxid_a"})
+	if err != nil || id != "wxid_b" {
+		t.Fatalf("MergeIdentity = %s, %v", id, err)
+	}
+	if _, err := s.MergeIdentity(ctx, "alice", "wxid_d", []string{"wxid_b"}); err != nil {
+		t.Fatal(err)
+	}
+	ids, err := s.IdentityMap(ctx, "alice")
+	if err != nil || len(ids) != 3 || ids["wxid_a"] != "wxid_d" || ids["wxid_b"] != "wxid_d" || ids["wxid_c"] != "wxid_d" {
+		t.Fatalf("IdentityMap = %v, %v", ids, err)
+	}
+	if identities, _ := s.Identities(ctx, "alice"); len(identities) != 1 || len(identities[0].Aliases) != 3 {
+		t.Fatalf("Identities = %+v", identities)
+	}
+
+	if err := s.RemoveIdentity(ctx, "alice", "wxid_d", "wxid_a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveIdentity(ctx, "alice", "wxid_d", "wxid_a"); err == nil {
+		t.Fatal("expected not found")
+	}
+	if err := s.RemoveIdentity(ctx, "alice", "wxid_d"); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ = s.IdentityMap(ctx, "alice"); len(ids) != 0 {
+		t.Fatalf("IdentityMap after remove = %v", ids)
+	}
+}
+
+func TestPurgeTalker(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	for _, account := range []string{"alice", "bob"} {
+		if err := s.AddBookmark(ctx, account, &Bookmark{Talker: "wxid_a", Seq: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetAnnotation(ctx, account, &model.Annotation{Talker: "wxid_a", Seq: 1, Note: "note"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddTag(ctx, account, "family", []string{"wxid_a", "wxid_b"}, false); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetDisplayName(ctx, account, &DisplayName{Talker: "wxid_a", Name: "Mom"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AddBookmark(ctx, "alice", &Bookmark{Talker: "wxid_b", Seq: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := s.PurgeTalker(ctx, "alice", "wxid_a")
+	if err != nil || *purged != (Purged{Bookmarks: 1, Annotations: 1, Tags: 1, DisplayNames: 1}) {
+		t.Fatalf("PurgeTalker = %+v, %v", purged, err)
+	}
+	if _, total, _ := s.ListBookmarks(ctx, "alice", "", 0, 0); total != 1 {
+		t.Fatalf("ListBookmarks after purge total = %d", total)
+	}
+	if talkers, _ := s.TagTalkers(ctx, "alice", "family"); len(talkers) != 1 || talkers[0] != "wxid_b" {
+		t.Fatalf("TagTalkers after purge = %v", talkers)
+	}
+	if names, _ := s.DisplayNames(ctx, "alice"); len(names) != 0 {
+		t.Fatalf("DisplayNames after purge = %v", names)
+	}
+	// 其他账号的数据不受影响
+	if _, err := s.GetAnnotation(ctx, "bob", "wxid_a", 1); err != nil {
+		t.Fatalf("GetAnnotation(bob) = %v", err)
+	}
+}
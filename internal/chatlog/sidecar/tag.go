@@ -0,0 +1,138 @@
+package sidecar
+
+import (
+	"context"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// Tag 会话标签及带有该标签的联系人与群聊
+type Tag struct {
+	Name    string   `json:"name"`
+	Talkers []string `json:"talkers"`
+}
+
+// Tags 列出所有标签，talker 不为空时只列出该会话的标签，按标签名称排序
+func (s *Store) Tags(ctx context.Context, account, talker string) ([]*Tag, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT tag, talker FROM tags WHERE account = ?"
+	args := []interface{}{account}
+	if talker != "" {
+		query = "SELECT tag, talker FROM tags WHERE account = ? AND tag IN (SELECT tag FROM tags WHERE account = ? AND talker = ?)"
+		args = append(args, account, talker)
+	}
+	rows, err := db.QueryContext(ctx, query+" ORDER BY tag, created_at, talker", args...)
+	if err != nil {
+		return nil, errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+
+	ret := make([]*Tag, 0)
+	for rows.Next() {
+		var name, id string
+		if err := rows.Scan(&name, &id); err != nil {
+			return nil, errors.ScanRowFailed(err)
+		}
+		if n := len(ret); n == 0 || ret[n-1].Name != name {
+			ret = append(ret, &Tag{Name: name})
+		}
+		last := ret[len(ret)-1]
+		last.Talkers = append(last.Talkers, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.QueryFailed(query, err)
+	}
+	return ret, nil
+}
+
+// TagTalkers 带有标签的会话，按添加顺序排列，标签不存在时返回空列表
+func (s *Store) TagTalkers(ctx context.Context, account, tag string) ([]string, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT talker FROM tags WHERE account = ? AND tag = ? ORDER BY created_at, talker"
+	rows, err := db.QueryContext(ctx, query, account, tag)
+	if err != nil {
+		return nil, errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+
+	ret := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.ScanRowFailed(err)
+		}
+		ret = append(ret, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.QueryFailed(query, err)
+	}
+	return ret, nil
+}
+
+// AddTag 为会话添加标签，replace 为 true 时替换标签原有的会话
+func (s *Store) AddTag(ctx context.Context, account, tag string, talkers []string, replace bool) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.QueryFailed("BEGIN", err)
+	}
+	defer tx.Rollback()
+
+	if replace {
+		query := "DELETE FROM tags WHERE account = ? AND tag = ?"
+		if _, err := tx.ExecContext(ctx, query, account, tag); err != nil {
+			return errors.QueryFailed(query, err)
+		}
+	}
+	now := time.Now().Unix()
+	query := "INSERT OR IGNORE INTO tags (account, tag, talker, created_at) VALUES (?, ?, ?, ?)"
+	for _, talker := range talkers {
+		if _, err := tx.ExecContext(ctx, query, account, tag, talker, now); err != nil {
+			return errors.QueryFailed(query, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.QueryFailed("COMMIT", err)
+	}
+	return nil
+}
+
+// RemoveTag 移除会话的标签，talkers 为空时删除整个标签，没有可移除的内容时返回 404 错误
+func (s *Store) RemoveTag(ctx context.Context, account, tag string, talkers ...string) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	var removed int64
+	if len(talkers) == 0 {
+		query := "DELETE FROM tags WHERE account = ? AND tag = ?"
+		res, err := db.ExecContext(ctx, query, account, tag)
+		if err != nil {
+			return errors.QueryFailed(query, err)
+		}
+		removed, _ = res.RowsAffected()
+	}
+	query := "DELETE FROM tags WHERE account = ? AND tag = ? AND talker = ?"
+	for _, talker := range talkers {
+		res, err := db.ExecContext(ctx, query, account, tag, talker)
+		if err != nil {
+			return errors.QueryFailed(query, err)
+		}
+		n, _ := res.RowsAffected()
+		removed += n
+	}
+	if removed == 0 {
+		return errors.TagNotFound(tag)
+	}
+	return nil
+}
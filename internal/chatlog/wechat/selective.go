@@ -0,0 +1,86 @@
+package wechat
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/wechatdb/datasource"
+	"github.com/sjzar/chatlog/pkg/filemonitor"
+)
+
+// requiredGroups 查询时必须存在的分组，选择性解密时总是解密
+var requiredGroups = []string{"contact", "chatroom"}
+
+// DecryptOptions 选择性解密的范围，零值表示解密全部数据库
+type DecryptOptions struct {
+	// Groups 只解密这些分组的数据库，如 message、session，分组名称见 datasource 各平台的 Groups；联系人与群聊总是解密
+	Groups []string
+
+	// Since 只解密在此时间之后修改过的消息分库，之前的分库不包含此后的消息；零值表示不限
+	Since time.Time
+}
+
+// selectDBFiles 按选项筛选数据目录中的数据库文件
+func (s *Service) selectDBFiles(dbFiles []string, opts DecryptOptions) ([]string, error) {
+	if len(opts.Groups) == 0 && opts.Since.IsZero() {
+		return dbFiles, nil
+	}
+
+	groups, err := datasource.Groups(s.ctx.Platform, s.ctx.Version)
+	if err != nil {
+		return nil, err
+	}
+	fgs := make(map[string]*filemonitor.FileGroup, len(groups))
+	names := make([]string, 0, len(groups))
+	for _, g := range groups {
+		fg, err := filemonitor.NewFileGroup(g.Name, s.ctx.DataDir, g.Pattern, g.BlackList)
+		if err != nil {
+			return nil, err
+		}
+		fgs[g.Name] = fg
+		names = append(names, g.Name)
+	}
+	selected := make(map[string]bool)
+	for _, name := range opts.Groups {
+		if _, ok := fgs[name]; !ok {
+			return nil, errors.Newf(nil, http.StatusBadRequest, "unknown database group: %s, available: %s", name, strings.Join(names, ","))
+		}
+		selected[name] = true
+	}
+	// 打开工作目录时需要读取联系人与群聊，只解密消息时同样解密这两个分组
+	if len(selected) > 0 {
+		for _, name := range requiredGroups {
+			if _, ok := fgs[name]; ok {
+				selected[name] = true
+			}
+		}
+	}
+
+	ret := make([]string, 0, len(dbFiles))
+	for _, dbFile := range dbFiles {
+		group := ""
+		for _, name := range names {
+			if fgs[name].Match(dbFile) {
+				group = name
+				break
+			}
+		}
+		// 不属于任何分组的数据库查询时不会读取，只在解密全部分组时解密
+		if len(selected) > 0 && !selected[group] {
+			continue
+		}
+		if group == datasource.MessageGroup && !opts.Since.IsZero() {
+			if info, err := os.Stat(dbFile); err == nil && info.ModTime().Before(opts.Since) {
+				log.Debug().Msgf("skip %s, not modified since %s", dbFile, opts.Since.Format(time.DateOnly))
+				continue
+			}
+		}
+		ret = append(ret, dbFile)
+	}
+	return ret, nil
+}
@@ -3,6 +3,7 @@ package wechat
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sync"
@@ -15,6 +16,7 @@ import (
 	"github.com/sjzar/chatlog/internal/errors"
 	"github.com/sjzar/chatlog/internal/wechat"
 	"github.com/sjzar/chatlog/internal/wechat/decrypt"
+	"github.com/sjzar/chatlog/pkg/filecrypt"
 	"github.com/sjzar/chatlog/pkg/filemonitor"
 	"github.com/sjzar/chatlog/pkg/util"
 )
@@ -30,6 +32,9 @@ type Service struct {
 	pendingActions map[string]bool
 	mutex          sync.Mutex
 	fm             *filemonitor.FileMonitor
+
+	// pages 每个数据库文件上次解密的页面状态，文件变化时只解密变化的页面
+	pages map[string]*decrypt.PageState
 }
 
 func NewService(ctx *ctx.Context) *Service {
@@ -37,6 +42,7 @@ func NewService(ctx *ctx.Context) *Service {
 		ctx:            ctx,
 		lastEvents:     make(map[string]time.Time),
 		pendingActions: make(map[string]bool),
+		pages:          make(map[string]*decrypt.PageState),
 	}
 }
 
@@ -73,9 +79,38 @@ func (s *Service) StartAutoDecrypt() error {
 		log.Debug().Err(err).Msg("failed to start file monitor")
 		return err
 	}
+
+	// 未监控期间微信写入的数据库文件在启动时补充解密
+	go s.decryptChanged(dbGroup)
 	return nil
 }
 
+// decryptChanged 解密比工作目录中副本更新的数据库文件
+func (s *Service) decryptChanged(dbGroup *filemonitor.FileGroup) {
+	dbFiles, err := dbGroup.List()
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to list db files")
+		return
+	}
+	for _, dbFile := range dbFiles {
+		src, err := os.Stat(dbFile)
+		if err != nil {
+			continue
+		}
+		if dst, err := os.Stat(s.outputPath(dbFile)); err == nil && !src.ModTime().After(dst.ModTime()) {
+			continue
+		}
+		if err := s.DecryptDBFile(dbFile); err != nil {
+			log.Debug().Msgf("DecryptDBFile %s failed: %v", dbFile, err)
+		}
+	}
+}
+
+// outputPath 数据库文件在工作目录中对应的解密副本路径
+func (s *Service) outputPath(dbFile string) string {
+	return filepath.Join(s.ctx.WorkDir, dbFile[len(s.ctx.DataDir):])
+}
+
 func (s *Service) StopAutoDecrypt() error {
 	if s.fm != nil {
 		if err := s.fm.Stop(); err != nil {
@@ -127,14 +162,22 @@ func (s *Service) waitAndProcess(dbFile string) {
 	}
 }
 
-func (s *Service) DecryptDBFile(dbFile string) error {
+// DecryptDBFile 解密数据库文件到工作目录，先写入临时文件，成功后替换原副本，正在提供的查询不受影响
+// 同一文件再次解密时只解密发生变化的页面，其余页面从原副本中复制（工作目录加密时无法按页读取，只复用派生的密钥）
+func (s *Service) DecryptDBFile(dbFile string) (err error) {
 
 	decryptor, err := decrypt.NewDecryptor(s.ctx.Platform, s.ctx.Version)
 	if err != nil {
 		return err
 	}
 
-	output := filepath.Join(s.ctx.WorkDir, dbFile[len(s.ctx.DataDir):])
+	// 工作目录启用加密时，解密后的数据库再以口令加密写入
+	workKey, err := filecrypt.DirKey(s.ctx.WorkDir, s.ctx.WorkKey)
+	if err != nil {
+		return err
+	}
+
+	output := s.outputPath(dbFile)
 	if err := util.PrepareDir(filepath.Dir(output)); err != nil {
 		return err
 	}
@@ -145,16 +188,57 @@ func (s *Service) DecryptDBFile(dbFile string) error {
 		return fmt.Errorf("failed to create output file: %v", err)
 	}
 	defer func() {
-		outputFile.Close()
+		if cerr := outputFile.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close output file: %v", cerr)
+		}
+		// 解密或写入失败时保留原副本，避免替换为不完整的数据库，下次重新完整解密
+		if err != nil {
+			s.mutex.Lock()
+			delete(s.pages, dbFile)
+			s.mutex.Unlock()
+			os.Remove(outputTemp)
+			return
+		}
 		if err := os.Rename(outputTemp, output); err != nil {
 			log.Debug().Err(err).Msgf("failed to rename %s to %s", outputTemp, output)
 		}
 	}()
 
-	if err := decryptor.Decrypt(context.Background(), dbFile, s.ctx.DataKey, outputFile); err != nil {
+	var w io.Writer = outputFile
+	if workKey != nil {
+		enc, encErr := filecrypt.NewWriter(outputFile, workKey)
+		if encErr != nil {
+			return encErr
+		}
+		// Close 写入最后一块密文，失败时临时文件不完整，不能替换原副本
+		defer func() {
+			if cerr := enc.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}()
+		w = enc
+	}
+
+	s.mutex.Lock()
+	prev := s.pages[dbFile]
+	s.mutex.Unlock()
+
+	var previous io.ReaderAt
+	if workKey == nil && prev != nil {
+		if f, err := os.Open(output); err == nil {
+			defer f.Close()
+			previous = f
+		}
+	}
+
+	state, decrypted, err := decrypt.DecryptIncremental(context.Background(), decryptor, dbFile, s.ctx.DataKey, prev, previous, w)
+	if err != nil {
 		if err == errors.ErrAlreadyDecrypted {
+			s.mutex.Lock()
+			delete(s.pages, dbFile)
+			s.mutex.Unlock()
 			if data, err := os.ReadFile(dbFile); err == nil {
-				outputFile.Write(data)
+				w.Write(data)
 			}
 			return nil
 		}
@@ -162,12 +246,21 @@ func (s *Service) DecryptDBFile(dbFile string) error {
 		return err
 	}
 
-	log.Debug().Msgf("Decrypted %s to %s", dbFile, output)
+	s.mutex.Lock()
+	s.pages[dbFile] = state
+	s.mutex.Unlock()
+
+	log.Debug().Msgf("Decrypted %s to %s, %d/%d pages changed", dbFile, output, decrypted, state.Pages())
 
 	return nil
 }
 
 func (s *Service) DecryptDBFiles() error {
+	return s.DecryptSelectedDBFiles(DecryptOptions{})
+}
+
+// DecryptSelectedDBFiles 只解密选定的数据库，选项为空时解密全部数据库
+func (s *Service) DecryptSelectedDBFiles(opts DecryptOptions) error {
 	dbGroup, err := filemonitor.NewFileGroup("wechat", s.ctx.DataDir, `.*\.db$`, []string{"fts"})
 	if err != nil {
 		return err
@@ -178,6 +271,10 @@ func (s *Service) DecryptDBFiles() error {
 		return err
 	}
 
+	if dbFiles, err = s.selectDBFiles(dbFiles, opts); err != nil {
+		return err
+	}
+
 	for _, dbFile := range dbFiles {
 		if err := s.DecryptDBFile(dbFile); err != nil {
 			log.Debug().Msgf("DecryptDBFile %s failed: %v", dbFile, err)
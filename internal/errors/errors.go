@@ -1,6 +1,7 @@
 package errors
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -111,6 +112,10 @@ func RootCause(err error) error {
 }
 
 func Err(c *gin.Context, err error) {
+	// 查询超时的错误可能被包装在查询错误中，统一按超时响应
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = QueryTimeout(err)
+	}
 	if appErr, ok := err.(*Error); ok {
 		c.JSON(appErr.Code, appErr.Error())
 		return
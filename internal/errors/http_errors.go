@@ -6,6 +6,18 @@ func InvalidArg(arg string) error {
 	return Newf(nil, http.StatusBadRequest, "invalid argument: %s", arg)
 }
 
+func RateLimited() error {
+	return New(nil, http.StatusTooManyRequests, "rate limit exceeded")
+}
+
+func ReadOnly() error {
+	return New(nil, http.StatusForbidden, "disabled in read-only mode")
+}
+
+func Locked() error {
+	return New(nil, http.StatusLocked, "service locked, unlock with passphrase")
+}
+
 func HTTPShutDown(cause error) error {
 	return Newf(cause, http.StatusInternalServerError, "http server shut down")
 }
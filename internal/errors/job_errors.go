@@ -0,0 +1,19 @@
+package errors
+
+import "net/http"
+
+func JobNotFound(id string) *Error {
+	return Newf(nil, http.StatusNotFound, "job not found: %s", id).WithStack()
+}
+
+func JobTypeUnsupported(_type string) *Error {
+	return Newf(nil, http.StatusBadRequest, "unsupported job type: %s", _type).WithStack()
+}
+
+func JobFinished(id string) *Error {
+	return Newf(nil, http.StatusConflict, "job already finished: %s", id).WithStack()
+}
+
+func JobPersistFailed(cause error) *Error {
+	return New(cause, http.StatusInternalServerError, "persist job failed").WithStack()
+}
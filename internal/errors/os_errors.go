@@ -21,3 +21,7 @@ func IncompleteRead(cause error) *Error {
 func WriteOutputFailed(cause error) *Error {
 	return New(cause, http.StatusInternalServerError, "failed to write output").WithStack()
 }
+
+func WriteFileFailed(path string, cause error) *Error {
+	return Newf(cause, http.StatusInternalServerError, "failed to write file: %s", path).WithStack()
+}
@@ -0,0 +1,27 @@
+package errors
+
+import "net/http"
+
+func BookmarkNotFound(talker string, seq int64) *Error {
+	return Newf(nil, http.StatusNotFound, "bookmark not found: %s %d", talker, seq).WithStack()
+}
+
+func AnnotationNotFound(talker string, seq int64) *Error {
+	return Newf(nil, http.StatusNotFound, "annotation not found: %s %d", talker, seq).WithStack()
+}
+
+func TagNotFound(tag string) *Error {
+	return Newf(nil, http.StatusNotFound, "tag not found: %s", tag).WithStack()
+}
+
+func DisplayNameNotFound(talker string) *Error {
+	return Newf(nil, http.StatusNotFound, "display name not found: %s", talker).WithStack()
+}
+
+func IdentityNotFound(id string) *Error {
+	return Newf(nil, http.StatusNotFound, "identity not found: %s", id).WithStack()
+}
+
+func SidecarUnavailable(cause error) *Error {
+	return New(cause, http.StatusServiceUnavailable, "sidecar store unavailable").WithStack()
+}
@@ -14,6 +14,10 @@ var (
 	ErrValidatorNotSet               = New(nil, http.StatusBadRequest, "validator not set")
 	ErrNoValidKey                    = New(nil, http.StatusBadRequest, "no valid key found")
 	ErrWeChatDLLNotFound             = New(nil, http.StatusBadRequest, "WeChatWin.dll module not found")
+	ErrBackupEncrypted               = New(nil, http.StatusBadRequest, "encrypted iOS backup requires the backup password")
+	ErrBackupPassword                = New(nil, http.StatusBadRequest, "incorrect iOS backup password")
+	ErrBackupWeChatNotFound          = New(nil, http.StatusBadRequest, "WeChat data not found in backup")
+	ErrInvalidMinidump               = New(nil, http.StatusBadRequest, "invalid minidump file")
 )
 
 func PlatformUnsupported(platform string, version int) *Error {
@@ -63,3 +67,7 @@ func WeChatAccountNotOnline(name string) *Error {
 func RefreshProcessStatusFailed(cause error) *Error {
 	return New(cause, http.StatusInternalServerError, "failed to refresh process status").WithStack()
 }
+
+func BackupAccountNotFound(account string) *Error {
+	return Newf(nil, http.StatusBadRequest, "account not found in backup: %s", account).WithStack()
+}
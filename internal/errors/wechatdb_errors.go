@@ -10,6 +10,12 @@ var (
 	ErrKeyEmpty        = New(nil, http.StatusBadRequest, "key empty").WithStack()
 	ErrMediaNotFound   = New(nil, http.StatusNotFound, "media not found").WithStack()
 	ErrKeyLengthMust32 = New(nil, http.StatusBadRequest, "key length must be 32 bytes").WithStack()
+	ErrDBClosed        = New(nil, http.StatusServiceUnavailable, "db closed").WithStack()
+
+	ErrWorkDirEncrypted = New(nil, http.StatusConflict, "encrypted work dir cannot be modified").WithStack()
+
+	// ErrIterStop 由逐条读取的回调返回，表示提前结束读取，不作为错误向上传递
+	ErrIterStop = New(nil, http.StatusOK, "iteration stopped")
 )
 
 // 数据库初始化相关错误
@@ -25,6 +31,10 @@ func DBInitFailed(cause error) *Error {
 	return New(cause, http.StatusInternalServerError, "db init failed").WithStack()
 }
 
+func QueryTimeout(cause error) *Error {
+	return New(cause, http.StatusGatewayTimeout, "query timeout")
+}
+
 func TalkerNotFound(talker string) *Error {
 	return Newf(nil, http.StatusNotFound, "talker not found: %s", talker).WithStack()
 }
@@ -57,6 +67,10 @@ func ContactNotFound(key string) *Error {
 	return Newf(nil, http.StatusNotFound, "contact not found: %s", key).WithStack()
 }
 
+func MessageNotFound(talker string, seq int64) *Error {
+	return Newf(nil, http.StatusNotFound, "message not found: %s %d", talker, seq).WithStack()
+}
+
 func InitCacheFailed(cause error) *Error {
 	return New(cause, http.StatusInternalServerError, "init cache failed").WithStack()
 }
@@ -73,6 +73,6 @@ type ServerInfo struct {
 var DefaultCapabilities = M{
 	"experimental": M{},
 	"prompts":      M{"listChanged": false},
-	"resources":    M{"subscribe": false, "listChanged": false},
+	"resources":    M{"subscribe": true, "listChanged": false},
 	"tools":        M{"listChanged": false},
 }
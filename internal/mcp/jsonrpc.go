@@ -1,5 +1,7 @@
 package mcp
 
+import "encoding/json"
+
 const (
 	JsonRPCVersion = "2.0"
 )
@@ -48,6 +50,18 @@ func NewResponse(id interface{}, result interface{}) *Response {
 	}
 }
 
+// message 客户端发来的消息，可能是请求、通知，也可能是对服务端请求（如 sampling）的响应
+type message struct {
+	Request
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// isResponse 响应没有 method，但有对应请求的 id
+func (m *message) isResponse() bool {
+	return m.Method == "" && m.ID != nil
+}
+
 // Notifications
 //
 //	{
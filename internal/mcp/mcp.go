@@ -19,6 +19,9 @@ type MCP struct {
 	sessionMu sync.Mutex
 
 	ProcessChan chan ProcessCtx
+
+	// BasePath 服务的路径前缀，SSE 会话告知客户端的消息地址带上该前缀
+	BasePath string
 }
 
 func NewMCP() *MCP {
@@ -29,19 +32,13 @@ func NewMCP() *MCP {
 }
 
 func (m *MCP) HandleSSE(c *gin.Context) {
-	id := uuid.New().String()
-	m.sessionMu.Lock()
-	m.sessions[id] = NewSession(c, id)
-	m.sessionMu.Unlock()
+	remove := m.addSession(NewSession(c, uuid.New().String(), m.BasePath+"/message"))
+	defer remove()
 
 	c.Stream(func(w io.Writer) bool {
 		<-c.Request.Context().Done()
 		return false
 	})
-
-	m.sessionMu.Lock()
-	delete(m.sessions, id)
-	m.sessionMu.Unlock()
 }
 
 func (m *MCP) GetSession(id string) *Session {
@@ -50,6 +47,17 @@ func (m *MCP) GetSession(id string) *Session {
 	return m.sessions[id]
 }
 
+// Sessions 当前连接的所有会话
+func (m *MCP) Sessions() []*Session {
+	m.sessionMu.Lock()
+	defer m.sessionMu.Unlock()
+	ret := make([]*Session, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		ret = append(ret, session)
+	}
+	return ret
+}
+
 func (m *MCP) HandleMessages(c *gin.Context) {
 
 	// panic("xxx")
@@ -78,12 +86,18 @@ func (m *MCP) HandleMessages(c *gin.Context) {
 		return
 	}
 
-	var req Request
-	if err := c.ShouldBindJSON(&req); err != nil {
+	var msg message
+	if err := c.ShouldBindJSON(&msg); err != nil {
 		c.JSON(http.StatusBadRequest, ErrInvalidRequest.JsonRPC())
 		c.Abort()
 		return
 	}
+	if msg.isResponse() {
+		session.receive(&msg)
+		c.String(http.StatusAccepted, "Accepted")
+		return
+	}
+	req := msg.Request
 
 	log.Debug().Msgf("session: %s, request: %s", sessionID, req)
 	select {
@@ -97,6 +111,18 @@ func (m *MCP) HandleMessages(c *gin.Context) {
 	c.String(http.StatusAccepted, "Accepted")
 }
 
+// addSession 注册会话，返回注销函数
+func (m *MCP) addSession(session *Session) func() {
+	m.sessionMu.Lock()
+	m.sessions[session.id] = session
+	m.sessionMu.Unlock()
+	return func() {
+		m.sessionMu.Lock()
+		delete(m.sessions, session.id)
+		m.sessionMu.Unlock()
+	}
+}
+
 func (m *MCP) Close() {
 	close(m.ProcessChan)
 }
@@ -66,6 +66,27 @@ type ResourcesReadRequest struct {
 	URI string `json:"uri"`
 }
 
+// ResourcesListRequest 分页列出资源，cursor 为上一页返回的 nextCursor
+type ResourcesListRequest struct {
+	Cursor string `json:"cursor"`
+}
+
+// ResourcesListResponse 资源列表，还有下一页时返回 nextCursor
+type ResourcesListResponse struct {
+	Resources  []Resource `json:"resources"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+// ResourcesSubscribeRequest 订阅或取消订阅资源更新
+type ResourcesSubscribeRequest struct {
+	URI string `json:"uri"`
+}
+
+// ResourceUpdatedParams 资源更新通知，客户端收到后重新读取资源
+type ResourceUpdatedParams struct {
+	URI string `json:"uri"`
+}
+
 type ReadingResourceContent struct {
 	URI      string `json:"uri"`
 	MimeType string `json:"mimeType,omitempty"`
@@ -0,0 +1,55 @@
+package mcp
+
+// Document: https://modelcontextprotocol.io/docs/concepts/sampling
+
+const (
+	// Server => Client
+	MethodSamplingCreateMessage = "sampling/createMessage"
+)
+
+// SamplingMessage 发送给客户端大模型的一条消息
+type SamplingMessage struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+//	{
+//		"method": "sampling/createMessage",
+//		"params": {
+//		  "messages": [
+//			{
+//			  "role": "user",
+//			  "content": {
+//				"type": "text",
+//				"text": "What files are in the current directory?"
+//			  }
+//			}
+//		  ],
+//		  "systemPrompt": "You are a helpful file system assistant.",
+//		  "includeContext": "thisServer",
+//		  "maxTokens": 100
+//		}
+//	  }
+type CreateMessageRequest struct {
+	Messages       []SamplingMessage `json:"messages"`
+	SystemPrompt   string            `json:"systemPrompt,omitempty"`
+	IncludeContext string            `json:"includeContext,omitempty"`
+	Temperature    float64           `json:"temperature,omitempty"`
+	MaxTokens      int               `json:"maxTokens"`
+}
+
+//	{
+//		"model": "gpt-4o",
+//		"stopReason": "endTurn",
+//		"role": "assistant",
+//		"content": {
+//		  "type": "text",
+//		  "text": "..."
+//		}
+//	  }
+type CreateMessageResult struct {
+	Model      string  `json:"model"`
+	StopReason string  `json:"stopReason,omitempty"`
+	Role       string  `json:"role"`
+	Content    Content `json:"content"`
+}
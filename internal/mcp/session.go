@@ -1,26 +1,70 @@
 package mcp
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
 )
 
 type Session struct {
-	id string
-	w  io.Writer
-	c  *ClientInfo
+	id   string
+	w    io.Writer
+	c    *ClientInfo
+	caps M
+
+	// ctx SSE 连接断开时结束，用于取消等待中的客户端响应
+	ctx context.Context
+
+	// 请求处理与资源更新通知可能同时写入
+	writeMu sync.Mutex
+
+	// 已订阅更新的资源 URI
+	subMu sync.Mutex
+	subs  map[string]bool
+
+	// 服务端发往客户端、等待响应的请求
+	seq       atomic.Int64
+	pendingMu sync.Mutex
+	pending   map[string]chan *clientResponse
+}
+
+// clientResponse 客户端对服务端请求的响应
+type clientResponse struct {
+	Result json.RawMessage
+	Error  *Error
 }
 
-func NewSession(c *gin.Context, id string) *Session {
+func NewSession(c *gin.Context, id string, endpoint string) *Session {
 	return &Session{
-		id: id,
-		w:  NewSSEWriter(c, id),
+		id:      id,
+		w:       NewSSEWriter(c, id, endpoint),
+		ctx:     c.Request.Context(),
+		subs:    make(map[string]bool),
+		pending: make(map[string]chan *clientResponse),
+	}
+}
+
+// NewStdioSession 标准输入输出上的会话，ctx 结束表示连接断开
+func NewStdioSession(ctx context.Context, id string, w io.Writer) *Session {
+	return &Session{
+		id:      id,
+		w:       &lineWriter{w: w},
+		ctx:     ctx,
+		subs:    make(map[string]bool),
+		pending: make(map[string]chan *clientResponse),
 	}
 }
 
 func (s *Session) Write(p []byte) (n int, err error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
 	return s.w.Write(p)
 }
 
@@ -46,3 +90,102 @@ func (s *Session) WriteResponse(req *Request, data interface{}) error {
 func (s *Session) SaveClientInfo(c *ClientInfo) {
 	s.c = c
 }
+
+// SaveClientCapabilities 保存客户端在初始化时声明的能力
+func (s *Session) SaveClientCapabilities(caps M) {
+	s.caps = caps
+}
+
+// SupportsSampling 客户端是否支持由服务端发起的大模型调用
+func (s *Session) SupportsSampling() bool {
+	_, ok := s.caps["sampling"]
+	return ok
+}
+
+// Context 会话的上下文，连接断开时结束，用于取消会话中进行的查询
+func (s *Session) Context() context.Context {
+	return s.ctx
+}
+
+// Call 向客户端发送请求并等待响应，ctx 结束或连接断开时返回错误
+func (s *Session) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	id := fmt.Sprintf("chatlog-%d", s.seq.Add(1))
+	ch := make(chan *clientResponse, 1)
+	s.pendingMu.Lock()
+	s.pending[id] = ch
+	s.pendingMu.Unlock()
+	defer func() {
+		s.pendingMu.Lock()
+		delete(s.pending, id)
+		s.pendingMu.Unlock()
+	}()
+
+	b, err := json.Marshal(Request{JsonRPC: JsonRPCVersion, ID: id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	s.Write(b)
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.ctx.Done():
+		return fmt.Errorf("session %s closed", s.id)
+	}
+}
+
+// receive 将客户端的响应交给等待中的 Call
+func (s *Session) receive(msg *message) {
+	s.pendingMu.Lock()
+	ch, ok := s.pending[fmt.Sprint(msg.ID)]
+	s.pendingMu.Unlock()
+	if !ok {
+		log.Debug().Msgf("session: %s, unexpected response: %v", s.id, msg.ID)
+		return
+	}
+	ch <- &clientResponse{Result: msg.Result, Error: msg.Error}
+}
+
+// WriteNotification 发送通知，通知没有 ID，客户端无需响应
+func (s *Session) WriteNotification(method string, params interface{}) error {
+	b, err := json.Marshal(Notification{JsonRPC: JsonRPCVersion, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	s.Write(b)
+	return nil
+}
+
+// Subscribe 订阅资源更新
+func (s *Session) Subscribe(uri string) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	s.subs[uri] = true
+}
+
+// Unsubscribe 取消订阅资源更新
+func (s *Session) Unsubscribe(uri string) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	delete(s.subs, uri)
+}
+
+// Subscriptions 已订阅的资源 URI
+func (s *Session) Subscriptions() []string {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	ret := make([]string, 0, len(s.subs))
+	for uri := range s.subs {
+		ret = append(ret, uri)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -14,11 +14,12 @@ const (
 )
 
 type SSEWriter struct {
-	id string
-	c  *gin.Context
+	id       string
+	endpoint string
+	c        *gin.Context
 }
 
-func NewSSEWriter(c *gin.Context, id string) *SSEWriter {
+func NewSSEWriter(c *gin.Context, id string, endpoint string) *SSEWriter {
 	c.Writer.Header().Set("Content-Type", SSEContentType)
 	c.Writer.Header().Set("Cache-Control", "no-cache")
 	c.Writer.Header().Set("Connection", "keep-alive")
@@ -26,8 +27,9 @@ func NewSSEWriter(c *gin.Context, id string) *SSEWriter {
 	c.Writer.Flush()
 
 	w := &SSEWriter{
-		id: id,
-		c:  c,
+		id:       id,
+		endpoint: endpoint,
+		c:        c,
 	}
 	w.WriteEndpoing()
 	go w.ping()
@@ -65,7 +67,7 @@ func (w *SSEWriter) ping() {
 // data: /message?sessionId=285d67ee-1c17-40d9-ab03-173d5ff48419
 func (w *SSEWriter) WriteEndpoing() {
 	w.c.Writer.WriteString(fmt.Sprintf("event: endpoint\n"))
-	w.c.Writer.WriteString(fmt.Sprintf("data: /message?sessionId=%s\n\n", w.id))
+	w.c.Writer.WriteString(fmt.Sprintf("data: %s?sessionId=%s\n\n", w.endpoint, w.id))
 	w.c.Writer.Flush()
 }
 
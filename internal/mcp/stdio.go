@@ -1 +1,63 @@
 package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/google/uuid"
+)
+
+// StdioMaxMessageSize 标准输入中单条消息的最大字节数
+const StdioMaxMessageSize = 16 * 1024 * 1024
+
+// lineWriter 每条消息写为一行，stdio 传输以换行分隔消息
+type lineWriter struct {
+	w io.Writer
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	if _, err := w.w.Write(append(p, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ServeStdio 在标准输入输出上提供 MCP 服务，每行一条 JSON-RPC 消息，输入结束时返回
+// 用于由桌面客户端作为子进程启动，不需要 HTTP 服务
+func (m *MCP) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	session := NewStdioSession(ctx, uuid.New().String(), w)
+	remove := m.addSession(session)
+	defer remove()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), StdioMaxMessageSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			if b, err := json.Marshal(ErrParseError.JsonRPC()); err == nil {
+				session.Write(b)
+			}
+			continue
+		}
+		if msg.isResponse() {
+			session.receive(&msg)
+			continue
+		}
+		req := msg.Request
+		select {
+		case m.ProcessChan <- ProcessCtx{Session: session, Request: &req}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
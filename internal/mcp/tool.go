@@ -1,5 +1,7 @@
 package mcp
 
+import "encoding/json"
+
 // Document: https://modelcontextprotocol.io/docs/concepts/tools
 
 const (
@@ -137,7 +139,27 @@ type ToolsCallResponse struct {
 	IsError bool      `json:"isError"`
 }
 
+// Content 工具返回的内容，type 为 text、image 或 resource
+// image 的 data 为 base64 编码的图片，resource 用于返回音频等其他二进制内容
 type Content struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type     string                  `json:"type"`
+	Text     string                  `json:"text"`
+	Data     string                  `json:"data,omitempty"`
+	MimeType string                  `json:"mimeType,omitempty"`
+	Resource *ReadingResourceContent `json:"resource,omitempty"`
+}
+
+// MarshalJSON 文本内容始终包含 text 字段，其他类型的内容不包含
+func (c Content) MarshalJSON() ([]byte, error) {
+	type content Content
+	if c.Type == "text" {
+		return json.Marshal(struct {
+			content
+			Text string `json:"text"`
+		}{content(c), c.Text})
+	}
+	return json.Marshal(struct {
+		content
+		Text string `json:"text,omitempty"`
+	}{content: content(c)})
 }
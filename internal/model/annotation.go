@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// Annotation 用户为单条消息添加的私人备注与标签，单独保存，不修改聊天记录
+type Annotation struct {
+	Talker    string    `json:"talker"`
+	Seq       int64     `json:"seq"`
+	Note      string    `json:"note"`
+	Tags      []string  `json:"tags"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
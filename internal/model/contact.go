@@ -6,6 +6,7 @@ type Contact struct {
 	Remark   string `json:"remark"`
 	NickName string `json:"nickName"`
 	IsFriend bool   `json:"isFriend"`
+	Avatar   string `json:"avatar,omitempty"` // 头像地址
 }
 
 // CREATE TABLE Contact(
@@ -47,6 +48,8 @@ type ContactV3 struct {
 	Remark    string `json:"Remark"`
 	NickName  string `json:"NickName"`
 	Reserved1 int    `json:"Reserved1"` // 1 自己好友或自己加入的群聊; 0 群聊成员(非好友)
+
+	SmallHeadImgUrl string `json:"SmallHeadImgUrl"`
 }
 
 func (c *ContactV3) Wrap() *Contact {
@@ -56,6 +59,7 @@ func (c *ContactV3) Wrap() *Contact {
 		Remark:   c.Remark,
 		NickName: c.NickName,
 		IsFriend: c.Reserved1 == 1,
+		Avatar:   c.SmallHeadImgUrl,
 	}
 }
 
@@ -40,6 +40,8 @@ type ContactDarwinV3 struct {
 	M_nsRemark    string `json:"m_nsRemark"`
 	M_uiSex       int    `json:"m_uiSex"`
 	M_nsAliasName string `json:"m_nsAliasName"`
+
+	M_nsHeadImgUrl string `json:"m_nsHeadImgUrl"`
 }
 
 func (c *ContactDarwinV3) Wrap() *Contact {
@@ -49,5 +51,6 @@ func (c *ContactDarwinV3) Wrap() *Contact {
 		Remark:   c.M_nsRemark,
 		NickName: c.Nickname,
 		IsFriend: true,
+		Avatar:   c.M_nsHeadImgUrl,
 	}
 }
@@ -30,6 +30,8 @@ type ContactV4 struct {
 	Remark    string `json:"remark"`
 	NickName  string `json:"nick_name"`
 	LocalType int    `json:"local_type"` // 2 群聊; 3 群聊成员(非好友); 5,6 企业微信;
+
+	SmallHeadURL string `json:"small_head_url"`
 }
 
 func (c *ContactV4) Wrap() *Contact {
@@ -39,5 +41,6 @@ func (c *ContactV4) Wrap() *Contact {
 		Remark:   c.Remark,
 		NickName: c.NickName,
 		IsFriend: c.LocalType != 3,
+		Avatar:   c.SmallHeadURL,
 	}
 }
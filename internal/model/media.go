@@ -12,6 +12,7 @@ type Media struct {
 	Size       int64  `json:"size"`
 	Data       []byte `json:"data"` // for voice
 	ModifyTime int64  `json:"modifyTime"`
+	Account    string `json:"account,omitempty"` // 文件所在账号，合并多个账号查询时返回
 }
 
 type MediaV3 struct {
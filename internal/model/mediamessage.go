@@ -162,18 +162,19 @@ type RecordXML struct {
 	RecordInfo RecordInfo `xml:"recordinfo,omitempty"`
 }
 
-func (r *RecordInfo) String(title, host string) string {
+// String 合并转发内容的纯文本，label 为消息类型名称，如“合并转发”
+func (r *RecordInfo) String(label, title, host string) string {
 	buf := strings.Builder{}
 	if title == "" {
 		title = r.Title
 	}
-	buf.WriteString(fmt.Sprintf("[合并转发|%s]\n", title))
+	buf.WriteString(fmt.Sprintf("[%s|%s]\n", label, title))
 	for _, item := range r.DataList.DataItems {
 		buf.WriteString(fmt.Sprintf("  %s %s\n", item.SourceName, item.SourceTime))
 
 		// 套娃合并转发
 		if item.DataType == "17" && item.RecordXML != nil {
-			content := item.RecordXML.RecordInfo.String(item.DataTitle, host)
+			content := item.RecordXML.RecordInfo.String(label, item.DataTitle, host)
 			if content != "" {
 				for _, line := range strings.Split(content, "\n") {
 					buf.WriteString(fmt.Sprintf("  %s\n", line))
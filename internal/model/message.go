@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/sjzar/chatlog/pkg/i18n"
 	"github.com/sjzar/chatlog/pkg/util"
 )
 
@@ -18,19 +19,24 @@ const (
 )
 
 type Message struct {
-	Version    string                 `json:"-"`                  // 消息版本，内部判断
-	Seq        int64                  `json:"seq"`                // 消息序号，10位时间戳 + 3位序号
-	Time       time.Time              `json:"time"`               // 消息创建时间，10位时间戳
-	Talker     string                 `json:"talker"`             // 聊天对象，微信 ID or 群 ID
-	TalkerName string                 `json:"talkerName"`         // 聊天对象名称
-	IsChatRoom bool                   `json:"isChatRoom"`         // 是否为群聊消息
-	Sender     string                 `json:"sender"`             // 发送人，微信 ID
-	SenderName string                 `json:"senderName"`         // 发送人名称
-	IsSelf     bool                   `json:"isSelf"`             // 是否为自己发送的消息
-	Type       int64                  `json:"type"`               // 消息类型
-	SubType    int64                  `json:"subType"`            // 消息子类型
-	Content    string                 `json:"content"`            // 消息内容，文字聊天内容
-	Contents   map[string]interface{} `json:"contents,omitempty"` // 消息内容，多媒体消息，采用更灵活的记录方式
+	Version    string                 `json:"-"`                    // 消息版本，内部判断
+	Seq        int64                  `json:"seq"`                  // 消息序号，10位时间戳 + 3位序号
+	Time       time.Time              `json:"time"`                 // 消息创建时间，10位时间戳
+	Talker     string                 `json:"talker"`               // 聊天对象，微信 ID or 群 ID
+	TalkerName string                 `json:"talkerName"`           // 聊天对象名称
+	IsChatRoom bool                   `json:"isChatRoom"`           // 是否为群聊消息
+	Sender     string                 `json:"sender"`               // 发送人，微信 ID
+	SenderName string                 `json:"senderName"`           // 发送人名称
+	IsSelf     bool                   `json:"isSelf"`               // 是否为自己发送的消息
+	Type       int64                  `json:"type"`                 // 消息类型
+	SubType    int64                  `json:"subType"`              // 消息子类型
+	Content    string                 `json:"content"`              // 消息内容，文字聊天内容
+	Contents   map[string]interface{} `json:"contents,omitempty"`   // 消息内容，多媒体消息，采用更灵活的记录方式
+	MediaURL   string                 `json:"mediaUrl,omitempty"`   // 多媒体内容地址
+	ThumbURL   string                 `json:"thumbUrl,omitempty"`   // 缩略图地址
+	MediaData  string                 `json:"mediaData,omitempty"`  // 内嵌的多媒体内容，data URI 格式
+	Account    string                 `json:"account,omitempty"`    // 来源账号，合并多个账号查询时返回
+	Annotation *Annotation            `json:"annotation,omitempty"` // 备注与标签，include_annotations=1 时返回
 
 	// Debug Info
 	MediaMsg *MediaMsg `json:"mediaMsg,omitempty"` // 原始多媒体消息，XML 格式
@@ -188,6 +194,85 @@ func (m *Message) SetContent(key string, value interface{}) {
 	m.Contents[key] = value
 }
 
+// SharedURLs 返回消息中分享的链接，包括文本消息中的链接和分享类消息（链接、小程序、视频号）的地址
+func (m *Message) SharedURLs() []string {
+	switch m.Type {
+	case 1:
+		return util.ExtractURLs(m.Content)
+	case 49:
+		switch m.SubType {
+		case 5, 33, 36, 51:
+			if url, ok := m.Contents["url"].(string); ok && url != "" {
+				return []string{url}
+			}
+		case 57:
+			return util.ExtractURLs(m.Content)
+		}
+	}
+	return nil
+}
+
+// IsMedia 是否为多媒体消息（图片、语音、视频、表情、文件）
+func (m *Message) IsMedia() bool {
+	switch m.Type {
+	case 3, 34, 43, 47:
+		return true
+	case 49:
+		return m.SubType == 6 || m.SubType == 8
+	}
+	return false
+}
+
+// MediaKeys 返回多媒体消息的媒体类型与可用于 /image、/video、/voice、/file 接口的 key 列表
+func (m *Message) MediaKeys() (string, []string) {
+	var _type string
+	var fields []string
+	switch {
+	case m.Type == 3:
+		_type, fields = "image", []string{"md5", "imgfile", "thumb"}
+	case m.Type == 34:
+		_type, fields = "voice", []string{"voice"}
+	case m.Type == 43:
+		_type, fields = "video", []string{"md5", "rawmd5", "videofile", "thumb"}
+	case m.Type == 49 && m.SubType == 6:
+		_type, fields = "file", []string{"md5"}
+	default:
+		return "", nil
+	}
+
+	keys := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if key, ok := m.Contents[field].(string); ok && key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return _type, keys
+}
+
+// SetMediaURLs 根据多媒体 key 生成内容与缩略图地址，prefix 为服务地址，如 http://127.0.0.1:5030
+func (m *Message) SetMediaURLs(prefix string) {
+	_type, keys := m.MediaKeys()
+	if _type == "" || len(keys) == 0 {
+		return
+	}
+
+	thumb, _ := m.Contents["thumb"].(string)
+	primary := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key != thumb {
+			primary = append(primary, key)
+		}
+	}
+	if len(primary) == 0 {
+		primary = keys
+	}
+
+	m.MediaURL = prefix + "/" + _type + "/" + strings.Join(primary, ",")
+	if thumb != "" {
+		m.ThumbURL = prefix + "/image/" + thumb
+	}
+}
+
 func (m *Message) PlainText(showChatRoom bool, timeFormat string, host string) string {
 
 	if timeFormat == "" {
@@ -200,7 +285,7 @@ func (m *Message) PlainText(showChatRoom bool, timeFormat string, host string) s
 
 	sender := m.Sender
 	if m.IsSelf {
-		sender = "我"
+		sender = i18n.T(m.lang(), "msg.me")
 	}
 	if m.SenderName != "" {
 		buf.WriteString(m.SenderName)
@@ -235,6 +320,7 @@ func (m *Message) PlainText(showChatRoom bool, timeFormat string, host string) s
 }
 
 func (m *Message) PlainTextContent() string {
+	lang := m.lang()
 	switch m.Type {
 	case 1:
 		return m.Content
@@ -255,14 +341,14 @@ func (m *Message) PlainTextContent() string {
 				keylist = append(keylist, thumb)
 			}
 		}
-		return fmt.Sprintf("![图片](http://%s/image/%s)", m.Contents["host"], strings.Join(keylist, ","))
+		return fmt.Sprintf("![%s](http://%s/image/%s)", i18n.T(lang, "msg.image"), m.Contents["host"], strings.Join(keylist, ","))
 	case 34:
 		if voice, ok := m.Contents["voice"]; ok {
-			return fmt.Sprintf("[语音](http://%s/voice/%s)", m.Contents["host"], voice)
+			return fmt.Sprintf("[%s](http://%s/voice/%s)", i18n.T(lang, "msg.voice"), m.Contents["host"], voice)
 		}
-		return "[语音]"
+		return "[" + i18n.T(lang, "msg.voice") + "]"
 	case 42:
-		return "[名片]"
+		return "[" + i18n.T(lang, "msg.card") + "]"
 	case 43:
 		keylist := make([]string, 0)
 		if m.Contents["md5"] != nil {
@@ -285,62 +371,65 @@ func (m *Message) PlainTextContent() string {
 				keylist = append(keylist, thumb)
 			}
 		}
-		return fmt.Sprintf("![视频](http://%s/video/%s)", m.Contents["host"], strings.Join(keylist, ","))
+		return fmt.Sprintf("![%s](http://%s/video/%s)", i18n.T(lang, "msg.video"), m.Contents["host"], strings.Join(keylist, ","))
 	case 47:
-		return "[动画表情]"
+		return "[" + i18n.T(lang, "msg.emoji") + "]"
 	case 49:
 		switch m.SubType {
 		case 5:
-			return fmt.Sprintf("[链接|%s](%s)", m.Contents["title"], m.Contents["url"])
+			return fmt.Sprintf("[%s|%s](%s)", i18n.T(lang, "msg.link"), m.Contents["title"], m.Contents["url"])
 		case 6:
-			return fmt.Sprintf("[文件|%s](http://%s/file/%s)", m.Contents["title"], m.Contents["host"], m.Contents["md5"])
+			return fmt.Sprintf("[%s|%s](http://%s/file/%s)", i18n.T(lang, "msg.file"), m.Contents["title"], m.Contents["host"], m.Contents["md5"])
 		case 8:
-			return "[GIF表情]"
+			return "[" + i18n.T(lang, "msg.gif") + "]"
 		case 19:
 			_recordInfo, ok := m.Contents["recordInfo"]
 			if !ok {
-				return "[合并转发]"
+				return "[" + i18n.T(lang, "msg.forward") + "]"
 			}
 			recordInfo, ok := _recordInfo.(*RecordInfo)
 			if !ok {
-				return "[合并转发]"
+				return "[" + i18n.T(lang, "msg.forward") + "]"
 			}
 			host := ""
 			if m.Contents["host"] != nil {
 				host = m.Contents["host"].(string)
 			}
-			return recordInfo.String("", host)
+			return recordInfo.String(i18n.T(lang, "msg.forward"), "", host)
 		case 33, 36:
 			if m.Contents["title"] == "" {
-				return "[小程序]"
+				return "[" + i18n.T(lang, "msg.miniapp") + "]"
 			}
-			return fmt.Sprintf("[小程序|%s](%s)", m.Contents["title"], m.Contents["url"])
+			return fmt.Sprintf("[%s|%s](%s)", i18n.T(lang, "msg.miniapp"), m.Contents["title"], m.Contents["url"])
 		case 51:
 			if m.Contents["title"] == "" {
-				return "[视频号]"
+				return "[" + i18n.T(lang, "msg.channels") + "]"
 			} else {
-				return fmt.Sprintf("[视频号|%s](%s)", m.Contents["title"], m.Contents["url"])
+				return fmt.Sprintf("[%s|%s](%s)", i18n.T(lang, "msg.channels"), m.Contents["title"], m.Contents["url"])
 			}
 		case 57:
 			_refer, ok := m.Contents["refer"]
 			if !ok {
 				if m.Content == "" {
-					return "[引用]"
+					return "[" + i18n.T(lang, "msg.quote") + "]"
 				}
-				return "> [引用]\n" + m.Content
+				return "> [" + i18n.T(lang, "msg.quote") + "]\n" + m.Content
 			}
 			refer, ok := _refer.(*Message)
 			if !ok {
 				if m.Content == "" {
-					return "[引用]"
+					return "[" + i18n.T(lang, "msg.quote") + "]"
 				}
-				return "> [引用]\n" + m.Content
+				return "> [" + i18n.T(lang, "msg.quote") + "]\n" + m.Content
 			}
 			buf := strings.Builder{}
 			host := ""
 			if m.Contents["host"] != nil {
 				host = m.Contents["host"].(string)
 			}
+			if lang != "" {
+				refer.SetContent("lang", lang)
+			}
 			referContent := refer.PlainText(false, "", host)
 			for _, line := range strings.Split(referContent, "\n") {
 				if line == "" {
@@ -355,20 +444,20 @@ func (m *Message) PlainTextContent() string {
 		case 62:
 			return m.Content
 		case 63:
-			return "[视频号]"
+			return "[" + i18n.T(lang, "msg.channels") + "]"
 		case 87:
-			return "[群公告]"
+			return "[" + i18n.T(lang, "msg.announcement") + "]"
 		case 2000:
 			return m.Content
 		case 2001:
-			return "[红包]"
+			return "[" + i18n.T(lang, "msg.red_packet") + "]"
 		case 2003:
-			return "[红包封面]"
+			return "[" + i18n.T(lang, "msg.red_packet_skin") + "]"
 		default:
-			return "[分享]"
+			return "[" + i18n.T(lang, "msg.share") + "]"
 		}
 	case 50:
-		return "[语音通话]"
+		return "[" + i18n.T(lang, "msg.voip") + "]"
 	case 10000:
 		return m.Content
 	default:
@@ -379,3 +468,9 @@ func (m *Message) PlainTextContent() string {
 		return fmt.Sprintf("Type: %d Content: %s", m.Type, content)
 	}
 }
+
+// lang 纯文本输出使用的语言，由调用方通过 SetContent("lang", ...) 指定，未指定时使用中文
+func (m *Message) lang() string {
+	lang, _ := m.Contents["lang"].(string)
+	return lang
+}
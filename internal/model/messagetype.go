@@ -0,0 +1,93 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MessageType 消息类型过滤条件，SubType 为 0 时匹配该类型的所有子类型
+type MessageType struct {
+	Type    int64
+	SubType int64
+}
+
+// MessageTypeNames 消息类型名称，用于接口的 type 参数
+var MessageTypeNames = map[string][]MessageType{
+	"text":     {{Type: 1}},
+	"image":    {{Type: 3}},
+	"voice":    {{Type: 34}},
+	"card":     {{Type: 42}},
+	"video":    {{Type: 43}},
+	"emoji":    {{Type: 47}, {Type: 49, SubType: 8}},
+	"location": {{Type: 48}},
+	"appmsg":   {{Type: 49}},
+	"link":     {{Type: 49, SubType: 5}},
+	"file":     {{Type: 49, SubType: 6}},
+	"forward":  {{Type: 49, SubType: 19}},
+	"miniapp":  {{Type: 49, SubType: 33}, {Type: 49, SubType: 36}},
+	"channels": {{Type: 49, SubType: 51}},
+	"quote":    {{Type: 49, SubType: 57}},
+	"pat":      {{Type: 49, SubType: 62}},
+	"transfer": {{Type: 49, SubType: 2000}},
+	"voip":     {{Type: 50}},
+	"system":   {{Type: 10000}, {Type: 10002}},
+}
+
+// ParseMessageTypes 解析以英文逗号分隔的消息类型，支持类型名称（如 image、file）与数字形式（如 3、49:6）
+func ParseMessageTypes(str string) ([]MessageType, error) {
+	types := make([]MessageType, 0)
+	for _, item := range strings.Split(str, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		if named, ok := MessageTypeNames[item]; ok {
+			types = append(types, named...)
+			continue
+		}
+
+		var t MessageType
+		_type, subType, hasSub := strings.Cut(item, ":")
+		v, err := strconv.ParseInt(_type, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unknown message type: %s", item)
+		}
+		t.Type = v
+		if hasSub {
+			v, err := strconv.ParseInt(subType, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unknown message type: %s", item)
+			}
+			t.SubType = v
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// BaseTypes 返回去重后的主类型，用于数据库查询条件
+func BaseTypes(types []MessageType) []int64 {
+	seen := make(map[int64]bool)
+	ret := make([]int64, 0, len(types))
+	for _, t := range types {
+		if !seen[t.Type] {
+			seen[t.Type] = true
+			ret = append(ret, t.Type)
+		}
+	}
+	return ret
+}
+
+// MatchTypes 判断消息是否属于给定类型，types 为空时总是匹配
+func (m *Message) MatchTypes(types []MessageType) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if m.Type == t.Type && (t.SubType == 0 || m.SubType == t.SubType) {
+			return true
+		}
+	}
+	return false
+}
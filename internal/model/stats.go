@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// HourStat 会话中单个发送人在一小时内的消息数量，系统消息等没有发送人的消息 Sender 为空
+type HourStat struct {
+	Talker   string    `json:"talker"`
+	Sender   string    `json:"sender"`
+	Hour     time.Time `json:"hour"` // 本地时间的整点
+	Messages int       `json:"messages"`
+	Texts    int       `json:"texts"`
+	Media    int       `json:"media"`
+}
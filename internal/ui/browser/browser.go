@@ -0,0 +1,516 @@
+package browser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/internal/ui/style"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	Title     = "browser"
+	ShowTitle = "聊天记录"
+
+	// PageSize 每次加载的消息数量
+	PageSize = 100
+
+	// PreviewHeight 消息预览区域的高度
+	PreviewHeight = 8
+
+	// SearchLimit 搜索结果的最大数量，只显示最近的匹配
+	SearchLimit = 200
+
+	// SearchDelay 输入停止后开始搜索的等待时间，避免每输入一个字符都查询一次
+	SearchDelay = 300 * time.Millisecond
+
+	timeFormat = "2006-01-02 15:04:05"
+
+	keyHint = "[yellow]Enter[white] 打开  [yellow]Tab[white] 切换列表  [yellow]/[white] 搜索  [yellow]Esc[white] 返回会话  [yellow]d[white] 跳转到日期  [yellow]r[white] 刷新会话"
+)
+
+// Source 聊天记录浏览器的数据来源
+type Source interface {
+	// Sessions 返回最近会话列表
+	Sessions() ([]*model.Session, error)
+
+	// Messages 返回会话在 [start, end] 内的消息，desc 为 true 时返回最近的 limit 条，结果均按时间升序
+	Messages(talker string, start, end time.Time, desc bool, limit int) ([]*model.Message, error)
+
+	// Context 返回消息前后各若干条消息，按时间升序
+	Context(talker string, seq int64, before, after int) ([]*model.Message, error)
+
+	// Search 在全部会话中搜索包含关键词的消息，返回最近的 limit 条，按时间倒序
+	Search(keyword string, limit int) ([]*model.Message, error)
+
+	// MediaPath 返回多媒体消息在数据目录中的文件路径，没有文件时返回空
+	MediaPath(msg *model.Message) string
+}
+
+// Browser 会话列表、消息列表与消息预览
+// 消息按页加载，选中第一条时加载更早的消息，选中最后一条且之后还有消息时加载更新的消息
+// 在搜索框中输入时消息列表显示搜索结果，打开搜索结果后显示该消息所在的对话
+type Browser struct {
+	*tview.Flex
+
+	app    *tview.Application
+	source Source
+
+	sessions *tview.Table
+	search   *tview.InputField
+	messages *tview.Table
+	preview  *tview.TextView
+	status   *tview.TextView
+
+	active   tview.Primitive
+	names    map[string]string
+	talker   string
+	items    []*model.Message
+	hasOlder bool
+	hasNewer bool
+	loading  bool
+
+	// 搜索结果，searching 为 true 时消息列表显示搜索结果
+	hits      []*model.Message
+	searching bool
+	searchSeq int
+
+	// OnJump 按下 d 时调用，由调用方输入日期后调用 JumpTo
+	OnJump func()
+}
+
+// New 创建聊天记录浏览器，数据在后台加载，完成后通过 app 更新界面
+func New(app *tview.Application, source Source) *Browser {
+	b := &Browser{
+		Flex:     tview.NewFlex(),
+		app:      app,
+		source:   source,
+		sessions: tview.NewTable(),
+		search:   tview.NewInputField(),
+		messages: tview.NewTable(),
+		preview:  tview.NewTextView(),
+		status:   tview.NewTextView(),
+		names:    make(map[string]string),
+	}
+	b.active = b.sessions
+
+	b.sessions.SetSelectable(true, false)
+	b.sessions.SetBorder(true)
+	b.sessions.SetBorderColor(style.BorderColor)
+	b.sessions.SetTitle("会话")
+	b.sessions.SetSelectedFunc(func(row, column int) {
+		if talker, ok := b.sessions.GetCell(row, 0).GetReference().(string); ok {
+			b.open(talker)
+		}
+	})
+
+	b.search.SetLabel("搜索: ")
+	b.search.SetFieldBackgroundColor(style.InputFieldBgColor)
+	b.search.SetChangedFunc(b.searchChanged)
+	b.search.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEscape:
+			b.closeSearch()
+		case tcell.KeyEnter, tcell.KeyTab:
+			if b.searching && len(b.hits) > 0 {
+				b.focus(b.messages)
+			}
+		}
+	})
+
+	b.messages.SetSelectable(true, false)
+	b.messages.SetBorder(true)
+	b.messages.SetBorderColor(style.BorderColor)
+	b.messages.SetTitle("消息")
+	b.messages.SetSelectionChangedFunc(func(row, column int) {
+		b.showPreview(row)
+		if !b.searching {
+			b.loadMore(row)
+		}
+	})
+	b.messages.SetSelectedFunc(func(row, column int) {
+		if b.searching && row >= 0 && row < len(b.hits) {
+			b.openHit(b.hits[row])
+		}
+	})
+
+	b.preview.SetDynamicColors(false)
+	b.preview.SetWrap(true)
+	b.preview.SetBorder(true)
+	b.preview.SetBorderColor(style.BorderColor)
+	b.preview.SetTitle("预览")
+
+	b.status.SetDynamicColors(true)
+	b.status.SetText(keyHint)
+
+	right := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(b.search, 1, 0, false).
+		AddItem(b.messages, 0, 1, false).
+		AddItem(b.preview, PreviewHeight, 0, false)
+	body := tview.NewFlex().
+		AddItem(b.sessions, 0, 1, true).
+		AddItem(right, 0, 3, false)
+	b.Flex.SetDirection(tview.FlexRow).
+		AddItem(body, 0, 1, true).
+		AddItem(b.status, 1, 0, false)
+
+	b.Flex.SetInputCapture(b.inputCapture)
+
+	return b
+}
+
+func (b *Browser) inputCapture(event *tcell.EventKey) *tcell.EventKey {
+	// 搜索框中的按键由搜索框处理
+	if b.Typing() {
+		return event
+	}
+	switch event.Key() {
+	case tcell.KeyTab, tcell.KeyBacktab:
+		b.toggleFocus()
+		return nil
+	case tcell.KeyEscape:
+		if b.searching {
+			b.closeSearch()
+			return nil
+		}
+	case tcell.KeyRune:
+		switch event.Rune() {
+		case '/':
+			b.focus(b.search)
+			return nil
+		case 'd':
+			if b.talker != "" && b.OnJump != nil {
+				b.OnJump()
+			}
+			return nil
+		case 'r':
+			b.Refresh()
+			return nil
+		}
+	}
+	return event
+}
+
+// Typing 焦点是否在搜索框中，此时方向键与字母键由搜索框处理
+func (b *Browser) Typing() bool {
+	return b.search.HasFocus()
+}
+
+func (b *Browser) toggleFocus() {
+	if b.active == b.sessions {
+		b.focus(b.messages)
+		return
+	}
+	b.focus(b.sessions)
+}
+
+func (b *Browser) focus(p tview.Primitive) {
+	b.active = p
+	b.app.SetFocus(p)
+}
+
+// Focus 切换到浏览器时恢复上次的焦点（会话列表、搜索框或消息列表）
+func (b *Browser) Focus(delegate func(p tview.Primitive)) {
+	delegate(b.active)
+}
+
+// Load 首次显示时加载会话列表
+func (b *Browser) Load() {
+	if b.sessions.GetRowCount() == 0 {
+		b.Refresh()
+	}
+}
+
+// Refresh 重新加载会话列表
+func (b *Browser) Refresh() {
+	b.setStatus("加载会话中...")
+	go func() {
+		sessions, err := b.source.Sessions()
+		b.app.QueueUpdateDraw(func() {
+			if err != nil {
+				b.setStatus("加载会话失败: " + err.Error())
+				return
+			}
+			b.sessions.Clear()
+			for i, session := range sessions {
+				name := session.NickName
+				if name == "" {
+					name = session.UserName
+				}
+				b.names[session.UserName] = name
+				b.sessions.SetCell(i, 0, tview.NewTableCell(tview.Escape(name)).
+					SetTextColor(style.FgColor).
+					SetReference(session.UserName).
+					SetExpansion(1))
+			}
+			b.sessions.Select(0, 0)
+			b.setStatus(fmt.Sprintf("共 %d 个会话", len(sessions)))
+		})
+	}()
+}
+
+// open 打开会话，加载最近的一页消息
+func (b *Browser) open(talker string) {
+	b.load(func() ([]*model.Message, error) {
+		return b.source.Messages(talker, time.Time{}, time.Now(), true, PageSize)
+	}, func(msgs []*model.Message) {
+		b.closeResults()
+		b.talker = talker
+		b.hasOlder = len(msgs) == PageSize
+		b.hasNewer = false
+		b.setItems(msgs, len(msgs)-1)
+		b.focus(b.messages)
+	})
+}
+
+// JumpTo 跳转到会话中 t 之后的第一条消息
+func (b *Browser) JumpTo(t time.Time) {
+	if b.talker == "" {
+		return
+	}
+	talker := b.talker
+	b.load(func() ([]*model.Message, error) {
+		return b.source.Messages(talker, t, time.Now(), false, PageSize)
+	}, func(msgs []*model.Message) {
+		if len(msgs) == 0 {
+			b.setStatus(t.Format("2006-01-02") + " 之后没有消息")
+			return
+		}
+		b.closeResults()
+		b.hasOlder = true
+		b.hasNewer = len(msgs) == PageSize
+		b.setItems(msgs, 0)
+		b.focus(b.messages)
+	})
+}
+
+// loadMore 选中第一条或最后一条消息时加载更早或更新的一页消息
+func (b *Browser) loadMore(row int) {
+	if b.loading || len(b.items) == 0 {
+		return
+	}
+	talker := b.talker
+	switch {
+	case row == 0 && b.hasOlder:
+		first := b.items[0]
+		b.load(func() ([]*model.Message, error) {
+			return b.source.Messages(talker, time.Time{}, first.Time, true, PageSize)
+		}, func(msgs []*model.Message) {
+			msgs = exclude(msgs, b.items)
+			b.hasOlder = len(msgs) > 0
+			b.setItems(append(msgs, b.items...), len(msgs))
+		})
+	case row == len(b.items)-1 && b.hasNewer:
+		last := b.items[len(b.items)-1]
+		b.load(func() ([]*model.Message, error) {
+			return b.source.Messages(talker, last.Time, time.Now(), false, PageSize)
+		}, func(msgs []*model.Message) {
+			msgs = exclude(msgs, b.items)
+			b.hasNewer = len(msgs) > 0
+			b.setItems(append(b.items, msgs...), len(b.items)-1)
+		})
+	}
+}
+
+// load 在后台加载消息，完成后在界面线程中调用 done
+func (b *Browser) load(fetch func() ([]*model.Message, error), done func([]*model.Message)) {
+	b.loading = true
+	b.setStatus("加载消息中...")
+	go func() {
+		msgs, err := fetch()
+		b.app.QueueUpdateDraw(func() {
+			b.loading = false
+			if err != nil {
+				b.setStatus("加载消息失败: " + err.Error())
+				return
+			}
+			done(msgs)
+		})
+	}()
+}
+
+// searchChanged 输入停止 SearchDelay 后搜索，期间再次输入则放弃之前的搜索
+func (b *Browser) searchChanged(text string) {
+	b.searchSeq++
+	seq := b.searchSeq
+	keyword := strings.TrimSpace(text)
+	if keyword == "" {
+		if b.searching {
+			b.closeResults()
+			b.setItems(b.items, len(b.items)-1)
+		}
+		return
+	}
+	time.AfterFunc(SearchDelay, func() {
+		b.app.QueueUpdateDraw(func() {
+			if seq != b.searchSeq {
+				return
+			}
+			b.setStatus("搜索中...")
+			go func() {
+				hits, err := b.source.Search(keyword, SearchLimit)
+				b.app.QueueUpdateDraw(func() {
+					if seq != b.searchSeq {
+						return
+					}
+					if err != nil {
+						b.setStatus("搜索失败: " + err.Error())
+						return
+					}
+					b.showHits(keyword, hits)
+				})
+			}()
+		})
+	})
+}
+
+// showHits 在消息列表中显示搜索结果，最近的匹配在最前
+func (b *Browser) showHits(keyword string, hits []*model.Message) {
+	b.searching = true
+	b.hits = hits
+	b.messages.SetTitle("搜索结果")
+	b.render(hits, true)
+	if len(hits) == 0 {
+		b.preview.Clear()
+		b.setStatus(fmt.Sprintf("没有找到 %q", keyword))
+		return
+	}
+	b.messages.Select(0, 0)
+	b.showPreview(0)
+	b.setStatus(fmt.Sprintf("找到 %d 条 %q", len(hits), keyword))
+}
+
+// closeSearch 清空搜索框，消息列表恢复为当前会话
+func (b *Browser) closeSearch() {
+	wasSearching := b.searching
+	b.closeResults()
+	b.search.SetText("")
+	if wasSearching {
+		b.setItems(b.items, len(b.items)-1)
+	}
+	b.focus(b.messages)
+}
+
+// closeResults 退出搜索结果，丢弃尚未完成的搜索
+func (b *Browser) closeResults() {
+	b.searchSeq++
+	b.searching = false
+	b.hits = nil
+	b.messages.SetTitle("消息")
+}
+
+// openHit 打开搜索结果所在的对话，加载前后各半页消息并选中该消息
+func (b *Browser) openHit(hit *model.Message) {
+	b.load(func() ([]*model.Message, error) {
+		return b.source.Context(hit.Talker, hit.Seq, PageSize/2, PageSize/2)
+	}, func(msgs []*model.Message) {
+		anchor := 0
+		for i, msg := range msgs {
+			if msg.Seq == hit.Seq {
+				anchor = i
+				break
+			}
+		}
+		b.closeResults()
+		b.talker = hit.Talker
+		b.hasOlder = anchor >= PageSize/2
+		b.hasNewer = len(msgs)-anchor-1 >= PageSize/2
+		b.setItems(msgs, anchor)
+		b.focus(b.messages)
+	})
+}
+
+// exclude 去掉已加载的消息，按时间边界加载时边界上的消息会重复返回
+func exclude(msgs []*model.Message, loaded []*model.Message) []*model.Message {
+	seen := make(map[int64]bool, len(loaded))
+	for _, msg := range loaded {
+		seen[msg.Seq] = true
+	}
+	ret := make([]*model.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		if !seen[msg.Seq] {
+			ret = append(ret, msg)
+		}
+	}
+	return ret
+}
+
+func (b *Browser) setItems(items []*model.Message, selected int) {
+	b.items = items
+	b.render(items, false)
+	if len(items) == 0 {
+		b.preview.Clear()
+		b.setStatus("没有消息")
+		return
+	}
+
+	// 选中时会触发加载，先标记为加载中，避免跳转后立即加载相邻页
+	b.loading = true
+	b.messages.Select(max(selected, 0), 0)
+	b.loading = false
+	b.showPreview(selected)
+	name := b.talker
+	if b.names[b.talker] != "" {
+		name = b.names[b.talker]
+	}
+	b.setStatus(fmt.Sprintf("%s  已加载 %d 条消息  %s ~ %s", name, len(items),
+		items[0].Time.Format("2006-01-02"), items[len(items)-1].Time.Format("2006-01-02")))
+}
+
+// render 每条消息一行，搜索结果另外显示所在会话
+func (b *Browser) render(msgs []*model.Message, withTalker bool) {
+	b.messages.Clear()
+	for i, msg := range msgs {
+		sender := msg.SenderName
+		if sender == "" {
+			sender = msg.Sender
+		}
+		if msg.IsSelf {
+			sender = "我"
+		}
+		content := strings.Join(strings.Fields(msg.PlainTextContent()), " ")
+		col := 0
+		b.messages.SetCell(i, col, tview.NewTableCell(msg.Time.Format(timeFormat)).SetTextColor(style.InfoBarItemFgColor))
+		col++
+		if withTalker {
+			talker := b.names[msg.Talker]
+			if talker == "" {
+				talker = msg.Talker
+			}
+			b.messages.SetCell(i, col, tview.NewTableCell(tview.Escape(talker)).SetTextColor(style.PausedStatusFgColor).SetMaxWidth(16))
+			col++
+		}
+		b.messages.SetCell(i, col, tview.NewTableCell(tview.Escape(sender)).SetTextColor(style.HelpHeaderFgColor).SetMaxWidth(16))
+		col++
+		b.messages.SetCell(i, col, tview.NewTableCell(tview.Escape(content)).SetTextColor(style.FgColor).SetExpansion(1))
+	}
+}
+
+// showPreview 显示选中消息的完整内容，多媒体消息显示文件路径
+func (b *Browser) showPreview(row int) {
+	msgs := b.items
+	if b.searching {
+		msgs = b.hits
+	}
+	if row < 0 || row >= len(msgs) {
+		return
+	}
+	msg := msgs[row]
+	text := msg.PlainText(true, timeFormat, "")
+	if msg.IsMedia() {
+		if path := b.source.MediaPath(msg); path != "" {
+			text += "文件: " + path + "\n"
+		}
+	}
+	b.preview.SetText(text)
+	b.preview.ScrollToBeginning()
+}
+
+func (b *Browser) setStatus(text string) {
+	b.status.SetText(tview.Escape(text) + "  " + keyHint)
+}
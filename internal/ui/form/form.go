@@ -119,6 +119,22 @@ func (f *Form) AddInputField(label, value string, fieldWidth int, accept func(te
 	return f
 }
 
+// AddPasswordField adds a password field to the form, input is masked with '*'.
+func (f *Form) AddPasswordField(label, value string, fieldWidth int, changed func(text string)) *Form {
+	f.fields = append(f.fields, formField{
+		label:      label,
+		value:      value,
+		fieldWidth: fieldWidth,
+	})
+
+	f.form.AddPasswordField(label, value, fieldWidth, '*', changed)
+
+	// 更新表单尺寸
+	f.recalculateSize()
+
+	return f
+}
+
 // AddButton adds a button to the form.
 func (f *Form) AddButton(label string, selected func()) *Form {
 	f.form.AddButton(label, selected)
@@ -14,7 +14,7 @@ const (
 	Content   = `[yellow]Chatlog 使用指南[white]
 
 [green]基本操作:[white]
-• 使用 [yellow]←→[white] 键在主菜单和帮助页面之间切换
+• 使用 [yellow]←→[white] 键在主菜单、聊天记录和帮助页面之间切换
 • 使用 [yellow]↑↓[white] 键在菜单项之间移动
 • 按 [yellow]Enter[white] 选择菜单项
 • 按 [yellow]Esc[white] 返回上一级菜单
@@ -42,6 +42,13 @@ const (
    • HTTP 服务端口 - 更改 HTTP 服务的监听端口
    • 工作目录 - 更改解密数据的存储位置
 
+[green]浏览聊天记录:[white]
+解密数据后，切换到"聊天记录"页面即可在终端中浏览，无需启动 HTTP 服务。
+• 在会话列表中按 [yellow]Enter[white] 打开会话，滚动到顶部或底部时自动加载更多消息
+• 按 [yellow]Tab[white] 在会话与消息列表之间切换，预览区显示完整内容与多媒体文件路径
+• 按 [yellow]d[white] 输入日期跳转，按 [yellow]r[white] 刷新会话列表
+• 按 [yellow]/[white] 搜索全部会话，输入时自动显示结果，在结果上按 [yellow]Enter[white] 跳转到对话，按 [yellow]Esc[white] 返回
+
 [green]HTTP API 使用:[white]
 • 聊天记录: [yellow]GET http://localhost:5030/api/v1/chatlog?time=2023-01-01&talker=wxid_xxx[white]
 • 联系人列表: [yellow]GET http://localhost:5030/api/v1/contact[white]
@@ -0,0 +1,103 @@
+package android
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/pbkdf2"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/wechat/decrypt/common"
+)
+
+// EnMicroMsg.db 使用 SQLCipher 1.x 的默认参数：页大小 1024，PBKDF2-HMAC-SHA1 迭代 4000 次，每页末尾 16 字节为 IV，没有 HMAC
+const (
+	PageSize  = 1024
+	IterCount = 4000
+	Reserve   = common.IVSize
+)
+
+// DefaultIMEI 新版本微信不再读取设备 IMEI，统一使用该值
+const DefaultIMEI = "1234567890ABCDEF"
+
+// Key 数据库口令为 MD5(IMEI + uin) 的前 7 位小写十六进制字符
+func Key(imei, uin string) string {
+	sum := md5.Sum([]byte(imei + uin))
+	return hex.EncodeToString(sum[:])[:7]
+}
+
+// DecryptDB 解密 EnMicroMsg.db，口令错误时返回 ErrDecryptIncorrectKey
+func DecryptDB(ctx context.Context, dbfile string, key string, output io.Writer) error {
+	f, err := os.Open(dbfile)
+	if err != nil {
+		return errors.OpenFileFailed(dbfile, err)
+	}
+	defer f.Close()
+
+	page := make([]byte, PageSize)
+	if _, err := io.ReadFull(f, page); err != nil {
+		return errors.ReadFileFailed(dbfile, err)
+	}
+	if bytes.HasPrefix(page, []byte(common.SQLiteHeader)) {
+		return errors.ErrAlreadyDecrypted
+	}
+
+	encKey := pbkdf2.Key([]byte(key), page[:common.SaltSize], IterCount, common.KeySize, sha1.New)
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return errors.DecryptCreateCipherFailed(err)
+	}
+
+	for pageNum := int64(0); ; pageNum++ {
+		select {
+		case <-ctx.Done():
+			return errors.ErrDecryptOperationCanceled
+		default:
+		}
+
+		data := decryptPage(block, page, pageNum)
+		if pageNum == 0 {
+			data = append([]byte(common.SQLiteHeader), data...)
+			if !validPage1(data) {
+				return errors.ErrDecryptIncorrectKey
+			}
+		}
+		if _, err := output.Write(data); err != nil {
+			return errors.WriteOutputFailed(err)
+		}
+
+		// 与其他平台一致，忽略末尾不完整的页面
+		if _, err := io.ReadFull(f, page); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return errors.ReadFileFailed(dbfile, err)
+		}
+	}
+}
+
+// decryptPage 第一页的前 16 字节为盐值，解密结果不含盐值，保留区原样写回
+func decryptPage(block cipher.Block, page []byte, pageNum int64) []byte {
+	offset := 0
+	if pageNum == 0 {
+		offset = common.SaltSize
+	}
+	iv := page[PageSize-Reserve:]
+	data := make([]byte, PageSize-Reserve-offset)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, page[offset:PageSize-Reserve])
+	return append(data, page[PageSize-Reserve:]...)
+}
+
+// validPage1 没有 HMAC 可用于校验口令，以解密后文件头中的页大小与固定的负载比例判断
+func validPage1(page []byte) bool {
+	return len(page) > 23 &&
+		page[16] == PageSize>>8 && page[17] == PageSize&0xff &&
+		page[20] == Reserve && page[21] == 64 && page[22] == 32 && page[23] == 32
+}
@@ -0,0 +1,108 @@
+package android
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/wechat/decrypt/common"
+)
+
+func TestKey(t *testing.T) {
+	if got := Key(DefaultIMEI, "1234567"); got != "77bf751" {
+		t.Errorf("Key() = %q, want %q", got, "77bf751")
+	}
+}
+
+// encryptDB 按 SQLCipher 1.x 的格式加密明文页面，第一页以盐值代替文件头
+func encryptDB(t *testing.T, key string, pages [][]byte) string {
+	salt := bytes.Repeat([]byte{0x5a}, common.SaltSize)
+	block, err := aes.NewCipher(pbkdf2.Key([]byte(key), salt, IterCount, common.KeySize, sha1.New))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	for i, page := range pages {
+		offset := 0
+		if i == 0 {
+			offset = common.SaltSize
+			buf.Write(salt)
+		}
+		iv := page[PageSize-Reserve:]
+		enc := make([]byte, PageSize-Reserve-offset)
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(enc, page[offset:PageSize-Reserve])
+		buf.Write(enc)
+		buf.Write(iv)
+	}
+	path := filepath.Join(t.TempDir(), "EnMicroMsg.db")
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDecryptDB(t *testing.T) {
+	page1 := make([]byte, PageSize)
+	copy(page1, common.SQLiteHeader)
+	page1[16], page1[17] = PageSize>>8, PageSize&0xff
+	page1[20], page1[21], page1[22], page1[23] = Reserve, 64, 32, 32
+	copy(page1[100:], "CREATE TABLE message")
+	copy(page1[PageSize-Reserve:], bytes.Repeat([]byte{1}, Reserve))
+	page2 := bytes.Repeat([]byte{0x42}, PageSize)
+
+	key := Key(DefaultIMEI, "1234567")
+	dbfile := encryptDB(t, key, [][]byte{page1, page2})
+
+	var out bytes.Buffer
+	if err := DecryptDB(context.Background(), dbfile, key, &out); err != nil {
+		t.Fatalf("DecryptDB() error: %v", err)
+	}
+	if want := append(append([]byte{}, page1...), page2...); !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("DecryptDB() output does not match the plain pages")
+	}
+
+	// 口令错误时第一页的文件头校验失败
+	out.Reset()
+	if err := DecryptDB(context.Background(), dbfile, "0000000", &out); err != errors.ErrDecryptIncorrectKey {
+		t.Errorf("DecryptDB(wrong key) error = %v, want ErrDecryptIncorrectKey", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("DecryptDB(wrong key) wrote %d bytes", out.Len())
+	}
+
+	// 已解密的数据库
+	plain := filepath.Join(t.TempDir(), "plain.db")
+	if err := os.WriteFile(plain, page1, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptDB(context.Background(), plain, key, &out); err != errors.ErrAlreadyDecrypted {
+		t.Errorf("DecryptDB(plain) error = %v, want ErrAlreadyDecrypted", err)
+	}
+}
+
+func TestValidPage1(t *testing.T) {
+	page := make([]byte, 24)
+	page[16], page[17] = PageSize>>8, PageSize&0xff
+	page[20], page[21], page[22], page[23] = Reserve, 64, 32, 32
+	if !validPage1(page) {
+		t.Errorf("validPage1() = false for a valid header")
+	}
+	for _, i := range []int{16, 17, 20, 21, 22, 23} {
+		bad := append([]byte{}, page...)
+		bad[i]++
+		if validPage1(bad) {
+			t.Errorf("validPage1() = true with byte %d changed", i)
+		}
+	}
+	if validPage1(page[:23]) {
+		t.Errorf("validPage1() = true for a short page")
+	}
+}
@@ -0,0 +1,243 @@
+package android
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/wechat/importer"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+type androidImporter struct {
+	ctx      context.Context
+	mediaDir string
+	w        *importer.Writer
+
+	// 解密后的 EnMicroMsg.db
+	src string
+}
+
+// Import 解密 EnMicroMsg.db 并将聊天数据导入工作目录，key 为数据库口令（见 Key）
+// mediaDir 为手机存储中的 MicroMsg/<账号目录>，包含 image2 与 video 目录，为空时只导入数据库
+func Import(ctx context.Context, dbfile string, key string, mediaDir string, workDir string, workKey []byte) (*importer.Report, error) {
+	w, err := importer.NewWriter(ctx, workDir, workKey)
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+
+	src := filepath.Join(w.Temp(), "source", "EnMicroMsg.db")
+	if err := decryptTo(ctx, dbfile, key, src); err != nil {
+		return nil, err
+	}
+
+	im := &androidImporter{
+		ctx:      ctx,
+		mediaDir: mediaDir,
+		w:        w,
+		src:      src,
+	}
+	if err := im.importContacts(); err != nil {
+		return nil, err
+	}
+	if err := im.importMessages(); err != nil {
+		return nil, err
+	}
+	if err := w.Install("source"); err != nil {
+		return nil, err
+	}
+	return w.Report, nil
+}
+
+func decryptTo(ctx context.Context, dbfile, key, dst string) error {
+	if err := util.PrepareDir(filepath.Dir(dst)); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return errors.OpenFileFailed(dst, err)
+	}
+	defer out.Close()
+	return DecryptDB(ctx, dbfile, key, out)
+}
+
+// importContacts 从 rcontact 表导入联系人，群聊的成员列表与群昵称来自 chatroom 表
+func (im *androidImporter) importContacts() error {
+	db, err := sql.Open("sqlite3", im.src)
+	if err != nil {
+		return errors.DBConnectFailed(im.src, err)
+	}
+	defer db.Close()
+
+	query := `SELECT r.username, IFNULL(r.nickname, ''), IFNULL(r.conRemark, ''), IFNULL(r.alias, ''),
+		IFNULL(c.memberlist, ''), IFNULL(c.displayname, '')
+		FROM rcontact r LEFT JOIN chatroom c ON c.chatroomname = r.username`
+	rows, err := db.QueryContext(im.ctx, query)
+	if err != nil {
+		return errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userName, nickName, remark, alias, members, displayNames string
+		if err := rows.Scan(&userName, &nickName, &remark, &alias, &members, &displayNames); err != nil {
+			return errors.ScanRowFailed(err)
+		}
+		if userName == "" {
+			continue
+		}
+		if strings.HasSuffix(userName, "@chatroom") {
+			// 未设置群名时 rcontact.nickname 为空，使用成员昵称拼接的 displayname
+			if nickName == "" {
+				nickName = displayNames
+			}
+			if err := im.w.AddChatRoom(userName, nickName, remark, members); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := im.w.AddContact(userName, nickName, remark, alias); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// importMessages 将 message 表按会话拆分为 Chat_<会话 MD5> 表写入 Message/msg_0.db
+// createTime 为毫秒，isSend 为 1 表示自己发送，对应 macOS 的 mesDes 为 0
+func (im *androidImporter) importMessages() error {
+	db, err := im.w.NewMessageDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(im.ctx, "ATTACH DATABASE ? AS src", im.src); err != nil {
+		return errors.DBConnectFailed(im.src, err)
+	}
+	defer db.ExecContext(im.ctx, "DETACH DATABASE src")
+
+	query := "SELECT DISTINCT talker FROM src.message WHERE talker IS NOT NULL AND talker != ''"
+	rows, err := db.QueryContext(im.ctx, query)
+	if err != nil {
+		return errors.QueryFailed(query, err)
+	}
+	talkers := make([]string, 0)
+	for rows.Next() {
+		var talker string
+		if err := rows.Scan(&talker); err != nil {
+			rows.Close()
+			return errors.ScanRowFailed(err)
+		}
+		talkers = append(talkers, talker)
+	}
+	rows.Close()
+
+	for _, talker := range talkers {
+		if err := im.ctx.Err(); err != nil {
+			return err
+		}
+		talkerMd5 := importer.MD5(talker)
+		table := "Chat_" + talkerMd5
+		query := fmt.Sprintf(importer.ChatTableSchema, table)
+		if _, err := db.ExecContext(im.ctx, query); err != nil {
+			return errors.QueryFailed(query, err)
+		}
+		query = fmt.Sprintf(`INSERT INTO main.%s (mesLocalID, mesSvrID, msgCreateTime, msgContent, msgStatus, msgImgStatus, messageType, mesDes)
+			SELECT msgId, msgSvrId, createTime / 1000, content, status, 0, type, CASE WHEN isSend = 1 THEN 0 ELSE 1 END
+			FROM src.message WHERE talker = ?`, table)
+		if _, err := db.ExecContext(im.ctx, query, talker); err != nil {
+			return errors.QueryFailed(query, err)
+		}
+
+		var count int
+		var lastTime sql.NullInt64
+		query = fmt.Sprintf("SELECT COUNT(*), MAX(msgCreateTime) FROM main.%s", table)
+		if err := db.QueryRowContext(im.ctx, query).Scan(&count, &lastTime); err != nil {
+			return errors.QueryFailed(query, err)
+		}
+		im.w.Report.Messages += count
+		if count > 0 {
+			if err := im.w.AddSession(talker, lastTime.Int64); err != nil {
+				return err
+			}
+		}
+	}
+
+	if im.mediaDir != "" && im.w.CopyMedia() {
+		if err := im.importImages(db); err != nil {
+			// 不同版本的 ImgInfo2 表结构不同，图片导入失败时仍保留消息
+			log.Warn().Err(err).Msg("import android images failed")
+		}
+		if err := im.importVideos(db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importImages 图片路径记录在 ImgInfo2.bigImgPath，文件位于 image2/<前两位>/<三四位>/<bigImgPath>，未下载的原图以 SERVERID:// 开头
+func (im *androidImporter) importImages(db *sql.DB) error {
+	query := `SELECT m.talker, m.msgId, m.content, i.bigImgPath FROM src.message m
+		JOIN src.ImgInfo2 i ON i.msglocalid = m.msgId WHERE m.type = 3`
+	return im.importMedia(db, query, 3, "Img", func(name string) string {
+		if len(name) < 4 || strings.HasPrefix(name, "SERVERID://") {
+			return ""
+		}
+		return filepath.Join(im.mediaDir, "image2", name[:2], name[2:4], name)
+	})
+}
+
+// importVideos 视频文件为 video/<imgPath>.mp4
+func (im *androidImporter) importVideos(db *sql.DB) error {
+	query := "SELECT talker, msgId, content, imgPath FROM src.message WHERE type = 43"
+	return im.importMedia(db, query, 43, "Video", func(name string) string {
+		if name == "" {
+			return ""
+		}
+		return filepath.Join(im.mediaDir, "video", name+".mp4")
+	})
+}
+
+// importMedia query 返回会话、消息 ID、内容与文件名，locate 返回文件路径，文件不存在时跳过
+func (im *androidImporter) importMedia(db *sql.DB, query string, msgType int64, dir string, locate func(name string) string) error {
+	rows, err := db.QueryContext(im.ctx, query)
+	if err != nil {
+		return errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var talker, content, name sql.NullString
+		var msgID int64
+		if err := rows.Scan(&talker, &msgID, &content, &name); err != nil {
+			return errors.ScanRowFailed(err)
+		}
+		src := locate(name.String)
+		if src == "" {
+			continue
+		}
+		mediaMd5 := importer.MediaMD5(msgType, content.String)
+		if mediaMd5 == "" {
+			continue
+		}
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		fileName := fmt.Sprintf("%d.jpg", msgID)
+		if msgType == 43 {
+			fileName = fmt.Sprintf("%d.mp4", msgID)
+		}
+		if err := im.w.AddMedia(mediaMd5, src, importer.MD5(talker.String), dir, fileName); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
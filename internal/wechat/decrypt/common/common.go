@@ -136,3 +136,23 @@ func DecryptPage(pageBuf []byte, encKey []byte, macKey []byte, pageNum int64, ha
 
 	return decryptedPage, nil
 }
+
+// PageFunc 解密数据库中的单页，pageNum 从 0 开始
+type PageFunc func(pageBuf []byte, pageNum int64) ([]byte, error)
+
+// NewPageFunc 返回使用已派生密钥逐页解密的函数，全零页原样返回
+func NewPageFunc(encKey []byte, macKey []byte, hashFunc func() hash.Hash, hmacSize int, reserve int, pageSize int) PageFunc {
+	return func(pageBuf []byte, pageNum int64) ([]byte, error) {
+		allZeros := true
+		for _, b := range pageBuf {
+			if b != 0 {
+				allZeros = false
+				break
+			}
+		}
+		if allZeros {
+			return pageBuf, nil
+		}
+		return DecryptPage(pageBuf, encKey, macKey, pageNum, hashFunc, hmacSize, reserve, pageSize)
+	}
+}
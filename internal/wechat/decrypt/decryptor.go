@@ -5,6 +5,7 @@ import (
 	"io"
 
 	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/wechat/decrypt/common"
 	"github.com/sjzar/chatlog/internal/wechat/decrypt/darwin"
 	"github.com/sjzar/chatlog/internal/wechat/decrypt/windows"
 )
@@ -14,6 +15,9 @@ type Decryptor interface {
 	// Decrypt 解密数据库
 	Decrypt(ctx context.Context, dbfile string, key string, output io.Writer) error
 
+	// PageDecrypter 派生数据库文件的密钥，返回逐页解密函数
+	PageDecrypter(dbfile string, key string) (common.PageFunc, error)
+
 	// Validate 验证密钥是否有效
 	Validate(page1 []byte, key []byte) bool
 
@@ -0,0 +1,111 @@
+package decrypt
+
+import (
+	"bytes"
+	"context"
+	"hash/fnv"
+	"io"
+	"os"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/wechat/decrypt/common"
+)
+
+// PageState 上次解密时的密钥与每页密文、明文的摘要，用于判断哪些页面发生了变化
+// 明文摘要用于确认上次的解密结果未被修改（如切换日志模式或清理消息），被修改的页面重新解密
+type PageState struct {
+	salt    []byte
+	sums    []uint64
+	outSums []uint64
+	decrypt common.PageFunc
+}
+
+// Pages 数据库的页数
+func (s *PageState) Pages() int {
+	return len(s.sums)
+}
+
+// DecryptIncremental 增量解密数据库，密文未变化的页面直接从上次解密的结果 previous 中复制，只解密发生变化的页面
+// prev 为空或盐值变化（数据库被重建）时全部重新解密，盐值不变时复用已派生的密钥
+// 返回本次的页面状态与实际解密的页数
+func DecryptIncremental(ctx context.Context, d Decryptor, dbfile string, key string, prev *PageState, previous io.ReaderAt, output io.Writer) (*PageState, int, error) {
+	pageSize := d.GetPageSize()
+
+	f, err := os.Open(dbfile)
+	if err != nil {
+		return nil, 0, errors.OpenFileFailed(dbfile, err)
+	}
+	defer f.Close()
+
+	salt := make([]byte, common.SaltSize)
+	if _, err := io.ReadFull(f, salt); err != nil {
+		return nil, 0, errors.ReadFileFailed(dbfile, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, errors.ReadFileFailed(dbfile, err)
+	}
+
+	state := &PageState{salt: salt}
+	if prev != nil && prev.decrypt != nil && bytes.Equal(prev.salt, salt) {
+		state.decrypt = prev.decrypt
+	} else {
+		if state.decrypt, err = d.PageDecrypter(dbfile, key); err != nil {
+			return nil, 0, err
+		}
+		prev, previous = nil, nil
+	}
+
+	pageBuf := make([]byte, pageSize)
+	prevBuf := make([]byte, pageSize)
+	decrypted := 0
+	for curPage := int64(0); ; curPage++ {
+		select {
+		case <-ctx.Done():
+			return nil, 0, errors.ErrDecryptOperationCanceled
+		default:
+		}
+
+		// 与完整解密一致，忽略末尾不完整的页面
+		if _, err := io.ReadFull(f, pageBuf); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, 0, errors.ReadFileFailed(dbfile, err)
+		}
+
+		sum := pageSum(pageBuf)
+		state.sums = append(state.sums, sum)
+
+		// 解密结果中每页与密文页对齐，第一页的盐值位置为 SQLite 文件头
+		if prev != nil && previous != nil && int(curPage) < len(prev.sums) && prev.sums[curPage] == sum {
+			if _, err := previous.ReadAt(prevBuf, curPage*int64(pageSize)); err == nil && pageSum(prevBuf) == prev.outSums[curPage] {
+				if _, err := output.Write(prevBuf); err != nil {
+					return nil, 0, errors.WriteOutputFailed(err)
+				}
+				state.outSums = append(state.outSums, prev.outSums[curPage])
+				continue
+			}
+		}
+
+		data, err := state.decrypt(pageBuf, curPage)
+		if err != nil {
+			return nil, 0, err
+		}
+		if curPage == 0 {
+			data = append([]byte(common.SQLiteHeader), data...)
+		}
+		if _, err := output.Write(data); err != nil {
+			return nil, 0, errors.WriteOutputFailed(err)
+		}
+		state.outSums = append(state.outSums, pageSum(data))
+		decrypted++
+	}
+
+	return state, decrypted, nil
+}
+
+func pageSum(page []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(page)
+	return h.Sum64()
+}
@@ -165,6 +165,26 @@ func (d *V4Decryptor) Decrypt(ctx context.Context, dbfile string, hexKey string,
 	return nil
 }
 
+// PageDecrypter 派生数据库文件的密钥，返回逐页解密函数，用于只解密发生变化的页面
+func (d *V4Decryptor) PageDecrypter(dbfile string, hexKey string) (common.PageFunc, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, errors.DecodeKeyFailed(err)
+	}
+
+	dbInfo, err := common.OpenDBFile(dbfile, d.pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if !d.Validate(dbInfo.FirstPage, key) {
+		return nil, errors.ErrDecryptIncorrectKey
+	}
+
+	encKey, macKey := d.deriveKeys(key, dbInfo.Salt)
+	return common.NewPageFunc(encKey, macKey, d.hashFunc, d.hmacSize, d.reserve, d.pageSize), nil
+}
+
 // GetPageSize 返回页面大小
 func (d *V4Decryptor) GetPageSize() int {
 	return d.pageSize
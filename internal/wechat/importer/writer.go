@@ -0,0 +1,317 @@
+package importer
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/filecrypt"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// 导入后的工作目录与 macOS 3.x 解密后的目录结构相同，以 darwin 平台、版本 3 读取
+const (
+	Platform = "darwin"
+	Version  = 3
+)
+
+// 工作目录中的数据库，文件名需与 darwinv3 数据源的分组匹配
+const (
+	contactDB = "Contact/wccontact_new2.db"
+	groupDB   = "Group/group_new.db"
+	sessionDB = "Session/session_new.db"
+	mediaDB   = "Hlink/hldata.db"
+)
+
+var schemas = map[string][]string{
+	contactDB: {
+		`CREATE TABLE WCContact (m_nsUsrName TEXT PRIMARY KEY, nickname TEXT, m_nsRemark TEXT, m_uiSex INTEGER, m_nsAliasName TEXT)`,
+	},
+	groupDB: {
+		`CREATE TABLE GroupContact (m_nsUsrName TEXT PRIMARY KEY, nickname TEXT, m_nsRemark TEXT, m_nsChatRoomMemList TEXT, m_nsChatRoomAdminList TEXT)`,
+		`CREATE TABLE GroupMember (m_nsUsrName TEXT PRIMARY KEY, nickname TEXT)`,
+	},
+	sessionDB: {
+		`CREATE TABLE SessionAbstract (m_nsUserName TEXT PRIMARY KEY, m_uUnReadCount INTEGER, m_uLastTime INTEGER)`,
+	},
+	mediaDB: {
+		`CREATE TABLE HlinkMediaRecord (mediaMd5 TEXT, mediaSize INTEGER, inodeNumber INTEGER, modifyTime INTEGER, CONSTRAINT _Md5_Size UNIQUE (mediaMd5, mediaSize))`,
+		`CREATE TABLE HlinkMediaDetail (localId INTEGER PRIMARY KEY AUTOINCREMENT, inodeNumber INTEGER, relativePath TEXT, fileName TEXT)`,
+	},
+}
+
+// ChatTableSchema 消息表，列名与 macOS 3.x 相同，mesDes 为 0 表示自己发送
+const ChatTableSchema = `CREATE TABLE %s (mesLocalID INTEGER PRIMARY KEY AUTOINCREMENT, mesSvrID INTEGER, msgCreateTime INTEGER,
+	msgContent TEXT, msgStatus INTEGER, msgImgStatus INTEGER, messageType INTEGER, mesDes INTEGER, msgSource TEXT)`
+
+// Report 导入结果
+type Report struct {
+	Account   string `json:"account"`
+	Messages  int    `json:"messages"`
+	Talkers   int    `json:"talkers"`
+	Contacts  int    `json:"contacts"`
+	ChatRooms int    `json:"chatRooms"`
+	Media     int    `json:"media"`
+}
+
+// Writer 在工作目录下的临时目录中生成与 macOS 3.x 解密后结构相同的数据库，Install 后移动到工作目录
+// 媒体文件复制到 Message/MessageTemp 下，数据目录需指定为工作目录；工作目录加密时不复制媒体文件
+type Writer struct {
+	ctx     context.Context
+	workDir string
+	temp    string
+	key     []byte
+	dbs     map[string]*sql.DB
+
+	messageDBs int
+	inode      int64
+
+	Report *Report
+}
+
+// NewWriter key 为工作目录的加密密钥，未加密时为 nil
+func NewWriter(ctx context.Context, workDir string, key []byte) (*Writer, error) {
+	if err := util.PrepareDir(workDir); err != nil {
+		return nil, err
+	}
+	temp, err := os.MkdirTemp(workDir, ".import-")
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{
+		ctx:     ctx,
+		workDir: workDir,
+		temp:    temp,
+		key:     key,
+		dbs:     make(map[string]*sql.DB),
+		Report:  &Report{},
+	}
+	for name, schema := range schemas {
+		db, err := w.open(name)
+		if err != nil {
+			w.Close()
+			return nil, err
+		}
+		for _, query := range schema {
+			if _, err := db.ExecContext(ctx, query); err != nil {
+				w.Close()
+				return nil, errors.QueryFailed(query, err)
+			}
+		}
+		w.dbs[name] = db
+	}
+	return w, nil
+}
+
+// Temp 临时目录，用于存放导入过程中的中间文件，Close 时删除
+func (w *Writer) Temp() string {
+	return w.temp
+}
+
+func (w *Writer) open(name string) (*sql.DB, error) {
+	dbPath := filepath.Join(w.temp, name)
+	if err := util.PrepareDir(filepath.Dir(dbPath)); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, errors.DBConnectFailed(dbPath, err)
+	}
+	// ATTACH 与事务只对当前连接有效
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+func (w *Writer) exec(name string, query string, args ...interface{}) error {
+	if _, err := w.dbs[name].ExecContext(w.ctx, query, args...); err != nil {
+		return errors.QueryFailed(query, err)
+	}
+	return nil
+}
+
+// AddContact 写入联系人
+func (w *Writer) AddContact(userName, nickName, remark, alias string) error {
+	if err := w.exec(contactDB, "INSERT OR REPLACE INTO WCContact VALUES (?, ?, ?, 0, ?)", userName, nickName, remark, alias); err != nil {
+		return err
+	}
+	w.Report.Contacts++
+	return nil
+}
+
+// AddChatRoom 写入群聊，members 为以分号分隔的成员 ID
+func (w *Writer) AddChatRoom(userName, nickName, remark, members string) error {
+	if err := w.exec(groupDB, "INSERT OR REPLACE INTO GroupContact VALUES (?, ?, ?, ?, '')", userName, nickName, remark, members); err != nil {
+		return err
+	}
+	w.Report.ChatRooms++
+	return nil
+}
+
+// AddMember 写入群成员的群昵称
+func (w *Writer) AddMember(userName, displayName string) error {
+	return w.exec(groupDB, "INSERT OR REPLACE INTO GroupMember VALUES (?, ?)", userName, displayName)
+}
+
+// AddSession 写入最近会话，lastTime 为最后一条消息的时间（秒）
+func (w *Writer) AddSession(userName string, lastTime int64) error {
+	if err := w.exec(sessionDB, "INSERT OR REPLACE INTO SessionAbstract VALUES (?, 0, ?)", userName, lastTime); err != nil {
+		return err
+	}
+	w.Report.Talkers++
+	return nil
+}
+
+// NewMessageDB 创建新的消息分库 Message/msg_<n>.db，由调用方关闭
+func (w *Writer) NewMessageDB() (*sql.DB, error) {
+	db, err := w.open(fmt.Sprintf("Message/msg_%d.db", w.messageDBs))
+	if err != nil {
+		return nil, err
+	}
+	w.messageDBs++
+	return db, nil
+}
+
+// CopyMedia 是否复制媒体文件，工作目录加密时媒体文件无法加密保存，不复制
+func (w *Writer) CopyMedia() bool {
+	return w.key == nil
+}
+
+// AddMedia 复制媒体文件到 Message/MessageTemp/<会话 MD5>/<dir>/<fileName>，并以消息中的 md5 建立索引，供 /image、/video 等接口查找
+func (w *Writer) AddMedia(mediaMd5 string, src string, talkerMd5 string, dir string, fileName string) error {
+	if !w.CopyMedia() {
+		return nil
+	}
+	relativePath := path.Join(talkerMd5, dir)
+	dst := filepath.Join(w.temp, "Message", "MessageTemp", relativePath, fileName)
+	if err := CopyFile(src, dst); err != nil {
+		return err
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		return errors.StatFileFailed(dst, err)
+	}
+	w.inode++
+	if err := w.exec(mediaDB, "INSERT OR IGNORE INTO HlinkMediaRecord VALUES (?, ?, ?, ?)", mediaMd5, info.Size(), w.inode, info.ModTime().Unix()); err != nil {
+		return err
+	}
+	if err := w.exec(mediaDB, "INSERT INTO HlinkMediaDetail (inodeNumber, relativePath, fileName) VALUES (?, ?, ?)", w.inode, relativePath, fileName); err != nil {
+		return err
+	}
+	w.Report.Media++
+	return nil
+}
+
+// Install 将生成的数据库与媒体文件移动到工作目录，替换同名文件；工作目录加密时数据库加密后写入
+// 调用前需关闭 NewMessageDB 返回的数据库，skip 为临时目录中不移动的中间文件目录
+func (w *Writer) Install(skip ...string) error {
+	for name, db := range w.dbs {
+		db.Close()
+		delete(w.dbs, name)
+	}
+	return filepath.Walk(w.temp, func(src string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(w.temp, src)
+		if err != nil {
+			return err
+		}
+		for _, s := range skip {
+			if rel == s {
+				return filepath.SkipDir
+			}
+		}
+		if info.IsDir() {
+			return nil
+		}
+		dst := filepath.Join(w.workDir, rel)
+		if err := util.PrepareDir(filepath.Dir(dst)); err != nil {
+			return err
+		}
+		if w.key == nil {
+			return os.Rename(src, dst)
+		}
+		return encryptFile(src, dst, w.key)
+	})
+}
+
+// Close 关闭数据库并删除临时目录
+func (w *Writer) Close() {
+	for _, db := range w.dbs {
+		db.Close()
+	}
+	os.RemoveAll(w.temp)
+}
+
+func encryptFile(src, dst string, key []byte) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.OpenFileFailed(src, err)
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return errors.OpenFileFailed(dst, err)
+	}
+	defer out.Close()
+	enc, err := filecrypt.NewWriter(out, key)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(enc, in); err != nil {
+		return errors.WriteOutputFailed(err)
+	}
+	return enc.Close()
+}
+
+// CopyFile 复制文件，自动创建目标目录
+func CopyFile(src, dst string) error {
+	if err := util.PrepareDir(filepath.Dir(dst)); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.OpenFileFailed(src, err)
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return errors.OpenFileFailed(dst, err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		return errors.WriteOutputFailed(err)
+	}
+	return nil
+}
+
+// MediaMD5 解析图片、视频等多媒体消息中的 md5，群聊消息的内容以 "发送人:\n" 开头
+func MediaMD5(msgType int64, content string) string {
+	if i := strings.Index(content, ":\n<"); i >= 0 {
+		content = content[i+2:]
+	}
+	msg := &model.Message{Type: msgType}
+	if err := msg.ParseMediaInfo(content); err != nil {
+		return ""
+	}
+	mediaMd5, _ := msg.Contents["md5"].(string)
+	return mediaMd5
+}
+
+// MD5 会话 ID 的 MD5，消息表名为 Chat_<MD5>
+func MD5(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,220 @@
+package ios
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/rs/zerolog/log"
+	"howett.net/plist"
+
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// Domain 微信在 iOS 备份中的应用域
+const Domain = "AppDomain-com.tencent.xin"
+
+// accountDB 账号目录为微信 ID 的 MD5，消息主库为 Documents/<md5>/DB/MM.sqlite
+var accountDB = regexp.MustCompile(`^Documents/([0-9a-f]{32})/DB/MM\.sqlite$`)
+
+// Backup iTunes / Finder 生成的备份，文件以 SHA1(域-相对路径) 命名存放在前两位字符的子目录中
+// 加密的备份需提供备份密码，读取文件时解密到临时目录，Close 时删除
+type Backup struct {
+	path string
+
+	// 微信应用域中的文件，相对路径 -> fileID
+	files map[string]string
+
+	// 加密备份的类密钥与各文件的密钥，未加密时为 nil
+	keybag *keybag
+	infos  map[string]*fileInfo
+
+	mu   sync.Mutex
+	temp string
+}
+
+// Open 读取备份的 Manifest.db，加密的备份以 password 解密，未提供密码时返回 ErrBackupEncrypted
+func Open(path string, password string) (*Backup, error) {
+	manifestFile := filepath.Join(path, "Manifest.plist")
+	data, err := os.ReadFile(manifestFile)
+	if err != nil {
+		return nil, errors.ReadFileFailed(manifestFile, err)
+	}
+	var manifest struct {
+		IsEncrypted  bool   `plist:"IsEncrypted"`
+		BackupKeyBag []byte `plist:"BackupKeyBag"`
+		ManifestKey  []byte `plist:"ManifestKey"`
+	}
+	if _, err := plist.Unmarshal(data, &manifest); err != nil {
+		return nil, errors.ReadFileFailed(manifestFile, err)
+	}
+
+	b := &Backup{path: path, files: make(map[string]string)}
+	manifestDB := filepath.Join(path, "Manifest.db")
+	if manifest.IsEncrypted {
+		if password == "" {
+			return nil, errors.ErrBackupEncrypted
+		}
+		if b.keybag, err = parseKeybag(manifest.BackupKeyBag); err != nil {
+			return nil, errors.ReadFileFailed(manifestFile, err)
+		}
+		if err := b.keybag.unlock(password); err != nil {
+			return nil, err
+		}
+		b.infos = make(map[string]*fileInfo)
+
+		// Manifest.db 同样加密，密钥为 ManifestKey
+		key, err := b.keybag.unwrap(manifest.ManifestKey)
+		if err != nil {
+			return nil, errors.ErrBackupPassword
+		}
+		if manifestDB, err = b.decrypt(manifestDB, "Manifest.db", &fileInfo{key: key}); err != nil {
+			b.Close()
+			return nil, err
+		}
+	}
+
+	if err := b.readManifest(manifestDB); err != nil {
+		b.Close()
+		return nil, err
+	}
+	if len(b.files) == 0 {
+		b.Close()
+		return nil, errors.ErrBackupWeChatNotFound
+	}
+	return b, nil
+}
+
+// readManifest 读取微信应用域中的文件列表，加密的备份同时读取各文件的密钥
+func (b *Backup) readManifest(manifestDB string) error {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", manifestDB))
+	if err != nil {
+		return errors.DBConnectFailed(manifestDB, err)
+	}
+	defer db.Close()
+
+	// flags 为 1 表示普通文件
+	query := "SELECT fileID, relativePath, file FROM Files WHERE domain = ? AND flags = 1"
+	rows, err := db.Query(query, Domain)
+	if err != nil {
+		return errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fileID, relativePath string
+		var file []byte
+		if err := rows.Scan(&fileID, &relativePath, &file); err != nil {
+			return errors.ScanRowFailed(err)
+		}
+		if b.keybag != nil {
+			info, err := parseFileInfo(file)
+			if err != nil || len(info.key) == 0 {
+				log.Debug().Err(err).Msgf("encryption key of %s not found", relativePath)
+				continue
+			}
+			b.infos[fileID] = info
+		}
+		b.files[relativePath] = fileID
+	}
+	if err := rows.Err(); err != nil {
+		return errors.QueryFailed(query, err)
+	}
+	return nil
+}
+
+// File 返回应用域中相对路径对应的备份文件路径，加密的备份返回解密后的临时文件
+func (b *Backup) File(relativePath string) (string, bool) {
+	fileID, ok := b.files[relativePath]
+	if !ok || len(fileID) < 2 {
+		return "", false
+	}
+	path := filepath.Join(b.path, fileID[:2], fileID)
+	if b.keybag == nil {
+		return path, true
+	}
+	info := b.infos[fileID]
+	key, err := b.keybag.unwrap(info.key)
+	if err != nil {
+		log.Warn().Err(err).Msgf("failed to unwrap key of %s", relativePath)
+		return "", false
+	}
+	path, err = b.decrypt(path, fileID, &fileInfo{key: key, size: info.size})
+	if err != nil {
+		log.Warn().Err(err).Msgf("failed to decrypt %s", relativePath)
+		return "", false
+	}
+	return path, true
+}
+
+// decrypt 将加密的备份文件解密到临时目录，info.key 为解开后的文件密钥，已解密过的文件直接返回
+func (b *Backup) decrypt(path string, name string, info *fileInfo) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.temp == "" {
+		dir, err := os.MkdirTemp("", "chatlog-ios-")
+		if err != nil {
+			return "", errors.WriteFileFailed(os.TempDir(), err)
+		}
+		b.temp = dir
+	}
+	dst := filepath.Join(b.temp, name)
+	if _, err := os.Stat(dst); err == nil {
+		return dst, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.ReadFileFailed(path, err)
+	}
+	out, err := decryptFile(info.key, data, info.size)
+	if err != nil {
+		return "", errors.ReadFileFailed(path, err)
+	}
+	if err := os.WriteFile(dst, out, 0600); err != nil {
+		return "", errors.WriteFileFailed(dst, err)
+	}
+	return dst, nil
+}
+
+// Close 删除解密的临时文件
+func (b *Backup) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.temp == "" {
+		return nil
+	}
+	err := os.RemoveAll(b.temp)
+	b.temp = ""
+	return err
+}
+
+// Files 返回以 prefix 开头的相对路径，按名称排序
+func (b *Backup) Files(prefix string) []string {
+	ret := make([]string, 0)
+	for relativePath := range b.files {
+		if strings.HasPrefix(relativePath, prefix) {
+			ret = append(ret, relativePath)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Accounts 返回备份中登录过的账号目录（微信 ID 的 MD5）
+func (b *Backup) Accounts() []string {
+	ret := make([]string, 0)
+	for relativePath := range b.files {
+		if m := accountDB.FindStringSubmatch(relativePath); m != nil {
+			ret = append(ret, m[1])
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,296 @@
+package ios
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+	"howett.net/plist"
+
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+const (
+	testAccount = "0123456789abcdef0123456789abcdef"
+	testDB      = "Documents/" + testAccount + "/DB/MM.sqlite"
+)
+
+// backupFile 备份中的一个文件
+type backupFile struct {
+	domain       string
+	relativePath string
+	flags        int
+	data         []byte
+}
+
+func fileID(f backupFile) string {
+	sum := sha1.Sum([]byte(f.domain + "-" + f.relativePath))
+	return hex.EncodeToString(sum[:])
+}
+
+var testFiles = []backupFile{
+	{Domain, testDB, 1, []byte("messages")},
+	{Domain, "Documents/" + testAccount + "/DB/WCDB_Contact.sqlite", 1, []byte("contacts")},
+	{Domain, "Documents/" + testAccount + "/Img/a.pic", 1, []byte("image")},
+	{Domain, "Documents/" + testAccount + "/DB", 2, nil},
+	{Domain, "Documents/ffffffffffffffffffffffffffffffff/DB/MM.sqlite.bak", 1, []byte("backup")},
+	{"HomeDomain", "Documents/fedcba9876543210fedcba9876543210/DB/MM.sqlite", 1, []byte("other app")},
+}
+
+// writeManifestDB 生成 Manifest.db，fileInfo 返回 file 列的内容
+func writeManifestDB(t *testing.T, path string, files []backupFile, fileInfo func(backupFile) []byte) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE Files (fileID TEXT PRIMARY KEY, domain TEXT, relativePath TEXT, flags INTEGER, file BLOB)"); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if _, err := db.Exec("INSERT INTO Files VALUES (?, ?, ?, ?, ?)", fileID(f), f.domain, f.relativePath, f.flags, fileInfo(f)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func writePlist(t *testing.T, path string, v interface{}) {
+	data, err := plist.Marshal(v, plist.BinaryFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeFile(t *testing.T, dir string, f backupFile, data []byte) {
+	id := fileID(f)
+	if err := os.MkdirAll(filepath.Join(dir, id[:2]), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, id[:2], id), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackupFiles(t *testing.T) {
+	dir := t.TempDir()
+	writePlist(t, filepath.Join(dir, "Manifest.plist"), map[string]interface{}{"IsEncrypted": false})
+	writeManifestDB(t, filepath.Join(dir, "Manifest.db"), testFiles, func(backupFile) []byte { return nil })
+	for _, f := range testFiles {
+		writeFile(t, dir, f, f.data)
+	}
+
+	b, err := Open(dir, "")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer b.Close()
+
+	// 只包含微信应用域中的普通文件
+	want := []string{
+		"Documents/" + testAccount + "/DB/MM.sqlite",
+		"Documents/" + testAccount + "/DB/WCDB_Contact.sqlite",
+	}
+	if got := b.Files("Documents/" + testAccount + "/DB/"); !slices.Equal(got, want) {
+		t.Errorf("Files() = %v, want %v", got, want)
+	}
+	if got := b.Files("Library/"); len(got) != 0 {
+		t.Errorf("Files(Library/) = %v, want empty", got)
+	}
+	if got := b.Accounts(); !slices.Equal(got, []string{testAccount}) {
+		t.Errorf("Accounts() = %v, want [%s]", got, testAccount)
+	}
+
+	path, ok := b.File(testDB)
+	if !ok {
+		t.Fatalf("File(%s) not found", testDB)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "messages" {
+		t.Errorf("File(%s) content = %q", testDB, data)
+	}
+	if _, ok := b.File("Documents/missing"); ok {
+		t.Errorf("File(missing) found")
+	}
+}
+
+func TestBackupNoWeChat(t *testing.T) {
+	dir := t.TempDir()
+	writePlist(t, filepath.Join(dir, "Manifest.plist"), map[string]interface{}{"IsEncrypted": false})
+	writeManifestDB(t, filepath.Join(dir, "Manifest.db"), testFiles[5:], func(backupFile) []byte { return nil })
+	if _, err := Open(dir, ""); err != errors.ErrBackupWeChatNotFound {
+		t.Errorf("Open() error = %v, want ErrBackupWeChatNotFound", err)
+	}
+}
+
+// aesWrap RFC 3394 AES 密钥包装
+func aesWrap(t *testing.T, kek, key []byte) []byte {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(key) / 8
+	a := append([]byte{}, aesUnwrapIV...)
+	r := append([]byte{}, key...)
+	buf := make([]byte, 16)
+	for j := 0; j <= 5; j++ {
+		for i := 1; i <= n; i++ {
+			copy(buf, a)
+			copy(buf[8:], r[(i-1)*8:i*8])
+			block.Encrypt(buf, buf)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(buf[:8])^uint64(n*j+i))
+			copy(r[(i-1)*8:i*8], buf[8:])
+		}
+	}
+	return append(a, r...)
+}
+
+func encryptFile(t *testing.T, key, data []byte) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pad := aes.BlockSize - len(data)%aes.BlockSize
+	data = append(append([]byte{}, data...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(out, data)
+	return out
+}
+
+func tlv(tag string, value []byte) []byte {
+	ret := make([]byte, 8, 8+len(value))
+	copy(ret, tag)
+	binary.BigEndian.PutUint32(ret[4:], uint32(len(value)))
+	return append(ret, value...)
+}
+
+func u32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+// wrappedKey 以类密钥包装文件密钥，前 4 字节为小端的保护等级
+func wrappedKey(t *testing.T, class uint32, classKey, key []byte) []byte {
+	return append(binary.LittleEndian.AppendUint32(nil, class), aesWrap(t, classKey, key)...)
+}
+
+// archivedFileInfo 生成 file 列的 NSKeyedArchiver 数据
+func archivedFileInfo(key []byte, size int) []byte {
+	data, _ := plist.Marshal(map[string]interface{}{
+		"$archiver": "NSKeyedArchiver",
+		"$version":  100000,
+		"$top":      map[string]interface{}{"root": plist.UID(1)},
+		"$objects": []interface{}{
+			"$null",
+			map[string]interface{}{
+				"EncryptionKey": plist.UID(2),
+				"Size":          size,
+				"$class":        plist.UID(3),
+			},
+			map[string]interface{}{"NS.data": key},
+			map[string]interface{}{"$classname": "MBFile"},
+		},
+	}, plist.BinaryFormat)
+	return data
+}
+
+func TestEncryptedBackup(t *testing.T) {
+	const password = "123456"
+	dir := t.TempDir()
+	salt, dpsl := bytes.Repeat([]byte{1}, 20), bytes.Repeat([]byte{2}, 20)
+	passcodeKey := pbkdf2.Key(pbkdf2.Key([]byte(password), dpsl, 10, 32, sha256.New), salt, 10, 32, sha1.New)
+
+	// 两个保护等级，类密钥以备份密码派生的密钥包装
+	classKeys := map[uint32][]byte{1: bytes.Repeat([]byte{0x11}, 32), 3: bytes.Repeat([]byte{0x33}, 32)}
+	keybag := slices.Concat(tlv("VERS", u32(3)), tlv("TYPE", u32(1)), tlv("UUID", make([]byte, 16)),
+		tlv("WRAP", u32(0)), tlv("SALT", salt), tlv("ITER", u32(10)), tlv("DPSL", dpsl), tlv("DPIC", u32(10)))
+	for _, class := range []uint32{1, 3} {
+		keybag = slices.Concat(keybag, tlv("UUID", bytes.Repeat([]byte{byte(class)}, 16)), tlv("CLAS", u32(class)),
+			tlv("WRAP", u32(3)), tlv("KTYP", u32(0)), tlv("WPKY", aesWrap(t, passcodeKey, classKeys[class])))
+	}
+
+	manifestKey := bytes.Repeat([]byte{0x4d}, 32)
+	plainDB := filepath.Join(t.TempDir(), "Manifest.db")
+	fileKeys := make(map[string][]byte)
+	writeManifestDB(t, plainDB, testFiles, func(f backupFile) []byte {
+		key := bytes.Repeat([]byte{byte(len(fileKeys) + 1)}, 32)
+		fileKeys[f.relativePath] = key
+		return archivedFileInfo(wrappedKey(t, 3, classKeys[3], key), len(f.data))
+	})
+	data, err := os.ReadFile(plainDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Manifest.db"), encryptFile(t, manifestKey, data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	writePlist(t, filepath.Join(dir, "Manifest.plist"), map[string]interface{}{
+		"IsEncrypted":  true,
+		"BackupKeyBag": keybag,
+		"ManifestKey":  wrappedKey(t, 1, classKeys[1], manifestKey),
+	})
+	for _, f := range testFiles {
+		writeFile(t, dir, f, encryptFile(t, fileKeys[f.relativePath], f.data))
+	}
+
+	if _, err := Open(dir, ""); err != errors.ErrBackupEncrypted {
+		t.Errorf("Open(no password) error = %v, want ErrBackupEncrypted", err)
+	}
+	if _, err := Open(dir, "654321"); err != errors.ErrBackupPassword {
+		t.Errorf("Open(wrong password) error = %v, want ErrBackupPassword", err)
+	}
+
+	b, err := Open(dir, password)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	if got := b.Accounts(); !slices.Equal(got, []string{testAccount}) {
+		t.Errorf("Accounts() = %v, want [%s]", got, testAccount)
+	}
+	for _, f := range testFiles[:3] {
+		path, ok := b.File(f.relativePath)
+		if !ok {
+			t.Fatalf("File(%s) not found", f.relativePath)
+		}
+		if data, _ := os.ReadFile(path); !bytes.Equal(data, f.data) {
+			t.Errorf("File(%s) content = %q, want %q", f.relativePath, data, f.data)
+		}
+	}
+
+	// Close 删除解密的临时文件
+	path, _ := b.File(testDB)
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("decrypted file %s not removed: %v", path, err)
+	}
+}
+
+func TestAESUnwrap(t *testing.T) {
+	// RFC 3394 4.1 节的测试向量
+	kek, _ := hex.DecodeString("000102030405060708090A0B0C0D0E0F")
+	wrapped, _ := hex.DecodeString("1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5")
+	want, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF")
+	got, err := aesUnwrap(kek, wrapped)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("aesUnwrap() = %x, %v, want %x", got, err, want)
+	}
+	if !bytes.Equal(aesWrap(t, kek, want), wrapped) {
+		t.Errorf("aesWrap() does not match the test vector")
+	}
+	wrapped[0] ^= 1
+	if _, err := aesUnwrap(kek, wrapped); err == nil {
+		t.Errorf("aesUnwrap() accepted a corrupted key")
+	}
+}
@@ -0,0 +1,67 @@
+package ios
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// contactRemark Friend.dbContactRemark 中的字段：1 昵称，2 微信号，3 备注
+type contactRemark struct {
+	NickName string
+	Alias    string
+	Remark   string
+}
+
+// contactChatRoom Friend.dbContactChatRoom 中的字段：1 以分号分隔的成员列表
+type contactChatRoom struct {
+	Members string
+}
+
+func parseContactRemark(data []byte) contactRemark {
+	var r contactRemark
+	protoStrings(data, func(num protowire.Number, v string) {
+		switch num {
+		case 1:
+			r.NickName = v
+		case 2:
+			r.Alias = v
+		case 3:
+			r.Remark = v
+		}
+	})
+	return r
+}
+
+func parseContactChatRoom(data []byte) contactChatRoom {
+	var r contactChatRoom
+	protoStrings(data, func(num protowire.Number, v string) {
+		if num == 1 {
+			r.Members = v
+		}
+	})
+	return r
+}
+
+// protoStrings 遍历 protobuf 消息中的长度前缀字段，其余类型的字段跳过，遇到格式错误时停止
+func protoStrings(data []byte, fn func(num protowire.Number, v string)) {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return
+		}
+		data = data[n:]
+		if typ == protowire.BytesType {
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return
+			}
+			fn(num, string(v))
+			data = data[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		if n < 0 {
+			return
+		}
+		data = data[n:]
+	}
+}
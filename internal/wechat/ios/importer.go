@@ -0,0 +1,273 @@
+package ios
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/wechat/importer"
+)
+
+// messageDB 消息分库，MM.sqlite 为主库，新版本拆分出 message_<n>.sqlite
+var messageDB = regexp.MustCompile(`^Documents/[0-9a-f]{32}/DB/(MM|message_[0-9]+)\.sqlite$`)
+
+var chatTable = regexp.MustCompile(`^Chat_[0-9a-f]{32}$`)
+
+type iosImporter struct {
+	ctx     context.Context
+	backup  *Backup
+	account string
+	w       *importer.Writer
+
+	// 会话 ID 的 MD5 -> 会话 ID，用于从消息表名还原会话
+	talkers map[string]string
+}
+
+// Import 将备份中账号的聊天数据导入工作目录，account 为账号目录名（微信 ID 的 MD5），备份中只有一个账号时可以为空
+// 消息、联系人、群聊与最近会话写入数据库；图片与视频复制到工作目录的 Message/MessageTemp 下
+func Import(ctx context.Context, b *Backup, account string, workDir string, key []byte) (*importer.Report, error) {
+	accounts := b.Accounts()
+	if len(accounts) == 0 {
+		return nil, errors.ErrBackupWeChatNotFound
+	}
+	if account == "" {
+		if len(accounts) > 1 {
+			return nil, fmt.Errorf("备份中有多个账号，请指定其中一个: %s", strings.Join(accounts, ", "))
+		}
+		account = accounts[0]
+	}
+	if _, ok := b.File(fmt.Sprintf("Documents/%s/DB/MM.sqlite", account)); !ok {
+		return nil, errors.BackupAccountNotFound(account)
+	}
+
+	w, err := importer.NewWriter(ctx, workDir, key)
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+	w.Report.Account = account
+
+	im := &iosImporter{
+		ctx:     ctx,
+		backup:  b,
+		account: account,
+		w:       w,
+		talkers: make(map[string]string),
+	}
+	if err := im.importContacts(); err != nil {
+		return nil, err
+	}
+	if err := im.importMessages(); err != nil {
+		return nil, err
+	}
+	if err := w.Install("source"); err != nil {
+		return nil, err
+	}
+	return w.Report, nil
+}
+
+// source 将备份中的数据库连同 -wal、-shm 文件以原文件名复制到临时目录，备份中的文件名为哈希值，无法直接读取日志
+func (im *iosImporter) source(relativePath string) (string, error) {
+	src, ok := im.backup.File(relativePath)
+	if !ok {
+		return "", errors.OpenFileFailed(relativePath, os.ErrNotExist)
+	}
+	dst := filepath.Join(im.w.Temp(), "source", path.Base(relativePath))
+	if err := importer.CopyFile(src, dst); err != nil {
+		return "", err
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if src, ok := im.backup.File(relativePath + suffix); ok {
+			if err := importer.CopyFile(src, dst+suffix); err != nil {
+				return "", err
+			}
+		}
+	}
+	return dst, nil
+}
+
+// importContacts 从 WCDB_Contact.sqlite 的 Friend 表导入联系人与群聊
+func (im *iosImporter) importContacts() error {
+	src, err := im.source(fmt.Sprintf("Documents/%s/DB/WCDB_Contact.sqlite", im.account))
+	if err != nil {
+		// 没有联系人库时仍可导入消息，会话以 ID 显示
+		log.Warn().Err(err).Msg("contact db not found in backup")
+		return nil
+	}
+
+	db, err := sql.Open("sqlite3", src)
+	if err != nil {
+		return errors.DBConnectFailed(src, err)
+	}
+	defer db.Close()
+
+	query := "SELECT userName, dbContactRemark, dbContactChatRoom FROM Friend"
+	rows, err := db.QueryContext(im.ctx, query)
+	if err != nil {
+		return errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userName string
+		var remarkData, chatRoomData []byte
+		if err := rows.Scan(&userName, &remarkData, &chatRoomData); err != nil {
+			return errors.ScanRowFailed(err)
+		}
+		if userName == "" {
+			continue
+		}
+		im.talkers[importer.MD5(userName)] = userName
+		remark := parseContactRemark(remarkData)
+
+		if strings.HasSuffix(userName, "@chatroom") {
+			chatRoom := parseContactChatRoom(chatRoomData)
+			if err := im.w.AddChatRoom(userName, remark.NickName, remark.Remark, chatRoom.Members); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := im.w.AddContact(userName, remark.NickName, remark.Remark, remark.Alias); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// importMessages 将各消息分库的 Chat_ 表按 macOS 的列名写入 Message/msg_<n>.db，并根据消息生成最近会话与媒体索引
+func (im *iosImporter) importMessages() error {
+	for _, relativePath := range im.backup.Files(fmt.Sprintf("Documents/%s/DB/", im.account)) {
+		if !messageDB.MatchString(relativePath) {
+			continue
+		}
+		src, err := im.source(relativePath)
+		if err != nil {
+			return err
+		}
+		if err := im.importMessageDB(src); err != nil {
+			return err
+		}
+		os.Remove(src)
+	}
+	return nil
+}
+
+func (im *iosImporter) importMessageDB(src string) error {
+	db, err := im.w.NewMessageDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(im.ctx, "ATTACH DATABASE ? AS src", src); err != nil {
+		return errors.DBConnectFailed(src, err)
+	}
+	defer db.ExecContext(im.ctx, "DETACH DATABASE src")
+
+	query := "SELECT name FROM src.sqlite_master WHERE type = 'table'"
+	rows, err := db.QueryContext(im.ctx, query)
+	if err != nil {
+		return errors.QueryFailed(query, err)
+	}
+	tables := make([]string, 0)
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			rows.Close()
+			return errors.ScanRowFailed(err)
+		}
+		if chatTable.MatchString(table) {
+			tables = append(tables, table)
+		}
+	}
+	rows.Close()
+
+	for _, table := range tables {
+		if err := im.ctx.Err(); err != nil {
+			return err
+		}
+		queries := []string{
+			fmt.Sprintf(importer.ChatTableSchema, table),
+			fmt.Sprintf(`INSERT INTO main.%s (mesLocalID, mesSvrID, msgCreateTime, msgContent, msgStatus, msgImgStatus, messageType, mesDes)
+				SELECT MesLocalID, MesSvrID, CreateTime, Message, Status, ImgStatus, Type, Des FROM src.%s`, table, table),
+		}
+		for _, query := range queries {
+			if _, err := db.ExecContext(im.ctx, query); err != nil {
+				return errors.QueryFailed(query, err)
+			}
+		}
+
+		var count int
+		var lastTime sql.NullInt64
+		query := fmt.Sprintf("SELECT COUNT(*), MAX(msgCreateTime) FROM main.%s", table)
+		if err := db.QueryRowContext(im.ctx, query).Scan(&count, &lastTime); err != nil {
+			return errors.QueryFailed(query, err)
+		}
+		im.w.Report.Messages += count
+
+		talkerMd5 := strings.TrimPrefix(table, "Chat_")
+		if talker, ok := im.talkers[talkerMd5]; ok && count > 0 {
+			if err := im.w.AddSession(talker, lastTime.Int64); err != nil {
+				return err
+			}
+		}
+
+		if im.w.CopyMedia() {
+			if err := im.importMedia(db, table, talkerMd5); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// importMedia 复制图片与视频，图片为 Img/<会话 MD5>/<mesLocalID>.pic（优先使用高清的 .pic_hd），视频为 Video/<会话 MD5>/<mesLocalID>.mp4
+func (im *iosImporter) importMedia(db *sql.DB, table, talkerMd5 string) error {
+	query := fmt.Sprintf("SELECT mesLocalID, msgContent, messageType FROM main.%s WHERE messageType IN (3, 43)", table)
+	rows, err := db.QueryContext(im.ctx, query)
+	if err != nil {
+		return errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var localID, msgType int64
+		var content string
+		if err := rows.Scan(&localID, &content, &msgType); err != nil {
+			return errors.ScanRowFailed(err)
+		}
+		mediaMd5 := importer.MediaMD5(msgType, content)
+		if mediaMd5 == "" {
+			continue
+		}
+
+		var candidates []string
+		var dir, fileName string
+		if msgType == 3 {
+			candidates = []string{".pic_hd", ".pic"}
+			dir, fileName = "Img", fmt.Sprintf("%d.jpg", localID)
+		} else {
+			candidates = []string{".mp4"}
+			dir, fileName = "Video", fmt.Sprintf("%d.mp4", localID)
+		}
+		for _, ext := range candidates {
+			src, ok := im.backup.File(fmt.Sprintf("Documents/%s/%s/%s/%d%s", im.account, dir, talkerMd5, localID, ext))
+			if !ok {
+				continue
+			}
+			if err := im.w.AddMedia(mediaMd5, src, talkerMd5, dir, fileName); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return rows.Err()
+}
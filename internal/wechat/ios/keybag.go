@@ -0,0 +1,228 @@
+package ios
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/crypto/pbkdf2"
+	"howett.net/plist"
+
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// wrapPasscode 类密钥以备份密码派生的密钥包装
+const wrapPasscode = 2
+
+// keybag 加密备份 Manifest.plist 中的 BackupKeyBag，由备份密码解开各保护等级的类密钥
+// 格式为连续的 4 字节标签、4 字节大端长度与值；第一个 UUID 之后的 UUID 开始一个类密钥
+type keybag struct {
+	salt []byte
+	iter int
+	// iOS 10.2 之后先以 PBKDF2-HMAC-SHA256 处理密码
+	dpsl []byte
+	dpic int
+
+	classes map[uint32]*classKey
+}
+
+type classKey struct {
+	class uint32
+	wrap  uint32
+	wpky  []byte
+	key   []byte
+}
+
+func parseKeybag(data []byte) (*keybag, error) {
+	kb := &keybag{classes: make(map[uint32]*classKey)}
+	var uuid []byte
+	var cur *classKey
+	for len(data) >= 8 {
+		tag := string(data[:4])
+		n := binary.BigEndian.Uint32(data[4:8])
+		if uint64(n) > uint64(len(data)-8) {
+			return nil, fmt.Errorf("keybag tag %s truncated", tag)
+		}
+		value := data[8 : 8+n]
+		data = data[8+n:]
+
+		var num uint32
+		if len(value) == 4 {
+			num = binary.BigEndian.Uint32(value)
+		}
+		switch {
+		case tag == "UUID" && uuid == nil:
+			uuid = value
+		case tag == "UUID":
+			if cur != nil {
+				kb.classes[cur.class] = cur
+			}
+			cur = &classKey{}
+		case cur == nil:
+			switch tag {
+			case "SALT":
+				kb.salt = value
+			case "ITER":
+				kb.iter = int(num)
+			case "DPSL":
+				kb.dpsl = value
+			case "DPIC":
+				kb.dpic = int(num)
+			}
+		default:
+			switch tag {
+			case "CLAS":
+				cur.class = num
+			case "WRAP":
+				cur.wrap = num
+			case "WPKY":
+				cur.wpky = value
+			}
+		}
+	}
+	if cur != nil {
+		kb.classes[cur.class] = cur
+	}
+	if len(kb.salt) == 0 || kb.iter == 0 {
+		return nil, fmt.Errorf("keybag salt not found")
+	}
+	return kb, nil
+}
+
+// unlock 以备份密码解开类密钥，密码错误时返回 ErrBackupPassword
+func (kb *keybag) unlock(password string) error {
+	key := []byte(password)
+	if len(kb.dpsl) > 0 {
+		key = pbkdf2.Key(key, kb.dpsl, kb.dpic, 32, sha256.New)
+	}
+	key = pbkdf2.Key(key, kb.salt, kb.iter, 32, sha1.New)
+	for _, ck := range kb.classes {
+		if len(ck.wpky) == 0 || ck.wrap&wrapPasscode == 0 {
+			continue
+		}
+		k, err := aesUnwrap(key, ck.wpky)
+		if err != nil {
+			return errors.ErrBackupPassword
+		}
+		ck.key = k
+	}
+	return nil
+}
+
+// unwrap 以保护等级的类密钥解开文件密钥，前 4 字节为小端的保护等级
+func (kb *keybag) unwrap(wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 4 {
+		return nil, fmt.Errorf("invalid wrapped key")
+	}
+	class := binary.LittleEndian.Uint32(wrapped[:4])
+	ck, ok := kb.classes[class]
+	if !ok || ck.key == nil {
+		return nil, fmt.Errorf("class key %d not found", class)
+	}
+	return aesUnwrap(ck.key, wrapped[4:])
+}
+
+// aesUnwrapIV RFC 3394 的默认初始值
+var aesUnwrapIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// aesUnwrap RFC 3394 AES 密钥解包，校验值不符时返回错误
+func aesUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, fmt.Errorf("invalid wrapped key length %d", len(wrapped))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(wrapped)/8 - 1
+	a := make([]byte, 8)
+	copy(a, wrapped[:8])
+	r := make([]byte, n*8)
+	copy(r, wrapped[8:])
+
+	buf := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			copy(buf, a)
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(buf[:8])^t)
+			copy(buf[8:], r[(i-1)*8:i*8])
+			block.Decrypt(buf, buf)
+			copy(a, buf[:8])
+			copy(r[(i-1)*8:i*8], buf[8:])
+		}
+	}
+	if subtle.ConstantTimeCompare(a, aesUnwrapIV) != 1 {
+		return nil, fmt.Errorf("key unwrap integrity check failed")
+	}
+	return r, nil
+}
+
+// decryptFile 以 AES-256-CBC、全零 IV 解密备份文件并去除 PKCS#7 填充，size 大于 0 时截取为原始大小
+func decryptFile(key, data []byte, size int64) ([]byte, error) {
+	if len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted file size %d is not a multiple of the block size", len(data))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(out, data)
+	if n := len(out); n > 0 {
+		if pad := int(out[n-1]); pad > 0 && pad <= aes.BlockSize && pad <= n &&
+			bytes.Equal(out[n-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+			out = out[:n-pad]
+		}
+	}
+	if size > 0 && size < int64(len(out)) {
+		out = out[:size]
+	}
+	return out, nil
+}
+
+// fileInfo Manifest.db 中 file 列的 NSKeyedArchiver 数据，包含加密文件的密钥与原始大小
+type fileInfo struct {
+	key  []byte
+	size int64
+}
+
+func parseFileInfo(data []byte) (*fileInfo, error) {
+	var archive struct {
+		Objects []interface{}        `plist:"$objects"`
+		Top     map[string]plist.UID `plist:"$top"`
+	}
+	if _, err := plist.Unmarshal(data, &archive); err != nil {
+		return nil, err
+	}
+	object := func(v interface{}) interface{} {
+		if uid, ok := v.(plist.UID); ok && int(uid) < len(archive.Objects) {
+			return archive.Objects[uid]
+		}
+		return v
+	}
+	root, ok := object(archive.Top["root"]).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("file info root not found")
+	}
+
+	info := &fileInfo{}
+	switch v := object(root["EncryptionKey"]).(type) {
+	case map[string]interface{}:
+		info.key, _ = v["NS.data"].([]byte)
+	case []byte:
+		info.key = v
+	}
+	switch v := root["Size"].(type) {
+	case uint64:
+		info.size = int64(v)
+	case int64:
+		info.size = v
+	}
+	return info, nil
+}
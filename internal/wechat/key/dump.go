@@ -0,0 +1,74 @@
+package key
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/wechat/decrypt"
+	"github.com/sjzar/chatlog/internal/wechat/key/windows"
+)
+
+// DumpPlatform 根据转储文件格式判断平台：Windows minidump 或 dumpmemory 生成的 macOS 内存转储
+func DumpPlatform(data []byte) string {
+	if windows.IsMinidump(data) {
+		return "windows"
+	}
+	return "darwin"
+}
+
+// DumpFilePlatform 读取转储文件头判断平台
+func DumpFilePlatform(dumpFile string) (string, error) {
+	f, err := os.Open(dumpFile)
+	if err != nil {
+		return "", errors.OpenFileFailed(dumpFile, err)
+	}
+	defer f.Close()
+	header := make([]byte, 32)
+	n, _ := io.ReadFull(f, header)
+	return DumpPlatform(header[:n]), nil
+}
+
+// SearchDumpFile 在进程内存转储文件中搜索密钥，使用与进程内提取相同的特征，并用数据库文件验证
+// Windows 需要 minidump 格式的完整转储以解析密钥指针，macOS 使用 dumpmemory 命令生成的转储
+func SearchDumpFile(ctx context.Context, dumpFile string, platform string, version int, validator *decrypt.Validator) (string, error) {
+	data, err := os.ReadFile(dumpFile)
+	if err != nil {
+		return "", errors.ReadFileFailed(dumpFile, err)
+	}
+	if platform == "" {
+		platform = DumpPlatform(data)
+	}
+
+	extractor, err := NewExtractor(platform, version)
+	if err != nil {
+		return "", err
+	}
+	extractor.SetValidate(validator)
+
+	if platform == "windows" {
+		dump, err := windows.ParseMinidump(data)
+		if err != nil {
+			return "", err
+		}
+		switch e := extractor.(type) {
+		case *windows.V3Extractor:
+			return e.SearchDump(ctx, dump)
+		case *windows.V4Extractor:
+			return e.SearchDump(ctx, dump)
+		}
+		return "", errors.PlatformUnsupported(platform, version)
+	}
+
+	if validator == nil {
+		return "", errors.ErrValidatorNotSet
+	}
+	if key, ok := extractor.SearchKey(ctx, data); ok {
+		return key, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.ErrNoValidKey
+}
@@ -0,0 +1,81 @@
+package windows
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/wechat/decrypt"
+)
+
+// 密钥指针前的特征，与进程内搜索使用的特征相同
+var (
+	V3DumpKeyPattern = []byte{0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	V4DumpKeyPattern = []byte{
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x2F, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+)
+
+// SearchDump 在 minidump 中搜索 3.x 版本的密钥
+func (e *V3Extractor) SearchDump(ctx context.Context, dump *Minidump) (string, error) {
+	pattern := V3DumpKeyPattern
+	if !dump.Is64Bit {
+		pattern = pattern[:4]
+	}
+	return searchDump(ctx, dump, pattern, e.validator)
+}
+
+// SearchDump 在 minidump 中搜索 4.0 版本的密钥
+func (e *V4Extractor) SearchDump(ctx context.Context, dump *Minidump) (string, error) {
+	return searchDump(ctx, dump, V4DumpKeyPattern, e.validator)
+}
+
+// searchDump 查找特征前的指针，读取指针指向的 32 字节作为候选密钥，用数据库文件验证
+// 转储中没有内存属性信息，搜索全部内存区域，同一地址只验证一次
+func searchDump(ctx context.Context, dump *Minidump, pattern []byte, validator *decrypt.Validator) (string, error) {
+	if validator == nil {
+		return "", errors.ErrValidatorNotSet
+	}
+	ptrSize := 8
+	readPtr := binary.LittleEndian.Uint64
+	if !dump.Is64Bit {
+		ptrSize = 4
+		readPtr = func(b []byte) uint64 { return uint64(binary.LittleEndian.Uint32(b)) }
+	}
+
+	checked := make(map[uint64]bool)
+	for i := len(dump.Regions) - 1; i >= 0; i-- {
+		memory := dump.Regions[i].Data
+		index := len(memory)
+		for {
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			default:
+			}
+
+			index = bytes.LastIndex(memory[:index], pattern)
+			if index == -1 || index-ptrSize < 0 {
+				break
+			}
+
+			ptrValue := readPtr(memory[index-ptrSize : index])
+			if ptrValue > 0x10000 && ptrValue < 0x7FFFFFFFFFFF && !checked[ptrValue] {
+				checked[ptrValue] = true
+				if keyData := dump.Read(ptrValue, 0x20); keyData != nil && validator.Validate(keyData) {
+					key := hex.EncodeToString(keyData)
+					log.Debug().Msgf("Valid key found at 0x%X", ptrValue)
+					return key, nil
+				}
+			}
+			index -= 1
+		}
+	}
+	return "", errors.ErrNoValidKey
+}
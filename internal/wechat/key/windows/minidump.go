@@ -0,0 +1,132 @@
+package windows
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// Minidump 文件格式，任务管理器"创建转储文件"与 procdump -ma 生成的完整转储包含进程的全部可读内存
+const (
+	MinidumpSignature = 0x504d444d // "MDMP"
+
+	streamMemoryList   = 5
+	streamSystemInfo   = 7
+	streamMemory64List = 9
+
+	archAMD64 = 9
+	archARM64 = 12
+)
+
+// MemoryRegion 转储中的一段内存
+type MemoryRegion struct {
+	Base uint64
+	Data []byte
+}
+
+// Minidump 解析后的转储文件，内存区域直接引用文件内容，不复制
+type Minidump struct {
+	Regions []MemoryRegion
+	Is64Bit bool
+}
+
+// IsMinidump 判断文件内容是否为 Windows minidump 格式
+func IsMinidump(data []byte) bool {
+	return len(data) >= 32 && binary.LittleEndian.Uint32(data) == MinidumpSignature
+}
+
+// ParseMinidump 解析 minidump 中的内存区域，优先使用完整转储的 Memory64List
+func ParseMinidump(data []byte) (*Minidump, error) {
+	if !IsMinidump(data) {
+		return nil, errors.ErrInvalidMinidump
+	}
+	count := binary.LittleEndian.Uint32(data[8:])
+	dirRva := uint64(binary.LittleEndian.Uint32(data[12:]))
+
+	dump := &Minidump{Is64Bit: true}
+	var memoryList, memory64List []byte
+	for i := uint64(0); i < uint64(count); i++ {
+		entry, ok := slice(data, dirRva+i*12, 12)
+		if !ok {
+			return nil, errors.ErrInvalidMinidump
+		}
+		stream, ok := slice(data, uint64(binary.LittleEndian.Uint32(entry[8:])), uint64(binary.LittleEndian.Uint32(entry[4:])))
+		if !ok {
+			continue
+		}
+		switch binary.LittleEndian.Uint32(entry) {
+		case streamMemoryList:
+			memoryList = stream
+		case streamMemory64List:
+			memory64List = stream
+		case streamSystemInfo:
+			if len(stream) >= 2 {
+				arch := binary.LittleEndian.Uint16(stream)
+				dump.Is64Bit = arch == archAMD64 || arch == archARM64
+			}
+		}
+	}
+
+	switch {
+	case memory64List != nil:
+		if len(memory64List) < 16 {
+			return nil, errors.ErrInvalidMinidump
+		}
+		n := binary.LittleEndian.Uint64(memory64List)
+		rva := binary.LittleEndian.Uint64(memory64List[8:])
+		for i := uint64(0); i < n; i++ {
+			desc, ok := slice(memory64List, 16+i*16, 16)
+			if !ok {
+				break
+			}
+			base, size := binary.LittleEndian.Uint64(desc), binary.LittleEndian.Uint64(desc[8:])
+			if region, ok := slice(data, rva, size); ok {
+				dump.Regions = append(dump.Regions, MemoryRegion{Base: base, Data: region})
+			}
+			rva += size
+		}
+	case memoryList != nil:
+		if len(memoryList) < 4 {
+			return nil, errors.ErrInvalidMinidump
+		}
+		n := uint64(binary.LittleEndian.Uint32(memoryList))
+		for i := uint64(0); i < n; i++ {
+			desc, ok := slice(memoryList, 4+i*16, 16)
+			if !ok {
+				break
+			}
+			base := binary.LittleEndian.Uint64(desc)
+			size, rva := uint64(binary.LittleEndian.Uint32(desc[8:])), uint64(binary.LittleEndian.Uint32(desc[12:]))
+			if region, ok := slice(data, rva, size); ok {
+				dump.Regions = append(dump.Regions, MemoryRegion{Base: base, Data: region})
+			}
+		}
+	}
+	if len(dump.Regions) == 0 {
+		return nil, errors.ErrNoMemoryRegionsFound
+	}
+	sort.Slice(dump.Regions, func(i, j int) bool { return dump.Regions[i].Base < dump.Regions[j].Base })
+	return dump, nil
+}
+
+// Read 读取转储中 addr 处的 n 个字节，地址不在转储中时返回 nil
+func (d *Minidump) Read(addr uint64, n int) []byte {
+	i := sort.Search(len(d.Regions), func(i int) bool { return d.Regions[i].Base > addr }) - 1
+	if i < 0 {
+		return nil
+	}
+	region := d.Regions[i]
+	ret, ok := slice(region.Data, addr-region.Base, uint64(n))
+	if !ok {
+		return nil
+	}
+	return ret
+}
+
+func slice(data []byte, offset, size uint64) ([]byte, bool) {
+	if offset > uint64(len(data)) || size > uint64(len(data))-offset {
+		return nil, false
+	}
+	return data[offset : offset+size], true
+}
@@ -3,8 +3,10 @@ package darwinv3
 import (
 	"context"
 	"crypto/md5"
+	"database/sql"
 	"encoding/hex"
 	"fmt"
+	"math"
 	"regexp"
 	"sort"
 	"strings"
@@ -64,10 +66,11 @@ type DataSource struct {
 	user2DisplayName map[string]string
 }
 
-func New(path string) (*DataSource, error) {
+// key 为工作目录的加密密钥，未加密时为 nil
+func New(path string, key []byte) (*DataSource, error) {
 	ds := &DataSource{
 		path:             path,
-		dbm:              dbm.NewDBManager(path),
+		dbm:              dbm.NewDBManager(path, key),
 		talkerDBMap:      make(map[string]string),
 		user2DisplayName: make(map[string]string),
 	}
@@ -155,6 +158,8 @@ func (ds *DataSource) initMessageDbs() error {
 		}
 		rows.Close()
 	}
+	// 索引信息读取完成后关闭消息分片，查询时按需打开
+	ds.dbm.ReleaseGroup(Message)
 	ds.talkerDBMap = talkerDBMap
 	return nil
 }
@@ -191,38 +196,85 @@ func (ds *DataSource) initChatRoomDb() error {
 	return nil
 }
 
-func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
+func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, msgType string, desc bool, limit, offset int) ([]*model.Message, error) {
+	filteredMessages := []*model.Message{}
+	err := ds.IterMessages(ctx, startTime, endTime, talker, sender, keyword, msgType, desc, func(message *model.Message) error {
+		filteredMessages = append(filteredMessages, message)
+		// 已经获取了足够的消息，可以提前结束
+		if limit > 0 && len(filteredMessages) >= offset+limit {
+			return errors.ErrIterStop
+		}
+		return nil
+	})
+	if err != nil && err != errors.ErrIterStop {
+		return nil, err
+	}
+
+	// 对所有消息按时间排序，darwinv3 的消息序号由时间戳生成，不同 talker 之间也可比较
+	sort.Slice(filteredMessages, func(i, j int) bool {
+		if desc {
+			return filteredMessages[i].Seq > filteredMessages[j].Seq
+		}
+		return filteredMessages[i].Seq < filteredMessages[j].Seq
+	})
+
+	// 处理分页
+	if limit > 0 {
+		if offset >= len(filteredMessages) {
+			return []*model.Message{}, nil
+		}
+		end := offset + limit
+		if end > len(filteredMessages) {
+			end = len(filteredMessages)
+		}
+		return filteredMessages[offset:end], nil
+	}
+
+	return filteredMessages, nil
+}
+
+// IterMessages 逐条读取符合条件的消息并调用 fn，不在内存中保留结果
+// 单个 talker 时按时间顺序回调，多个 talker 时按 talker 分组回调；fn 返回错误时停止读取并返回该错误
+func (ds *DataSource) IterMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, msgType string, desc bool, fn func(*model.Message) error) error {
 	if talker == "" {
-		return nil, errors.ErrTalkerEmpty
+		return errors.ErrTalkerEmpty
 	}
 
 	// 解析talker参数，支持多个talker（以英文逗号分隔）
 	talkers := util.Str2List(talker, ",")
 	if len(talkers) == 0 {
-		return nil, errors.ErrTalkerEmpty
+		return errors.ErrTalkerEmpty
 	}
 
 	// 解析sender参数，支持多个发送者（以英文逗号分隔）
 	senders := util.Str2List(sender, ",")
 
+	// 解析消息类型
+	types, err := model.ParseMessageTypes(msgType)
+	if err != nil {
+		return errors.InvalidArg("type")
+	}
+
+	sortOrder := "ASC"
+	if desc {
+		sortOrder = "DESC"
+	}
+
 	// 预编译正则表达式（如果有keyword）
 	var regex *regexp.Regexp
 	if keyword != "" {
 		var err error
 		regex, err = regexp.Compile(keyword)
 		if err != nil {
-			return nil, errors.QueryFailed("invalid regex pattern", err)
+			return errors.QueryFailed("invalid regex pattern", err)
 		}
 	}
 
-	// 从每个相关数据库中查询消息，并在读取时进行过滤
-	filteredMessages := []*model.Message{}
-
 	// 对每个talker进行查询
 	for _, talkerItem := range talkers {
 		// 检查上下文是否已取消
 		if err := ctx.Err(); err != nil {
-			return nil, err
+			return err
 		}
 
 		// 在 darwinv3 中，需要先找到对应的数据库
@@ -234,8 +286,7 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 			continue
 		}
 
-		db, err := ds.dbm.OpenDB(dbPath)
-		if err != nil {
+		if _, err := ds.dbm.OpenDB(dbPath); err != nil {
 			log.Error().Msgf("数据库 %s 未打开", dbPath)
 			continue
 		}
@@ -243,15 +294,16 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 		tableName := fmt.Sprintf("Chat_%s", talkerMd5)
 
 		// 构建查询条件
+		// 消息序号依赖同一秒内的消息顺序，消息类型不在查询中过滤
 		query := fmt.Sprintf(`
 			SELECT msgCreateTime, msgContent, messageType, mesDes
 			FROM %s 
 			WHERE msgCreateTime >= ? AND msgCreateTime <= ? 
-			ORDER BY msgCreateTime ASC
-		`, tableName)
+			ORDER BY msgCreateTime %s, mesLocalID ASC
+		`, tableName, sortOrder)
 
-		// 执行查询
-		rows, err := db.QueryContext(ctx, query, startTime.Unix(), endTime.Unix())
+		// 执行查询，相同会话的查询复用预编译语句
+		stmt, err := ds.dbm.Stmt(dbPath, query)
 		if err != nil {
 			// 如果表不存在，跳过此talker
 			if strings.Contains(err.Error(), "no such table") {
@@ -260,8 +312,14 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 			log.Err(err).Msgf("从数据库 %s 查询消息失败", dbPath)
 			continue
 		}
+		rows, err := stmt.QueryContext(ctx, startTime.Unix(), endTime.Unix())
+		if err != nil {
+			log.Err(err).Msgf("从数据库 %s 查询消息失败", dbPath)
+			continue
+		}
 
 		// 处理查询结果，在读取时进行过滤
+		seq := newSeqCounter()
 		for rows.Next() {
 			var msg model.MessageDarwinV3
 			err := rows.Scan(
@@ -278,6 +336,12 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 
 			// 将消息包装为通用模型
 			message := msg.Wrap(talkerItem)
+			message.Seq = seq.next(msg.MsgCreateTime)
+
+			// 应用消息类型过滤
+			if !message.MatchTypes(types) {
+				continue
+			}
 
 			// 应用sender过滤
 			if len(senders) > 0 {
@@ -301,52 +365,17 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 				}
 			}
 
-			// 通过所有过滤条件，保留此消息
-			filteredMessages = append(filteredMessages, message)
-
-			// 检查是否已经满足分页处理数量
-			if limit > 0 && len(filteredMessages) >= offset+limit {
-				// 已经获取了足够的消息，可以提前返回
+			// 通过所有过滤条件，交给回调处理
+			if err := fn(message); err != nil {
 				rows.Close()
-
-				// 对所有消息按时间排序
-				sort.Slice(filteredMessages, func(i, j int) bool {
-					return filteredMessages[i].Seq < filteredMessages[j].Seq
-				})
-
-				// 处理分页
-				if offset >= len(filteredMessages) {
-					return []*model.Message{}, nil
-				}
-				end := offset + limit
-				if end > len(filteredMessages) {
-					end = len(filteredMessages)
-				}
-				return filteredMessages[offset:end], nil
+				return err
 			}
 		}
 		rows.Close()
 	}
 
-	// 对所有消息按时间排序
-	// FIXME 不同 talker 需要使用 Time 排序
-	sort.Slice(filteredMessages, func(i, j int) bool {
-		return filteredMessages[i].Time.Before(filteredMessages[j].Time)
-	})
-
-	// 处理分页
-	if limit > 0 {
-		if offset >= len(filteredMessages) {
-			return []*model.Message{}, nil
-		}
-		end := offset + limit
-		if end > len(filteredMessages) {
-			end = len(filteredMessages)
-		}
-		return filteredMessages[offset:end], nil
-	}
-
-	return filteredMessages, nil
+	// 查询被取消时结果不完整，不能当作正常结束
+	return ctx.Err()
 }
 
 // 从表名中提取 talker
@@ -370,31 +399,29 @@ func (ds *DataSource) GetContacts(ctx context.Context, key string, limit, offset
 
 	if key != "" {
 		// 按照关键字查询
-		query = `SELECT IFNULL(m_nsUsrName,""), IFNULL(nickname,""), IFNULL(m_nsRemark,""), m_uiSex, IFNULL(m_nsAliasName,"") 
+		query = `SELECT IFNULL(m_nsUsrName,""), IFNULL(nickname,""), IFNULL(m_nsRemark,""), m_uiSex, IFNULL(m_nsAliasName,""), IFNULL(m_nsHeadImgUrl,"") 
 				FROM WCContact 
 				WHERE m_nsUsrName = ? OR nickname = ? OR m_nsRemark = ? OR m_nsAliasName = ?`
 		args = []interface{}{key, key, key, key}
 	} else {
 		// 查询所有联系人
-		query = `SELECT IFNULL(m_nsUsrName,""), IFNULL(nickname,""), IFNULL(m_nsRemark,""), m_uiSex, IFNULL(m_nsAliasName,"") 
+		query = `SELECT IFNULL(m_nsUsrName,""), IFNULL(nickname,""), IFNULL(m_nsRemark,""), m_uiSex, IFNULL(m_nsAliasName,""), IFNULL(m_nsHeadImgUrl,"") 
 				FROM WCContact`
 	}
 
-	// 添加排序、分页
+	// 添加排序、分页，分页参数化以便复用预编译语句
 	query += ` ORDER BY m_nsUsrName`
 	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", limit)
-		if offset > 0 {
-			query += fmt.Sprintf(" OFFSET %d", offset)
-		}
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, limit, max(offset, 0))
 	}
 
 	// 执行查询
-	db, err := ds.dbm.GetDB(Contact)
+	stmt, err := ds.dbm.GroupStmt(Contact, query)
 	if err != nil {
 		return nil, err
 	}
-	rows, err := db.QueryContext(ctx, query, args...)
+	rows, err := stmt.QueryContext(ctx, args...)
 	if err != nil {
 		return nil, errors.QueryFailed(query, err)
 	}
@@ -409,6 +436,7 @@ func (ds *DataSource) GetContacts(ctx context.Context, key string, limit, offset
 			&contactDarwinV3.M_nsRemark,
 			&contactDarwinV3.M_uiSex,
 			&contactDarwinV3.M_nsAliasName,
+			&contactDarwinV3.M_nsHeadImgUrl,
 		)
 
 		if err != nil {
@@ -648,7 +676,300 @@ WHERE
 	return media, nil
 }
 
+// PurgeTalker 清空会话的消息表，并删除联系人、群聊与最近会话记录
+func (ds *DataSource) PurgeTalker(ctx context.Context, talker string) (int, error) {
+	if talker == "" {
+		return 0, errors.ErrTalkerEmpty
+	}
+	_talkerMd5Bytes := md5.Sum([]byte(talker))
+	talkerMd5 := hex.EncodeToString(_talkerMd5Bytes[:])
+
+	count := 0
+	if dbPath, ok := ds.talkerDBMap[talkerMd5]; ok {
+		n, err := ds.dbm.Exec(ctx, dbPath, fmt.Sprintf("DELETE FROM Chat_%s", talkerMd5))
+		if err != nil {
+			return 0, err
+		}
+		count = int(n)
+	}
+	for _, item := range []struct{ group, query string }{
+		{Contact, "DELETE FROM WCContact WHERE m_nsUsrName = ?"},
+		{ChatRoom, "DELETE FROM GroupContact WHERE m_nsUsrName = ?"},
+		{Session, "DELETE FROM SessionAbstract WHERE m_nsUserName = ?"},
+	} {
+		if _, err := ds.dbm.ExecGroup(ctx, item.group, item.query, talker); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// BuildIndexes 为每个会话的消息表建立按时间查询的索引，返回建立索引的表数量
+// 消息查询按 msgCreateTime 范围过滤并排序，微信原有的表结构中没有对应的索引
+func (ds *DataSource) BuildIndexes(ctx context.Context) (int, error) {
+	dbPaths, err := ds.dbm.GetDBPath(Message)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, dbPath := range dbPaths {
+		tables, err := ds.dbm.Tables(dbPath, "Chat_%")
+		if err != nil {
+			return total, err
+		}
+		queries := make([]string, 0, len(tables)+1)
+		for _, table := range tables {
+			if extractTalkerFromTableName(table) == "" {
+				continue
+			}
+			queries = append(queries, fmt.Sprintf("CREATE INDEX IF NOT EXISTS chatlog_%s_time ON %s(msgCreateTime, mesLocalID)", table, table))
+		}
+		queries = append(queries, "ANALYZE")
+		if err := ds.dbm.ExecAll(ctx, dbPath, queries); err != nil {
+			return total, err
+		}
+		total += len(queries) - 1
+	}
+	return total, nil
+}
+
 // Close 实现关闭数据库连接的方法
 func (ds *DataSource) Close() error {
 	return ds.dbm.Close()
 }
+
+// CountMessages 统计时间范围内的消息数量，talker 为空时统计所有会话
+func (ds *DataSource) CountMessages(ctx context.Context, startTime, endTime time.Time, talker string) (int, error) {
+	talkerMd5s := make([]string, 0)
+	if talkers := util.Str2List(talker, ","); len(talkers) > 0 {
+		for _, talkerItem := range talkers {
+			_talkerMd5Bytes := md5.Sum([]byte(talkerItem))
+			talkerMd5s = append(talkerMd5s, hex.EncodeToString(_talkerMd5Bytes[:]))
+		}
+	} else {
+		for talkerMd5 := range ds.talkerDBMap {
+			talkerMd5s = append(talkerMd5s, talkerMd5)
+		}
+	}
+
+	total := 0
+	for _, talkerMd5 := range talkerMd5s {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		dbPath, ok := ds.talkerDBMap[talkerMd5]
+		if !ok {
+			continue
+		}
+		db, err := ds.dbm.OpenDB(dbPath)
+		if err != nil {
+			log.Error().Msgf("数据库 %s 未打开", dbPath)
+			continue
+		}
+
+		query := fmt.Sprintf("SELECT COUNT(*) FROM Chat_%s WHERE msgCreateTime >= ? AND msgCreateTime <= ?", talkerMd5)
+		var count int
+		if err := db.QueryRowContext(ctx, query, startTime.Unix(), endTime.Unix()).Scan(&count); err != nil {
+			if strings.Contains(err.Error(), "no such table") {
+				continue
+			}
+			return 0, errors.QueryFailed(query, err)
+		}
+		total += count
+	}
+
+	return total, nil
+}
+
+// DeleteMessages 从工作目录中删除时间范围内的消息，talker 为空时处理所有会话
+func (ds *DataSource) DeleteMessages(ctx context.Context, startTime, endTime time.Time, talker string) (int, error) {
+	talkerMd5s := make([]string, 0)
+	if talkers := util.Str2List(talker, ","); len(talkers) > 0 {
+		for _, talkerItem := range talkers {
+			_talkerMd5Bytes := md5.Sum([]byte(talkerItem))
+			talkerMd5s = append(talkerMd5s, hex.EncodeToString(_talkerMd5Bytes[:]))
+		}
+	} else {
+		for talkerMd5 := range ds.talkerDBMap {
+			talkerMd5s = append(talkerMd5s, talkerMd5)
+		}
+	}
+
+	total := 0
+	for _, talkerMd5 := range talkerMd5s {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		dbPath, ok := ds.talkerDBMap[talkerMd5]
+		if !ok {
+			continue
+		}
+		query := fmt.Sprintf("DELETE FROM Chat_%s WHERE msgCreateTime >= ? AND msgCreateTime <= ?", talkerMd5)
+		n, err := ds.dbm.Exec(ctx, dbPath, query, startTime.Unix(), endTime.Unix())
+		if err != nil {
+			if strings.Contains(err.Error(), "no such table") {
+				continue
+			}
+			return total, err
+		}
+		total += int(n)
+	}
+
+	return total, nil
+}
+
+// CountSessions 统计最近会话数量
+func (ds *DataSource) CountSessions(ctx context.Context) (int, error) {
+	query := `SELECT COUNT(*) FROM SessionAbstract`
+
+	db, err := ds.dbm.GetDB(Session)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	if err := db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return 0, errors.QueryFailed(query, err)
+	}
+	return count, nil
+}
+
+// seqCounter 为消息生成 10 位时间戳 + 3 位序号的消息序号
+// darwinv3 的消息表没有全局序号，同一秒内按 mesLocalID 顺序编号
+type seqCounter struct {
+	last  int64
+	index int64
+}
+
+func newSeqCounter() *seqCounter {
+	return &seqCounter{last: -1}
+}
+
+func (c *seqCounter) next(createTime int64) int64 {
+	if createTime != c.last {
+		c.last = createTime
+		c.index = 0
+	} else {
+		c.index++
+	}
+	return createTime*1000 + c.index
+}
+
+// GetMessage 按消息序号获取单条消息，序号的前 10 位为消息创建时间
+func (ds *DataSource) GetMessage(ctx context.Context, talker string, seq int64) (*model.Message, error) {
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+
+	_talkerMd5Bytes := md5.Sum([]byte(talker))
+	talkerMd5 := hex.EncodeToString(_talkerMd5Bytes[:])
+	dbPath, ok := ds.talkerDBMap[talkerMd5]
+	if !ok {
+		return nil, errors.TalkerNotFound(talker)
+	}
+
+	db, err := ds.dbm.OpenDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT msgCreateTime, msgContent, messageType, mesDes
+		FROM Chat_%s
+		WHERE msgCreateTime = ?
+		ORDER BY mesLocalID ASC
+		LIMIT 1 OFFSET ?
+	`, talkerMd5)
+
+	var msg model.MessageDarwinV3
+	err = db.QueryRowContext(ctx, query, seq/1000, seq%1000).Scan(
+		&msg.MsgCreateTime,
+		&msg.MsgContent,
+		&msg.MessageType,
+		&msg.MesDes,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.MessageNotFound(talker, seq)
+		}
+		return nil, errors.QueryFailed(query, err)
+	}
+
+	message := msg.Wrap(talker)
+	message.Seq = seq
+	return message, nil
+}
+
+// GetAdjacentMessages 获取与指定消息相邻的消息
+// 消息序号依赖同一秒内的消息顺序，先按创建时间找到第 limit 条相邻消息所在的秒，再读取该时间段并编号
+func (ds *DataSource) GetAdjacentMessages(ctx context.Context, talker string, seq int64, desc bool, limit int) ([]*model.Message, error) {
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+	messages := make([]*model.Message, 0, limit)
+	if limit <= 0 {
+		return messages, nil
+	}
+
+	_talkerMd5Bytes := md5.Sum([]byte(talker))
+	talkerMd5 := hex.EncodeToString(_talkerMd5Bytes[:])
+	dbPath, ok := ds.talkerDBMap[talkerMd5]
+	if !ok {
+		return nil, errors.TalkerNotFound(talker)
+	}
+	db, err := ds.dbm.OpenDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	createTime := seq / 1000
+	op, sortOrder := ">", "ASC"
+	if desc {
+		op, sortOrder = "<", "DESC"
+	}
+	query := fmt.Sprintf(`
+		SELECT msgCreateTime
+		FROM Chat_%s
+		WHERE msgCreateTime %s ?
+		ORDER BY msgCreateTime %s
+		LIMIT 1 OFFSET ?
+	`, talkerMd5, op, sortOrder)
+
+	// 相邻消息不足 limit 条时读取到会话的开头或结尾
+	start, end := time.Unix(createTime, 0), time.Unix(math.MaxInt32, 0)
+	if desc {
+		start, end = time.Unix(0, 0), time.Unix(createTime, 0)
+	}
+	var boundary int64
+	err = db.QueryRowContext(ctx, query, createTime, limit-1).Scan(&boundary)
+	switch {
+	case err == nil && desc:
+		start = time.Unix(boundary, 0)
+	case err == nil:
+		end = time.Unix(boundary, 0)
+	case err != sql.ErrNoRows:
+		return nil, errors.QueryFailed(query, err)
+	}
+
+	err = ds.IterMessages(ctx, start, end, talker, "", "", "", false, func(m *model.Message) error {
+		if desc && m.Seq < seq || !desc && m.Seq > seq {
+			messages = append(messages, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if desc {
+		if len(messages) > limit {
+			messages = messages[len(messages)-limit:]
+		}
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	} else if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
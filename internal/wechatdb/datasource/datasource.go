@@ -9,6 +9,7 @@ import (
 	"github.com/sjzar/chatlog/internal/errors"
 	"github.com/sjzar/chatlog/internal/model"
 	"github.com/sjzar/chatlog/internal/wechatdb/datasource/darwinv3"
+	"github.com/sjzar/chatlog/internal/wechatdb/datasource/dbm"
 	v4 "github.com/sjzar/chatlog/internal/wechatdb/datasource/v4"
 	"github.com/sjzar/chatlog/internal/wechatdb/datasource/windowsv3"
 )
@@ -16,7 +17,22 @@ import (
 type DataSource interface {
 
 	// 消息
-	GetMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error)
+	GetMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, msgType string, desc bool, limit, offset int) ([]*model.Message, error)
+
+	// 逐条读取消息，不在内存中保留结果；fn 返回 errors.ErrIterStop 时提前结束
+	IterMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, msgType string, desc bool, fn func(*model.Message) error) error
+
+	// 单条消息，seq 为消息序号
+	GetMessage(ctx context.Context, talker string, seq int64) (*model.Message, error)
+
+	// 与指定消息相邻的消息，desc 为 true 时返回序号小于 seq 的最近 limit 条（按序号倒序），否则返回序号大于 seq 的最近 limit 条（按序号顺序）
+	GetAdjacentMessages(ctx context.Context, talker string, seq int64, desc bool, limit int) ([]*model.Message, error)
+
+	// 消息数量，talker 为空时统计所有会话
+	CountMessages(ctx context.Context, startTime, endTime time.Time, talker string) (int, error)
+
+	// 从工作目录中删除时间范围内的消息，talker 为空时处理所有会话，返回删除的消息数量
+	DeleteMessages(ctx context.Context, startTime, endTime time.Time, talker string) (int, error)
 
 	// 联系人
 	GetContacts(ctx context.Context, key string, limit, offset int) ([]*model.Contact, error)
@@ -27,25 +43,51 @@ type DataSource interface {
 	// 最近会话
 	GetSessions(ctx context.Context, key string, limit, offset int) ([]*model.Session, error)
 
+	// 最近会话数量
+	CountSessions(ctx context.Context) (int, error)
+
 	// 媒体
 	GetMedia(ctx context.Context, _type string, key string) (*model.Media, error)
 
+	// 从工作目录中删除会话的消息、联系人或群聊与最近会话记录，返回删除的消息数量
+	PurgeTalker(ctx context.Context, talker string) (int, error)
+
+	// 在工作目录的消息数据库中建立辅助索引，返回建立索引的表数量
+	BuildIndexes(ctx context.Context) (int, error)
+
 	// 设置回调函数
 	SetCallback(name string, callback func(event fsnotify.Event) error) error
 
 	Close() error
 }
 
-func New(path string, platform string, version int) (DataSource, error) {
+// MessageGroup 各平台消息分库所在的分组
+const MessageGroup = "message"
+
+// Groups 返回平台与版本对应的数据库分组，如 message、contact、session
+func Groups(platform string, version int) ([]*dbm.Group, error) {
+	switch {
+	case platform == "windows" && version == 3:
+		return windowsv3.Groups, nil
+	case (platform == "windows" || platform == "darwin") && version == 4:
+		return v4.Groups, nil
+	case platform == "darwin" && version == 3:
+		return darwinv3.Groups, nil
+	default:
+		return nil, errors.PlatformUnsupported(platform, version)
+	}
+}
+
+func New(path string, platform string, version int, key []byte) (DataSource, error) {
 	switch {
 	case platform == "windows" && version == 3:
-		return windowsv3.New(path)
+		return windowsv3.New(path, key)
 	case platform == "windows" && version == 4:
-		return v4.New(path)
+		return v4.New(path, key)
 	case platform == "darwin" && version == 3:
-		return darwinv3.New(path)
+		return darwinv3.New(path, key)
 	case platform == "darwin" && version == 4:
-		return v4.New(path)
+		return v4.New(path, key)
 	default:
 		return nil, errors.PlatformUnsupported(platform, version)
 	}
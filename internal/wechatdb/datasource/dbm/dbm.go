@@ -1,36 +1,74 @@
 package dbm
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/rs/zerolog/log"
+	"github.com/shirou/gopsutil/v4/process"
 
 	"github.com/sjzar/chatlog/internal/errors"
 	"github.com/sjzar/chatlog/pkg/filecopy"
+	"github.com/sjzar/chatlog/pkg/filecrypt"
 	"github.com/sjzar/chatlog/pkg/filemonitor"
 )
 
+const (
+	// maxOpenConns 每个数据库的连接池大小，Web、MCP 与导出请求可以并发读取同一个数据库
+	maxOpenConns = 8
+	// connMaxIdleTime 空闲连接保留时间
+	connMaxIdleTime = 5 * time.Minute
+	// busyTimeout 数据库被修改时等待的毫秒数，避免直接返回 database is locked
+	busyTimeout = 5000
+	// idleTimeout 数据库超过该时间未被查询时关闭，下次查询时重新打开，减少消息分片较多时占用的内存与文件句柄
+	idleTimeout = 10 * time.Minute
+	// tempPrefix 解密临时目录的前缀，目录名中包含创建者的进程号
+	tempPrefix = "chatlog-"
+)
+
+var cleanTempOnce sync.Once
+
 type DBManager struct {
 	path    string
 	fm      *filemonitor.FileMonitor
 	fgs     map[string]*filemonitor.FileGroup
 	dbs     map[string]*sql.DB
 	dbPaths map[string][]string
+	stmts   map[string]map[string]*cachedStmt
+	used    map[string]*atomic.Int64
 	mutex   sync.RWMutex
+	stop    chan struct{}
+
+	// 工作目录加密时，数据库解密到仅当前用户可访问的临时目录后打开，关闭时删除
+	key     []byte
+	tempDir string
+	temps   map[string]string
 }
 
-func NewDBManager(path string) *DBManager {
+// NewDBManager key 为工作目录的加密密钥，未加密时为 nil
+func NewDBManager(path string, key []byte) *DBManager {
+	cleanTempOnce.Do(cleanStaleTemp)
 	return &DBManager{
 		path:    path,
 		fm:      filemonitor.NewFileMonitor(),
 		fgs:     make(map[string]*filemonitor.FileGroup),
 		dbs:     make(map[string]*sql.DB),
 		dbPaths: make(map[string][]string),
+		stmts:   make(map[string]map[string]*cachedStmt),
+		used:    make(map[string]*atomic.Int64),
+		key:     key,
+		temps:   make(map[string]string),
 	}
 }
 
@@ -111,60 +149,328 @@ func (d *DBManager) GetDBPath(name string) ([]string, error) {
 func (d *DBManager) OpenDB(path string) (*sql.DB, error) {
 	d.mutex.RLock()
 	db, ok := d.dbs[path]
+	if ok {
+		d.touch(path)
+	}
 	d.mutex.RUnlock()
 	if ok {
 		return db, nil
 	}
 	var err error
 	tempPath := path
-	if runtime.GOOS == "windows" {
+	if d.key != nil && filecrypt.IsEncryptedFile(path) {
+		tempPath, err = d.decryptTemp(path)
+		if err != nil {
+			log.Err(err).Msgf("解密数据库 %s 失败", path)
+			return nil, err
+		}
+	} else if runtime.GOOS == "windows" {
 		tempPath, err = filecopy.GetTempCopy(path)
 		if err != nil {
 			log.Err(err).Msgf("获取临时拷贝文件 %s 失败", path)
 			return nil, err
 		}
 	}
-	db, err = sql.Open("sqlite3", tempPath)
+	if tempPath == path {
+		enableWAL(path)
+	}
+	// 查询连接只读，修改统一通过 Exec 进行
+	db, err = sql.Open("sqlite3", fmt.Sprintf("%s?_query_only=1&_busy_timeout=%d", tempPath, busyTimeout))
 	if err != nil {
 		log.Err(err).Msgf("连接数据库 %s 失败", path)
 		return nil, err
 	}
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(maxOpenConns)
+	db.SetConnMaxIdleTime(connMaxIdleTime)
 	d.mutex.Lock()
 	d.dbs[path] = db
+	used := &atomic.Int64{}
+	used.Store(time.Now().UnixNano())
+	d.used[path] = used
+	if tempPath != path && d.key != nil {
+		d.temps[path] = tempPath
+	}
 	d.mutex.Unlock()
 	return db, nil
 }
 
+// enableWAL 将工作目录中的数据库切换为 WAL 模式，读取与 Exec 的修改互不阻塞
+// 切换失败（如目录只读）时保持原有模式，不影响读取
+func enableWAL(path string) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?_journal_mode=WAL&_busy_timeout=%d", path, busyTimeout))
+	if err != nil {
+		return
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		log.Debug().Err(err).Msgf("数据库 %s 切换 WAL 模式失败", path)
+	}
+}
+
+// decryptTemp 将加密的数据库解密到临时目录，每次打开使用新的文件，避免覆盖仍在使用的旧文件
+func (d *DBManager) decryptTemp(path string) (string, error) {
+	d.mutex.Lock()
+	if d.tempDir == "" {
+		dir, err := os.MkdirTemp("", fmt.Sprintf("%s%d-", tempPrefix, os.Getpid()))
+		if err != nil {
+			d.mutex.Unlock()
+			return "", err
+		}
+		d.tempDir = dir
+	}
+	dir := d.tempDir
+	d.mutex.Unlock()
+
+	f, err := os.CreateTemp(dir, "*-"+filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+	tempPath := f.Name()
+	f.Close()
+	if err := filecrypt.DecryptFile(path, tempPath, d.key); err != nil {
+		os.Remove(tempPath)
+		return "", err
+	}
+	return tempPath, nil
+}
+
+// cleanStaleTemp 删除异常退出时残留的解密临时目录，创建目录的进程仍在运行时保留
+func cleanStaleTemp() {
+	dirs, _ := filepath.Glob(filepath.Join(os.TempDir(), tempPrefix+"*"))
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(strings.SplitN(strings.TrimPrefix(filepath.Base(dir), tempPrefix), "-", 2)[0])
+		if err == nil && pid != os.Getpid() {
+			if ok, _ := process.PidExists(int32(pid)); ok {
+				continue
+			}
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			log.Debug().Err(err).Msgf("删除临时目录 %s 失败", dir)
+		}
+	}
+}
+
 func (d *DBManager) Callback(event fsnotify.Event) error {
 	if !event.Op.Has(fsnotify.Create) {
 		return nil
 	}
 
+	d.release(event.Name)
+	return nil
+}
+
+// release 丢弃已打开的连接，延迟关闭以等待进行中的查询，下次访问时重新打开
+func (d *DBManager) release(path string) {
 	d.mutex.Lock()
-	db, ok := d.dbs[event.Name]
-	if ok {
-		delete(d.dbs, event.Name)
-		go func(db *sql.DB) {
-			time.Sleep(time.Second * 5)
-			db.Close()
-		}(db)
+	defer d.mutex.Unlock()
+	db, ok := d.dbs[path]
+	if !ok {
+		return
 	}
-	d.mutex.Unlock()
+	delete(d.dbs, path)
+	delete(d.used, path)
+	stmts := d.stmts[path]
+	delete(d.stmts, path)
+	tempPath := d.temps[path]
+	delete(d.temps, path)
+	go func(db *sql.DB) {
+		time.Sleep(time.Second * 5)
+		closeStmts(stmts)
+		db.Close()
+		if tempPath != "" {
+			os.Remove(tempPath)
+		}
+	}(db)
+}
+
+// touch 记录数据库最近一次使用的时间，调用方需持有锁
+func (d *DBManager) touch(path string) {
+	if used, ok := d.used[path]; ok {
+		used.Store(time.Now().UnixNano())
+	}
+}
 
+// ReleaseGroup 关闭分组内已打开的数据库，用于启动时读取完索引信息后释放消息分片，首次查询时再打开
+func (d *DBManager) ReleaseGroup(name string) {
+	d.mutex.RLock()
+	dbPaths := d.dbPaths[name]
+	d.mutex.RUnlock()
+	for _, path := range dbPaths {
+		d.release(path)
+	}
+}
+
+// closeIdle 定期关闭超过 idleTimeout 未使用的数据库
+func (d *DBManager) closeIdle(stop <-chan struct{}) {
+	ticker := time.NewTicker(idleTimeout / 10)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		deadline := time.Now().Add(-idleTimeout).UnixNano()
+		idle := make([]string, 0)
+		d.mutex.RLock()
+		for path, used := range d.used {
+			if used.Load() < deadline {
+				idle = append(idle, path)
+			}
+		}
+		d.mutex.RUnlock()
+		for _, path := range idle {
+			log.Debug().Msgf("关闭空闲数据库 %s", path)
+			d.release(path)
+		}
+	}
+}
+
+// Exec 直接修改工作目录中的数据库文件，返回影响的行数
+// 查询使用的连接可能指向临时拷贝，修改后丢弃以读取新数据；加密的工作目录不支持修改
+// 删除的内容会被覆盖，不会残留在数据库文件的空闲页中
+func (d *DBManager) Exec(ctx context.Context, path string, query string, args ...interface{}) (int64, error) {
+	if d.key != nil {
+		return 0, errors.ErrWorkDirEncrypted
+	}
+	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?_secure_delete=on&_busy_timeout=%d", path, busyTimeout))
+	if err != nil {
+		return 0, errors.DBConnectFailed(path, err)
+	}
+	defer db.Close()
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, errors.QueryFailed(query, err)
+	}
+	// 将 WAL 中的修改写回数据库文件并清空 WAL，避免数据库文件被重新解密覆盖后残留的 WAL 被误用
+	if _, err := db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		log.Debug().Err(err).Msgf("数据库 %s 执行 checkpoint 失败", path)
+	}
+	d.release(path)
+	return result.RowsAffected()
+}
+
+// Tables 返回数据库中名称匹配 LIKE 模式的表
+func (d *DBManager) Tables(path string, pattern string) ([]string, error) {
+	db, err := d.OpenDB(path)
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT name FROM sqlite_master WHERE type='table' AND name LIKE ?"
+	rows, err := db.Query(query, pattern)
+	if err != nil {
+		return nil, errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+	tables := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, errors.ScanRowFailed(err)
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// ExecAll 在同一连接与事务中依次执行多条修改语句，用于建立索引等批量维护操作，加密的工作目录不支持修改
+func (d *DBManager) ExecAll(ctx context.Context, path string, queries []string) error {
+	if d.key != nil {
+		return errors.ErrWorkDirEncrypted
+	}
+	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=%d", path, busyTimeout))
+	if err != nil {
+		return errors.DBConnectFailed(path, err)
+	}
+	defer db.Close()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.DBConnectFailed(path, err)
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			tx.Rollback()
+			return errors.QueryFailed(query, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.QueryFailed("", err)
+	}
+	if _, err := db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		log.Debug().Err(err).Msgf("数据库 %s 执行 checkpoint 失败", path)
+	}
+	d.release(path)
 	return nil
 }
 
+// ExecGroup 在分组内的所有数据库文件上执行修改，返回影响的总行数，没有对应表的文件跳过
+func (d *DBManager) ExecGroup(ctx context.Context, name string, query string, args ...interface{}) (int64, error) {
+	dbPaths, err := d.GetDBPath(name)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, path := range dbPaths {
+		n, err := d.Exec(ctx, path, query, args...)
+		if err != nil {
+			if strings.Contains(err.Error(), "no such table") {
+				continue
+			}
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// execBatch IN 条件每批的参数数量，低于 SQLite 的参数数量上限
+const execBatch = 500
+
+// ExecGroupIn 在分组内的所有数据库文件上按 ID 列表执行修改，query 中的 %s 替换为 IN 条件的占位符
+func (d *DBManager) ExecGroupIn(ctx context.Context, name string, query string, ids []interface{}) (int64, error) {
+	var total int64
+	for start := 0; start < len(ids); start += execBatch {
+		batch := ids[start:min(start+execBatch, len(ids))]
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(batch)), ",")
+		n, err := d.ExecGroup(ctx, name, fmt.Sprintf(query, placeholders), batch...)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
 func (d *DBManager) Start() error {
+	d.mutex.Lock()
+	if d.stop == nil {
+		d.stop = make(chan struct{})
+		go d.closeIdle(d.stop)
+	}
+	d.mutex.Unlock()
 	return d.fm.Start()
 }
 
 func (d *DBManager) Stop() error {
+	d.mutex.Lock()
+	if d.stop != nil {
+		close(d.stop)
+		d.stop = nil
+	}
+	d.mutex.Unlock()
 	return d.fm.Stop()
 }
 
 func (d *DBManager) Close() error {
+	for _, stmts := range d.stmts {
+		closeStmts(stmts)
+	}
 	for _, db := range d.dbs {
 		db.Close()
 	}
-	return d.fm.Stop()
+	if d.tempDir != "" {
+		os.RemoveAll(d.tempDir)
+	}
+	return d.Stop()
 }
@@ -0,0 +1,107 @@
+package dbm
+
+import (
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// maxStmts 每个数据库文件缓存的预编译语句上限，消息查询的表名随会话变化，超出时淘汰最久未使用的语句
+const maxStmts = 256
+
+// stmtCloseDelay 被淘汰的语句延迟关闭，其他调用方可能已取得该语句但尚未执行
+const stmtCloseDelay = 5 * time.Second
+
+// cachedStmt 缓存的预编译语句与最近一次使用的时间
+type cachedStmt struct {
+	stmt *sql.Stmt
+	used atomic.Int64
+}
+
+// Stmt 返回数据库文件上缓存的预编译语句，同一查询在请求之间复用，省去每次解析 SQL 的开销
+// 语句随连接一起在数据库文件更新时关闭，调用方不需要也不应该关闭返回的语句
+func (d *DBManager) Stmt(path string, query string) (*sql.Stmt, error) {
+	d.mutex.RLock()
+	cached, ok := d.stmts[path][query]
+	if ok {
+		d.touch(path)
+		cached.used.Store(time.Now().UnixNano())
+	}
+	d.mutex.RUnlock()
+	if ok {
+		return cached.stmt, nil
+	}
+
+	for {
+		db, err := d.OpenDB(path)
+		if err != nil {
+			return nil, err
+		}
+		stmt, err := db.Prepare(query)
+		if err != nil {
+			return nil, errors.QueryFailed(query, err)
+		}
+		if cached, ok := d.cacheStmt(path, query, db, stmt); ok {
+			return cached, nil
+		}
+		// 预编译期间连接已被丢弃，关闭语句后在新连接上重新预编译
+		stmt.Close()
+	}
+}
+
+// cacheStmt 缓存在 db 上预编译的语句，已有缓存时关闭新语句并返回缓存的语句；db 已不是当前连接时返回 false
+func (d *DBManager) cacheStmt(path string, query string, db *sql.DB, stmt *sql.Stmt) (*sql.Stmt, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.dbs[path] != db {
+		return nil, false
+	}
+	if cached, ok := d.stmts[path][query]; ok {
+		stmt.Close()
+		return cached.stmt, true
+	}
+	stmts := d.stmts[path]
+	if stmts == nil {
+		stmts = make(map[string]*cachedStmt)
+		d.stmts[path] = stmts
+	}
+	if len(stmts) >= maxStmts {
+		evictStmt(stmts)
+	}
+	entry := &cachedStmt{stmt: stmt}
+	entry.used.Store(time.Now().UnixNano())
+	stmts[query] = entry
+	return stmt, true
+}
+
+// GroupStmt 返回分组中第一个数据库文件上缓存的预编译语句
+func (d *DBManager) GroupStmt(name string, query string) (*sql.Stmt, error) {
+	dbPaths, err := d.GetDBPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return d.Stmt(dbPaths[0], query)
+}
+
+// evictStmt 淘汰最久未使用的一条语句，与 release 一样延迟关闭，调用方需持有锁
+func evictStmt(stmts map[string]*cachedStmt) {
+	var oldest string
+	var oldestUsed int64
+	for query, cached := range stmts {
+		if used := cached.used.Load(); oldest == "" || used < oldestUsed {
+			oldest, oldestUsed = query, used
+		}
+	}
+	stmt := stmts[oldest].stmt
+	delete(stmts, oldest)
+	time.AfterFunc(stmtCloseDelay, func() { stmt.Close() })
+}
+
+// closeStmts 关闭语句，进行中的查询结束后才会真正释放
+func closeStmts(stmts map[string]*cachedStmt) {
+	for _, cached := range stmts {
+		cached.stmt.Close()
+	}
+}
@@ -72,11 +72,12 @@ type DataSource struct {
 	messageInfos []MessageDBInfo
 }
 
-func New(path string) (*DataSource, error) {
+// key 为工作目录的加密密钥，未加密时为 nil
+func New(path string, key []byte) (*DataSource, error) {
 
 	ds := &DataSource{
 		path:         path,
-		dbm:          dbm.NewDBManager(path),
+		dbm:          dbm.NewDBManager(path, key),
 		messageInfos: make([]MessageDBInfo, 0),
 	}
 
@@ -162,6 +163,8 @@ func (ds *DataSource) initMessageDbs() error {
 			infos[i].EndTime = infos[i+1].StartTime
 		}
 	}
+	// 索引信息读取完成后关闭消息分片，查询时按需打开
+	ds.dbm.ReleaseGroup(Message)
 	ds.messageInfos = infos
 	return nil
 }
@@ -177,47 +180,99 @@ func (ds *DataSource) getDBInfosForTimeRange(startTime, endTime time.Time) []Mes
 	return dbs
 }
 
-func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
+func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, msgType string, desc bool, limit, offset int) ([]*model.Message, error) {
+	filteredMessages := []*model.Message{}
+	err := ds.IterMessages(ctx, startTime, endTime, talker, sender, keyword, msgType, desc, func(message *model.Message) error {
+		filteredMessages = append(filteredMessages, message)
+		// 已经获取了足够的消息，可以提前结束
+		if limit > 0 && len(filteredMessages) >= offset+limit {
+			return errors.ErrIterStop
+		}
+		return nil
+	})
+	if err != nil && err != errors.ErrIterStop {
+		return nil, err
+	}
+
+	// 对所有消息按时间排序
+	sort.Slice(filteredMessages, func(i, j int) bool {
+		if desc {
+			return filteredMessages[i].Seq > filteredMessages[j].Seq
+		}
+		return filteredMessages[i].Seq < filteredMessages[j].Seq
+	})
+
+	// 处理分页
+	if limit > 0 {
+		if offset >= len(filteredMessages) {
+			return []*model.Message{}, nil
+		}
+		end := offset + limit
+		if end > len(filteredMessages) {
+			end = len(filteredMessages)
+		}
+		return filteredMessages[offset:end], nil
+	}
+
+	return filteredMessages, nil
+}
+
+// IterMessages 逐条读取符合条件的消息并调用 fn，不在内存中保留结果
+// 单个 talker 时按时间顺序回调，多个 talker 时按 talker 分组回调；fn 返回错误时停止读取并返回该错误
+func (ds *DataSource) IterMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, msgType string, desc bool, fn func(*model.Message) error) error {
 	if talker == "" {
-		return nil, errors.ErrTalkerEmpty
+		return errors.ErrTalkerEmpty
 	}
 
 	// 解析talker参数，支持多个talker（以英文逗号分隔）
 	talkers := util.Str2List(talker, ",")
 	if len(talkers) == 0 {
-		return nil, errors.ErrTalkerEmpty
+		return errors.ErrTalkerEmpty
 	}
 
 	// 找到时间范围内的数据库文件
 	dbInfos := ds.getDBInfosForTimeRange(startTime, endTime)
 	if len(dbInfos) == 0 {
-		return nil, errors.TimeRangeNotFound(startTime, endTime)
+		return errors.TimeRangeNotFound(startTime, endTime)
+	}
+
+	// 倒序查询时从最新的数据库开始，便于提前结束
+	sortOrder := "ASC"
+	if desc {
+		sortOrder = "DESC"
+		reversed := make([]MessageDBInfo, len(dbInfos))
+		for i, info := range dbInfos {
+			reversed[len(dbInfos)-1-i] = info
+		}
+		dbInfos = reversed
 	}
 
 	// 解析sender参数，支持多个发送者（以英文逗号分隔）
 	senders := util.Str2List(sender, ",")
 
+	// 解析消息类型，主类型在查询时过滤，子类型在读取时过滤
+	types, err := model.ParseMessageTypes(msgType)
+	if err != nil {
+		return errors.InvalidArg("type")
+	}
+
 	// 预编译正则表达式（如果有keyword）
 	var regex *regexp.Regexp
 	if keyword != "" {
 		var err error
 		regex, err = regexp.Compile(keyword)
 		if err != nil {
-			return nil, errors.QueryFailed("invalid regex pattern", err)
+			return errors.QueryFailed("invalid regex pattern", err)
 		}
 	}
 
-	// 从每个相关数据库中查询消息，并在读取时进行过滤
-	filteredMessages := []*model.Message{}
-
 	for _, dbInfo := range dbInfos {
 		// 检查上下文是否已取消
 		if err := ctx.Err(); err != nil {
-			return nil, err
+			return err
 		}
 
-		db, err := ds.dbm.OpenDB(dbInfo.FilePath)
-		if err != nil {
+		if _, err := ds.dbm.OpenDB(dbInfo.FilePath); err != nil {
 			log.Error().Msgf("数据库 %s 未打开", dbInfo.FilePath)
 			continue
 		}
@@ -230,22 +285,38 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 			tableName := "Msg_" + talkerMd5
 
 			// 检查表是否存在
+			stmt, err := ds.dbm.Stmt(dbInfo.FilePath, "SELECT 1 FROM sqlite_master WHERE type='table' AND name=?")
+			if err != nil {
+				return err
+			}
 			var exists bool
-			err = db.QueryRowContext(ctx,
-				"SELECT 1 FROM sqlite_master WHERE type='table' AND name=?",
-				tableName).Scan(&exists)
+			err = stmt.QueryRowContext(ctx, tableName).Scan(&exists)
 
 			if err != nil {
 				if err == sql.ErrNoRows {
 					// 表不存在，继续下一个talker
 					continue
 				}
-				return nil, errors.QueryFailed("", err)
+				return errors.QueryFailed("", err)
 			}
 
 			// 构建查询条件
 			conditions := []string{"create_time >= ? AND create_time <= ?"}
 			args := []interface{}{startTime.Unix(), endTime.Unix()}
+			if len(types) > 0 {
+				baseTypes := model.BaseTypes(types)
+				conditions = append(conditions, fmt.Sprintf("(m.local_type & 4294967295) IN (%s)", strings.TrimSuffix(strings.Repeat("?,", len(baseTypes)), ",")))
+				for _, t := range baseTypes {
+					args = append(args, t)
+				}
+			}
+			// 发送人在查询时按 real_sender_id 过滤，可使用 chatlog index 建立的发送人索引，读取时仍会再次核对
+			if len(senders) > 0 {
+				conditions = append(conditions, fmt.Sprintf("m.real_sender_id IN (SELECT rowid FROM Name2Id WHERE user_name IN (%s))", strings.TrimSuffix(strings.Repeat("?,", len(senders)), ",")))
+				for _, s := range senders {
+					args = append(args, s)
+				}
+			}
 			log.Debug().Msgf("Table name: %s", tableName)
 			log.Debug().Msgf("Start time: %d, End time: %d", startTime.Unix(), endTime.Unix())
 
@@ -254,11 +325,11 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 				FROM %s m
 				LEFT JOIN Name2Id n ON m.real_sender_id = n.rowid
 				WHERE %s 
-				ORDER BY m.sort_seq ASC
-			`, tableName, strings.Join(conditions, " AND "))
+				ORDER BY m.sort_seq %s
+			`, tableName, strings.Join(conditions, " AND "), sortOrder)
 
-			// 执行查询
-			rows, err := db.QueryContext(ctx, query, args...)
+			// 执行查询，相同会话与条件的查询复用预编译语句
+			stmt, err = ds.dbm.Stmt(dbInfo.FilePath, query)
 			if err != nil {
 				// 如果表不存在，SQLite 会返回错误
 				if strings.Contains(err.Error(), "no such table") {
@@ -267,6 +338,11 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 				log.Err(err).Msgf("从数据库 %s 查询消息失败", dbInfo.FilePath)
 				continue
 			}
+			rows, err := stmt.QueryContext(ctx, args...)
+			if err != nil {
+				log.Err(err).Msgf("从数据库 %s 查询消息失败", dbInfo.FilePath)
+				continue
+			}
 
 			// 处理查询结果，在读取时进行过滤
 			for rows.Next() {
@@ -283,12 +359,17 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 				)
 				if err != nil {
 					rows.Close()
-					return nil, errors.ScanRowFailed(err)
+					return errors.ScanRowFailed(err)
 				}
 
 				// 将消息转换为标准格式
 				message := msg.Wrap(talkerItem)
 
+				// 应用消息类型过滤
+				if !message.MatchTypes(types) {
+					continue
+				}
+
 				// 应用sender过滤
 				if len(senders) > 0 {
 					senderMatch := false
@@ -311,52 +392,18 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 					}
 				}
 
-				// 通过所有过滤条件，保留此消息
-				filteredMessages = append(filteredMessages, message)
-
-				// 检查是否已经满足分页处理数量
-				if limit > 0 && len(filteredMessages) >= offset+limit {
-					// 已经获取了足够的消息，可以提前返回
+				// 通过所有过滤条件，交给回调处理
+				if err := fn(message); err != nil {
 					rows.Close()
-
-					// 对所有消息按时间排序
-					sort.Slice(filteredMessages, func(i, j int) bool {
-						return filteredMessages[i].Seq < filteredMessages[j].Seq
-					})
-
-					// 处理分页
-					if offset >= len(filteredMessages) {
-						return []*model.Message{}, nil
-					}
-					end := offset + limit
-					if end > len(filteredMessages) {
-						end = len(filteredMessages)
-					}
-					return filteredMessages[offset:end], nil
+					return err
 				}
 			}
 			rows.Close()
 		}
 	}
 
-	// 对所有消息按时间排序
-	sort.Slice(filteredMessages, func(i, j int) bool {
-		return filteredMessages[i].Seq < filteredMessages[j].Seq
-	})
-
-	// 处理分页
-	if limit > 0 {
-		if offset >= len(filteredMessages) {
-			return []*model.Message{}, nil
-		}
-		end := offset + limit
-		if end > len(filteredMessages) {
-			end = len(filteredMessages)
-		}
-		return filteredMessages[offset:end], nil
-	}
-
-	return filteredMessages, nil
+	// 查询被取消时结果不完整，不能当作正常结束
+	return ctx.Err()
 }
 
 // 联系人
@@ -366,30 +413,28 @@ func (ds *DataSource) GetContacts(ctx context.Context, key string, limit, offset
 
 	if key != "" {
 		// 按照关键字查询
-		query = `SELECT username, local_type, alias, remark, nick_name 
+		query = `SELECT username, local_type, alias, remark, nick_name, IFNULL(small_head_url,"") 
 				FROM contact 
 				WHERE username = ? OR alias = ? OR remark = ? OR nick_name = ?`
 		args = []interface{}{key, key, key, key}
 	} else {
 		// 查询所有联系人
-		query = `SELECT username, local_type, alias, remark, nick_name FROM contact`
+		query = `SELECT username, local_type, alias, remark, nick_name, IFNULL(small_head_url,"") FROM contact`
 	}
 
-	// 添加排序、分页
+	// 添加排序、分页，分页参数化以便复用预编译语句
 	query += ` ORDER BY username`
 	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", limit)
-		if offset > 0 {
-			query += fmt.Sprintf(" OFFSET %d", offset)
-		}
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, limit, max(offset, 0))
 	}
 
 	// 执行查询
-	db, err := ds.dbm.GetDB(Contact)
+	stmt, err := ds.dbm.GroupStmt(Contact, query)
 	if err != nil {
 		return nil, err
 	}
-	rows, err := db.QueryContext(ctx, query, args...)
+	rows, err := stmt.QueryContext(ctx, args...)
 	if err != nil {
 		return nil, errors.QueryFailed(query, err)
 	}
@@ -404,6 +449,7 @@ func (ds *DataSource) GetContacts(ctx context.Context, key string, limit, offset
 			&contactV4.Alias,
 			&contactV4.Remark,
 			&contactV4.NickName,
+			&contactV4.SmallHeadURL,
 		)
 
 		if err != nil {
@@ -717,6 +763,324 @@ func (ds *DataSource) GetVoice(ctx context.Context, key string) (*model.Media, e
 	return nil, errors.ErrMediaNotFound
 }
 
+// PurgeTalker 清空会话的消息表，并删除联系人、群聊与最近会话记录
+func (ds *DataSource) PurgeTalker(ctx context.Context, talker string) (int, error) {
+	if talker == "" {
+		return 0, errors.ErrTalkerEmpty
+	}
+	_talkerMd5Bytes := md5.Sum([]byte(talker))
+	tableName := "Msg_" + hex.EncodeToString(_talkerMd5Bytes[:])
+
+	count, err := ds.dbm.ExecGroup(ctx, Message, fmt.Sprintf("DELETE FROM %s", tableName))
+	if err != nil {
+		return int(count), err
+	}
+	for _, item := range []struct{ group, query string }{
+		{Contact, "DELETE FROM contact WHERE username = ?"},
+		{Contact, "DELETE FROM chat_room WHERE username = ?"},
+		{Session, "DELETE FROM SessionTable WHERE username = ?"},
+	} {
+		if _, err := ds.dbm.ExecGroup(ctx, item.group, item.query, talker); err != nil {
+			return int(count), err
+		}
+	}
+	return int(count), nil
+}
+
+// BuildIndexes 为每个会话的消息表建立按时间与按发送人查询的索引，返回建立索引的表数量
+// 消息查询按 create_time 范围过滤，微信原有的表结构中没有对应的索引
+func (ds *DataSource) BuildIndexes(ctx context.Context) (int, error) {
+	total := 0
+	for _, dbInfo := range ds.messageInfos {
+		tables, err := ds.dbm.Tables(dbInfo.FilePath, "Msg_%")
+		if err != nil {
+			return total, err
+		}
+		queries := make([]string, 0, 2*len(tables)+1)
+		for _, table := range tables {
+			// 只处理 Msg_ 加会话 MD5 命名的消息表
+			if len(table) != len("Msg_")+md5.Size*2 {
+				continue
+			}
+			queries = append(queries,
+				fmt.Sprintf("CREATE INDEX IF NOT EXISTS chatlog_%s_time ON %s(create_time)", table, table),
+				fmt.Sprintf("CREATE INDEX IF NOT EXISTS chatlog_%s_sender ON %s(real_sender_id, create_time)", table, table),
+			)
+		}
+		n := len(queries) / 2
+		queries = append(queries, "ANALYZE")
+		if err := ds.dbm.ExecAll(ctx, dbInfo.FilePath, queries); err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
 func (ds *DataSource) Close() error {
 	return ds.dbm.Close()
 }
+
+// CountMessages 统计时间范围内的消息数量，talker 为空时统计所有会话
+func (ds *DataSource) CountMessages(ctx context.Context, startTime, endTime time.Time, talker string) (int, error) {
+	talkers := util.Str2List(talker, ",")
+
+	total := 0
+	for _, dbInfo := range ds.getDBInfosForTimeRange(startTime, endTime) {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		db, err := ds.dbm.OpenDB(dbInfo.FilePath)
+		if err != nil {
+			log.Error().Msgf("数据库 %s 未打开", dbInfo.FilePath)
+			continue
+		}
+
+		tables, err := messageTables(ctx, db, talkers)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, tableName := range tables {
+			query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE create_time >= ? AND create_time <= ?", tableName)
+			var count int
+			if err := db.QueryRowContext(ctx, query, startTime.Unix(), endTime.Unix()).Scan(&count); err != nil {
+				if strings.Contains(err.Error(), "no such table") {
+					continue
+				}
+				return 0, errors.QueryFailed(query, err)
+			}
+			total += count
+		}
+	}
+
+	return total, nil
+}
+
+// messageTables 会话对应的消息表名，talkers 为空时返回数据库中所有的消息表
+func messageTables(ctx context.Context, db *sql.DB, talkers []string) ([]string, error) {
+	tables := make([]string, 0, len(talkers))
+	if len(talkers) > 0 {
+		for _, talkerItem := range talkers {
+			_talkerMd5Bytes := md5.Sum([]byte(talkerItem))
+			tables = append(tables, "Msg_"+hex.EncodeToString(_talkerMd5Bytes[:]))
+		}
+		return tables, nil
+	}
+	rows, err := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type='table' AND name LIKE 'Msg_%'")
+	if err != nil {
+		return nil, errors.QueryFailed("", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tableName string
+		if err := rows.Scan(&tableName); err != nil {
+			return nil, errors.ScanRowFailed(err)
+		}
+		tables = append(tables, tableName)
+	}
+	return tables, nil
+}
+
+// DeleteMessages 从工作目录中删除时间范围内的消息及对应的语音数据，talker 为空时处理所有会话
+func (ds *DataSource) DeleteMessages(ctx context.Context, startTime, endTime time.Time, talker string) (int, error) {
+	talkers := util.Str2List(talker, ",")
+
+	total := 0
+	for _, dbInfo := range ds.getDBInfosForTimeRange(startTime, endTime) {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		db, err := ds.dbm.OpenDB(dbInfo.FilePath)
+		if err != nil {
+			log.Error().Msgf("数据库 %s 未打开", dbInfo.FilePath)
+			continue
+		}
+		tables, err := messageTables(ctx, db, talkers)
+		if err != nil {
+			return total, err
+		}
+
+		// 先读出语音消息 ID，删除消息后连接会被丢弃
+		voices := make([]interface{}, 0)
+		for _, tableName := range tables {
+			query := fmt.Sprintf("SELECT server_id FROM %s WHERE create_time >= ? AND create_time <= ? AND (local_type & 4294967295) = 34", tableName)
+			rows, err := db.QueryContext(ctx, query, startTime.Unix(), endTime.Unix())
+			if err != nil {
+				if strings.Contains(err.Error(), "no such table") {
+					continue
+				}
+				return total, errors.QueryFailed(query, err)
+			}
+			for rows.Next() {
+				var id int64
+				if err := rows.Scan(&id); err != nil {
+					rows.Close()
+					return total, errors.ScanRowFailed(err)
+				}
+				voices = append(voices, id)
+			}
+			rows.Close()
+		}
+
+		for _, tableName := range tables {
+			n, err := ds.dbm.Exec(ctx, dbInfo.FilePath, fmt.Sprintf("DELETE FROM %s WHERE create_time >= ? AND create_time <= ?", tableName), startTime.Unix(), endTime.Unix())
+			if err != nil {
+				if strings.Contains(err.Error(), "no such table") {
+					continue
+				}
+				return total, err
+			}
+			total += int(n)
+		}
+		if _, err := ds.dbm.ExecGroupIn(ctx, Voice, "DELETE FROM VoiceInfo WHERE svr_id IN (%s)", voices); err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// CountSessions 统计最近会话数量
+func (ds *DataSource) CountSessions(ctx context.Context) (int, error) {
+	query := `SELECT COUNT(*) FROM SessionTable`
+
+	db, err := ds.dbm.GetDB(Session)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	if err := db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return 0, errors.QueryFailed(query, err)
+	}
+	return count, nil
+}
+
+// GetMessage 按消息序号获取单条消息，序号的前 10 位为消息创建时间
+func (ds *DataSource) GetMessage(ctx context.Context, talker string, seq int64) (*model.Message, error) {
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+
+	_talkerMd5Bytes := md5.Sum([]byte(talker))
+	tableName := "Msg_" + hex.EncodeToString(_talkerMd5Bytes[:])
+	createTime := time.Unix(seq/1000, 0)
+
+	query := fmt.Sprintf(`
+		SELECT m.sort_seq, m.server_id, m.local_type, n.user_name, m.create_time, m.message_content, m.packed_info_data, m.status
+		FROM %s m
+		LEFT JOIN Name2Id n ON m.real_sender_id = n.rowid
+		WHERE m.sort_seq = ?
+	`, tableName)
+
+	for _, dbInfo := range ds.getDBInfosForTimeRange(createTime.Add(-time.Second), createTime.Add(time.Second)) {
+		stmt, err := ds.dbm.Stmt(dbInfo.FilePath, query)
+		if err != nil {
+			if strings.Contains(err.Error(), "no such table") {
+				continue
+			}
+			log.Err(err).Msgf("从数据库 %s 查询消息失败", dbInfo.FilePath)
+			continue
+		}
+
+		var msg model.MessageV4
+		err = stmt.QueryRowContext(ctx, seq).Scan(
+			&msg.SortSeq,
+			&msg.ServerID,
+			&msg.LocalType,
+			&msg.UserName,
+			&msg.CreateTime,
+			&msg.MessageContent,
+			&msg.PackedInfoData,
+			&msg.Status,
+		)
+		if err != nil {
+			if err == sql.ErrNoRows || strings.Contains(err.Error(), "no such table") {
+				continue
+			}
+			return nil, errors.QueryFailed(query, err)
+		}
+		return msg.Wrap(talker), nil
+	}
+
+	return nil, errors.MessageNotFound(talker, seq)
+}
+
+// GetAdjacentMessages 按序号从指定消息向前或向后逐个数据库查询，取够 limit 条即结束
+func (ds *DataSource) GetAdjacentMessages(ctx context.Context, talker string, seq int64, desc bool, limit int) ([]*model.Message, error) {
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+	messages := make([]*model.Message, 0, limit)
+	if limit <= 0 {
+		return messages, nil
+	}
+
+	_talkerMd5Bytes := md5.Sum([]byte(talker))
+	tableName := "Msg_" + hex.EncodeToString(_talkerMd5Bytes[:])
+	createTime := time.Unix(seq/1000, 0)
+
+	// 向前查找时从消息所在的数据库开始倒序遍历
+	dbInfos := make([]MessageDBInfo, 0, len(ds.messageInfos))
+	for _, info := range ds.messageInfos {
+		if desc && !info.StartTime.After(createTime) || !desc && !info.EndTime.Before(createTime) {
+			dbInfos = append(dbInfos, info)
+		}
+	}
+	op, sortOrder := ">", "ASC"
+	if desc {
+		op, sortOrder = "<", "DESC"
+		for i, j := 0, len(dbInfos)-1; i < j; i, j = i+1, j-1 {
+			dbInfos[i], dbInfos[j] = dbInfos[j], dbInfos[i]
+		}
+	}
+
+	query := fmt.Sprintf(`
+		SELECT m.sort_seq, m.server_id, m.local_type, n.user_name, m.create_time, m.message_content, m.packed_info_data, m.status
+		FROM %s m
+		LEFT JOIN Name2Id n ON m.real_sender_id = n.rowid
+		WHERE m.sort_seq %s ?
+		ORDER BY m.sort_seq %s
+		LIMIT ?
+	`, tableName, op, sortOrder)
+
+	for _, dbInfo := range dbInfos {
+		if len(messages) >= limit {
+			break
+		}
+		stmt, err := ds.dbm.Stmt(dbInfo.FilePath, query)
+		if err != nil {
+			if strings.Contains(err.Error(), "no such table") {
+				continue
+			}
+			log.Err(err).Msgf("从数据库 %s 查询消息失败", dbInfo.FilePath)
+			continue
+		}
+		rows, err := stmt.QueryContext(ctx, seq, limit-len(messages))
+		if err != nil {
+			return nil, errors.QueryFailed(query, err)
+		}
+		for rows.Next() {
+			var msg model.MessageV4
+			if err := rows.Scan(
+				&msg.SortSeq,
+				&msg.ServerID,
+				&msg.LocalType,
+				&msg.UserName,
+				&msg.CreateTime,
+				&msg.MessageContent,
+				&msg.PackedInfoData,
+				&msg.Status,
+			); err != nil {
+				rows.Close()
+				return nil, errors.ScanRowFailed(err)
+			}
+			messages = append(messages, msg.Wrap(talker))
+		}
+		rows.Close()
+	}
+
+	return messages, ctx.Err()
+}
@@ -2,6 +2,7 @@ package windowsv3
 
 import (
 	"context"
+	"database/sql"
 	"encoding/hex"
 	"fmt"
 	"regexp"
@@ -79,10 +80,11 @@ type DataSource struct {
 }
 
 // New 创建一个新的 WindowsV3DataSource
-func New(path string) (*DataSource, error) {
+// key 为工作目录的加密密钥，未加密时为 nil
+func New(path string, key []byte) (*DataSource, error) {
 	ds := &DataSource{
 		path:         path,
-		dbm:          dbm.NewDBManager(path),
+		dbm:          dbm.NewDBManager(path, key),
 		messageInfos: make([]MessageDBInfo, 0),
 	}
 
@@ -112,7 +114,8 @@ func New(path string) (*DataSource, error) {
 }
 
 func (ds *DataSource) SetCallback(name string, callback func(event fsnotify.Event) error) error {
-	if name == "chatroom" {
+	// 群聊与会话数据都在 MicroMsg.db 中
+	if name == "chatroom" || name == "session" {
 		name = Contact
 	}
 	return ds.dbm.AddCallback(name, callback)
@@ -207,6 +210,8 @@ func (ds *DataSource) initMessageDbs() error {
 			infos[i].EndTime = infos[i+1].StartTime
 		}
 	}
+	// 索引信息读取完成后关闭消息分片，查询时按需打开
+	ds.dbm.ReleaseGroup(Message)
 	ds.messageInfos = infos
 	return nil
 }
@@ -222,47 +227,99 @@ func (ds *DataSource) getDBInfosForTimeRange(startTime, endTime time.Time) []Mes
 	return dbs
 }
 
-func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
+func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, msgType string, desc bool, limit, offset int) ([]*model.Message, error) {
+	filteredMessages := []*model.Message{}
+	err := ds.IterMessages(ctx, startTime, endTime, talker, sender, keyword, msgType, desc, func(message *model.Message) error {
+		filteredMessages = append(filteredMessages, message)
+		// 已经获取了足够的消息，可以提前结束
+		if limit > 0 && len(filteredMessages) >= offset+limit {
+			return errors.ErrIterStop
+		}
+		return nil
+	})
+	if err != nil && err != errors.ErrIterStop {
+		return nil, err
+	}
+
+	// 对所有消息按时间排序
+	sort.Slice(filteredMessages, func(i, j int) bool {
+		if desc {
+			return filteredMessages[i].Seq > filteredMessages[j].Seq
+		}
+		return filteredMessages[i].Seq < filteredMessages[j].Seq
+	})
+
+	// 处理分页
+	if limit > 0 {
+		if offset >= len(filteredMessages) {
+			return []*model.Message{}, nil
+		}
+		end := offset + limit
+		if end > len(filteredMessages) {
+			end = len(filteredMessages)
+		}
+		return filteredMessages[offset:end], nil
+	}
+
+	return filteredMessages, nil
+}
+
+// IterMessages 逐条读取符合条件的消息并调用 fn，不在内存中保留结果
+// 单个 talker 时按时间顺序回调，多个 talker 时按 talker 分组回调；fn 返回错误时停止读取并返回该错误
+func (ds *DataSource) IterMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, msgType string, desc bool, fn func(*model.Message) error) error {
 	if talker == "" {
-		return nil, errors.ErrTalkerEmpty
+		return errors.ErrTalkerEmpty
 	}
 
 	// 解析talker参数，支持多个talker（以英文逗号分隔）
 	talkers := util.Str2List(talker, ",")
 	if len(talkers) == 0 {
-		return nil, errors.ErrTalkerEmpty
+		return errors.ErrTalkerEmpty
 	}
 
 	// 找到时间范围内的数据库文件
 	dbInfos := ds.getDBInfosForTimeRange(startTime, endTime)
 	if len(dbInfos) == 0 {
-		return nil, errors.TimeRangeNotFound(startTime, endTime)
+		return errors.TimeRangeNotFound(startTime, endTime)
+	}
+
+	// 倒序查询时从最新的数据库开始，便于提前结束
+	sortOrder := "ASC"
+	if desc {
+		sortOrder = "DESC"
+		reversed := make([]MessageDBInfo, len(dbInfos))
+		for i, info := range dbInfos {
+			reversed[len(dbInfos)-1-i] = info
+		}
+		dbInfos = reversed
 	}
 
 	// 解析sender参数，支持多个发送者（以英文逗号分隔）
 	senders := util.Str2List(sender, ",")
 
+	// 解析消息类型，主类型在查询时过滤，子类型在读取时过滤
+	types, err := model.ParseMessageTypes(msgType)
+	if err != nil {
+		return errors.InvalidArg("type")
+	}
+
 	// 预编译正则表达式（如果有keyword）
 	var regex *regexp.Regexp
 	if keyword != "" {
 		var err error
 		regex, err = regexp.Compile(keyword)
 		if err != nil {
-			return nil, errors.QueryFailed("invalid regex pattern", err)
+			return errors.QueryFailed("invalid regex pattern", err)
 		}
 	}
 
-	// 从每个相关数据库中查询消息
-	filteredMessages := []*model.Message{}
-
 	for _, dbInfo := range dbInfos {
 		// 检查上下文是否已取消
 		if err := ctx.Err(); err != nil {
-			return nil, err
+			return err
 		}
 
-		db, err := ds.dbm.OpenDB(dbInfo.FilePath)
-		if err != nil {
+		if _, err := ds.dbm.OpenDB(dbInfo.FilePath); err != nil {
 			log.Error().Msgf("数据库 %s 未打开", dbInfo.FilePath)
 			continue
 		}
@@ -282,17 +339,24 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 				conditions = append(conditions, "StrTalker = ?")
 				args = append(args, talkerItem)
 			}
+			if len(types) > 0 {
+				baseTypes := model.BaseTypes(types)
+				conditions = append(conditions, fmt.Sprintf("Type IN (%s)", strings.TrimSuffix(strings.Repeat("?,", len(baseTypes)), ",")))
+				for _, t := range baseTypes {
+					args = append(args, t)
+				}
+			}
 
 			query := fmt.Sprintf(`
 				SELECT MsgSvrID, Sequence, CreateTime, StrTalker, IsSender, 
 					Type, SubType, StrContent, CompressContent, BytesExtra
 				FROM MSG 
 				WHERE %s 
-				ORDER BY Sequence ASC
-			`, strings.Join(conditions, " AND "))
+				ORDER BY Sequence %s
+			`, strings.Join(conditions, " AND "), sortOrder)
 
-			// 执行查询
-			rows, err := db.QueryContext(ctx, query, args...)
+			// 执行查询，相同会话与条件的查询复用预编译语句
+			stmt, err := ds.dbm.Stmt(dbInfo.FilePath, query)
 			if err != nil {
 				// 如果表不存在，跳过此talker
 				if strings.Contains(err.Error(), "no such table") {
@@ -301,6 +365,11 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 				log.Err(err).Msgf("从数据库 %s 查询消息失败", dbInfo.FilePath)
 				continue
 			}
+			rows, err := stmt.QueryContext(ctx, args...)
+			if err != nil {
+				log.Err(err).Msgf("从数据库 %s 查询消息失败", dbInfo.FilePath)
+				continue
+			}
 
 			// 处理查询结果，在读取时进行过滤
 			for rows.Next() {
@@ -322,7 +391,7 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 				)
 				if err != nil {
 					rows.Close()
-					return nil, errors.ScanRowFailed(err)
+					return errors.ScanRowFailed(err)
 				}
 				msg.CompressContent = compressContent
 				msg.BytesExtra = bytesExtra
@@ -330,6 +399,11 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 				// 将消息转换为标准格式
 				message := msg.Wrap()
 
+				// 应用消息类型过滤
+				if !message.MatchTypes(types) {
+					continue
+				}
+
 				// 应用sender过滤
 				if len(senders) > 0 {
 					senderMatch := false
@@ -352,52 +426,18 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 					}
 				}
 
-				// 通过所有过滤条件，保留此消息
-				filteredMessages = append(filteredMessages, message)
-
-				// 检查是否已经满足分页处理数量
-				if limit > 0 && len(filteredMessages) >= offset+limit {
-					// 已经获取了足够的消息，可以提前返回
+				// 通过所有过滤条件，交给回调处理
+				if err := fn(message); err != nil {
 					rows.Close()
-
-					// 对所有消息按时间排序
-					sort.Slice(filteredMessages, func(i, j int) bool {
-						return filteredMessages[i].Seq < filteredMessages[j].Seq
-					})
-
-					// 处理分页
-					if offset >= len(filteredMessages) {
-						return []*model.Message{}, nil
-					}
-					end := offset + limit
-					if end > len(filteredMessages) {
-						end = len(filteredMessages)
-					}
-					return filteredMessages[offset:end], nil
+					return err
 				}
 			}
 			rows.Close()
 		}
 	}
 
-	// 对所有消息按时间排序
-	sort.Slice(filteredMessages, func(i, j int) bool {
-		return filteredMessages[i].Seq < filteredMessages[j].Seq
-	})
-
-	// 处理分页
-	if limit > 0 {
-		if offset >= len(filteredMessages) {
-			return []*model.Message{}, nil
-		}
-		end := offset + limit
-		if end > len(filteredMessages) {
-			end = len(filteredMessages)
-		}
-		return filteredMessages[offset:end], nil
-	}
-
-	return filteredMessages, nil
+	// 查询被取消时结果不完整，不能当作正常结束
+	return ctx.Err()
 }
 
 // GetContacts 实现获取联系人信息的方法
@@ -407,29 +447,27 @@ func (ds *DataSource) GetContacts(ctx context.Context, key string, limit, offset
 
 	if key != "" {
 		// 按照关键字查询
-		query = `SELECT UserName, Alias, Remark, NickName, Reserved1 FROM Contact 
+		query = `SELECT UserName, Alias, Remark, NickName, Reserved1, IFNULL(SmallHeadImgUrl,"") FROM Contact 
                 WHERE UserName = ? OR Alias = ? OR Remark = ? OR NickName = ?`
 		args = []interface{}{key, key, key, key}
 	} else {
 		// 查询所有联系人
-		query = `SELECT UserName, Alias, Remark, NickName, Reserved1 FROM Contact`
+		query = `SELECT UserName, Alias, Remark, NickName, Reserved1, IFNULL(SmallHeadImgUrl,"") FROM Contact`
 	}
 
-	// 添加排序、分页
+	// 添加排序、分页，分页参数化以便复用预编译语句
 	query += ` ORDER BY UserName`
 	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", limit)
-		if offset > 0 {
-			query += fmt.Sprintf(" OFFSET %d", offset)
-		}
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, limit, max(offset, 0))
 	}
 
 	// 执行查询
-	db, err := ds.dbm.GetDB(Contact)
+	stmt, err := ds.dbm.GroupStmt(Contact, query)
 	if err != nil {
 		return nil, err
 	}
-	rows, err := db.QueryContext(ctx, query, args...)
+	rows, err := stmt.QueryContext(ctx, args...)
 	if err != nil {
 		return nil, errors.QueryFailed(query, err)
 	}
@@ -444,6 +482,7 @@ func (ds *DataSource) GetContacts(ctx context.Context, key string, limit, offset
 			&contactV3.Remark,
 			&contactV3.NickName,
 			&contactV3.Reserved1,
+			&contactV3.SmallHeadImgUrl,
 		)
 
 		if err != nil {
@@ -768,7 +807,351 @@ func (ds *DataSource) GetVoice(ctx context.Context, key string) (*model.Media, e
 	return nil, errors.ErrMediaNotFound
 }
 
+// PurgeTalker 删除会话的消息，以及联系人、群聊与最近会话记录
+func (ds *DataSource) PurgeTalker(ctx context.Context, talker string) (int, error) {
+	if talker == "" {
+		return 0, errors.ErrTalkerEmpty
+	}
+
+	count := 0
+	for _, dbInfo := range ds.messageInfos {
+		query, arg := "DELETE FROM MSG WHERE StrTalker = ?", interface{}(talker)
+		if talkerID, ok := dbInfo.TalkerMap[talker]; ok {
+			query, arg = "DELETE FROM MSG WHERE TalkerId = ?", talkerID
+		}
+		n, err := ds.dbm.Exec(ctx, dbInfo.FilePath, query, arg)
+		if err != nil {
+			if strings.Contains(err.Error(), "no such table") {
+				continue
+			}
+			return count, err
+		}
+		count += int(n)
+	}
+
+	// 联系人、群聊与会话都在 MicroMsg.db 中
+	for _, query := range []string{
+		"DELETE FROM Contact WHERE UserName = ?",
+		"DELETE FROM ChatRoom WHERE ChatRoomName = ?",
+		"DELETE FROM Session WHERE strUsrName = ?",
+	} {
+		if _, err := ds.dbm.ExecGroup(ctx, Contact, query, talker); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// BuildIndexes 为消息表建立按会话与时间查询的索引，返回建立索引的表数量
+// 消息查询按 StrTalker 或 TalkerId 与 Sequence 范围过滤，微信原有的表结构中没有对应的组合索引
+func (ds *DataSource) BuildIndexes(ctx context.Context) (int, error) {
+	total := 0
+	for _, dbInfo := range ds.messageInfos {
+		queries := []string{"CREATE INDEX IF NOT EXISTS chatlog_MSG_talker_seq ON MSG(StrTalker, Sequence)"}
+		// 有 Name2ID 表的版本按 TalkerId 查询
+		if len(dbInfo.TalkerMap) > 0 {
+			queries = append(queries, "CREATE INDEX IF NOT EXISTS chatlog_MSG_talkerid_seq ON MSG(TalkerId, Sequence)")
+		}
+		queries = append(queries, "ANALYZE")
+		if err := ds.dbm.ExecAll(ctx, dbInfo.FilePath, queries); err != nil {
+			return total, err
+		}
+		total++
+	}
+	return total, nil
+}
+
 // Close 实现 DataSource 接口的 Close 方法
 func (ds *DataSource) Close() error {
 	return ds.dbm.Close()
 }
+
+// CountMessages 统计时间范围内的消息数量，talker 为空时统计所有会话
+func (ds *DataSource) CountMessages(ctx context.Context, startTime, endTime time.Time, talker string) (int, error) {
+	talkers := util.Str2List(talker, ",")
+
+	total := 0
+	for _, dbInfo := range ds.getDBInfosForTimeRange(startTime, endTime) {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		db, err := ds.dbm.OpenDB(dbInfo.FilePath)
+		if err != nil {
+			log.Error().Msgf("数据库 %s 未打开", dbInfo.FilePath)
+			continue
+		}
+
+		// 无 talker 条件时整体统计一次
+		talkerItems := talkers
+		if len(talkerItems) == 0 {
+			talkerItems = []string{""}
+		}
+
+		for _, talkerItem := range talkerItems {
+			conditions, args := messageConditions(dbInfo, startTime, endTime, talkerItem)
+			query := fmt.Sprintf("SELECT COUNT(*) FROM MSG WHERE %s", strings.Join(conditions, " AND "))
+			var count int
+			if err := db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+				if strings.Contains(err.Error(), "no such table") {
+					continue
+				}
+				return 0, errors.QueryFailed(query, err)
+			}
+			total += count
+		}
+	}
+
+	return total, nil
+}
+
+// messageConditions 按时间范围与会话筛选消息的条件，talker 为空时不限会话
+func messageConditions(dbInfo MessageDBInfo, startTime, endTime time.Time, talker string) ([]string, []interface{}) {
+	conditions := []string{"Sequence >= ? AND Sequence <= ?"}
+	args := []interface{}{startTime.Unix() * 1000, endTime.Unix() * 1000}
+	if talker != "" {
+		talkerID, ok := dbInfo.TalkerMap[talker]
+		if ok {
+			conditions = append(conditions, "TalkerId = ?")
+			args = append(args, talkerID)
+		} else {
+			conditions = append(conditions, "StrTalker = ?")
+			args = append(args, talker)
+		}
+	}
+	return conditions, args
+}
+
+// DeleteMessages 从工作目录中删除时间范围内的消息及对应的语音数据，talker 为空时处理所有会话
+func (ds *DataSource) DeleteMessages(ctx context.Context, startTime, endTime time.Time, talker string) (int, error) {
+	talkerItems := util.Str2List(talker, ",")
+	if len(talkerItems) == 0 {
+		talkerItems = []string{""}
+	}
+
+	total := 0
+	for _, dbInfo := range ds.getDBInfosForTimeRange(startTime, endTime) {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		db, err := ds.dbm.OpenDB(dbInfo.FilePath)
+		if err != nil {
+			log.Error().Msgf("数据库 %s 未打开", dbInfo.FilePath)
+			continue
+		}
+
+		// 先读出语音消息 ID，删除消息后连接会被丢弃
+		voices := make([]interface{}, 0)
+		for _, talkerItem := range talkerItems {
+			conditions, args := messageConditions(dbInfo, startTime, endTime, talkerItem)
+			query := fmt.Sprintf("SELECT MsgSvrID FROM MSG WHERE %s AND Type = 34", strings.Join(conditions, " AND "))
+			rows, err := db.QueryContext(ctx, query, args...)
+			if err != nil {
+				if strings.Contains(err.Error(), "no such table") {
+					continue
+				}
+				return total, errors.QueryFailed(query, err)
+			}
+			for rows.Next() {
+				var id int64
+				if err := rows.Scan(&id); err != nil {
+					rows.Close()
+					return total, errors.ScanRowFailed(err)
+				}
+				voices = append(voices, id)
+			}
+			rows.Close()
+		}
+
+		for _, talkerItem := range talkerItems {
+			conditions, args := messageConditions(dbInfo, startTime, endTime, talkerItem)
+			n, err := ds.dbm.Exec(ctx, dbInfo.FilePath, fmt.Sprintf("DELETE FROM MSG WHERE %s", strings.Join(conditions, " AND ")), args...)
+			if err != nil {
+				if strings.Contains(err.Error(), "no such table") {
+					continue
+				}
+				return total, err
+			}
+			total += int(n)
+		}
+		if _, err := ds.dbm.ExecGroupIn(ctx, Voice, "DELETE FROM Media WHERE Reserved0 IN (%s)", voices); err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// CountSessions 统计最近会话数量
+func (ds *DataSource) CountSessions(ctx context.Context) (int, error) {
+	query := `SELECT COUNT(*) FROM Session`
+
+	db, err := ds.dbm.GetDB(Contact)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	if err := db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return 0, errors.QueryFailed(query, err)
+	}
+	return count, nil
+}
+
+// GetMessage 按消息序号获取单条消息，序号的前 10 位为消息创建时间
+func (ds *DataSource) GetMessage(ctx context.Context, talker string, seq int64) (*model.Message, error) {
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+
+	createTime := time.Unix(seq/1000, 0)
+	for _, dbInfo := range ds.getDBInfosForTimeRange(createTime.Add(-time.Second), createTime.Add(time.Second)) {
+		db, err := ds.dbm.OpenDB(dbInfo.FilePath)
+		if err != nil {
+			log.Error().Msgf("数据库 %s 未打开", dbInfo.FilePath)
+			continue
+		}
+
+		conditions := []string{"Sequence = ?"}
+		args := []interface{}{seq}
+		if talkerID, ok := dbInfo.TalkerMap[talker]; ok {
+			conditions = append(conditions, "TalkerId = ?")
+			args = append(args, talkerID)
+		} else {
+			conditions = append(conditions, "StrTalker = ?")
+			args = append(args, talker)
+		}
+
+		query := fmt.Sprintf(`
+			SELECT MsgSvrID, Sequence, CreateTime, StrTalker, IsSender,
+				Type, SubType, StrContent, CompressContent, BytesExtra
+			FROM MSG
+			WHERE %s
+		`, strings.Join(conditions, " AND "))
+
+		var msg model.MessageV3
+		var compressContent []byte
+		var bytesExtra []byte
+		err = db.QueryRowContext(ctx, query, args...).Scan(
+			&msg.MsgSvrID,
+			&msg.Sequence,
+			&msg.CreateTime,
+			&msg.StrTalker,
+			&msg.IsSender,
+			&msg.Type,
+			&msg.SubType,
+			&msg.StrContent,
+			&compressContent,
+			&bytesExtra,
+		)
+		if err != nil {
+			if err == sql.ErrNoRows || strings.Contains(err.Error(), "no such table") {
+				continue
+			}
+			return nil, errors.QueryFailed(query, err)
+		}
+		msg.CompressContent = compressContent
+		msg.BytesExtra = bytesExtra
+
+		return msg.Wrap(), nil
+	}
+
+	return nil, errors.MessageNotFound(talker, seq)
+}
+
+// GetAdjacentMessages 按序号从指定消息向前或向后逐个数据库查询，取够 limit 条即结束
+func (ds *DataSource) GetAdjacentMessages(ctx context.Context, talker string, seq int64, desc bool, limit int) ([]*model.Message, error) {
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+	messages := make([]*model.Message, 0, limit)
+	if limit <= 0 {
+		return messages, nil
+	}
+
+	createTime := time.Unix(seq/1000, 0)
+
+	// 向前查找时从消息所在的数据库开始倒序遍历
+	dbInfos := make([]MessageDBInfo, 0, len(ds.messageInfos))
+	for _, info := range ds.messageInfos {
+		if desc && !info.StartTime.After(createTime) || !desc && !info.EndTime.Before(createTime) {
+			dbInfos = append(dbInfos, info)
+		}
+	}
+	op, sortOrder := ">", "ASC"
+	if desc {
+		op, sortOrder = "<", "DESC"
+		for i, j := 0, len(dbInfos)-1; i < j; i, j = i+1, j-1 {
+			dbInfos[i], dbInfos[j] = dbInfos[j], dbInfos[i]
+		}
+	}
+
+	for _, dbInfo := range dbInfos {
+		if len(messages) >= limit {
+			break
+		}
+		if _, err := ds.dbm.OpenDB(dbInfo.FilePath); err != nil {
+			log.Error().Msgf("数据库 %s 未打开", dbInfo.FilePath)
+			continue
+		}
+
+		conditions := []string{"Sequence " + op + " ?"}
+		args := []interface{}{seq}
+		if talkerID, ok := dbInfo.TalkerMap[talker]; ok {
+			conditions = append(conditions, "TalkerId = ?")
+			args = append(args, talkerID)
+		} else {
+			conditions = append(conditions, "StrTalker = ?")
+			args = append(args, talker)
+		}
+		args = append(args, limit-len(messages))
+
+		query := fmt.Sprintf(`
+			SELECT MsgSvrID, Sequence, CreateTime, StrTalker, IsSender,
+				Type, SubType, StrContent, CompressContent, BytesExtra
+			FROM MSG
+			WHERE %s
+			ORDER BY Sequence %s
+			LIMIT ?
+		`, strings.Join(conditions, " AND "), sortOrder)
+
+		stmt, err := ds.dbm.Stmt(dbInfo.FilePath, query)
+		if err != nil {
+			if strings.Contains(err.Error(), "no such table") {
+				continue
+			}
+			log.Err(err).Msgf("从数据库 %s 查询消息失败", dbInfo.FilePath)
+			continue
+		}
+		rows, err := stmt.QueryContext(ctx, args...)
+		if err != nil {
+			return nil, errors.QueryFailed(query, err)
+		}
+		for rows.Next() {
+			var msg model.MessageV3
+			var compressContent []byte
+			var bytesExtra []byte
+			if err := rows.Scan(
+				&msg.MsgSvrID,
+				&msg.Sequence,
+				&msg.CreateTime,
+				&msg.StrTalker,
+				&msg.IsSender,
+				&msg.Type,
+				&msg.SubType,
+				&msg.StrContent,
+				&compressContent,
+				&bytesExtra,
+			); err != nil {
+				rows.Close()
+				return nil, errors.ScanRowFailed(err)
+			}
+			msg.CompressContent = compressContent
+			msg.BytesExtra = bytesExtra
+			messages = append(messages, msg.Wrap())
+		}
+		rows.Close()
+	}
+
+	return messages, ctx.Err()
+}
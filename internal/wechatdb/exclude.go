@@ -0,0 +1,108 @@
+package wechatdb
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// SetExclude 设置不对外提供的会话，可填写微信 ID、群 ID、备注或昵称
+// 被排除的会话不会出现在联系人、群聊、会话列表中，也无法查询其聊天记录
+func (w *DB) SetExclude(keys []string) {
+	exclude := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			exclude = append(exclude, key)
+		}
+	}
+	w.excludeMu.Lock()
+	w.exclude = exclude
+	w.excludeMu.Unlock()
+}
+
+// excludeKeys 当前的排除列表，列表只会被整体替换，返回后可在锁外遍历
+func (w *DB) excludeKeys() []string {
+	w.excludeMu.RLock()
+	defer w.excludeMu.RUnlock()
+	return w.exclude
+}
+
+// excludedIDs 将排除列表解析为微信 ID 与群 ID，每次查询时解析，联系人缓存刷新后名称变化也能生效
+func (w *DB) excludedIDs() map[string]bool {
+	keys := w.excludeKeys()
+	if len(keys) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	ids := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		resolved := false
+		if contact, _ := w.repo.GetContact(ctx, key); contact != nil {
+			ids[contact.UserName] = true
+			resolved = true
+		}
+		if chatRoom, _ := w.repo.GetChatRoom(ctx, key); chatRoom != nil {
+			ids[chatRoom.Name] = true
+			resolved = true
+		}
+		// 找不到联系人的按 ID 处理，如已删除的好友
+		if !resolved {
+			ids[key] = true
+		}
+	}
+	return ids
+}
+
+// isExcluded 会话是否被排除，talker 可以是 ID 或名称
+func (w *DB) isExcluded(ids map[string]bool, talker string) bool {
+	if len(ids) == 0 {
+		return false
+	}
+	if ids[talker] {
+		return true
+	}
+	ctx := context.Background()
+	if contact, _ := w.repo.GetContact(ctx, talker); contact != nil && ids[contact.UserName] {
+		return true
+	}
+	if chatRoom, _ := w.repo.GetChatRoom(ctx, talker); chatRoom != nil && ids[chatRoom.Name] {
+		return true
+	}
+	return false
+}
+
+// allowedTalkers 去除 talker 列表中被排除的会话，指定的会话全部被排除时返回 false
+func (w *DB) allowedTalkers(ids map[string]bool, talker string) (string, bool) {
+	if len(ids) == 0 || talker == "" {
+		return talker, true
+	}
+	talkers := make([]string, 0)
+	for _, t := range util.Str2List(talker, ",") {
+		if !w.isExcluded(ids, t) {
+			talkers = append(talkers, t)
+		}
+	}
+	return strings.Join(talkers, ","), len(talkers) > 0
+}
+
+// excludeItems 过滤被排除的会话后再分页
+func excludeItems[T any](items []T, ids map[string]bool, id func(T) string, limit, offset int) []T {
+	ret := make([]T, 0, len(items))
+	for _, item := range items {
+		if !ids[id(item)] {
+			ret = append(ret, item)
+		}
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(ret) {
+		return ret[:0]
+	}
+	ret = ret[offset:]
+	if limit > 0 && limit < len(ret) {
+		ret = ret[:limit]
+	}
+	return ret
+}
@@ -0,0 +1,304 @@
+package wechatdb
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/internal/wechatdb/repository"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// fakeDataSource 内存数据源，与实际的数据源一样必须指定会话
+type fakeDataSource struct {
+	messages []*model.Message
+	contacts []*model.Contact
+	sessions []*model.Session
+}
+
+func (ds *fakeDataSource) match(m *model.Message, startTime, endTime time.Time, talker, keyword string) bool {
+	if m.Time.Before(startTime) || m.Time.After(endTime) {
+		return false
+	}
+	if talker != "" && !slices.Contains(util.Str2List(talker, ","), m.Talker) {
+		return false
+	}
+	return keyword == "" || strings.Contains(m.Content, keyword)
+}
+
+func (ds *fakeDataSource) GetMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, msgType string, desc bool, limit, offset int) ([]*model.Message, error) {
+	ret := make([]*model.Message, 0)
+	if err := ds.IterMessages(ctx, startTime, endTime, talker, sender, keyword, msgType, desc, func(m *model.Message) error {
+		ret = append(ret, m)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return excludeItems(ret, nil, func(m *model.Message) string { return m.Talker }, limit, offset), nil
+}
+
+func (ds *fakeDataSource) IterMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, msgType string, desc bool, fn func(*model.Message) error) error {
+	if talker == "" {
+		return errors.ErrTalkerEmpty
+	}
+	messages := make([]*model.Message, 0)
+	for _, m := range ds.messages {
+		if ds.match(m, startTime, endTime, talker, keyword) {
+			messages = append(messages, m)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		if desc {
+			return messages[i].Seq > messages[j].Seq
+		}
+		return messages[i].Seq < messages[j].Seq
+	})
+	for _, m := range messages {
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ds *fakeDataSource) GetMessage(ctx context.Context, talker string, seq int64) (*model.Message, error) {
+	for _, m := range ds.messages {
+		if m.Talker == talker && m.Seq == seq {
+			return m, nil
+		}
+	}
+	return nil, errors.MessageNotFound(talker, seq)
+}
+
+func (ds *fakeDataSource) GetAdjacentMessages(ctx context.Context, talker string, seq int64, desc bool, limit int) ([]*model.Message, error) {
+	ret := make([]*model.Message, 0)
+	ds.IterMessages(ctx, time.Time{}, time.Now(), talker, "", "", "", desc, func(m *model.Message) error {
+		if desc && m.Seq < seq || !desc && m.Seq > seq {
+			ret = append(ret, m)
+		}
+		return nil
+	})
+	return excludeItems(ret, nil, func(m *model.Message) string { return m.Talker }, limit, 0), nil
+}
+
+func (ds *fakeDataSource) CountMessages(ctx context.Context, startTime, endTime time.Time, talker string) (int, error) {
+	n := 0
+	for _, m := range ds.messages {
+		if ds.match(m, startTime, endTime, talker, "") {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (ds *fakeDataSource) DeleteMessages(ctx context.Context, startTime, endTime time.Time, talker string) (int, error) {
+	return 0, nil
+}
+
+func (ds *fakeDataSource) GetContacts(ctx context.Context, key string, limit, offset int) ([]*model.Contact, error) {
+	return ds.contacts, nil
+}
+
+func (ds *fakeDataSource) GetChatRooms(ctx context.Context, key string, limit, offset int) ([]*model.ChatRoom, error) {
+	return []*model.ChatRoom{}, nil
+}
+
+func (ds *fakeDataSource) GetSessions(ctx context.Context, key string, limit, offset int) ([]*model.Session, error) {
+	return excludeItems(ds.sessions, nil, func(s *model.Session) string { return s.UserName }, limit, offset), nil
+}
+
+func (ds *fakeDataSource) CountSessions(ctx context.Context) (int, error) {
+	return len(ds.sessions), nil
+}
+
+func (ds *fakeDataSource) GetMedia(ctx context.Context, _type string, key string) (*model.Media, error) {
+	return nil, errors.ErrMediaNotFound
+}
+
+func (ds *fakeDataSource) PurgeTalker(ctx context.Context, talker string) (int, error) {
+	return 0, nil
+}
+
+func (ds *fakeDataSource) BuildIndexes(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func (ds *fakeDataSource) SetCallback(name string, callback func(event fsnotify.Event) error) error {
+	return nil
+}
+
+func (ds *fakeDataSource) Close() error {
+	return nil
+}
+
+// newTestDB 三个会话交替各 10 条消息，排除 wxid_b
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	ds := &fakeDataSource{}
+	base := time.Date(2024, 3, 15, 10, 0, 0, 0, time.Local)
+	talkers := []string{"wxid_a", "wxid_b", "wxid_c"}
+	for i := 0; i < 30; i++ {
+		ds.messages = append(ds.messages, &model.Message{
+			Seq:     int64(i + 1),
+			Time:    base.Add(time.Duration(i) * time.Minute),
+			Talker:  talkers[i%3],
+			Sender:  talkers[i%3],
+			Content: "hello",
+		})
+	}
+	for i, talker := range talkers {
+		ds.contacts = append(ds.contacts, &model.Contact{UserName: talker, NickName: strings.ToUpper(talker)})
+		ds.sessions = append(ds.sessions, &model.Session{UserName: talker, NTime: base.Add(time.Duration(i) * time.Hour)})
+	}
+
+	repo, err := repository.New(ds)
+	if err != nil {
+		t.Fatalf("repository.New() error: %v", err)
+	}
+	w := &DB{ds: ds, repo: repo}
+	w.SetExclude([]string{"wxid_b"})
+	return w
+}
+
+func TestExcludeMessages(t *testing.T) {
+	w := newTestDB(t)
+	ctx := context.Background()
+	start, end := time.Time{}, time.Now()
+
+	// 与实际的数据源一样，未指定会话时不读取消息
+	if _, err := w.GetMessages(ctx, start, end, "", "", "hello", "", true, 5, 0); err != errors.ErrTalkerEmpty {
+		t.Errorf("GetMessages(\"\") error = %v, want ErrTalkerEmpty", err)
+	}
+
+	// 关键词搜索时调用方将最近会话展开为会话列表，列表中被排除的会话同样不返回，翻页不重复不遗漏
+	talkers := "wxid_a,wxid_b,wxid_c"
+	seen := make(map[int64]bool)
+	for offset := 0; offset < 20; offset += 5 {
+		messages, err := w.GetMessages(ctx, start, end, talkers, "", "hello", "", true, 5, offset)
+		if err != nil {
+			t.Fatalf("GetMessages(offset=%d) error: %v", offset, err)
+		}
+		if len(messages) != 5 {
+			t.Fatalf("GetMessages(offset=%d) = %d messages, want 5", offset, len(messages))
+		}
+		for i, m := range messages {
+			if m.Talker == "wxid_b" {
+				t.Errorf("GetMessages(offset=%d) returned excluded talker", offset)
+			}
+			if seen[m.Seq] {
+				t.Errorf("GetMessages(offset=%d) returned seq %d twice", offset, m.Seq)
+			}
+			if i > 0 && m.Seq > messages[i-1].Seq {
+				t.Errorf("GetMessages(offset=%d) not in descending order", offset)
+			}
+			seen[m.Seq] = true
+		}
+	}
+	if messages, _ := w.GetMessages(ctx, start, end, talkers, "", "hello", "", true, 5, 20); len(messages) != 0 {
+		t.Errorf("GetMessages(offset=20) = %d messages, want 0", len(messages))
+	}
+
+	sessions, err := w.GetSessions(ctx, "", 0, 0)
+	if err != nil {
+		t.Fatalf("GetSessions() error: %v", err)
+	}
+	ids := make([]string, 0, len(sessions.Items))
+	for _, session := range sessions.Items {
+		ids = append(ids, session.UserName)
+	}
+	if messages, err := w.GetMessages(ctx, start, end, strings.Join(ids, ","), "", "hello", "", true, 0, 0); err != nil || len(messages) != 20 {
+		t.Errorf("GetMessages(sessions) = %d messages, %v, want 20", len(messages), err)
+	}
+
+	n := 0
+	if err := w.IterMessages(ctx, start, end, talkers, "", "", "", false, func(m *model.Message) error {
+		if m.Talker == "wxid_b" {
+			t.Errorf("IterMessages returned excluded talker")
+		}
+		n++
+		return nil
+	}); err != nil || n != 20 {
+		t.Errorf("IterMessages() = %d, %v, want 20", n, err)
+	}
+
+	if _, err := w.GetMessages(ctx, start, end, "wxid_b", "", "", "", false, 0, 0); err == nil {
+		t.Errorf("GetMessages(wxid_b) succeeded, want error")
+	}
+	if _, err := w.GetMessage(ctx, "wxid_b", 2); err == nil {
+		t.Errorf("GetMessage(wxid_b) succeeded, want error")
+	}
+	if _, err := w.GetMessage(ctx, "wxid_a", 1); err != nil {
+		t.Errorf("GetMessage(wxid_a) error: %v", err)
+	}
+
+	// wxid_a 的消息序号为 1、4、7 … 28
+	messages, index, err := w.GetMessageContext(ctx, "wxid_a", 13, 2, 3)
+	if err != nil {
+		t.Fatalf("GetMessageContext(wxid_a) error: %v", err)
+	}
+	var seqs []int64
+	for _, m := range messages {
+		seqs = append(seqs, m.Seq)
+	}
+	if want := []int64{7, 10, 13, 16, 19, 22}; !slices.Equal(seqs, want) || index != 2 {
+		t.Errorf("GetMessageContext(wxid_a) = %v, %d, want %v, 2", seqs, index, want)
+	}
+	if _, _, err := w.GetMessageContext(ctx, "wxid_b", 2, 2, 2); err == nil {
+		t.Errorf("GetMessageContext(wxid_b) succeeded, want error")
+	}
+}
+
+func TestExcludeCounts(t *testing.T) {
+	w := newTestDB(t)
+	ctx := context.Background()
+	start, end := time.Time{}, time.Now()
+
+	if n, err := w.CountMessages(ctx, start, end, ""); err != nil || n != 20 {
+		t.Errorf("CountMessages() = %d, %v, want 20", n, err)
+	}
+	if n, err := w.CountMessages(ctx, start, end, "wxid_a"); err != nil || n != 10 {
+		t.Errorf("CountMessages(wxid_a) = %d, %v, want 10", n, err)
+	}
+	if _, err := w.CountMessages(ctx, start, end, "wxid_b"); err == nil {
+		t.Errorf("CountMessages(wxid_b) succeeded, want error")
+	}
+	if n, err := w.CountContacts(ctx); err != nil || n != 2 {
+		t.Errorf("CountContacts() = %d, %v, want 2", n, err)
+	}
+	if n, err := w.CountSessions(ctx); err != nil || n != 2 {
+		t.Errorf("CountSessions() = %d, %v, want 2", n, err)
+	}
+}
+
+func TestExcludeLists(t *testing.T) {
+	w := newTestDB(t)
+	ctx := context.Background()
+
+	contacts, err := w.GetContacts(ctx, "", 0, 0)
+	if err != nil {
+		t.Fatalf("GetContacts() error: %v", err)
+	}
+	for _, c := range contacts.Items {
+		if c.UserName == "wxid_b" {
+			t.Errorf("GetContacts() returned excluded contact")
+		}
+	}
+	if len(contacts.Items) != 2 {
+		t.Errorf("GetContacts() = %d items, want 2", len(contacts.Items))
+	}
+
+	sessions, err := w.GetSessions(ctx, "", 1, 1)
+	if err != nil {
+		t.Fatalf("GetSessions() error: %v", err)
+	}
+	if len(sessions.Items) != 1 || sessions.Items[0].UserName != "wxid_c" {
+		t.Errorf("GetSessions(limit=1, offset=1) = %+v, want [wxid_c]", sessions.Items)
+	}
+}
@@ -89,6 +89,8 @@ func (r *Repository) initChatRoomCache(ctx context.Context) error {
 	r.chatRoomRemark = chatRoomRemark
 	r.chatRoomNickName = chatRoomNickName
 
+	// 群聊更新后名称可能变化，清空查找结果
+	r.chatRoomLRU.Purge()
 	return nil
 }
 
@@ -131,6 +133,11 @@ func (r *Repository) GetChatRooms(ctx context.Context, key string, limit, offset
 	return ret, nil
 }
 
+// CountChatRooms 群聊数量，直接读取缓存
+func (r *Repository) CountChatRooms(ctx context.Context) (int, error) {
+	return len(r.chatRoomList), nil
+}
+
 func (r *Repository) GetChatRoom(ctx context.Context, key string) (*model.ChatRoom, error) {
 	chatRoom := r.findChatRoom(key)
 	if chatRoom == nil {
@@ -147,7 +154,17 @@ func (r *Repository) enrichChatRoom(chatRoom *model.ChatRoom) {
 	}
 }
 
+// findChatRoom 按 ID 或名称查找群聊，结果缓存到群聊缓存刷新为止
 func (r *Repository) findChatRoom(key string) *model.ChatRoom {
+	if chatRoom, ok := r.chatRoomLRU.Get(key); ok {
+		return chatRoom
+	}
+	chatRoom := r.scanChatRoom(key)
+	r.chatRoomLRU.Add(key, chatRoom)
+	return chatRoom
+}
+
+func (r *Repository) scanChatRoom(key string) *model.ChatRoom {
 	if chatRoom, ok := r.chatRoomCache[key]; ok {
 		return chatRoom
 	}
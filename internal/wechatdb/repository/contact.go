@@ -86,6 +86,9 @@ func (r *Repository) initContactCache(ctx context.Context) error {
 	r.aliasList = aliasList
 	r.remarkList = remarkList
 	r.nickNameList = nickNameList
+
+	// 联系人更新后名称可能变化，清空查找结果
+	r.contactLRU.Purge()
 	return nil
 }
 
@@ -134,7 +137,22 @@ func (r *Repository) GetContacts(ctx context.Context, key string, limit, offset
 	return ret, nil
 }
 
+// CountContacts 联系人数量，直接读取缓存
+func (r *Repository) CountContacts(ctx context.Context) (int, error) {
+	return len(r.contactList), nil
+}
+
+// findContact 按 ID 或名称查找联系人，结果缓存到联系人缓存刷新为止
 func (r *Repository) findContact(key string) *model.Contact {
+	if contact, ok := r.contactLRU.Get(key); ok {
+		return contact
+	}
+	contact := r.scanContact(key)
+	r.contactLRU.Add(key, contact)
+	return contact
+}
+
+func (r *Repository) scanContact(key string) *model.Contact {
 	if contact, ok := r.contactCache[key]; ok {
 		return contact
 	}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"slices"
 	"strings"
 	"time"
 
@@ -12,10 +13,10 @@ import (
 )
 
 // GetMessages 实现 Repository 接口的 GetMessages 方法
-func (r *Repository) GetMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
+func (r *Repository) GetMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, msgType string, desc bool, limit, offset int) ([]*model.Message, error) {
 
 	talker, sender = r.parseTalkerAndSender(ctx, talker, sender)
-	messages, err := r.ds.GetMessages(ctx, startTime, endTime, talker, sender, keyword, limit, offset)
+	messages, err := r.ds.GetMessages(ctx, startTime, endTime, talker, sender, keyword, msgType, desc, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -28,6 +29,56 @@ func (r *Repository) GetMessages(ctx context.Context, startTime, endTime time.Ti
 	return messages, nil
 }
 
+// IterMessages 逐条读取消息并补充发送者等信息，适合结果较多、无需整体排序的场景
+func (r *Repository) IterMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, msgType string, desc bool, fn func(*model.Message) error) error {
+	talker, sender = r.parseTalkerAndSender(ctx, talker, sender)
+	return r.ds.IterMessages(ctx, startTime, endTime, talker, sender, keyword, msgType, desc, func(message *model.Message) error {
+		r.enrichMessage(message)
+		return fn(message)
+	})
+}
+
+// GetMessage 获取单条消息并补充发送者等信息
+func (r *Repository) GetMessage(ctx context.Context, talker string, seq int64) (*model.Message, error) {
+	talker, _ = r.parseTalkerAndSender(ctx, talker, "")
+	message, err := r.ds.GetMessage(ctx, talker, seq)
+	if err != nil {
+		return nil, err
+	}
+	r.enrichMessage(message)
+	return message, nil
+}
+
+// GetMessageContext 获取指定消息前后各若干条消息，返回的列表包含该消息本身，index 为其在列表中的位置
+func (r *Repository) GetMessageContext(ctx context.Context, talker string, seq int64, before, after int) ([]*model.Message, int, error) {
+	talker, _ = r.parseTalkerAndSender(ctx, talker, "")
+	anchor, err := r.ds.GetMessage(ctx, talker, seq)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// 按序号向前、向后各取最近的若干条，避免长时间没有消息的会话扫描整个会话
+	prev, err := r.ds.GetAdjacentMessages(ctx, talker, seq, true, before)
+	if err != nil {
+		return nil, 0, err
+	}
+	slices.Reverse(prev)
+	next, err := r.ds.GetAdjacentMessages(ctx, talker, seq, false, after)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	messages := make([]*model.Message, 0, len(prev)+1+len(next))
+	messages = append(messages, prev...)
+	messages = append(messages, anchor)
+	messages = append(messages, next...)
+	if err := r.EnrichMessages(ctx, messages); err != nil {
+		log.Debug().Msgf("EnrichMessages failed: %v", err)
+	}
+
+	return messages, len(prev), nil
+}
+
 // EnrichMessages 补充消息的额外信息
 func (r *Repository) EnrichMessages(ctx context.Context, messages []*model.Message) error {
 	for _, msg := range messages {
@@ -60,6 +111,12 @@ func (r *Repository) enrichMessage(msg *model.Message) {
 	}
 }
 
+// ResolveTalkers 将以英文逗号分隔的会话名称解析为会话 ID 列表
+func (r *Repository) ResolveTalkers(ctx context.Context, talker string) []string {
+	talker, _ = r.parseTalkerAndSender(ctx, talker, "")
+	return util.Str2List(talker, ",")
+}
+
 func (r *Repository) parseTalkerAndSender(ctx context.Context, talker, sender string) (string, string) {
 	displayName2User := make(map[string]string)
 	users := make(map[string]bool)
@@ -109,3 +166,15 @@ func (r *Repository) parseTalkerAndSender(ctx context.Context, talker, sender st
 
 	return talker, sender
 }
+
+// CountMessages 统计消息数量，talker 为空时统计所有会话
+func (r *Repository) CountMessages(ctx context.Context, startTime, endTime time.Time, talker string) (int, error) {
+	talker, _ = r.parseTalkerAndSender(ctx, talker, "")
+	return r.ds.CountMessages(ctx, startTime, endTime, talker)
+}
+
+// DeleteMessages 从工作目录中删除时间范围内的消息，talker 可以是名称
+func (r *Repository) DeleteMessages(ctx context.Context, startTime, endTime time.Time, talker string) (int, error) {
+	talker, _ = r.parseTalkerAndSender(ctx, talker, "")
+	return r.ds.DeleteMessages(ctx, startTime, endTime, talker)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/sjzar/chatlog/internal/errors"
 	"github.com/sjzar/chatlog/internal/model"
 	"github.com/sjzar/chatlog/internal/wechatdb/datasource"
+	"github.com/sjzar/chatlog/pkg/util"
 )
 
 // Repository 实现了 repository.Repository 接口
@@ -36,8 +37,15 @@ type Repository struct {
 
 	// 快速查找索引
 	chatRoomUserToInfo map[string]*model.Contact
+
+	// 按 ID 或名称查找的结果，模糊匹配需要遍历名称列表，同一名称反复查找时直接返回，未找到的结果同样缓存
+	contactLRU  *util.LRU[string, *model.Contact]
+	chatRoomLRU *util.LRU[string, *model.ChatRoom]
 }
 
+// lookupCacheSize 查找结果缓存的条目数
+const lookupCacheSize = 4096
+
 // New 创建一个新的 Repository
 func New(ds datasource.DataSource) (*Repository, error) {
 	r := &Repository{
@@ -57,6 +65,8 @@ func New(ds datasource.DataSource) (*Repository, error) {
 		chatRoomList:       make([]string, 0),
 		chatRoomRemark:     make([]string, 0),
 		chatRoomNickName:   make([]string, 0),
+		contactLRU:         util.NewLRU[string, *model.Contact](lookupCacheSize),
+		chatRoomLRU:        util.NewLRU[string, *model.ChatRoom](lookupCacheSize),
 	}
 
 	// 初始化缓存
@@ -105,6 +115,28 @@ func (r *Repository) chatroomCallback(event fsnotify.Event) error {
 	return nil
 }
 
+// SetCallback 注册数据库文件变更回调
+func (r *Repository) SetCallback(name string, callback func(event fsnotify.Event) error) error {
+	return r.ds.SetCallback(name, callback)
+}
+
+// PurgeTalker 从工作目录中删除会话数据，并重新加载联系人与群聊缓存
+func (r *Repository) PurgeTalker(ctx context.Context, talker string) (int, error) {
+	count, err := r.ds.PurgeTalker(ctx, talker)
+	if err != nil {
+		return count, err
+	}
+	if err := r.initCache(ctx); err != nil {
+		return count, errors.InitCacheFailed(err)
+	}
+	return count, nil
+}
+
+// BuildIndexes 在工作目录的消息数据库中建立辅助索引
+func (r *Repository) BuildIndexes(ctx context.Context) (int, error) {
+	return r.ds.BuildIndexes(ctx)
+}
+
 // Close 实现 Repository 接口的 Close 方法
 func (r *Repository) Close() error {
 	return r.ds.Close()
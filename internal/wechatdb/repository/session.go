@@ -9,3 +9,7 @@ import (
 func (r *Repository) GetSessions(ctx context.Context, key string, limit, offset int) ([]*model.Session, error) {
 	return r.ds.GetSessions(ctx, key, limit, offset)
 }
+
+func (r *Repository) CountSessions(ctx context.Context) (int, error) {
+	return r.ds.CountSessions(ctx)
+}
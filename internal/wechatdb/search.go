@@ -0,0 +1,374 @@
+package wechatdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// SearchFile 工作目录中的消息全文索引，由 BuildSearchIndex 生成
+const SearchFile = "chatlog_search.db"
+
+// searchSchema docs 为每条消息的会话、序号与时间，fts 以相同的 docid 保存分词后的消息文本，
+// talkers 为已建立索引的会话，meta.until 之前的消息已建立索引
+// 使用 SQLite 默认编译的 FTS4，不需要额外的编译选项
+var searchSchema = []string{
+	`CREATE TABLE IF NOT EXISTS docs (
+		docid INTEGER PRIMARY KEY,
+		talker TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		time INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS docs_talker ON docs (talker, time)`,
+	`CREATE VIRTUAL TABLE IF NOT EXISTS fts USING fts4(content, tokenize=simple)`,
+	`CREATE TABLE IF NOT EXISTS talkers (talker TEXT PRIMARY KEY) WITHOUT ROWID`,
+	`CREATE TABLE IF NOT EXISTS meta (key TEXT PRIMARY KEY, value INTEGER NOT NULL)`,
+}
+
+// searchTokens 将文本拆分为以空格分隔的单个字母或数字并转为小写，其他字符丢弃
+// 中文没有分词，逐字索引后以短语查询相邻的字，关键词在原文中出现时一定能匹配
+func searchTokens(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// searchQuery 关键词对应的短语查询与关键词表示的文字，关键词不是普通文字（如转义后的文字）的正则表达式
+// 或没有字母与数字时返回空，只能逐条匹配
+func searchQuery(keyword string) (string, string) {
+	if keyword == "" {
+		return "", ""
+	}
+	regex, err := regexp.Compile(keyword)
+	if err != nil {
+		return "", ""
+	}
+	literal, complete := regex.LiteralPrefix()
+	if !complete {
+		return "", ""
+	}
+	tokens := searchTokens(literal)
+	if tokens == "" {
+		return "", ""
+	}
+	return `"` + tokens + `"`, literal
+}
+
+// searchDB 打开全文索引数据库，create 为 false 且尚未生成时返回 nil
+// 加密的工作目录不生成索引，避免消息内容以明文落盘
+func (w *DB) searchDB(create bool) (*sql.DB, error) {
+	if w.key != nil {
+		return nil, errors.ErrWorkDirEncrypted
+	}
+	w.searchMu.Lock()
+	defer w.searchMu.Unlock()
+	if w.search != nil {
+		return w.search, nil
+	}
+
+	path := filepath.Join(w.path, SearchFile)
+	if _, err := os.Stat(path); err != nil && !create {
+		return nil, nil
+	}
+	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?_journal_mode=WAL&_busy_timeout=5000", path))
+	if err != nil {
+		return nil, errors.DBConnectFailed(path, err)
+	}
+	for _, query := range searchSchema {
+		if _, err := db.Exec(query); err != nil {
+			db.Close()
+			return nil, errors.QueryFailed(query, err)
+		}
+	}
+	w.search = db
+	return db, nil
+}
+
+// BuildSearchIndex 为最近会话列表中所有会话在当前时间之前的消息建立全文索引，替换已有的索引，返回建立索引的会话数量
+// 之后新增的消息在查询时逐条匹配，重新解密后需要重新建立
+func (w *DB) BuildSearchIndex(ctx context.Context) (int, error) {
+	db, err := w.searchDB(true)
+	if err != nil {
+		return 0, err
+	}
+
+	sessions, err := w.repo.GetSessions(ctx, "", 0, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.QueryFailed("BEGIN", err)
+	}
+	defer tx.Rollback()
+
+	exec := func(query string, args ...interface{}) (sql.Result, error) {
+		res, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return nil, errors.QueryFailed(query, err)
+		}
+		return res, nil
+	}
+	for _, query := range []string{"DELETE FROM docs", "DELETE FROM fts", "DELETE FROM talkers"} {
+		if _, err := exec(query); err != nil {
+			return 0, err
+		}
+	}
+
+	until := time.Now().Truncate(time.Second)
+	start, _, _ := util.TimeRangeOf("all")
+	n := 0
+	for _, session := range sessions {
+		talker := session.UserName
+		err := w.repo.IterMessages(ctx, start, until.Add(-time.Second), talker, "", "", "", false, func(msg *model.Message) error {
+			content := searchTokens(msg.PlainTextContent())
+			if content == "" {
+				return nil
+			}
+			res, err := exec("INSERT INTO docs (talker, seq, time) VALUES (?, ?, ?)", talker, msg.Seq, msg.Time.Unix())
+			if err != nil {
+				return err
+			}
+			docid, _ := res.LastInsertId()
+			_, err = exec("INSERT INTO fts (docid, content) VALUES (?, ?)", docid, content)
+			return err
+		})
+		if e, ok := err.(*errors.Error); ok && e.Code == http.StatusNotFound {
+			err = nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, err
+			}
+			// 读取失败的会话不计入，删除已写入的部分，查询时改为逐条匹配
+			log.Debug().Err(err).Msgf("index messages of %s failed", talker)
+			if err := deleteSearchDocs(ctx, tx, talker, time.Time{}, time.Time{}); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if _, err := exec("INSERT OR IGNORE INTO talkers (talker) VALUES (?)", talker); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	if _, err := exec("INSERT OR REPLACE INTO meta (key, value) VALUES ('until', ?)", until.Unix()); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.QueryFailed("COMMIT", err)
+	}
+	return n, nil
+}
+
+// deleteSearchDocs 删除会话在时间范围内的索引，start 与 end 为零值时删除该会话的全部索引
+func deleteSearchDocs(ctx context.Context, tx *sql.Tx, talker string, start, end time.Time) error {
+	cond, args := "talker = ?", []interface{}{talker}
+	if !start.IsZero() || !end.IsZero() {
+		cond += " AND time >= ? AND time <= ?"
+		args = append(args, start.Unix(), end.Unix())
+	}
+	for _, query := range []string{
+		"DELETE FROM fts WHERE docid IN (SELECT docid FROM docs WHERE " + cond + ")",
+		"DELETE FROM docs WHERE " + cond,
+	} {
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return errors.QueryFailed(query, err)
+		}
+	}
+	return nil
+}
+
+// removeSearch 删除消息后同步删除索引中的内容，start 与 end 为零值时删除会话的全部索引
+// 未生成索引时不做处理；删除失败时移出已建立索引的会话，查询时改为逐条匹配
+func (w *DB) removeSearch(ctx context.Context, talker string, start, end time.Time) {
+	db, err := w.searchDB(false)
+	if err != nil || db == nil {
+		return
+	}
+	for _, id := range w.repo.ResolveTalkers(ctx, talker) {
+		tx, err := db.BeginTx(ctx, nil)
+		if err == nil {
+			if err = deleteSearchDocs(ctx, tx, id, start, end); err == nil {
+				err = tx.Commit()
+			}
+			tx.Rollback()
+		}
+		if err != nil {
+			log.Err(err).Msgf("remove search index of %s failed", id)
+			db.ExecContext(ctx, "DELETE FROM talkers WHERE talker = ?", id)
+		}
+	}
+}
+
+// searchUntil 全文索引覆盖的截止时间与已建立索引的会话，尚未生成索引时返回零值
+func searchUntil(ctx context.Context, db *sql.DB) (time.Time, map[string]bool, error) {
+	var until int64
+	query := "SELECT value FROM meta WHERE key = 'until'"
+	if err := db.QueryRowContext(ctx, query).Scan(&until); err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, nil, nil
+		}
+		return time.Time{}, nil, errors.QueryFailed(query, err)
+	}
+
+	query = "SELECT talker FROM talkers"
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return time.Time{}, nil, errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+	talkers := make(map[string]bool)
+	for rows.Next() {
+		var talker string
+		if err := rows.Scan(&talker); err != nil {
+			return time.Time{}, nil, errors.ScanRowFailed(err)
+		}
+		talkers[talker] = true
+	}
+	return time.Unix(until, 0), talkers, rows.Err()
+}
+
+// searchMessages 使用全文索引按关键词查询多个会话的消息，按时间合并后分页；ok 为 false 时无法使用索引
+// 索引只用于找出候选消息，读取后仍按关键词核对；索引之后的新消息与未建立索引的会话逐条匹配
+func (w *DB) searchMessages(ctx context.Context, start, end time.Time, talker string, keyword string, desc bool, limit, offset int) ([]*model.Message, bool, error) {
+	query, literal := searchQuery(keyword)
+	if query == "" {
+		return nil, false, nil
+	}
+	db, err := w.searchDB(false)
+	if err != nil || db == nil {
+		return nil, false, nil
+	}
+	until, covered, err := searchUntil(ctx, db)
+	if err != nil || until.IsZero() {
+		return nil, false, nil
+	}
+
+	indexed := make(map[string]bool)
+	tail := make([]string, 0)
+	direct := make([]string, 0)
+	for _, id := range w.repo.ResolveTalkers(ctx, talker) {
+		if covered[id] {
+			indexed[id] = true
+			tail = append(tail, id)
+		} else {
+			direct = append(direct, id)
+		}
+	}
+	if len(indexed) == 0 {
+		return nil, false, nil
+	}
+
+	n := 0
+	if limit > 0 {
+		n = offset + limit
+	}
+	messages, err := w.indexedMessages(ctx, db, query, literal, indexed, start, end, until, desc, n)
+	if err != nil {
+		return nil, true, err
+	}
+
+	// 索引截止时间之后的新消息一次读取全部匹配，数量较少
+	if from := maxTime(start, until); !end.Before(from) {
+		err := w.repo.IterMessages(ctx, from, end, strings.Join(tail, ","), "", keyword, "", desc, func(msg *model.Message) error {
+			messages = append(messages, msg)
+			return nil
+		})
+		if e, ok := err.(*errors.Error); !ok || e.Code != http.StatusNotFound {
+			if err != nil {
+				return nil, true, err
+			}
+		}
+	}
+	// 未建立索引的会话分别逐条匹配
+	if len(direct) > 0 {
+		ret, err := w.talkerMessages(ctx, direct, start, end, "", keyword, "", desc, n, 0)
+		if e, ok := err.(*errors.Error); !ok || e.Code != http.StatusNotFound {
+			if err != nil {
+				return nil, true, err
+			}
+			messages = append(messages, ret...)
+		}
+	}
+
+	sortByTime(messages, desc)
+	return excludeItems(messages, nil, func(m *model.Message) string { return m.Talker }, limit, offset), true, nil
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// indexedMessages 按时间顺序读取索引中匹配的候选消息，核对关键词后最多返回 n 条，n 为 0 时不限制
+func (w *DB) indexedMessages(ctx context.Context, db *sql.DB, query string, literal string, talkers map[string]bool, start, end, until time.Time, desc bool, n int) ([]*model.Message, error) {
+	if !until.After(start) {
+		return []*model.Message{}, nil
+	}
+	if end.After(until.Add(-time.Second)) {
+		end = until.Add(-time.Second)
+	}
+	order := "ASC"
+	if desc {
+		order = "DESC"
+	}
+	q := fmt.Sprintf(`SELECT talker, seq FROM docs
+		WHERE docid IN (SELECT docid FROM fts WHERE fts MATCH ?) AND time >= ? AND time <= ?
+		ORDER BY time %s, seq %s`, order, order)
+	rows, err := db.QueryContext(ctx, q, query, start.Unix(), end.Unix())
+	if err != nil {
+		return nil, errors.QueryFailed(q, err)
+	}
+	defer rows.Close()
+
+	ret := make([]*model.Message, 0)
+	for rows.Next() {
+		var talker string
+		var seq int64
+		if err := rows.Scan(&talker, &seq); err != nil {
+			return nil, errors.ScanRowFailed(err)
+		}
+		if !talkers[talker] {
+			continue
+		}
+		// 索引之后被删除的消息跳过
+		msg, err := w.repo.GetMessage(ctx, talker, seq)
+		if err != nil || !strings.Contains(msg.PlainTextContent(), literal) {
+			continue
+		}
+		ret = append(ret, msg)
+		if n > 0 && len(ret) >= n {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.QueryFailed(q, err)
+	}
+	return ret, ctx.Err()
+}
@@ -0,0 +1,116 @@
+package wechatdb
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+func TestSearchTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Hello, World", "h e l l o w o r l d"},
+		{"明天 10 点开会！", "明 天 1 0 点 开 会"},
+		{"...", ""},
+	}
+	for _, tt := range tests {
+		if got := searchTokens(tt.text); got != tt.want {
+			t.Errorf("searchTokens(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	queries := []struct {
+		keyword string
+		query   string
+		literal string
+	}{
+		{"开会", `"开 会"`, "开会"},
+		// 转义后的普通文字按原文匹配
+		{`v1\.0`, `"v 1 0"`, "v1.0"},
+		// 正则表达式无法使用索引
+		{"开.*会", "", ""},
+		{"!!", "", ""},
+	}
+	for _, tt := range queries {
+		if query, literal := searchQuery(tt.keyword); query != tt.query || literal != tt.literal {
+			t.Errorf("searchQuery(%q) = %q, %q, want %q, %q", tt.keyword, query, literal, tt.query, tt.literal)
+		}
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	w := newTestDB(t)
+	w.path = t.TempDir()
+	defer w.Close()
+	ds := w.ds.(*fakeDataSource)
+	for i, m := range ds.messages {
+		m.Type = 1
+		m.Content = "随便聊聊"
+		if i%2 == 0 {
+			m.Content = "明天开会，记得带电脑"
+		}
+	}
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+
+	n, err := w.BuildSearchIndex(ctx)
+	if err != nil || n != 3 {
+		t.Fatalf("BuildSearchIndex() = %d, %v, want 3", n, err)
+	}
+
+	// 索引之后新增的消息逐条匹配
+	ds.messages = append(ds.messages, &model.Message{Seq: 100, Time: time.Now().Add(time.Minute), Talker: "wxid_a", Sender: "wxid_a", Type: 1, Content: "下周开会"})
+	end := time.Now().Add(time.Hour)
+
+	seqs := func(messages []*model.Message) []int64 {
+		ret := make([]int64, 0, len(messages))
+		for _, m := range messages {
+			ret = append(ret, m.Seq)
+		}
+		return ret
+	}
+	talkers := "wxid_a,wxid_b,wxid_c"
+	messages, ok, err := w.searchMessages(ctx, start, end, talkers, "开会", true, 4, 0)
+	if err != nil || !ok {
+		t.Fatalf("searchMessages() = %v, %v", ok, err)
+	}
+	// wxid_b 被排除时由 GetMessages 过滤，此处直接查询索引包含全部会话
+	if want := []int64{100, 29, 27, 25}; !slices.Equal(seqs(messages), want) {
+		t.Errorf("searchMessages() = %v, want %v", seqs(messages), want)
+	}
+
+	// 通过 GetMessages 查询时不返回被排除的会话，结果与逐条匹配一致
+	indexed, err := w.GetMessages(ctx, start, end, talkers, "", "开会", "", true, 0, 0)
+	if err != nil {
+		t.Fatalf("GetMessages() error: %v", err)
+	}
+	var scanned []*model.Message
+	for _, talker := range []string{"wxid_a", "wxid_c"} {
+		ret, _ := w.repo.GetMessages(ctx, start, end, talker, "", "开会", "", true, 0, 0)
+		scanned = append(scanned, ret...)
+	}
+	slices.SortFunc(scanned, func(a, b *model.Message) int { return b.Time.Compare(a.Time) })
+	if !slices.Equal(seqs(indexed), seqs(scanned)) {
+		t.Errorf("GetMessages() = %v, want %v", seqs(indexed), seqs(scanned))
+	}
+
+	// 正则表达式不使用索引
+	if _, ok, _ := w.searchMessages(ctx, start, end, talkers, "开.*会", true, 0, 0); ok {
+		t.Errorf("searchMessages(regex) used the index")
+	}
+
+	// 清除会话时删除索引中的内容
+	if _, err := w.PurgeTalker("wxid_a"); err != nil {
+		t.Fatal(err)
+	}
+	db, _ := w.searchDB(false)
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM docs WHERE talker = 'wxid_a'").Scan(&count); err != nil || count != 0 {
+		t.Errorf("docs of wxid_a after purge = %d, %v", count, err)
+	}
+}
@@ -0,0 +1,390 @@
+package wechatdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// StatsFile 工作目录中按小时汇总的消息统计，由 BuildStats 生成
+const StatsFile = "chatlog_stats.db"
+
+// statsSchema hourly 为每个会话、发送人、整点的消息数量，talkers 为已汇总的会话，meta.until 之前的消息已汇总
+var statsSchema = []string{
+	`CREATE TABLE IF NOT EXISTS hourly (
+		talker TEXT NOT NULL,
+		hour INTEGER NOT NULL,
+		sender TEXT NOT NULL,
+		messages INTEGER NOT NULL,
+		texts INTEGER NOT NULL,
+		media INTEGER NOT NULL,
+		PRIMARY KEY (talker, hour, sender)
+	) WITHOUT ROWID`,
+	`CREATE INDEX IF NOT EXISTS hourly_hour ON hourly (hour)`,
+	`CREATE TABLE IF NOT EXISTS talkers (talker TEXT PRIMARY KEY) WITHOUT ROWID`,
+	`CREATE TABLE IF NOT EXISTS meta (key TEXT PRIMARY KEY, value INTEGER NOT NULL)`,
+}
+
+type hourKey struct {
+	talker string
+	sender string
+	hour   int64
+}
+
+// hourStats 按会话、发送人、整点汇总消息数量
+type hourStats map[hourKey]*model.HourStat
+
+func (s hourStats) add(msg *model.Message) {
+	sender := msg.Sender
+	if msg.Type == 10000 {
+		sender = ""
+	}
+	hour := hourOf(msg.Time)
+	key := hourKey{talker: msg.Talker, sender: sender, hour: hour.Unix()}
+	stat, ok := s[key]
+	if !ok {
+		stat = &model.HourStat{Talker: msg.Talker, Sender: sender, Hour: hour}
+		s[key] = stat
+	}
+	stat.Messages++
+	switch {
+	case msg.Type == 1:
+		stat.Texts++
+	case msg.IsMedia():
+		stat.Media++
+	}
+}
+
+func (s hourStats) list() []*model.HourStat {
+	ret := make([]*model.HourStat, 0, len(s))
+	for _, stat := range s {
+		ret = append(ret, stat)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if !ret[i].Hour.Equal(ret[j].Hour) {
+			return ret[i].Hour.Before(ret[j].Hour)
+		}
+		if ret[i].Talker != ret[j].Talker {
+			return ret[i].Talker < ret[j].Talker
+		}
+		return ret[i].Sender < ret[j].Sender
+	})
+	return ret
+}
+
+// hourOf 时间所在的本地整点
+func hourOf(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.Local)
+}
+
+// statsDB 打开统计数据库，create 为 false 且尚未生成时返回 nil
+// 加密的工作目录不生成统计，避免会话与发送人信息以明文落盘
+func (w *DB) statsDB(create bool) (*sql.DB, error) {
+	if w.key != nil {
+		return nil, errors.ErrWorkDirEncrypted
+	}
+	w.statsMu.Lock()
+	defer w.statsMu.Unlock()
+	if w.stats != nil {
+		return w.stats, nil
+	}
+
+	path := filepath.Join(w.path, StatsFile)
+	if _, err := os.Stat(path); err != nil && !create {
+		return nil, nil
+	}
+	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?_journal_mode=WAL&_busy_timeout=5000", path))
+	if err != nil {
+		return nil, errors.DBConnectFailed(path, err)
+	}
+	for _, query := range statsSchema {
+		if _, err := db.Exec(query); err != nil {
+			db.Close()
+			return nil, errors.QueryFailed(query, err)
+		}
+	}
+	w.stats = db
+	return db, nil
+}
+
+// statsUntil 统计覆盖的截止时间与已汇总的会话，尚未生成统计时返回零值
+func (w *DB) statsUntil(ctx context.Context, db *sql.DB) (time.Time, map[string]bool, error) {
+	var until int64
+	query := "SELECT value FROM meta WHERE key = 'until'"
+	if err := db.QueryRowContext(ctx, query).Scan(&until); err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, nil, nil
+		}
+		return time.Time{}, nil, errors.QueryFailed(query, err)
+	}
+
+	query = "SELECT talker FROM talkers"
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return time.Time{}, nil, errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+	talkers := make(map[string]bool)
+	for rows.Next() {
+		var talker string
+		if err := rows.Scan(&talker); err != nil {
+			return time.Time{}, nil, errors.ScanRowFailed(err)
+		}
+		talkers[talker] = true
+	}
+	return time.Unix(until, 0), talkers, rows.Err()
+}
+
+// collectStats 汇总会话在时间范围内的消息，time range not found 等未找到的情况视为没有消息
+func (w *DB) collectStats(ctx context.Context, stats hourStats, start, end time.Time, talkers []string) error {
+	if len(talkers) == 0 || end.Before(start) {
+		return nil
+	}
+	err := w.repo.IterMessages(ctx, start, end, strings.Join(talkers, ","), "", "", "", false, func(msg *model.Message) error {
+		stats.add(msg)
+		return nil
+	})
+	if e, ok := err.(*errors.Error); ok && e.Code == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+// writeStats 替换 talkers 的统计，all 为 true 时替换全部统计并更新截止时间
+func writeStats(ctx context.Context, db *sql.DB, stats hourStats, talkers []string, until time.Time, all bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.QueryFailed("BEGIN", err)
+	}
+	defer tx.Rollback()
+
+	exec := func(query string, args ...interface{}) error {
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return errors.QueryFailed(query, err)
+		}
+		return nil
+	}
+
+	if all {
+		for _, query := range []string{"DELETE FROM hourly", "DELETE FROM talkers"} {
+			if err := exec(query); err != nil {
+				return err
+			}
+		}
+	} else {
+		for _, talker := range talkers {
+			if err := exec("DELETE FROM hourly WHERE talker = ?", talker); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, talker := range talkers {
+		if err := exec("INSERT OR IGNORE INTO talkers (talker) VALUES (?)", talker); err != nil {
+			return err
+		}
+	}
+	for _, stat := range stats {
+		if err := exec("INSERT INTO hourly (talker, hour, sender, messages, texts, media) VALUES (?, ?, ?, ?, ?, ?)",
+			stat.Talker, stat.Hour.Unix(), stat.Sender, stat.Messages, stat.Texts, stat.Media); err != nil {
+			return err
+		}
+	}
+	if all {
+		if err := exec("INSERT OR REPLACE INTO meta (key, value) VALUES ('until', ?)", until.Unix()); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.QueryFailed("COMMIT", err)
+	}
+	return nil
+}
+
+// BuildStats 汇总最近会话列表中所有会话在当前整点之前的消息，替换已有的统计，返回汇总的会话数量
+// 之后新增的消息在查询时直接统计，重新解密后需要重新汇总
+func (w *DB) BuildStats(ctx context.Context) (int, error) {
+	db, err := w.statsDB(true)
+	if err != nil {
+		return 0, err
+	}
+
+	sessions, err := w.repo.GetSessions(ctx, "", 0, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	until := hourOf(time.Now())
+	start, _, _ := util.TimeRangeOf("all")
+	stats := make(hourStats)
+	talkers := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		// 汇总失败的会话不计入，避免保存不完整的统计
+		talkerStats := make(hourStats)
+		if err := w.collectStats(ctx, talkerStats, start, until.Add(-time.Second), []string{session.UserName}); err != nil {
+			if ctx.Err() != nil {
+				return 0, err
+			}
+			log.Debug().Err(err).Msgf("collect stats of %s failed", session.UserName)
+			continue
+		}
+		for key, stat := range talkerStats {
+			stats[key] = stat
+		}
+		talkers = append(talkers, session.UserName)
+	}
+
+	if err := writeStats(ctx, db, stats, talkers, until, true); err != nil {
+		return 0, err
+	}
+	return len(talkers), nil
+}
+
+// refreshStats 删除消息后重新汇总会话，未生成统计或会话未汇总时不做处理
+func (w *DB) refreshStats(ctx context.Context, talker string) {
+	db, err := w.statsDB(false)
+	if err != nil || db == nil {
+		return
+	}
+	until, covered, err := w.statsUntil(ctx, db)
+	if err != nil || until.IsZero() {
+		return
+	}
+
+	for _, id := range w.repo.ResolveTalkers(ctx, talker) {
+		if !covered[id] {
+			continue
+		}
+		start, _, _ := util.TimeRangeOf("all")
+		stats := make(hourStats)
+		err := w.collectStats(ctx, stats, start, until.Add(-time.Second), []string{id})
+		if err == nil {
+			err = writeStats(ctx, db, stats, []string{id}, until, false)
+		}
+		if err != nil {
+			// 无法重新汇总时移出已汇总的会话，查询时改为直接统计
+			log.Err(err).Msgf("refresh stats of %s failed", id)
+			db.ExecContext(ctx, "DELETE FROM talkers WHERE talker = ?", id)
+		}
+	}
+}
+
+// GetStats 按会话、发送人、整点统计时间范围内的消息数量，talker 为空时统计最近会话列表中的所有会话
+// 已汇总的整点读取统计数据库，范围两端不足一小时的部分与汇总之后的新消息直接统计
+func (w *DB) GetStats(ctx context.Context, start, end time.Time, talker string) ([]*model.HourStat, error) {
+	excluded := w.excludedIDs()
+	talkers, ok := w.allowedTalkers(excluded, talker)
+	if !ok {
+		return nil, errors.TalkerNotFound(talker)
+	}
+
+	var ids []string
+	if talker == "" {
+		sessions, err := w.repo.GetSessions(ctx, "", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range sessions {
+			if !excluded[session.UserName] {
+				ids = append(ids, session.UserName)
+			}
+		}
+	} else {
+		ids = w.repo.ResolveTalkers(ctx, talkers)
+	}
+
+	var until time.Time
+	var covered map[string]bool
+	db, err := w.statsDB(false)
+	if err != nil && err != errors.ErrWorkDirEncrypted {
+		return nil, err
+	}
+	if db != nil {
+		if until, covered, err = w.statsUntil(ctx, db); err != nil {
+			return nil, err
+		}
+	}
+
+	// 统计数据库覆盖的整点范围 [from, to)
+	from := hourOf(start)
+	if from.Before(start) {
+		from = from.Add(time.Hour)
+	}
+	to := hourOf(end.Add(time.Second))
+	if until.Before(to) {
+		to = until
+	}
+
+	stats := make(hourStats)
+	direct := make([]string, 0)
+	summarized := make([]string, 0)
+	for _, id := range ids {
+		if covered[id] && from.Before(to) {
+			summarized = append(summarized, id)
+		} else {
+			direct = append(direct, id)
+		}
+	}
+
+	if err := w.collectStats(ctx, stats, start, end, direct); err != nil {
+		return nil, err
+	}
+	if len(summarized) == 0 {
+		return stats.list(), nil
+	}
+
+	if err := w.collectStats(ctx, stats, start, from.Add(-time.Second), summarized); err != nil {
+		return nil, err
+	}
+	if err := w.collectStats(ctx, stats, to, end, summarized); err != nil {
+		return nil, err
+	}
+
+	query := "SELECT talker, hour, sender, messages, texts, media FROM hourly WHERE hour >= ? AND hour < ?"
+	args := []interface{}{from.Unix(), to.Unix()}
+	if len(summarized) == 1 {
+		query += " AND talker = ?"
+		args = append(args, summarized[0])
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+
+	wanted := make(map[string]bool, len(summarized))
+	for _, id := range summarized {
+		wanted[id] = true
+	}
+	for rows.Next() {
+		var hour int64
+		stat := &model.HourStat{}
+		if err := rows.Scan(&stat.Talker, &hour, &stat.Sender, &stat.Messages, &stat.Texts, &stat.Media); err != nil {
+			return nil, errors.ScanRowFailed(err)
+		}
+		if !wanted[stat.Talker] {
+			continue
+		}
+		stat.Hour = time.Unix(hour, 0)
+		stats[hourKey{talker: stat.Talker, sender: stat.Sender, hour: hour}] = stat
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.QueryFailed(query, err)
+	}
+
+	return stats.list(), nil
+}
@@ -2,11 +2,19 @@ package wechatdb
 
 import (
 	"context"
+	"database/sql"
+	"net/http"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/sjzar/chatlog/internal/errors"
 	"github.com/sjzar/chatlog/internal/model"
 	"github.com/sjzar/chatlog/internal/wechatdb/datasource"
 	"github.com/sjzar/chatlog/internal/wechatdb/repository"
+	"github.com/sjzar/chatlog/pkg/util"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -15,16 +23,31 @@ type DB struct {
 	path     string
 	platform string
 	version  int
+	key      []byte
 	ds       datasource.DataSource
 	repo     *repository.Repository
+
+	// 不对外提供的会话，配置重新加载时整体替换
+	excludeMu sync.RWMutex
+	exclude   []string
+
+	// 按小时汇总的消息统计，首次使用时打开
+	statsMu sync.Mutex
+	stats   *sql.DB
+
+	// 消息全文索引，首次使用时打开
+	searchMu sync.Mutex
+	search   *sql.DB
 }
 
-func New(path string, platform string, version int) (*DB, error) {
+// key 为工作目录的加密密钥，未加密时为 nil
+func New(path string, platform string, version int, key []byte) (*DB, error) {
 
 	w := &DB{
 		path:     path,
 		platform: platform,
 		version:  version,
+		key:      key,
 	}
 
 	// 初始化，加载数据库文件信息
@@ -36,6 +59,18 @@ func New(path string, platform string, version int) (*DB, error) {
 }
 
 func (w *DB) Close() error {
+	w.statsMu.Lock()
+	if w.stats != nil {
+		w.stats.Close()
+		w.stats = nil
+	}
+	w.statsMu.Unlock()
+	w.searchMu.Lock()
+	if w.search != nil {
+		w.search.Close()
+		w.search = nil
+	}
+	w.searchMu.Unlock()
 	if w.repo != nil {
 		return w.repo.Close()
 	}
@@ -44,7 +79,7 @@ func (w *DB) Close() error {
 
 func (w *DB) Initialize() error {
 	var err error
-	w.ds, err = datasource.New(w.path, w.platform, w.version)
+	w.ds, err = datasource.New(w.path, w.platform, w.version, w.key)
 	if err != nil {
 		return err
 	}
@@ -57,24 +92,217 @@ func (w *DB) Initialize() error {
 	return nil
 }
 
-func (w *DB) GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
-	ctx := context.Background()
+// SetCallback 注册数据库文件变更回调，name 为 message、contact、chatroom 等数据分组
+func (w *DB) SetCallback(name string, callback func(event fsnotify.Event) error) error {
+	return w.repo.SetCallback(name, callback)
+}
+
+func (w *DB) GetMessages(ctx context.Context, start, end time.Time, talker string, sender string, keyword string, msgType string, desc bool, limit, offset int) ([]*model.Message, error) {
+	excluded := w.excludedIDs()
+	talkers, ok := w.allowedTalkers(excluded, talker)
+	if !ok {
+		return nil, errors.TalkerNotFound(talker)
+	}
+
+	// 只按关键词查询时优先使用全文索引
+	if sender == "" && msgType == "" {
+		if messages, ok, err := w.searchMessages(ctx, start, end, talkers, keyword, desc, limit, offset); ok {
+			return messages, err
+		}
+	}
+
+	// 多个会话分页时分别查询后合并，以逗号分隔一次查询时分页结果会集中在第一个会话
+	if ids := util.Str2List(talkers, ","); limit > 0 && len(ids) > 1 {
+		return w.talkerMessages(ctx, ids, start, end, sender, keyword, msgType, desc, limit, offset)
+	}
 
 	// 使用 repository 获取消息
-	messages, err := w.repo.GetMessages(ctx, start, end, talker, sender, keyword, limit, offset)
+	return w.repo.GetMessages(ctx, start, end, talkers, sender, keyword, msgType, desc, limit, offset)
+}
+
+// talkerMessages 分别查询每个会话的前 offset+limit 条消息，按时间交错合并后再分页
+// 不存在的会话跳过，全部不存在时返回错误
+func (w *DB) talkerMessages(ctx context.Context, talkers []string, start, end time.Time, sender string, keyword string, msgType string, desc bool, limit, offset int) ([]*model.Message, error) {
+	n := 0
+	if limit > 0 {
+		n = offset + limit
+	}
+	var _err error
+	found := false
+	merged := make([]*model.Message, 0)
+	for _, talker := range talkers {
+		messages, err := w.repo.GetMessages(ctx, start, end, talker, sender, keyword, msgType, desc, n, 0)
+		if e, ok := err.(*errors.Error); ok && e.Code == http.StatusNotFound {
+			_err = err
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		merged = append(merged, messages...)
+	}
+	if !found && _err != nil {
+		return nil, _err
+	}
+	sortByTime(merged, desc)
+	return excludeItems(merged, nil, func(m *model.Message) string { return m.Talker }, limit, offset), nil
+}
+
+// sortByTime 按消息时间排序，时间相同时保持原有顺序
+func sortByTime(messages []*model.Message, desc bool) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		if desc {
+			return messages[i].Time.After(messages[j].Time)
+		}
+		return messages[i].Time.Before(messages[j].Time)
+	})
+}
+
+// IterMessages 逐条读取消息，不在内存中保留结果；单个会话时按时间顺序回调，多个会话时按会话分组回调
+// fn 返回 errors.ErrIterStop 时提前结束，不作为错误返回
+func (w *DB) IterMessages(ctx context.Context, start, end time.Time, talker string, sender string, keyword string, msgType string, desc bool, fn func(*model.Message) error) error {
+	excluded := w.excludedIDs()
+	talkers, ok := w.allowedTalkers(excluded, talker)
+	if !ok {
+		return errors.TalkerNotFound(talker)
+	}
+
+	err := w.repo.IterMessages(ctx, start, end, talkers, sender, keyword, msgType, desc, fn)
+	if err == errors.ErrIterStop {
+		return nil
+	}
+	return err
+}
+
+// GetMessage 按消息序号获取单条消息
+func (w *DB) GetMessage(ctx context.Context, talker string, seq int64) (*model.Message, error) {
+	if w.isExcluded(w.excludedIDs(), talker) {
+		return nil, errors.TalkerNotFound(talker)
+	}
+	return w.repo.GetMessage(ctx, talker, seq)
+}
+
+// GetMessageContext 获取指定消息前后的消息
+func (w *DB) GetMessageContext(ctx context.Context, talker string, seq int64, before, after int) ([]*model.Message, int, error) {
+	if w.isExcluded(w.excludedIDs(), talker) {
+		return nil, 0, errors.TalkerNotFound(talker)
+	}
+	return w.repo.GetMessageContext(ctx, talker, seq, before, after)
+}
+
+// CountMessages 统计消息数量，talker 为空时统计所有会话
+func (w *DB) CountMessages(ctx context.Context, start, end time.Time, talker string) (int, error) {
+	excluded := w.excludedIDs()
+	talkers, ok := w.allowedTalkers(excluded, talker)
+	if !ok {
+		return 0, errors.TalkerNotFound(talker)
+	}
+	count, err := w.repo.CountMessages(ctx, start, end, talkers)
+	if err != nil || talker != "" {
+		return count, err
+	}
+
+	// 统计所有会话时减去被排除会话的消息数
+	for id := range excluded {
+		n, err := w.repo.CountMessages(ctx, start, end, id)
+		if err != nil {
+			continue
+		}
+		count -= n
+	}
+	return count, nil
+}
+
+// DeleteMessages 从工作目录中删除会话在时间范围内的消息，返回删除的消息数量
+// 为避免误删，必须指定会话
+func (w *DB) DeleteMessages(start, end time.Time, talker string) (int, error) {
+	if talker == "" {
+		return 0, errors.ErrTalkerEmpty
+	}
+	talkers, ok := w.allowedTalkers(w.excludedIDs(), talker)
+	if !ok {
+		return 0, errors.TalkerNotFound(talker)
+	}
+	n, err := w.repo.DeleteMessages(context.Background(), start, end, talkers)
 	if err != nil {
-		return nil, err
+		return n, err
+	}
+	w.refreshStats(context.Background(), talkers)
+	w.removeSearch(context.Background(), talkers, start, end)
+	return n, nil
+}
+
+// PurgeTalker 从工作目录中删除会话的消息、联系人与最近会话记录，返回删除的消息数量
+// talker 需为完整的微信 ID 或群 ID
+func (w *DB) PurgeTalker(talker string) (int, error) {
+	if talker == "" {
+		return 0, errors.ErrTalkerEmpty
+	}
+	if w.isExcluded(w.excludedIDs(), talker) {
+		return 0, errors.TalkerNotFound(talker)
 	}
+	n, err := w.repo.PurgeTalker(context.Background(), talker)
+	if err != nil {
+		return n, err
+	}
+	w.refreshStats(context.Background(), talker)
+	w.removeSearch(context.Background(), talker, time.Time{}, time.Time{})
+	return n, nil
+}
+
+// BuildIndexes 在工作目录的消息数据库中建立辅助索引，返回建立索引的表数量
+func (w *DB) BuildIndexes() (int, error) {
+	return w.repo.BuildIndexes(context.Background())
+}
 
-	return messages, nil
+func (w *DB) CountContacts(ctx context.Context) (int, error) {
+	if len(w.excludeKeys()) > 0 {
+		resp, err := w.GetContacts(ctx, "", 0, 0)
+		if err != nil {
+			return 0, err
+		}
+		return len(resp.Items), nil
+	}
+	return w.repo.CountContacts(ctx)
+}
+
+func (w *DB) CountChatRooms(ctx context.Context) (int, error) {
+	if len(w.excludeKeys()) > 0 {
+		resp, err := w.GetChatRooms(ctx, "", 0, 0)
+		if err != nil {
+			return 0, err
+		}
+		return len(resp.Items), nil
+	}
+	return w.repo.CountChatRooms(ctx)
+}
+
+func (w *DB) CountSessions(ctx context.Context) (int, error) {
+	if len(w.excludeKeys()) > 0 {
+		resp, err := w.GetSessions(ctx, "", 0, 0)
+		if err != nil {
+			return 0, err
+		}
+		return len(resp.Items), nil
+	}
+	return w.repo.CountSessions(ctx)
 }
 
 type GetContactsResp struct {
 	Items []*model.Contact `json:"items"`
 }
 
-func (w *DB) GetContacts(key string, limit, offset int) (*GetContactsResp, error) {
-	ctx := context.Background()
+func (w *DB) GetContacts(ctx context.Context, key string, limit, offset int) (*GetContactsResp, error) {
+	if excluded := w.excludedIDs(); len(excluded) > 0 {
+		contacts, err := w.repo.GetContacts(ctx, key, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &GetContactsResp{
+			Items: excludeItems(contacts, excluded, func(c *model.Contact) string { return c.UserName }, limit, offset),
+		}, nil
+	}
 
 	contacts, err := w.repo.GetContacts(ctx, key, limit, offset)
 	if err != nil {
@@ -90,8 +318,16 @@ type GetChatRoomsResp struct {
 	Items []*model.ChatRoom `json:"items"`
 }
 
-func (w *DB) GetChatRooms(key string, limit, offset int) (*GetChatRoomsResp, error) {
-	ctx := context.Background()
+func (w *DB) GetChatRooms(ctx context.Context, key string, limit, offset int) (*GetChatRoomsResp, error) {
+	if excluded := w.excludedIDs(); len(excluded) > 0 {
+		chatRooms, err := w.repo.GetChatRooms(ctx, key, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &GetChatRoomsResp{
+			Items: excludeItems(chatRooms, excluded, func(c *model.ChatRoom) string { return c.Name }, limit, offset),
+		}, nil
+	}
 
 	chatRooms, err := w.repo.GetChatRooms(ctx, key, limit, offset)
 	if err != nil {
@@ -107,8 +343,16 @@ type GetSessionsResp struct {
 	Items []*model.Session `json:"items"`
 }
 
-func (w *DB) GetSessions(key string, limit, offset int) (*GetSessionsResp, error) {
-	ctx := context.Background()
+func (w *DB) GetSessions(ctx context.Context, key string, limit, offset int) (*GetSessionsResp, error) {
+	if excluded := w.excludedIDs(); len(excluded) > 0 {
+		sessions, err := w.repo.GetSessions(ctx, key, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &GetSessionsResp{
+			Items: excludeItems(sessions, excluded, func(s *model.Session) string { return s.UserName }, limit, offset),
+		}, nil
+	}
 
 	// 使用 repository 获取会话列表
 	sessions, err := w.repo.GetSessions(ctx, key, limit, offset)
@@ -121,6 +365,6 @@ func (w *DB) GetSessions(key string, limit, offset int) (*GetSessionsResp, error
 	}, nil
 }
 
-func (w *DB) GetMedia(_type string, key string) (*model.Media, error) {
-	return w.repo.GetMedia(context.Background(), _type, key)
+func (w *DB) GetMedia(ctx context.Context, _type string, key string) (*model.Media, error) {
+	return w.repo.GetMedia(ctx, _type, key)
 }
@@ -0,0 +1,68 @@
+package autostart
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrUnsupported = errors.New("service installation is not supported on this platform")
+
+// Config 注册为系统服务的程序
+type Config struct {
+	// Name 服务名，macOS 下的 launchd 标签为 com.sjzar.<Name>
+	Name        string
+	DisplayName string
+	Description string
+
+	// Exec 可执行文件的绝对路径，Args 为启动参数
+	Exec string
+	Args []string
+
+	// Env 额外的环境变量，格式为 KEY=VALUE
+	Env []string
+
+	// LogFile 标准输出与错误的写入位置，仅用于 macOS；Linux 下写入 journald，Windows 服务没有标准输出
+	LogFile string
+}
+
+// Status 服务的安装与运行状态
+type Status struct {
+	Name      string `json:"name"`
+	Installed bool   `json:"installed"`
+	Running   bool   `json:"running"`
+	PID       int    `json:"pid,omitempty"`
+
+	// File 服务定义文件，Windows 下为空
+	File string `json:"file,omitempty"`
+}
+
+// Install 注册服务并立即启动，已注册时覆盖原有定义
+func Install(c Config) error {
+	if c.Name == "" || c.Exec == "" {
+		return errors.New("service name and executable are required")
+	}
+	return install(c)
+}
+
+// Uninstall 停止并删除服务，未注册时不返回错误
+func Uninstall(name string) error {
+	return uninstall(name)
+}
+
+// Query 查询服务状态
+func Query(name string) (*Status, error) {
+	return query(name)
+}
+
+// envOf 拆分 KEY=VALUE 格式的环境变量
+func envOf(env []string) [][2]string {
+	ret := make([][2]string, 0, len(env))
+	for _, kv := range env {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			continue
+		}
+		ret = append(ret, [2]string{k, v})
+	}
+	return ret
+}
@@ -0,0 +1,157 @@
+//go:build darwin
+
+package autostart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func label(name string) string {
+	return "com.sjzar." + name
+}
+
+// plistPath 返回 launchd 用户代理的定义文件
+func plistPath(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", label(name)+".plist"), nil
+}
+
+// domain 当前用户的 launchd 域
+func domain() string {
+	return "gui/" + strconv.Itoa(os.Getuid())
+}
+
+func plistString(b *bytes.Buffer, s string) {
+	b.WriteString("<string>")
+	xml.EscapeText(b, []byte(s))
+	b.WriteString("</string>\n")
+}
+
+func plistFile(c Config) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+<key>Label</key>
+`)
+	plistString(&b, label(c.Name))
+	b.WriteString("<key>ProgramArguments</key>\n<array>\n")
+	plistString(&b, c.Exec)
+	for _, arg := range c.Args {
+		plistString(&b, arg)
+	}
+	b.WriteString("</array>\n")
+	if env := envOf(c.Env); len(env) > 0 {
+		b.WriteString("<key>EnvironmentVariables</key>\n<dict>\n")
+		for _, kv := range env {
+			b.WriteString("<key>")
+			xml.EscapeText(&b, []byte(kv[0]))
+			b.WriteString("</key>\n")
+			plistString(&b, kv[1])
+		}
+		b.WriteString("</dict>\n")
+	}
+	if c.LogFile != "" {
+		b.WriteString("<key>StandardOutPath</key>\n")
+		plistString(&b, c.LogFile)
+		b.WriteString("<key>StandardErrorPath</key>\n")
+		plistString(&b, c.LogFile)
+	}
+	// 登录时启动，异常退出后重新启动
+	b.WriteString(`<key>RunAtLoad</key>
+<true/>
+<key>KeepAlive</key>
+<dict>
+<key>SuccessfulExit</key>
+<false/>
+</dict>
+<key>ExitTimeOut</key>
+<integer>40</integer>
+</dict>
+</plist>
+`)
+	return b.Bytes()
+}
+
+func launchctl(args ...string) (string, error) {
+	out, err := exec.Command("launchctl", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("launchctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+func install(c Config) error {
+	path, err := plistPath(c.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if c.LogFile != "" {
+		if err := os.MkdirAll(filepath.Dir(c.LogFile), 0755); err != nil {
+			return err
+		}
+	}
+	// 已加载时先卸载，使新的定义生效
+	launchctl("bootout", domain()+"/"+label(c.Name))
+	if err := os.WriteFile(path, plistFile(c), 0644); err != nil {
+		return err
+	}
+	_, err = launchctl("bootstrap", domain(), path)
+	return err
+}
+
+func uninstall(name string) error {
+	path, err := plistPath(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	launchctl("bootout", domain()+"/"+label(name))
+	return os.Remove(path)
+}
+
+func query(name string) (*Status, error) {
+	path, err := plistPath(name)
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{Name: label(name), File: path}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return status, nil
+	}
+	status.Installed = true
+	out, err := launchctl("print", domain()+"/"+label(name))
+	if err != nil {
+		// 定义文件存在但未加载
+		return status, nil
+	}
+	for _, line := range strings.Split(out, "\n") {
+		k, v, ok := strings.Cut(strings.TrimSpace(line), " = ")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "state":
+			status.Running = v == "running"
+		case "pid":
+			status.PID, _ = strconv.Atoi(v)
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package autostart
+
+// IsService 当前进程是否由 Windows 服务管理器启动，其他平台总是返回 false
+func IsService() bool {
+	return false
+}
+
+// Run 执行 run，systemd 与 launchd 通过 SIGTERM 停止服务，由 run 自行处理
+func Run(name string, run func() error, stop func()) error {
+	return run()
+}
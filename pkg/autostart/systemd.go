@@ -0,0 +1,128 @@
+//go:build linux
+
+package autostart
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// unitPath 返回 systemd 用户服务的定义文件
+func unitPath(name string) (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "systemd", "user", name+".service"), nil
+}
+
+// unitQuote 按 systemd 的规则引用命令行参数，% 与 $ 需要转义
+func unitQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "$", "$$").Replace(s)
+	return `"` + s + `"`
+}
+
+func unitFile(c Config) []byte {
+	var b bytes.Buffer
+	description := c.Description
+	if description == "" {
+		description = c.Name
+	}
+	fmt.Fprintf(&b, "[Unit]\nDescription=%s\nAfter=network.target\n\n", description)
+
+	b.WriteString("[Service]\n")
+	cmd := []string{unitQuote(c.Exec)}
+	for _, arg := range c.Args {
+		cmd = append(cmd, unitQuote(arg))
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(cmd, " "))
+	for _, kv := range envOf(c.Env) {
+		fmt.Fprintf(&b, "Environment=%s\n", unitQuote(kv[0]+"="+kv[1]))
+	}
+	// 收到 SIGTERM 后等待进行中的请求完成
+	b.WriteString("Restart=on-failure\nRestartSec=5\nKillSignal=SIGTERM\nTimeoutStopSec=40\n\n")
+
+	b.WriteString("[Install]\nWantedBy=default.target\n")
+	return b.Bytes()
+}
+
+func systemctl(args ...string) (string, error) {
+	out, err := exec.Command("systemctl", append([]string{"--user"}, args...)...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("systemctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func install(c Config) error {
+	path, err := unitPath(c.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, unitFile(c), 0644); err != nil {
+		return err
+	}
+	if _, err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	if _, err := systemctl("enable", c.Name+".service"); err != nil {
+		return err
+	}
+	_, err = systemctl("restart", c.Name+".service")
+	return err
+}
+
+func uninstall(name string) error {
+	path, err := unitPath(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	// 服务未加载时停止失败，仍删除定义文件
+	systemctl("disable", "--now", name+".service")
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	_, err = systemctl("daemon-reload")
+	return err
+}
+
+func query(name string) (*Status, error) {
+	path, err := unitPath(name)
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{Name: name, File: path}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return status, nil
+	}
+	status.Installed = true
+	out, err := systemctl("show", name+".service", "--property=ActiveState,MainPID")
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		k, v, _ := strings.Cut(line, "=")
+		switch k {
+		case "ActiveState":
+			status.Running = v == "active"
+		case "MainPID":
+			status.PID, _ = strconv.Atoi(v)
+		}
+	}
+	return status, nil
+}
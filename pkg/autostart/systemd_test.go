@@ -0,0 +1,30 @@
+//go:build linux
+
+package autostart
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnitFile(t *testing.T) {
+	unit := string(unitFile(Config{
+		Name: "chatlog",
+		Exec: "/opt/chat log/chatlog",
+		Args: []string{"server", "-w", `/home/a "b"/100%`, "-r", "$HOME"},
+		Env:  []string{"CHATLOG_DIR=/home/a/.chatlog", "INVALID"},
+	}))
+	for _, want := range []string{
+		"Description=chatlog\n",
+		`ExecStart="/opt/chat log/chatlog" "server" "-w" "/home/a \"b\"/100%%" "-r" "$$HOME"` + "\n",
+		`Environment="CHATLOG_DIR=/home/a/.chatlog"` + "\n",
+		"WantedBy=default.target\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit file missing %q:\n%s", want, unit)
+		}
+	}
+	if strings.Contains(unit, "INVALID") {
+		t.Errorf("unit file contains invalid env:\n%s", unit)
+	}
+}
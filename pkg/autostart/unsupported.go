@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !windows
+
+package autostart
+
+func install(c Config) error {
+	return ErrUnsupported
+}
+
+func uninstall(name string) error {
+	return ErrUnsupported
+}
+
+func query(name string) (*Status, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,189 @@
+//go:build windows
+
+package autostart
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// stopTimeout 等待服务停止的最长时间，包含处理进行中请求的时间
+const stopTimeout = 40 * time.Second
+
+func install(c Config) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect to service manager (run as administrator): %w", err)
+	}
+	defer m.Disconnect()
+
+	// 已注册时删除后重新创建，使新的参数生效
+	if s, err := m.OpenService(c.Name); err == nil {
+		stopService(s)
+		s.Delete()
+		s.Close()
+		waitDeleted(m, c.Name)
+	}
+
+	s, err := m.CreateService(c.Name, c.Exec, mgr.Config{
+		DisplayName: c.DisplayName,
+		Description: c.Description,
+		StartType:   mgr.StartAutomatic,
+	}, c.Args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	s.SetRecoveryActions([]mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: 5 * time.Second}}, 60)
+
+	if env := envOf(c.Env); len(env) > 0 {
+		k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\`+c.Name, registry.SET_VALUE)
+		if err != nil {
+			return err
+		}
+		values := make([]string, 0, len(env))
+		for _, kv := range env {
+			values = append(values, kv[0]+"="+kv[1])
+		}
+		err = k.SetStringsValue("Environment", values)
+		k.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return s.Start()
+}
+
+func uninstall(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect to service manager (run as administrator): %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return nil
+	}
+	defer s.Close()
+	stopService(s)
+	return s.Delete()
+}
+
+func query(name string) (*Status, error) {
+	status := &Status{Name: name}
+	h, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_CONNECT)
+	if err != nil {
+		return nil, err
+	}
+	defer windows.CloseServiceHandle(h)
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	s, err := windows.OpenService(h, namePtr, windows.SERVICE_QUERY_STATUS)
+	if err != nil {
+		if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+			return status, nil
+		}
+		return nil, err
+	}
+	defer windows.CloseServiceHandle(s)
+	status.Installed = true
+
+	var p windows.SERVICE_STATUS_PROCESS
+	var needed uint32
+	if err := windows.QueryServiceStatusEx(s, windows.SC_STATUS_PROCESS_INFO, (*byte)(unsafe.Pointer(&p)), uint32(unsafe.Sizeof(p)), &needed); err != nil {
+		return nil, err
+	}
+	status.Running = p.CurrentState == windows.SERVICE_RUNNING
+	status.PID = int(p.ProcessId)
+	return status, nil
+}
+
+// stopService 停止服务并等待其退出
+func stopService(s *mgr.Service) {
+	st, err := s.Control(svc.Stop)
+	if err != nil {
+		return
+	}
+	deadline := time.Now().Add(stopTimeout)
+	for st.State != svc.Stopped && time.Now().Before(deadline) {
+		time.Sleep(300 * time.Millisecond)
+		if st, err = s.Query(); err != nil {
+			return
+		}
+	}
+}
+
+// waitDeleted 等待服务管理器完成删除，删除前无法创建同名服务
+func waitDeleted(m *mgr.Mgr, name string) {
+	for i := 0; i < 20; i++ {
+		s, err := m.OpenService(name)
+		if err != nil {
+			return
+		}
+		s.Close()
+		time.Sleep(300 * time.Millisecond)
+	}
+}
+
+// IsService 当前进程是否由 Windows 服务管理器启动
+func IsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+// Run 由服务管理器启动时在服务模式下执行 run，收到停止请求时调用 stop 并等待 run 返回
+// 非服务模式下直接执行 run
+func Run(name string, run func() error, stop func()) error {
+	if !IsService() {
+		return run()
+	}
+	h := &handler{run: run, stop: stop}
+	if err := svc.Run(name, h); err != nil {
+		return err
+	}
+	return h.err
+}
+
+type handler struct {
+	run  func() error
+	stop func()
+	err  error
+}
+
+func (h *handler) Execute(args []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	s <- svc.Status{State: svc.StartPending}
+	done := make(chan error, 1)
+	go func() {
+		done <- h.run()
+	}()
+	s <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case h.err = <-done:
+			if h.err != nil {
+				return true, 1
+			}
+			return false, 0
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				s <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				s <- svc.Status{State: svc.StopPending, WaitHint: uint32(stopTimeout / time.Millisecond)}
+				h.stop()
+				h.err = <-done
+				return false, 0
+			}
+		}
+	}
+}
@@ -19,6 +19,7 @@ package config
 import (
 	"errors"
 	"os"
+	"path/filepath"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
@@ -66,6 +67,14 @@ func Init(name, _type, path string) error {
 		return err
 	}
 
+	// An existing YAML file takes precedence over the default type.
+	for _, ext := range []string{"yaml", "yml"} {
+		if _, err := os.Stat(filepath.Join(path, name+"."+ext)); err == nil {
+			_type = ext
+			break
+		}
+	}
+
 	ConfigName = name
 	ConfigType = _type
 	ConfigPath = path
@@ -78,6 +87,7 @@ func Load(conf interface{}) error {
 	viper.SetConfigName(ConfigName)
 	viper.SetConfigType(ConfigType)
 	viper.AddConfigPath(ConfigPath)
+	viper.SetConfigFile(ConfigFile())
 	if err := viper.ReadInConfig(); err != nil {
 		if err := viper.SafeWriteConfig(); err != nil {
 			return err
@@ -86,10 +96,39 @@ func Load(conf interface{}) error {
 	if err := viper.Unmarshal(conf); err != nil {
 		return err
 	}
+	if err := LoadEnv(conf, EnvPrefix); err != nil {
+		return err
+	}
 	SetDefault(conf)
 	return nil
 }
 
+// Reload re-reads the configuration file and unmarshals it into conf.
+// The file is parsed into a fresh instance first, so the current settings
+// are left untouched if it is invalid.
+func Reload(conf interface{}) error {
+	v := viper.New()
+	v.SetConfigFile(ConfigFile())
+	v.SetConfigType(ConfigType)
+	if err := v.ReadInConfig(); err != nil {
+		return err
+	}
+	if err := v.Unmarshal(conf); err != nil {
+		return err
+	}
+	if err := LoadEnv(conf, EnvPrefix); err != nil {
+		return err
+	}
+	SetDefault(conf)
+	// keep the global instance in sync so that SetConfig writes back the new content
+	return viper.ReadInConfig()
+}
+
+// ConfigFile returns the path of the configuration file.
+func ConfigFile() string {
+	return filepath.Join(ConfigPath, ConfigName+"."+ConfigType)
+}
+
 // LoadFile loads the configuration from a specified file.
 // It unmarshals the configuration into the provided conf interface.
 func LoadFile(file string, conf interface{}) error {
@@ -100,6 +139,9 @@ func LoadFile(file string, conf interface{}) error {
 	if err := viper.Unmarshal(conf); err != nil {
 		return err
 	}
+	if err := LoadEnv(conf, EnvPrefix); err != nil {
+		return err
+	}
 	SetDefault(conf)
 	return nil
 }
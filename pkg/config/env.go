@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2023 shenjunzheng@gmail.com
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix is the prefix of environment variables that override the
+// configuration file, empty to disable.
+var EnvPrefix string
+
+// SetEnvPrefix updates the prefix of environment variables.
+func SetEnvPrefix(prefix string) {
+	EnvPrefix = prefix
+}
+
+// LoadEnv overrides the fields of conf with environment variables.
+// The variable name is the prefix followed by the upper-cased mapstructure
+// keys joined with underscores, e.g. PREFIX_HTTP_AUTH_PASSWORD for http.auth.password.
+// Slices of simple types are comma separated, other slices and maps are JSON.
+// The values are applied to conf only and never written back to the file.
+func LoadEnv(conf interface{}, prefix string) error {
+	if conf == nil || prefix == "" {
+		return nil
+	}
+	val := reflect.ValueOf(conf)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+	return loadEnvStruct(val, strings.ToUpper(prefix))
+}
+
+func loadEnvStruct(val reflect.Value, prefix string) error {
+	typ := val.Type()
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Field(i)
+		name, ok := envName(typ.Field(i))
+		if !ok || !field.CanSet() {
+			continue
+		}
+		key := prefix + "_" + name
+		if field.Kind() == reflect.Struct {
+			if err := loadEnvStruct(field, key); err != nil {
+				return err
+			}
+			continue
+		}
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		if err := setEnvValue(field, value); err != nil {
+			return fmt.Errorf("invalid environment variable %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// envName returns the upper-cased mapstructure key of the field.
+func envName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	tag := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+	if tag == "-" {
+		return "", false
+	}
+	if tag == "" {
+		tag = field.Name
+	}
+	return strings.ToUpper(tag), true
+}
+
+func setEnvValue(val reflect.Value, value string) error {
+	switch val.Kind() {
+	case reflect.String:
+		val.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		val.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		val.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		val.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		val.SetFloat(f)
+	case reflect.Slice:
+		if val.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(value), "[") {
+			items := make([]string, 0)
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			val.Set(reflect.ValueOf(items).Convert(val.Type()))
+			return nil
+		}
+		fallthrough
+	default:
+		ptr := reflect.New(val.Type())
+		if err := json.Unmarshal([]byte(value), ptr.Interface()); err != nil {
+			return err
+		}
+		val.Set(ptr.Elem())
+	}
+	return nil
+}
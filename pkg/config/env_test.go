@@ -0,0 +1,52 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+type envTestConfig struct {
+	Dir     string   `mapstructure:"-"`
+	Name    string   `mapstructure:"name"`
+	Timeout int      `mapstructure:"timeout" default:"120"`
+	Exclude []string `mapstructure:"exclude"`
+	HTTP    struct {
+		Redact bool    `mapstructure:"redact"`
+		Rate   float64 `mapstructure:"rate"`
+	} `mapstructure:"http"`
+	Accounts []struct {
+		Account string `json:"account"`
+	} `mapstructure:"accounts"`
+}
+
+func TestLoadEnv(t *testing.T) {
+	t.Setenv("TEST_DIR", "/ignored")
+	t.Setenv("TEST_NAME", "chatlog")
+	t.Setenv("TEST_EXCLUDE", "a@chatroom, 工作群,")
+	t.Setenv("TEST_HTTP_REDACT", "true")
+	t.Setenv("TEST_HTTP_RATE", "2.5")
+	t.Setenv("TEST_ACCOUNTS", `[{"account": "ios"}]`)
+
+	conf := &envTestConfig{Name: "file"}
+	if err := LoadEnv(conf, "test"); err != nil {
+		t.Fatal(err)
+	}
+	SetDefault(conf)
+	if conf.Dir != "" || conf.Name != "chatlog" || conf.Timeout != 120 {
+		t.Errorf("conf = %+v", conf)
+	}
+	if !reflect.DeepEqual(conf.Exclude, []string{"a@chatroom", "工作群"}) {
+		t.Errorf("exclude = %q", conf.Exclude)
+	}
+	if !conf.HTTP.Redact || conf.HTTP.Rate != 2.5 {
+		t.Errorf("http = %+v", conf.HTTP)
+	}
+	if len(conf.Accounts) != 1 || conf.Accounts[0].Account != "ios" {
+		t.Errorf("accounts = %+v", conf.Accounts)
+	}
+
+	t.Setenv("TEST_TIMEOUT", "abc")
+	if err := LoadEnv(conf, "test"); err == nil {
+		t.Error("invalid value: want error")
+	}
+}
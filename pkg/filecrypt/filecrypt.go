@@ -0,0 +1,291 @@
+package filecrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// MarkerFile 记录目录加密参数的文件，存在时表示目录中的数据库文件已加密
+	MarkerFile = ".chatlog-encrypt.json"
+
+	// chunkSize 每个加密块的明文大小
+	chunkSize = 64 * 1024
+
+	// prefixSize 随机 nonce 前缀长度，剩余 5 字节为块序号与结束标记
+	prefixSize = 7
+)
+
+// magic 加密文件头
+var magic = []byte("CLENC001")
+
+var (
+	ErrKeyRequired = errors.New("work dir is encrypted, key is required")
+	ErrWrongKey    = errors.New("wrong key for encrypted work dir")
+	ErrCorrupted   = errors.New("encrypted file is corrupted or truncated")
+)
+
+// marker 目录加密参数，check 用于校验密钥是否正确
+type marker struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Check   []byte `json:"check"`
+}
+
+var (
+	keyMu    sync.Mutex
+	keyCache = make(map[string][]byte)
+)
+
+// DirKey 根据口令获取目录的加密密钥
+// 目录未加密且口令为空时返回 nil，表示不加密；目录未加密但提供了口令时初始化加密参数
+func DirKey(dir, passphrase string) ([]byte, error) {
+	path := filepath.Join(dir, MarkerFile)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if passphrase == "" {
+		if err == nil {
+			return nil, ErrKeyRequired
+		}
+		return nil, nil
+	}
+
+	var m marker
+	if err == nil {
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+	} else {
+		m.Version = 1
+		m.Salt = make([]byte, 16)
+		if _, err := rand.Read(m.Salt); err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := deriveKey(passphrase, m.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if m.Check != nil {
+		if !hmac.Equal(m.Check, checksum(key)) {
+			return nil, ErrWrongKey
+		}
+		return key, nil
+	}
+
+	m.Check = checksum(key)
+	if data, err = json.Marshal(m); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// IsEncryptedDir 目录是否已启用加密
+func IsEncryptedDir(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, MarkerFile))
+	return err == nil
+}
+
+// deriveKey scrypt 派生密钥较慢，相同口令与 salt 只计算一次
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	h := sha256.Sum256(append([]byte(passphrase+"\x00"), salt...))
+	id := string(h[:])
+
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if key, ok := keyCache[id]; ok {
+		return key, nil
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	keyCache[id] = key
+	return key, nil
+}
+
+func checksum(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("chatlog work dir"))
+	return mac.Sum(nil)
+}
+
+// IsEncryptedFile 文件是否为加密格式
+func IsEncryptedFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(f, head); err != nil {
+		return false
+	}
+	return bytes.Equal(head, magic)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// nonce 前缀 + 4 字节块序号 + 1 字节结束标记，防止块被重排或截断
+func nonce(prefix []byte, index uint32, last bool) []byte {
+	n := make([]byte, 12)
+	copy(n, prefix)
+	binary.BigEndian.PutUint32(n[prefixSize:], index)
+	if last {
+		n[11] = 1
+	}
+	return n
+}
+
+// Writer 分块加密写入，必须调用 Close 写出最后一块
+type Writer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	index  uint32
+	buf    []byte
+	closed bool
+}
+
+// NewWriter 写出文件头并返回加密写入器
+func NewWriter(w io.Writer, key []byte) (*Writer, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, prefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(append([]byte{}, magic...), prefix...)); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead, prefix: prefix, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	n := len(p)
+	for len(p) > 0 {
+		m := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+m]
+		p = p[m:]
+		// 缓冲区满且还有后续数据时才写出，保证最后一块小于 chunkSize
+		if len(w.buf) == chunkSize && len(p) > 0 {
+			if err := w.flush(false); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (w *Writer) flush(last bool) error {
+	if len(w.buf) == chunkSize && last {
+		// 明文恰好为整块时追加一个空的结束块
+		if err := w.flush(false); err != nil {
+			return err
+		}
+	}
+	sealed := w.aead.Seal(nil, nonce(w.prefix, w.index, last), w.buf, nil)
+	w.index++
+	w.buf = w.buf[:0]
+	_, err := w.w.Write(sealed)
+	return err
+}
+
+// Close 写出最后一块，不关闭底层 Writer
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.flush(true)
+}
+
+// Decrypt 解密整个文件内容写入 dst
+func Decrypt(dst io.Writer, src io.Reader, key []byte) error {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	head := make([]byte, len(magic)+prefixSize)
+	if _, err := io.ReadFull(src, head); err != nil || !bytes.Equal(head[:len(magic)], magic) {
+		return ErrCorrupted
+	}
+	prefix := head[len(magic):]
+
+	buf := make([]byte, chunkSize+aead.Overhead())
+	for index := uint32(0); ; index++ {
+		n, err := io.ReadFull(src, buf)
+		last := false
+		switch err {
+		case nil:
+		case io.ErrUnexpectedEOF:
+			last = true
+		case io.EOF:
+			return ErrCorrupted
+		default:
+			return err
+		}
+		plain, err := aead.Open(buf[:0], nonce(prefix, index, last), buf[:n], nil)
+		if err != nil {
+			return ErrCorrupted
+		}
+		if _, err := dst.Write(plain); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// DecryptFile 将加密文件解密到 dst，dst 仅当前用户可读
+func DecryptFile(src, dst string, key []byte) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err := Decrypt(out, in, key); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,74 @@
+package filecrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3 * chunkSize} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+
+		var enc bytes.Buffer
+		w, err := NewWriter(&enc, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 分多次写入，覆盖跨块的情况
+		for p := plain; len(p) > 0; {
+			n := min(len(p), 1000)
+			if _, err := w.Write(p[:n]); err != nil {
+				t.Fatal(err)
+			}
+			p = p[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		var dec bytes.Buffer
+		if err := Decrypt(&dec, bytes.NewReader(enc.Bytes()), key); err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(dec.Bytes(), plain) {
+			t.Fatalf("size %d: plaintext mismatch", size)
+		}
+
+		// 截断到块边界也应当报错
+		if size > chunkSize {
+			truncated := enc.Bytes()[:len(magic)+prefixSize+chunkSize+16]
+			if err := Decrypt(&bytes.Buffer{}, bytes.NewReader(truncated), key); err != ErrCorrupted {
+				t.Fatalf("size %d: truncated file should fail, got %v", size, err)
+			}
+		}
+	}
+}
+
+func TestDirKey(t *testing.T) {
+	dir := t.TempDir()
+
+	if key, err := DirKey(dir, ""); key != nil || err != nil {
+		t.Fatalf("plain dir should not need a key: %v %v", key, err)
+	}
+	key, err := DirKey(dir, "secret")
+	if err != nil || len(key) != 32 {
+		t.Fatalf("init key: %v", err)
+	}
+	if !IsEncryptedDir(dir) {
+		t.Fatal("marker file not written")
+	}
+	if again, err := DirKey(dir, "secret"); err != nil || !bytes.Equal(again, key) {
+		t.Fatalf("key should be stable: %v", err)
+	}
+	if _, err := DirKey(dir, "wrong"); err != ErrWrongKey {
+		t.Fatalf("wrong key: got %v", err)
+	}
+	if _, err := DirKey(dir, ""); err != ErrKeyRequired {
+		t.Fatalf("missing key: got %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+// Package graphql 实现一个精简的 GraphQL 查询执行器，字段按名称从 Go 对象的 JSON 表示中读取，
+// 关联字段与根字段通过 Resolver 提供
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Resolver 解析字段值，source 为父对象，根字段的 source 为 nil
+type Resolver func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error)
+
+// FieldDef 字段定义，Type 为结果的对象类型名称，为空时结果按 JSON 值处理
+type FieldDef struct {
+	Type    string
+	Resolve Resolver
+}
+
+// Object 对象类型的字段定义，未定义的字段从对象的 JSON 表示中读取
+type Object map[string]*FieldDef
+
+// Schema 查询入口与对象类型
+// MaxDepth 限制查询的字段嵌套层数，MaxNodes 限制单次查询中调用 Resolver 的次数，为 0 时不限制
+type Schema struct {
+	Query    string
+	Types    map[string]Object
+	MaxDepth int
+	MaxNodes int
+}
+
+// Error 执行错误
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Result 查询结果
+type Result struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []*Error               `json:"errors,omitempty"`
+}
+
+// Execute 执行查询，语法错误时 Data 为空
+func (s *Schema) Execute(ctx context.Context, query string, variables map[string]interface{}, operationName string) *Result {
+	fields, err := Parse(query, variables, operationName)
+	if err != nil {
+		return &Result{Errors: []*Error{{Message: err.Error()}}}
+	}
+	if s.MaxDepth > 0 {
+		if depth := fieldDepth(fields); depth > s.MaxDepth {
+			return &Result{Errors: []*Error{{Message: fmt.Sprintf("query depth %d exceeds the limit of %d", depth, s.MaxDepth)}}}
+		}
+	}
+
+	e := &executor{schema: s}
+	data := e.object(ctx, s.Query, nil, fields, nil)
+	return &Result{Data: data, Errors: e.errors}
+}
+
+// fieldDepth 选择集的最大嵌套层数，顶层字段为 1
+func fieldDepth(fields []*Field) int {
+	max := 0
+	for _, f := range fields {
+		if d := 1 + fieldDepth(f.Selections); d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+type executor struct {
+	schema *Schema
+	errors []*Error
+	nodes  int
+}
+
+func (e *executor) fail(path []interface{}, err error) {
+	e.errors = append(e.errors, &Error{
+		Message: err.Error(),
+		Path:    append([]interface{}(nil), path...),
+	})
+}
+
+// count 记录一次 Resolver 调用，超出 MaxNodes 时只记录一次错误，之后的字段都不再解析
+func (e *executor) count(path []interface{}) bool {
+	e.nodes++
+	max := e.schema.MaxNodes
+	if max <= 0 || e.nodes <= max {
+		return true
+	}
+	if e.nodes == max+1 {
+		e.fail(path, fmt.Errorf("query resolves more than %d nodes", max))
+	}
+	return false
+}
+
+// object 按选择集读取对象字段
+func (e *executor) object(ctx context.Context, typeName string, source interface{}, fields []*Field, path []interface{}) map[string]interface{} {
+	obj := e.schema.Types[typeName]
+	ret := make(map[string]interface{}, len(fields))
+
+	var values map[string]interface{}
+	for _, f := range fields {
+		fieldPath := append(path, f.Key())
+		if err := ctx.Err(); err != nil {
+			e.fail(fieldPath, err)
+			return ret
+		}
+
+		if f.Name == "__typename" {
+			ret[f.Key()] = typeName
+			continue
+		}
+
+		if def, ok := obj[f.Name]; ok {
+			if !e.count(fieldPath) {
+				return ret
+			}
+			v, err := def.Resolve(ctx, source, f.Args)
+			if err != nil {
+				e.fail(fieldPath, err)
+				ret[f.Key()] = nil
+				continue
+			}
+			ret[f.Key()] = e.complete(ctx, def.Type, v, f, fieldPath)
+			continue
+		}
+
+		if source == nil {
+			e.fail(fieldPath, fmt.Errorf("cannot query field %q on type %q", f.Name, typeName))
+			continue
+		}
+		if values == nil {
+			v, err := toJSONValue(source)
+			if err != nil {
+				e.fail(fieldPath, err)
+				return ret
+			}
+			values, _ = v.(map[string]interface{})
+		}
+		v, ok := values[f.Name]
+		if !ok {
+			e.fail(fieldPath, fmt.Errorf("cannot query field %q on type %q", f.Name, typeName))
+			continue
+		}
+		ret[f.Key()] = e.selectJSON(v, f, fieldPath)
+	}
+	return ret
+}
+
+// complete 处理 Resolver 的返回值，对象类型继续按选择集展开，列表逐项展开
+func (e *executor) complete(ctx context.Context, typeName string, v interface{}, f *Field, path []interface{}) interface{} {
+	if isNil(v) {
+		return nil
+	}
+	if typeName == "" {
+		value, err := toJSONValue(v)
+		if err != nil {
+			e.fail(path, err)
+			return nil
+		}
+		return e.selectJSON(value, f, path)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		list := make([]interface{}, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			list[i] = e.complete(ctx, typeName, rv.Index(i).Interface(), f, append(path, i))
+		}
+		return list
+	}
+
+	if len(f.Selections) == 0 {
+		e.fail(path, fmt.Errorf("field %q of type %q must have a selection of subfields", f.Name, typeName))
+		return nil
+	}
+	return e.object(ctx, typeName, v, f.Selections, path)
+}
+
+// selectJSON 在 JSON 值上应用选择集，没有选择集时返回完整的值
+func (e *executor) selectJSON(v interface{}, f *Field, path []interface{}) interface{} {
+	if len(f.Selections) == 0 || v == nil {
+		return v
+	}
+	switch value := v.(type) {
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i := range value {
+			list[i] = e.selectJSON(value[i], f, append(path, i))
+		}
+		return list
+	case map[string]interface{}:
+		ret := make(map[string]interface{}, len(f.Selections))
+		for _, sub := range f.Selections {
+			if sub.Name == "__typename" {
+				ret[sub.Key()] = "JSON"
+				continue
+			}
+			ret[sub.Key()] = e.selectJSON(value[sub.Name], sub, append(path, sub.Key()))
+		}
+		return ret
+	default:
+		e.fail(path, fmt.Errorf("field %q is a scalar and cannot have a selection of subfields", f.Name))
+		return nil
+	}
+}
+
+func toJSONValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var ret interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// String 读取字符串参数
+func String(args map[string]interface{}, name string) string {
+	if v, ok := args[name].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// Int 读取整数参数，兼容变量中以 JSON 数字传入的值
+func Int(args map[string]interface{}, name string, def int) int {
+	switch v := args[name].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return int(i)
+		}
+	}
+	return def
+}
+
+// Bool 读取布尔参数
+func Bool(args map[string]interface{}, name string) bool {
+	v, _ := args[name].(bool)
+	return v
+}
@@ -0,0 +1,138 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type testUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func testSchema() *Schema {
+	users := map[string]*testUser{
+		"u1": {ID: "u1", Name: "Alice"},
+		"u2": {ID: "u2", Name: "Bob"},
+	}
+	return &Schema{
+		Query: "Query",
+		Types: map[string]Object{
+			"Query": {
+				"user": {Type: "User", Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+					u, ok := users[String(args, "id")]
+					if !ok {
+						return nil, fmt.Errorf("user not found")
+					}
+					return u, nil
+				}},
+				"users": {Type: "User", Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+					return []*testUser{users["u1"], users["u2"]}[:Int(args, "limit", 2)], nil
+				}},
+			},
+			"User": {
+				"friend": {Type: "User", Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+					if source.(*testUser).ID == "u1" {
+						return users["u2"], nil
+					}
+					return users["u1"], nil
+				}},
+			},
+		},
+	}
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		want      string
+		errors    int
+	}{
+		{
+			name:  "fields and relations",
+			query: `{ user(id: "u1") { name friend { id __typename } } }`,
+			want:  `{"user":{"friend":{"__typename":"User","id":"u2"},"name":"Alice"}}`,
+		},
+		{
+			name:      "variables and aliases",
+			query:     `query Q($n: Int = 2) { first: users(limit: $n) { name } }`,
+			variables: map[string]interface{}{"n": json.Number("1")},
+			want:      `{"first":[{"name":"Alice"}]}`,
+		},
+		{
+			name:  "directives",
+			query: `query ($all: Boolean = false) { users { id name @include(if: $all) } }`,
+			want:  `{"users":[{"id":"u1"},{"id":"u2"}]}`,
+		},
+		{
+			name:   "resolver error",
+			query:  `{ user(id: "x") { name } }`,
+			want:   `{"user":null}`,
+			errors: 1,
+		},
+		{
+			name:   "unknown field",
+			query:  `{ user(id: "u1") { age } }`,
+			want:   `{"user":{}}`,
+			errors: 1,
+		},
+	}
+
+	schema := testSchema()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := schema.Execute(context.Background(), tt.query, tt.variables, "")
+			data, _ := json.Marshal(result.Data)
+			if string(data) != tt.want {
+				t.Errorf("data = %s, want %s", data, tt.want)
+			}
+			if len(result.Errors) != tt.errors {
+				t.Errorf("errors = %d, want %d", len(result.Errors), tt.errors)
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, query := range []string{
+		`{ user(id: "u1") { ...UserFields } }`,
+		`mutation { user }`,
+		`{ user(id: "u1" }`,
+		`query A { a } query B { b }`,
+		strings.Repeat("{ a ", MaxParseDepth+1) + strings.Repeat("}", MaxParseDepth+1),
+		`{ a(v: ` + strings.Repeat("[", MaxParseDepth+1) + strings.Repeat("]", MaxParseDepth+1) + `) }`,
+	} {
+		if _, err := Parse(query, nil, ""); err == nil {
+			t.Errorf("Parse(%q) expected error", query)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	schema := testSchema()
+	schema.MaxDepth = 3
+	result := schema.Execute(context.Background(), `{ user(id: "u1") { friend { friend { id } } } }`, nil, "")
+	if result.Data != nil || len(result.Errors) != 1 {
+		t.Errorf("depth limit: data = %v, errors = %d", result.Data, len(result.Errors))
+	}
+	result = schema.Execute(context.Background(), `{ user(id: "u1") { friend { id } } }`, nil, "")
+	if len(result.Errors) != 0 {
+		t.Errorf("depth within limit: errors = %v", result.Errors[0].Message)
+	}
+
+	schema = testSchema()
+	schema.MaxNodes = 2
+	result = schema.Execute(context.Background(), `{ users { friend { id } } }`, nil, "")
+	if len(result.Errors) != 1 {
+		t.Fatalf("node limit: errors = %d, want 1", len(result.Errors))
+	}
+	data, _ := json.Marshal(result.Data)
+	if want := `{"users":[{"friend":{"id":"u2"}},{}]}`; string(data) != want {
+		t.Errorf("node limit: data = %s, want %s", data, want)
+	}
+}
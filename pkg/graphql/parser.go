@@ -0,0 +1,708 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Field 查询中的字段，参数中的变量已替换为实际值
+type Field struct {
+	Alias      string
+	Name       string
+	Args       map[string]interface{}
+	Selections []*Field
+}
+
+// Key 返回结果中的字段名
+func (f *Field) Key() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// MaxParseDepth 选择集与参数值的最大嵌套层数，超出时在解析阶段报错，避免深层嵌套耗尽栈空间
+const MaxParseDepth = 32
+
+// Parse 解析查询文档，返回指定操作的顶层字段
+// 仅支持 query 操作、变量、别名以及 @include/@skip 指令，不支持片段
+func Parse(query string, variables map[string]interface{}, operationName string) ([]*Field, error) {
+	p := &parser{lex: newLexer(query)}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	var selected *operation
+	count := 0
+	for p.tok.kind != tokEOF {
+		op, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		count++
+		if operationName == "" || op.name == operationName {
+			if selected != nil && operationName == "" {
+				return nil, fmt.Errorf("operationName is required when the document contains multiple operations")
+			}
+			selected = op
+		}
+	}
+	if selected == nil {
+		if count == 0 {
+			return nil, fmt.Errorf("no operation found")
+		}
+		return nil, fmt.Errorf("unknown operation: %s", operationName)
+	}
+	if selected.kind != "query" {
+		return nil, fmt.Errorf("%s operations are not supported", selected.kind)
+	}
+
+	vars := make(map[string]interface{}, len(selected.defaults))
+	for name, v := range selected.defaults {
+		vars[name] = v
+	}
+	for name, v := range variables {
+		vars[name] = v
+	}
+	return resolveFields(selected.selections, vars)
+}
+
+type operation struct {
+	kind       string
+	name       string
+	defaults   map[string]interface{}
+	selections []*rawField
+}
+
+// rawField 解析阶段的字段，参数值可能包含变量引用
+type rawField struct {
+	alias      string
+	name       string
+	args       map[string]interface{}
+	directives []directive
+	selections []*rawField
+}
+
+type directive struct {
+	name string
+	args map[string]interface{}
+}
+
+// variable 参数中的变量引用
+type variable string
+
+func resolveFields(raws []*rawField, vars map[string]interface{}) ([]*Field, error) {
+	fields := make([]*Field, 0, len(raws))
+	for _, raw := range raws {
+		include := true
+		for _, d := range raw.directives {
+			v, err := resolveValue(d.args["if"], vars)
+			if err != nil {
+				return nil, err
+			}
+			cond, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("directive @%s requires a boolean \"if\" argument", d.name)
+			}
+			switch d.name {
+			case "include":
+				include = include && cond
+			case "skip":
+				include = include && !cond
+			default:
+				return nil, fmt.Errorf("unknown directive @%s", d.name)
+			}
+		}
+		if !include {
+			continue
+		}
+
+		args := make(map[string]interface{}, len(raw.args))
+		for name, v := range raw.args {
+			value, err := resolveValue(v, vars)
+			if err != nil {
+				return nil, err
+			}
+			args[name] = value
+		}
+		selections, err := resolveFields(raw.selections, vars)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, &Field{
+			Alias:      raw.alias,
+			Name:       raw.name,
+			Args:       args,
+			Selections: selections,
+		})
+	}
+	return fields, nil
+}
+
+func resolveValue(v interface{}, vars map[string]interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case variable:
+		resolved, ok := vars[string(value)]
+		if !ok {
+			return nil, nil
+		}
+		return resolved, nil
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i := range value {
+			item, err := resolveValue(value[i], vars)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = item
+		}
+		return list, nil
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(value))
+		for k := range value {
+			item, err := resolveValue(value[k], vars)
+			if err != nil {
+				return nil, err
+			}
+			obj[k] = item
+		}
+		return obj, nil
+	default:
+		return v, nil
+	}
+}
+
+type parser struct {
+	lex   *lexer
+	tok   token
+	depth int
+}
+
+// enter 进入一层嵌套，超出 MaxParseDepth 时报错，返回后需调用 leave
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > MaxParseDepth {
+		return p.errorf("document is nested too deeply")
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) next() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) expect(kind tokenKind, value string) error {
+	if p.tok.kind != kind || (value != "" && p.tok.value != value) {
+		want := value
+		if want == "" {
+			want = kind.String()
+		}
+		return p.errorf("expected %s, found %q", want, p.tok.value)
+	}
+	return p.next()
+}
+
+func (p *parser) is(kind tokenKind, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("syntax error at %d: %s", p.tok.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseOperation() (*operation, error) {
+	op := &operation{kind: "query", defaults: map[string]interface{}{}}
+
+	if p.is(tokPunct, "{") {
+		selections, err := p.parseSelectionSet()
+		if err != nil {
+			return nil, err
+		}
+		op.selections = selections
+		return op, nil
+	}
+
+	if p.tok.kind != tokName {
+		return nil, p.errorf("unexpected %q", p.tok.value)
+	}
+	switch p.tok.value {
+	case "query", "mutation", "subscription":
+		op.kind = p.tok.value
+	case "fragment":
+		return nil, p.errorf("fragments are not supported")
+	default:
+		return nil, p.errorf("unexpected %q", p.tok.value)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind == tokName {
+		op.name = p.tok.value
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.is(tokPunct, "(") {
+		if err := p.parseVariableDefinitions(op); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+
+	selections, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = selections
+	return op, nil
+}
+
+func (p *parser) parseVariableDefinitions(op *operation) error {
+	if err := p.expect(tokPunct, "("); err != nil {
+		return err
+	}
+	for !p.is(tokPunct, ")") {
+		if err := p.expect(tokPunct, "$"); err != nil {
+			return err
+		}
+		if p.tok.kind != tokName {
+			return p.errorf("expected variable name")
+		}
+		name := p.tok.value
+		if err := p.next(); err != nil {
+			return err
+		}
+		if err := p.expect(tokPunct, ":"); err != nil {
+			return err
+		}
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if p.is(tokPunct, "=") {
+			if err := p.next(); err != nil {
+				return err
+			}
+			v, err := p.parseValue(true)
+			if err != nil {
+				return err
+			}
+			op.defaults[name] = v
+		}
+	}
+	return p.next()
+}
+
+// skipType 跳过变量类型声明，类型只用于文档可读性，不做校验
+func (p *parser) skipType() error {
+	if p.is(tokPunct, "[") {
+		if err := p.next(); err != nil {
+			return err
+		}
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if err := p.expect(tokPunct, "]"); err != nil {
+			return err
+		}
+	} else if err := p.expect(tokName, ""); err != nil {
+		return err
+	}
+	if p.is(tokPunct, "!") {
+		return p.next()
+	}
+	return nil
+}
+
+func (p *parser) parseSelectionSet() ([]*rawField, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	if err := p.expect(tokPunct, "{"); err != nil {
+		return nil, err
+	}
+	fields := make([]*rawField, 0)
+	for !p.is(tokPunct, "}") {
+		if p.tok.kind == tokEOF {
+			return nil, p.errorf("unexpected end of document")
+		}
+		if p.is(tokPunct, "...") {
+			return nil, p.errorf("fragments are not supported")
+		}
+		field, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, p.errorf("empty selection set")
+	}
+	return fields, p.next()
+}
+
+func (p *parser) parseField() (*rawField, error) {
+	if p.tok.kind != tokName {
+		return nil, p.errorf("expected field name, found %q", p.tok.value)
+	}
+	field := &rawField{name: p.tok.value}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.is(tokPunct, ":") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokName {
+			return nil, p.errorf("expected field name after alias")
+		}
+		field.alias, field.name = field.name, p.tok.value
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.is(tokPunct, "(") {
+		args, err := p.parseArguments()
+		if err != nil {
+			return nil, err
+		}
+		field.args = args
+	}
+
+	directives, err := p.parseDirectives()
+	if err != nil {
+		return nil, err
+	}
+	field.directives = directives
+
+	if p.is(tokPunct, "{") {
+		selections, err := p.parseSelectionSet()
+		if err != nil {
+			return nil, err
+		}
+		field.selections = selections
+	}
+	return field, nil
+}
+
+func (p *parser) parseArguments() (map[string]interface{}, error) {
+	if err := p.expect(tokPunct, "("); err != nil {
+		return nil, err
+	}
+	args := make(map[string]interface{})
+	for !p.is(tokPunct, ")") {
+		if p.tok.kind != tokName {
+			return nil, p.errorf("expected argument name, found %q", p.tok.value)
+		}
+		name := p.tok.value
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokPunct, ":"); err != nil {
+			return nil, err
+		}
+		v, err := p.parseValue(false)
+		if err != nil {
+			return nil, err
+		}
+		args[name] = v
+	}
+	return args, p.next()
+}
+
+func (p *parser) parseDirectives() ([]directive, error) {
+	var directives []directive
+	for p.is(tokPunct, "@") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokName {
+			return nil, p.errorf("expected directive name")
+		}
+		d := directive{name: p.tok.value}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.is(tokPunct, "(") {
+			args, err := p.parseArguments()
+			if err != nil {
+				return nil, err
+			}
+			d.args = args
+		}
+		directives = append(directives, d)
+	}
+	return directives, nil
+}
+
+func (p *parser) parseValue(constant bool) (interface{}, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	tok := p.tok
+	switch {
+	case tok.kind == tokPunct && tok.value == "$":
+		if constant {
+			return nil, p.errorf("variables are not allowed here")
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokName {
+			return nil, p.errorf("expected variable name")
+		}
+		name := p.tok.value
+		return variable(name), p.next()
+	case tok.kind == tokInt:
+		v, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, p.errorf("invalid int %s", tok.value)
+		}
+		return int(v), p.next()
+	case tok.kind == tokFloat:
+		v, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, p.errorf("invalid float %s", tok.value)
+		}
+		return v, p.next()
+	case tok.kind == tokString:
+		return tok.value, p.next()
+	case tok.kind == tokName:
+		var v interface{}
+		switch tok.value {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			// 枚举值按字符串处理
+			v = tok.value
+		}
+		return v, p.next()
+	case tok.kind == tokPunct && tok.value == "[":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		list := make([]interface{}, 0)
+		for !p.is(tokPunct, "]") {
+			if p.tok.kind == tokEOF {
+				return nil, p.errorf("unexpected end of document")
+			}
+			v, err := p.parseValue(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, p.next()
+	case tok.kind == tokPunct && tok.value == "{":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		obj := make(map[string]interface{})
+		for !p.is(tokPunct, "}") {
+			if p.tok.kind != tokName {
+				return nil, p.errorf("expected object field name")
+			}
+			name := p.tok.value
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if err := p.expect(tokPunct, ":"); err != nil {
+				return nil, err
+			}
+			v, err := p.parseValue(constant)
+			if err != nil {
+				return nil, err
+			}
+			obj[name] = v
+		}
+		return obj, p.next()
+	default:
+		return nil, p.errorf("unexpected %q", tok.value)
+	}
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokEOF:
+		return "end of document"
+	case tokPunct:
+		return "punctuator"
+	case tokName:
+		return "name"
+	case tokInt:
+		return "int"
+	case tokFloat:
+		return "float"
+	default:
+		return "string"
+	}
+}
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: src}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: l.pos}, nil
+	}
+
+	start := l.pos
+	c := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return token{kind: tokPunct, value: "...", pos: start}, nil
+	case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+		l.pos++
+		return token{kind: tokPunct, value: string(c), pos: start}, nil
+	case c == '_' || isLetter(c):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokName, value: l.src[start:l.pos], pos: start}, nil
+	case c == '-' || isDigit(c):
+		return l.number()
+	case c == '"':
+		return l.string()
+	default:
+		return token{}, fmt.Errorf("syntax error at %d: unexpected character %q", start, c)
+	}
+}
+
+// skipIgnored 跳过空白、逗号与注释
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			l.pos++
+		case strings.HasPrefix(l.src[l.pos:], "\ufeff"):
+			l.pos += len("\ufeff")
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	kind := tokInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+		l.pos++
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = tokFloat
+		l.pos++
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = tokFloat
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+		}
+	}
+	value := l.src[start:l.pos]
+	if value == "-" {
+		return token{}, fmt.Errorf("syntax error at %d: invalid number", start)
+	}
+	return token{kind: kind, value: value, pos: start}, nil
+}
+
+func (l *lexer) string() (token, error) {
+	start := l.pos
+	if strings.HasPrefix(l.src[l.pos:], `"""`) {
+		end := strings.Index(l.src[l.pos+3:], `"""`)
+		if end < 0 {
+			return token{}, fmt.Errorf("syntax error at %d: unterminated string", start)
+		}
+		value := l.src[l.pos+3 : l.pos+3+end]
+		l.pos += end + 6
+		return token{kind: tokString, value: strings.TrimSpace(value), pos: start}, nil
+	}
+
+	l.pos++
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case '\\':
+			l.pos += 2
+		case '"':
+			l.pos++
+			value, err := strconv.Unquote(l.src[start:l.pos])
+			if err != nil {
+				return token{}, fmt.Errorf("syntax error at %d: invalid string", start)
+			}
+			return token{kind: tokString, value: value, pos: start}, nil
+		case '\n':
+			return token{}, fmt.Errorf("syntax error at %d: unterminated string", start)
+		default:
+			l.pos++
+		}
+	}
+	return token{}, fmt.Errorf("syntax error at %d: unterminated string", start)
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
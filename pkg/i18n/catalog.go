@@ -0,0 +1,117 @@
+package i18n
+
+var zh = map[string]string{
+	// 消息纯文本
+	"msg.me":              "我",
+	"msg.image":           "图片",
+	"msg.voice":           "语音",
+	"msg.card":            "名片",
+	"msg.video":           "视频",
+	"msg.emoji":           "动画表情",
+	"msg.link":            "链接",
+	"msg.file":            "文件",
+	"msg.gif":             "GIF表情",
+	"msg.forward":         "合并转发",
+	"msg.miniapp":         "小程序",
+	"msg.channels":        "视频号",
+	"msg.quote":           "引用",
+	"msg.announcement":    "群公告",
+	"msg.red_packet":      "红包",
+	"msg.red_packet_skin": "红包封面",
+	"msg.share":           "分享",
+	"msg.voip":            "语音通话",
+
+	// 活跃度
+	"activity.very_active": "🔥 非常活跃",
+	"activity.active":      "⚡ 活跃",
+	"activity.normal":      "📈 一般",
+	"activity.low":         "📊 较少",
+	"activity.quiet":       "😴 安静",
+
+	// 话题汇总
+	"topic.daily":         "日常交流",
+	"topic.hot":           "热门话题讨论",
+	"topic.active":        "活跃群聊",
+	"topic.unknown_group": "未知群聊",
+
+	// 摘要
+	"digest.title":    "聊天摘要",
+	"digest.stats":    "消息 %d 条 · 发言 %d 人 · %s",
+	"digest.keywords": "热门话题：",
+	"digest.quotes":   "金句：",
+	"digest.empty":    "该时间段内没有聊天记录",
+	"digest.sep":      "、",
+
+	// 分享链接
+	"links.title":  "分享链接 - %s",
+	"links.count":  "共 %d 个链接",
+	"links.time":   "时间",
+	"links.sender": "发送人",
+	"links.url":    "链接",
+	"links.times":  "次数",
+
+	// CSV 表头
+	"csv.contacts":  "微信ID,微信号,备注,昵称",
+	"csv.chatrooms": "群ID,备注,群名称,群主,成员数",
+	"csv.sessions":  "会话ID,排序,名称,最后消息,时间",
+	"csv.links":     "时间,会话,发送人ID,发送人,标题,链接,次数",
+	"csv.messages":  "时间,会话,发送人ID,发送人,自己发送,类型,子类型,内容",
+
+	// 离线导出
+	"export.title": "聊天记录 - %s",
+}
+
+var en = map[string]string{
+	"msg.me":              "Me",
+	"msg.image":           "Image",
+	"msg.voice":           "Voice",
+	"msg.card":            "Contact Card",
+	"msg.video":           "Video",
+	"msg.emoji":           "Sticker",
+	"msg.link":            "Link",
+	"msg.file":            "File",
+	"msg.gif":             "GIF",
+	"msg.forward":         "Chat History",
+	"msg.miniapp":         "Mini Program",
+	"msg.channels":        "Channels",
+	"msg.quote":           "Quote",
+	"msg.announcement":    "Group Notice",
+	"msg.red_packet":      "Red Packet",
+	"msg.red_packet_skin": "Red Packet Cover",
+	"msg.share":           "Share",
+	"msg.voip":            "Voice Call",
+
+	"activity.very_active": "🔥 Very active",
+	"activity.active":      "⚡ Active",
+	"activity.normal":      "📈 Moderate",
+	"activity.low":         "📊 Low",
+	"activity.quiet":       "😴 Quiet",
+
+	"topic.daily":         "Daily chat",
+	"topic.hot":           "Hot topics",
+	"topic.active":        "Active group",
+	"topic.unknown_group": "Unknown group",
+
+	"digest.title":    "Chat Digest",
+	"digest.stats":    "%d messages · %d members · %s",
+	"digest.keywords": "Hot topics: ",
+	"digest.quotes":   "Quotes:",
+	"digest.empty":    "No messages in this period",
+	"digest.sep":      ", ",
+
+	"links.title":  "Shared Links - %s",
+	"links.count":  "%d links",
+	"links.time":   "Time",
+	"links.sender": "Sender",
+	"links.url":    "Link",
+	"links.times":  "Count",
+
+	// 英文表头与未指定语言时的原有列名一致
+	"csv.contacts":  "UserName,Alias,Remark,NickName",
+	"csv.chatrooms": "Name,Remark,NickName,Owner,UserCount",
+	"csv.sessions":  "UserName,NOrder,NickName,Content,NTime",
+	"csv.links":     "Time,Talker,Sender,SenderName,Title,URL,Count",
+	"csv.messages":  "Time,Talker,Sender,SenderName,IsSelf,Type,SubType,Content",
+
+	"export.title": "Chat History - %s",
+}
@@ -0,0 +1,72 @@
+// Package i18n 提供纯文本与分析结果中固定文案的多语言支持，目前支持中文与英文
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 支持的语言
+const (
+	ZH = "zh"
+	EN = "en"
+)
+
+// Default 未指定语言时使用的语言
+const Default = ZH
+
+var catalogs = map[string]map[string]string{
+	ZH: zh,
+	EN: en,
+}
+
+// T 返回指定语言的文案，缺失时依次回退到默认语言与 key 本身
+func T(lang, key string) string {
+	if v, ok := catalogs[lang][key]; ok {
+		return v
+	}
+	if v, ok := catalogs[Default][key]; ok {
+		return v
+	}
+	return key
+}
+
+// Tf 返回格式化后的文案
+func Tf(lang, key string, args ...interface{}) string {
+	return fmt.Sprintf(T(lang, key), args...)
+}
+
+// Parse 解析 lang 参数或 Accept-Language 请求头，如 en、zh-CN、en-US,en;q=0.9,zh;q=0.8
+// 返回权重最高的受支持语言，无法识别时返回空字符串
+func Parse(str string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	candidates := make([]candidate, 0)
+	for _, part := range strings.Split(str, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if _, ok := catalogs[lang]; !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{lang: lang, q: q})
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	return candidates[0].lang
+}
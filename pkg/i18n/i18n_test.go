@@ -0,0 +1,35 @@
+package i18n
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"en", EN},
+		{"zh-CN", ZH},
+		{"en-US,en;q=0.9,zh;q=0.8", EN},
+		{"fr-FR,zh;q=0.5,en;q=0.7", EN},
+		{"fr-FR,de;q=0.5", ""},
+		{"en;q=0", ""},
+	}
+	for _, tt := range tests {
+		if got := Parse(tt.input); got != tt.want {
+			t.Errorf("Parse(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	if got := T(EN, "msg.image"); got != "Image" {
+		t.Errorf("T(en) = %q", got)
+	}
+	if got := T("", "msg.image"); got != "图片" {
+		t.Errorf("T(default) = %q", got)
+	}
+	if got := T(EN, "missing.key"); got != "missing.key" {
+		t.Errorf("T(missing) = %q", got)
+	}
+}
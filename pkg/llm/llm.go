@@ -0,0 +1,115 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultBaseURL = "https://api.openai.com/v1"
+	DefaultTimeout = 60 * time.Second
+)
+
+var ErrNotConfigured = errors.New("llm not configured")
+
+// Message 对话消息
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Client OpenAI 兼容的 Chat Completions 客户端
+// 适用于 OpenAI、DeepSeek、通义千问、Ollama 等提供兼容接口的服务
+type Client struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+
+	httpClient *http.Client
+}
+
+func NewClient(baseURL, apiKey, model string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		APIKey:     apiKey,
+		Model:      model,
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// Enabled 是否已配置模型
+func (c *Client) Enabled() bool {
+	return c != nil && c.Model != ""
+}
+
+type chatRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Chat 发送对话并返回模型回复内容
+func (c *Client) Chat(ctx context.Context, messages []Message) (string, error) {
+	if !c.Enabled() {
+		return "", ErrNotConfigured
+	}
+
+	body, err := json.Marshal(chatRequest{Model: c.Model, Messages: messages})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var ret chatResponse
+	if err := json.Unmarshal(data, &ret); err != nil {
+		return "", fmt.Errorf("llm response decode failed (status %d): %w", resp.StatusCode, err)
+	}
+	if ret.Error != nil {
+		return "", fmt.Errorf("llm request failed (status %d): %s", resp.StatusCode, ret.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("llm request failed: status %d", resp.StatusCode)
+	}
+	if len(ret.Choices) == 0 {
+		return "", errors.New("llm response has no choices")
+	}
+
+	return strings.TrimSpace(ret.Choices[0].Message.Content), nil
+}
@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer sk-test" {
+			t.Errorf("unexpected authorization: %s", got)
+		}
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		if req.Model != "test-model" || len(req.Messages) != 1 {
+			t.Errorf("unexpected request: %+v", req)
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":" 你好 "}}]}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL+"/", "sk-test", "test-model")
+	out, err := c.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "你好" {
+		t.Errorf("Chat() = %q, want %q", out, "你好")
+	}
+}
+
+func TestChatError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"message":"invalid api key"}}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, "bad", "test-model")
+	if _, err := c.Chat(context.Background(), nil); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestNotConfigured(t *testing.T) {
+	c := NewClient("", "", "")
+	if _, err := c.Chat(context.Background(), nil); err != ErrNotConfigured {
+		t.Errorf("expected ErrNotConfigured, got %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+// Package mdns 在局域网中以 mDNS 发布 HTTP 服务，只应答本服务相关的查询
+package mdns
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ttl 记录的有效时间（秒），与常见实现一致
+const ttl = 120
+
+var group = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Server mDNS 应答服务
+// 发布 <name>.local 的 A 记录，以及 _http._tcp 类型的服务实例，支持 DNS-SD 的设备可自动发现
+type Server struct {
+	host     dnsmessage.Name // <name>.local.
+	instance dnsmessage.Name // <name>._http._tcp.local.
+	service  dnsmessage.Name // _http._tcp.local.
+	meta     dnsmessage.Name // _services._dns-sd._udp.local.
+	port     uint16
+	ips      []net.IP
+	txt      []string
+
+	conn      *net.UDPConn
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// Start 开始应答查询并发布服务，ips 为 <name>.local 解析到的 IPv4 地址，txt 为服务实例的附加信息
+func Start(name string, port int, ips []net.IP, txt ...string) (*Server, error) {
+	name = strings.Trim(name, ".")
+	if name == "" || strings.Contains(name, ".") {
+		return nil, errors.New("mdns: invalid name")
+	}
+	var v4 []net.IP
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			v4 = append(v4, ip4)
+		}
+	}
+	if len(v4) == 0 {
+		return nil, errors.New("mdns: no IPv4 address")
+	}
+
+	s := &Server{
+		host:     dnsmessage.MustNewName(name + ".local."),
+		instance: dnsmessage.MustNewName(name + "._http._tcp.local."),
+		service:  dnsmessage.MustNewName("_http._tcp.local."),
+		meta:     dnsmessage.MustNewName("_services._dns-sd._udp.local."),
+		port:     uint16(port),
+		ips:      v4,
+		txt:      txt,
+		done:     make(chan struct{}),
+	}
+	if len(s.txt) == 0 {
+		s.txt = []string{"path=/"}
+	}
+
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	go s.serve()
+
+	// 启动时主动通告两次，便于已在浏览的设备立即发现
+	go func() {
+		for i := 0; i < 2; i++ {
+			s.announce(ttl)
+			select {
+			case <-s.done:
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+	return s, nil
+}
+
+// Close 发送 TTL 为 0 的通告使设备移除缓存的记录，然后停止应答
+func (s *Server) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.announce(0)
+		err = s.conn.Close()
+	})
+	return err
+}
+
+func (s *Server) serve() {
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		s.handle(buf[:n], from)
+	}
+}
+
+// handle 解析查询，有匹配的问题时应答
+// 来源端口不是 5353 的简单查询（如 dig -p 5353）以单播回复，并带回原问题与 ID
+func (s *Server) handle(packet []byte, from *net.UDPAddr) {
+	var p dnsmessage.Parser
+	header, err := p.Start(packet)
+	if err != nil || header.Response {
+		return
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return
+	}
+
+	legacy := from.Port != group.Port
+	var answers []dnsmessage.Question
+	unicast := legacy
+	for _, q := range questions {
+		// 最高位为 QU 标志，请求单播应答
+		if q.Class&(1<<15) != 0 {
+			unicast = true
+			q.Class &^= 1 << 15
+		}
+		if s.matches(q) {
+			answers = append(answers, q)
+		}
+	}
+	if len(answers) == 0 {
+		return
+	}
+
+	var id uint16
+	var echo []dnsmessage.Question
+	if legacy {
+		id, echo = header.ID, questions
+	}
+	msg, err := s.response(id, echo, answers, ttl)
+	if err != nil {
+		return
+	}
+	to := group
+	if unicast {
+		to = from
+	}
+	s.conn.WriteToUDP(msg, to)
+}
+
+func (s *Server) matches(q dnsmessage.Question) bool {
+	if q.Class != dnsmessage.ClassINET && q.Class != dnsmessage.ClassANY {
+		return false
+	}
+	name := strings.ToLower(q.Name.String())
+	switch {
+	case name == strings.ToLower(s.host.String()):
+		return q.Type == dnsmessage.TypeA || q.Type == dnsmessage.TypeALL
+	case name == strings.ToLower(s.instance.String()):
+		return q.Type == dnsmessage.TypeSRV || q.Type == dnsmessage.TypeTXT || q.Type == dnsmessage.TypeALL
+	case name == s.service.String(), name == s.meta.String():
+		return q.Type == dnsmessage.TypePTR || q.Type == dnsmessage.TypeALL
+	}
+	return false
+}
+
+func (s *Server) announce(ttl uint32) {
+	msg, err := s.response(0, nil, []dnsmessage.Question{{Name: s.service, Type: dnsmessage.TypePTR}}, ttl)
+	if err != nil {
+		return
+	}
+	s.conn.WriteToUDP(msg, group)
+}
+
+// response 构造应答，服务实例的 SRV、TXT 与主机的 A 记录总是放在附加记录中，免去设备的二次查询
+func (s *Server) response(id uint16, echo []dnsmessage.Question, questions []dnsmessage.Question, ttl uint32) ([]byte, error) {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, Response: true, Authoritative: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	for _, q := range echo {
+		if err := b.Question(q); err != nil {
+			return nil, err
+		}
+	}
+
+	header := func(name dnsmessage.Name, typ dnsmessage.Type, flush bool) dnsmessage.ResourceHeader {
+		class := dnsmessage.ClassINET
+		// 唯一记录设置缓存刷新标志，共享的 PTR 记录不设置
+		if flush && id == 0 {
+			class |= 1 << 15
+		}
+		return dnsmessage.ResourceHeader{Name: name, Type: typ, Class: class, TTL: ttl}
+	}
+	ptr := func(name, target dnsmessage.Name) error {
+		return b.PTRResource(header(name, dnsmessage.TypePTR, false), dnsmessage.PTRResource{PTR: target})
+	}
+	records := func(a, srv, txt bool) error {
+		if srv {
+			if err := b.SRVResource(header(s.instance, dnsmessage.TypeSRV, true), dnsmessage.SRVResource{Target: s.host, Port: s.port}); err != nil {
+				return err
+			}
+		}
+		if txt {
+			if err := b.TXTResource(header(s.instance, dnsmessage.TypeTXT, true), dnsmessage.TXTResource{TXT: s.txt}); err != nil {
+				return err
+			}
+		}
+		if a {
+			for _, ip := range s.ips {
+				var addr [4]byte
+				copy(addr[:], ip)
+				if err := b.AResource(header(s.host, dnsmessage.TypeA, true), dnsmessage.AResource{A: addr}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	var answeredA, answeredSRV, answeredTXT bool
+	for _, q := range questions {
+		name := strings.ToLower(q.Name.String())
+		all := q.Type == dnsmessage.TypeALL
+		var err error
+		switch {
+		case name == strings.ToLower(s.host.String()):
+			err = records(!answeredA, false, false)
+			answeredA = true
+		case name == strings.ToLower(s.instance.String()):
+			srv := (all || q.Type == dnsmessage.TypeSRV) && !answeredSRV
+			txt := (all || q.Type == dnsmessage.TypeTXT) && !answeredTXT
+			err = records(false, srv, txt)
+			answeredSRV, answeredTXT = answeredSRV || srv, answeredTXT || txt
+		case name == s.service.String():
+			err = ptr(s.service, s.instance)
+		case name == s.meta.String():
+			err = ptr(s.meta, s.service)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := b.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	if err := records(!answeredA, !answeredSRV, !answeredTXT); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
@@ -0,0 +1,81 @@
+package mdns
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func testServer() *Server {
+	return &Server{
+		host:     dnsmessage.MustNewName("chatlog.local."),
+		instance: dnsmessage.MustNewName("chatlog._http._tcp.local."),
+		service:  dnsmessage.MustNewName("_http._tcp.local."),
+		meta:     dnsmessage.MustNewName("_services._dns-sd._udp.local."),
+		port:     5030,
+		ips:      []net.IP{net.IPv4(192, 168, 1, 10).To4()},
+		txt:      []string{"path=/"},
+	}
+}
+
+func TestMatches(t *testing.T) {
+	s := testServer()
+	tests := []struct {
+		name string
+		typ  dnsmessage.Type
+		want bool
+	}{
+		{"_http._tcp.local.", dnsmessage.TypePTR, true},
+		{"_services._dns-sd._udp.local.", dnsmessage.TypePTR, true},
+		{"Chatlog.Local.", dnsmessage.TypeA, true},
+		{"chatlog.local.", dnsmessage.TypeAAAA, false},
+		{"chatlog._http._tcp.local.", dnsmessage.TypeSRV, true},
+		{"other.local.", dnsmessage.TypeA, false},
+	}
+	for _, tt := range tests {
+		q := dnsmessage.Question{Name: dnsmessage.MustNewName(tt.name), Type: tt.typ, Class: dnsmessage.ClassINET}
+		if got := s.matches(q); got != tt.want {
+			t.Errorf("matches(%s %v) = %v, want %v", tt.name, tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestResponse(t *testing.T) {
+	s := testServer()
+	q := dnsmessage.Question{Name: s.service, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}
+	data, err := s.response(0, nil, []dnsmessage.Question{q}, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(data); err != nil {
+		t.Fatal(err)
+	}
+	if !msg.Response || len(msg.Questions) != 0 {
+		t.Fatalf("header = %+v, questions = %d", msg.Header, len(msg.Questions))
+	}
+	if len(msg.Answers) != 1 || msg.Answers[0].Body.(*dnsmessage.PTRResource).PTR != s.instance {
+		t.Fatalf("answers = %v", msg.Answers)
+	}
+
+	var srv *dnsmessage.SRVResource
+	var a *dnsmessage.AResource
+	for _, r := range msg.Additionals {
+		switch body := r.Body.(type) {
+		case *dnsmessage.SRVResource:
+			srv = body
+		case *dnsmessage.AResource:
+			a = body
+		}
+		if r.Header.Class&(1<<15) == 0 {
+			t.Errorf("%v record without cache flush", r.Header.Type)
+		}
+	}
+	if srv == nil || srv.Port != 5030 || srv.Target != s.host {
+		t.Errorf("srv = %+v", srv)
+	}
+	if a == nil || a.A != [4]byte{192, 168, 1, 10} {
+		t.Errorf("a = %+v", a)
+	}
+}
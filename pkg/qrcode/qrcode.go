@@ -0,0 +1,496 @@
+// Package qrcode 生成二维码，只支持字节模式与 M 级纠错，版本 1 到 10，足够编码局域网访问地址
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+var ErrTooLong = errors.New("qrcode: data too long")
+
+// eccM 格式信息中 M 级纠错的编码
+const eccM = 0
+
+// version M 级纠错下各版本的分块参数
+type version struct {
+	ec     int      // 每块的纠错码字数
+	blocks [][2]int // 分组，每组为 {块数, 每块的数据码字数}
+	align  []int    // 校正图形的中心坐标
+}
+
+var versions = []version{
+	1:  {10, [][2]int{{1, 16}}, nil},
+	2:  {16, [][2]int{{1, 28}}, []int{6, 18}},
+	3:  {26, [][2]int{{1, 44}}, []int{6, 22}},
+	4:  {18, [][2]int{{2, 32}}, []int{6, 26}},
+	5:  {24, [][2]int{{2, 43}}, []int{6, 30}},
+	6:  {16, [][2]int{{4, 27}}, []int{6, 34}},
+	7:  {18, [][2]int{{4, 31}}, []int{6, 22, 38}},
+	8:  {22, [][2]int{{2, 38}, {2, 39}}, []int{6, 24, 42}},
+	9:  {22, [][2]int{{3, 36}, {2, 37}}, []int{6, 26, 46}},
+	10: {26, [][2]int{{4, 43}, {1, 44}}, []int{6, 28, 50}},
+}
+
+func (v version) dataCodewords() int {
+	n := 0
+	for _, g := range v.blocks {
+		n += g[0] * g[1]
+	}
+	return n
+}
+
+// Code 二维码矩阵，Modules[y][x] 为 true 表示深色模块
+type Code struct {
+	Version int
+	Size    int
+	Modules [][]bool
+
+	function [][]bool
+}
+
+// Encode 以字节模式编码数据，选择能容纳数据的最小版本
+func Encode(data []byte) (*Code, error) {
+	ver := 0
+	for v := 1; v < len(versions); v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= versions[v].dataCodewords()*8 {
+			ver = v
+			break
+		}
+	}
+	if ver == 0 {
+		return nil, ErrTooLong
+	}
+
+	size := 17 + 4*ver
+	c := &Code{Version: ver, Size: size}
+	c.Modules = make([][]bool, size)
+	c.function = make([][]bool, size)
+	for i := range c.Modules {
+		c.Modules[i] = make([]bool, size)
+		c.function[i] = make([]bool, size)
+	}
+
+	c.drawFunctionPatterns()
+	c.drawCodewords(interleave(ver, encodeData(ver, data)))
+
+	// 选择惩罚分最低的掩码
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask)
+	}
+	c.applyMask(best)
+	c.drawFormatBits(best)
+	return c, nil
+}
+
+// encodeData 生成数据码字：模式指示、字符计数、数据、终止符与填充
+func encodeData(ver int, data []byte) []byte {
+	capacity := versions[ver].dataCodewords()
+	var bits bitBuffer
+	bits.append(0x4, 4)
+	if ver >= 10 {
+		bits.append(len(data), 16)
+	} else {
+		bits.append(len(data), 8)
+	}
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	bits.append(0, min(4, capacity*8-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity*8; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	ret := make([]byte, capacity)
+	for i, bit := range bits {
+		if bit {
+			ret[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+	return ret
+}
+
+// interleave 分块计算纠错码，并按块交错排列数据码字与纠错码字
+func interleave(ver int, data []byte) []byte {
+	v := versions[ver]
+	gen := rsGenerator(v.ec)
+	var blocks, ecs [][]byte
+	for _, g := range v.blocks {
+		for i := 0; i < g[0]; i++ {
+			block := data[:g[1]]
+			data = data[g[1]:]
+			blocks = append(blocks, block)
+			ecs = append(ecs, rsRemainder(block, gen))
+		}
+	}
+	var ret []byte
+	for i := 0; ; i++ {
+		added := false
+		for _, block := range blocks {
+			if i < len(block) {
+				ret = append(ret, block[i])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	for i := 0; i < v.ec; i++ {
+		for _, ec := range ecs {
+			ret = append(ret, ec[i])
+		}
+	}
+	return ret
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(val, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, (val>>i)&1 == 1)
+	}
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.Modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	// 定时图形
+	for i := 0; i < c.Size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	// 位置探测图形与分隔符
+	for _, p := range [][2]int{{3, 3}, {c.Size - 4, 3}, {3, c.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := p[0]+dx, p[1]+dy
+				if x < 0 || x >= c.Size || y < 0 || y >= c.Size {
+					continue
+				}
+				dist := max(abs(dx), abs(dy))
+				c.setFunction(x, y, dist != 2 && dist != 4)
+			}
+		}
+	}
+
+	// 校正图形，跳过与位置探测图形重叠的三个角
+	align := versions[c.Version].align
+	n := len(align)
+	for i, y := range align {
+		for j, x := range align {
+			if (i == 0 && j == 0) || (i == 0 && j == n-1) || (i == n-1 && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// 先占位格式信息，选择掩码后重新绘制
+	c.drawFormatBits(0)
+	c.drawVersion()
+}
+
+// formatBits 返回 M 级纠错与 mask 对应的 15 位格式信息
+func formatBits(mask int) int {
+	data := eccM<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (c *Code) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(i))
+	}
+	c.setFunction(8, 7, bit(6))
+	c.setFunction(8, 8, bit(7))
+	c.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(i))
+	}
+	c.setFunction(8, c.Size-8, true)
+}
+
+// versionBits 返回版本 7 及以上的 18 位版本信息
+func versionBits(ver int) int {
+	rem := ver
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return ver<<12 | rem
+}
+
+func (c *Code) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+	bits := versionBits(c.Version)
+	for i := 0; i < 18; i++ {
+		dark := (bits>>i)&1 == 1
+		a, b := c.Size-11+i%3, i/3
+		c.setFunction(a, b, dark)
+		c.setFunction(b, a, dark)
+	}
+}
+
+// drawCodewords 从右下角起按两列一组的之字形路径填充码字，剩余位保持浅色
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if c.function[y][x] || i >= len(data)*8 {
+					continue
+				}
+				c.Modules[y][x] = (data[i>>3]>>(7-i&7))&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.function[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				c.Modules[y][x] = !c.Modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty 按规范的四条规则计算惩罚分
+func (c *Code) penalty() int {
+	ret := 0
+	at := func(x, y int, vertical bool) bool {
+		if vertical {
+			return c.Modules[x][y]
+		}
+		return c.Modules[y][x]
+	}
+	finder := []bool{true, false, true, true, true, false, true}
+	for _, vertical := range []bool{false, true} {
+		for y := 0; y < c.Size; y++ {
+			run := 1
+			for x := 1; x <= c.Size; x++ {
+				if x < c.Size && at(x, y, vertical) == at(x-1, y, vertical) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					ret += 3 + run - 5
+				}
+				run = 1
+			}
+			// 类似位置探测图形的 1:1:3:1:1 且一侧有 4 个浅色模块
+			for x := 0; x+7 <= c.Size; x++ {
+				match := true
+				for k, dark := range finder {
+					if at(x+k, y, vertical) != dark {
+						match = false
+						break
+					}
+				}
+				if !match {
+					continue
+				}
+				if c.light(x-4, x, y, vertical) || c.light(x+7, x+11, y, vertical) {
+					ret += 40
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Modules[y][x] {
+				dark++
+			}
+			if x+1 < c.Size && y+1 < c.Size {
+				m := c.Modules[y][x]
+				if m == c.Modules[y][x+1] && m == c.Modules[y+1][x] && m == c.Modules[y+1][x+1] {
+					ret += 3
+				}
+			}
+		}
+	}
+	total := c.Size * c.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	ret += max(k, 0) * 10
+	return ret
+}
+
+// light 判断 [from, to) 范围内的模块是否均为浅色，超出边界的部分视为静区
+func (c *Code) light(from, to, y int, vertical bool) bool {
+	for x := from; x < to; x++ {
+		if x < 0 || x >= c.Size {
+			continue
+		}
+		if (vertical && c.Modules[x][y]) || (!vertical && c.Modules[y][x]) {
+			return false
+		}
+	}
+	return true
+}
+
+// String 以半高方块字符输出二维码，每个字符表示上下两个模块，quiet 为四周静区的模块数
+// 明确设置前景与背景色，在深色与浅色终端中均为白底黑码
+func (c *Code) String(quiet int) string {
+	dark := func(x, y int) bool {
+		x, y = x-quiet, y-quiet
+		return x >= 0 && y >= 0 && x < c.Size && y < c.Size && c.Modules[y][x]
+	}
+	n := c.Size + 2*quiet
+	var b strings.Builder
+	for y := 0; y < n; y += 2 {
+		for x := 0; x < n; x++ {
+			fg, bg := 97, 107
+			if dark(x, y) {
+				fg = 30
+			}
+			if dark(x, y+1) {
+				bg = 40
+			}
+			fmt.Fprintf(&b, "\x1b[%d;%dm▀", fg, bg)
+		}
+		b.WriteString("\x1b[0m\n")
+	}
+	return b.String()
+}
+
+// PNG 以 scale 像素每模块输出 PNG 图片
+func (c *Code) PNG(scale, quiet int) ([]byte, error) {
+	n := (c.Size + 2*quiet) * scale
+	img := image.NewPaletted(image.Rect(0, 0, n, n), color.Palette{color.White, color.Black})
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.Modules[y][x] {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex((x+quiet)*scale+dx, (y+quiet)*scale+dy, 1)
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// gfMul GF(256) 乘法，本原多项式为 0x11D
+func gfMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+// rsGenerator 返回 degree 次 Reed-Solomon 生成多项式的系数，省略最高次项
+func rsGenerator(degree int) []byte {
+	ret := make([]byte, degree)
+	ret[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range ret {
+			ret[j] = gfMul(ret[j], root)
+			if j+1 < len(ret) {
+				ret[j] ^= ret[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return ret
+}
+
+// rsRemainder 计算数据的纠错码字
+func rsRemainder(data, gen []byte) []byte {
+	ret := make([]byte, len(gen))
+	for _, b := range data {
+		factor := b ^ ret[0]
+		copy(ret, ret[1:])
+		ret[len(ret)-1] = 0
+		for i := range ret {
+			ret[i] ^= gfMul(gen[i], factor)
+		}
+	}
+	return ret
+}
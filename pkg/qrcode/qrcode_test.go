@@ -0,0 +1,170 @@
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRSRemainder(t *testing.T) {
+	// "HELLO WORLD" 1-M 的数据码字与纠错码字
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsGenerator(10)); !bytes.Equal(got, want) {
+		t.Errorf("rsRemainder = %v, want %v", got, want)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	if got := formatBits(0); got != 0b101010000010010 {
+		t.Errorf("formatBits(0) = %015b", got)
+	}
+	if got := versionBits(7); got != 0x07C94 {
+		t.Errorf("versionBits(7) = %#x", got)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	for _, n := range []int{1, 20, 60, 100, 150, 213} {
+		data := []byte(strings.Repeat("http://192.168.1.10:5030/", 10)[:n])
+		c, err := Encode(data)
+		if err != nil {
+			t.Fatalf("Encode(%d bytes): %v", n, err)
+		}
+		if got := decode(t, c); !bytes.Equal(got, data) {
+			t.Errorf("version %d: decoded %q, want %q", c.Version, got, data)
+		}
+	}
+	if _, err := Encode(make([]byte, 214)); err != ErrTooLong {
+		t.Errorf("Encode(214 bytes) error = %v, want ErrTooLong", err)
+	}
+}
+
+// decode 读取格式信息与码字，校验纠错码后还原数据，用于检查编码结果
+func decode(t *testing.T, c *Code) []byte {
+	t.Helper()
+
+	// 左上角的格式信息
+	bits := 0
+	read := func(x, y, i int) {
+		if c.Modules[y][x] {
+			bits |= 1 << i
+		}
+	}
+	for i := 0; i <= 5; i++ {
+		read(8, i, i)
+	}
+	read(8, 7, 6)
+	read(8, 8, 7)
+	read(7, 8, 8)
+	for i := 9; i < 15; i++ {
+		read(14-i, 8, i)
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m) == bits {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("invalid format bits %015b", bits)
+	}
+
+	// 去掉掩码后按之字形路径读取码字
+	plain := &Code{Version: c.Version, Size: c.Size, Modules: make([][]bool, c.Size), function: c.function}
+	for y := range c.Modules {
+		plain.Modules[y] = append([]bool(nil), c.Modules[y]...)
+	}
+	plain.applyMask(mask)
+	v := versions[c.Version]
+	total := v.dataCodewords() + v.ec*len(blocksOf(v))
+	codewords := make([]byte, total)
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if c.function[y][x] || i >= total*8 {
+					continue
+				}
+				if plain.Modules[y][x] {
+					codewords[i>>3] |= 1 << (7 - i&7)
+				}
+				i++
+			}
+		}
+	}
+
+	// 还原各块并以伴随式校验纠错码
+	sizes := blocksOf(v)
+	blocks := make([][]byte, len(sizes))
+	pos := 0
+	for k := 0; k < len(codewords); k++ {
+		for b := range blocks {
+			if len(blocks[b]) < sizes[b] {
+				blocks[b] = append(blocks[b], codewords[pos])
+				pos++
+			}
+		}
+		if pos >= v.dataCodewords() {
+			break
+		}
+	}
+	for k := 0; k < v.ec; k++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], codewords[pos])
+			pos++
+		}
+	}
+	var data []byte
+	for b, block := range blocks {
+		alpha := byte(1)
+		for k := 0; k < v.ec; k++ {
+			var s byte
+			for _, cw := range block {
+				s = gfMul(s, alpha) ^ cw
+			}
+			if s != 0 {
+				t.Fatalf("block %d: syndrome %d = %d", b, k, s)
+			}
+			alpha = gfMul(alpha, 2)
+		}
+		data = append(data, block[:sizes[b]]...)
+	}
+
+	if data[0]>>4 != 0x4 {
+		t.Fatalf("mode = %x, want byte mode", data[0]>>4)
+	}
+	if c.Version >= 10 {
+		n := int(data[0]&0xF)<<12 | int(data[1])<<4 | int(data[2]>>4)
+		return shift(data[2:], n)
+	}
+	n := int(data[0]&0xF)<<4 | int(data[1]>>4)
+	return shift(data[1:], n)
+}
+
+// blocksOf 返回各块的数据码字数
+func blocksOf(v version) []int {
+	var ret []int
+	for _, g := range v.blocks {
+		for i := 0; i < g[0]; i++ {
+			ret = append(ret, g[1])
+		}
+	}
+	return ret
+}
+
+// shift 读取从半字节处开始的 n 个字节
+func shift(data []byte, n int) []byte {
+	ret := make([]byte, n)
+	for i := range ret {
+		ret[i] = data[i]<<4 | data[i+1]>>4
+	}
+	return ret
+}
@@ -0,0 +1,77 @@
+package redact
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// 敏感信息类型
+const (
+	KindPhone    = "phone"
+	KindIDCard   = "id_card"
+	KindBankCard = "bank_card"
+	KindAddress  = "address"
+	KindPassword = "password"
+)
+
+var (
+	// addressRe 含门牌号的道路地址，或含楼栋号的小区地址
+	addressRe = regexp.MustCompile(`(\p{Han}{2,8}(省|市|区|县|镇))*\p{Han}{1,12}((路|街|大道|巷|弄)\d+号|(小区|花园|公寓|大厦|新村|家园)\d+(栋|幢|号楼|座))(\d+(单元|层|楼|室|号))*`)
+
+	// passwordRe 聊天中分享的密码，如 "密码是 abc123"、"pwd: xxx"
+	passwordRe = regexp.MustCompile(`(?i)(密码|口令|password|passwd|pwd)\s*(是|为|:|：|=)?\s*([^\s，。,;；]{4,64})`)
+)
+
+// Finding 文本中发现的一处疑似敏感信息，Text 为遮盖后的内容
+type Finding struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+}
+
+// Find 查找文本中疑似的手机号、身份证号、银行卡号、地址与密码
+func Find(s string) []Finding {
+	if s == "" {
+		return nil
+	}
+	ret := make([]Finding, 0)
+	for _, m := range spacedPhoneRe.FindAllString(s, -1) {
+		ret = append(ret, Finding{Kind: KindPhone, Text: maskDigits(m, 3, 4)})
+	}
+	for _, m := range spacedCardRe.FindAllString(s, -1) {
+		if luhn(strings.ReplaceAll(m, " ", "")) {
+			ret = append(ret, Finding{Kind: KindBankCard, Text: maskDigits(m, 4, 4)})
+		}
+	}
+	for _, m := range digitsRe.FindAllString(s, -1) {
+		if kind := numberKind(m); kind != "" {
+			ret = append(ret, Finding{Kind: kind, Text: maskNumber(m)})
+		}
+	}
+	for _, m := range addressRe.FindAllString(s, -1) {
+		ret = append(ret, Finding{Kind: KindAddress, Text: maskDigits(m, 0, 0)})
+	}
+	for _, m := range passwordRe.FindAllStringSubmatch(s, -1) {
+		if !hasDigitOrSymbol(m[3]) {
+			continue
+		}
+		ret = append(ret, Finding{Kind: KindPassword, Text: strings.TrimSuffix(m[0], m[3]) + maskSecret(m[3])})
+	}
+	return ret
+}
+
+// hasDigitOrSymbol 排除 "密码忘了"、"password reset" 一类的普通文字
+func hasDigitOrSymbol(s string) bool {
+	for _, r := range s {
+		if r < utf8.RuneSelf && !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return true
+		}
+	}
+	return false
+}
+
+// maskSecret 只保留首个字符
+func maskSecret(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(r) + strings.Repeat("*", utf8.RuneCountInString(s[size:]))
+}
@@ -0,0 +1,150 @@
+package redact
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+const (
+	// ImageBlur 高斯模糊，保留大致的色彩与构图
+	ImageBlur = "blur"
+
+	// ImageReplace 替换为同样尺寸的灰色占位图
+	ImageReplace = "replace"
+)
+
+const (
+	// blurSize 模糊前将图片缩小到的最大边长，缩小后再模糊速度快且无法还原细节
+	blurSize = 48
+
+	// blurRadius 缩小后的图片上的模糊半径
+	blurRadius = 2
+)
+
+// placeholderColor 占位图颜色
+var placeholderColor = color.RGBA{R: 0xd0, G: 0xd0, B: 0xd0, A: 0xff}
+
+// Image 按 mode 遮盖图片内容，输出与原图尺寸相同，页面布局不变
+// 无法解码的图片一律替换为默认尺寸的占位图，避免原图泄露
+func Image(data []byte, mode string) ([]byte, string, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		src, mode = nil, ImageReplace
+	}
+
+	var buf bytes.Buffer
+	if mode == ImageBlur {
+		if err := jpeg.Encode(&buf, blur(src), &jpeg.Options{Quality: 80}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+
+	w, h := 320, 240
+	if src != nil {
+		w, h = src.Bounds().Dx(), src.Bounds().Dy()
+	}
+	if err := png.Encode(&buf, placeholder(w, h)); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+// placeholder 单色占位图，调色板图片编码后很小
+func placeholder(w, h int) image.Image {
+	return image.NewPaletted(image.Rect(0, 0, w, h), color.Palette{placeholderColor})
+}
+
+// blur 缩小、三次盒式模糊近似高斯模糊，再双线性插值放大回原尺寸
+func blur(src image.Image) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	sw, sh := w, h
+	if w > blurSize || h > blurSize {
+		if w >= h {
+			sw, sh = blurSize, max(1, h*blurSize/w)
+		} else {
+			sw, sh = max(1, w*blurSize/h), blurSize
+		}
+	}
+
+	small := shrink(src, sw, sh)
+	for i := 0; i < 3; i++ {
+		boxBlur(small, sw, sh, blurRadius, 1, sw)
+		boxBlur(small, sh, sw, blurRadius, sw, 1)
+	}
+	return enlarge(small, sw, sh, w, h)
+}
+
+// shrink 缩小为 sw x sh，每个像素取对应区域内最多 8x8 个采样点的平均值
+func shrink(src image.Image, sw, sh int) []float64 {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	px := make([]float64, sw*sh*3)
+	for y := 0; y < sh; y++ {
+		y0, y1 := y*h/sh, max((y+1)*h/sh, y*h/sh+1)
+		for x := 0; x < sw; x++ {
+			x0, x1 := x*w/sw, max((x+1)*w/sw, x*w/sw+1)
+			stepX, stepY := max(1, (x1-x0)/8), max(1, (y1-y0)/8)
+			var r, g, bl, n float64
+			for sy := y0; sy < y1; sy += stepY {
+				for sx := x0; sx < x1; sx += stepX {
+					cr, cg, cb, _ := src.At(b.Min.X+sx, b.Min.Y+sy).RGBA()
+					r, g, bl, n = r+float64(cr>>8), g+float64(cg>>8), bl+float64(cb>>8), n+1
+				}
+			}
+			i := (y*sw + x) * 3
+			px[i], px[i+1], px[i+2] = r/n, g/n, bl/n
+		}
+	}
+	return px
+}
+
+// boxBlur 沿一个方向做盒式模糊，lines 条长度为 length 的像素线，step 为线内相邻像素的间隔，stride 为相邻线的间隔
+func boxBlur(px []float64, length, lines, radius, step, stride int) {
+	line := make([]float64, length*3)
+	for l := 0; l < lines; l++ {
+		base := l * stride
+		for i := 0; i < length; i++ {
+			copy(line[i*3:i*3+3], px[(base+i*step)*3:])
+		}
+		for i := 0; i < length; i++ {
+			var r, g, b float64
+			for k := -radius; k <= radius; k++ {
+				j := min(max(i+k, 0), length-1) * 3
+				r, g, b = r+line[j], g+line[j+1], b+line[j+2]
+			}
+			n := float64(2*radius + 1)
+			o := (base + i*step) * 3
+			px[o], px[o+1], px[o+2] = r/n, g/n, b/n
+		}
+	}
+}
+
+// enlarge 双线性插值放大到 w x h
+func enlarge(px []float64, sw, sh, w, h int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	at := func(x, y, c int) float64 {
+		return px[(min(y, sh-1)*sw+min(x, sw-1))*3+c]
+	}
+	for y := 0; y < h; y++ {
+		fy := max((float64(y)+0.5)*float64(sh)/float64(h)-0.5, 0)
+		y0, ty := int(fy), fy-float64(int(fy))
+		for x := 0; x < w; x++ {
+			fx := max((float64(x)+0.5)*float64(sw)/float64(w)-0.5, 0)
+			x0, tx := int(fx), fx-float64(int(fx))
+			o := dst.PixOffset(x, y)
+			for c := 0; c < 3; c++ {
+				top := at(x0, y0, c)*(1-tx) + at(x0+1, y0, c)*tx
+				bottom := at(x0, y0+1, c)*(1-tx) + at(x0+1, y0+1, c)*tx
+				dst.Pix[o+c] = uint8(top*(1-ty) + bottom*ty + 0.5)
+			}
+			dst.Pix[o+3] = 0xff
+		}
+	}
+	return dst
+}
@@ -0,0 +1,261 @@
+package redact
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	// digitsRe 连续的数字，身份证号末位可能为 X
+	digitsRe = regexp.MustCompile(`\d+[Xx]?`)
+
+	// idCardRe 18 位居民身份证号
+	idCardRe = regexp.MustCompile(`^[1-9]\d{5}(18|19|20)\d{2}(0[1-9]|1[0-2])(0[1-9]|[12]\d|3[01])\d{3}[\dXx]$`)
+
+	// phoneRe 中国大陆手机号
+	phoneRe = regexp.MustCompile(`^(86)?1[3-9]\d{9}$`)
+
+	// spacedPhoneRe 以空格或短横线分隔的手机号，如 138 1234 5678
+	spacedPhoneRe = regexp.MustCompile(`\b1[3-9]\d[- ]\d{4}[- ]\d{4}\b`)
+
+	// spacedCardRe 每四位空格分隔的银行卡号
+	spacedCardRe = regexp.MustCompile(`\b\d{4}( \d{4}){3}( \d{1,3})?\b`)
+
+	// wxidRe 微信 ID
+	wxidRe = regexp.MustCompile(`wxid_[0-9A-Za-z_-]{4,}`)
+
+	// blobRe base64 编码的二进制数据，如内嵌图片与 MCP 返回的媒体内容
+	blobRe = regexp.MustCompile(`[0-9A-Za-z+/]{256,}={0,2}`)
+)
+
+// Redactor 脱敏处理：遮盖手机号、身份证号、银行卡号与微信 ID，并将已知的名称替换为固定的化名
+// 相同的 salt 下同一个 ID 或名称总是得到相同的化名，便于对照分析
+type Redactor struct {
+	salt []byte
+
+	mu    sync.RWMutex
+	names *strings.Replacer
+}
+
+// New 创建脱敏处理器，salt 为空时随机生成，化名仅在本次运行中保持一致
+func New(salt string) *Redactor {
+	key := []byte(salt)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	return &Redactor{salt: key}
+}
+
+// hash 生成化名使用的短哈希
+func (r *Redactor) hash(kind, value string) string {
+	mac := hmac.New(sha256.New, r.salt)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:10]
+}
+
+// ID 微信 ID、微信号或群 ID 的化名，群 ID 保留 @chatroom 后缀
+func (r *Redactor) ID(id string) string {
+	if id == "" {
+		return ""
+	}
+	if name, ok := strings.CutSuffix(id, "@chatroom"); ok {
+		return "room_" + r.hash("id", name) + "@chatroom"
+	}
+	return "user_" + r.hash("id", id)
+}
+
+// Name 显示名称的化名，prefix 区分联系人与群聊，如 User、Group
+func (r *Redactor) Name(prefix, name string) string {
+	if name == "" {
+		return ""
+	}
+	return prefix + "-" + r.hash("name", name)[:6]
+}
+
+// SetNames 设置需要替换的原文与化名，较长的原文优先匹配
+// 过短的名称容易误伤正文，不做替换
+func (r *Redactor) SetNames(names map[string]string) {
+	keys := make([]string, 0, len(names))
+	for key := range names {
+		if replaceable(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+
+	pairs := make([]string, 0, len(keys)*2)
+	for _, key := range keys {
+		pairs = append(pairs, key, names[key])
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.names = nil
+	if len(pairs) > 0 {
+		r.names = strings.NewReplacer(pairs...)
+	}
+}
+
+// replaceable 至少 2 个字符，纯 ASCII 名称至少 3 个字符
+func replaceable(name string) bool {
+	n := utf8.RuneCountInString(name)
+	if n == len(name) {
+		return n >= 3
+	}
+	return n >= 2
+}
+
+// Text 脱敏一段文本，其中的 base64 数据保持不变，以免替换名称时破坏数据
+func (r *Redactor) Text(s string) string {
+	if s == "" {
+		return s
+	}
+	blobs := blobRe.FindAllStringIndex(s, -1)
+	if len(blobs) == 0 {
+		return r.text(s)
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range blobs {
+		b.WriteString(r.text(s[last:loc[0]]))
+		b.WriteString(s[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(r.text(s[last:]))
+	return b.String()
+}
+
+func (r *Redactor) text(s string) string {
+	if s == "" {
+		return s
+	}
+	s = spacedPhoneRe.ReplaceAllStringFunc(s, func(m string) string {
+		return maskDigits(m, 3, 4)
+	})
+	s = spacedCardRe.ReplaceAllStringFunc(s, func(m string) string {
+		digits := strings.ReplaceAll(m, " ", "")
+		if !luhn(digits) {
+			return m
+		}
+		return maskDigits(m, 4, 4)
+	})
+	s = digitsRe.ReplaceAllStringFunc(s, maskNumber)
+	s = wxidRe.ReplaceAllStringFunc(s, r.ID)
+
+	r.mu.RLock()
+	names := r.names
+	r.mu.RUnlock()
+	if names != nil {
+		s = names.Replace(s)
+	}
+	return s
+}
+
+// maskNumber 按长度与格式识别身份证号、手机号与银行卡号，其余数字保持不变
+func maskNumber(s string) string {
+	switch numberKind(s) {
+	case KindIDCard:
+		return maskDigits(s, 3, 2)
+	case KindPhone:
+		return maskDigits(s, len(s)-8, 4)
+	case KindBankCard:
+		return maskDigits(s, 4, 4)
+	}
+	return s
+}
+
+// numberKind 连续数字的类型，无法识别时返回空字符串
+func numberKind(s string) string {
+	switch {
+	case len(s) == 18 && idCardRe.MatchString(s):
+		return KindIDCard
+	case phoneRe.MatchString(s):
+		return KindPhone
+	case len(s) >= 16 && len(s) <= 19 && luhn(s):
+		return KindBankCard
+	}
+	return ""
+}
+
+// maskDigits 保留前 head 位与后 tail 位，中间的数字替换为 *
+func maskDigits(s string, head, tail int) string {
+	b := []byte(s)
+	for i := head; i < len(b)-tail; i++ {
+		if b[i] >= '0' && b[i] <= '9' || b[i] == 'X' || b[i] == 'x' {
+			b[i] = '*'
+		}
+	}
+	return string(b)
+}
+
+// luhn 银行卡号校验，减少将订单号等长数字误判为卡号
+func luhn(s string) bool {
+	sum := 0
+	double := false
+	for i := len(s) - 1; i >= 0; i-- {
+		d := int(s[i] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// JSON 脱敏 JSON 中的所有字符串与对象键，数字等其他值保持不变，解析失败时按文本处理
+func (r *Redactor) JSON(data []byte) []byte {
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return []byte(r.Text(string(data)))
+	}
+	ret, err := json.Marshal(r.value(v))
+	if err != nil {
+		return []byte(r.Text(string(data)))
+	}
+	return ret
+}
+
+func (r *Redactor) value(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		return r.Text(val)
+	case []interface{}:
+		for i := range val {
+			val[i] = r.value(val[i])
+		}
+		return val
+	case map[string]interface{}:
+		ret := make(map[string]interface{}, len(val))
+		for key, item := range val {
+			ret[r.Text(key)] = r.value(item)
+		}
+		return ret
+	default:
+		return v
+	}
+}
@@ -0,0 +1,136 @@
+package redact
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	r := New("test")
+	tests := []struct {
+		in, want string
+	}{
+		{"电话13812345678请回电", "电话138****5678请回电"},
+		{"电话 138 1234 5678", "电话 138 **** 5678"},
+		{"身份证 11010519491231002X", "身份证 110*************2X"},
+		{"卡号4111111111111111", "卡号4111********1111"},
+		{"卡号 4111 1111 1111 1111", "卡号 4111 **** **** 1111"},
+		{"订单 1234567890123456", "订单 1234567890123456"},
+		{"seq 12345", "seq 12345"},
+	}
+	for _, tt := range tests {
+		if got := r.Text(tt.in); got != tt.want {
+			t.Errorf("Text(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTextBlob(t *testing.T) {
+	r := New("test")
+	r.SetNames(map[string]string{"Tom": "User-1"})
+	blob := strings.Repeat("AbTom13812345678", 20)
+	in := "Tom 13812345678 data:image/png;base64," + blob
+	want := "User-1 138****5678 data:image/png;base64," + blob
+	if got := r.Text(in); got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+}
+
+func TestPseudonym(t *testing.T) {
+	r := New("test")
+	id := r.ID("wxid_abcdef123")
+	if id != New("test").ID("wxid_abcdef123") || id == New("other").ID("wxid_abcdef123") {
+		t.Fatalf("ID pseudonym should depend only on salt and value: %s", id)
+	}
+	if !strings.HasSuffix(r.ID("123@chatroom"), "@chatroom") {
+		t.Fatalf("chatroom ID should keep suffix")
+	}
+
+	got := r.Text("来自 wxid_abcdef123 的消息")
+	if got != "来自 "+id+" 的消息" {
+		t.Fatalf("wxid not replaced: %s", got)
+	}
+	if r.Text(got) != got {
+		t.Fatalf("redaction should be idempotent: %s", r.Text(got))
+	}
+
+	r.SetNames(map[string]string{"张三": "User-1", "张三丰": "User-2", "A": "User-3"})
+	if got := r.Text("张三丰和张三说A"); got != "User-2和User-1说A" {
+		t.Fatalf("names not replaced: %s", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	r := New("test")
+	r.SetNames(map[string]string{"张三": "User-1"})
+	got := string(r.JSON([]byte(`{"张三":1,"items":[{"content":"13812345678","seq":13812345678}]}`)))
+	want := `{"User-1":1,"items":[{"content":"138****5678","seq":13812345678}]}`
+	if got != want {
+		t.Fatalf("JSON = %s, want %s", got, want)
+	}
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []Finding
+	}{
+		{"我的手机 13812345678，卡号 4111 1111 1111 1111", []Finding{{KindBankCard, "4111 **** **** 1111"}, {KindPhone, "138****5678"}}},
+		{"寄到北京市朝阳区建国路88号2单元301室", []Finding{{KindAddress, "寄到北京市朝阳区建国路**号*单元***室"}}},
+		{"wifi 密码是 abc12345", []Finding{{KindPassword, "密码是 a*******"}}},
+		{"密码忘了怎么办", []Finding{}},
+		{"今天天气不错", []Finding{}},
+	}
+	for _, tt := range tests {
+		got := Find(tt.in)
+		if len(got) != len(tt.want) {
+			t.Errorf("Find(%q) = %v, want %v", tt.in, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Find(%q)[%d] = %v, want %v", tt.in, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			src.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: uint8((x * y) % 256), A: 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []string{ImageBlur, ImageReplace} {
+		data, contentType, err := Image(buf.Bytes(), mode)
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		img, format, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if contentType != "image/"+format {
+			t.Errorf("%s: content type %s, format %s", mode, contentType, format)
+		}
+		if img.Bounds().Dx() != 200 || img.Bounds().Dy() != 100 {
+			t.Errorf("%s: size %v, want 200x100", mode, img.Bounds())
+		}
+	}
+
+	// 无法解码的内容替换为占位图
+	data, contentType, err := Image([]byte("not an image"), ImageBlur)
+	if err != nil || contentType != "image/png" || len(data) == 0 {
+		t.Errorf("invalid image: %s, %v", contentType, err)
+	}
+}
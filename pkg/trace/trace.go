@@ -0,0 +1,217 @@
+// Package trace 记录请求的调用链路，以 OTLP/HTTP（JSON 编码）导出到 OpenTelemetry Collector、Jaeger 等后端
+// 未调用 Init 时所有操作均为空操作，Span 的方法可在 nil 上调用
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Span 类型，与 OTLP 中的 SpanKind 取值一致
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// EnvEndpoint 未配置导出地址时使用的标准环境变量
+const EnvEndpoint = "OTEL_EXPORTER_OTLP_ENDPOINT"
+
+// Config 导出配置
+type Config struct {
+	// OTLP/HTTP 地址，如 http://localhost:4318，未包含路径时追加 /v1/traces
+	// 为空时使用环境变量 OTEL_EXPORTER_OTLP_ENDPOINT
+	Endpoint string
+
+	// 请求导出地址时附加的请求头，如鉴权信息
+	Headers map[string]string
+
+	// 上报的服务名称，默认 chatlog
+	ServiceName string
+
+	// 服务版本
+	Version string
+}
+
+var global atomic.Pointer[Tracer]
+
+type spanKey struct{}
+
+// Attr 属性
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Attr      { return Attr{key, value} }
+func Int(key string, value int) Attr     { return Attr{key, int64(value)} }
+func Int64(key string, value int64) Attr { return Attr{key, value} }
+func Bool(key string, value bool) Attr   { return Attr{key, value} }
+
+// Init 开始导出，替换已有的导出器，未配置导出地址时返回 nil, nil
+func Init(conf Config) (*Tracer, error) {
+	if conf.Endpoint == "" {
+		conf.Endpoint = os.Getenv(EnvEndpoint)
+	}
+	if conf.Endpoint == "" {
+		return nil, nil
+	}
+	t, err := newTracer(conf)
+	if err != nil {
+		return nil, err
+	}
+	if old := global.Swap(t); old != nil {
+		old.Shutdown(context.Background())
+	}
+	return t, nil
+}
+
+// Enabled 是否正在导出
+func Enabled() bool {
+	return global.Load() != nil
+}
+
+// Start 开始一个内部 Span，ctx 中已有 Span 时作为其子 Span，未启用时返回原 ctx 与 nil
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	return start(ctx, name, KindInternal, attrs)
+}
+
+// StartServer 开始处理请求的 Span，traceparent 为请求头中的 W3C Trace Context，合法时延续调用方的链路
+func StartServer(ctx context.Context, name string, traceparent string, attrs ...Attr) (context.Context, *Span) {
+	if FromContext(ctx) == nil {
+		if traceID, spanID, ok := ParseTraceparent(traceparent); ok {
+			ctx = context.WithValue(ctx, spanKey{}, &Span{traceID: traceID, spanID: spanID})
+		}
+	}
+	return start(ctx, name, KindServer, attrs)
+}
+
+func start(ctx context.Context, name string, kind int, attrs []Attr) (context.Context, *Span) {
+	t := global.Load()
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+		attrs:  attrs,
+	}
+	if parent := FromContext(ctx); parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Detach 返回不含 Span 的 ctx，之后开始的 Span 作为新链路的起点，保留 ctx 的取消与超时
+func Detach(ctx context.Context) context.Context {
+	return context.WithValue(ctx, spanKey{}, (*Span)(nil))
+}
+
+// FromContext ctx 中当前的 Span，没有时返回 nil
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Span 一次操作的耗时与属性
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+
+	mu    sync.Mutex
+	end   time.Time
+	attrs []Attr
+	err   string
+	ended bool
+}
+
+// SetAttributes 添加属性
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil || s.tracer == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// SetError 标记失败，err 为 nil 时忽略
+func (s *Span) SetError(err error) {
+	if s == nil || s.tracer == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+// End 结束并提交导出，重复调用无效
+func (s *Span) End() {
+	if s == nil || s.tracer == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.export(s)
+}
+
+// TraceID 十六进制的链路 ID，nil 时为空
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// Traceparent W3C Trace Context 格式的请求头，用于向下游传递链路
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%x-%x-01", s.traceID, s.spanID)
+}
+
+// ParseTraceparent 解析 W3C Trace Context 请求头，格式为 00-<trace-id>-<parent-id>-<flags>
+func ParseTraceparent(v string) (traceID [16]byte, spanID [8]byte, ok bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return traceID, spanID, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return traceID, spanID, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil {
+		return traceID, spanID, false
+	}
+	if _, err := hex.Decode(spanID[:], []byte(parts[2])); err != nil {
+		return traceID, spanID, false
+	}
+	if traceID == [16]byte{} || spanID == [8]byte{} {
+		return traceID, spanID, false
+	}
+	return traceID, spanID, true
+}
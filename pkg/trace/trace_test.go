@@ -0,0 +1,97 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-xyz-00f067aa0ba902b7-01", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if _, _, ok := ParseTraceparent(tt.in); ok != tt.want {
+			t.Errorf("ParseTraceparent(%q) = %v, want %v", tt.in, ok, tt.want)
+		}
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	ctx, span := Start(context.Background(), "noop")
+	if span != nil || FromContext(ctx) != nil {
+		t.Fatal("span created without tracer")
+	}
+	span.SetAttributes(String("k", "v"))
+	span.SetError(errors.New("x"))
+	span.End()
+}
+
+func TestExport(t *testing.T) {
+	got := make(chan otlpRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "token" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		got <- req
+	}))
+	defer srv.Close()
+
+	tracer, err := Init(Config{Endpoint: srv.URL, Headers: map[string]string{"Authorization": "token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := StartServer(context.Background(), "GET /", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, child := Start(ctx, "query", Int("rows", 3))
+	child.SetError(errors.New("failed"))
+	child.End()
+	parent.End()
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if Enabled() {
+		t.Fatal("tracer still enabled after shutdown")
+	}
+
+	req := <-got
+	if len(req.ResourceSpans) != 1 || req.ResourceSpans[0].Resource.Attributes[0].Value.StringValue == nil ||
+		*req.ResourceSpans[0].Resource.Attributes[0].Value.StringValue != "chatlog" {
+		t.Fatalf("unexpected resource %+v", req.ResourceSpans)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	query, root := spans[0], spans[1]
+	if root.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || root.ParentSpanID != "00f067aa0ba902b7" || root.Kind != KindServer {
+		t.Errorf("unexpected server span %+v", root)
+	}
+	if query.TraceID != root.TraceID || query.ParentSpanID != root.SpanID || query.Kind != KindInternal {
+		t.Errorf("unexpected child span %+v", query)
+	}
+	if query.Status == nil || query.Status.Code != statusError || query.Status.Message != "failed" {
+		t.Errorf("unexpected status %+v", query.Status)
+	}
+	if len(query.Attributes) != 1 || query.Attributes[0].Value.IntValue == nil || *query.Attributes[0].Value.IntValue != "3" {
+		t.Errorf("unexpected attributes %+v", query.Attributes)
+	}
+}
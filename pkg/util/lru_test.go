@@ -0,0 +1,34 @@
+package util
+
+import "testing"
+
+func TestLRU(t *testing.T) {
+	c := NewLRU[string, int](2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+
+	// 访问 a 后 b 成为最久未访问的条目
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %d, %v", v, ok)
+	}
+	c.Add("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("b should be evicted")
+	}
+	if v, ok := c.Get("c"); !ok || v != 3 {
+		t.Fatalf("Get(c) = %d, %v", v, ok)
+	}
+
+	c.Add("a", 10)
+	if v, _ := c.Get("a"); v != 10 {
+		t.Fatalf("Get(a) = %d, want 10", v)
+	}
+	if c.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", c.Len())
+	}
+
+	c.Purge()
+	if _, ok := c.Get("a"); ok || c.Len() != 0 {
+		t.Fatal("cache should be empty after Purge")
+	}
+}
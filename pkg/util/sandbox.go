@@ -0,0 +1,67 @@
+package util
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// ErrOutsideRoot 路径不在允许访问的根目录内
+var ErrOutsideRoot = errors.New("path is outside of allowed roots")
+
+// Sandbox 将文件访问限制在若干根目录内，根目录与访问路径都会解析符号链接后再比较
+type Sandbox struct {
+	roots []string
+}
+
+// NewSandbox 创建文件访问沙箱，忽略为空或不存在的根目录
+func NewSandbox(roots ...string) *Sandbox {
+	sb := &Sandbox{}
+	for _, root := range roots {
+		if root == "" {
+			continue
+		}
+		if real, err := realPath(root); err == nil {
+			sb.roots = append(sb.roots, real)
+		}
+	}
+	return sb
+}
+
+// Resolve 将 base 下的相对路径解析为真实路径
+// name 中的 .. 不会越过 base；通过符号链接指向允许的根目录之外的文件时返回 ErrOutsideRoot，文件不存在时返回对应的错误
+func (sb *Sandbox) Resolve(base, name string) (string, error) {
+	if base == "" {
+		return "", ErrOutsideRoot
+	}
+	real, err := realPath(filepath.Join(base, filepath.Clean(string(filepath.Separator)+name)))
+	if err != nil {
+		return "", err
+	}
+	if !sb.Contains(real) {
+		return "", ErrOutsideRoot
+	}
+	return real, nil
+}
+
+// Contains 真实路径是否位于某个根目录内
+func (sb *Sandbox) Contains(real string) bool {
+	for _, root := range sb.roots {
+		rel, err := filepath.Rel(root, real)
+		if err != nil || filepath.IsAbs(rel) {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func realPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
@@ -0,0 +1,52 @@
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSandboxResolve(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "sub", "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Skip("symlink not supported:", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "sub", "a.txt"), filepath.Join(root, "inner.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	sb := NewSandbox(root)
+
+	if _, err := sb.Resolve(root, "sub/a.txt"); err != nil {
+		t.Errorf("Resolve(sub/a.txt) error = %v", err)
+	}
+	if _, err := sb.Resolve(root, "inner.txt"); err != nil {
+		t.Errorf("Resolve(inner.txt) error = %v", err)
+	}
+	if _, err := sb.Resolve(root, "link.txt"); !errors.Is(err, ErrOutsideRoot) {
+		t.Errorf("Resolve(link.txt) error = %v, want ErrOutsideRoot", err)
+	}
+	if _, err := sb.Resolve(root, "../"+filepath.Base(outside)+"/secret.txt"); !os.IsNotExist(err) {
+		t.Errorf("Resolve(../) error = %v, want not exist", err)
+	}
+	if _, err := sb.Resolve("", "sub/a.txt"); !errors.Is(err, ErrOutsideRoot) {
+		t.Errorf("Resolve with empty base error = %v, want ErrOutsideRoot", err)
+	}
+
+	// 显式配置的根目录允许访问
+	sb = NewSandbox(root, outside)
+	if _, err := sb.Resolve(root, "link.txt"); err != nil {
+		t.Errorf("Resolve(link.txt) with extra root error = %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package util
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"unicode"
@@ -69,3 +70,32 @@ func Str2List(str string, sep string) []string {
 
 	return list
 }
+
+var urlRegexp = regexp.MustCompile(`https?://[^\s<>"'\x{3000}-\x{303F}\x{FF01}-\x{FF5E}]+`)
+
+// ExtractURLs 提取文本中出现的所有 http/https 链接，按出现顺序返回
+func ExtractURLs(text string) []string {
+	matches := urlRegexp.FindAllString(text, -1)
+	urls := make([]string, 0, len(matches))
+	for _, u := range matches {
+		u = strings.TrimRight(u, ".,;:!?)]}")
+		if len(u) > len("https://") {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// EstimateTokens 粗略估算文本的 token 数，用于控制返回给大模型的内容长度
+// 中日韩文字约 1 字 1 个 token，其他字符约 4 个 1 个 token
+func EstimateTokens(s string) int {
+	cjk, other := 0, 0
+	for _, r := range s {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
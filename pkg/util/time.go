@@ -431,6 +431,17 @@ func TimeOf(str string) (t time.Time, ok bool) {
 	return
 }
 
+// CutoffOf 解析保留期限，返回截止时间点
+// 支持距今的时长 180d、26w、6m、1y（截止到当天 00:00:00），以及 TimeOf 支持的时间格式
+func CutoffOf(str string) (time.Time, bool) {
+	str = strings.TrimSpace(str)
+	if matched, _ := regexp.MatchString(`^\d+[dwmy]$`, str); matched {
+		start, _, ok := TimeRangeOf("last-" + str)
+		return start, ok
+	}
+	return TimeOf(str)
+}
+
 // TimeRangeOf 解析各种格式的时间范围
 // 支持以下格式:
 // 1. 单个时间点: 根据时间粒度确定合适的时间范围
@@ -918,6 +918,30 @@ func TestTimeRangeOf(t *testing.T) {
 	}
 }
 
+func TestCutoffOf(t *testing.T) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	tests := []struct {
+		input  string
+		want   time.Time
+		wantOk bool
+	}{
+		{"180d", today.AddDate(0, 0, -180), true},
+		{"2w", today.AddDate(0, 0, -14), true},
+		{"6m", today.AddDate(0, -6, 0), true},
+		{"1y", today.AddDate(-1, 0, 0), true},
+		{"2024-01-01", time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local), true},
+		{"0d", time.Time{}, false},
+		{"abc", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := CutoffOf(tt.input)
+		if ok != tt.wantOk || (ok && !got.Equal(tt.want)) {
+			t.Errorf("CutoffOf(%q) = %v, %v, want %v, %v", tt.input, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
 // 测试边界情况
 func TestTimeOfEdgeCases(t *testing.T) {
 	// 测试非常长的数字字符串
@@ -0,0 +1,48 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		origin  string
+		origins []string
+		want    bool
+	}{
+		{"", nil, true},
+		{"http://127.0.0.1:5030", nil, true},
+		{"http://127.0.0.1:5031", nil, false},
+		{"https://evil.example", nil, false},
+		{"https://evil.example", []string{"https://evil.example/"}, true},
+		{"null", nil, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://127.0.0.1:5030/ws/chatlog", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := CheckOrigin(r, tt.origins); got != tt.want {
+			t.Errorf("CheckOrigin(%q, %v) = %v, want %v", tt.origin, tt.origins, got, tt.want)
+		}
+	}
+}
+
+func TestUpgradeRejectsCrossOrigin(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://127.0.0.1:5030/ws/chatlog?talker=x", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	r.Header.Set("Origin", "https://evil.example")
+
+	w := httptest.NewRecorder()
+	if _, err := Upgrade(w, r); err != ErrBadOrigin {
+		t.Fatalf("Upgrade() error = %v, want %v", err, ErrBadOrigin)
+	}
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}